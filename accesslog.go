@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Access log formats
+const (
+	accessLogCommon   = "common"   // Common Log Format of Apache and nginx
+	accessLogCombined = "combined" // Common Log Format with referer and User-Agent
+	accessLogJSON     = "json"     // one JSON object per request
+)
+
+// secretParams are query parameters whose values are replaced in logged URIs
+var secretParams = []string{"password", "passwd", "pass", "token", "key"}
+
+// AccessLogger writes one line per HTTP request, separate from the application log
+type AccessLogger struct {
+	format string
+	now    func() time.Time
+	// country returns the GeoIP country of a connection address, nil omits it
+	country func(ip string) string
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewAccessLogger creates an access logger writing lines in format to out,
+// masked according to the log privacy mode
+func NewAccessLogger(out io.Writer, format, privacy string) *AccessLogger {
+	if privacy != "" && privacy != logPrivacyOff {
+		out = newRedactingWriter(out, privacy)
+	}
+	return &AccessLogger{format: format, out: out, now: time.Now}
+}
+
+// accessEntry describes a served request
+type accessEntry struct {
+	Time      string  `json:"time"`
+	Remote    string  `json:"remote"`
+	User      string  `json:"user,omitempty"`
+	Method    string  `json:"method"`
+	URI       string  `json:"uri"`
+	Proto     string  `json:"proto"`
+	Status    int     `json:"status"`
+	Bytes     int     `json:"bytes"`
+	Duration  float64 `json:"duration_ms"`
+	Referer   string  `json:"referer,omitempty"`
+	UserAgent string  `json:"user_agent,omitempty"`
+	Country   string  `json:"country,omitempty"`
+	Forwarded string  `json:"forwarded,omitempty"`
+}
+
+// Log writes the entry for r answered with status and a body of size bytes.
+// The remote host is the connection address, X-Forwarded-For is chosen by the
+// client and only logged as an extra field.
+func (l *AccessLogger) Log(r *http.Request, start time.Time, status, size int) {
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		user, _, _ = queryCredentials(r)
+	}
+	entry := accessEntry{
+		Remote:    remoteIP(r),
+		User:      user,
+		Method:    r.Method,
+		URI:       redactURI(r.URL),
+		Proto:     r.Proto,
+		Status:    status,
+		Bytes:     size,
+		Duration:  float64(l.now().Sub(start).Microseconds()) / 1000,
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		Forwarded: sanitizeLogField(r.Header.Get("X-Forwarded-For")),
+	}
+	if l.country != nil {
+		entry.Country = l.country(entry.Remote)
+	}
+
+	var line string
+	switch l.format {
+	case accessLogJSON:
+		entry.Time = start.UTC().Format(time.RFC3339Nano)
+		data, _ := json.Marshal(entry)
+		line = string(data)
+	default:
+		line = fmt.Sprintf(`%s - %s [%s] "%s %s %s" %d %s`,
+			entry.Remote, clfField(sanitizeLogField(user)), start.Format("02/Jan/2006:15:04:05 -0700"),
+			entry.Method, entry.URI, entry.Proto, status, clfBytes(size))
+		if l.format == accessLogCombined {
+			line += fmt.Sprintf(" %q %q", entry.Referer, entry.UserAgent)
+		}
+		// Appended like nginx's $http_x_forwarded_for, so the fields before keep their positions
+		if entry.Forwarded != "" {
+			line += fmt.Sprintf(" %q", entry.Forwarded)
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.out, line)
+}
+
+// clfField returns value or "-" for an empty field of the Common Log Format
+func clfField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// clfBytes returns the body size, "-" for an empty body as in the Common Log Format
+func clfBytes(size int) string {
+	if size == 0 {
+		return "-"
+	}
+	return strconv.Itoa(size)
+}
+
+// redactURI returns the request URI with the values of credential parameters replaced
+func redactURI(u *url.URL) string {
+	if u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	query := u.Query()
+	redacted := false
+	for _, param := range secretParams {
+		if query.Has(param) {
+			query.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return u.RequestURI()
+	}
+	return u.EscapedPath() + "?" + query.Encode()
+}
+
+// accessRecorder captures the status and body size of a response for the access log
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (a *accessRecorder) WriteHeader(status int) {
+	if a.status == 0 {
+		a.status = status
+	}
+	a.ResponseWriter.WriteHeader(status)
+}
+
+func (a *accessRecorder) Write(p []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(p)
+	a.size += n
+	return n, err
+}
+
+// Wrap logs every request served by next
+func (l *AccessLogger) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := l.now()
+		recorder := &accessRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.status == 0 {
+			recorder.status = http.StatusOK
+		}
+		l.Log(r, start, recorder.status, recorder.size)
+	})
+}
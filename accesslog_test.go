@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAccessLogger(t *testing.T) {
+	start := time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)
+	tests := []struct {
+		name      string
+		format    string
+		privacy   string
+		target    string
+		forwarded string
+		expected  string
+	}{
+		{
+			name:     "common log format",
+			format:   accessLogCommon,
+			target:   "/nic/update?hostname=home.example.com&myip=1.2.3.4",
+			expected: `203.0.113.9 - admin [01/Mar/2024:12:30:45 +0000] "GET /nic/update?hostname=home.example.com&myip=1.2.3.4 HTTP/1.1" 200 12` + "\n",
+		},
+		{
+			name:     "combined log format",
+			format:   accessLogCombined,
+			target:   "/health",
+			expected: `203.0.113.9 - admin [01/Mar/2024:12:30:45 +0000] "GET /health HTTP/1.1" 200 12 "https://example.com/" "Fritz!Box DDNS/1.0.1"` + "\n",
+		},
+		{
+			name:     "query credentials are redacted",
+			format:   accessLogCommon,
+			target:   "/update?hostname=home.example.com&password=secret",
+			expected: `203.0.113.9 - admin [01/Mar/2024:12:30:45 +0000] "GET /update?hostname=home.example.com&password=REDACTED HTTP/1.1" 200 12` + "\n",
+		},
+		{
+			name:      "forwarded address is logged separately",
+			format:    accessLogCommon,
+			target:    "/health",
+			forwarded: "198.51.100.1 - evil [01/Mar/2024:00:00:00 +0000]",
+			expected:  `203.0.113.9 - admin [01/Mar/2024:12:30:45 +0000] "GET /health HTTP/1.1" 200 12 "198.51.100.1_-_evil_[01/Mar/2024:00:00:00_+0000]"` + "\n",
+		},
+		{
+			name:     "log privacy",
+			format:   accessLogCommon,
+			privacy:  logPrivacyIP,
+			target:   "/health",
+			expected: `203.0.113.0 - admin [01/Mar/2024:12:30:45 +0000] "GET /health HTTP/1.1" 200 12` + "\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := NewAccessLogger(&out, tt.format, tt.privacy)
+			logger.now = func() time.Time { return start }
+			handler := logger.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("good 1.2.3.4"))
+			}))
+
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.RemoteAddr = "203.0.113.9:40000"
+			req.SetBasicAuth("admin", "password")
+			req.Header.Set("Referer", "https://example.com/")
+			req.Header.Set("User-Agent", "Fritz!Box DDNS/1.0.1")
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if out.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestAccessLoggerJSON(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewAccessLogger(&out, accessLogJSON, logPrivacyOff)
+	handler := logger.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	}))
+	req := httptest.NewRequest("POST", "/api/records?token=secret", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry accessEntry
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", out.String(), err)
+	}
+	if entry.Method != "POST" || entry.URI != "/api/records?token=REDACTED" || entry.Status != http.StatusUnauthorized || entry.Bytes != len("Unauthorized\n") || entry.User != "" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+	if entry.Remote != "192.0.2.1" || entry.Forwarded != "198.51.100.1" {
+		t.Errorf("Unexpected entry %+v", entry)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AdGuardBackend writes local DNS records as DNS rewrites through the AdGuard Home API
+type AdGuardBackend struct {
+	baseURL  string
+	user     string
+	password string
+	client   *http.Client
+}
+
+// adguardRewrite is a DNS rewrite of GET /control/rewrite/list
+type adguardRewrite struct {
+	Domain string `json:"domain"`
+	Answer string `json:"answer"`
+}
+
+// NewAdGuardBackend creates a backend for the AdGuard Home instance at baseURL
+func NewAdGuardBackend(baseURL, user, password string) *AdGuardBackend {
+	return &AdGuardBackend{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		user:     user,
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SetRecord replaces the rewrites of hostname with the same address family as value
+func (b *AdGuardBackend) SetRecord(hostname, recordType, value string) error {
+	var rewrites []adguardRewrite
+	if err := b.do("GET", "/control/rewrite/list", nil, &rewrites); err != nil {
+		return err
+	}
+
+	exists := false
+	for _, rewrite := range rewrites {
+		if !strings.EqualFold(rewrite.Domain, hostname) || !isAddressOf(recordType, rewrite.Answer) {
+			continue
+		}
+		if rewrite.Answer == value {
+			exists = true
+			continue
+		}
+		if err := b.do("POST", "/control/rewrite/delete", rewrite, nil); err != nil {
+			return err
+		}
+	}
+	if exists {
+		return nil
+	}
+	return b.do("POST", "/control/rewrite/add", adguardRewrite{Domain: hostname, Answer: value}, nil)
+}
+
+// do sends body as JSON to path and decodes the response into out unless it is nil
+func (b *AdGuardBackend) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, b.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if b.user != "" {
+		req.SetBasicAuth(b.user, b.password)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkDeliveryStatus(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
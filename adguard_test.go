@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdGuardSetRecord(t *testing.T) {
+	var requests []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path == "/control/rewrite/list" {
+			w.Write([]byte(`[{"domain":"nas.example.com","answer":"192.168.178.9"},{"domain":"nas.example.com","answer":"fd00::9"},{"domain":"tv.example.com","answer":"192.168.178.20"}]`))
+			return
+		}
+		var rewrite adguardRewrite
+		json.NewDecoder(r.Body).Decode(&rewrite)
+		requests = append(requests, r.URL.Path+" "+rewrite.Domain+" "+rewrite.Answer)
+	}))
+	defer api.Close()
+
+	backend := NewAdGuardBackend(api.URL, "admin", "secret")
+	if err := backend.SetRecord("nas.example.com", "AAAA", "fd00::10"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "/control/rewrite/delete nas.example.com fd00::9;/control/rewrite/add nas.example.com fd00::10"
+	if got := strings.Join(requests, ";"); got != expected {
+		t.Errorf("Expected requests %q, got %q", expected, got)
+	}
+
+	requests = nil
+	if err := backend.SetRecord("tv.example.com", "A", "192.168.178.20"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(requests) != 0 {
+		t.Errorf("Expected no changes for an existing rewrite, got %v", requests)
+	}
+
+	backend.password = "wrong"
+	if err := backend.SetRecord("nas.example.com", "A", "192.168.178.10"); err == nil {
+		t.Error("Expected rejected credentials to fail")
+	}
+}
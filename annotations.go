@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxAnnotationLength bounds each annotation field, the whole marker has to
+// fit into a single 255 byte TXT string
+const maxAnnotationLength = 48
+
+// recordAnnotation is the metadata kept in the ownership marker of a record.
+// The Hetzner DNS API has no record comments, so the TXT marker carries it.
+type recordAnnotation struct {
+	// CreatedBy is the user whose update created the record, for records from
+	// before annotations were enabled the user of the first annotated update
+	CreatedBy string `json:"created_by,omitempty"`
+	// Device is the client software of the last update, e.g. "fritz!box" or "ddclient"
+	Device string `json:"device,omitempty"`
+	// ClientIP is the address the last update was sent from
+	ClientIP string `json:"client_ip,omitempty"`
+}
+
+// annotationTracker keeps the client of the latest update request per hostname
+// and the annotations last written per record
+type annotationTracker struct {
+	mu      sync.Mutex
+	pending map[string]recordAnnotation
+	written map[string]recordAnnotation
+}
+
+// newAnnotationTracker creates an empty tracker
+func newAnnotationTracker() *annotationTracker {
+	return &annotationTracker{pending: make(map[string]recordAnnotation), written: make(map[string]recordAnnotation)}
+}
+
+// SetClient remembers the client updating hostname
+func (t *annotationTracker) SetClient(hostname string, annotation recordAnnotation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[hostname] = annotation
+}
+
+// Client returns the client of the latest update of hostname, if known
+func (t *annotationTracker) Client(hostname string) (recordAnnotation, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	annotation, ok := t.pending[hostname]
+	return annotation, ok
+}
+
+// SetWritten remembers the annotation stored for hostname and recordType
+func (t *annotationTracker) SetWritten(hostname, recordType string, annotation recordAnnotation) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.written[hostname+"/"+recordType] = annotation
+}
+
+// Written returns the annotation stored for hostname and recordType, nil if it is unknown
+func (t *annotationTracker) Written(hostname, recordType string) *recordAnnotation {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	annotation, ok := t.written[hostname+"/"+recordType]
+	if !ok {
+		return nil
+	}
+	return &annotation
+}
+
+// requestAnnotation describes the client sending the update request r
+func requestAnnotation(r *http.Request) recordAnnotation {
+	return recordAnnotation{CreatedBy: requestUsername(r), Device: agentName(r.UserAgent()), ClientIP: getClientIP(r)}
+}
+
+// annotationField makes value safe for the comma separated marker
+func annotationField(value string) string {
+	value = strings.Map(func(c rune) rune {
+		if c == ',' || c == '=' || c == '"' || c == '\\' || c < ' ' || c > '~' {
+			return '_'
+		}
+		return c
+	}, value)
+	if len(value) > maxAnnotationLength {
+		value = value[:maxAnnotationLength]
+	}
+	return value
+}
+
+// annotatedOwnershipValue returns the marker value for owner carrying annotation
+func annotatedOwnershipValue(owner string, annotation recordAnnotation) string {
+	value := fmt.Sprintf("heritage=%s,owner=%s", ownershipHeritage, owner)
+	for _, field := range []struct{ key, value string }{
+		{"created-by", annotation.CreatedBy},
+		{"device", annotation.Device},
+		{"client-ip", annotation.ClientIP},
+	} {
+		if field.value != "" {
+			value += "," + field.key + "=" + annotationField(field.value)
+		}
+	}
+	return `"` + value + `"`
+}
+
+// parseOwnershipValue splits a marker value into its key=value fields
+func parseOwnershipValue(value string) map[string]string {
+	fields := map[string]string{}
+	for _, item := range strings.Split(strings.Trim(value, `"`), ",") {
+		if key, field, ok := strings.Cut(item, "="); ok {
+			fields[key] = field
+		}
+	}
+	return fields
+}
+
+// markerAnnotation returns the annotation stored in a marker value, nil if it has none
+func markerAnnotation(value string) *recordAnnotation {
+	fields := parseOwnershipValue(value)
+	annotation := recordAnnotation{CreatedBy: fields["created-by"], Device: fields["device"], ClientIP: fields["client-ip"]}
+	if annotation == (recordAnnotation{}) {
+		return nil
+	}
+	return &annotation
+}
+
+// annotateRecord writes the client of the latest update of hostname into the
+// marker of the existing record if it changed, keeping the record's creator
+func (s *DynDNSServer) annotateRecord(lookup *recordLookup, hostname, recordType string) {
+	if s.annotations == nil || s.ownerID == "" {
+		return
+	}
+	client, ok := s.annotations.Client(hostname)
+	if !ok {
+		return
+	}
+	marker := findOwnershipRecord(lookup.Records, lookup.Name, recordType, s.ownerID)
+	if marker == nil {
+		return
+	}
+
+	annotation := client
+	if existing := markerAnnotation(marker.Value); existing != nil {
+		if existing.CreatedBy != "" {
+			annotation.CreatedBy = existing.CreatedBy
+		}
+		if *existing == annotation {
+			s.annotations.SetWritten(hostname, recordType, annotation)
+			return
+		}
+	}
+
+	ttl := defaultRecordTTL
+	_, err := lookup.Client.UpdateRecord(marker.ID, UpdateRecordRequest{
+		ZoneID: lookup.Zone.ID,
+		Type:   "TXT",
+		Name:   marker.Name,
+		Value:  annotatedOwnershipValue(s.ownerID, annotation),
+		TTL:    &ttl,
+	})
+	if err != nil {
+		log.Printf("Failed to update annotation of %s %s: %v", recordType, hostname, err)
+		return
+	}
+	s.annotations.SetWritten(hostname, recordType, annotation)
+}
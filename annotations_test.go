@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAnnotatedOwnershipValue(t *testing.T) {
+	value := annotatedOwnershipValue("bridge1", recordAnnotation{CreatedBy: "admin", Device: "fritz,box", ClientIP: "203.0.113.7"})
+	if value != `"heritage=hetzner-dyndns,owner=bridge1,created-by=admin,device=fritz_box,client-ip=203.0.113.7"` {
+		t.Errorf("Unexpected marker value %s", value)
+	}
+
+	records := []DNSRecord{{ID: "m1", Type: "TXT", Name: "_dyndns-a.home", Value: value}}
+	if !isOwnedRecord(records, "home", "A", "bridge1") {
+		t.Error("Expected annotated marker to mark the record as owned")
+	}
+	if isOwnedRecord(records, "home", "A", "bridge2") {
+		t.Error("Expected marker of another owner to be ignored")
+	}
+
+	annotation := markerAnnotation(value)
+	if annotation == nil || annotation.CreatedBy != "admin" || annotation.Device != "fritz_box" || annotation.ClientIP != "203.0.113.7" {
+		t.Errorf("Unexpected annotation %+v", annotation)
+	}
+	if markerAnnotation(ownershipValue("bridge1")) != nil {
+		t.Error("Expected plain marker to have no annotation")
+	}
+}
+
+func TestRequestAnnotationUser(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	if err := server.configureAuth(AuthConfig{Update: "token", Tokens: []string{"secret-token"}}); err != nil {
+		t.Fatalf("configureAuth failed: %v", err)
+	}
+
+	// The username next to a token is not what authenticated the request
+	req := httptest.NewRequest("GET", "/update?username=alice&password=guess", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+	if !server.authorize(httptest.NewRecorder(), req, authScopeUpdate) {
+		t.Fatal("Expected the token to be accepted")
+	}
+	if annotation := requestAnnotation(req); annotation.CreatedBy != "token" {
+		t.Errorf("Expected the record to be attributed to the token, got %s", annotation.CreatedBy)
+	}
+}
+
+func TestUpdateAnnotatesRecords(t *testing.T) {
+	tests := []struct {
+		name           string
+		records        []DNSRecord
+		expectedWrites []string
+	}{
+		{
+			name:           "new record gets annotated marker",
+			expectedWrites: []string{"POST A home 203.0.113.7", `POST TXT _dyndns-a.home "heritage=hetzner-dyndns,owner=bridge1,created-by=admin,device=ddclient,client-ip=192.0.2.1"`},
+		},
+		{
+			name: "existing marker keeps creator",
+			records: []DNSRecord{
+				{ID: "rec1", Type: "A", Name: "home", Value: "198.51.100.1"},
+				{ID: "rec2", Type: "TXT", Name: "_dyndns-a.home", Value: annotatedOwnershipValue("bridge1", recordAnnotation{CreatedBy: "alice", Device: "fritz!box", ClientIP: "198.51.100.1"})},
+			},
+			expectedWrites: []string{"PUT A home 203.0.113.7", `PUT TXT _dyndns-a.home "heritage=hetzner-dyndns,owner=bridge1,created-by=alice,device=ddclient,client-ip=192.0.2.1"`},
+		},
+		{
+			name: "unchanged annotation is not written",
+			records: []DNSRecord{
+				{ID: "rec1", Type: "A", Name: "home", Value: "203.0.113.7"},
+				{ID: "rec2", Type: "TXT", Name: "_dyndns-a.home", Value: annotatedOwnershipValue("bridge1", recordAnnotation{CreatedBy: "alice", Device: "ddclient", ClientIP: "192.0.2.1"})},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			fake := newTestFake(t, tt.records...)
+			mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "GET" {
+					body, _ := io.ReadAll(r.Body)
+					r.Body = io.NopCloser(bytes.NewReader(body))
+					var req CreateRecordRequest
+					json.Unmarshal(body, &req)
+					writes = append(writes, r.Method+" "+req.Type+" "+req.Name+" "+req.Value)
+				}
+				fake.ServeHTTP(w, r)
+			}))
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.ownerID = "bridge1"
+			server.annotations = newAnnotationTracker()
+
+			req := httptest.NewRequest("GET", "/update?hostname=home.example.com&myip=203.0.113.7", nil)
+			req.SetBasicAuth("admin", "password")
+			req.Header.Set("User-Agent", "ddclient/3.11.2")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if !strings.HasPrefix(w.Body.String(), "good") && !strings.HasPrefix(w.Body.String(), "nochg") {
+				t.Fatalf("Unexpected response %q", w.Body.String())
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %q, got %q", tt.expectedWrites, writes)
+			}
+			if annotation := server.annotations.Written("home.example.com", "A"); annotation == nil || annotation.ClientIP != "192.0.2.1" {
+				t.Errorf("Expected written annotation in status, got %+v", annotation)
+			}
+		})
+	}
+}
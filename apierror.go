@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// apiErrorCodes maps HTTP status codes to the machine-readable codes of API errors
+var apiErrorCodes = map[int]string{
+	http.StatusBadRequest:           "bad_request",
+	http.StatusUnauthorized:         "unauthorized",
+	http.StatusForbidden:            "forbidden",
+	http.StatusNotFound:             "not_found",
+	http.StatusMethodNotAllowed:     "method_not_allowed",
+	http.StatusConflict:             "conflict",
+	http.StatusUnsupportedMediaType: "unsupported_media_type",
+	http.StatusTooManyRequests:      "rate_limited",
+	http.StatusInternalServerError:  "internal_error",
+	http.StatusBadGateway:           "upstream_error",
+	http.StatusServiceUnavailable:   "unavailable",
+}
+
+// validRequestID restricts request IDs taken from clients to safe log and header values
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// apiError is the JSON error envelope of the /api/ endpoints
+type apiError struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id"`
+	// Details names what was wrong, e.g. the missing parameter
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// httpError answers a failed request with the JSON envelope below /api/ and as plain
+// text elsewhere, so the dyndns2 endpoints keep the responses their clients expect
+func httpError(w http.ResponseWriter, r *http.Request, message string, status int) {
+	httpErrorDetails(w, r, message, status, nil)
+}
+
+// httpErrorDetails is httpError with details for the JSON envelope
+func httpErrorDetails(w http.ResponseWriter, r *http.Request, message string, status int, details map[string]string) {
+	if !strings.HasPrefix(r.URL.Path, "/api/") {
+		http.Error(w, message, status)
+		return
+	}
+
+	code, ok := apiErrorCodes[status]
+	if !ok {
+		code = strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	}
+	id := requestID(r)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Request-ID", id)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiError{Code: code, Message: message, RequestID: id, Details: details})
+}
+
+// requestID returns the X-Request-ID of a request, e.g. set by a reverse proxy, or a new random ID
+func requestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-ID"); validRequestID.MatchString(id) {
+		return id
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHTTPError(t *testing.T) {
+	tests := []struct {
+		name            string
+		path            string
+		requestID       string
+		status          int
+		details         map[string]string
+		expectedCode    string
+		expectedID      string
+		expectPlainText bool
+	}{
+		{name: "missing parameter", path: "/api/records", status: http.StatusBadRequest, details: map[string]string{"parameter": "zone"}, expectedCode: "bad_request"},
+		{name: "upstream failure", path: "/api/rollback", status: http.StatusBadGateway, expectedCode: "upstream_error"},
+		{name: "request id of a proxy", path: "/api/status", requestID: "req-42", status: http.StatusUnauthorized, expectedCode: "unauthorized", expectedID: "req-42"},
+		{name: "unsafe request id replaced", path: "/api/status", requestID: "bad id\r\n", status: http.StatusForbidden, expectedCode: "forbidden"},
+		{name: "unmapped status", path: "/api/usage", status: http.StatusTeapot, expectedCode: "i'm_a_teapot"},
+		{name: "dyndns2 endpoint stays plain text", path: "/update", status: http.StatusBadRequest, expectPlainText: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.requestID != "" {
+				req.Header.Set("X-Request-ID", tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			httpErrorDetails(w, req, "Something failed", tt.status, tt.details)
+
+			if w.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, w.Code)
+			}
+			if tt.expectPlainText {
+				if w.Body.String() != "Something failed\n" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+					t.Errorf("Expected a plain text error, got %q", w.Body.String())
+				}
+				return
+			}
+
+			var envelope apiError
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("Invalid JSON error %q: %v", w.Body.String(), err)
+			}
+			if envelope.Code != tt.expectedCode || envelope.Message != "Something failed" || !reflect.DeepEqual(envelope.Details, tt.details) {
+				t.Errorf("Unexpected envelope %+v", envelope)
+			}
+			if tt.expectedID != "" && envelope.RequestID != tt.expectedID {
+				t.Errorf("Expected request ID %s, got %s", tt.expectedID, envelope.RequestID)
+			}
+			if len(envelope.RequestID) == 0 || strings.ContainsAny(envelope.RequestID, " \r\n") {
+				t.Errorf("Expected a safe request ID, got %q", envelope.RequestID)
+			}
+			if w.Header().Get("X-Request-ID") != envelope.RequestID {
+				t.Errorf("Expected the request ID in the header, got %q", w.Header().Get("X-Request-ID"))
+			}
+		})
+	}
+}
+
+func TestAPIErrorsFromHandlers(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+
+	tests := []struct {
+		name         string
+		method       string
+		target       string
+		password     string
+		handler      http.HandlerFunc
+		expectedCode string
+	}{
+		{name: "authentication", method: "GET", target: "/api/status", password: "wrong", handler: server.handleStatus, expectedCode: "unauthorized"},
+		{name: "method", method: "PUT", target: "/api/usage", password: "password", handler: allowMethods(server.handleUsage, "GET"), expectedCode: "method_not_allowed"},
+		{name: "parameter", method: "POST", target: "/api/rollback", password: "password", handler: server.handleRollback, expectedCode: "bad_request"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.SetBasicAuth("admin", tt.password)
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+
+			var envelope apiError
+			if err := json.Unmarshal(w.Body.Bytes(), &envelope); err != nil {
+				t.Fatalf("Invalid JSON error %q: %v", w.Body.String(), err)
+			}
+			if envelope.Code != tt.expectedCode {
+				t.Errorf("Expected code %s, got %+v", tt.expectedCode, envelope)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Outbound request log levels
+const (
+	apiLogOff   = "off"
+	apiLogError = "error" // failed requests only
+	apiLogInfo  = "info"  // all requests without bodies
+	apiLogDebug = "debug" // all requests with redacted bodies
+)
+
+// apiLogLevels lists the outbound request log levels
+var apiLogLevels = []string{apiLogOff, apiLogError, apiLogInfo, apiLogDebug}
+
+// sensitiveKeys are JSON keys whose values are never logged
+var sensitiveKeys = []string{"token", "password", "secret", "key", "auth"}
+
+// requestLogger logs outbound API requests. Successful requests are sampled
+// with sampleRate, failed ones are always logged.
+type requestLogger struct {
+	level      string
+	sampleRate float64
+	random     func() float64
+}
+
+// EnableRequestLog logs the client's requests at level, sampling successful
+// requests with sampleRate between 0 and 1
+func (c *Client) EnableRequestLog(level string, sampleRate float64) {
+	if level == apiLogOff {
+		c.requestLog = nil
+		return
+	}
+	c.requestLog = &requestLogger{level: level, sampleRate: sampleRate, random: rand.Float64}
+}
+
+// logRequest logs a finished request with its status, or err if it failed without a response
+func (c *Client) logRequest(method, endpoint string, body []byte, status int, duration time.Duration, err error) {
+	l := c.requestLog
+	if l == nil {
+		return
+	}
+	failed := err != nil || status >= 400
+	if !failed && (l.level == apiLogError || l.random() >= l.sampleRate) {
+		return
+	}
+
+	line := fmt.Sprintf("API request %s %s", method, endpoint)
+	if err != nil {
+		line += fmt.Sprintf(" failed after %s: %v", duration.Round(time.Millisecond), err)
+	} else {
+		line += fmt.Sprintf(" status=%d duration=%s", status, duration.Round(time.Millisecond))
+	}
+	if l.level == apiLogDebug && len(body) > 0 {
+		line += fmt.Sprintf(" body=%s", redactBody(body))
+	}
+	log.Print(line)
+}
+
+// redactBody masks the values of sensitive keys in a JSON body
+func redactBody(body []byte) []byte {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []byte("<invalid JSON>")
+	}
+	redacted, err := json.Marshal(redactValue(value))
+	if err != nil {
+		return []byte("<invalid JSON>")
+	}
+	return bytes.TrimSpace(redacted)
+}
+
+// redactValue replaces the values of sensitive keys in decoded JSON
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSensitiveKey(key) {
+				v[key] = "***"
+				continue
+			}
+			v[key] = redactValue(field)
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+	}
+	return value
+}
+
+// isSensitiveKey reports whether a JSON key names a credential
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
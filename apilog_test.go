@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRequestLog(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/records/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(RecordResponse{Record: DNSRecord{ID: "created"}})
+	}))
+	defer mockAPI.Close()
+
+	tests := []struct {
+		name       string
+		level      string
+		sampled    bool
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name:       "error level logs failures only",
+			level:      apiLogError,
+			sampled:    true,
+			expected:   []string{"GET /records/missing status=404"},
+			unexpected: []string{"POST /records"},
+		},
+		{
+			name:       "info level logs requests without body",
+			level:      apiLogInfo,
+			sampled:    true,
+			expected:   []string{"POST /records status=200 duration=", "GET /records/missing status=404"},
+			unexpected: []string{"body="},
+		},
+		{
+			name:     "debug level logs redacted body",
+			level:    apiLogDebug,
+			sampled:  true,
+			expected: []string{`body={"name":"home","type":"A","value":"203.0.113.1","zone_id":"zone1"}`},
+		},
+		{
+			name:       "unsampled successes are skipped",
+			level:      apiLogInfo,
+			expected:   []string{"GET /records/missing status=404"},
+			unexpected: []string{"POST /records"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			log.SetOutput(&out)
+			defer log.SetOutput(os.Stderr)
+
+			client := NewClient("secret-api-token")
+			client.BaseURL = mockAPI.URL
+			client.EnableRequestLog(tt.level, 0.5)
+			client.requestLog.random = func() float64 {
+				if tt.sampled {
+					return 0.1
+				}
+				return 0.9
+			}
+
+			client.CreateRecord(CreateRecordRequest{Type: "A", Name: "home", Value: "203.0.113.1", ZoneID: "zone1"})
+			client.GetRecord("missing")
+
+			logged := out.String()
+			if strings.Contains(logged, "secret-api-token") {
+				t.Errorf("API token must never be logged: %s", logged)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(logged, expected) {
+					t.Errorf("Expected log to contain %q, got %s", expected, logged)
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if strings.Contains(logged, unexpected) {
+					t.Errorf("Expected log not to contain %q, got %s", unexpected, logged)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactBody(t *testing.T) {
+	got := string(redactBody([]byte(`{"name":"home","api_token":"abc","nested":[{"Password":"x","value":"1"}]}`)))
+	expected := `{"api_token":"***","name":"home","nested":[{"Password":"***","value":"1"}]}`
+	if got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+	if got := string(redactBody([]byte("token=abc"))); got != "<invalid JSON>" {
+		t.Errorf("Expected non-JSON body to be withheld, got %s", got)
+	}
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// Authentication scopes, each endpoint group has its own auth chain
+type authScope string
+
+const (
+	authScopeUpdate  authScope = "update"
+	authScopeAdmin   authScope = "admin"
+	authScopeMetrics authScope = "metrics"
+)
+
+var (
+	errMissingCredentials = errors.New("missing credentials")
+	errInvalidCredentials = errors.New("invalid credentials")
+)
+
+// authUserKey is the request context key of the user authorize authenticated
+type authUserKey struct{}
+
+// Authenticator checks a single authentication method
+type Authenticator interface {
+	// Authenticate returns the authenticated user, or an error describing why r was rejected
+	Authenticate(r *http.Request) (string, error)
+	// Challenge returns the WWW-Authenticate value sent on failure, empty for methods the client cannot retry
+	Challenge() string
+}
+
+// AuthChain is a sequence of steps that all have to pass, each step passes
+// if any of its authenticators accepts the request. An empty chain allows everything.
+type AuthChain [][]Authenticator
+
+// AuthConfig configures the authentication chains of the endpoint groups.
+// Chains are written as steps separated by "," with alternatives separated
+// by "|", e.g. "ip,basic|token" requires an allowed address and either Basic
+// Auth or a bearer token. Available methods: none, basic, token, mtls and ip.
+type AuthConfig struct {
+	Update  string
+	Admin   string
+	Metrics string
+
+	Tokens     []string
+	AllowedIPs []string
+}
+
+// basicAuth checks the DynDNS username and password
+type basicAuth struct {
+	username string
+	password string
+	// aliases are further usernames sharing the password, e.g. to select a client profile
+	aliases []string
+	// allowQuery accepts FritzBox style username and password query parameters
+	allowQuery bool
+}
+
+// Authenticate implements Authenticator
+func (a basicAuth) Authenticate(r *http.Request) (string, error) {
+	user, pass, ok := r.BasicAuth()
+	if !ok && a.allowQuery {
+		// FritzBox can pass the credentials through the <username> and <passwd> placeholders
+		user, pass, ok = queryCredentials(r)
+	}
+	if !ok {
+		return "", errMissingCredentials
+	}
+	if !a.knownUser(user) || !secureCompare(pass, a.password) {
+		return user, errInvalidCredentials
+	}
+	return user, nil
+}
+
+// knownUser reports whether user is the configured username or one of its aliases
+func (a basicAuth) knownUser(user string) bool {
+	known := secureCompare(user, a.username)
+	for _, alias := range a.aliases {
+		if secureCompare(user, alias) {
+			known = true
+		}
+	}
+	return known
+}
+
+// Challenge implements Authenticator
+func (a basicAuth) Challenge() string {
+	return `Basic realm="DynDNS"`
+}
+
+// tokenAuth checks for one of the configured bearer tokens
+type tokenAuth struct {
+	tokens []string
+}
+
+// Authenticate implements Authenticator
+func (a tokenAuth) Authenticate(r *http.Request) (string, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return "", errMissingCredentials
+	}
+	for _, valid := range a.tokens {
+		if secureCompare(token, valid) {
+			return "token", nil
+		}
+	}
+	return "", errInvalidCredentials
+}
+
+// Challenge implements Authenticator
+func (a tokenAuth) Challenge() string {
+	return `Bearer realm="DynDNS"`
+}
+
+// certAuth accepts clients presenting a certificate signed by the configured client CA
+type certAuth struct{}
+
+// Authenticate implements Authenticator
+func (certAuth) Authenticate(r *http.Request) (string, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return "", errors.New("missing client certificate")
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, nil
+}
+
+// Challenge implements Authenticator
+func (certAuth) Challenge() string {
+	return ""
+}
+
+// ipAllowlist accepts requests from the configured networks. It uses the
+// connection address and ignores forwarding headers, which clients can forge.
+type ipAllowlist struct {
+	prefixes []netip.Prefix
+}
+
+// Authenticate implements Authenticator
+func (a ipAllowlist) Authenticate(r *http.Request) (string, error) {
+	host := remoteIP(r)
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid client address %s", host)
+	}
+	for _, prefix := range a.prefixes {
+		if prefix.Contains(addr.Unmap()) {
+			return "", nil
+		}
+	}
+	return "", fmt.Errorf("address %s not allowed", addr)
+}
+
+// Challenge implements Authenticator
+func (a ipAllowlist) Challenge() string {
+	return ""
+}
+
+// secureCompare compares secrets in constant time
+func secureCompare(given, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(expected)) == 1
+}
+
+// configureAuth builds the auth chains of all scopes from cfg, scopes without a specification keep their default
+func (s *DynDNSServer) configureAuth(cfg AuthConfig) error {
+	allowlist := ipAllowlist{}
+	for _, entry := range cfg.AllowedIPs {
+		prefix, err := parseBlocklistEntry(entry)
+		if err != nil {
+			return fmt.Errorf("invalid allowed IP %q: %w", entry, err)
+		}
+		allowlist.prefixes = append(allowlist.prefixes, prefix)
+	}
+
+	specs := map[authScope]string{
+		authScopeUpdate:  cfg.Update,
+		authScopeAdmin:   cfg.Admin,
+		authScopeMetrics: cfg.Metrics,
+	}
+	chains := make(map[authScope]AuthChain, len(specs))
+	for scope, spec := range specs {
+		if spec == "" {
+			// Keep the default chain of the scope
+			continue
+		}
+		methods := map[string]Authenticator{
+			"basic": s.basicAuth(scope == authScopeUpdate),
+			"mtls":  certAuth{},
+		}
+		if len(cfg.Tokens) > 0 {
+			methods["token"] = tokenAuth{tokens: cfg.Tokens}
+		}
+		if len(allowlist.prefixes) > 0 {
+			methods["ip"] = allowlist
+		}
+
+		chain, err := parseAuthChain(spec, methods)
+		if err != nil {
+			return fmt.Errorf("invalid %s auth chain: %w", scope, err)
+		}
+		chains[scope] = chain
+	}
+
+	s.auth = chains
+	return nil
+}
+
+// parseAuthChain parses a chain specification using the available methods
+func parseAuthChain(spec string, methods map[string]Authenticator) (AuthChain, error) {
+	var chain AuthChain
+	for _, step := range splitList(spec) {
+		var alternatives []Authenticator
+		for _, name := range strings.Split(step, "|") {
+			name = strings.TrimSpace(name)
+			if name == "none" {
+				alternatives = nil
+				break
+			}
+			method, ok := methods[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown or unconfigured auth method %q", name)
+			}
+			alternatives = append(alternatives, method)
+		}
+		if alternatives != nil {
+			chain = append(chain, alternatives)
+		}
+	}
+	return chain, nil
+}
+
+// authChain returns the chain for scope, defaulting to Basic Auth for updates and the admin API
+func (s *DynDNSServer) authChain(scope authScope) AuthChain {
+	if chain, ok := s.auth[scope]; ok {
+		return chain
+	}
+	switch scope {
+	case authScopeUpdate:
+		return AuthChain{{s.basicAuth(true)}}
+	case authScopeAdmin:
+		return AuthChain{{s.basicAuth(false)}}
+	}
+	return nil
+}
+
+// basicAuth returns the Basic Auth method for the configured credentials and profile usernames
+func (s *DynDNSServer) basicAuth(allowQuery bool) basicAuth {
+	auth := basicAuth{username: s.username, password: s.password, allowQuery: allowQuery}
+	for user := range s.profileUsers {
+		auth.aliases = append(auth.aliases, user)
+	}
+	if s.roundRobin != nil {
+		auth.aliases = append(auth.aliases, s.roundRobin.sources...)
+	}
+	return auth
+}
+
+// authorize runs the auth chain of scope for r. Failures are answered with
+// 401, or 403 if the failing step cannot be retried with credentials, and
+// written to the auth log. The user authenticated by the chain is stored in
+// the context of r for requestUsername.
+func (s *DynDNSServer) authorize(w http.ResponseWriter, r *http.Request, scope authScope) bool {
+	authenticated := ""
+	for _, step := range s.authChain(scope) {
+		var user string
+		var reasons, challenges []string
+		passed := false
+		for _, method := range step {
+			name, err := method.Authenticate(r)
+			if err == nil {
+				if name != "" {
+					authenticated = name
+				}
+				passed = true
+				break
+			}
+			if name != "" {
+				user = name
+			}
+			reasons = append(reasons, err.Error())
+			if challenge := method.Challenge(); challenge != "" {
+				challenges = append(challenges, challenge)
+			}
+		}
+		if passed {
+			continue
+		}
+
+		if s.authLog != nil {
+			s.authLog.Failure(r, user, strings.Join(reasons, ", "))
+		}
+		s.events.Publish(Event{
+			Kind:     eventAuthFailure,
+			Severity: severityWarning,
+			Username: sanitizeLogField(user),
+			Source:   getClientIP(r),
+			Error:    strings.Join(reasons, ", "),
+		})
+		if len(challenges) == 0 {
+			httpError(w, r, "Forbidden", http.StatusForbidden)
+			return false
+		}
+		for _, challenge := range challenges {
+			w.Header().Add("WWW-Authenticate", challenge)
+		}
+		httpError(w, r, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	*r = *r.WithContext(context.WithValue(r.Context(), authUserKey{}, authenticated))
+	return true
+}
+
+// requireAuth wraps next with the auth chain of scope
+func (s *DynDNSServer) requireAuth(scope authScope, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.authorize(w, r, scope) {
+			next(w, r)
+		}
+	}
+}
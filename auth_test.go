@@ -0,0 +1,160 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorizeChains(t *testing.T) {
+	tests := []struct {
+		name           string
+		cfg            AuthConfig
+		scope          authScope
+		setup          func(*http.Request)
+		expectedStatus int
+	}{
+		{
+			name:           "default update chain accepts query credentials",
+			scope:          authScopeUpdate,
+			setup:          func(r *http.Request) { r.URL.RawQuery = "username=admin&password=password" },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "default admin chain rejects query credentials",
+			scope:          authScopeAdmin,
+			setup:          func(r *http.Request) { r.URL.RawQuery = "username=admin&password=password" },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "default metrics chain is open",
+			scope:          authScopeMetrics,
+			setup:          func(r *http.Request) {},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "token accepted as alternative",
+			cfg:            AuthConfig{Admin: "basic|token", Tokens: []string{"secret-token"}},
+			scope:          authScopeAdmin,
+			setup:          func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret-token") },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "wrong token rejected",
+			cfg:            AuthConfig{Admin: "basic|token", Tokens: []string{"secret-token"}},
+			scope:          authScopeAdmin,
+			setup:          func(r *http.Request) { r.Header.Set("Authorization", "Bearer guess") },
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "allowlisted address with credentials",
+			cfg:            AuthConfig{Admin: "ip,basic", AllowedIPs: []string{"192.0.2.0/24"}},
+			scope:          authScopeAdmin,
+			setup:          func(r *http.Request) { r.SetBasicAuth("admin", "password") },
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "address outside allowlist",
+			cfg:            AuthConfig{Admin: "ip,basic", AllowedIPs: []string{"198.51.100.0/24"}},
+			scope:          authScopeAdmin,
+			setup:          func(r *http.Request) { r.SetBasicAuth("admin", "password") },
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "forwarding headers ignored by allowlist",
+			cfg:            AuthConfig{Metrics: "ip", AllowedIPs: []string{"198.51.100.0/24"}},
+			scope:          authScopeMetrics,
+			setup:          func(r *http.Request) { r.Header.Set("X-Forwarded-For", "198.51.100.1") },
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "metrics protected by token",
+			cfg:            AuthConfig{Metrics: "token", Tokens: []string{"scrape"}},
+			scope:          authScopeMetrics,
+			setup:          func(r *http.Request) {},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:  "client certificate",
+			cfg:   AuthConfig{Update: "mtls"},
+			scope: authScopeUpdate,
+			setup: func(r *http.Request) {
+				cert := &x509.Certificate{Subject: pkix.Name{CommonName: "fritzbox"}}
+				r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "missing client certificate",
+			cfg:            AuthConfig{Update: "mtls"},
+			scope:          authScopeUpdate,
+			setup:          func(r *http.Request) { r.SetBasicAuth("admin", "password") },
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+			if err := server.configureAuth(tt.cfg); err != nil {
+				t.Fatalf("configureAuth failed: %v", err)
+			}
+
+			handler := server.requireAuth(tt.scope, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			req := httptest.NewRequest("GET", "/api/status", nil)
+			req.RemoteAddr = "192.0.2.10:1234"
+			tt.setup(req)
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("Expected WWW-Authenticate header for 401 response")
+			}
+		})
+	}
+}
+
+func TestConfigureAuthErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           AuthConfig
+		errorContains string
+	}{
+		{"unknown method", AuthConfig{Update: "basic|magic"}, "magic"},
+		{"token without tokens", AuthConfig{Admin: "token"}, "token"},
+		{"ip without allowlist", AuthConfig{Metrics: "ip"}, "ip"},
+		{"invalid allowlist entry", AuthConfig{Metrics: "ip", AllowedIPs: []string{"lan"}}, "lan"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+			err := server.configureAuth(tt.cfg)
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+			if !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("Expected error to contain '%s', got '%s'", tt.errorContains, err.Error())
+			}
+		})
+	}
+}
+
+func TestParseAuthChainNone(t *testing.T) {
+	chain, err := parseAuthChain("none", map[string]Authenticator{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(chain) != 0 {
+		t.Errorf("Expected empty chain, got %d steps", len(chain))
+	}
+}
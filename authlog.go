@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// DefaultAuthLogFormat is a single line format matched easily by fail2ban and CrowdSec
+const DefaultAuthLogFormat = "{time} dyndns auth failure from {ip}: {reason} (user={user}, path={path})"
+
+// AuthLogger writes one line per failed authentication to a dedicated stream
+type AuthLogger struct {
+	format string
+	now    func() time.Time
+
+	mu  sync.Mutex
+	out io.Writer
+}
+
+// NewAuthLogger creates an auth logger writing lines in format to out.
+// The placeholders {time}, {ip}, {forwarded}, {reason}, {user} and {path} are substituted.
+func NewAuthLogger(out io.Writer, format string) *AuthLogger {
+	if format == "" {
+		format = DefaultAuthLogFormat
+	}
+	return &AuthLogger{format: format, out: out, now: time.Now}
+}
+
+// Failure logs a failed authentication of r. {ip} is the connection address,
+// so clients cannot get other addresses banned by forging X-Forwarded-For,
+// which is only logged as {forwarded}. {path} is logged escaped so encoded
+// line breaks cannot start a forged line.
+func (l *AuthLogger) Failure(r *http.Request, user, reason string) {
+	if user == "" {
+		user = "-"
+	}
+	forwarded := r.Header.Get("X-Forwarded-For")
+	if forwarded == "" {
+		forwarded = "-"
+	}
+	line := strings.NewReplacer(
+		"{time}", l.now().UTC().Format(time.RFC3339),
+		"{ip}", remoteIP(r),
+		"{forwarded}", sanitizeLogField(forwarded),
+		"{reason}", sanitizeLogMessage(reason),
+		"{user}", sanitizeLogField(user),
+		"{path}", sanitizeLogField(r.URL.EscapedPath()),
+	).Replace(l.format)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintln(l.out, line)
+}
+
+// sanitizeLogField strips line breaks and spaces so client supplied values cannot forge log lines
+func sanitizeLogField(value string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == ' ' || r == '\t' {
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+// sanitizeLogMessage strips control characters from a message that may contain
+// client supplied values, keeping its spaces readable
+func sanitizeLogMessage(value string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, value)
+}
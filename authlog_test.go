@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAuthLoggerFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewAuthLogger(&out, "{ip} {reason} {user} forwarded={forwarded}")
+
+	req := httptest.NewRequest("GET", "/update", nil)
+	req.RemoteAddr = "198.51.100.7:4242"
+	// Forged forwarding headers must not change the logged address
+	req.Header.Set("X-Forwarded-For", "203.0.113.1, 10.0.0.1")
+	req.Header.Set("X-Real-IP", "203.0.113.2")
+	logger.Failure(req, "evil user\nforged", "invalid credentials")
+
+	expected := "198.51.100.7 invalid credentials evil_user_forged forwarded=203.0.113.1,_10.0.0.1\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestAuthLoggerEscapesPathAndReason(t *testing.T) {
+	var out bytes.Buffer
+	logger := NewAuthLogger(&out, "")
+
+	// An encoded line break in the path must not start a forged line
+	req := httptest.NewRequest("GET", "/debug/x%0A2024-01-01T00:00:00Z%20dyndns%20auth%20failure%20from%20192.0.2.1", nil)
+	req.RemoteAddr = "198.51.100.7:4242"
+	logger.Failure(req, "", "client certificate CN=evil\nforged is not allowed")
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected a single log line, got %q", out.String())
+	}
+	if !strings.Contains(lines[0], "path=/debug/x%0A2024-01-01T00:00:00Z%20dyndns") {
+		t.Errorf("Expected escaped path, got %q", lines[0])
+	}
+	if !strings.Contains(lines[0], ": client certificate CN=evil_forged is not allowed (") {
+		t.Errorf("Expected sanitized reason, got %q", lines[0])
+	}
+}
+
+func TestAuthenticateLogsFailures(t *testing.T) {
+	var out bytes.Buffer
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.authLog = NewAuthLogger(&out, "")
+
+	tests := []struct {
+		name           string
+		setup          func(*http.Request)
+		allowQuery     bool
+		expectedReason string
+	}{
+		{
+			name:           "missing credentials",
+			setup:          func(r *http.Request) {},
+			expectedReason: "missing credentials",
+		},
+		{
+			name:           "wrong password",
+			setup:          func(r *http.Request) { r.SetBasicAuth("admin", "guess") },
+			expectedReason: "invalid credentials",
+		},
+		{
+			name:           "valid credentials",
+			setup:          func(r *http.Request) { r.SetBasicAuth("admin", "password") },
+			expectedReason: "",
+		},
+		{
+			name:           "valid query credentials",
+			setup:          func(r *http.Request) { r.URL.RawQuery = "username=admin&password=password" },
+			allowQuery:     true,
+			expectedReason: "",
+		},
+	}
+
+	linePattern := regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\S+ dyndns auth failure from 203\.0\.113\.9: .+ \(user=\S+, path=/update\)$`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out.Reset()
+			req := httptest.NewRequest("GET", "/update", nil)
+			req.RemoteAddr = "203.0.113.9:1234"
+			tt.setup(req)
+			w := httptest.NewRecorder()
+
+			scope := authScopeAdmin
+			if tt.allowQuery {
+				scope = authScopeUpdate
+			}
+			ok := server.authorize(w, req, scope)
+			if ok != (tt.expectedReason == "") {
+				t.Fatalf("Expected authenticated=%v, got %v", tt.expectedReason == "", ok)
+			}
+			if tt.expectedReason == "" {
+				if out.Len() != 0 {
+					t.Errorf("Expected no auth log output, got %q", out.String())
+				}
+				return
+			}
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Expected status 401, got %d", w.Code)
+			}
+			line := strings.TrimSuffix(out.String(), "\n")
+			if !linePattern.MatchString(line) || !strings.Contains(line, tt.expectedReason) {
+				t.Errorf("Unexpected auth log line %q", line)
+			}
+		})
+	}
+}
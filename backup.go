@@ -0,0 +1,315 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// backupBucket stores the failover state of the records with a backup address
+const backupBucket = "backup"
+
+// Defaults of the failover hysteresis
+const (
+	defaultBackupFailAfter    = 3
+	defaultBackupRecoverAfter = 5
+)
+
+// BackupIP is the address a record of Hostname switches to while its primary is down
+type BackupIP struct {
+	Hostname string
+	Type     string
+	IP       string
+}
+
+// parseBackupIPs parses "hostname=ip" entries separated by commas, a hostname
+// may have one IPv4 and one IPv6 backup
+func parseBackupIPs(value string) ([]BackupIP, error) {
+	var backups []BackupIP
+	seen := map[string]bool{}
+	for _, entry := range splitList(value) {
+		hostname, ip, ok := strings.Cut(entry, "=")
+		hostname, ip = parse.NormalizeHostname(strings.TrimSpace(hostname)), strings.TrimSpace(ip)
+		if !ok || hostname == "" || strings.Contains(hostname, "*") {
+			return nil, fmt.Errorf("expected hostname=ip, got %q", entry)
+		}
+		recordType := "A"
+		switch {
+		case parse.IsIPv4(ip):
+		case parse.IsIPv6(ip):
+			recordType = "AAAA"
+		default:
+			return nil, fmt.Errorf("invalid address in %q", entry)
+		}
+		if seen[hostname+"/"+recordType] {
+			return nil, fmt.Errorf("more than one %s backup for %s", recordType, hostname)
+		}
+		seen[hostname+"/"+recordType] = true
+		backups = append(backups, BackupIP{Hostname: hostname, Type: recordType, IP: ip})
+	}
+	return backups, nil
+}
+
+// backupState is the failover state of a record, reported in GET /api/status
+type backupState struct {
+	// Primary is the address last sent by the client, Backup the one used while it is down
+	Primary string `json:"primary,omitempty"`
+	Backup  string `json:"backup"`
+	// Active is set while the record points at the backup
+	Active bool `json:"active"`
+	// Failures and Successes count the consecutive checks of the primary
+	Failures  int        `json:"failures"`
+	Successes int        `json:"successes"`
+	Since     *time.Time `json:"since,omitempty"`
+}
+
+// BackupMonitor checks the ports of DYNDNS_PORT_CHECKS on the primary address
+// of records with a backup. After failAfter failed checks in a row the record
+// is switched to the backup, after recoverAfter successful checks back to the
+// primary. Updates received meanwhile only change the primary.
+type BackupMonitor struct {
+	server       *DynDNSServer
+	checker      *PortChecker
+	interval     time.Duration
+	failAfter    int
+	recoverAfter int
+
+	mu     sync.Mutex
+	states map[string]*backupState
+}
+
+// NewBackupMonitor creates a monitor for backups, continuing the state kept in
+// the server's store
+func NewBackupMonitor(server *DynDNSServer, checker *PortChecker, backups []BackupIP, interval time.Duration, failAfter, recoverAfter int) *BackupMonitor {
+	server.metrics.Describe("dyndns_backup_switches_total", "counter", "Number of switches of records between their primary and backup address.")
+	m := &BackupMonitor{
+		server:       server,
+		checker:      checker,
+		interval:     interval,
+		failAfter:    failAfter,
+		recoverAfter: recoverAfter,
+		states:       make(map[string]*backupState),
+	}
+	for _, backup := range backups {
+		key := backup.Hostname + "/" + backup.Type
+		state := &backupState{Backup: backup.IP}
+		if data, ok, err := server.store.Get(backupBucket, key); err != nil {
+			log.Printf("Failed to load the failover state of %s %s: %v", backup.Hostname, backup.Type, err)
+		} else if ok && json.Unmarshal(data, state) == nil {
+			state.Backup = backup.IP
+		}
+		m.states[key] = state
+	}
+	return m
+}
+
+// Hold remembers ip as the primary address of hostname's recordType and
+// reports whether the record points at the backup, so the update is not written
+func (m *BackupMonitor) Hold(hostname, recordType, ip string) bool {
+	if m == nil {
+		return false
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.states[hostname+"/"+recordType]
+	if state == nil {
+		return false
+	}
+	if state.Primary != ip {
+		state.Primary, state.Failures, state.Successes = ip, 0, 0
+		m.save(hostname, recordType, state)
+	}
+	return state.Active
+}
+
+// Status returns the failover state of hostname's recordType, nil without a backup
+func (m *BackupMonitor) Status(hostname, recordType string) *backupState {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.states[hostname+"/"+recordType]
+	if state == nil {
+		return nil
+	}
+	status := *state
+	return &status
+}
+
+// Run checks the primaries every interval until stop is closed
+func (m *BackupMonitor) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.Probe()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Probe checks the primary address of every record with a backup once and
+// switches the records whose threshold was reached. The checks run without
+// holding mu, so updates passing Hold are not delayed by slow ports.
+func (m *BackupMonitor) Probe() {
+	m.mu.Lock()
+	keys := make([]string, 0, len(m.states))
+	for key := range m.states {
+		keys = append(keys, key)
+	}
+	m.mu.Unlock()
+	sort.Strings(keys)
+	for _, key := range keys {
+		hostname, recordType, _ := strings.Cut(key, "/")
+		m.probe(hostname, recordType)
+	}
+}
+
+// probe checks the primary of one record
+func (m *BackupMonitor) probe(hostname, recordType string) {
+	key := hostname + "/" + recordType
+	m.mu.Lock()
+	primary, backup := m.states[key].Primary, m.states[key].Backup
+	m.mu.Unlock()
+
+	if primary == "" {
+		// Before the first update the current value of the record is the primary
+		lookup, err := m.server.lookupRecord(hostname, recordType)
+		if err != nil || lookup.Existing == nil || lookup.Existing.Value == backup {
+			return
+		}
+		primary = lookup.Existing.Value
+	}
+	err := m.check(hostname, primary)
+
+	m.mu.Lock()
+	state := m.states[key]
+	switch {
+	case state.Primary == "":
+		state.Primary = primary
+	case state.Primary != primary:
+		// An update changed the primary while the old one was checked
+		m.mu.Unlock()
+		return
+	}
+	if err != nil {
+		log.Printf("Failover: %s (%s) of %s failed its check: %v", primary, recordType, hostname, err)
+		state.Failures++
+		state.Successes = 0
+	} else {
+		state.Successes++
+		state.Failures = 0
+	}
+	toBackup := !state.Active && state.Failures >= m.failAfter
+	toPrimary := state.Active && state.Successes >= m.recoverAfter
+	current := *state
+	m.save(hostname, recordType, state)
+	m.mu.Unlock()
+
+	if toBackup || toPrimary {
+		m.switchTo(hostname, recordType, current, toBackup)
+	}
+}
+
+// check reports an error if one of the ports checked for hostname is not reachable on ip
+func (m *BackupMonitor) check(hostname, ip string) error {
+	for _, check := range m.checker.checks {
+		if !matchesRoute(check.Pattern, hostname) {
+			continue
+		}
+		if err := m.checker.check(ip, check.Port); err != nil {
+			return fmt.Errorf("port %d: %w", check.Port, err)
+		}
+	}
+	return nil
+}
+
+// switchTo points the record at the backup or back at the primary and
+// notifies about the switch. The write passes maintenance mode and the rate
+// limit like an update, writes that fail or are dropped are retried with the
+// next check.
+func (m *BackupMonitor) switchTo(hostname, recordType string, state backupState, backup bool) {
+	from, to, target := state.Primary, state.Backup, "backup"
+	if !backup {
+		from, to, target = state.Backup, state.Primary, "primary"
+	}
+	decision, err := m.server.submit(parkedWrite{Hostname: hostname, Type: recordType, Value: to, Failover: true, System: historySystemFailover})
+	if err != nil {
+		log.Printf("Failover: failed to switch %s %s to the %s %s: %v", hostname, recordType, target, to, err)
+		return
+	}
+	if decision == rateNoChange && m.server.maintenance.Active() {
+		log.Printf("Failover: switch of %s %s to the %s %s dropped by maintenance mode", hostname, recordType, target, to)
+		return
+	}
+
+	severity := severityWarning
+	message := fmt.Sprintf("%s %s switched to the backup %s after %d failed checks of %s", hostname, recordType, to, state.Failures, from)
+	since := m.server.clock.Now().UTC()
+	if !backup {
+		severity = severityInfo
+		message = fmt.Sprintf("%s %s switched back to the primary %s after %d successful checks", hostname, recordType, to, state.Successes)
+	}
+
+	m.mu.Lock()
+	current := m.states[hostname+"/"+recordType]
+	current.Active, current.Since = backup, &since
+	if !backup {
+		current.Since = nil
+	}
+	m.save(hostname, recordType, current)
+	// An update held back while switching back sent a newer primary
+	primary := current.Primary
+	m.mu.Unlock()
+	if !backup && primary != to {
+		if _, err := m.server.submit(parkedWrite{Hostname: hostname, Type: recordType, Value: primary, System: historySystemFailover}); err != nil {
+			log.Printf("Failover: failed to update %s %s to the new primary %s: %v", hostname, recordType, primary, err)
+		}
+	}
+
+	log.Printf("Failover: %s", message)
+	m.server.metrics.Inc("dyndns_backup_switches_total", Labels{"to": target})
+	m.server.events.Publish(Event{
+		Kind:     eventAlert,
+		Severity: severity,
+		Hostname: hostname,
+		Type:     recordType,
+		OldValue: from,
+		NewValue: to,
+		Message:  message,
+	})
+}
+
+// save stores the state of hostname's recordType, the caller holds mu
+func (m *BackupMonitor) save(hostname, recordType string, state *backupState) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return
+	}
+	if err := m.server.store.Put(backupBucket, hostname+"/"+recordType, data); err != nil {
+		log.Printf("Failed to store the failover state of %s %s: %v", hostname, recordType, err)
+	}
+}
+
+// checkBackupIPs reports backups of hostnames without a port check deciding about the failover
+func checkBackupIPs(backups []BackupIP, checks []PortCheck) error {
+	for _, backup := range backups {
+		checked := false
+		for _, check := range checks {
+			checked = checked || matchesRoute(check.Pattern, backup.Hostname)
+		}
+		if !checked {
+			return fmt.Errorf("DYNDNS_BACKUP_IPS requires a DYNDNS_PORT_CHECKS entry for %s", backup.Hostname)
+		}
+	}
+	return nil
+}
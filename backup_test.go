@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseBackupIPs(t *testing.T) {
+	tests := []struct {
+		value         string
+		expected      []BackupIP
+		errorContains string
+	}{
+		{value: "", expected: nil},
+		{
+			value:    "Home.example.com=198.51.100.9, home.example.com=2001:db8::9",
+			expected: []BackupIP{{"home.example.com", "A", "198.51.100.9"}, {"home.example.com", "AAAA", "2001:db8::9"}},
+		},
+		{value: "home.example.com", errorContains: "expected hostname=ip"},
+		{value: "*.example.com=198.51.100.9", errorContains: "expected hostname=ip"},
+		{value: "home.example.com=backup", errorContains: "invalid address"},
+		{value: "home.example.com=198.51.100.9,home.example.com=198.51.100.10", errorContains: "more than one A backup"},
+	}
+
+	for _, tt := range tests {
+		backups, err := parseBackupIPs(tt.value)
+		if tt.errorContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("parseBackupIPs(%q): expected error containing %q, got %v", tt.value, tt.errorContains, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseBackupIPs(%q): unexpected error: %v", tt.value, err)
+			continue
+		}
+		if len(backups) != len(tt.expected) {
+			t.Errorf("parseBackupIPs(%q) = %+v, expected %+v", tt.value, backups, tt.expected)
+			continue
+		}
+		for i := range backups {
+			if backups[i] != tt.expected[i] {
+				t.Errorf("parseBackupIPs(%q) = %+v, expected %+v", tt.value, backups, tt.expected)
+			}
+		}
+	}
+}
+
+func TestBackupMonitor(t *testing.T) {
+	fake, client := newFakeAPIServer(t, DNSRecord{Name: "home", Type: "A", Value: "203.0.113.1"})
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	checker := NewPortChecker(server, []PortCheck{{Pattern: "home.example.com", Port: 443}}, "", 0, time.Second)
+	reachable := map[string]bool{"203.0.113.1": true, "203.0.113.5": true}
+	checker.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(address)
+		if !reachable[host] {
+			return nil, errors.New("connection refused")
+		}
+		client, peer := net.Pipe()
+		peer.Close()
+		return client, nil
+	}
+	var alerts []Event
+	server.events.Subscribe(func(event Event) { alerts = append(alerts, event) }, eventAlert)
+	server.backups = NewBackupMonitor(server, checker, []BackupIP{{"home.example.com", "A", "198.51.100.9"}}, time.Minute, 2, 2)
+
+	value := func() string {
+		for _, record := range fake.Records() {
+			if record.Name == "home" && record.Type == "A" {
+				return record.Value
+			}
+		}
+		return ""
+	}
+	update := func(ip string) string {
+		req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&myip="+ip, nil)
+		req.SetBasicAuth("admin", "password")
+		w := httptest.NewRecorder()
+		server.handleUpdate(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Update to %s failed: %d %s", ip, w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	// The current value is the primary until the client sends one
+	server.backups.Probe()
+	if state := server.backups.Status("home.example.com", "A"); state.Primary != "203.0.113.1" || state.Successes != 1 {
+		t.Fatalf("Expected the record value to become the primary, got %+v", state)
+	}
+
+	reachable["203.0.113.1"] = false
+	server.backups.Probe()
+	if value() != "203.0.113.1" || len(alerts) != 0 {
+		t.Fatalf("Expected no switch after one failed check, got %s and %v", value(), alerts)
+	}
+	server.backups.Probe()
+	if value() != "198.51.100.9" {
+		t.Fatalf("Expected the backup after two failed checks, got %s", value())
+	}
+	if len(alerts) != 1 || alerts[0].Severity != severityWarning || alerts[0].NewValue != "198.51.100.9" {
+		t.Errorf("Expected a warning about the switch to the backup, got %+v", alerts)
+	}
+
+	// Updates while on the backup only change the primary
+	if body := update("203.0.113.5"); !strings.HasPrefix(body, "nochg") {
+		t.Errorf("Expected nochg while on the backup, got %s", body)
+	}
+	if state := server.backups.Status("home.example.com", "A"); value() != "198.51.100.9" || state.Primary != "203.0.113.5" || !state.Active {
+		t.Fatalf("Expected the record to stay on the backup with the new primary, got %s and %+v", value(), state)
+	}
+
+	server.backups.Probe()
+	if value() != "198.51.100.9" {
+		t.Fatalf("Expected no switch back after one successful check, got %s", value())
+	}
+	server.backups.Probe()
+	if value() != "203.0.113.5" {
+		t.Fatalf("Expected the new primary after two successful checks, got %s", value())
+	}
+	if len(alerts) != 2 || alerts[1].Severity != severityInfo || alerts[1].NewValue != "203.0.113.5" {
+		t.Errorf("Expected a notification about the recovery, got %+v", alerts)
+	}
+
+	// The state survives a restart through the store
+	restarted := NewBackupMonitor(server, checker, []BackupIP{{"home.example.com", "A", "198.51.100.9"}}, time.Minute, 2, 2)
+	if state := restarted.Status("home.example.com", "A"); state.Primary != "203.0.113.5" || state.Active {
+		t.Errorf("Expected the stored state, got %+v", state)
+	}
+}
+
+func TestBackupMonitorProbeDoesNotBlockHold(t *testing.T) {
+	_, client := newFakeAPIServer(t, DNSRecord{Name: "home", Type: "A", Value: "203.0.113.1"})
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	checker := NewPortChecker(server, []PortCheck{{Pattern: "home.example.com", Port: 443}}, "", 0, time.Second)
+	dialing, release := make(chan struct{}), make(chan struct{})
+	checker.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		close(dialing)
+		<-release
+		return nil, errors.New("timeout")
+	}
+	server.backups = NewBackupMonitor(server, checker, []BackupIP{{"home.example.com", "A", "198.51.100.9"}}, time.Minute, 3, 3)
+
+	probed := make(chan struct{})
+	go func() {
+		server.backups.Probe()
+		close(probed)
+	}()
+	<-dialing
+
+	held := make(chan bool)
+	go func() { held <- server.backups.Hold("home.example.com", "A", "203.0.113.1") }()
+	select {
+	case <-held:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Hold to return while a check is running")
+	}
+	close(release)
+	<-probed
+	if state := server.backups.Status("home.example.com", "A"); state.Failures != 1 {
+		t.Errorf("Expected the failed check to be counted, got %+v", state)
+	}
+}
+
+func TestBackupMonitorHonorsMaintenance(t *testing.T) {
+	fake, client := newFakeAPIServer(t, DNSRecord{Name: "home", Type: "A", Value: "203.0.113.1"})
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	checker := NewPortChecker(server, []PortCheck{{Pattern: "home.example.com", Port: 443}}, "", 0, time.Second)
+	checker.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		return nil, errors.New("connection refused")
+	}
+	server.backups = NewBackupMonitor(server, checker, []BackupIP{{"home.example.com", "A", "198.51.100.9"}}, time.Minute, 1, 1)
+	value := func() string {
+		for _, record := range fake.Records() {
+			if record.Name == "home" && record.Type == "A" {
+				return record.Value
+			}
+		}
+		return ""
+	}
+
+	server.maintenance.Begin("", maintenanceNoChange)
+	server.backups.Probe()
+	if state := server.backups.Status("home.example.com", "A"); value() != "203.0.113.1" || state.Active {
+		t.Fatalf("Expected no switch during maintenance, got %s and %+v", value(), state)
+	}
+
+	server.maintenance.End()
+	server.backups.Probe()
+	if state := server.backups.Status("home.example.com", "A"); value() != "198.51.100.9" || !state.Active {
+		t.Errorf("Expected the switch after maintenance, got %s and %+v", value(), state)
+	}
+}
+
+func TestBackupMonitorRunStops(t *testing.T) {
+	server := NewDynDNSServer(nil, "admin", "password", "8080")
+	monitor := NewBackupMonitor(server, NewPortChecker(server, nil, "", 0, time.Second), nil, time.Hour, 1, 1)
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		monitor.Run(stop)
+		close(stopped)
+	}()
+	close(stop)
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Expected Run to return once stop is closed")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// normalizeBasePath returns the path prefix of DYNDNS_BASE_PATH with a leading
+// and without a trailing slash, e.g. /dyndns, empty for the root
+func normalizeBasePath(value string) (string, error) {
+	path := strings.Trim(strings.TrimSpace(value), "/")
+	if path == "" {
+		return "", nil
+	}
+	if strings.ContainsAny(path, "?#%* ") || strings.Contains(path, "//") {
+		return "", fmt.Errorf("expected a path such as /dyndns, got %q", value)
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "." || segment == ".." {
+			return "", fmt.Errorf("expected a path such as /dyndns, got %q", value)
+		}
+	}
+	return "/" + path, nil
+}
+
+// requestBasePath returns the path prefix the client reached the bridge at:
+// the X-Forwarded-Prefix of a proxy that stripped it, followed by basePath
+func requestBasePath(r *http.Request, basePath string) string {
+	prefix := strings.TrimSuffix(r.Header.Get("X-Forwarded-Prefix"), "/")
+	if prefix != "" && !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return prefix + basePath
+}
+
+// withBasePath appends basePath to a server URL without a path, e.g. the one given to fritzbox-config
+func withBasePath(serverURL, basePath string) string {
+	u, err := url.Parse(serverURL)
+	if err != nil || basePath == "" || strings.Trim(u.Path, "/") != "" {
+		return serverURL
+	}
+	return strings.TrimSuffix(serverURL, "/") + basePath
+}
+
+// mountBasePath serves handler below the base path. Health checks stay
+// available at the root as well, so container and load balancer probes need
+// no change.
+func (s *DynDNSServer) mountBasePath(handler http.Handler) http.Handler {
+	if s.basePath == "" {
+		return handler
+	}
+	mux := http.NewServeMux()
+	// The subtree pattern redirects /dyndns to /dyndns/
+	mux.Handle(s.basePath+"/", http.StripPrefix(s.basePath, handler))
+	for _, route := range s.routes() {
+		if route.Group == routeGroupHealth && route.Path != "/" {
+			mux.Handle(route.Path, handler)
+		}
+	}
+	return mux
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeBasePath(t *testing.T) {
+	tests := []struct {
+		value       string
+		expected    string
+		expectError bool
+	}{
+		{value: "", expected: ""},
+		{value: "/", expected: ""},
+		{value: "dyndns", expected: "/dyndns"},
+		{value: "/dyndns/", expected: "/dyndns"},
+		{value: "/services/dyndns", expected: "/services/dyndns"},
+		{value: "/dyndns?x=1", expectError: true},
+		{value: "/a//b", expectError: true},
+		{value: "/a/../b", expectError: true},
+	}
+
+	for _, tt := range tests {
+		path, err := normalizeBasePath(tt.value)
+		if (err != nil) != tt.expectError {
+			t.Errorf("normalizeBasePath(%q): expected error %v, got %v", tt.value, tt.expectError, err)
+			continue
+		}
+		if path != tt.expected {
+			t.Errorf("normalizeBasePath(%q) = %q, expected %q", tt.value, path, tt.expected)
+		}
+	}
+}
+
+func TestRequestBaseURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		basePath string
+		prefix   string
+		expected string
+	}{
+		{name: "root", expected: "http://dyndns.lan"},
+		{name: "base path", basePath: "/dyndns", expected: "http://dyndns.lan/dyndns"},
+		{name: "stripped by the proxy", prefix: "/dyndns/", expected: "http://dyndns.lan/dyndns"},
+		{name: "both", basePath: "/dyndns", prefix: "home", expected: "http://dyndns.lan/home/dyndns"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://dyndns.lan/api/fritzbox", nil)
+			if tt.prefix != "" {
+				req.Header.Set("X-Forwarded-Prefix", tt.prefix)
+			}
+			if url := requestBaseURL(req, tt.basePath); url != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, url)
+			}
+		})
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	tests := []struct {
+		serverURL string
+		expected  string
+	}{
+		{"http://192.168.178.2:8080", "http://192.168.178.2:8080/dyndns"},
+		{"https://proxy.lan/", "https://proxy.lan/dyndns"},
+		{"https://proxy.lan/other", "https://proxy.lan/other"},
+	}
+	for _, tt := range tests {
+		if url := withBasePath(tt.serverURL, "/dyndns"); url != tt.expected {
+			t.Errorf("withBasePath(%q) = %q, expected %q", tt.serverURL, url, tt.expected)
+		}
+	}
+	if url := withBasePath("http://192.168.178.2:8080", ""); url != "http://192.168.178.2:8080" {
+		t.Errorf("Expected the URL to be unchanged without a base path, got %s", url)
+	}
+}
+
+func TestRoutesBasePath(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.hostnames = []string{"home.example.com"}
+	server.basePath = "/dyndns"
+	routes := server.Routes()
+
+	tests := []struct {
+		path             string
+		expectedStatus   int
+		expectedContains string
+	}{
+		{path: "/dyndns/api/status", expectedStatus: http.StatusOK},
+		{path: "/dyndns/health", expectedStatus: http.StatusOK},
+		{path: "/dyndns", expectedStatus: http.StatusTemporaryRedirect},
+		{path: "/dyndns/api/fritzbox", expectedStatus: http.StatusOK, expectedContains: "http://dyndns.lan/dyndns/update?"},
+		{path: "/dyndns/api/sla?month=2026-03&format=html", expectedStatus: http.StatusOK, expectedContains: `href="/dyndns/api/sla?month=2026-02&amp;format=html"`},
+		// Probes keep working without the prefix
+		{path: "/health", expectedStatus: http.StatusOK},
+		{path: "/readyz", expectedStatus: http.StatusOK},
+		{path: "/api/status", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://dyndns.lan"+tt.path, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedContains) {
+				t.Errorf("Expected body to contain %q, got %s", tt.expectedContains, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestOpenAPIServers(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.basePath = "/dyndns"
+
+	req := httptest.NewRequest("GET", "/openapi.json", nil)
+	req.Header.Set("X-Forwarded-Prefix", "/home")
+	w := httptest.NewRecorder()
+	server.handleOpenAPI(w, req)
+
+	var spec struct {
+		Servers []struct {
+			URL string `json:"url"`
+		} `json:"servers"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Invalid document: %v", err)
+	}
+	if len(spec.Servers) != 1 || spec.Servers[0].URL != "/home/dyndns" {
+		t.Errorf("Expected the server URL /home/dyndns, got %+v", spec.Servers)
+	}
+}
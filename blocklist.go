@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Blocklist decides whether requests from an IP address are rejected
+type Blocklist interface {
+	Blocked(ip string) (bool, error)
+}
+
+// FileBlocklist rejects addresses listed in a local file, one IP or CIDR per
+// line with # comments. The file is reloaded when it changes.
+type FileBlocklist struct {
+	path string
+
+	mu       sync.Mutex
+	modTime  time.Time
+	prefixes []netip.Prefix
+}
+
+// NewFileBlocklist creates a blocklist backed by path and loads it
+func NewFileBlocklist(path string) (*FileBlocklist, error) {
+	b := &FileBlocklist{path: path}
+	if err := b.reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Blocked reports whether ip is contained in the file
+func (b *FileBlocklist) Blocked(ip string) (bool, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false, nil
+	}
+	if err := b.reload(); err != nil {
+		// Keep using the last successfully loaded list
+		log.Printf("Failed to reload blocklist %s: %v", b.path, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, prefix := range b.prefixes {
+		if prefix.Contains(addr.Unmap()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// reload reads the file again if it was modified since the last load
+func (b *FileBlocklist) reload() error {
+	info, err := os.Stat(b.path)
+	if err != nil {
+		return fmt.Errorf("failed to read blocklist: %w", err)
+	}
+
+	b.mu.Lock()
+	unchanged := info.ModTime().Equal(b.modTime)
+	b.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	file, err := os.Open(b.path)
+	if err != nil {
+		return fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	defer file.Close()
+
+	var prefixes []netip.Prefix
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry, _, _ := strings.Cut(scanner.Text(), "#")
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := parseBlocklistEntry(entry)
+		if err != nil {
+			return fmt.Errorf("invalid blocklist entry on line %d: %w", line, err)
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read blocklist: %w", err)
+	}
+
+	b.mu.Lock()
+	b.prefixes = prefixes
+	b.modTime = info.ModTime()
+	b.mu.Unlock()
+	log.Printf("Loaded %d blocklist entries from %s", len(prefixes), b.path)
+	return nil
+}
+
+// parseBlocklistEntry parses a single IP address or CIDR prefix
+func parseBlocklistEntry(entry string) (netip.Prefix, error) {
+	if strings.Contains(entry, "/") {
+		prefix, err := netip.ParsePrefix(entry)
+		return prefix.Masked(), err
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// crowdSecCacheLimit bounds the cached answers, the cache is filled before
+// authentication and clients can rotate their source addresses
+const crowdSecCacheLimit = 10000
+
+// CrowdSecBlocklist asks a CrowdSec Local API for active decisions on an address.
+// Answers are cached for a short time to keep the LAPI load low.
+type CrowdSecBlocklist struct {
+	URL        string
+	APIKey     string
+	HTTPClient *http.Client
+	CacheTTL   time.Duration
+
+	mu    sync.Mutex
+	cache map[string]crowdSecCacheEntry
+	now   func() time.Time
+}
+
+// crowdSecCacheEntry is a cached LAPI answer
+type crowdSecCacheEntry struct {
+	blocked bool
+	expires time.Time
+}
+
+// crowdSecDecision is the subset of a LAPI decision used by the blocklist
+type crowdSecDecision struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// NewCrowdSecBlocklist creates a blocklist querying the LAPI at baseURL with a bouncer API key
+func NewCrowdSecBlocklist(baseURL, apiKey string) *CrowdSecBlocklist {
+	return &CrowdSecBlocklist{
+		URL:        strings.TrimSuffix(baseURL, "/"),
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: 5 * time.Second},
+		CacheTTL:   time.Minute,
+		cache:      make(map[string]crowdSecCacheEntry),
+		now:        time.Now,
+	}
+}
+
+// Blocked reports whether CrowdSec has a ban decision for ip
+func (c *CrowdSecBlocklist) Blocked(ip string) (bool, error) {
+	c.mu.Lock()
+	entry, ok := c.cache[ip]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expires) {
+		return entry.blocked, nil
+	}
+
+	req, err := http.NewRequest("GET", c.URL+"/v1/decisions?ip="+url.QueryEscape(ip), nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-Api-Key", c.APIKey)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to query CrowdSec: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("CrowdSec returned status %d", resp.StatusCode)
+	}
+
+	// The LAPI answers with null if there is no decision
+	var decisions []crowdSecDecision
+	if err := json.NewDecoder(resp.Body).Decode(&decisions); err != nil {
+		return false, fmt.Errorf("failed to decode CrowdSec decisions: %w", err)
+	}
+	blocked := false
+	for _, decision := range decisions {
+		if decision.Type == "ban" {
+			blocked = true
+			break
+		}
+	}
+
+	c.store(ip, blocked)
+	return blocked, nil
+}
+
+// store caches the answer for ip. Beyond crowdSecCacheLimit answers, expired
+// ones are forgotten, and if that is not enough the ones expiring first.
+func (c *CrowdSecBlocklist) store(ip string, blocked bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	c.cache[ip] = crowdSecCacheEntry{blocked: blocked, expires: now.Add(c.CacheTTL)}
+	if len(c.cache) <= crowdSecCacheLimit {
+		return
+	}
+	for cached, entry := range c.cache {
+		if !now.Before(entry.expires) {
+			delete(c.cache, cached)
+		}
+	}
+	for len(c.cache) > crowdSecCacheLimit {
+		oldest := ""
+		for cached, entry := range c.cache {
+			if oldest == "" || entry.expires.Before(c.cache[oldest].expires) {
+				oldest = cached
+			}
+		}
+		delete(c.cache, oldest)
+	}
+}
+
+// addBlocklist makes the server consult blocklist before processing requests
+func (s *DynDNSServer) addBlocklist(blocklist Blocklist) {
+	s.metrics.Describe("dyndns_blocked_requests_total", "counter", "Number of requests rejected by a blocklist.")
+	s.blocklists = append(s.blocklists, blocklist)
+}
+
+// rejectBlocked wraps next, rejecting requests from blocked addresses before
+// they are processed. Blocklists that cannot be consulted fail open. The
+// connection address is checked, forwarding headers would let blocked
+// clients pick any address.
+func (s *DynDNSServer) rejectBlocked(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := remoteIP(r)
+		for _, blocklist := range s.blocklists {
+			blocked, err := blocklist.Blocked(ip)
+			if err != nil {
+				log.Printf("Blocklist lookup for %s failed: %v", ip, err)
+				continue
+			}
+			if blocked {
+				log.Printf("Rejected request from blocked address %s", ip)
+				s.metrics.Inc("dyndns_blocked_requests_total", nil)
+				httpError(w, r, "Forbidden", http.StatusForbidden)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	content := "# known scanners\n198.51.100.7\n203.0.113.0/24 # whole range\n2001:db8:bad::/48\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	blocklist, err := NewFileBlocklist(path)
+	if err != nil {
+		t.Fatalf("NewFileBlocklist failed: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		blocked bool
+	}{
+		{"198.51.100.7", true},
+		{"198.51.100.8", false},
+		{"203.0.113.200", true},
+		{"::ffff:203.0.113.5", true},
+		{"2001:db8:bad:1::1", true},
+		{"2001:db8:good::1", false},
+		{"not-an-ip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			blocked, err := blocklist.Blocked(tt.ip)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if blocked != tt.blocked {
+				t.Errorf("Expected blocked=%v, got %v", tt.blocked, blocked)
+			}
+		})
+	}
+}
+
+func TestFileBlocklistReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	os.WriteFile(path, []byte("198.51.100.7\n"), 0o644)
+
+	blocklist, err := NewFileBlocklist(path)
+	if err != nil {
+		t.Fatalf("NewFileBlocklist failed: %v", err)
+	}
+
+	os.WriteFile(path, []byte("198.51.100.8\n"), 0o644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	if blocked, _ := blocklist.Blocked("198.51.100.8"); !blocked {
+		t.Error("Expected changed file to be reloaded")
+	}
+	if blocked, _ := blocklist.Blocked("198.51.100.7"); blocked {
+		t.Error("Expected removed entry not to be blocked anymore")
+	}
+}
+
+func TestFileBlocklistInvalidEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	os.WriteFile(path, []byte("198.51.100.7\nbogus\n"), 0o644)
+
+	if _, err := NewFileBlocklist(path); err == nil {
+		t.Error("Expected error for invalid entry")
+	}
+}
+
+func TestCrowdSecBlocklist(t *testing.T) {
+	requests := 0
+	lapi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("X-Api-Key") != "bouncer-key" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/v1/decisions" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("ip") == "198.51.100.7" {
+			w.Write([]byte(`[{"type":"ban","value":"198.51.100.7","scope":"Ip"}]`))
+			return
+		}
+		w.Write([]byte("null"))
+	}))
+	defer lapi.Close()
+
+	blocklist := NewCrowdSecBlocklist(lapi.URL+"/", "bouncer-key")
+
+	if blocked, err := blocklist.Blocked("198.51.100.7"); err != nil || !blocked {
+		t.Errorf("Expected banned address to be blocked, got %v, %v", blocked, err)
+	}
+	if blocked, err := blocklist.Blocked("198.51.100.8"); err != nil || blocked {
+		t.Errorf("Expected address without decision not to be blocked, got %v, %v", blocked, err)
+	}
+	blocklist.Blocked("198.51.100.7")
+	if requests != 2 {
+		t.Errorf("Expected cached answer to be reused, got %d requests", requests)
+	}
+
+	blocklist.APIKey = "wrong"
+	if _, err := blocklist.Blocked("198.51.100.9"); err == nil {
+		t.Error("Expected error for rejected API key")
+	}
+}
+
+func TestCrowdSecCacheBounded(t *testing.T) {
+	blocklist := NewCrowdSecBlocklist("http://127.0.0.1:1", "bouncer-key")
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	blocklist.now = func() time.Time { return now }
+
+	// Rotating source addresses must not grow the cache without limit
+	for i := 0; i < crowdSecCacheLimit+10; i++ {
+		now = now.Add(time.Millisecond)
+		blocklist.store(fmt.Sprintf("2001:db8::%x", i), false)
+	}
+	if len(blocklist.cache) != crowdSecCacheLimit {
+		t.Errorf("Expected %d cached answers, got %d", crowdSecCacheLimit, len(blocklist.cache))
+	}
+	if _, ok := blocklist.cache["2001:db8::0"]; ok {
+		t.Error("Expected the answer expiring first to be evicted")
+	}
+
+	now = now.Add(blocklist.CacheTTL)
+	blocklist.cache["198.51.100.8"] = crowdSecCacheEntry{expires: now.Add(time.Hour)}
+	blocklist.store("198.51.100.7", true)
+	if len(blocklist.cache) != 2 {
+		t.Errorf("Expected expired answers to be evicted, got %d cached", len(blocklist.cache))
+	}
+}
+
+// staticBlocklist blocks a fixed address or fails
+type staticBlocklist struct {
+	ip  string
+	err error
+}
+
+func (b staticBlocklist) Blocked(ip string) (bool, error) {
+	return ip == b.ip, b.err
+}
+
+func TestRejectBlocked(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.addBlocklist(staticBlocklist{err: os.ErrNotExist})
+	server.addBlocklist(staticBlocklist{ip: "198.51.100.7"})
+
+	handler := server.rejectBlocked(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tests := []struct {
+		remoteAddr     string
+		forwardedFor   string
+		expectedStatus int
+	}{
+		{"198.51.100.7:1234", "", http.StatusForbidden},
+		{"198.51.100.8:1234", "", http.StatusTeapot},
+		// Forwarding headers are forged easily and must not unblock
+		{"198.51.100.7:1234", "203.0.113.1", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remoteAddr+" "+tt.forwardedFor, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/update", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+				req.Header.Set("X-Real-IP", tt.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+
+	if value := server.metrics.Value("dyndns_blocked_requests_total", nil); value != 2 {
+		t.Errorf("Expected 2 blocked requests, got %v", value)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// bogonPrefixes are the ranges that never make sense as the public address of
+// a DynDNS host. FritzBoxes occasionally report their WAN address while it is
+// still a CGNAT or LAN address, publishing it would break the hostname.
+var bogonPrefixes = mustParsePrefixes(
+	"0.0.0.0/8",       // this network
+	"10.0.0.0/8",      // RFC 1918
+	"100.64.0.0/10",   // carrier-grade NAT, RFC 6598
+	"127.0.0.0/8",     // loopback
+	"169.254.0.0/16",  // link-local
+	"172.16.0.0/12",   // RFC 1918
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // TEST-NET-1, RFC 5737
+	"192.168.0.0/16",  // RFC 1918
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // TEST-NET-2
+	"203.0.113.0/24",  // TEST-NET-3
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved and broadcast
+	"::/128",          // unspecified
+	"::1/128",         // loopback
+	"::ffff:0:0/96",   // IPv4-mapped
+	"64:ff9b:1::/48",  // local-use NAT64
+	"100::/64",        // discard
+	"2001:db8::/32",   // documentation, RFC 3849
+	"3fff::/20",       // documentation, RFC 9637
+	"fc00::/7",        // unique local
+	"fe80::/10",       // link-local
+	"ff00::/8",        // multicast
+)
+
+// mustParsePrefixes parses CIDR prefixes, panicking on invalid ones
+func mustParsePrefixes(cidrs ...string) []netip.Prefix {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefixes = append(prefixes, netip.MustParsePrefix(cidr))
+	}
+	return prefixes
+}
+
+// bogonRange returns the private or reserved range containing ip, or an invalid prefix if ip is public
+func bogonRange(ip string) netip.Prefix {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Prefix{}
+	}
+	for _, prefix := range bogonPrefixes {
+		if prefix.Contains(addr) {
+			return prefix
+		}
+	}
+	return netip.Prefix{}
+}
+
+// checkPublicIP rejects private and reserved addresses unless bogons are allowed
+func (s *DynDNSServer) checkPublicIP(ip string) error {
+	if !s.rejectBogons || ip == "" {
+		return nil
+	}
+	if prefix := bogonRange(ip); prefix.IsValid() {
+		return fmt.Errorf("%s is not a public address (%s)", ip, prefix)
+	}
+	return nil
+}
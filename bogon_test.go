@@ -0,0 +1,82 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBogonRange(t *testing.T) {
+	tests := []struct {
+		ip    string
+		bogon bool
+	}{
+		{"192.168.178.20", true},
+		{"10.1.2.3", true},
+		{"172.20.0.1", true},
+		{"100.64.12.1", true},
+		{"127.0.0.1", true},
+		{"169.254.1.1", true},
+		{"203.0.113.7", true},
+		{"0.0.0.0", true},
+		{"fe80::1", true},
+		{"fd00::1234", true},
+		{"::1", true},
+		{"2001:db8::1", true},
+		{"::ffff:192.168.1.1", true},
+		{"1.1.1.1", false},
+		{"100.128.0.1", false},
+		{"172.32.0.1", false},
+		{"2a01:4f8::1", false},
+		{"not-an-ip", false},
+	}
+
+	for _, tt := range tests {
+		if got := bogonRange(tt.ip).IsValid(); got != tt.bogon {
+			t.Errorf("bogonRange(%s): expected bogon=%v, got %v", tt.ip, tt.bogon, got)
+		}
+	}
+}
+
+func TestHandleUpdateRejectsBogons(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		allowPrivate  bool
+		expectedCode  int
+		bodyContains  string
+		expectedWrite bool
+	}{
+		{name: "private IPv4", query: "myip=192.168.178.20", expectedCode: http.StatusBadRequest, bodyContains: "192.168.0.0/16"},
+		{name: "CGNAT IPv4", query: "myip=100.64.0.10", expectedCode: http.StatusBadRequest, bodyContains: "100.64.0.0/10"},
+		{name: "link-local IPv6 next to public IPv4", query: "myip=1.1.1.1&myipv6=fe80::1", expectedCode: http.StatusBadRequest, bodyContains: "fe80::/10"},
+		{name: "public addresses", query: "myip=1.1.1.1&myipv6=2a01:4f8::1", expectedCode: http.StatusOK, bodyContains: "good", expectedWrite: true},
+		{name: "private allowed", query: "myip=192.168.178.20", allowPrivate: true, expectedCode: http.StatusOK, bodyContains: "good", expectedWrite: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, nil, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.rejectBogons = !tt.allowPrivate
+
+			req := httptest.NewRequest("GET", "/update?hostname=home.example.com&"+tt.query, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if w.Code != tt.expectedCode || !strings.Contains(w.Body.String(), tt.bodyContains) {
+				t.Errorf("Expected %d containing %q, got %d: %s", tt.expectedCode, tt.bodyContains, w.Code, w.Body.String())
+			}
+			if (len(writes) > 0) != tt.expectedWrite {
+				t.Errorf("Expected write=%v, got %v", tt.expectedWrite, writes)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+)
+
+// TTL range accepted by the Hetzner DNS API
+const (
+	minRecordTTL = 60
+	maxRecordTTL = 86400
+)
+
+// configChecker validates a loaded configuration against the live environment for check-config
+type configChecker struct {
+	server *DynDNSServer
+	cfg    *Config
+	out    io.Writer
+	client *http.Client
+	dial   func(network, address string, timeout time.Duration) (net.Conn, error)
+
+	zones  map[*Client][]Zone
+	failed int
+}
+
+// runCheckConfig validates tokens, zone coverage, TTLs and notification endpoints
+// and returns an error if any check failed, for use in CI and before deployments
+func runCheckConfig(server *DynDNSServer, cfg *Config, out io.Writer) error {
+	checker := &configChecker{
+		server: server,
+		cfg:    cfg,
+		out:    out,
+		client: &http.Client{Timeout: 10 * time.Second},
+		dial:   net.DialTimeout,
+		zones:  make(map[*Client][]Zone),
+	}
+	return checker.Run()
+}
+
+// Run performs all checks and reports each result
+func (c *configChecker) Run() error {
+	fmt.Fprintln(c.out, "ok    configuration: environment variables are valid")
+	c.checkTokens()
+	c.checkHostnames()
+	c.checkTTLs()
+	c.checkNotifications()
+
+	if c.failed > 0 {
+		return fmt.Errorf("%d check(s) failed", c.failed)
+	}
+	fmt.Fprintln(c.out, "All checks passed")
+	return nil
+}
+
+// report writes the result of a check, err nil means it passed
+func (c *configChecker) report(name string, err error, hint string) {
+	if err == nil {
+		fmt.Fprintf(c.out, "ok    %s\n", name)
+		return
+	}
+	c.failed++
+	fmt.Fprintf(c.out, "FAIL  %s: %v\n", name, err)
+	if hint != "" {
+		fmt.Fprintf(c.out, "      %s\n", hint)
+	}
+}
+
+// checkTokens lists the zones of every configured API token
+func (c *configChecker) checkTokens() {
+	type token struct {
+		name   string
+		client *Client
+	}
+	var tokens []token
+	if c.server.client != nil {
+		tokens = append(tokens, token{"HETZNER_DNS_API_KEY", c.server.client})
+	}
+	if c.server.router != nil {
+		for _, route := range c.server.router.routes {
+			tokens = append(tokens, token{"DYNDNS_ZONE_TOKENS entry " + route.pattern, route.client})
+		}
+	}
+
+	for _, token := range tokens {
+		zones, err := token.client.GetZones()
+		if err != nil {
+			c.report("token "+token.name, err, "Check that the token is valid and has access to the DNS zones")
+			continue
+		}
+		c.zones[token.client] = zones
+		c.report(fmt.Sprintf("token %s (%d zones)", token.name, len(zones)), nil, "")
+	}
+}
+
+// checkHostnames verifies that a zone covers every configured hostname
+func (c *configChecker) checkHostnames() {
+	hostnames := slices.Clone(c.cfg.Hostnames)
+	for _, record := range c.cfg.ManifestRecords {
+		hostnames = append(hostnames, record.Name)
+	}
+	for _, tenant := range c.cfg.Tenants {
+		hostnames = append(hostnames, tenant.Zones...)
+	}
+	sort.Strings(hostnames)
+
+	for _, hostname := range slices.Compact(hostnames) {
+		// Patterns are matched at update time, only concrete names can be checked
+		if strings.Contains(hostname, "*") {
+			continue
+		}
+		name := "zone for " + hostname
+		client, err := c.server.clientFor(hostname)
+		if err != nil {
+			c.report(name, err, "Add the zone to DYNDNS_ZONE_TOKENS or set HETZNER_DNS_API_KEY")
+			continue
+		}
+		zones, ok := c.zones[client]
+		if !ok {
+			c.report(name, errors.New("the token of the zone was rejected"), "")
+			continue
+		}
+		zone, _ := dyndns.ZoneFor(zones, hostname)
+		if zone == nil {
+			c.report(name, fmt.Errorf("no zone of the token covers %s", hostname), "Create the zone in Hetzner DNS or fix the hostname")
+			continue
+		}
+		c.report(fmt.Sprintf("zone for %s (%s)", hostname, zone.Name), nil, "")
+	}
+}
+
+// checkTTLs verifies that configured TTLs are accepted by the API
+func (c *configChecker) checkTTLs() {
+	for _, record := range c.cfg.ManifestRecords {
+		if record.TTL == 0 {
+			continue
+		}
+		var err error
+		if record.TTL < minRecordTTL || record.TTL > maxRecordTTL {
+			err = fmt.Errorf("%d is outside %d to %d", record.TTL, minRecordTTL, maxRecordTTL)
+		}
+		c.report(fmt.Sprintf("ttl of %s %s", record.Name, record.Type), err, "Change the ttl in "+c.cfg.Manifest)
+	}
+}
+
+// checkNotifications verifies that the endpoints of the enabled notifiers answer
+func (c *configChecker) checkNotifications() {
+	notify := c.cfg.Notify
+	endpoints := map[string]string{
+		"webhook":  notify.WebhookURL,
+		"ntfy":     notify.NtfyURL,
+		"gotify":   notify.GotifyURL,
+		"telegram": "https://api.telegram.org",
+		"pushover": "https://api.pushover.net",
+	}
+	for _, notifier := range notify.Notifiers {
+		if notifier == "email" {
+			c.report("email notifier "+notify.SMTP.Addr, c.checkDial(notify.SMTP.Addr), "Check DYNDNS_NOTIFY_SMTP_ADDR and the firewall")
+			continue
+		}
+		if endpoint := endpoints[notifier]; endpoint != "" {
+			c.report(notifier+" notifier "+endpoint, c.checkURL(endpoint), "Check the URL and that the service is reachable from here")
+		}
+	}
+	for _, tenant := range c.cfg.Tenants {
+		for _, endpoint := range []string{tenant.NotifyWebhookURL, tenant.NotifyNtfyURL} {
+			if endpoint != "" {
+				c.report("notifier of tenant "+tenant.Name+" "+endpoint, c.checkURL(endpoint), "Check the URL in the tenants file")
+			}
+		}
+	}
+}
+
+// checkURL reports whether endpoint answers at all, any HTTP status counts as reachable
+func (c *configChecker) checkURL(endpoint string) error {
+	req, err := http.NewRequest(http.MethodHead, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// checkDial reports whether a TCP connection to address can be opened
+func (c *configChecker) checkDial(address string) error {
+	conn, err := c.dial("tcp", address, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunCheckConfig(t *testing.T) {
+	fake := newTestFake(t)
+	fake.tokens = []string{"valid-token"}
+	mockAPI := httptest.NewServer(fake)
+	defer mockAPI.Close()
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}))
+	defer webhook.Close()
+
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	unreachable := "http://" + closed.Addr().String()
+	closed.Close()
+
+	tests := []struct {
+		name           string
+		token          string
+		cfg            Config
+		expectError    bool
+		expectedOutput []string
+	}{
+		{
+			name:  "valid configuration",
+			token: "valid-token",
+			cfg: Config{
+				Hostnames:       []string{"home.example.com", "*.example.com"},
+				ManifestRecords: []ManifestRecord{{Name: "nas.example.com", Type: "A", TTL: 300, Target: "public-ipv4"}},
+				Notify:          NotifyConfig{Notifiers: []string{"webhook"}, WebhookURL: webhook.URL},
+			},
+			expectedOutput: []string{"ok    token HETZNER_DNS_API_KEY (1 zones)", "ok    zone for home.example.com (example.com)", "ok    webhook notifier", "All checks passed"},
+		},
+		{
+			name:           "rejected token",
+			token:          "expired-token",
+			cfg:            Config{Hostnames: []string{"home.example.com"}},
+			expectError:    true,
+			expectedOutput: []string{"FAIL  token HETZNER_DNS_API_KEY", "FAIL  zone for home.example.com: the token of the zone was rejected"},
+		},
+		{
+			name:           "hostname without zone",
+			token:          "valid-token",
+			cfg:            Config{Hostnames: []string{"home.example.org"}},
+			expectError:    true,
+			expectedOutput: []string{"FAIL  zone for home.example.org: no zone of the token covers home.example.org", "Create the zone"},
+		},
+		{
+			name:           "TTL out of range",
+			token:          "valid-token",
+			cfg:            Config{Manifest: "records.yaml", ManifestRecords: []ManifestRecord{{Name: "nas.example.com", Type: "A", TTL: 30, Target: "public-ipv4"}}},
+			expectError:    true,
+			expectedOutput: []string{"FAIL  ttl of nas.example.com A: 30 is outside 60 to 86400", "records.yaml"},
+		},
+		{
+			name:           "unreachable notification endpoint",
+			token:          "valid-token",
+			cfg:            Config{Notify: NotifyConfig{Notifiers: []string{"ntfy"}, NtfyURL: unreachable}},
+			expectError:    true,
+			expectedOutput: []string{"FAIL  ntfy notifier " + unreachable},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(tt.token)
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+
+			var out bytes.Buffer
+			err := runCheckConfig(server, &tt.cfg, &out)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Expected error %v, got %v\n%s", tt.expectError, err, out.String())
+			}
+			for _, expected := range tt.expectedOutput {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("Expected output to contain %q, got:\n%s", expected, out.String())
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// defaultFlapThreshold is the number of value changes per hour above which a record is flapping
+const defaultFlapThreshold = 4
+
+// recordChurn describes how stable the value of a record is, as reported by /api/status
+type recordChurn struct {
+	// Changes counts the value changes since startup, repeated updates to the same value are not counted
+	Changes    int        `json:"changes"`
+	LastChange *time.Time `json:"last_change,omitempty"`
+	// AverageLeaseSeconds is the average time a value was kept, known after the second change
+	AverageLeaseSeconds float64 `json:"average_lease_seconds,omitempty"`
+	ChangesLastHour     int     `json:"changes_last_hour"`
+	// Flapping is set while the value changes more often per hour than DYNDNS_FLAP_THRESHOLD
+	Flapping bool `json:"flapping"`
+}
+
+// churnState is the change history of a record
+type churnState struct {
+	changes     int
+	first, last time.Time
+	recent      []time.Time
+	flapping    bool
+}
+
+// churnTracker counts value changes per record to spot unstable ISP connections or
+// routers reconnecting in a loop, alerting when a record starts flapping
+type churnTracker struct {
+	mu      sync.Mutex
+	records map[string]*churnState
+	// threshold is the number of changes per hour tolerated, zero disables flap detection
+	threshold int
+	events    *EventBus
+	metrics   *Metrics
+	now       func() time.Time
+}
+
+// newChurnTracker creates a tracker alerting through events when a record flaps
+func newChurnTracker(threshold int, events *EventBus, metrics *Metrics) *churnTracker {
+	metrics.Describe("dyndns_ip_changes_total", "counter", "Number of record value changes by type.")
+	metrics.Describe("dyndns_flapping_records", "gauge", "Number of records whose value changes more often than the flap threshold.")
+	return &churnTracker{
+		records:   make(map[string]*churnState),
+		threshold: threshold,
+		events:    events,
+		metrics:   metrics,
+		now:       time.Now,
+	}
+}
+
+// Observe counts a value change of hostname's record of recordType
+func (t *churnTracker) Observe(hostname, recordType string) {
+	t.mu.Lock()
+	key := hostname + "/" + recordType
+	state, ok := t.records[key]
+	if !ok {
+		state = &churnState{}
+		t.records[key] = state
+	}
+	now := t.now().UTC()
+	if state.changes == 0 {
+		state.first = now
+	}
+	state.changes++
+	state.last = now
+	state.recent = append(pruneBefore(state.recent, now.Add(-time.Hour)), now)
+
+	startsFlapping := t.threshold > 0 && len(state.recent) > t.threshold && !state.flapping
+	if startsFlapping {
+		state.flapping = true
+	}
+	changes := len(state.recent)
+	flapping := t.countFlapping(now)
+	t.mu.Unlock()
+
+	t.metrics.Inc("dyndns_ip_changes_total", Labels{"type": recordType})
+	t.metrics.Set("dyndns_flapping_records", nil, float64(flapping))
+	if startsFlapping {
+		message := fmt.Sprintf("%s %s changed %d times within an hour, the connection or router may be unstable", hostname, recordType, changes)
+		log.Printf("Flapping: %s", message)
+		t.events.Publish(Event{Kind: eventAlert, Severity: severityWarning, Hostname: hostname, Type: recordType, Message: message})
+	}
+}
+
+// countFlapping updates the flapping state of all records and returns how many are flapping
+func (t *churnTracker) countFlapping(now time.Time) int {
+	flapping := 0
+	for _, state := range t.records {
+		state.recent = pruneBefore(state.recent, now.Add(-time.Hour))
+		if state.flapping && len(state.recent) <= t.threshold {
+			state.flapping = false
+		}
+		if state.flapping {
+			flapping++
+		}
+	}
+	return flapping
+}
+
+// Stats returns the churn of hostname's record of recordType, nil if its value never changed
+func (t *churnTracker) Stats(hostname, recordType string) *recordChurn {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.records[hostname+"/"+recordType]
+	if !ok {
+		return nil
+	}
+	t.metrics.Set("dyndns_flapping_records", nil, float64(t.countFlapping(t.now().UTC())))
+	last := state.last
+	churn := &recordChurn{
+		Changes:         state.changes,
+		LastChange:      &last,
+		ChangesLastHour: len(state.recent),
+		Flapping:        state.flapping,
+	}
+	if state.changes > 1 {
+		churn.AverageLeaseSeconds = state.last.Sub(state.first).Seconds() / float64(state.changes-1)
+	}
+	return churn
+}
+
+// pruneBefore drops the times before cutoff from the sorted slice times
+func pruneBefore(times []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(times) && times[i].Before(cutoff) {
+		i++
+	}
+	return times[i:]
+}
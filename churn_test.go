@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChurnTrackerFlapping(t *testing.T) {
+	tests := []struct {
+		name             string
+		threshold        int
+		changes          int
+		interval         time.Duration
+		expectedAlerts   int
+		expectedFlapping bool
+	}{
+		{name: "stable", threshold: 4, changes: 2, interval: 10 * time.Minute},
+		{name: "at the threshold", threshold: 4, changes: 4, interval: 5 * time.Minute},
+		{name: "flapping", threshold: 4, changes: 8, interval: 5 * time.Minute, expectedAlerts: 1, expectedFlapping: true},
+		{name: "spread over hours", threshold: 4, changes: 8, interval: 20 * time.Minute},
+		{name: "disabled", changes: 8, interval: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := NewEventBus()
+			var alerts []Event
+			events.Subscribe(func(event Event) { alerts = append(alerts, event) }, eventAlert)
+			metrics := NewMetrics()
+			tracker := newChurnTracker(tt.threshold, events, metrics)
+			now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+			tracker.now = func() time.Time { return now }
+
+			for i := 0; i < tt.changes; i++ {
+				if i > 0 {
+					now = now.Add(tt.interval)
+				}
+				tracker.Observe("home.example.com", "A")
+			}
+
+			if len(alerts) != tt.expectedAlerts {
+				t.Errorf("Expected %d alerts, got %v", tt.expectedAlerts, alerts)
+			}
+			churn := tracker.Stats("home.example.com", "A")
+			if churn.Changes != tt.changes {
+				t.Errorf("Expected %d changes, got %d", tt.changes, churn.Changes)
+			}
+			if churn.Flapping != tt.expectedFlapping {
+				t.Errorf("Expected flapping %v, got %v", tt.expectedFlapping, churn.Flapping)
+			}
+			if churn.AverageLeaseSeconds != tt.interval.Seconds() {
+				t.Errorf("Expected an average lease of %v, got %vs", tt.interval, churn.AverageLeaseSeconds)
+			}
+			if got := metrics.Value("dyndns_ip_changes_total", Labels{"type": "A"}); got != float64(tt.changes) {
+				t.Errorf("Expected %d counted changes, got %v", tt.changes, got)
+			}
+		})
+	}
+}
+
+func TestChurnTrackerRecovers(t *testing.T) {
+	events := NewEventBus()
+	var alerts []Event
+	events.Subscribe(func(event Event) { alerts = append(alerts, event) }, eventAlert)
+	metrics := NewMetrics()
+	tracker := newChurnTracker(2, events, metrics)
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tracker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		tracker.Observe("home.example.com", "AAAA")
+	}
+	if !tracker.Stats("home.example.com", "AAAA").Flapping || metrics.Value("dyndns_flapping_records", nil) != 1 {
+		t.Fatal("Expected the record to be flapping")
+	}
+
+	now = now.Add(2 * time.Hour)
+	churn := tracker.Stats("home.example.com", "AAAA")
+	if churn.Flapping || churn.ChangesLastHour != 0 || metrics.Value("dyndns_flapping_records", nil) != 0 {
+		t.Errorf("Expected the record to have recovered, got %+v", churn)
+	}
+
+	// A new burst alerts again
+	for i := 0; i < 3; i++ {
+		tracker.Observe("home.example.com", "AAAA")
+	}
+	if len(alerts) != 2 {
+		t.Errorf("Expected 2 alerts, got %v", alerts)
+	}
+}
+
+func TestStatusReportsChurn(t *testing.T) {
+	server := NewDynDNSServer(nil, "admin", "password", "8080")
+	server.status.Record("home.example.com", "A", "192.0.2.2", recordStateOK, nil)
+	server.status.Record("nas.example.com", "A", "192.0.2.3", recordStateOK, nil)
+	server.notifyIPChange("home.example.com", "A", "192.0.2.1", "192.0.2.2")
+
+	records := server.currentStatus().Records
+	for _, record := range records {
+		switch record.Hostname {
+		case "home.example.com":
+			if record.Churn == nil || record.Churn.Changes != 1 || record.Churn.LastChange == nil {
+				t.Errorf("Expected one change of home.example.com, got %+v", record.Churn)
+			}
+		case "nas.example.com":
+			if record.Churn != nil {
+				t.Errorf("Expected no churn for nas.example.com, got %+v", record.Churn)
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// circuitStateValues are the values of the dyndns_circuit_breaker_state gauge
+var circuitStateValues = map[string]float64{circuitClosed: 0, circuitHalfOpen: 1, circuitOpen: 2}
+
+// errCircuitOpen is returned for requests short-circuited by an open breaker
+var errCircuitOpen = fmt.Errorf("circuit breaker open, Hetzner DNS API requests are suspended")
+
+// circuitBreaker stops sending requests after several consecutive failures
+// of the API. Once the cooldown has passed a single probe request is let
+// through, its failure reopens the breaker with twice the cooldown.
+type circuitBreaker struct {
+	threshold   int
+	cooldown    time.Duration
+	maxCooldown time.Duration
+	onChange    func(state string)
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	// current is the cooldown of the current open period
+	current time.Duration
+	probing bool
+}
+
+// EnableCircuitBreaker suspends requests for cooldown after threshold consecutive
+// failed requests, doubling the cooldown up to maxCooldown while probes keep
+// failing. onChange is called with the new state on every transition and may be nil.
+func (c *Client) EnableCircuitBreaker(threshold int, cooldown, maxCooldown time.Duration, onChange func(state string)) {
+	if maxCooldown < cooldown {
+		maxCooldown = cooldown
+	}
+	c.breaker = &circuitBreaker{
+		threshold:   threshold,
+		cooldown:    cooldown,
+		maxCooldown: maxCooldown,
+		onChange:    onChange,
+		now:         time.Now,
+		state:       circuitClosed,
+	}
+}
+
+// CircuitState returns the state of the client's circuit breaker, closed if it has none
+func (c *Client) CircuitState() string {
+	if c.breaker == nil {
+		return circuitClosed
+	}
+	c.breaker.mu.Lock()
+	defer c.breaker.mu.Unlock()
+	return c.breaker.state
+}
+
+// allowRequest reports whether a request may be sent
+func (c *Client) allowRequest() bool {
+	if c.breaker == nil {
+		return true
+	}
+
+	b := c.breaker
+	b.mu.Lock()
+	switch b.state {
+	case circuitOpen:
+		if b.now().Sub(b.openedAt) < b.current {
+			b.mu.Unlock()
+			return false
+		}
+		b.state = circuitHalfOpen
+		b.probing = true
+		b.mu.Unlock()
+		b.changed(circuitHalfOpen)
+		return true
+	case circuitHalfOpen:
+		// Only a single probe is in flight while half-open
+		allowed := !b.probing
+		b.probing = true
+		b.mu.Unlock()
+		return allowed
+	}
+	b.mu.Unlock()
+	return true
+}
+
+// observeRequest records the outcome of a request sent to the API
+func (c *Client) observeRequest(failed bool) {
+	if c.breaker == nil {
+		return
+	}
+
+	b := c.breaker
+	b.mu.Lock()
+	previous := b.state
+	b.probing = false
+	switch {
+	case !failed:
+		b.state = circuitClosed
+		b.failures = 0
+	case b.state == circuitHalfOpen:
+		b.state = circuitOpen
+		b.openedAt = b.now()
+		b.current = min(2*b.current, b.maxCooldown)
+	case b.state == circuitClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.state = circuitOpen
+			b.openedAt = b.now()
+			b.current = b.cooldown
+		}
+	}
+	state := b.state
+	b.mu.Unlock()
+
+	if state != previous {
+		b.changed(state)
+	}
+}
+
+// changed reports a state transition
+func (b *circuitBreaker) changed(state string) {
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
+
+// enableCircuitBreaker configures the circuit breaker of client, exposing its
+// state as name in /readyz and the metrics and alerting when it opens
+func (s *DynDNSServer) enableCircuitBreaker(client *Client, name string, threshold int, cooldown, maxCooldown time.Duration) {
+	s.metrics.Describe("dyndns_circuit_breaker_state", "gauge", "State of the Hetzner DNS API circuit breaker, 0 closed, 1 half-open, 2 open.")
+	s.metrics.Describe("dyndns_circuit_breaker_opens_total", "counter", "Number of times the Hetzner DNS API circuit breaker opened.")
+	s.metrics.Set("dyndns_circuit_breaker_state", Labels{"token": name}, 0)
+
+	client.EnableCircuitBreaker(threshold, cooldown, maxCooldown, func(state string) {
+		s.metrics.Set("dyndns_circuit_breaker_state", Labels{"token": name}, circuitStateValues[state])
+		switch state {
+		case circuitOpen:
+			s.metrics.Inc("dyndns_circuit_breaker_opens_total", Labels{"token": name})
+			message := fmt.Sprintf("Hetzner DNS API requests with token %s keep failing, suspending them", name)
+			log.Print(message)
+			s.events.Publish(Event{Kind: eventAlert, Severity: severityWarning, Message: message})
+		case circuitHalfOpen:
+			log.Printf("Probing the Hetzner DNS API with token %s", name)
+		case circuitClosed:
+			log.Printf("Hetzner DNS API requests with token %s succeed again, resuming them", name)
+		}
+	})
+
+	if s.breakers == nil {
+		s.breakers = make(map[string]*Client)
+	}
+	s.breakers[name] = client
+}
+
+// readyResponse is returned by /readyz
+type readyResponse struct {
+	Status string `json:"status"`
+	// CircuitBreakers maps token names to their breaker state
+	CircuitBreakers map[string]string `json:"circuit_breakers,omitempty"`
+}
+
+// handleReady reports whether updates can currently be written, answering 503
+// while a circuit breaker suspends requests to the API
+func (s *DynDNSServer) handleReady(w http.ResponseWriter, r *http.Request) {
+	response := readyResponse{Status: "ready"}
+	for name, client := range s.breakers {
+		if response.CircuitBreakers == nil {
+			response.CircuitBreakers = make(map[string]string)
+		}
+		state := client.CircuitState()
+		response.CircuitBreakers[name] = state
+		if state != circuitClosed {
+			response.Status = "unavailable"
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if response.Status != "ready" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	var failing atomic.Bool
+	failing.Store(true)
+	fake := newTestFake(t)
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		fake.ServeHTTP(w, r)
+	}))
+	defer mockAPI.Close()
+
+	var transitions []string
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	client.EnableCircuitBreaker(2, time.Minute, 3*time.Minute, func(state string) { transitions = append(transitions, state) })
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+
+	steps := []struct {
+		name             string
+		advance          time.Duration
+		recover          bool
+		expectedState    string
+		expectedRequests int32
+	}{
+		{name: "first failure", expectedState: circuitClosed, expectedRequests: 1},
+		{name: "threshold reached", expectedState: circuitOpen, expectedRequests: 2},
+		{name: "short-circuited while open", expectedState: circuitOpen, expectedRequests: 2},
+		{name: "failed probe doubles cooldown", advance: time.Minute, expectedState: circuitOpen, expectedRequests: 3},
+		{name: "still suspended", advance: time.Minute, expectedState: circuitOpen, expectedRequests: 3},
+		{name: "successful probe closes", advance: time.Minute, recover: true, expectedState: circuitClosed, expectedRequests: 4},
+	}
+	for _, step := range steps {
+		now = now.Add(step.advance)
+		failing.Store(!step.recover)
+		_, err := client.GetZones()
+		if step.recover && err != nil {
+			t.Errorf("%s: unexpected error: %v", step.name, err)
+		}
+		if !step.recover && !isAPIUnavailable(err) {
+			t.Errorf("%s: expected API unavailable error, got %v", step.name, err)
+		}
+		if state := client.CircuitState(); state != step.expectedState {
+			t.Errorf("%s: expected state %s, got %s", step.name, step.expectedState, state)
+		}
+		if got := requests.Load(); got != step.expectedRequests {
+			t.Errorf("%s: expected %d requests, got %d", step.name, step.expectedRequests, got)
+		}
+	}
+
+	expected := []string{circuitOpen, circuitHalfOpen, circuitOpen, circuitHalfOpen, circuitClosed}
+	if len(transitions) != len(expected) {
+		t.Fatalf("Expected transitions %v, got %v", expected, transitions)
+	}
+	for i := range expected {
+		if transitions[i] != expected[i] {
+			t.Errorf("Expected transitions %v, got %v", expected, transitions)
+			break
+		}
+	}
+}
+
+func TestHandleReady(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.enableCircuitBreaker(client, "default", 1, time.Minute, time.Minute)
+
+	w := httptest.NewRecorder()
+	server.handleReady(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected 200 while the breaker is closed, got %d", w.Code)
+	}
+
+	client.GetZones()
+	w = httptest.NewRecorder()
+	server.handleReady(w, httptest.NewRequest("GET", "/readyz", nil))
+	var response readyResponse
+	json.NewDecoder(w.Body).Decode(&response)
+	if w.Code != http.StatusServiceUnavailable || response.CircuitBreakers["default"] != circuitOpen {
+		t.Errorf("Expected 503 with open breaker, got %d %+v", w.Code, response)
+	}
+	if value := server.metrics.Value("dyndns_circuit_breaker_state", Labels{"token": "default"}); value != 2 {
+		t.Errorf("Expected open state in metrics, got %v", value)
+	}
+	if value := server.metrics.Value("dyndns_circuit_breaker_opens_total", Labels{"token": "default"}); value != 1 {
+		t.Errorf("Expected one opening in metrics, got %v", value)
+	}
+}
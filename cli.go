@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// cliUsage describes the supported subcommands
+const cliUsage = `usage:
+  hetzner-dyndns                              start the DynDNS server
+  hetzner-dyndns --version                    print the version and build information
+  hetzner-dyndns --print-config               print the configuration with masked secrets
+  hetzner-dyndns --mock-provider              run against an in-memory fake of the Hetzner DNS API
+  hetzner-dyndns --uci <file>                 read the configuration from an OpenWrt UCI file, - for stdin
+  hetzner-dyndns check-config                 validate tokens, zones, TTLs and notification endpoints
+  hetzner-dyndns dashboard export             print a Grafana dashboard for the exposed metrics
+  hetzner-dyndns import ddclient|inadyn <file> convert the configuration of another client
+  hetzner-dyndns fakeapi [--listen :8081] [--zones <a,b>] [--state <file>] [--token <token>] [--rate-limit <n>]
+  hetzner-dyndns fritzbox-config <server-url> [hostname] [--qr file.png]
+  hetzner-dyndns record add <hostname> SRV|CAA <value>
+  hetzner-dyndns record delete <hostname> [A|AAAA|SRV|CAA [value]]
+  hetzner-dyndns migrate <zone> --to <token> [--from <token>] [--to-url <url>] [--dry-run]
+  hetzner-dyndns rollback <hostname>          restore the previous A and AAAA values`
+
+// runCommand executes a subcommand against the configured API and writes its result to out
+func runCommand(server *DynDNSServer, args []string, out io.Writer) error {
+	if len(args) > 0 && args[0] == "rollback" {
+		return runRollback(server, args[1:], out)
+	}
+	if len(args) > 0 && args[0] == "migrate" {
+		return runMigrate(server, args[1:], out)
+	}
+	if len(args) > 0 && args[0] == "fritzbox-config" {
+		return runFritzBoxConfig(server, args[1:], out)
+	}
+	if len(args) < 2 || args[0] != "record" || (args[1] != "delete" && args[1] != "add") {
+		return fmt.Errorf("unknown command: %s\n%s", strings.Join(args, " "), cliUsage)
+	}
+	if args[1] == "add" {
+		return runRecordAdd(server, args[2:], out)
+	}
+
+	if len(args) < 3 {
+		return fmt.Errorf("record delete requires a hostname\n%s", cliUsage)
+	}
+	hostname := strings.ToLower(args[2])
+	recordType := ""
+	if len(args) >= 4 {
+		recordType = strings.ToUpper(args[3])
+	}
+	if isServiceRecordType(recordType) {
+		return runServiceRecordDelete(server, hostname, recordType, strings.Join(args[4:], " "), out)
+	}
+	if len(args) > 4 {
+		return fmt.Errorf("record delete takes a value only for SRV and CAA records\n%s", cliUsage)
+	}
+
+	types, err := deleteTypes(recordType)
+	if err != nil {
+		return err
+	}
+	deleted, err := server.deleteHostRecords(hostname, types)
+	if err != nil {
+		return err
+	}
+
+	if len(deleted) == 0 {
+		fmt.Fprintf(out, "No records found for %s\n", hostname)
+		return nil
+	}
+	fmt.Fprintf(out, "Deleted %s records of %s\n", strings.Join(deleted, ", "), hostname)
+	return nil
+}
+
+// runRecordAdd handles "record add <hostname> SRV|CAA <value>", the value may span several arguments
+func runRecordAdd(server *DynDNSServer, args []string, out io.Writer) error {
+	if len(args) < 3 {
+		return fmt.Errorf("record add requires a hostname, type and value\n%s", cliUsage)
+	}
+	hostname := strings.ToLower(strings.TrimSuffix(args[0], "."))
+	recordType := strings.ToUpper(args[1])
+	if !isServiceRecordType(recordType) {
+		return fmt.Errorf("unsupported record type: %s", args[1])
+	}
+
+	value, created, err := server.addServiceRecord(hostname, recordType, strings.Join(args[2:], " "), 0)
+	if err != nil {
+		return err
+	}
+	if !created {
+		fmt.Fprintf(out, "%s record %s of %s already exists\n", recordType, value, hostname)
+		return nil
+	}
+	fmt.Fprintf(out, "Created %s record %s of %s\n", recordType, value, hostname)
+	return nil
+}
+
+// runServiceRecordDelete deletes the SRV or CAA records of hostname, only the one of value if given
+func runServiceRecordDelete(server *DynDNSServer, hostname, recordType, value string, out io.Writer) error {
+	deleted, err := server.removeServiceRecords(hostname, recordType, value)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		fmt.Fprintf(out, "No %s records found for %s\n", recordType, hostname)
+		return nil
+	}
+	fmt.Fprintf(out, "Deleted %d %s records of %s\n", deleted, recordType, hostname)
+	return nil
+}
+
+// runRollback handles "rollback <hostname>"
+func runRollback(server *DynDNSServer, args []string, out io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("rollback requires a hostname\n%s", cliUsage)
+	}
+	hostname := strings.ToLower(args[0])
+
+	restored, err := server.rollback(hostname)
+	if err != nil {
+		return err
+	}
+	for _, entry := range restored {
+		fmt.Fprintf(out, "Rolled back %s %s from %s to %s\n", hostname, entry.Type, entry.Replaced, entry.Value)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunCommandRecordDelete(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "old", Value: "1.1.1.1"}}, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.deletable = []string{"old.example.com"}
+
+	var out bytes.Buffer
+	if err := runCommand(server, []string{"record", "delete", "old.example.com"}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(writes, ";") != "DELETE rec1" {
+		t.Errorf("Expected record to be deleted, got %v", writes)
+	}
+	if !strings.Contains(out.String(), "Deleted A records of old.example.com") {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+}
+
+func TestRunCommandErrors(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+
+	tests := []struct {
+		name          string
+		args          []string
+		errorContains string
+	}{
+		{"unknown command", []string{"zone", "list"}, "unknown command"},
+		{"missing hostname", []string{"record", "delete"}, "requires a hostname"},
+		{"unsupported type", []string{"record", "delete", "old.example.com", "MX"}, "unsupported record type"},
+		{"not whitelisted", []string{"record", "delete", "home.example.com"}, "not whitelisted"},
+		{"add without value", []string{"record", "add", "example.com", "CAA"}, "requires a hostname, type and value"},
+		{"add unsupported type", []string{"record", "add", "example.com", "TXT", "hello"}, "unsupported record type"},
+		{"add invalid value", []string{"record", "add", "example.com", "CAA", "0", "issue", "lets", "encrypt"}, "invalid CAA issuer"},
+		{"value for A record", []string{"record", "delete", "old.example.com", "A", "1.1.1.1"}, "only for SRV and CAA"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runCommand(server, tt.args, &bytes.Buffer{})
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+			if !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("Expected error to contain '%s', got '%s'", tt.errorContains, err.Error())
+			}
+		})
+	}
+}
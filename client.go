@@ -3,11 +3,15 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"io"
 	"net/http"
+	"sync/atomic"
 	"time"
+
+	"fritzbox-hetzner-dyndns/dyndns"
 )
 
 const (
@@ -19,6 +23,21 @@ type Client struct {
 	APIKey     string
 	HTTPClient *http.Client
 	BaseURL    string
+	// PageSize fetches records in pages of this size, 0 lets the API return all records at once
+	PageSize int
+	// FilterByName asks the API for the records of a name instead of listing the zone, see GetRecordsByName
+	FilterByName bool
+	// filterUnsupported is set once the API ignored or rejected the name filter
+	filterUnsupported atomic.Bool
+	// zones optionally keeps the zones in memory, see EnableZoneIndex
+	zones *zoneIndex
+
+	// failover optionally switches to a secondary token, see EnableFailover
+	failover *tokenFailover
+	// breaker optionally suspends requests while the API keeps failing, see EnableCircuitBreaker
+	breaker *circuitBreaker
+	// requestLog optionally logs requests, see EnableRequestLog
+	requestLog *requestLogger
 }
 
 // NewClient creates a new Hetzner DNS API client
@@ -32,31 +51,61 @@ func NewClient(apiKey string) *Client {
 	}
 }
 
+// unavailableError marks failures caused by the API being unreachable or failing on its side
+type unavailableError struct {
+	err error
+}
+
+func (e unavailableError) Error() string { return e.err.Error() }
+func (e unavailableError) Unwrap() error { return e.err }
+
+// isAPIUnavailable reports whether err was caused by the API being unreachable or failing on its side
+func isAPIUnavailable(err error) bool {
+	var unavailable unavailableError
+	return errors.As(err, &unavailable)
+}
+
 // makeRequest makes an HTTP request to the Hetzner DNS API
 func (c *Client) makeRequest(method, endpoint string, body interface{}) (*http.Response, error) {
 	var reqBody io.Reader
+	var jsonBody []byte
 
 	if body != nil {
-		jsonBody, err := json.Marshal(body)
+		var err error
+		jsonBody, err = json.Marshal(body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		reqBody = bytes.NewBuffer(jsonBody)
+		reqBody = bytes.NewReader(jsonBody)
 	}
 
-	log.Printf("Execute request to '%s' using body '%s'", endpoint, reqBody)
 	req, err := http.NewRequest(method, c.BaseURL+endpoint, reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set headers
-	req.Header.Set("Auth-API-Token", c.APIKey)
+	req.Header.Set("Auth-API-Token", c.token())
 	req.Header.Set("Content-Type", "application/json")
 
+	if !c.allowRequest() {
+		return nil, unavailableError{errCircuitOpen}
+	}
+	start := time.Now()
 	resp, err := c.HTTPClient.Do(req)
+	c.observeRequest(err != nil || resp.StatusCode >= 500)
 	if err != nil {
-		return nil, fmt.Errorf("failed to make request: %w", err)
+		c.logRequest(method, endpoint, jsonBody, 0, time.Since(start), err)
+		return nil, unavailableError{fmt.Errorf("failed to make request: %w", err)}
+	}
+	c.logRequest(method, endpoint, jsonBody, resp.StatusCode, time.Since(start), nil)
+
+	// Retry once with the secondary token if the primary was just given up
+	if c.observeStatus(resp.StatusCode) {
+		// Drain the body so the connection is reused for the retry
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return c.makeRequest(method, endpoint, body)
 	}
 
 	return resp, nil
@@ -74,11 +123,19 @@ func (c *Client) handleResponse(resp *http.Response, result interface{}) error {
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 		var apiError APIError
 		if err := json.Unmarshal(body, &apiError); err != nil {
-			return fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+			err = fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+			if resp.StatusCode >= 500 {
+				return unavailableError{err}
+			}
+			return err
 		}
 
 		log.Printf("Error from API: '%d' using body '%s'", resp.StatusCode, string(body))
-		return fmt.Errorf("API error: %s", apiError.Error.Message)
+		err = fmt.Errorf("API error: %s", apiError.Error.Message)
+		if resp.StatusCode >= 500 {
+			return unavailableError{err}
+		}
+		return err
 	}
 
 	if result != nil {
@@ -92,19 +149,42 @@ func (c *Client) handleResponse(resp *http.Response, result interface{}) error {
 
 // GetAllRecords retrieves all DNS records for a zone
 func (c *Client) GetAllRecords(zoneID string) ([]DNSRecord, error) {
+	if c.PageSize <= 0 {
+		records, _, err := c.GetRecordsPage(zoneID, 0, 0)
+		return records, err
+	}
+
+	var all []DNSRecord
+	for page := 1; ; page++ {
+		records, pagination, err := c.GetRecordsPage(zoneID, page, c.PageSize)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, records...)
+		if page >= pagination.LastPage {
+			return all, nil
+		}
+	}
+}
+
+// GetRecordsPage retrieves a single page of DNS records for a zone, a page of 0 omits the pagination parameters
+func (c *Client) GetRecordsPage(zoneID string, page, perPage int) ([]DNSRecord, Pagination, error) {
 	endpoint := fmt.Sprintf("/records?zone_id=%s", zoneID)
+	if page > 0 {
+		endpoint += fmt.Sprintf("&page=%d&per_page=%d", page, perPage)
+	}
 
 	resp, err := c.makeRequest("GET", endpoint, nil)
 	if err != nil {
-		return nil, err
+		return nil, Pagination{}, err
 	}
 
 	var recordsResp RecordsResponse
 	if err := c.handleResponse(resp, &recordsResp); err != nil {
-		return nil, err
+		return nil, Pagination{}, err
 	}
 
-	return recordsResp.Records, nil
+	return recordsResp.Records, recordsResp.Meta.Pagination, nil
 }
 
 // GetRecord retrieves a specific DNS record by ID
@@ -168,17 +248,79 @@ func (c *Client) DeleteRecord(recordID string) error {
 	return c.handleResponse(resp, nil)
 }
 
-// GetZones retrieves all DNS zones
+// GetZones retrieves all DNS zones, following the pagination of accounts with many zones
 func (c *Client) GetZones() ([]Zone, error) {
-	resp, err := c.makeRequest("GET", "/zones", nil)
+	var all []Zone
+	for page := 1; ; page++ {
+		endpoint := "/zones"
+		if page > 1 {
+			endpoint = fmt.Sprintf("/zones?page=%d&per_page=%d", page, zonesPerPage)
+		}
+
+		resp, err := c.makeRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var zonesResp ZonesResponse
+		if err := c.handleResponse(resp, &zonesResp); err != nil {
+			return nil, err
+		}
+
+		all = append(all, zonesResp.Zones...)
+		if page >= zonesResp.Meta.Pagination.LastPage {
+			return all, nil
+		}
+	}
+}
+
+// zonesPerPage is the page size the API uses for zones by default
+const zonesPerPage = 100
+
+// defaultRecordTTL is the TTL of created dynamic records, 60 minutes
+const defaultRecordTTL = dyndns.DefaultTTL
+
+// FindZoneForFQDN returns the zone holding fqdn and the record name relative
+// to it, "@" for the apex. The most specific zone wins if zones are nested.
+func (c *Client) FindZoneForFQDN(fqdn string) (*Zone, string, error) {
+	zone, name, err := c.zoneFor(fqdn)
 	if err != nil {
-		return nil, err
+		return nil, "", fmt.Errorf("failed to get zones: %w", err)
 	}
+	if zone == nil {
+		return nil, "", fmt.Errorf("no zone found for hostname: %s", fqdn)
+	}
+	return zone, name, nil
+}
 
-	var zonesResp ZonesResponse
-	if err := c.handleResponse(resp, &zonesResp); err != nil {
+// GetRecordsByNameAndType returns the records of the zone with the given name and type
+func (c *Client) GetRecordsByNameAndType(zoneID, name, recordType string) ([]DNSRecord, error) {
+	records, err := c.GetRecordsByName(zoneID, name)
+	if err != nil {
 		return nil, err
 	}
 
-	return zonesResp.Zones, nil
+	var matching []DNSRecord
+	for _, record := range records {
+		if record.Name == name && record.Type == recordType {
+			matching = append(matching, record)
+		}
+	}
+	return matching, nil
+}
+
+// EnsureRecord makes the record name of recordType hold value, creating it
+// if it does not exist. A ttl of 0 keeps the TTL of an existing record and
+// uses defaultRecordTTL for a new one. It reports whether a write was needed.
+func (c *Client) EnsureRecord(zoneID, name, recordType, value string, ttl int) (*DNSRecord, bool, error) {
+	records, err := c.GetRecordsByNameAndType(zoneID, name, recordType)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	var existing *DNSRecord
+	if len(records) > 0 {
+		existing = &records[0]
+	}
+	return dyndns.UpsertRecord(c, zoneID, name, recordType, value, ttl, existing)
 }
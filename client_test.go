@@ -7,6 +7,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"fritzbox-hetzner-dyndns/dyndns"
 )
 
 func TestNewClient(t *testing.T) {
@@ -366,3 +368,144 @@ func TestGetZones(t *testing.T) {
 		t.Errorf("Unexpected zones: %+v", zones)
 	}
 }
+
+func TestZoneForFQDN(t *testing.T) {
+	zones := []Zone{
+		{ID: "zone1", Name: "example.com"},
+		{ID: "zone2", Name: "lab.example.com"},
+	}
+
+	tests := []struct {
+		fqdn         string
+		expectedZone string
+		expectedName string
+	}{
+		{"example.com", "zone1", "@"},
+		{"home.example.com", "zone1", "home"},
+		{"nas.lab.example.com", "zone2", "nas"},
+		{"Lab.Example.com.", "zone2", "@"},
+		{"example.org", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fqdn, func(t *testing.T) {
+			zone, name := dyndns.ZoneFor(zones, tt.fqdn)
+			if tt.expectedZone == "" {
+				if zone != nil {
+					t.Errorf("Expected no zone, got %s", zone.ID)
+				}
+				return
+			}
+			if zone == nil || zone.ID != tt.expectedZone || name != tt.expectedName {
+				t.Errorf("Expected %s/%s, got %v/%s", tt.expectedZone, tt.expectedName, zone, name)
+			}
+		})
+	}
+}
+
+func TestGetRecordsByNameAndType(t *testing.T) {
+	_, client := newFakeAPIServer(t,
+		DNSRecord{ID: "1", Type: "A", Name: "home", Value: "203.0.113.1"},
+		DNSRecord{ID: "2", Type: "AAAA", Name: "home", Value: "2001:db8::1"},
+		DNSRecord{ID: "3", Type: "A", Name: "other", Value: "203.0.113.2"},
+	)
+
+	records, err := client.GetRecordsByNameAndType("zone-example-com", "home", "A")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(records) != 1 || records[0].ID != "1" {
+		t.Errorf("Expected record 1, got %v", records)
+	}
+}
+
+func TestEnsureRecord(t *testing.T) {
+	ttl := 300
+	tests := []struct {
+		name          string
+		records       []DNSRecord
+		value         string
+		ttl           int
+		expectChanged bool
+		expectedWrite string
+	}{
+		{"create missing record", nil, "1.2.3.4", 0, true, "POST A home"},
+		{"update changed value", []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1", TTL: &ttl}}, "1.2.3.4", 0, true, "PUT rec1"},
+		{"update changed TTL", []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.2.3.4", TTL: &ttl}}, "1.2.3.4", 60, true, "PUT rec1"},
+		{"keep current record", []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.2.3.4", TTL: &ttl}}, "1.2.3.4", 0, false, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, tt.records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+
+			_, changed, err := client.EnsureRecord("zone-example-com", "home", "A", tt.value, tt.ttl)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if changed != tt.expectChanged {
+				t.Errorf("Expected changed %v, got %v", tt.expectChanged, changed)
+			}
+			if strings.Join(writes, ";") != tt.expectedWrite {
+				t.Errorf("Expected writes %q, got %v", tt.expectedWrite, writes)
+			}
+		})
+	}
+}
+
+func TestGetAllRecordsPaginated(t *testing.T) {
+	var pages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		pages = append(pages, query.Get("page")+"/"+query.Get("per_page"))
+
+		resp := RecordsResponse{Records: []DNSRecord{{ID: "page" + query.Get("page")}}}
+		resp.Meta.Pagination.LastPage = 3
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	client.PageSize = 2
+
+	records, err := client.GetAllRecords("zone123")
+	if err != nil {
+		t.Fatalf("GetAllRecords failed: %v", err)
+	}
+	if len(records) != 3 || records[2].ID != "page3" {
+		t.Errorf("Expected one record per page, got %+v", records)
+	}
+	if strings.Join(pages, ",") != "1/2,2/2,3/2" {
+		t.Errorf("Unexpected pages requested: %v", pages)
+	}
+}
+
+func TestGetZonesPaginated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("page")
+		if page == "" {
+			page = "1"
+		}
+		resp := ZonesResponse{Zones: []Zone{{ID: "zone" + page}}}
+		resp.Meta.Pagination.LastPage = 2
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+
+	zones, err := client.GetZones()
+	if err != nil {
+		t.Fatalf("GetZones failed: %v", err)
+	}
+	if len(zones) != 2 || zones[1].ID != "zone2" {
+		t.Errorf("Expected zones of both pages, got %+v", zones)
+	}
+}
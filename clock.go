@@ -0,0 +1,81 @@
+package main
+
+import "time"
+
+// Clock is the time source of caches, rate limits, backoffs and timestamps,
+// tests replace it to simulate the passing of time
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once d has passed
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a call scheduled with Clock.AfterFunc
+type Timer interface {
+	// Stop cancels the call and reports whether it was still pending
+	Stop() bool
+}
+
+// systemClock is the Clock of the operating system
+type systemClock struct{}
+
+// Now returns the current time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc schedules f with time.AfterFunc
+func (systemClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// sleep blocks for d as measured by clock
+func sleep(clock Clock, d time.Duration) {
+	done := make(chan struct{})
+	clock.AfterFunc(d, func() { close(done) })
+	<-done
+}
+
+// now returns the current time of the server's clock. It is the time source
+// of the trackers created with the server, so they follow later SetClock calls.
+func (s *DynDNSServer) now() time.Time {
+	return s.clock.Now()
+}
+
+// SetClock replaces the time source of the server and of the caches, rate
+// limiters and circuit breakers configured so far, so it is called once the
+// server is set up. Tenants added later inherit the clock.
+func (s *DynDNSServer) SetClock(clock Clock) {
+	s.clock = clock
+	if s.dnssec != nil {
+		s.dnssec.now = s.now
+	}
+	if s.notifications != nil {
+		s.notifications.clock = clock
+	}
+	if s.authLog != nil {
+		s.authLog.now = s.now
+	}
+	for _, blocklist := range s.blocklists {
+		if crowdSec, ok := blocklist.(*CrowdSecBlocklist); ok {
+			crowdSec.now = s.now
+		}
+	}
+	if s.nohost != nil {
+		s.nohost.now = s.now
+	}
+	if s.limiter != nil {
+		s.limiter.clock = clock
+	}
+	for _, client := range s.clients() {
+		if client == nil {
+			continue
+		}
+		if client.zones != nil {
+			client.zones.now = s.now
+		}
+		if client.breaker != nil {
+			client.breaker.now = s.now
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock whose time only moves with Advance
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// fakeTimer is a call scheduled on a fakeClock
+type fakeTimer struct {
+	clock *fakeClock
+	at    time.Time
+	f     func()
+	done  bool
+}
+
+// newFakeClock creates a clock standing at now
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	timer := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, timer)
+	return timer
+}
+
+// Advance moves the clock forward by d and runs the calls that became due, in order
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	var due []*fakeTimer
+	pending := c.timers[:0]
+	for _, timer := range c.timers {
+		switch {
+		case timer.done:
+		case !timer.at.After(c.now):
+			timer.done = true
+			due = append(due, timer)
+		default:
+			pending = append(pending, timer)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].at.Before(due[j].at) })
+	for _, timer := range due {
+		timer.f()
+	}
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	if t.done {
+		return false
+	}
+	t.done = true
+	return true
+}
+
+func TestRateLimiterClock(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	limiter := NewRateLimiter(time.Minute)
+	limiter.clock = clock
+
+	var written []string
+	write := func(value string) { written = append(written, value) }
+	if decision := limiter.Reserve("home/A", "203.0.113.1", write); decision != rateAllow {
+		t.Fatalf("Expected the first write to be allowed, got %v", decision)
+	}
+	clock.Advance(10 * time.Second)
+	if decision := limiter.Reserve("home/A", "203.0.113.2", write); decision != rateQueued {
+		t.Fatalf("Expected a differing write to be queued, got %v", decision)
+	}
+	limiter.Reserve("home/A", "203.0.113.3", write)
+
+	clock.Advance(49 * time.Second)
+	if len(written) != 0 {
+		t.Fatalf("Expected no write before the interval passed, got %v", written)
+	}
+	clock.Advance(time.Second)
+	if len(written) != 1 || written[0] != "203.0.113.3" {
+		t.Fatalf("Expected the latest queued value to be written, got %v", written)
+	}
+
+	// The queued write started a new interval
+	if decision := limiter.Reserve("home/A", "203.0.113.4", write); decision != rateQueued {
+		t.Errorf("Expected a write right after the flush to be queued, got %v", decision)
+	}
+	clock.Advance(time.Minute)
+	if decision := limiter.Reserve("home/A", "203.0.113.4", write); decision != rateNoChange {
+		t.Errorf("Expected the flushed value to be unchanged, got %v", decision)
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+
+	client := NewClient("test-api-key")
+	client.EnableZoneIndex(time.Hour)
+	client.EnableCircuitBreaker(1, time.Minute, time.Hour, nil)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.nohost = newNohostCache(time.Minute, time.Hour)
+	server.limiter = NewRateLimiter(time.Minute)
+	server.dnssec = NewDNSSECMonitor("", time.Hour, 0)
+	server.dnssec.lookup = func(ctx context.Context, resolver, zone string) (bool, error) { return true, nil }
+	server.SetClock(clock)
+
+	server.status.Record("home.example.com", "A", "203.0.113.1", recordStateOK, nil)
+	server.recordChange("home.example.com", "A", "", "203.0.113.1", nil)
+	server.rememberPrevious("home.example.com", "A", "203.0.113.1")
+	server.nohost.Miss("nohost.example.com")
+
+	if last := server.status.Snapshot()[0].LastUpdate; last == nil || !last.Equal(start) {
+		t.Errorf("Expected the status timestamp from the clock, got %v", last)
+	}
+	if entries, _ := server.recordHistory("home.example.com", ""); len(entries) != 1 || !entries[0].Time.Equal(start) {
+		t.Errorf("Expected the history timestamp from the clock, got %+v", entries)
+	}
+	if previous, _ := server.previous("home.example.com", "A"); previous == nil || !previous.ChangedAt.Equal(start) {
+		t.Errorf("Expected the previous value timestamp from the clock, got %+v", previous)
+	}
+	if !server.nohost.Cached("nohost.example.com") {
+		t.Fatalf("Expected the unknown hostname to be cached")
+	}
+	clock.Advance(2 * time.Minute)
+	if server.nohost.Cached("nohost.example.com") {
+		t.Errorf("Expected the cache entry to expire with the clock")
+	}
+	if client.zones.now().Sub(start) != 2*time.Minute || client.breaker.now().Sub(start) != 2*time.Minute {
+		t.Errorf("Expected the zone index and circuit breaker to use the clock")
+	}
+	if server.limiter.clock != Clock(clock) {
+		t.Errorf("Expected the rate limiter to use the clock")
+	}
+
+	now := start.Add(2 * time.Minute)
+	server.refreshed.Mark("home.example.com", "A")
+	server.health.Observe(unavailableError{errors.New("connection refused")})
+	server.maintenance.Begin("", maintenanceQueue)
+	defer server.maintenance.End()
+	var published Event
+	server.events.Subscribe(func(event Event) { published = event }, eventAlert)
+	server.events.Publish(Event{Kind: eventAlert})
+	server.dnssec.Signed("example.com")
+	if seen := server.refreshed.LastSeen("home.example.com", "A"); !seen.Equal(now) {
+		t.Errorf("Expected the refresh timestamp from the clock, got %v", seen)
+	}
+	if since := server.health.UnavailableSince(); !since.Equal(now) {
+		t.Errorf("Expected the outage to start at the clock's time, got %v", since)
+	}
+	if since := server.maintenance.Begin("", maintenanceQueue).Since; since == nil || !since.Equal(now) {
+		t.Errorf("Expected the maintenance start from the clock, got %v", since)
+	}
+	if !published.Timestamp.Equal(now) {
+		t.Errorf("Expected the event timestamp from the clock, got %v", published.Timestamp)
+	}
+	if status := server.dnssec.Status(); len(status) != 1 || !status[0].CheckedAt.Equal(now) {
+		t.Errorf("Expected the DNSSEC check time from the clock, got %+v", status)
+	}
+}
+
+func TestSleepClock(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	notifier := &recordingNotifier{failures: 1}
+	notifications := NewNotifications(nil, 1)
+	notifications.clock = clock
+	notifications.Add("test", notifier, eventIPChange)
+	notifications.Send(Event{Kind: eventIPChange})
+
+	// The retry waits for the backoff on the clock
+	deadline := time.Now().Add(2 * time.Second)
+	attempts := func() int {
+		notifier.mu.Lock()
+		defer notifier.mu.Unlock()
+		return notifier.attempts
+	}
+	for attempts() != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if calls := attempts(); calls != 1 {
+		t.Fatalf("Expected the retry to wait for the clock, got %d calls", calls)
+	}
+	clock.Advance(time.Second)
+	notifications.Wait()
+	if calls := attempts(); calls != 2 {
+		t.Errorf("Expected the retry after the backoff, got %d calls", calls)
+	}
+}
+
+func TestSetClockTimestamps(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	var authLog bytes.Buffer
+	server.authLog = NewAuthLogger(&authLog, "{time}")
+	reporter := NewReporter(server)
+	server.SetClock(newFakeClock(start.Add(-time.Hour)))
+	// A later clock replaces the one the server was set up with
+	server.SetClock(newFakeClock(start))
+
+	if since := NewReporter(server).Generate().Since; !since.Equal(start) {
+		t.Errorf("Expected the report period to start at the clock's time, got %v", since)
+	}
+	if generated := reporter.Generate().GeneratedAt; !generated.Equal(start) {
+		t.Errorf("Expected the report time from the clock, got %v", generated)
+	}
+	if timestamp := server.currentStatus().Timestamp; timestamp != "2026-03-01T12:00:00Z" {
+		t.Errorf("Expected the status timestamp from the clock, got %s", timestamp)
+	}
+
+	w := httptest.NewRecorder()
+	server.handleHealth(w, httptest.NewRequest("GET", "/health", nil))
+	var health healthResponse
+	if err := json.NewDecoder(w.Body).Decode(&health); err != nil || health.Timestamp != "2026-03-01T12:00:00Z" {
+		t.Errorf("Expected the health timestamp from the clock, got %+v, %v", health, err)
+	}
+
+	req := httptest.NewRequest("GET", "/update", nil)
+	server.authorize(httptest.NewRecorder(), req, authScopeUpdate)
+	if authLog.String() != "2026-03-01T12:00:00Z\n" {
+		t.Errorf("Expected the auth log time from the clock, got %q", authLog.String())
+	}
+
+	server.health.Observe(unavailableError{errors.New("connection refused")})
+	if since := server.health.UnavailableSince(); !since.Equal(start) {
+		t.Errorf("Expected the outage to start at the clock's time, got %v", since)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// minGzipSize is the smallest body worth compressing, smaller bodies fit in a packet anyway
+const minGzipSize = 512
+
+// Cache-Control policies of the read-only endpoints
+const (
+	// cacheRevalidate lets dashboards poll with If-None-Match every time
+	cacheRevalidate = "private, no-cache"
+	// cacheStatic is used for documents that only change with the binary
+	cacheStatic = "public, max-age=3600"
+)
+
+// bufferedResponse collects a response so it can be compressed and tagged as a whole
+type bufferedResponse struct {
+	w      http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.w.Header()
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// withCaching wraps a read-only handler with Cache-Control, ETags answered
+// with 304 Not Modified and gzip compression of successful responses. The
+// ETag is derived from the body unless next sets one itself.
+func withCaching(next http.HandlerFunc, cacheControl string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response := &bufferedResponse{w: w}
+		next(response, r)
+		if response.status == 0 {
+			response.status = http.StatusOK
+		}
+
+		cacheable := response.status == http.StatusOK && (r.Method == http.MethodGet || r.Method == http.MethodHead)
+		if !cacheable {
+			w.WriteHeader(response.status)
+			w.Write(response.body.Bytes())
+			return
+		}
+
+		body := response.body.Bytes()
+		etag := w.Header().Get("ETag")
+		if etag == "" {
+			etag = bodyETag(body)
+			w.Header().Set("ETag", etag)
+		}
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Add("Vary", "Accept-Encoding")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if len(body) >= minGzipSize && acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			var compressed bytes.Buffer
+			zw := gzip.NewWriter(&compressed)
+			zw.Write(body)
+			zw.Close()
+			body = compressed.Bytes()
+			w.Header().Set("Content-Encoding", "gzip")
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}
+}
+
+// bodyETag returns a weak ETag of body, weak because the gzip and identity
+// encodings of the same body share it
+func bodyETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// valueETag returns the ETag of v encoded as JSON, for responses whose body
+// also contains fields like timestamps that should not invalidate caches
+func valueETag(v any) string {
+	data, _ := json.Marshal(v)
+	return bodyETag(data)
+}
+
+// etagMatches reports whether the If-None-Match header matches etag using weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsGzip reports whether the Accept-Encoding header allows gzip
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		if q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWithCaching(t *testing.T) {
+	large := strings.Repeat(`{"hostname":"home.example.com"}`, 50)
+	tests := []struct {
+		name             string
+		method           string
+		body             string
+		status           int
+		headers          map[string]string
+		expectedStatus   int
+		expectedEncoding string
+		expectCached     bool
+	}{
+		{
+			name:             "large body is compressed",
+			method:           "GET",
+			body:             large,
+			headers:          map[string]string{"Accept-Encoding": "br, gzip;q=0.8"},
+			expectedStatus:   http.StatusOK,
+			expectedEncoding: "gzip",
+			expectCached:     true,
+		},
+		{
+			name:           "small body is not compressed",
+			method:         "GET",
+			body:           `{"status":"ok"}`,
+			headers:        map[string]string{"Accept-Encoding": "gzip"},
+			expectedStatus: http.StatusOK,
+			expectCached:   true,
+		},
+		{
+			name:           "gzip refused by client",
+			method:         "GET",
+			body:           large,
+			headers:        map[string]string{"Accept-Encoding": "gzip;q=0, identity"},
+			expectedStatus: http.StatusOK,
+			expectCached:   true,
+		},
+		{
+			name:           "matching ETag is not modified",
+			method:         "GET",
+			body:           large,
+			headers:        map[string]string{"If-None-Match": `"other", ` + bodyETag([]byte(large))},
+			expectedStatus: http.StatusNotModified,
+			expectCached:   true,
+		},
+		{
+			name:           "errors are passed through",
+			method:         "GET",
+			body:           large,
+			status:         http.StatusBadGateway,
+			headers:        map[string]string{"Accept-Encoding": "gzip"},
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "writes are passed through",
+			method:         "POST",
+			body:           large,
+			status:         http.StatusCreated,
+			headers:        map[string]string{"Accept-Encoding": "gzip"},
+			expectedStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := withCaching(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tt.status != 0 {
+					w.WriteHeader(tt.status)
+				}
+				io.WriteString(w, tt.body)
+			}, cacheRevalidate)
+
+			req := httptest.NewRequest(tt.method, "/api/status", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			handler(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			if encoding := w.Header().Get("Content-Encoding"); encoding != tt.expectedEncoding {
+				t.Errorf("Expected encoding %q, got %q", tt.expectedEncoding, encoding)
+			}
+			if cached := w.Header().Get("ETag") != ""; cached != tt.expectCached {
+				t.Errorf("Expected ETag set to be %v, got headers %v", tt.expectCached, w.Header())
+			}
+			if tt.expectCached && w.Header().Get("Cache-Control") != cacheRevalidate {
+				t.Errorf("Expected Cache-Control %s, got %s", cacheRevalidate, w.Header().Get("Cache-Control"))
+			}
+
+			body := w.Body.String()
+			if tt.expectedEncoding == "gzip" {
+				reader, err := gzip.NewReader(w.Body)
+				if err != nil {
+					t.Fatalf("Invalid gzip body: %v", err)
+				}
+				data, _ := io.ReadAll(reader)
+				body = string(data)
+			}
+			if tt.expectedStatus != http.StatusNotModified && body != tt.body {
+				t.Errorf("Expected body to be preserved, got %d bytes", len(body))
+			}
+		})
+	}
+}
+
+func TestHandleStatusETag(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.status.Record("home.example.com", "A", "203.0.113.7", recordStateOK, nil)
+	handler := withCaching(server.handleStatus, cacheRevalidate)
+
+	get := func(etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/status", nil)
+		req.SetBasicAuth("admin", "password")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	etag := get("").Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected an ETag")
+	}
+	if w := get(etag); w.Code != http.StatusNotModified {
+		t.Errorf("Expected unchanged status to be not modified, got %d", w.Code)
+	}
+
+	server.status.Record("home.example.com", "A", "203.0.113.8", recordStateOK, nil)
+	if w := get(etag); w.Code != http.StatusOK {
+		t.Errorf("Expected changed status to be served, got %d", w.Code)
+	}
+}
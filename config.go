@@ -0,0 +1,891 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds the bridge configuration read from the environment
+type Config struct {
+	APIKey   string
+	Username string
+	Password string
+	Port     string
+
+	// ListenAddrs are the addresses the server listens on, all addresses on Port if empty
+	ListenAddrs []string
+	// ListenFamily is dual, ipv4 or ipv6 and restricts the listeners to that address family
+	ListenFamily string
+
+	// SecondaryAPIKey is used once APIKey was rejected TokenFailoverAfter times in a row
+	SecondaryAPIKey    string
+	TokenFailoverAfter int
+	// ZoneTokens maps zone names or hostname globs to the API tokens used for them
+	ZoneTokens map[string]APIToken
+	// ZoneTokensFile holds further pattern=token lines overriding ZoneTokens, rewritten by migrate
+	ZoneTokensFile string
+	// CircuitBreakerAfter suspends API requests for CircuitBreakerCooldown after
+	// this many consecutive failures, doubling up to CircuitBreakerMaxCooldown, zero disables it
+	CircuitBreakerAfter       int
+	CircuitBreakerCooldown    time.Duration
+	CircuitBreakerMaxCooldown time.Duration
+
+	// MinUpdateInterval is the minimum time between writes per hostname, zero disables rate limiting
+	MinUpdateInterval time.Duration
+	IPv6InterfaceID   string
+	OwnerID           string
+
+	Verify        bool
+	VerifyTimeout time.Duration
+
+	StaleAfter      time.Duration
+	JanitorInterval time.Duration
+	JanitorDryRun   bool
+
+	// Auth configures the auth chains of the update, admin and metrics endpoints
+	Auth AuthConfig
+
+	// TLSCert and TLSKey serve HTTPS, TLSClientCA enables client certificate authentication
+	TLSCert     string
+	TLSKey      string
+	TLSClientCA string
+
+	// GRPCPort serves the gRPC API with DYNDNS_AUTH_TOKENS authentication, empty disables it
+	GRPCPort string
+
+	// Responses maps dyndns2 return codes to custom response body templates
+	Responses map[string]string
+
+	// AllowPost accepts update parameters as POST form data
+	AllowPost bool
+	// AllowPrivateIPs accepts private and reserved addresses in updates, e.g. for split-horizon setups
+	AllowPrivateIPs bool
+
+	// LogPrivacy masks IP addresses ("ip") or IP addresses and hostnames ("full") in logs
+	LogPrivacy string
+	// APILogLevel logs failed ("error") or all ("info", "debug" with bodies) API
+	// requests, successful ones sampled with APILogSampleRate
+	APILogLevel      string
+	APILogSampleRate float64
+
+	// AuthLog is the file authentication failures are written to, "-" for stderr
+	AuthLog       string
+	AuthLogFormat string
+
+	// AccessLog is the file every HTTP request is written to, "-" for stdout, in
+	// AccessLogFormat common, combined or json
+	AccessLog       string
+	AccessLogFormat string
+	// Debug serves pprof and expvar below /debug/ to administrators, DebugListen
+	// serves them without authentication on their own address
+	Debug       bool
+	DebugListen string
+
+	// LogFile is the file the application log is written to, empty for stderr
+	LogFile string
+	// Logging holds the destinations and rotation of the logs from DYNDNS_LOGGING_FILE
+	Logging LoggingConfig
+
+	// BlocklistFile and CrowdSecURL reject requests from known-bad addresses
+	BlocklistFile  string
+	CrowdSecURL    string
+	CrowdSecAPIKey string
+	// GeoIPDatabase tags update requests with their country, GeoIPCountries
+	// rejects the others and GeoIPRejectUnknown those without a country
+	GeoIPDatabase      string
+	GeoIPCountries     []string
+	GeoIPRejectUnknown bool
+
+	// RequireUserAgent rejects updates without User-Agent, BlockedUserAgents those from matching agents
+	RequireUserAgent  bool
+	BlockedUserAgents []string
+
+	// PageSize fetches records in pages, DirectLookup fetches known records by ID instead of listing zones
+	PageSize int
+	// RecordFilter asks the API for the records of a name instead of listing the zone
+	RecordFilter bool
+	// ZoneIndexInterval keeps the zones in memory for this long, zero lists them for every update
+	ZoneIndexInterval time.Duration
+	DirectLookup      bool
+	// WarmUp prefetches the zones and records of the configured hostnames at startup
+	WarmUp bool
+	// NohostTTL caches hostnames without a zone, doubling up to NohostMaxTTL for repeated misses, zero disables it
+	NohostTTL    time.Duration
+	NohostMaxTTL time.Duration
+
+	// APIURL overrides the Hetzner DNS API base URL, e.g. for a mock API in integration environments
+	APIURL string
+	// Transport tunes the connections to the API
+	Transport TransportConfig
+	// MockProvider serves MockZones from an in-memory fake of the API instead of Hetzner DNS
+	MockProvider bool
+	MockZones    []string
+	// MockState persists the records of the mock provider across restarts, empty keeps them in memory
+	MockState string
+	// MockDNSListen answers DNS queries for the mock records on this UDP address, empty disables it
+	MockDNSListen string
+
+	// UpdateTimeout bounds the whole update of a request, zero waits until it completes
+	UpdateTimeout time.Duration
+	// FastAck answers updates with good before performing them in the background
+	FastAck bool
+	// PropagationWait holds the answer to a changing update until the authoritative
+	// nameservers serve the new value, at most this long, zero answers immediately
+	PropagationWait time.Duration
+	// RetryInterval retries writes parked while the API is unavailable, zero fails them instead
+	RetryInterval time.Duration
+
+	// Strict restricts the bridge to records it owns and that show no signs of external management
+	Strict bool
+	// ConflictPolicy handles records modified by another actor: overwrite, skip or error
+	ConflictPolicy string
+
+	// ProfileUsers maps further usernames sharing the password to client profiles
+	ProfileUsers map[string]string
+	// EmptyIPModes maps client profiles to the handling of updates without addresses
+	EmptyIPModes map[string]string
+	// ConnectionIPUsers fill in a missing address of the connection's family from the connection, "*" for all
+	ConnectionIPUsers []string
+
+	// FlapThreshold is the number of value changes per hour after which a record is reported
+	// as flapping, zero disables the alert
+	FlapThreshold int
+
+	// HistoryLimit is the number of value changes kept per record for /api/history, zero disables the history
+	HistoryLimit int
+
+	// MaxSubdomainDepth limits the labels of updated hostnames below their zone, zero is
+	// unlimited, SubdomainDepths overrides it per username
+	MaxSubdomainDepth int
+	SubdomainDepths   map[string]int
+	// DeletableHosts are the hostname patterns that may be deleted through the API and CLI
+	DeletableHosts []string
+	// Tenants are served with their own credentials, zones and state, read from DYNDNS_TENANTS_FILE
+	Tenants []TenantConfig
+	// QuotaUpdates and QuotaWrites limit the updates and API writes of each credential per day, zero is unlimited
+	QuotaUpdates int
+	QuotaWrites  int
+
+	// DSLiteHosts are hostname patterns behind DS-Lite whose IPv4 address is ignored,
+	// DSLiteDeleteA additionally deletes their existing A records
+	DSLiteHosts   []string
+	DSLiteDeleteA bool
+
+	// RoundRobinHosts are hostname patterns whose A and AAAA records hold one value per source credential,
+	// RoundRobinSources are usernames sharing DYNDNS_PASSWORD to tell the sources apart
+	RoundRobinHosts   []string
+	RoundRobinSources []string
+
+	// Hostnames are reconciled against the detected public IP at startup and every ReconcileInterval
+	Hostnames         []string
+	ReconcileInterval time.Duration
+	IPv4DetectURL     string
+	IPv6DetectURL     string
+
+	// FritzBoxURL polls the FritzBox over TR-064 every FritzBoxInterval and updates
+	// Hostnames when its addresses change, instead of waiting for the router to push
+	FritzBoxURL      string
+	FritzBoxInterval time.Duration
+
+	// Manifest declares records kept in their desired state every ManifestInterval,
+	// ManifestDryRun only reports drift
+	Manifest         string
+	ManifestRecords  []ManifestRecord
+	ManifestInterval time.Duration
+	ManifestDryRun   bool
+	// Git pulls the manifest from a repository instead of reading DYNDNS_MANIFEST directly
+	Git GitSyncConfig
+
+	Store StoreConfig
+
+	// Kubernetes enables the watcher for annotated Services and Ingresses
+	Kubernetes         bool
+	KubernetesInterval time.Duration
+
+	// DockerSocket enables the watcher for labeled containers on that Docker daemon socket
+	DockerSocket   string
+	DockerInterval time.Duration
+
+	// MQTT is enabled when a broker address is set
+	MQTT MQTTConfig
+
+	// Report is enabled when at least one sink is configured
+	Report ReportConfig
+
+	// Notify is enabled when at least one notifier is configured
+	Notify NotifyConfig
+
+	// Hooks are commands run for events of the kinds in HookEvents
+	Hooks       []string
+	HookEvents  []string
+	HookTimeout time.Duration
+
+	// WireGuardPeers get their endpoint updated when the tracked hostname changes
+	WireGuardPeers []WireGuardPeer
+
+	// PortChecks are verified to be reachable on the new address after changes,
+	// through PortCheckURL if set and by dialing the address otherwise
+	PortChecks       []PortCheck
+	PortCheckURL     string
+	PortCheckDelay   time.Duration
+	PortCheckTimeout time.Duration
+
+	// BasePath is the prefix of all endpoints behind a shared reverse proxy, e.g. /dyndns
+	BasePath string
+
+	// Emulations serve the update endpoints of other providers, e.g. /update.php of ipv64
+	Emulations []*providerEmulation
+
+	// BackupIPs are switched to after BackupFailAfter failed port checks of the
+	// primary every BackupInterval, and back after BackupRecoverAfter successful ones
+	BackupIPs          []BackupIP
+	BackupInterval     time.Duration
+	BackupFailAfter    int
+	BackupRecoverAfter int
+
+	// SLAInterval is the time between the availability samples of /api/sla, zero disables them
+	SLAInterval time.Duration
+
+	// ReverseDNS updates PTR records of Hetzner servers along with their forward records
+	ReverseDNS ReverseDNSConfig
+
+	// Firewall restricts Hetzner Cloud firewall rules to the current address of a hostname
+	Firewall FirewallConfig
+
+	// DNSSEC looks up whether zones are signed and warns about records changing
+	// again within DNSSECChurnWindow, delaying those writes by DNSSECDelay
+	DNSSEC            bool
+	DNSSECResolver    string
+	DNSSECChurnWindow time.Duration
+	DNSSECDelay       time.Duration
+
+	// Annotations stores who created a record and the device and address of
+	// its last update in the ownership marker
+	Annotations bool
+
+	// LocalDNS mirrors updates to a LAN DNS server answering with internal addresses
+	LocalDNS LocalDNSConfig
+}
+
+// LoadConfig reads the configuration from the environment
+func LoadConfig() (*Config, error) {
+	return loadConfig(os.LookupEnv)
+}
+
+// loadConfig reads the configuration using lookup, which behaves like os.LookupEnv
+func loadConfig(lookup func(string) (string, bool)) (*Config, error) {
+	env := func(name, def string) string {
+		if value, ok := lookup(name); ok && value != "" {
+			return value
+		}
+		return def
+	}
+
+	cfg := &Config{
+		APIKey:          env("HETZNER_DNS_API_KEY", ""),
+		SecondaryAPIKey: env("HETZNER_DNS_API_KEY_SECONDARY", ""),
+		Username:        env("DYNDNS_USERNAME", "admin"),
+		Password:        env("DYNDNS_PASSWORD", ""),
+		Port:            env("DYNDNS_PORT", "8080"),
+		IPv6InterfaceID: env("DYNDNS_IPV6_INTERFACE_ID", ""),
+		OwnerID:         env("DYNDNS_OWNER_ID", ""),
+		Verify:          env("DYNDNS_VERIFY", "") == "true",
+		JanitorDryRun:   env("DYNDNS_JANITOR_DRY_RUN", "") == "true",
+		Kubernetes:      env("DYNDNS_KUBERNETES", "") == "true",
+		DockerSocket:    env("DYNDNS_DOCKER_SOCKET", ""),
+		Hostnames:       splitList(env("DYNDNS_HOSTNAMES", "")),
+		FritzBoxURL:     env("DYNDNS_FRITZBOX_URL", ""),
+		DeletableHosts:  splitList(env("DYNDNS_DELETABLE_HOSTS", "")),
+		DSLiteHosts:     splitList(env("DYNDNS_DSLITE_HOSTS", "")),
+		DSLiteDeleteA:   env("DYNDNS_DSLITE_DELETE_A", "") == "true",
+		LogPrivacy:      env("DYNDNS_LOG_PRIVACY", logPrivacyOff),
+		APILogLevel:     env("DYNDNS_API_LOG_LEVEL", apiLogError),
+		AllowPost:       env("DYNDNS_ALLOW_POST", "") == "true",
+		FastAck:         env("DYNDNS_FAST_ACK", "") == "true",
+		AllowPrivateIPs: env("DYNDNS_ALLOW_PRIVATE_IPS", "") == "true",
+		TLSCert:         env("DYNDNS_TLS_CERT", ""),
+		TLSKey:          env("DYNDNS_TLS_KEY", ""),
+		TLSClientCA:     env("DYNDNS_TLS_CLIENT_CA", ""),
+		GRPCPort:        env("DYNDNS_GRPC_PORT", ""),
+		ListenFamily:    env("DYNDNS_LISTEN_FAMILY", listenDual),
+		BlocklistFile:   env("DYNDNS_BLOCKLIST_FILE", ""),
+		CrowdSecURL:     env("DYNDNS_CROWDSEC_URL", ""),
+		CrowdSecAPIKey:  env("DYNDNS_CROWDSEC_API_KEY", ""),
+		IPv4DetectURL:   DefaultIPv4DetectURL,
+		IPv6DetectURL:   DefaultIPv6DetectURL,
+		Auth: AuthConfig{
+			Update:     env("DYNDNS_AUTH_UPDATE", "basic"),
+			Admin:      env("DYNDNS_AUTH_ADMIN", "basic"),
+			Metrics:    env("DYNDNS_AUTH_METRICS", "none"),
+			Tokens:     splitList(env("DYNDNS_AUTH_TOKENS", "")),
+			AllowedIPs: splitList(env("DYNDNS_AUTH_ALLOWED_IPS", "")),
+		},
+		Store: StoreConfig{
+			Type:          env("DYNDNS_STORE", "memory"),
+			Path:          env("DYNDNS_STORE_PATH", "dyndns.db"),
+			RedisAddr:     env("DYNDNS_REDIS_ADDR", "localhost:6379"),
+			RedisPassword: env("DYNDNS_REDIS_PASSWORD", ""),
+		},
+		MQTT: MQTTConfig{
+			Broker:      env("DYNDNS_MQTT_BROKER", ""),
+			ClientID:    env("DYNDNS_MQTT_CLIENT_ID", "hetzner-dyndns"),
+			Username:    env("DYNDNS_MQTT_USERNAME", ""),
+			Password:    env("DYNDNS_MQTT_PASSWORD", ""),
+			TopicPrefix: env("DYNDNS_MQTT_TOPIC_PREFIX", "dyndns"),
+			Commands:    env("DYNDNS_MQTT_COMMANDS", "") == "true",
+		},
+	}
+
+	pageSize, err := strconv.Atoi(env("DYNDNS_PAGE_SIZE", "0"))
+	if err != nil || pageSize < 0 {
+		return nil, fmt.Errorf("invalid DYNDNS_PAGE_SIZE: must be a non-negative number")
+	}
+	cfg.PageSize = pageSize
+	cfg.RecordFilter = env("DYNDNS_RECORD_FILTER", "") == "true"
+	cfg.DirectLookup = env("DYNDNS_DIRECT_LOOKUP", "") == "true"
+	cfg.WarmUp = env("DYNDNS_WARM_UP", "") == "true"
+
+	cfg.Report = ReportConfig{
+		Sinks:        splitList(env("DYNDNS_REPORT", "")),
+		WebhookURL:   env("DYNDNS_REPORT_WEBHOOK_URL", ""),
+		SMTPAddr:     env("DYNDNS_REPORT_SMTP_ADDR", ""),
+		SMTPUsername: env("DYNDNS_REPORT_SMTP_USERNAME", ""),
+		SMTPPassword: env("DYNDNS_REPORT_SMTP_PASSWORD", ""),
+		From:         env("DYNDNS_REPORT_EMAIL_FROM", ""),
+		To:           splitList(env("DYNDNS_REPORT_EMAIL_TO", "")),
+	}
+	reportAt, err := parseTimeOfDay(env("DYNDNS_REPORT_TIME", "08:00"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_REPORT_TIME: %w", err)
+	}
+	cfg.Report.At = reportAt
+
+	cfg.Notify = NotifyConfig{
+		Notifiers:  splitList(env("DYNDNS_NOTIFY", "")),
+		Templates:  map[string]string{},
+		WebhookURL: env("DYNDNS_NOTIFY_WEBHOOK_URL", ""),
+		SMTP: SMTPConfig{
+			Addr:     env("DYNDNS_NOTIFY_SMTP_ADDR", ""),
+			Username: env("DYNDNS_NOTIFY_SMTP_USERNAME", ""),
+			Password: env("DYNDNS_NOTIFY_SMTP_PASSWORD", ""),
+			From:     env("DYNDNS_NOTIFY_EMAIL_FROM", ""),
+			To:       splitList(env("DYNDNS_NOTIFY_EMAIL_TO", "")),
+		},
+		NtfyURL:        env("DYNDNS_NOTIFY_NTFY_URL", ""),
+		NtfyToken:      env("DYNDNS_NOTIFY_NTFY_TOKEN", ""),
+		TelegramToken:  env("DYNDNS_NOTIFY_TELEGRAM_TOKEN", ""),
+		TelegramChatID: env("DYNDNS_NOTIFY_TELEGRAM_CHAT_ID", ""),
+		GotifyURL:      env("DYNDNS_NOTIFY_GOTIFY_URL", ""),
+		GotifyToken:    env("DYNDNS_NOTIFY_GOTIFY_TOKEN", ""),
+		PushoverToken:  env("DYNDNS_NOTIFY_PUSHOVER_TOKEN", ""),
+		PushoverUser:   env("DYNDNS_NOTIFY_PUSHOVER_USER", ""),
+
+		TelegramCommands:     env("DYNDNS_TELEGRAM_COMMANDS", "") == "true",
+		TelegramAllowedChats: splitList(env("DYNDNS_TELEGRAM_ALLOWED_CHATS", env("DYNDNS_NOTIFY_TELEGRAM_CHAT_ID", ""))),
+	}
+	for _, kind := range eventKinds {
+		if value := env("DYNDNS_NOTIFY_TEMPLATE_"+strings.ToUpper(kind), ""); value != "" {
+			cfg.Notify.Templates[kind] = value
+		}
+	}
+	notifyRoutes, err := parseNotifyRoutes(env("DYNDNS_NOTIFY_ROUTES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_NOTIFY_ROUTES: %w", err)
+	}
+	cfg.Notify.Routes = notifyRoutes
+	notifyHosts, err := parseNotifierLists(env("DYNDNS_NOTIFY_HOSTS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_NOTIFY_HOSTS: %w", err)
+	}
+	cfg.Notify.Hosts = notifyHosts
+	cfg.Notify.Severities = map[string]string{}
+	for _, entry := range splitList(env("DYNDNS_NOTIFY_SEVERITY", "")) {
+		name, severity, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid DYNDNS_NOTIFY_SEVERITY: expected notifier=severity, got %q", entry)
+		}
+		cfg.Notify.Severities[name] = severity
+	}
+	notifyRetries, err := strconv.Atoi(env("DYNDNS_NOTIFY_RETRIES", "3"))
+	if err != nil || notifyRetries < 0 {
+		return nil, fmt.Errorf("invalid DYNDNS_NOTIFY_RETRIES: must be a non-negative number")
+	}
+	cfg.Notify.Retries = notifyRetries
+
+	cfg.Hooks = splitList(env("DYNDNS_HOOKS", ""))
+	hookEvents, err := parseHookEvents(env("DYNDNS_HOOK_EVENTS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_HOOK_EVENTS: %w", err)
+	}
+	cfg.HookEvents = hookEvents
+
+	wireGuardPeers, err := parseWireGuardPeers(env("DYNDNS_WIREGUARD_PEERS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_WIREGUARD_PEERS: %w", err)
+	}
+	cfg.WireGuardPeers = wireGuardPeers
+
+	portChecks, err := parsePortChecks(env("DYNDNS_PORT_CHECKS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_PORT_CHECKS: %w", err)
+	}
+	cfg.PortChecks = portChecks
+	cfg.PortCheckURL = env("DYNDNS_PORT_CHECK_URL", "")
+
+	rdnsTargets, err := parseReverseDNSTargets(env("DYNDNS_RDNS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_RDNS: %w", err)
+	}
+	cfg.ReverseDNS = ReverseDNSConfig{
+		Targets:       rdnsTargets,
+		CloudToken:    env("DYNDNS_HCLOUD_TOKEN", ""),
+		RobotUser:     env("DYNDNS_ROBOT_USER", ""),
+		RobotPassword: env("DYNDNS_ROBOT_PASSWORD", ""),
+	}
+
+	firewallTargets, err := parseFirewallTargets(env("DYNDNS_FIREWALL_RULES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_FIREWALL_RULES: %w", err)
+	}
+	if cfg.ListenAddrs, err = parseListenAddrs(env("DYNDNS_LISTEN", ""), cfg.Port); err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_LISTEN: %w", err)
+	}
+	cfg.DNSSEC = env("DYNDNS_DNSSEC", "") == "true"
+	cfg.Annotations = env("DYNDNS_ANNOTATIONS", "") == "true"
+	cfg.DNSSECResolver = env("DYNDNS_DNSSEC_RESOLVER", "")
+	cfg.Firewall = FirewallConfig{Targets: firewallTargets, CloudToken: cfg.ReverseDNS.CloudToken}
+
+	localHosts, err := parseLocalHosts(env("DYNDNS_LOCAL_HOSTS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_LOCAL_HOSTS: %w", err)
+	}
+	localTTL, err := strconv.Atoi(env("DYNDNS_RFC2136_TTL", "60"))
+	if err != nil || localTTL < 0 {
+		return nil, fmt.Errorf("invalid DYNDNS_RFC2136_TTL: must be a non-negative number")
+	}
+	localRoutes, err := parseLocalDNSRoutes(env("DYNDNS_LOCAL_ROUTES", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_LOCAL_ROUTES: %w", err)
+	}
+	cfg.LocalDNS = LocalDNSConfig{
+		Hosts:           localHosts,
+		Routes:          localRoutes,
+		RFC2136Server:   env("DYNDNS_RFC2136_SERVER", ""),
+		RFC2136Zone:     env("DYNDNS_RFC2136_ZONE", ""),
+		RFC2136TSIG:     env("DYNDNS_RFC2136_TSIG", ""),
+		RFC2136TTL:      localTTL,
+		PiholeURL:       env("DYNDNS_PIHOLE_URL", ""),
+		PiholePassword:  env("DYNDNS_PIHOLE_PASSWORD", ""),
+		AdGuardURL:      env("DYNDNS_ADGUARD_URL", ""),
+		AdGuardUser:     env("DYNDNS_ADGUARD_USER", ""),
+		AdGuardPassword: env("DYNDNS_ADGUARD_PASSWORD", ""),
+	}
+
+	cfg.APIURL = env("HETZNER_DNS_API_URL", BaseURL)
+	cfg.MockProvider = env("DYNDNS_MOCK_PROVIDER", "") == "true"
+	cfg.MockZones = splitList(env("DYNDNS_MOCK_ZONES", ""))
+	cfg.MockState = env("DYNDNS_MOCK_STATE", "")
+	cfg.MockDNSListen = env("DYNDNS_MOCK_DNS_LISTEN", "")
+	if cfg.MockProvider {
+		// The fake backend accepts any token
+		cfg.APIURL = mockProviderURL
+		if cfg.APIKey == "" {
+			cfg.APIKey = "mock-token"
+		}
+		cfg.MockZones = mockZones(cfg.MockZones, cfg.Hostnames)
+	}
+	cfg.Transport = TransportConfig{
+		Proxy:             env("DYNDNS_HTTP_PROXY", ""),
+		DNSServer:         env("DYNDNS_DNS_SERVER", ""),
+		DisableKeepAlives: env("DYNDNS_DISABLE_KEEPALIVES", "") == "true",
+		DisableHTTP2:      env("DYNDNS_DISABLE_HTTP2", "") == "true",
+		TLSMinVersion:     env("DYNDNS_TLS_MIN_VERSION", ""),
+		CABundle:          env("DYNDNS_CA_BUNDLE", ""),
+	}
+	for name, target := range map[string]*int{
+		"DYNDNS_MAX_IDLE_CONNS":      &cfg.Transport.MaxIdleConns,
+		"DYNDNS_MAX_CONNS_PER_HOST":  &cfg.Transport.MaxConnsPerHost,
+		"DYNDNS_QUOTA_UPDATES":       &cfg.QuotaUpdates,
+		"DYNDNS_QUOTA_WRITES":        &cfg.QuotaWrites,
+		"DYNDNS_MAX_SUBDOMAIN_DEPTH": &cfg.MaxSubdomainDepth,
+	} {
+		value, err := strconv.Atoi(env(name, "0"))
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid %s: must be a non-negative number", name)
+		}
+		*target = value
+	}
+
+	cfg.Strict = env("DYNDNS_STRICT", "") == "true"
+	cfg.ConflictPolicy = env("DYNDNS_CONFLICT_POLICY", conflictOverwrite)
+
+	cfg.RequireUserAgent = env("DYNDNS_REQUIRE_USER_AGENT", "") == "true"
+	cfg.BlockedUserAgents = splitList(env("DYNDNS_BLOCKED_USER_AGENTS", ""))
+
+	profileUsers, err := parseProfileUsers(env("DYNDNS_PROFILE_USERS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_PROFILE_USERS: %w", err)
+	}
+	cfg.ProfileUsers = profileUsers
+
+	emptyIPModes, err := parseEmptyIPModes(env("DYNDNS_EMPTY_IP", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_EMPTY_IP: %w", err)
+	}
+	cfg.EmptyIPModes = emptyIPModes
+	cfg.ConnectionIPUsers = splitList(env("DYNDNS_CONNECTION_IP_USERS", ""))
+
+	subdomainDepths, err := parseSubdomainDepths(env("DYNDNS_SUBDOMAIN_DEPTHS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_SUBDOMAIN_DEPTHS: %w", err)
+	}
+	cfg.SubdomainDepths = subdomainDepths
+
+	cfg.Manifest = env("DYNDNS_MANIFEST", "")
+	cfg.ManifestDryRun = env("DYNDNS_MANIFEST_DRY_RUN", "") == "true"
+	cfg.Git = GitSyncConfig{
+		Repo:          env("DYNDNS_GIT_REPO", ""),
+		Branch:        env("DYNDNS_GIT_BRANCH", "main"),
+		Path:          env("DYNDNS_GIT_PATH", "records.yaml"),
+		Dir:           env("DYNDNS_GIT_DIR", filepath.Join(os.TempDir(), "dyndns-manifest")),
+		WebhookSecret: env("DYNDNS_GIT_WEBHOOK_SECRET", ""),
+	}
+	// The manifest of a repository is validated after every pull instead
+	if cfg.Git.Repo != "" {
+		if cfg.Manifest == "" {
+			cfg.Manifest = cfg.Git.ManifestPath()
+		}
+	} else if cfg.Manifest != "" {
+		records, err := loadManifest(cfg.Manifest)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DYNDNS_MANIFEST: %w", err)
+		}
+		cfg.ManifestRecords = records
+	}
+
+	// The environment takes precedence over the logging file
+	if path := env("DYNDNS_LOGGING_FILE", ""); path != "" {
+		logging, err := loadLoggingFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DYNDNS_LOGGING_FILE: %w", err)
+		}
+		cfg.Logging = logging
+	}
+	cfg.Debug = env("DYNDNS_DEBUG", "") == "true"
+	cfg.DebugListen = env("DYNDNS_DEBUG_LISTEN", "")
+	cfg.LogFile = env("DYNDNS_LOG_FILE", cfg.Logging.Application.Path)
+	cfg.AccessLog = env("DYNDNS_ACCESS_LOG", cfg.Logging.Access.Path)
+	cfg.AccessLogFormat = env("DYNDNS_ACCESS_LOG_FORMAT", cmp.Or(cfg.Logging.Access.Format, accessLogCommon))
+	cfg.AuthLog = env("DYNDNS_AUTH_LOG", cfg.Logging.Audit.Path)
+	cfg.AuthLogFormat = env("DYNDNS_AUTH_LOG_FORMAT", cmp.Or(cfg.Logging.Audit.Format, DefaultAuthLogFormat))
+
+	if path := env("DYNDNS_TENANTS_FILE", ""); path != "" {
+		tenants, err := loadTenants(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DYNDNS_TENANTS_FILE: %w", err)
+		}
+		cfg.Tenants = tenants
+	}
+
+	zoneTokens, err := parseZoneTokens(env("DYNDNS_ZONE_TOKENS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_ZONE_TOKENS: %w", err)
+	}
+	cfg.ZoneTokens = zoneTokens
+	cfg.ZoneTokensFile = env("DYNDNS_ZONE_TOKENS_FILE", "")
+	if cfg.ZoneTokensFile != "" {
+		fileTokens, err := readZoneTokensFile(cfg.ZoneTokensFile)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DYNDNS_ZONE_TOKENS_FILE: %w", err)
+		}
+		for pattern, token := range fileTokens {
+			cfg.ZoneTokens[pattern] = token
+		}
+	}
+
+	cfg.Responses = map[string]string{}
+	for _, code := range responseCodes {
+		if value := env("DYNDNS_RESPONSE_"+strings.ToUpper(code), ""); value != "" {
+			cfg.Responses[code] = value
+		}
+	}
+
+	failoverAfter, err := strconv.Atoi(env("DYNDNS_TOKEN_FAILOVER_AFTER", "3"))
+	if err != nil || failoverAfter < 1 {
+		return nil, fmt.Errorf("invalid DYNDNS_TOKEN_FAILOVER_AFTER: must be a positive number")
+	}
+	cfg.TokenFailoverAfter = failoverAfter
+
+	sampleRate, err := strconv.ParseFloat(env("DYNDNS_API_LOG_SAMPLE_RATE", "1"), 64)
+	if err != nil || sampleRate < 0 || sampleRate > 1 {
+		return nil, fmt.Errorf("invalid DYNDNS_API_LOG_SAMPLE_RATE: must be a number between 0 and 1")
+	}
+	cfg.APILogSampleRate = sampleRate
+
+	flapThreshold, err := strconv.Atoi(env("DYNDNS_FLAP_THRESHOLD", strconv.Itoa(defaultFlapThreshold)))
+	if err != nil || flapThreshold < 0 {
+		return nil, fmt.Errorf("invalid DYNDNS_FLAP_THRESHOLD: must be a non-negative number")
+	}
+	cfg.FlapThreshold = flapThreshold
+
+	if cfg.BasePath, err = normalizeBasePath(env("DYNDNS_BASE_PATH", "")); err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_BASE_PATH: %w", err)
+	}
+
+	cfg.RoundRobinHosts = splitList(env("DYNDNS_ROUND_ROBIN", ""))
+	cfg.RoundRobinSources = splitList(env("DYNDNS_ROUND_ROBIN_SOURCES", ""))
+
+	if cfg.Emulations, err = parseEmulations(env("DYNDNS_EMULATE", "")); err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_EMULATE: %w", err)
+	}
+
+	if cfg.BackupIPs, err = parseBackupIPs(env("DYNDNS_BACKUP_IPS", "")); err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_BACKUP_IPS: %w", err)
+	}
+	backupFailAfter, err := strconv.Atoi(env("DYNDNS_BACKUP_FAIL_AFTER", strconv.Itoa(defaultBackupFailAfter)))
+	if err != nil || backupFailAfter < 1 {
+		return nil, fmt.Errorf("invalid DYNDNS_BACKUP_FAIL_AFTER: must be a positive number")
+	}
+	cfg.BackupFailAfter = backupFailAfter
+	backupRecoverAfter, err := strconv.Atoi(env("DYNDNS_BACKUP_RECOVER_AFTER", strconv.Itoa(defaultBackupRecoverAfter)))
+	if err != nil || backupRecoverAfter < 1 {
+		return nil, fmt.Errorf("invalid DYNDNS_BACKUP_RECOVER_AFTER: must be a positive number")
+	}
+	cfg.BackupRecoverAfter = backupRecoverAfter
+
+	cfg.GeoIPDatabase = env("DYNDNS_GEOIP_DATABASE", "")
+	if cfg.GeoIPCountries, err = parseCountries(env("DYNDNS_GEOIP_COUNTRIES", "")); err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_GEOIP_COUNTRIES: %w", err)
+	}
+	cfg.GeoIPRejectUnknown = env("DYNDNS_GEOIP_REJECT_UNKNOWN", "") == "true"
+
+	historyLimit, err := strconv.Atoi(env("DYNDNS_HISTORY_LIMIT", strconv.Itoa(defaultHistoryLimit)))
+	if err != nil || historyLimit < 0 {
+		return nil, fmt.Errorf("invalid DYNDNS_HISTORY_LIMIT: must be a non-negative number")
+	}
+	cfg.HistoryLimit = historyLimit
+
+	breakerAfter, err := strconv.Atoi(env("DYNDNS_CIRCUIT_BREAKER_AFTER", "0"))
+	if err != nil || breakerAfter < 0 {
+		return nil, fmt.Errorf("invalid DYNDNS_CIRCUIT_BREAKER_AFTER: must be a non-negative number")
+	}
+	cfg.CircuitBreakerAfter = breakerAfter
+
+	redisDB, err := strconv.Atoi(env("DYNDNS_REDIS_DB", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DYNDNS_REDIS_DB: %w", err)
+	}
+	cfg.Store.RedisDB = redisDB
+
+	// An explicitly empty detection URL disables that address family
+	if value, ok := lookup("DYNDNS_IPV4_DETECT_URL"); ok {
+		cfg.IPv4DetectURL = value
+	}
+	if value, ok := lookup("DYNDNS_IPV6_DETECT_URL"); ok {
+		cfg.IPv6DetectURL = value
+	}
+
+	durations := []struct {
+		name   string
+		def    string
+		target *time.Duration
+	}{
+		{"DYNDNS_MIN_UPDATE_INTERVAL", "60s", &cfg.MinUpdateInterval},
+		{"DYNDNS_VERIFY_TIMEOUT", "2m", &cfg.VerifyTimeout},
+		{"DYNDNS_STALE_AFTER", "0", &cfg.StaleAfter},
+		{"DYNDNS_JANITOR_INTERVAL", "1h", &cfg.JanitorInterval},
+		{"DYNDNS_RECONCILE_INTERVAL", "15m", &cfg.ReconcileInterval},
+		{"DYNDNS_FRITZBOX_INTERVAL", "1m", &cfg.FritzBoxInterval},
+		{"DYNDNS_MANIFEST_INTERVAL", "5m", &cfg.ManifestInterval},
+		{"DYNDNS_GIT_INTERVAL", "5m", &cfg.Git.Interval},
+		{"DYNDNS_GIT_TIMEOUT", "1m", &cfg.Git.Timeout},
+		{"DYNDNS_KUBERNETES_INTERVAL", "30s", &cfg.KubernetesInterval},
+		{"DYNDNS_DOCKER_INTERVAL", "30s", &cfg.DockerInterval},
+		{"DYNDNS_RETRY_INTERVAL", "30s", &cfg.RetryInterval},
+		{"DYNDNS_UPDATE_TIMEOUT", "20s", &cfg.UpdateTimeout},
+		{"DYNDNS_PROPAGATION_WAIT", "0", &cfg.PropagationWait},
+		{"DYNDNS_PORT_CHECK_DELAY", "30s", &cfg.PortCheckDelay},
+		{"DYNDNS_PORT_CHECK_TIMEOUT", "10s", &cfg.PortCheckTimeout},
+		{"DYNDNS_SLA_INTERVAL", "0", &cfg.SLAInterval},
+		{"DYNDNS_BACKUP_INTERVAL", "1m", &cfg.BackupInterval},
+		{"DYNDNS_NOHOST_TTL", "1m", &cfg.NohostTTL},
+		{"DYNDNS_NOHOST_MAX_TTL", "1h", &cfg.NohostMaxTTL},
+		{"DYNDNS_ZONE_INDEX_INTERVAL", "0", &cfg.ZoneIndexInterval},
+		{"DYNDNS_IDLE_CONN_TIMEOUT", "90s", &cfg.Transport.IdleConnTimeout},
+		{"DYNDNS_HOOK_TIMEOUT", "30s", &cfg.HookTimeout},
+		{"DYNDNS_DNSSEC_CHURN_WINDOW", "10m", &cfg.DNSSECChurnWindow},
+		{"DYNDNS_DNSSEC_DELAY", "0", &cfg.DNSSECDelay},
+		{"DYNDNS_CIRCUIT_BREAKER_COOLDOWN", "30s", &cfg.CircuitBreakerCooldown},
+		{"DYNDNS_CIRCUIT_BREAKER_MAX_COOLDOWN", "10m", &cfg.CircuitBreakerMaxCooldown},
+	}
+	for _, d := range durations {
+		value, err := time.ParseDuration(env(d.name, d.def))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", d.name, err)
+		}
+		*d.target = value
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks that required settings are present and consistent
+func (c *Config) Validate() error {
+	if c.APIKey == "" && len(c.ZoneTokens) == 0 {
+		return fmt.Errorf("HETZNER_DNS_API_KEY or DYNDNS_ZONE_TOKENS environment variable is required")
+	}
+	if c.Password == "" {
+		return fmt.Errorf("DYNDNS_PASSWORD environment variable is required")
+	}
+	switch c.LogPrivacy {
+	case logPrivacyOff, logPrivacyIP, logPrivacyFull:
+	default:
+		return fmt.Errorf("invalid DYNDNS_LOG_PRIVACY: %s (expected off, ip or full)", c.LogPrivacy)
+	}
+	switch c.AccessLogFormat {
+	case accessLogCommon, accessLogCombined, accessLogJSON:
+	default:
+		return fmt.Errorf("invalid DYNDNS_ACCESS_LOG_FORMAT: %s (expected common, combined or json)", c.AccessLogFormat)
+	}
+	if err := checkListenFamily(c.ListenAddrs, c.ListenFamily); err != nil {
+		return err
+	}
+	if !slices.Contains(apiLogLevels, c.APILogLevel) {
+		return fmt.Errorf("invalid DYNDNS_API_LOG_LEVEL: %s (expected %s)", c.APILogLevel, strings.Join(apiLogLevels, ", "))
+	}
+	if c.Strict && c.OwnerID == "" {
+		return fmt.Errorf("DYNDNS_STRICT requires DYNDNS_OWNER_ID")
+	}
+	if c.FritzBoxURL != "" && len(c.Hostnames) == 0 {
+		return fmt.Errorf("DYNDNS_FRITZBOX_URL requires DYNDNS_HOSTNAMES, the hostnames to update with the polled addresses")
+	}
+	if c.FritzBoxURL != "" && c.FritzBoxInterval <= 0 {
+		return fmt.Errorf("DYNDNS_FRITZBOX_INTERVAL must be positive")
+	}
+	if c.Annotations && c.OwnerID == "" {
+		return fmt.Errorf("DYNDNS_ANNOTATIONS requires DYNDNS_OWNER_ID, the annotations are stored in the ownership markers")
+	}
+	if _, ok := tlsVersions[c.Transport.TLSMinVersion]; c.Transport.TLSMinVersion != "" && !ok {
+		return fmt.Errorf("invalid DYNDNS_TLS_MIN_VERSION: %s (expected 1.2 or 1.3)", c.Transport.TLSMinVersion)
+	}
+	if _, err := c.Report.NewSinks(); err != nil {
+		return fmt.Errorf("invalid DYNDNS_REPORT: %w", err)
+	}
+	if _, err := c.Notify.NewNotifications(); err != nil {
+		return fmt.Errorf("invalid DYNDNS_NOTIFY: %w", err)
+	}
+	if err := c.ReverseDNS.Validate(); err != nil {
+		return fmt.Errorf("invalid DYNDNS_RDNS: %w", err)
+	}
+	if c.FastAck && c.PropagationWait > 0 {
+		return fmt.Errorf("DYNDNS_FAST_ACK answers before the update is performed, DYNDNS_PROPAGATION_WAIT cannot wait for it")
+	}
+	if c.PropagationWait > 0 && c.UpdateTimeout > 0 && c.PropagationWait >= c.UpdateTimeout {
+		return fmt.Errorf("DYNDNS_PROPAGATION_WAIT must be shorter than DYNDNS_UPDATE_TIMEOUT, otherwise the update is answered before propagation")
+	}
+	if len(c.Firewall.Targets) > 0 && c.Firewall.CloudToken == "" {
+		return fmt.Errorf("DYNDNS_FIREWALL_RULES requires DYNDNS_HCLOUD_TOKEN")
+	}
+	if err := c.LocalDNS.Validate(); err != nil {
+		return err
+	}
+	for _, hook := range c.Hooks {
+		if _, err := exec.LookPath(strings.Fields(hook)[0]); err != nil {
+			return fmt.Errorf("invalid DYNDNS_HOOKS: %w", err)
+		}
+	}
+	if c.Notify.TelegramCommands {
+		if c.Notify.TelegramToken == "" || len(c.Notify.TelegramAllowedChats) == 0 {
+			return fmt.Errorf("DYNDNS_TELEGRAM_COMMANDS requires DYNDNS_NOTIFY_TELEGRAM_TOKEN and DYNDNS_TELEGRAM_ALLOWED_CHATS")
+		}
+		if _, err := parseChatIDs(c.Notify.TelegramAllowedChats); err != nil {
+			return fmt.Errorf("invalid DYNDNS_TELEGRAM_ALLOWED_CHATS: %w", err)
+		}
+	}
+	if !isValidConflictPolicy(c.ConflictPolicy) {
+		return fmt.Errorf("invalid DYNDNS_CONFLICT_POLICY: %s (expected overwrite, skip or error)", c.ConflictPolicy)
+	}
+	switch c.Store.Type {
+	case "memory", "bolt", "sqlite", "redis":
+	default:
+		return fmt.Errorf("invalid DYNDNS_STORE: %s (expected memory, bolt, sqlite or redis)", c.Store.Type)
+	}
+	if c.PortCheckURL != "" && !strings.Contains(c.PortCheckURL, "{ip}") {
+		return fmt.Errorf("DYNDNS_PORT_CHECK_URL must contain {ip}, e.g. https://checker.example.com/?ip={ip}&port={port}")
+	}
+	for _, tenant := range c.Tenants {
+		if _, ok := c.ProfileUsers[tenant.Username]; ok || tenant.Username == c.Username || slices.Contains(c.RoundRobinSources, tenant.Username) {
+			return fmt.Errorf("tenant %s: username %s is already used by the bridge", tenant.Name, tenant.Username)
+		}
+	}
+	if c.Git.Repo != "" && c.Manifest != c.Git.ManifestPath() {
+		return fmt.Errorf("DYNDNS_GIT_REPO and DYNDNS_MANIFEST cannot be used together, set DYNDNS_GIT_PATH instead")
+	}
+	if c.Git.WebhookSecret != "" && c.Git.Repo == "" {
+		return fmt.Errorf("DYNDNS_GIT_WEBHOOK_SECRET requires DYNDNS_GIT_REPO")
+	}
+	if c.DSLiteDeleteA && len(c.DSLiteHosts) == 0 {
+		return fmt.Errorf("DYNDNS_DSLITE_DELETE_A requires DYNDNS_DSLITE_HOSTS")
+	}
+	if err := checkBackupIPs(c.BackupIPs, c.PortChecks); err != nil {
+		return err
+	}
+	if len(c.BackupIPs) > 0 && c.BackupInterval <= 0 {
+		return fmt.Errorf("DYNDNS_BACKUP_INTERVAL must be positive")
+	}
+	if len(c.RoundRobinSources) > 0 && len(c.RoundRobinHosts) == 0 {
+		return fmt.Errorf("DYNDNS_ROUND_ROBIN_SOURCES requires DYNDNS_ROUND_ROBIN")
+	}
+	if c.StaleAfter > 0 && c.OwnerID == "" {
+		return fmt.Errorf("DYNDNS_STALE_AFTER requires DYNDNS_OWNER_ID to be set")
+	}
+	if _, err := ParseResponseTemplates(c.Responses); err != nil {
+		return err
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return fmt.Errorf("DYNDNS_TLS_CERT and DYNDNS_TLS_KEY must be set together")
+	}
+	if c.TLSClientCA != "" && c.TLSCert == "" {
+		return fmt.Errorf("DYNDNS_TLS_CLIENT_CA requires DYNDNS_TLS_CERT and DYNDNS_TLS_KEY to be set")
+	}
+	if c.GRPCPort != "" && len(c.Auth.Tokens) == 0 {
+		return fmt.Errorf("DYNDNS_GRPC_PORT requires DYNDNS_AUTH_TOKENS, gRPC calls authenticate with bearer tokens")
+	}
+	if (len(c.GeoIPCountries) > 0 || c.GeoIPRejectUnknown) && c.GeoIPDatabase == "" {
+		return fmt.Errorf("DYNDNS_GEOIP_COUNTRIES and DYNDNS_GEOIP_REJECT_UNKNOWN require DYNDNS_GEOIP_DATABASE")
+	}
+	if c.CrowdSecURL != "" && c.CrowdSecAPIKey == "" {
+		return fmt.Errorf("DYNDNS_CROWDSEC_URL requires DYNDNS_CROWDSEC_API_KEY to be set")
+	}
+	if c.DockerSocket != "" && c.OwnerID == "" {
+		return fmt.Errorf("DYNDNS_DOCKER_SOCKET requires DYNDNS_OWNER_ID to be set")
+	}
+	if c.WarmUp && !c.DirectLookup && c.ZoneIndexInterval <= 0 {
+		return fmt.Errorf("DYNDNS_WARM_UP requires DYNDNS_DIRECT_LOOKUP or DYNDNS_ZONE_INDEX_INTERVAL to keep what it fetches")
+	}
+	if !c.MockProvider && (c.MockState != "" || c.MockDNSListen != "" || len(c.MockZones) > 0) {
+		return fmt.Errorf("DYNDNS_MOCK_ZONES, DYNDNS_MOCK_STATE and DYNDNS_MOCK_DNS_LISTEN require DYNDNS_MOCK_PROVIDER=true")
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,386 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mapLookup returns a lookup function backed by env, behaving like os.LookupEnv
+func mapLookup(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	cfg, err := loadConfig(mapLookup(map[string]string{
+		"HETZNER_DNS_API_KEY": "token",
+		"DYNDNS_PASSWORD":     "secret",
+	}))
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	if cfg.Username != "admin" || cfg.Port != "8080" {
+		t.Errorf("Unexpected defaults: username=%s, port=%s", cfg.Username, cfg.Port)
+	}
+	if cfg.MinUpdateInterval != 60*time.Second {
+		t.Errorf("Expected default minimum interval 60s, got %v", cfg.MinUpdateInterval)
+	}
+	if cfg.ReconcileInterval != 15*time.Minute {
+		t.Errorf("Expected default reconcile interval 15m, got %v", cfg.ReconcileInterval)
+	}
+	if cfg.IPv4DetectURL != DefaultIPv4DetectURL || cfg.IPv6DetectURL != DefaultIPv6DetectURL {
+		t.Errorf("Unexpected detection URLs: %s, %s", cfg.IPv4DetectURL, cfg.IPv6DetectURL)
+	}
+	if len(cfg.Hostnames) != 0 {
+		t.Errorf("Expected no hostnames, got %v", cfg.Hostnames)
+	}
+}
+
+func TestLoadConfigValues(t *testing.T) {
+	cfg, err := loadConfig(mapLookup(map[string]string{
+		"HETZNER_DNS_API_KEY":        "token",
+		"DYNDNS_PASSWORD":            "secret",
+		"DYNDNS_MIN_UPDATE_INTERVAL": "0",
+		"DYNDNS_HOSTNAMES":           "home.example.com, nas.example.com,,",
+		"DYNDNS_IPV6_DETECT_URL":     "",
+		"DYNDNS_OWNER_ID":            "bridge1",
+		"DYNDNS_STALE_AFTER":         "720h",
+	}))
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	if cfg.MinUpdateInterval != 0 {
+		t.Errorf("Expected rate limiting to be disabled, got %v", cfg.MinUpdateInterval)
+	}
+	if strings.Join(cfg.Hostnames, ";") != "home.example.com;nas.example.com" {
+		t.Errorf("Unexpected hostnames: %v", cfg.Hostnames)
+	}
+	if cfg.IPv6DetectURL != "" {
+		t.Errorf("Expected IPv6 detection to be disabled, got %s", cfg.IPv6DetectURL)
+	}
+	if cfg.StaleAfter != 720*time.Hour {
+		t.Errorf("Expected stale after 720h, got %v", cfg.StaleAfter)
+	}
+}
+
+func TestLoadConfigZoneTokens(t *testing.T) {
+	cfg, err := loadConfig(mapLookup(map[string]string{
+		"DYNDNS_PASSWORD":    "secret",
+		"DYNDNS_ZONE_TOKENS": "example.com=token_a, *.lab.example.org = token_b|token_b2",
+	}))
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	if cfg.APIKey != "" {
+		t.Errorf("Expected no default API key, got %s", cfg.APIKey)
+	}
+	if cfg.ZoneTokens["example.com"].Primary != "token_a" || cfg.ZoneTokens["*.lab.example.org"].Primary != "token_b" {
+		t.Errorf("Unexpected zone tokens: %v", cfg.ZoneTokens)
+	}
+	if cfg.ZoneTokens["*.lab.example.org"].Secondary != "token_b2" {
+		t.Errorf("Expected secondary token, got %+v", cfg.ZoneTokens["*.lab.example.org"])
+	}
+	if cfg.TokenFailoverAfter != 3 {
+		t.Errorf("Expected default failover threshold 3, got %d", cfg.TokenFailoverAfter)
+	}
+}
+
+func TestLoadConfigLoggingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logging.yaml")
+	os.WriteFile(path, []byte("application:\n  path: /var/log/dyndns.log\naccess:\n  path: /var/log/access.log\n  format: json\naudit:\n  path: /var/log/audit.log\n"), 0o600)
+	cfg, err := loadConfig(mapLookup(map[string]string{
+		"HETZNER_DNS_API_KEY": "token",
+		"DYNDNS_PASSWORD":     "secret",
+		"DYNDNS_LOGGING_FILE": path,
+		"DYNDNS_AUTH_LOG":     "-",
+	}))
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	if cfg.LogFile != "/var/log/dyndns.log" || cfg.AccessLog != "/var/log/access.log" || cfg.AccessLogFormat != accessLogJSON {
+		t.Errorf("Expected the destinations of the logging file, got %q %q %q", cfg.LogFile, cfg.AccessLog, cfg.AccessLogFormat)
+	}
+	if cfg.AuthLog != "-" || cfg.AuthLogFormat != DefaultAuthLogFormat {
+		t.Errorf("Expected DYNDNS_AUTH_LOG to take precedence, got %q %q", cfg.AuthLog, cfg.AuthLogFormat)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		env           map[string]string
+		errorContains string
+	}{
+		{
+			name:          "missing API key",
+			env:           map[string]string{"DYNDNS_PASSWORD": "secret"},
+			errorContains: "HETZNER_DNS_API_KEY",
+		},
+		{
+			name:          "missing password",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token"},
+			errorContains: "DYNDNS_PASSWORD",
+		},
+		{
+			name:          "invalid duration",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_VERIFY_TIMEOUT": "soon"},
+			errorContains: "DYNDNS_VERIFY_TIMEOUT",
+		},
+		{
+			name:          "invalid zone token mapping",
+			env:           map[string]string{"DYNDNS_PASSWORD": "secret", "DYNDNS_ZONE_TOKENS": "example.com"},
+			errorContains: "DYNDNS_ZONE_TOKENS",
+		},
+		{
+			name:          "invalid log privacy mode",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_LOG_PRIVACY": "strict"},
+			errorContains: "DYNDNS_LOG_PRIVACY",
+		},
+		{
+			name:          "invalid conflict policy",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_CONFLICT_POLICY": "merge"},
+			errorContains: "DYNDNS_CONFLICT_POLICY",
+		},
+		{
+			name:          "telegram commands without token",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_TELEGRAM_COMMANDS": "true", "DYNDNS_TELEGRAM_ALLOWED_CHATS": "42"},
+			errorContains: "DYNDNS_TELEGRAM_COMMANDS",
+		},
+		{
+			name:          "invalid telegram chat",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_TELEGRAM_COMMANDS": "true", "DYNDNS_NOTIFY_TELEGRAM_TOKEN": "123:abc", "DYNDNS_TELEGRAM_ALLOWED_CHATS": "@me"},
+			errorContains: "DYNDNS_TELEGRAM_ALLOWED_CHATS",
+		},
+		{
+			name:          "firewall rules without cloud token",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_FIREWALL_RULES": "home.example.com=4711:home"},
+			errorContains: "DYNDNS_HCLOUD_TOKEN",
+		},
+		{
+			name:          "local hosts without RFC 2136 server",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_LOCAL_HOSTS": "nas.example.com=192.168.178.10"},
+			errorContains: "DYNDNS_RFC2136_SERVER",
+		},
+		{
+			name:          "invalid TSIG key",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_LOCAL_HOSTS": "nas.example.com", "DYNDNS_RFC2136_SERVER": "192.168.178.1", "DYNDNS_RFC2136_ZONE": "example.com", "DYNDNS_RFC2136_TSIG": "dyndns"},
+			errorContains: "DYNDNS_RFC2136_TSIG",
+		},
+		{
+			name:          "several local DNS servers without routes",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_LOCAL_HOSTS": "nas.example.com", "DYNDNS_PIHOLE_URL": "http://pi.hole", "DYNDNS_ADGUARD_URL": "http://192.168.178.2"},
+			errorContains: "DYNDNS_LOCAL_ROUTES",
+		},
+		{
+			name:          "local route to unconfigured server",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_LOCAL_HOSTS": "nas.example.com", "DYNDNS_PIHOLE_URL": "http://pi.hole", "DYNDNS_LOCAL_ROUTES": "example.com=adguard"},
+			errorContains: "adguard",
+		},
+		{
+			name:          "propagation wait longer than update timeout",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_PROPAGATION_WAIT": "30s"},
+			errorContains: "DYNDNS_PROPAGATION_WAIT",
+		},
+		{
+			name:          "invalid report time",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_REPORT": "log", "DYNDNS_REPORT_TIME": "8am"},
+			errorContains: "DYNDNS_REPORT_TIME",
+		},
+		{
+			name:          "invalid TLS minimum version",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_TLS_MIN_VERSION": "1.0"},
+			errorContains: "DYNDNS_TLS_MIN_VERSION",
+		},
+		{
+			name:          "invalid connection pool size",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_MAX_IDLE_CONNS": "many"},
+			errorContains: "DYNDNS_MAX_IDLE_CONNS",
+		},
+		{
+			name:          "strict mode without owner",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_STRICT": "true"},
+			errorContains: "DYNDNS_OWNER_ID",
+		},
+		{
+			name:          "TLS key without certificate",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_TLS_KEY": "key.pem"},
+			errorContains: "DYNDNS_TLS_CERT",
+		},
+		{
+			name:          "gRPC without auth tokens",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_GRPC_PORT": "9090"},
+			errorContains: "DYNDNS_AUTH_TOKENS",
+		},
+		{
+			name:          "listen address of the wrong family",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_LISTEN": "0.0.0.0", "DYNDNS_LISTEN_FAMILY": "ipv6"},
+			errorContains: "DYNDNS_LISTEN_FAMILY",
+		},
+		{
+			name:          "invalid listen address",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_LISTEN": "router.local"},
+			errorContains: "DYNDNS_LISTEN",
+		},
+		{
+			name:          "DS-Lite deletion without hosts",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_DSLITE_DELETE_A": "true"},
+			errorContains: "DYNDNS_DSLITE_HOSTS",
+		},
+		{
+			name:          "backup address without port check",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_BACKUP_IPS": "home.example.com=198.51.100.9", "DYNDNS_PORT_CHECKS": "nas.example.com:443"},
+			errorContains: "DYNDNS_PORT_CHECKS entry for home.example.com",
+		},
+		{
+			name:          "invalid backup address",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_BACKUP_IPS": "home.example.com=backup"},
+			errorContains: "DYNDNS_BACKUP_IPS",
+		},
+		{
+			name:          "invalid GeoIP country",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_GEOIP_DATABASE": "/var/lib/GeoIP/GeoLite2-Country.mmdb", "DYNDNS_GEOIP_COUNTRIES": "Germany"},
+			errorContains: "DYNDNS_GEOIP_COUNTRIES",
+		},
+		{
+			name:          "GeoIP countries without database",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_GEOIP_COUNTRIES": "DE"},
+			errorContains: "DYNDNS_GEOIP_DATABASE",
+		},
+		{
+			name:          "round-robin sources without hostnames",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_ROUND_ROBIN_SOURCES": "wan1,wan2"},
+			errorContains: "DYNDNS_ROUND_ROBIN",
+		},
+		{
+			name:          "invalid port check",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_PORT_CHECKS": "home.example.com"},
+			errorContains: "DYNDNS_PORT_CHECKS",
+		},
+		{
+			name:          "port checker URL without placeholder",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_PORT_CHECK_URL": "https://checker.example.com/"},
+			errorContains: "{ip}",
+		},
+		{
+			name:          "missing tenants file",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_TENANTS_FILE": "/nonexistent/tenants.yaml"},
+			errorContains: "DYNDNS_TENANTS_FILE",
+		},
+		{
+			name:          "negative quota",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_QUOTA_UPDATES": "-1"},
+			errorContains: "DYNDNS_QUOTA_UPDATES",
+		},
+		{
+			name:          "missing manifest",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_MANIFEST": "/nonexistent/records.yaml"},
+			errorContains: "DYNDNS_MANIFEST",
+		},
+		{
+			name:          "git repository and manifest",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_GIT_REPO": "https://git.example.com/dns.git", "DYNDNS_MANIFEST": "/etc/dyndns/records.yaml"},
+			errorContains: "DYNDNS_GIT_PATH",
+		},
+		{
+			name:          "fritzbox polling without hostnames",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_FRITZBOX_URL": "http://fritz.box:49000"},
+			errorContains: "DYNDNS_FRITZBOX_URL requires DYNDNS_HOSTNAMES",
+		},
+		{
+			name:          "zero fritzbox interval",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_FRITZBOX_URL": "http://fritz.box:49000", "DYNDNS_HOSTNAMES": "home.example.com", "DYNDNS_FRITZBOX_INTERVAL": "0"},
+			errorContains: "DYNDNS_FRITZBOX_INTERVAL",
+		},
+		{
+			name:          "fast ack with propagation wait",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_FAST_ACK": "true", "DYNDNS_PROPAGATION_WAIT": "10s"},
+			errorContains: "DYNDNS_FAST_ACK",
+		},
+		{
+			name:          "invalid subdomain depths",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_SUBDOMAIN_DEPTHS": "nas"},
+			errorContains: "DYNDNS_SUBDOMAIN_DEPTHS",
+		},
+		{
+			name:          "negative flap threshold",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_FLAP_THRESHOLD": "-1"},
+			errorContains: "DYNDNS_FLAP_THRESHOLD",
+		},
+		{
+			name:          "unreadable zone tokens file",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_ZONE_TOKENS_FILE": "/"},
+			errorContains: "DYNDNS_ZONE_TOKENS_FILE",
+		},
+		{
+			name:          "mock state without mock provider",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_MOCK_STATE": "/tmp/mock.json"},
+			errorContains: "DYNDNS_MOCK_PROVIDER",
+		},
+		{
+			name:          "warm-up without a cache",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_WARM_UP": "true"},
+			errorContains: "DYNDNS_WARM_UP",
+		},
+		{
+			name:          "invalid empty IP mode",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_EMPTY_IP": "fritzbox=detect"},
+			errorContains: "DYNDNS_EMPTY_IP",
+		},
+		{
+			name:          "invalid access log format",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_ACCESS_LOG_FORMAT": "apache"},
+			errorContains: "DYNDNS_ACCESS_LOG_FORMAT",
+		},
+		{
+			name:          "unreadable logging file",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_LOGGING_FILE": "/"},
+			errorContains: "DYNDNS_LOGGING_FILE",
+		},
+		{
+			name:          "negative history limit",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_HISTORY_LIMIT": "-1"},
+			errorContains: "DYNDNS_HISTORY_LIMIT",
+		},
+		{
+			name:          "invalid base path",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_BASE_PATH": "/dyndns?x"},
+			errorContains: "DYNDNS_BASE_PATH",
+		},
+		{
+			name:          "unknown emulated provider",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_EMULATE": "ipv64,noip"},
+			errorContains: "DYNDNS_EMULATE",
+		},
+		{
+			name:          "invalid response template",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_RESPONSE_GOOD": "{{.Address}}"},
+			errorContains: "good response template",
+		},
+		{
+			name:          "janitor without owner",
+			env:           map[string]string{"HETZNER_DNS_API_KEY": "token", "DYNDNS_PASSWORD": "secret", "DYNDNS_STALE_AFTER": "1h"},
+			errorContains: "DYNDNS_OWNER_ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := loadConfig(mapLookup(tt.env))
+			if err == nil {
+				t.Fatal("Expected error but got none")
+			}
+			if !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("Expected error to contain '%s', got '%s'", tt.errorContains, err.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+)
+
+// modifiedBucket holds the modification timestamps of the records last written by the bridge
+const modifiedBucket = "modified"
+
+// Conflict policies for records changed by another actor since the bridge last wrote them
+const (
+	conflictOverwrite = "overwrite"
+	conflictSkip      = "skip"
+	conflictError     = "error"
+)
+
+// errRecordConflict is returned for conflicting records under the error policy
+var errRecordConflict = errors.New("record was modified by another actor")
+
+// isValidConflictPolicy reports whether policy is a known conflict policy
+func isValidConflictPolicy(policy string) bool {
+	switch policy {
+	case conflictOverwrite, conflictSkip, conflictError:
+		return true
+	}
+	return false
+}
+
+// checkConflict detects whether existing was changed by someone else, either
+// since the bridge last wrote it or while the update was being prepared, and
+// applies the conflict policy. It reports whether the write should go ahead.
+func (s *DynDNSServer) checkConflict(client *Client, hostname, recordType string, existing *DNSRecord) (bool, error) {
+	if s.conflictPolicy == "" || s.conflictPolicy == conflictOverwrite {
+		return true, nil
+	}
+
+	conflict := false
+	if known, ok, err := s.store.Get(modifiedBucket, hostname+"/"+recordType); err == nil && ok {
+		conflict = string(known) != "" && existing.Modified != "" && string(known) != existing.Modified
+	}
+	if !conflict {
+		// Re-fetch right before writing to catch changes made since the zone was listed
+		current, err := client.GetRecord(existing.ID)
+		if err != nil {
+			return false, fmt.Errorf("failed to re-fetch record: %w", err)
+		}
+		conflict = current.Modified != existing.Modified || current.Value != existing.Value
+	}
+	if !conflict {
+		return true, nil
+	}
+
+	if s.conflictPolicy == conflictSkip {
+		log.Printf("Conflict: %s (%s) was modified by another actor, skipping update", hostname, recordType)
+		return false, nil
+	}
+	return false, fmt.Errorf("%s (%s): %w", hostname, recordType, errRecordConflict)
+}
+
+// rememberModified records the modification timestamp of a record written by the bridge
+func (s *DynDNSServer) rememberModified(hostname, recordType string, record *DNSRecord) {
+	if record == nil || record.Modified == "" {
+		return
+	}
+	if err := s.store.Put(modifiedBucket, hostname+"/"+recordType, []byte(record.Modified)); err != nil {
+		log.Printf("Failed to remember modification of %s (%s): %v", hostname, recordType, err)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestUpdateDNSRecordConflict(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        string
+		known         string
+		current       string
+		expectError   bool
+		expectedWrite bool
+	}{
+		{"unchanged record is written", conflictSkip, "t1", "t1", false, true},
+		{"external change is overwritten", conflictOverwrite, "t0", "t1", false, true},
+		{"external change is skipped", conflictSkip, "t0", "t1", false, false},
+		{"external change is an error", conflictError, "t0", "t1", true, false},
+		{"change while updating is skipped", conflictSkip, "t1", "t2", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := newTestFake(t, DNSRecord{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1", Modified: "t1"})
+			// The record changes between listing and fetching it before the write
+			mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "GET" && r.URL.Path == "/records/rec1" {
+					fake.mu.Lock()
+					fake.state.Records[0].Modified = tt.current
+					fake.mu.Unlock()
+				}
+				fake.ServeHTTP(w, r)
+			}))
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.conflictPolicy = tt.policy
+			server.store.Put(modifiedBucket, "home.example.com/A", []byte(tt.known))
+
+			err := server.updateDNSRecord("home.example.com", "1.2.3.4", "A")
+			if tt.expectError != errors.Is(err, errRecordConflict) {
+				t.Errorf("Expected conflict error %v, got %v", tt.expectError, err)
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if written := slices.Contains(fake.Requests(), "PUT /records/rec1"); written != tt.expectedWrite {
+				t.Errorf("Expected write %v, got %v", tt.expectedWrite, written)
+			}
+			if tt.expectedWrite {
+				stored := fake.Records()[0].Modified
+				if modified, _, _ := server.store.Get(modifiedBucket, "home.example.com/A"); string(modified) != stored {
+					t.Errorf("Expected modification %q of the write to be remembered, got %q", stored, modified)
+				}
+			}
+		})
+	}
+}
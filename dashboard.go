@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// dashboardPanel describes a Grafana panel and the PromQL queries it plots
+type dashboardPanel struct {
+	Title   string
+	Unit    string
+	Queries []dashboardQuery
+}
+
+// dashboardQuery is a single PromQL expression and its legend
+type dashboardQuery struct {
+	Expr   string
+	Legend string
+}
+
+// dashboardPanels are the panels of the exported dashboard. Every metric used
+// here must be described by the code recording it, see TestDashboardMetrics.
+var dashboardPanels = []dashboardPanel{
+	{
+		Title: "Update requests",
+		Unit:  "reqps",
+		Queries: []dashboardQuery{
+			{`sum by (result) (rate(dyndns_update_requests_total[5m]))`, "{{result}}"},
+		},
+	},
+	{
+		Title: "Record updates",
+		Unit:  "ops",
+		Queries: []dashboardQuery{
+			{`sum by (type, result) (rate(dyndns_record_updates_total[5m]))`, "{{type}} {{result}}"},
+		},
+	},
+	{
+		Title: "Update error ratio",
+		Unit:  "percentunit",
+		Queries: []dashboardQuery{
+			{`sum(rate(dyndns_record_updates_total{result="error"}[5m])) / sum(rate(dyndns_record_updates_total[5m]))`, "errors"},
+		},
+	},
+	{
+		Title: "API requests",
+		Unit:  "reqps",
+		Queries: []dashboardQuery{
+			{`sum by (method, code) (rate(dyndns_api_requests_total[5m]))`, "{{method}} {{code}}"},
+		},
+	},
+	{
+		Title: "API latency",
+		Unit:  "s",
+		Queries: []dashboardQuery{
+			{`rate(dyndns_api_request_duration_seconds_sum[5m]) / rate(dyndns_api_request_duration_seconds_count[5m])`, "average"},
+		},
+	},
+	{
+		Title: "API error ratio",
+		Unit:  "percentunit",
+		Queries: []dashboardQuery{
+			{`sum(rate(dyndns_api_requests_total{code=~"5..|error"}[5m])) / sum(rate(dyndns_api_requests_total[5m]))`, "errors"},
+		},
+	},
+	{
+		Title: "Propagation latency",
+		Unit:  "s",
+		Queries: []dashboardQuery{
+			{`rate(dyndns_propagation_seconds_sum[1h]) / rate(dyndns_propagation_seconds_count[1h])`, "average"},
+			{`sum by (result) (increase(dyndns_verification_total[1h]))`, "{{result}}"},
+		},
+	},
+	{
+		Title: "Events",
+		Unit:  "short",
+		Queries: []dashboardQuery{
+			{`sum by (kind) (increase(dyndns_events_total[1h]))`, "{{kind}}"},
+		},
+	},
+	{
+		Title: "Token failovers",
+		Unit:  "short",
+		Queries: []dashboardQuery{
+			{`sum by (token) (increase(dyndns_token_failovers_total[1h]))`, "{{token}}"},
+		},
+	},
+}
+
+// dashboard builds the Grafana dashboard model, two panels per row
+func dashboard() map[string]interface{} {
+	panels := make([]map[string]interface{}, 0, len(dashboardPanels))
+	for i, panel := range dashboardPanels {
+		targets := make([]map[string]interface{}, 0, len(panel.Queries))
+		for j, query := range panel.Queries {
+			targets = append(targets, map[string]interface{}{
+				"expr":         query.Expr,
+				"legendFormat": query.Legend,
+				"refId":        string(rune('A' + j)),
+			})
+		}
+
+		panels = append(panels, map[string]interface{}{
+			"id":         i + 1,
+			"type":       "timeseries",
+			"title":      panel.Title,
+			"datasource": map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos":    map[string]int{"x": (i % 2) * 12, "y": (i / 2) * 8, "w": 12, "h": 8},
+			"fieldConfig": map[string]interface{}{
+				"defaults":  map[string]string{"unit": panel.Unit},
+				"overrides": []interface{}{},
+			},
+			"targets": targets,
+		})
+	}
+
+	return map[string]interface{}{
+		"title":         "Hetzner DynDNS",
+		"uid":           "hetzner-dyndns",
+		"tags":          []string{"dyndns", "hetzner"},
+		"schemaVersion": 39,
+		"version":       1,
+		"refresh":       "1m",
+		"time":          map[string]string{"from": "now-24h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []map[string]interface{}{{
+				"name":  "datasource",
+				"label": "Data source",
+				"type":  "datasource",
+				"query": "prometheus",
+			}},
+		},
+		"panels": panels,
+	}
+}
+
+// runDashboardCommand handles "dashboard export", writing the dashboard JSON to out
+func runDashboardCommand(args []string, out io.Writer) error {
+	if len(args) != 2 || args[0] != "dashboard" || args[1] != "export" {
+		return fmt.Errorf("unknown command: %s\n%s", strings.Join(args, " "), cliUsage)
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(dashboard())
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// TestDashboardMetrics keeps the dashboard in sync with the metrics the code exposes
+func TestDashboardMetrics(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	newMeteredTransport(nil, server.metrics)
+	NewVerifier(time.Minute, time.Second, server.metrics)
+	server.enableTokenFailover(server.client, "default", "secondary", 3)
+
+	metricName := regexp.MustCompile(`dyndns_[a-z_]+`)
+	for _, panel := range dashboardPanels {
+		for _, query := range panel.Queries {
+			for _, name := range metricName.FindAllString(query.Expr, -1) {
+				if _, ok := server.metrics.families[name]; !ok {
+					t.Errorf("Panel %q uses undescribed metric %s", panel.Title, name)
+				}
+			}
+		}
+	}
+}
+
+func TestRunDashboardCommand(t *testing.T) {
+	var out bytes.Buffer
+	if err := runDashboardCommand([]string{"dashboard", "export"}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var model struct {
+		Title  string `json:"title"`
+		Panels []struct {
+			Title   string `json:"title"`
+			Targets []struct {
+				Expr  string `json:"expr"`
+				RefID string `json:"refId"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &model); err != nil {
+		t.Fatalf("Invalid dashboard JSON: %v", err)
+	}
+	if model.Title != "Hetzner DynDNS" || len(model.Panels) != len(dashboardPanels) {
+		t.Errorf("Unexpected dashboard: %+v", model)
+	}
+	if target := model.Panels[0].Targets[0]; target.RefID != "A" || target.Expr != dashboardPanels[0].Queries[0].Expr {
+		t.Errorf("Unexpected first target: %+v", target)
+	}
+
+	if err := runDashboardCommand([]string{"dashboard", "import"}, &out); err == nil {
+		t.Error("Expected error for unknown dashboard command")
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// errUpdateTimeout is returned for an update still running after updateTimeout
+var errUpdateTimeout = errors.New("update did not finish in time")
+
+// withDeadline runs update, giving up waiting for it after updateTimeout.
+// The client is answered before it gives up, with errUpdateTimeout since the
+// outcome is not known yet, and the update finishes in the background with
+// its outcome recorded in the status API.
+func (s *DynDNSServer) withDeadline(hostname string, update func() (bool, error)) (bool, error) {
+	update = s.timeProcessing(update)
+	if s.updateTimeout <= 0 {
+		return update()
+	}
+
+	type result struct {
+		unchanged bool
+		err       error
+	}
+	done := make(chan result, 1)
+	go func() {
+		unchanged, err := s.recoverUpdate(hostname, update)()
+		done <- result{unchanged, err}
+	}()
+
+	timer := time.NewTimer(s.updateTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-done:
+		return r.unchanged, r.err
+	case <-timer.C:
+		log.Printf("Update of %s exceeded %s, finishing in the background", hostname, s.updateTimeout)
+		return false, errUpdateTimeout
+	}
+}
+
+// acknowledge runs the update of an update request. With fastAck the client is
+// answered as accepted right away and the update runs in the background, for
+// router firmwares that mark the provider as failed after a slow answer.
+func (s *DynDNSServer) acknowledge(hostname string, update func() (bool, error)) (bool, error) {
+	if !s.fastAck {
+		return s.withDeadline(hostname, update)
+	}
+	s.metrics.Inc("dyndns_update_fast_acks_total", nil)
+	go s.recoverUpdate(hostname, s.timeProcessing(update))()
+	return false, nil
+}
+
+// recoverUpdate wraps an update running in its own goroutine, where a panic
+// would not reach recoverPanics and crash the bridge, to return it as error
+func (s *DynDNSServer) recoverUpdate(hostname string, update func() (bool, error)) func() (bool, error) {
+	return func() (unchanged bool, err error) {
+		defer func() {
+			if cause := recover(); cause != nil {
+				log.Printf("Panic updating %s: %v\n%s", hostname, cause, debug.Stack())
+				s.metrics.Inc("dyndns_update_panics_total", nil)
+				unchanged, err = false, fmt.Errorf("update of %s panicked: %v", hostname, cause)
+			}
+		}()
+		return update()
+	}
+}
+
+// timeProcessing wraps update to measure the time spent on the update itself
+func (s *DynDNSServer) timeProcessing(update func() (bool, error)) func() (bool, error) {
+	return func() (bool, error) {
+		start := time.Now()
+		defer func() {
+			s.metrics.Add("dyndns_update_processing_seconds_sum", nil, time.Since(start).Seconds())
+			s.metrics.Inc("dyndns_update_processing_seconds_count", nil)
+		}()
+		return update()
+	}
+}
+
+// timeResponses measures how long clients wait for the answer to their update requests
+func (s *DynDNSServer) timeResponses(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next(w, r)
+		s.metrics.Add("dyndns_update_response_seconds_sum", nil, time.Since(start).Seconds())
+		s.metrics.Inc("dyndns_update_response_seconds_count", nil)
+	}
+}
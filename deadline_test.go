@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithDeadline(t *testing.T) {
+	server := NewDynDNSServer(nil, "admin", "password", "8080")
+	server.updateTimeout = 50 * time.Millisecond
+
+	unchanged, err := server.withDeadline("home.example.com", func() (bool, error) { return true, nil })
+	if !unchanged || err != nil {
+		t.Errorf("Expected fast update result to be returned, got %v %v", unchanged, err)
+	}
+
+	failure := errors.New("write failed")
+	if _, err := server.withDeadline("home.example.com", func() (bool, error) { return false, failure }); err != failure {
+		t.Errorf("Expected fast update error to be returned, got %v", err)
+	}
+
+	finished := make(chan struct{})
+	start := time.Now()
+	unchanged, err = server.withDeadline("home.example.com", func() (bool, error) {
+		defer close(finished)
+		time.Sleep(200 * time.Millisecond)
+		return true, failure
+	})
+	if unchanged || !errors.Is(err, errUpdateTimeout) {
+		t.Errorf("Expected late update to time out, got %v %v", unchanged, err)
+	}
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("Expected answer after the deadline, took %v", elapsed)
+	}
+	<-finished
+}
+
+func TestBackgroundUpdatePanic(t *testing.T) {
+	tests := []struct {
+		name    string
+		fastAck bool
+	}{
+		{"deadline", false},
+		{"fast ack", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewDynDNSServer(nil, "admin", "password", "8080")
+			server.updateTimeout = 50 * time.Millisecond
+			server.fastAck = tt.fastAck
+
+			_, err := server.acknowledge("home.example.com", func() (bool, error) { panic("nil zone") })
+			if !tt.fastAck && (err == nil || !strings.Contains(err.Error(), "nil zone")) {
+				t.Errorf("Expected the panic as error, got %v", err)
+			}
+			deadline := time.Now().Add(2 * time.Second)
+			for server.metrics.Value("dyndns_update_panics_total", nil) != 1 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if value := server.metrics.Value("dyndns_update_panics_total", nil); value != 1 {
+				t.Errorf("Expected 1 recovered panic, got %g", value)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateDeadline(t *testing.T) {
+	var writes []string
+	api := newOwnershipMockAPI(t, nil, &writes)
+	defer api.Close()
+	written := make(chan struct{}, 1)
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		api.Config.Handler.ServeHTTP(w, r)
+		if r.Method == "POST" {
+			written <- struct{}{}
+		}
+	}))
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.updateTimeout = 20 * time.Millisecond
+
+	req := httptest.NewRequest("GET", "/update?hostname=home.example.com&myip=1.2.3.4", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	server.handleUpdate(w, req)
+
+	if w.Body.String() != "911" {
+		t.Errorf("Expected 911 response after the deadline, got %q", w.Body.String())
+	}
+	select {
+	case <-written:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the update to finish in the background")
+	}
+}
+
+func TestHandleUpdateFastAck(t *testing.T) {
+	var writes []string
+	api := newOwnershipMockAPI(t, nil, &writes)
+	defer api.Close()
+	written := make(chan struct{}, 1)
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		api.Config.Handler.ServeHTTP(w, r)
+		if r.Method == "POST" {
+			written <- struct{}{}
+		}
+	}))
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.fastAck = true
+
+	req := httptest.NewRequest("GET", "/update?hostname=home.example.com&myip=1.2.3.4", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	start := time.Now()
+	server.timeResponses(server.handleUpdate)(w, req)
+
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Errorf("Expected an answer before the API was called, took %v", elapsed)
+	}
+	if w.Body.String() != "good IPv4: 1.2.3.4" {
+		t.Errorf("Expected good response, got %q", w.Body.String())
+	}
+	if value := server.metrics.Value("dyndns_update_fast_acks_total", nil); value != 1 {
+		t.Errorf("Expected 1 fast ack, got %g", value)
+	}
+	if value := server.metrics.Value("dyndns_update_response_seconds_count", nil); value != 1 {
+		t.Errorf("Expected 1 timed response, got %g", value)
+	}
+
+	select {
+	case <-written:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the update to run in the background")
+	}
+	// The processing time is recorded once the background update returns
+	deadline := time.Now().Add(2 * time.Second)
+	for server.metrics.Value("dyndns_update_processing_seconds_count", nil) != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if sum := server.metrics.Value("dyndns_update_processing_seconds_sum", nil); sum < 0.05 {
+		t.Errorf("Expected the processing time to include the API calls, got %gs", sum)
+	}
+	if sum := server.metrics.Value("dyndns_update_response_seconds_sum", nil); sum >= 0.05 {
+		t.Errorf("Expected the response time to exclude the API calls, got %gs", sum)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"time"
+)
+
+// debugState is the internal state of the bridge published as the dyndns variable of /debug/vars
+type debugState struct {
+	Goroutines  int              `json:"goroutines"`
+	Hostnames   int              `json:"hostnames"`
+	LookupCache int              `json:"lookup_cache_entries"`
+	NohostCache int              `json:"nohost_cache_entries"`
+	RetryQueue  int              `json:"retry_queue_length"`
+	ZoneIndexes []debugZoneIndex `json:"zone_indexes,omitempty"`
+	Maintenance bool             `json:"maintenance"`
+}
+
+// debugZoneIndex describes the zone index of one API client
+type debugZoneIndex struct {
+	Zones     int       `json:"zones"`
+	Refreshed time.Time `json:"refreshed"`
+}
+
+// debugState collects the sizes of the caches and queues
+func (s *DynDNSServer) debugState() debugState {
+	state := debugState{
+		Goroutines:  runtime.NumGoroutine(),
+		Hostnames:   len(s.hostnames),
+		Maintenance: s.maintenance.Active(),
+	}
+	if s.lookups != nil {
+		state.LookupCache = s.lookups.Len()
+	}
+	if s.nohost != nil {
+		state.NohostCache = s.nohost.Len()
+	}
+	if s.retries != nil {
+		state.RetryQueue = s.retries.Len()
+	}
+	for _, client := range s.clients() {
+		if client == nil || client.zones == nil {
+			continue
+		}
+		zones, refreshed := client.zones.Stats()
+		state.ZoneIndexes = append(state.ZoneIndexes, debugZoneIndex{Zones: zones, Refreshed: refreshed})
+	}
+	return state
+}
+
+// handleDebugVars serves the expvar variables, e.g. memstats and cmdline,
+// together with the internal state of the bridge
+func (s *DynDNSServer) handleDebugVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	state, _ := json.Marshal(s.debugState())
+	fmt.Fprintf(w, "{\n%q: %s", "dyndns", state)
+	expvar.Do(func(kv expvar.KeyValue) {
+		fmt.Fprintf(w, ",\n%q: %s", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "\n}\n")
+}
+
+// debugHandler serves the pprof profiles below /debug/pprof/ and the
+// variables at /debug/vars
+func (s *DynDNSServer) debugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/vars", s.handleDebugVars)
+	return mux
+}
+
+// serveDebug serves the debug endpoints without authentication on their own
+// listener, which should only be reachable by administrators
+func (s *DynDNSServer) serveDebug(addr string) error {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if ip := net.ParseIP(host); host == "" || (ip != nil && !ip.IsLoopback()) {
+			log.Printf("WARNING: debug endpoints on %s are reachable from other hosts without authentication", addr)
+		}
+	}
+	log.Printf("Serving /debug/pprof and /debug/vars on %s", addr)
+	server := &http.Server{Addr: addr, Handler: s.debugHandler(), ReadHeaderTimeout: 10 * time.Second}
+	return server.ListenAndServe()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDebugHandler(t *testing.T) {
+	_, client := newFakeAPIServer(t)
+	client.EnableZoneIndex(time.Hour)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.hostnames = []string{"home.example.com"}
+	server.lookups = newLookupCache()
+	server.nohost = newNohostCache(time.Minute, time.Hour)
+	server.nohost.Miss("nas.example.org")
+	if _, _, err := client.zoneFor("home.example.com"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	server.debugHandler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/vars", nil))
+	var vars struct {
+		DynDNS   debugState     `json:"dyndns"`
+		MemStats map[string]any `json:"memstats"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &vars); err != nil {
+		t.Fatalf("Expected JSON, got %q: %v", w.Body.String(), err)
+	}
+	state := vars.DynDNS
+	if state.Hostnames != 1 || state.NohostCache != 1 || state.Goroutines == 0 || len(state.ZoneIndexes) != 1 || state.ZoneIndexes[0].Zones != 1 {
+		t.Errorf("Unexpected state %+v", state)
+	}
+	if vars.MemStats["HeapAlloc"] == nil {
+		t.Errorf("Expected the memstats variable, got %v", vars.MemStats)
+	}
+
+	w = httptest.NewRecorder()
+	server.debugHandler().ServeHTTP(w, httptest.NewRequest("GET", "/debug/pprof/", nil))
+	if w.Code != 200 || !strings.Contains(w.Body.String(), "goroutine") {
+		t.Errorf("Expected the pprof index, got %d %q", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// apiHealth tracks whether the Hetzner DNS API is currently reachable
+type apiHealth struct {
+	mu               sync.Mutex
+	unavailableSince time.Time
+	now              func() time.Time
+}
+
+// Observe updates the health from the result of an API operation. Errors
+// unrelated to the API being down, e.g. unknown zones, leave it unchanged.
+func (h *apiHealth) Observe(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case err == nil:
+		h.unavailableSince = time.Time{}
+	case isAPIUnavailable(err) && h.unavailableSince.IsZero():
+		h.unavailableSince = h.now().UTC()
+	}
+}
+
+// UnavailableSince returns when the API became unreachable, the zero time while it is available
+func (h *apiHealth) UnavailableSince() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.unavailableSince
+}
+
+// parkedWrite is an update held back from the API, e.g. while it was down.
+// Source names the round-robin member written, empty for the whole record.
+// Failover marks the switches of the backup monitor, which it does not hold back.
+type parkedWrite struct {
+	Hostname string
+	Type     string
+	Value    string
+	Source   string
+	Failover bool
+	// System is the background component that submitted the write, empty for client requests
+	System string
+	// TTL is written with the value, zero keeps the TTL of an existing record
+	TTL int
+}
+
+// key identifies the record, or the member of a round-robin record, written
+func (w parkedWrite) key() string {
+	if w.Source != "" {
+		return w.Hostname + "/" + w.Type + "/" + w.Source
+	}
+	return w.Hostname + "/" + w.Type
+}
+
+// RetryQueue parks updates that failed because the API was unavailable and
+// retries them periodically. Only the latest value per record is kept.
+type RetryQueue struct {
+	server *DynDNSServer
+	mu     sync.Mutex
+	writes map[string]parkedWrite
+}
+
+// NewRetryQueue creates an empty retry queue writing through server
+func NewRetryQueue(server *DynDNSServer) *RetryQueue {
+	return &RetryQueue{server: server, writes: make(map[string]parkedWrite)}
+}
+
+// Park queues write, replacing a previously parked value of the same record
+func (q *RetryQueue) Park(write parkedWrite) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.writes[write.key()] = write
+}
+
+// Len returns the number of parked writes
+func (q *RetryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.writes)
+}
+
+// Run retries the parked writes every interval until stop is closed
+func (q *RetryQueue) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			q.Flush()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Flush attempts all parked writes. Writes failing because the API is still
+// down stay parked, writes failing for other reasons are dropped.
+func (q *RetryQueue) Flush() {
+	// The parked writes would only be queued again until maintenance ends
+	if q.server.maintenance.Active() {
+		return
+	}
+	q.mu.Lock()
+	writes := make([]parkedWrite, 0, len(q.writes))
+	for _, write := range q.writes {
+		writes = append(writes, write)
+	}
+	q.mu.Unlock()
+
+	for _, write := range writes {
+		err := q.server.writeRecord(write)
+		q.server.health.Observe(err)
+		if isAPIUnavailable(err) {
+			// Everything else will fail the same way, try again next time
+			return
+		}
+
+		q.mu.Lock()
+		if q.writes[write.key()] == write {
+			delete(q.writes, write.key())
+		}
+		q.mu.Unlock()
+
+		if err != nil {
+			log.Printf("Dropping parked %s update of %s: %v", write.Type, write.Hostname, err)
+			q.server.status.Record(write.Hostname, write.Type, write.Value, recordStateError, err)
+			continue
+		}
+		log.Printf("Applied parked update of %s %s record to %s", write.Hostname, write.Type, write.Value)
+		q.server.status.Record(write.Hostname, write.Type, write.Value, recordStateOK, nil)
+	}
+}
+
+// parkWrite parks a write that failed because the API was unavailable. It
+// reports false if parking is disabled or err has another cause.
+func (s *DynDNSServer) parkWrite(write parkedWrite, err error) bool {
+	if s.retries == nil || !isAPIUnavailable(err) {
+		return false
+	}
+	log.Printf("Hetzner DNS API unavailable, parking %s update of %s to %s: %v", write.Type, write.Hostname, write.Value, err)
+	s.retries.Park(write)
+	return true
+}
+
+// cachedListing is the last successful record listing of a zone
+type cachedListing struct {
+	response recordsResponse
+	at       time.Time
+}
+
+// listingCache keeps the last record listing per zone to answer reads while the API is down
+type listingCache struct {
+	mu       sync.Mutex
+	listings map[string]cachedListing
+	now      func() time.Time
+}
+
+// Put remembers the listing of zone
+func (c *listingCache) Put(zone string, response *recordsResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.listings == nil {
+		c.listings = make(map[string]cachedListing)
+	}
+	c.listings[zone] = cachedListing{response: *response, at: c.now().UTC()}
+}
+
+// Get returns a copy of the cached listing of zone marked as stale
+func (c *listingCache) Get(zone string) (*recordsResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	listing, ok := c.listings[zone]
+	if !ok {
+		return nil, false
+	}
+	response := listing.response
+	response.Stale = true
+	response.CachedAt = &listing.at
+	return &response, true
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// newFlakyMockAPI wraps api, failing every request with 503 while down is set
+func newFlakyMockAPI(down *atomic.Bool, api http.Handler) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, `{"error":{"message":"service unavailable","code":503}}`, http.StatusServiceUnavailable)
+			return
+		}
+		api.ServeHTTP(w, r)
+	}))
+}
+
+func TestIsAPIUnavailable(t *testing.T) {
+	var down atomic.Bool
+	down.Store(true)
+	mockAPI := newFlakyMockAPI(&down, http.NotFoundHandler())
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	if _, err := client.GetZones(); !isAPIUnavailable(err) {
+		t.Errorf("Expected 503 to mark the API unavailable, got %v", err)
+	}
+
+	down.Store(false)
+	if _, err := client.GetZones(); err == nil || isAPIUnavailable(err) {
+		t.Errorf("Expected 404 not to mark the API unavailable, got %v", err)
+	}
+
+	client.BaseURL = "http://127.0.0.1:1"
+	if _, err := client.GetZones(); !isAPIUnavailable(err) {
+		t.Errorf("Expected connection failure to mark the API unavailable, got %v", err)
+	}
+	if isAPIUnavailable(errors.New("other")) {
+		t.Error("Expected plain errors not to mark the API unavailable")
+	}
+}
+
+func TestHandleUpdateParksWritesWhileAPIDown(t *testing.T) {
+	var down atomic.Bool
+	var writes []string
+	api := newOwnershipMockAPI(t, nil, &writes)
+	defer api.Close()
+	mockAPI := newFlakyMockAPI(&down, api.Config.Handler)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.retries = NewRetryQueue(server)
+
+	down.Store(true)
+	req := httptest.NewRequest("GET", "/update?hostname=home.example.com&myip=1.2.3.4", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	server.handleUpdate(w, req)
+
+	if w.Body.String() != "good IPv4: 1.2.3.4" {
+		t.Errorf("Expected good response while the API is down, got %q", w.Body.String())
+	}
+	if server.retries.Len() != 1 {
+		t.Fatalf("Expected one parked write, got %d", server.retries.Len())
+	}
+
+	req = httptest.NewRequest("GET", "/api/status", nil)
+	req.SetBasicAuth("admin", "password")
+	w = httptest.NewRecorder()
+	server.handleStatus(w, req)
+	var status statusResponse
+	json.NewDecoder(w.Body).Decode(&status)
+	if status.Status != "degraded" || status.APIUnavailableSince == nil || status.QueueDepth != 1 {
+		t.Errorf("Expected degraded status with one queued write, got %+v", status)
+	}
+
+	down.Store(false)
+	server.retries.Flush()
+	if server.retries.Len() != 0 || len(writes) == 0 || writes[0] != "POST A home" {
+		t.Errorf("Expected parked write to be applied, got %d parked and writes %v", server.retries.Len(), writes)
+	}
+	if !server.health.UnavailableSince().IsZero() {
+		t.Error("Expected API to be healthy again")
+	}
+}
+
+func TestHandleListRecordsServesCacheWhileAPIDown(t *testing.T) {
+	var down atomic.Bool
+	var writes []string
+	api := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"}}, &writes)
+	defer api.Close()
+	mockAPI := newFlakyMockAPI(&down, api.Config.Handler)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	list := func() (int, recordsResponse) {
+		req := httptest.NewRequest("GET", "/api/records?zone=example.com", nil)
+		req.SetBasicAuth("admin", "password")
+		w := httptest.NewRecorder()
+		server.handleRecords(w, req)
+		var response recordsResponse
+		json.NewDecoder(w.Body).Decode(&response)
+		return w.Code, response
+	}
+
+	if code, response := list(); code != http.StatusOK || response.Stale {
+		t.Fatalf("Expected fresh listing, got %d %+v", code, response)
+	}
+
+	down.Store(true)
+	code, response := list()
+	if code != http.StatusOK || !response.Stale || response.CachedAt == nil || len(response.Records) != 1 {
+		t.Errorf("Expected stale cached listing, got %d %+v", code, response)
+	}
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dnssecCacheTTL is how long a zone's DNSSEC state is reused before it is
+// looked up again, failed lookups are retried after dnssecErrorTTL
+const (
+	dnssecCacheTTL = 6 * time.Hour
+	dnssecErrorTTL = 5 * time.Minute
+)
+
+// dnssecLookupTimeout bounds a single DS lookup
+const dnssecLookupTimeout = 3 * time.Second
+
+// dsLookupFunc reports whether the parent zone publishes DS records for zone
+type dsLookupFunc func(ctx context.Context, resolver, zone string) (bool, error)
+
+// zoneDNSSEC is the DNSSEC state of a zone reported by GET /api/status
+type zoneDNSSEC struct {
+	Zone      string    `json:"zone"`
+	Signed    bool      `json:"signed"`
+	CheckedAt time.Time `json:"checked_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// DNSSECMonitor detects signed zones and warns about records that change again
+// shortly after their previous change. Validating resolvers may keep serving
+// the old signed answer until its TTL expires, so rapid churn in a signed zone
+// shows up as stale or failing lookups rather than the new address.
+type DNSSECMonitor struct {
+	resolver string
+	// window is the time after a change in which another change counts as churn
+	window time.Duration
+	// delay postpones writes detected as churn, zero writes them immediately
+	delay  time.Duration
+	lookup dsLookupFunc
+
+	mu      sync.Mutex
+	zones   map[string]*zoneDNSSEC
+	changes map[string]time.Time
+	now     func() time.Time
+}
+
+// NewDNSSECMonitor creates a monitor looking up DS records through resolver,
+// the first nameserver of /etc/resolv.conf if it is empty
+func NewDNSSECMonitor(resolver string, window, delay time.Duration) *DNSSECMonitor {
+	if resolver == "" {
+		resolver = systemNameserver()
+	}
+	return &DNSSECMonitor{
+		resolver: resolver,
+		window:   window,
+		delay:    delay,
+		lookup:   lookupDS,
+		zones:    make(map[string]*zoneDNSSEC),
+		changes:  make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Signed reports whether zone has DS records in its parent, using the cached
+// state if it is recent. Failed lookups are remembered as unsigned.
+func (m *DNSSECMonitor) Signed(zone string) bool {
+	m.mu.Lock()
+	state, ok := m.zones[zone]
+	m.mu.Unlock()
+	if ok {
+		ttl := dnssecCacheTTL
+		if state.Error != "" {
+			ttl = dnssecErrorTTL
+		}
+		if m.now().Sub(state.CheckedAt) < ttl {
+			return state.Signed
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dnssecLookupTimeout)
+	defer cancel()
+	signed, err := m.lookup(ctx, m.resolver, zone)
+
+	state = &zoneDNSSEC{Zone: zone, Signed: signed, CheckedAt: m.now().UTC()}
+	if err != nil {
+		state.Error = err.Error()
+	}
+	m.mu.Lock()
+	m.zones[zone] = state
+	m.mu.Unlock()
+	return signed
+}
+
+// CheckChange records a change of hostname's recordType in zone and returns
+// a warning and the delay to wait before writing if it is churn in a signed zone
+func (m *DNSSECMonitor) CheckChange(zone, hostname, recordType string) (string, time.Duration) {
+	if !m.Signed(zone) {
+		return "", 0
+	}
+
+	key := hostname + "/" + recordType
+	now := m.now()
+	m.mu.Lock()
+	last, ok := m.changes[key]
+	m.changes[key] = now
+	m.mu.Unlock()
+
+	if !ok || now.Sub(last) >= m.window {
+		return "", 0
+	}
+	warning := fmt.Sprintf("%s %s changed again %s after its last change in DNSSEC-signed zone %s, validating resolvers may serve the old answer until its TTL expires",
+		hostname, recordType, now.Sub(last).Round(time.Second), zone)
+	return warning, m.delay
+}
+
+// Status returns the known DNSSEC state of all zones sorted by name
+func (m *DNSSECMonitor) Status() []zoneDNSSEC {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	zones := make([]zoneDNSSEC, 0, len(m.zones))
+	for _, state := range m.zones {
+		zones = append(zones, *state)
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Zone < zones[j].Zone })
+	return zones
+}
+
+// checkDNSSEC warns about and optionally delays a change of an existing record in a signed zone
+func (s *DynDNSServer) checkDNSSEC(lookup *recordLookup, hostname, recordType, value string) {
+	if s.dnssec == nil {
+		return
+	}
+	if lookup.Existing == nil || lookup.Existing.Value == value {
+		// Keep the zone's state current for the status API
+		s.dnssec.Signed(lookup.Zone.Name)
+		return
+	}
+
+	warning, delay := s.dnssec.CheckChange(lookup.Zone.Name, hostname, recordType)
+	if warning == "" {
+		return
+	}
+	if delay > 0 {
+		warning += fmt.Sprintf(", delaying the update by %s", delay)
+	}
+	log.Printf("DNSSEC warning: %s", warning)
+	s.events.Publish(Event{Kind: eventAlert, Severity: severityWarning, Hostname: hostname, Type: recordType, NewValue: value, Message: warning})
+	sleep(s.clock, delay)
+}
+
+// systemNameserver returns the first nameserver of /etc/resolv.conf, falling back to a public resolver
+func systemNameserver() string {
+	file, err := os.Open("/etc/resolv.conf")
+	if err == nil {
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) >= 2 && fields[0] == "nameserver" {
+				return fields[1]
+			}
+		}
+	}
+	return "1.1.1.1"
+}
+
+// DNS wire format constants used by lookupDS
+const (
+	dnsTypeDS    = 43
+	dnsClassIN   = 1
+	dnsFlagRD    = 0x0100
+	dnsRcodeMask = 0x000f
+)
+
+// lookupDS queries resolver for the DS records of zone over UDP
+func lookupDS(ctx context.Context, resolver, zone string) (bool, error) {
+	query, id, err := buildDNSQuery(zone, dnsTypeDS)
+	if err != nil {
+		return false, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", net.JoinHostPort(resolver, "53"))
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return false, err
+	}
+	response := make([]byte, 4096)
+	n, err := conn.Read(response)
+	if err != nil {
+		return false, err
+	}
+	return parseDSResponse(response[:n], id)
+}
+
+// buildDNSQuery encodes a recursive query for name and qtype and returns it with its ID
+func buildDNSQuery(name string, qtype uint16) ([]byte, uint16, error) {
+	id := uint16(rand.Intn(1 << 16))
+	msg := make([]byte, 12, 12+len(name)+6)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], dnsFlagRD)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+
+	msg, err := appendDNSName(msg, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+	return msg, id, nil
+}
+
+// appendDNSName appends name in uncompressed wire format
+func appendDNSName(msg []byte, name string) ([]byte, error) {
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil, fmt.Errorf("invalid DNS name %q", name)
+		}
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	return append(msg, 0), nil
+}
+
+// errShortDNSMessage is returned for truncated or malformed responses
+var errShortDNSMessage = fmt.Errorf("malformed DNS response")
+
+// parseDSResponse reports whether the response to query id contains DS records
+func parseDSResponse(msg []byte, id uint16) (bool, error) {
+	if len(msg) < 12 {
+		return false, errShortDNSMessage
+	}
+	if binary.BigEndian.Uint16(msg[0:]) != id {
+		return false, fmt.Errorf("DNS response ID mismatch")
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	switch rcode := flags & dnsRcodeMask; rcode {
+	case 0:
+	case 3:
+		// NXDOMAIN, the zone is not delegated at all
+		return false, nil
+	default:
+		return false, fmt.Errorf("DNS response code %d", rcode)
+	}
+
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	answers := int(binary.BigEndian.Uint16(msg[6:]))
+	offset := 12
+	var err error
+	for i := 0; i < questions; i++ {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return false, err
+		}
+		offset += 4
+	}
+	for i := 0; i < answers; i++ {
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return false, err
+		}
+		if offset+10 > len(msg) {
+			return false, errShortDNSMessage
+		}
+		rrtype := binary.BigEndian.Uint16(msg[offset:])
+		length := int(binary.BigEndian.Uint16(msg[offset+8:]))
+		if rrtype == dnsTypeDS {
+			return true, nil
+		}
+		offset += 10 + length
+	}
+	return false, nil
+}
+
+// skipDNSName returns the offset after the possibly compressed name at offset
+func skipDNSName(msg []byte, offset int) (int, error) {
+	for {
+		if offset >= len(msg) {
+			return 0, errShortDNSMessage
+		}
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xc0 == 0xc0:
+			// Compression pointer, the name ends here
+			return offset + 2, nil
+		}
+		offset += 1 + length
+	}
+}
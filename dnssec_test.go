@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dnsResponse turns query into a response with rcode and answers of the given types
+func dnsResponse(query []byte, rcode uint16, types ...uint16) []byte {
+	msg := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(msg[2:], 0x8000|dnsFlagRD|rcode)
+	binary.BigEndian.PutUint16(msg[6:], uint16(len(types)))
+	for _, rrtype := range types {
+		msg = append(msg, 0xc0, 0x0c)
+		msg = binary.BigEndian.AppendUint16(msg, rrtype)
+		msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+		msg = binary.BigEndian.AppendUint32(msg, 3600)
+		msg = binary.BigEndian.AppendUint16(msg, 4)
+		msg = append(msg, 1, 2, 3, 4)
+	}
+	return msg
+}
+
+func TestParseDSResponse(t *testing.T) {
+	query, id, err := buildDNSQuery("example.com.", dnsTypeDS)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		msg         []byte
+		id          uint16
+		signed      bool
+		expectError bool
+	}{
+		{name: "DS answer", msg: dnsResponse(query, 0, dnsTypeDS), id: id, signed: true},
+		{name: "DS after RRSIG", msg: dnsResponse(query, 0, 46, dnsTypeDS), id: id, signed: true},
+		{name: "no answer", msg: dnsResponse(query, 0), id: id},
+		{name: "NXDOMAIN", msg: dnsResponse(query, 3), id: id},
+		{name: "SERVFAIL", msg: dnsResponse(query, 2), id: id, expectError: true},
+		{name: "ID mismatch", msg: dnsResponse(query, 0, dnsTypeDS), id: id + 1, expectError: true},
+		{name: "truncated", msg: dnsResponse(query, 0, dnsTypeDS)[:len(query)+4], id: id, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed, err := parseDSResponse(tt.msg, tt.id)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if signed != tt.signed {
+				t.Errorf("Expected signed=%v, got %v", tt.signed, signed)
+			}
+		})
+	}
+
+	if _, _, err := buildDNSQuery("example..com", dnsTypeDS); err == nil {
+		t.Error("Expected error for empty label")
+	}
+}
+
+func TestDNSSECChurnWarning(t *testing.T) {
+	tests := []struct {
+		name           string
+		signed         bool
+		window         time.Duration
+		expectedAlerts int
+	}{
+		{name: "signed zone within window", signed: true, window: time.Hour, expectedAlerts: 1},
+		{name: "signed zone outside window", signed: true, window: 0, expectedAlerts: 0},
+		{name: "unsigned zone", signed: false, window: time.Hour, expectedAlerts: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"}}, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.dnssec = NewDNSSECMonitor("192.0.2.53", tt.window, 0)
+			lookups := 0
+			server.dnssec.lookup = func(ctx context.Context, resolver, zone string) (bool, error) {
+				lookups++
+				return tt.signed, nil
+			}
+
+			var alerts []Event
+			server.events.Subscribe(func(event Event) { alerts = append(alerts, event) }, eventAlert)
+
+			for _, ip := range []string{"2.2.2.2", "3.3.3.3"} {
+				if err := server.updateDNSRecord("home.example.com", ip, "A"); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+
+			if len(alerts) != tt.expectedAlerts {
+				t.Fatalf("Expected %d alerts, got %v", tt.expectedAlerts, alerts)
+			}
+			if tt.expectedAlerts > 0 && !strings.Contains(alerts[0].Message, "DNSSEC-signed zone example.com") {
+				t.Errorf("Unexpected alert message: %q", alerts[0].Message)
+			}
+			if lookups != 1 {
+				t.Errorf("Expected the zone state to be cached, got %d lookups", lookups)
+			}
+			if len(writes) != 2 {
+				t.Errorf("Expected both updates to be written, got %v", writes)
+			}
+		})
+	}
+}
+
+func TestStatusDNSSEC(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.dnssec = NewDNSSECMonitor("192.0.2.53", time.Hour, 0)
+	server.dnssec.lookup = func(ctx context.Context, resolver, zone string) (bool, error) {
+		if zone == "broken.example" {
+			return false, fmt.Errorf("timeout")
+		}
+		return zone == "example.com", nil
+	}
+	server.dnssec.Signed("example.com")
+	server.dnssec.Signed("broken.example")
+
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	server.handleStatus(w, req)
+
+	var response statusResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(response.DNSSEC) != 2 {
+		t.Fatalf("Expected two zones, got %+v", response.DNSSEC)
+	}
+	if response.DNSSEC[0].Zone != "broken.example" || response.DNSSEC[0].Error == "" || response.DNSSEC[0].Signed {
+		t.Errorf("Unexpected state of failed lookup: %+v", response.DNSSEC[0])
+	}
+	if response.DNSSEC[1].Zone != "example.com" || !response.DNSSEC[1].Signed {
+		t.Errorf("Expected example.com to be signed: %+v", response.DNSSEC[1])
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// DockerHostnameLabel lists the hostnames (comma separated) to point at the host for a container
+const DockerHostnameLabel = "dyndns.hostname"
+
+// dockerBucket is the store bucket remembering hostnames managed for containers
+const dockerBucket = "docker"
+
+// DockerClient is a minimal client for the Docker Engine API on a unix socket
+type DockerClient struct {
+	HTTPClient *http.Client
+	BaseURL    string
+}
+
+// dockerContainer is the subset of the container list used by the watcher
+type dockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// NewDockerClient creates a client talking to the Docker daemon listening on socket
+func NewDockerClient(socket string) *DockerClient {
+	return &DockerClient{
+		BaseURL: "http://docker",
+		HTTPClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// ListLabeledContainers returns the running containers carrying the hostname label
+func (d *DockerClient) ListLabeledContainers() ([]dockerContainer, error) {
+	filters := url.QueryEscape(fmt.Sprintf(`{"label":[%q],"status":["running"]}`, DockerHostnameLabel))
+
+	resp, err := d.HTTPClient.Get(d.BaseURL + "/containers/json?filters=" + filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("failed to list containers: status %d: %s", resp.StatusCode, body)
+	}
+
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("failed to decode container list: %w", err)
+	}
+	return containers, nil
+}
+
+// DockerWatcher maintains records for labeled containers pointing at the host's public IP
+type DockerWatcher struct {
+	server   *DynDNSServer
+	docker   *DockerClient
+	detector *IPDetector
+}
+
+// NewDockerWatcher creates a watcher using docker to discover containers and detector for the host IP
+func NewDockerWatcher(server *DynDNSServer, docker *DockerClient, detector *IPDetector) *DockerWatcher {
+	server.metrics.Describe("dyndns_docker_hostnames", "gauge", "Number of hostnames managed for Docker containers.")
+	server.metrics.Describe("dyndns_docker_sync_errors_total", "counter", "Number of failed Docker syncs.")
+
+	return &DockerWatcher{server: server, docker: docker, detector: detector}
+}
+
+// Run syncs immediately and then every interval until stop is closed
+func (w *DockerWatcher) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Sync(); err != nil {
+			log.Printf("Docker sync failed: %v", err)
+			w.server.metrics.Inc("dyndns_docker_sync_errors_total", nil)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Sync points the hostnames of running labeled containers at the public IP and
+// removes the records of hostnames whose containers are gone
+func (w *DockerWatcher) Sync() error {
+	containers, err := w.docker.ListLabeledContainers()
+	if err != nil {
+		return err
+	}
+
+	desired := map[string]string{} // hostname -> container name
+	for _, container := range containers {
+		name := container.ID
+		if len(container.Names) > 0 {
+			name = container.Names[0]
+		}
+		for _, hostname := range splitList(container.Labels[DockerHostnameLabel]) {
+			desired[hostname] = name
+		}
+	}
+
+	var lastErr error
+	if len(desired) > 0 {
+		if err := w.ensure(desired); err != nil {
+			lastErr = err
+		}
+	}
+
+	managed, err := w.server.store.List(dockerBucket)
+	if err != nil {
+		return fmt.Errorf("failed to load managed hostnames: %w", err)
+	}
+	for hostname := range managed {
+		if _, ok := desired[hostname]; ok {
+			continue
+		}
+		if err := w.remove(hostname); err != nil {
+			log.Printf("Failed to remove records of stopped container for %s: %v", hostname, err)
+			lastErr = err
+		}
+	}
+
+	w.server.metrics.Set("dyndns_docker_hostnames", nil, float64(len(desired)))
+	return lastErr
+}
+
+// ensure points all desired hostnames at the detected public addresses
+func (w *DockerWatcher) ensure(desired map[string]string) error {
+	ipv4, err := w.detector.DetectIPv4()
+	if err != nil {
+		return err
+	}
+	ipv6, err := w.detector.DetectIPv6()
+	if err != nil {
+		return err
+	}
+
+	hostnames := make([]string, 0, len(desired))
+	for hostname := range desired {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	var lastErr error
+	for _, hostname := range hostnames {
+		if err := w.server.store.Put(dockerBucket, hostname, []byte(desired[hostname])); err != nil {
+			return fmt.Errorf("failed to remember managed hostname: %w", err)
+		}
+		for recordType, ip := range map[string]string{"A": ipv4, "AAAA": ipv6} {
+			if ip == "" {
+				continue
+			}
+			if _, err := w.server.ensureRecord(hostname, ip, recordType, historySystemDocker); err != nil {
+				log.Printf("Docker sync of %s %s failed: %v", hostname, recordType, err)
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+// remove deletes the records of a hostname no longer used by any container
+func (w *DockerWatcher) remove(hostname string) error {
+	for _, recordType := range []string{"A", "AAAA"} {
+		deleted, err := w.server.removeRecord(hostname, recordType)
+		if err != nil {
+			return err
+		}
+		if deleted {
+			log.Printf("Removed %s record of %s, container stopped", recordType, hostname)
+		}
+	}
+	return w.server.store.Delete(dockerBucket, hostname)
+}
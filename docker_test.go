@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDockerWatcherSync(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	dockerServer := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" || !strings.Contains(r.URL.Query().Get("filters"), DockerHostnameLabel) {
+			t.Errorf("Unexpected request %s", r.URL)
+		}
+		w.Write([]byte(`[{"Id": "abc", "Names": ["/app"], "Labels": {"dyndns.hostname": "app.example.com"}}]`))
+	})}
+	go dockerServer.Serve(listener)
+	defer dockerServer.Close()
+
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.1"))
+	}))
+	defer echo.Close()
+
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "old", Value: "203.0.113.1"},
+		{ID: "rec2", Type: "TXT", Name: "_dyndns-a.old", Value: ownershipValue("bridge1")},
+	}
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, records, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.ownerID = "bridge1"
+	server.store.Put(dockerBucket, "old.example.com", []byte("/old"))
+
+	watcher := NewDockerWatcher(server, NewDockerClient(socket), NewIPDetector(echo.URL, ""))
+	if err := watcher.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	expected := "POST A app;POST TXT _dyndns-a.app;DELETE rec1;DELETE rec2"
+	if got := strings.Join(writes, ";"); got != expected {
+		t.Errorf("Expected writes %q, got %q", expected, got)
+	}
+
+	managed, _ := server.store.List(dockerBucket)
+	if len(managed) != 1 || string(managed["app.example.com"]) != "/app" {
+		t.Errorf("Unexpected managed hostnames: %v", managed)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+)
+
+// dsLiteHosts are hostnames behind DS-Lite connections. Their IPv4 address is
+// a carrier-grade NAT address shared with other customers, so only the AAAA
+// record is maintained and the IPv4 address sent by the router is ignored.
+type dsLiteHosts struct {
+	patterns []string
+	// deleteA removes an A record left over from before the switch to DS-Lite
+	deleteA bool
+
+	mu sync.Mutex
+	// cleaned are the hostnames whose stale A record is known to be gone
+	cleaned map[string]bool
+}
+
+// newDSLiteHosts creates the DS-Lite mode for hostnames matching patterns
+func newDSLiteHosts(patterns []string, deleteA bool) *dsLiteHosts {
+	return &dsLiteHosts{patterns: patterns, deleteA: deleteA, cleaned: make(map[string]bool)}
+}
+
+// Matches reports whether hostname uses DS-Lite, wildcard records follow their hostname
+func (d *dsLiteHosts) Matches(hostname string) bool {
+	hostname = strings.TrimPrefix(hostname, "*.")
+	for _, pattern := range d.patterns {
+		if matchesRoute(strings.ToLower(pattern), hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDSLite reports whether target only keeps its AAAA record
+func (s *DynDNSServer) isDSLite(target string) bool {
+	return s.dsLite != nil && s.dsLite.Matches(target)
+}
+
+// removeStaleA deletes the A record of a DS-Lite target once if DYNDNS_DSLITE_DELETE_A is set.
+// Failures are logged and retried with the next update, the AAAA update goes ahead.
+func (s *DynDNSServer) removeStaleA(target string) {
+	if !s.isDSLite(target) || !s.dsLite.deleteA {
+		return
+	}
+	s.dsLite.mu.Lock()
+	cleaned := s.dsLite.cleaned[target]
+	s.dsLite.mu.Unlock()
+	if cleaned {
+		return
+	}
+
+	var removed bool
+	var err error
+	if s.ownerID != "" {
+		removed, err = s.removeRecord(target, "A")
+	} else {
+		removed, err = s.removeUnmanagedRecord(target, "A")
+	}
+	if err != nil {
+		log.Printf("DS-Lite: failed to delete stale A record of %s: %v", target, err)
+		return
+	}
+	if removed {
+		log.Printf("DS-Lite: deleted stale A record of %s", target)
+	}
+	s.dsLite.mu.Lock()
+	s.dsLite.cleaned[target] = true
+	s.dsLite.mu.Unlock()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestDSLiteHostsMatches(t *testing.T) {
+	hosts := newDSLiteHosts([]string{"home.example.com", "*.lab.example.com"}, false)
+	tests := []struct {
+		hostname string
+		expected bool
+	}{
+		{hostname: "home.example.com", expected: true},
+		{hostname: "*.home.example.com", expected: true},
+		{hostname: "nas.lab.example.com", expected: true},
+		{hostname: "office.example.com", expected: false},
+	}
+
+	for _, tt := range tests {
+		if matched := hosts.Matches(tt.hostname); matched != tt.expected {
+			t.Errorf("Expected Matches(%s) to be %v, got %v", tt.hostname, tt.expected, matched)
+		}
+	}
+}
+
+func TestHandleUpdateDSLite(t *testing.T) {
+	staleA := DNSRecord{ID: "rec1", Type: "A", Name: "home", Value: "100.64.1.2"}
+	tests := []struct {
+		name             string
+		query            string
+		ownerID          string
+		deleteA          bool
+		rejectBogons     bool
+		records          []DNSRecord
+		expectedResponse string
+		expectedWrites   []string
+	}{
+		{
+			name:             "IPv4 is ignored",
+			query:            "hostname=home.example.com&myip=100.64.1.2&myipv6=2a01:4f8::1",
+			rejectBogons:     true,
+			records:          []DNSRecord{staleA},
+			expectedResponse: "good IPv6: 2a01:4f8::1",
+			expectedWrites:   []string{"POST AAAA home"},
+		},
+		{
+			name:             "IPv4 only is answered without change",
+			query:            "hostname=home.example.com&myip=100.64.1.2",
+			expectedResponse: "nochg",
+		},
+		{
+			name:             "stale A record is deleted",
+			query:            "hostname=home.example.com&myip=100.64.1.2&myipv6=2001:db8::1",
+			deleteA:          true,
+			records:          []DNSRecord{staleA},
+			expectedResponse: "good IPv6: 2001:db8::1",
+			expectedWrites:   []string{"DELETE rec1", "POST AAAA home"},
+		},
+		{
+			name:    "stale owned A record is deleted with its marker",
+			query:   "hostname=home.example.com&myip=100.64.1.2&myipv6=2001:db8::1",
+			ownerID: "bridge1",
+			deleteA: true,
+			records: []DNSRecord{
+				staleA,
+				{ID: "rec2", Type: "TXT", Name: "_dyndns-a.home", Value: ownershipValue("bridge1")},
+			},
+			expectedResponse: "good IPv6: 2001:db8::1",
+			expectedWrites:   []string{"DELETE rec1", "DELETE rec2", "POST AAAA home", "POST TXT _dyndns-aaaa.home"},
+		},
+		{
+			name:             "other hostnames keep IPv4",
+			query:            "hostname=office.example.com&myip=203.0.113.7",
+			deleteA:          true,
+			expectedResponse: "good IPv4: 203.0.113.7",
+			expectedWrites:   []string{"POST A office"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, tt.records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.ownerID = tt.ownerID
+			server.rejectBogons = tt.rejectBogons
+			server.dsLite = newDSLiteHosts([]string{"home.example.com"}, tt.deleteA)
+
+			req := httptest.NewRequest("GET", "/update?"+tt.query, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if w.Body.String() != tt.expectedResponse {
+				t.Errorf("Expected response %q, got %q", tt.expectedResponse, w.Body.String())
+			}
+			if !reflect.DeepEqual(writes, tt.expectedWrites) {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+		})
+	}
+}
+
+func TestDSLiteSkipsAOfOtherWriters(t *testing.T) {
+	newServer := func(t *testing.T) (*DynDNSServer, *[]string) {
+		var writes []string
+		mockAPI := newOwnershipMockAPI(t, nil, &writes)
+		t.Cleanup(mockAPI.Close)
+		client := NewClient("test-api-key")
+		client.BaseURL = mockAPI.URL
+		server := NewDynDNSServer(client, "admin", "password", "8080")
+		server.dsLite = newDSLiteHosts([]string{"home.example.com"}, false)
+		return server, &writes
+	}
+
+	t.Run("grpc", func(t *testing.T) {
+		server, writes := newServer(t)
+		request := protoMessage(nil).String(1, "home.example.com").String(2, "203.0.113.1").String(3, "2001:db8::1")
+		if code, _ := callGRPC(t, serveGRPC(t, server), "Update", "grpc-token", request); code != grpcOK {
+			t.Fatalf("Expected status %d, got %d", grpcOK, code)
+		}
+		if expected := []string{"POST AAAA home"}; !reflect.DeepEqual(*writes, expected) {
+			t.Errorf("Expected writes %v, got %v", expected, *writes)
+		}
+	})
+
+	t.Run("mqtt", func(t *testing.T) {
+		server, writes := newServer(t)
+		payload, _ := json.Marshal(mqttUpdateCommand{Hostname: "home.example.com", MyIP: "203.0.113.1"})
+		header, body, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(mqttPublishPacket("dyndns/update", payload, false))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		NewMQTTBridge(server, MQTTConfig{Commands: true}).handlePublish(header, body)
+		if len(*writes) != 0 {
+			t.Errorf("Expected no writes, got %v", *writes)
+		}
+	})
+
+	t.Run("telegram", func(t *testing.T) {
+		server, writes := newServer(t)
+		server.hostnames = []string{"home.example.com"}
+		echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("203.0.113.1"))
+		}))
+		defer echo.Close()
+
+		bot := NewTelegramBot(server, NewIPDetector(echo.URL, ""), "123:abc", []int64{42})
+		if reply := bot.forceUpdate("home.example.com"); reply != "home.example.com:\nA skipped, the hostname uses DS-Lite" {
+			t.Errorf("Unexpected reply %q", reply)
+		}
+		if len(*writes) != 0 {
+			t.Errorf("Expected no writes, got %v", *writes)
+		}
+	})
+}
@@ -1,69 +1,358 @@
 package main
 
 import (
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"strings"
 	"time"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+	"fritzbox-hetzner-dyndns/parse"
 )
 
 // DynDNSServer handles DynDNS update requests from FritzBox
 type DynDNSServer struct {
 	client   *Client
+	router   *ClientRouter
 	username string
 	password string
 	port     string
+	limiter  *RateLimiter
+
+	// listenAddrs are the addresses served, all addresses on port if empty
+	listenAddrs []string
+	// listenFamily restricts the listeners to IPv4 or IPv6, see DYNDNS_LISTEN_FAMILY
+	listenFamily string
+
+	// dsLite ignores the IPv4 address of hostnames behind DS-Lite, nil disables it
+	dsLite *dsLiteHosts
+
+	// ipv6InterfaceID is combined with the FritzBox <ip6lanprefix> to address a LAN host
+	ipv6InterfaceID string
+
+	// ownerID enables TXT ownership markers, only records owned by this ID are modified
+	ownerID string
+
+	// responses customizes the response bodies, nil uses the formats of the client profile
+	responses ResponseTemplates
+	// requireAgent answers badagent to requests without User-Agent, blockedAgents
+	// to requests whose User-Agent contains one of the substrings
+	requireAgent  bool
+	blockedAgents []string
+	agents        *agentTracker
+	// health tracks API outages, retries parks writes during them, nil fails them instead
+	health   apiHealth
+	retries  *RetryQueue
+	listings listingCache
+	// updateTimeout bounds the whole update flow of a request, zero waits for it
+	updateTimeout time.Duration
+	// fastAck answers update requests before performing them, see DYNDNS_FAST_ACK
+	fastAck bool
+	// hostnames are the configured hostnames kept up to date by reconciliation
+	hostnames []string
+	// zones restricts updates to hostnames in these zones, empty allows all hostnames
+	zones []string
+	// tenants maps the usernames of tenants to their isolated servers, see DYNDNS_TENANTS_FILE,
+	// tenant is the name of the tenant served by a tenant's server
+	tenants map[string]*DynDNSServer
+	tenant  string
+	// usage counts updates and API writes per credential and enforces the daily quotas
+	usage *usageTracker
+	// manifest keeps the records of DYNDNS_MANIFEST in their declared state, nil without a manifest
+	manifest *ManifestReconciler
+	// gitSync pulls the manifest from DYNDNS_GIT_REPO, nil unless configured
+	gitSync *GitSync
+	// strict only touches records that carry the ownership marker and look unmodified
+	strict bool
+	// conflictPolicy handles records changed by another actor, empty overwrites them
+	conflictPolicy string
+	// lookups caches record IDs to fetch single records instead of listing zones, nil disables it
+	lookups *lookupCache
+	// nohost caches hostnames without a zone, nil looks them up on every request
+	nohost *nohostCache
+	// profileUsers selects a client profile by username, sharing the password of username
+	profileUsers map[string]string
+	// emptyIP maps profile names to the handling of updates without addresses, "" applies to all profiles
+	emptyIP map[string]string
+	// connectionIPUsers take the address of the connection's family from the connection if it is not sent
+	connectionIPUsers []string
+	// maxSubdomainDepth limits how many labels below its zone an updated hostname may have,
+	// subdomainDepths overrides it per username, zero is unlimited
+	maxSubdomainDepth int
+	subdomainDepths   map[string]int
+
+	// allowPost accepts update parameters as POST form data in addition to GET queries
+	allowPost bool
+	// rejectBogons refuses private, loopback, link-local and documentation addresses in updates
+	rejectBogons bool
+
+	// deletable lists the hostname patterns that may be deleted through /api/records
+	deletable []string
+
+	// auth holds the configured auth chains, see configureAuth
+	auth map[authScope]AuthChain
+	// tlsCert and tlsKey enable HTTPS, clientCAs verifies client certificates for mtls auth
+	tlsCert   string
+	tlsKey    string
+	clientCAs *x509.CertPool
+
+	// authLog receives authentication failures, blocklists are consulted before requests are processed
+	authLog    *AuthLogger
+	blocklists []Blocklist
+	// accessLog receives a line per HTTP request, nil disables it
+	accessLog *AccessLogger
+	// debug serves pprof and expvar below /debug/ to administrators
+	debug bool
+	// basePath is the prefix of all endpoints, e.g. /dyndns, empty for the root
+	basePath string
+	// roundRobin are the hostnames keeping one value per source credential
+	roundRobin *roundRobinHosts
+	// backups switches records to their backup address while the primary is down
+	backups *BackupMonitor
+	// emulations serve the update endpoints of other providers
+	emulations []*providerEmulation
+	// geoip tags update requests with their country, countries rejects the
+	// others if set and rejectUnknownCountry those without a country
+	geoip                *GeoIPDatabase
+	countries            []string
+	rejectUnknownCountry bool
+
+	// done is closed when the server shuts down, stopping background loops
+	done chan struct{}
+
+	// clock is the time source, see SetClock
+	clock     Clock
+	store     Store
+	metrics   *Metrics
+	refreshed *refreshTracker
+	status    *statusTracker
+	verifier  *Verifier
+	// propagation holds the answer to an update until the change is served, nil answers immediately
+	propagation *Verifier
+	mqtt        *MQTTBridge
+	// dnssec warns about rapid changes in signed zones, nil disables the checks
+	dnssec *DNSSECMonitor
+	// breakers maps token names to the clients with a circuit breaker, reported by /readyz
+	breakers map[string]*Client
+	// annotations stores the updating client in the ownership markers, nil disables it
+	annotations *annotationTracker
+	// churn counts value changes per record and detects flapping addresses
+	churn *churnTracker
+	// history keeps the value changes of each record in the store for /api/history
+	history *historyTracker
+	// zoneTokensFile is DYNDNS_ZONE_TOKENS_FILE, the route of migrated zones is written there
+	zoneTokensFile string
+	// maintenance holds back writes to the DNS API while it is active
+	maintenance *maintenanceMode
+	// events distributes what happens to metrics, MQTT and notifications, which delivers
+	// them to the configured notifiers if set
+	events        *EventBus
+	notifications *Notifications
 }
 
 // NewDynDNSServer creates a new DynDNS server
 func NewDynDNSServer(client *Client, username, password, port string) *DynDNSServer {
-	return &DynDNSServer{
-		client:   client,
-		username: username,
-		password: password,
-		port:     port,
+	store := NewMemoryStore()
+	metrics := NewMetrics()
+	metrics.Describe("dyndns_record_updates_total", "counter", "Number of record updates by type and result.")
+	metrics.Describe("dyndns_update_response_seconds_sum", "counter", "Total time clients waited for the answer to update requests.")
+	metrics.Describe("dyndns_update_response_seconds_count", "counter", "Number of answered update requests.")
+	metrics.Describe("dyndns_update_processing_seconds_sum", "counter", "Total time spent performing updates, including those finished in the background.")
+	metrics.Describe("dyndns_update_processing_seconds_count", "counter", "Number of performed updates.")
+	metrics.Describe("dyndns_update_fast_acks_total", "counter", "Number of updates answered before they were performed.")
+	metrics.Describe("dyndns_update_panics_total", "counter", "Number of background updates that panicked.")
+	events := NewEventBus()
+	s := &DynDNSServer{
+		client:      client,
+		username:    username,
+		password:    password,
+		port:        port,
+		clock:       systemClock{},
+		store:       store,
+		metrics:     metrics,
+		refreshed:   newRefreshTracker(store),
+		status:      newStatusTracker(),
+		agents:      newAgentTracker(metrics),
+		usage:       newUsageTracker(usageQuota{}, events, metrics),
+		churn:       newChurnTracker(defaultFlapThreshold, events, metrics),
+		history:     newHistoryTracker(defaultHistoryLimit),
+		maintenance: newMaintenanceMode(metrics),
+		events:      events,
+		done:        make(chan struct{}),
 	}
+	s.health.now = s.now
+	s.listings.now = s.now
+	s.refreshed.now = s.now
+	s.status.now = s.now
+	s.usage.now = s.now
+	s.churn.now = s.now
+	s.history.now = s.now
+	s.maintenance.now = s.now
+	s.events.now = s.now
+	s.subscribeBuiltins()
+	return s
+}
+
+// SetStore replaces the state store, e.g. with a shared one for multi-replica deployments
+func (s *DynDNSServer) SetStore(store Store) {
+	s.store = store
+	s.refreshed = newRefreshTracker(store)
+	s.refreshed.now = s.now
 }
 
 // handleUpdate handles DynDNS update requests
 func (s *DynDNSServer) handleUpdate(w http.ResponseWriter, r *http.Request) {
+	s.handleUpdateAs(w, r, nil)
+}
+
+// handleUpdateAs handles an update request with the parameters and responses
+// of profile, nil selects the profile by the request
+func (s *DynDNSServer) handleUpdateAs(w http.ResponseWriter, r *http.Request, profile *ClientProfile) {
+	if !parseUpdateForm(w, r) {
+		return
+	}
+
 	// Check authentication
-	user, pass, ok := r.BasicAuth()
-	if !ok || user != s.username || pass != s.password {
-		w.Header().Set("WWW-Authenticate", `Basic realm="DynDNS"`)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !s.authorize(w, r, authScopeUpdate) {
+		return
+	}
+
+	// HEAD probes the endpoint and credentials without performing an update
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	// Parse query parameters
-	hostname := r.URL.Query().Get("hostname")
-	myip := r.URL.Query().Get("myip")
-	myipv6 := r.URL.Query().Get("myipv6")
-	offline := r.URL.Query().Get("offline")
+	if profile == nil {
+		profile = s.selectProfile(r)
+	}
+	if !s.checkUserAgent(r) {
+		log.Printf("Rejected update from User-Agent %q", r.UserAgent())
+		s.agents.Observe(r.UserAgent(), "badagent")
+		s.respond(w, profile, responseData{Code: "badagent"})
+		return
+	}
+	s.agents.Observe(r.UserAgent(), "accepted")
+
+	// Parse query parameters according to the client's quirks
+	params := parse.UpdateRequestWith(r, profile.Params)
+	if profile.FritzBoxDualStack {
+		var err error
+		if params, err = applyDualStack(params, s.ipv6InterfaceID); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	hostname := parse.NormalizeHostname(params.Hostname)
+	myip := params.MyIP
+	myipv6 := params.MyIPv6
+	offline := params.Offline
+	system := params.System
+	wildcard := params.Wildcard
+
+	origin := ""
+	if country := s.requestCountry(r); country != "" {
+		origin = ", country=" + country
+	}
+	log.Printf("DynDNS update request: hostname=%s, myip=%s, myipv6=%s, offline=%s, system=%s, wildcard=%s, agent=%q%s",
+		hostname, myip, myipv6, offline, system, wildcard, r.UserAgent(), origin)
 
-	log.Printf("DynDNS update request: hostname=%s, myip=%s, myipv6=%s, offline=%s", hostname, myip, myipv6, offline)
+	// Only the dynamic and static DNS systems of the dyndns2 protocol are supported
+	if !isSupportedSystem(system) {
+		log.Printf("Unsupported system parameter: %s", system)
+		s.respond(w, profile, responseData{Code: "badagent", Hostname: hostname})
+		return
+	}
 
 	if hostname == "" {
 		http.Error(w, "Missing hostname parameter", http.StatusBadRequest)
 		return
 	}
 
+	// Garbage would otherwise only surface as a vague nohost after zone lookups
+	if err := parse.CheckFQDN(hostname); err != nil {
+		log.Printf("Rejected update: %v", err)
+		s.respond(w, profile, responseData{Code: "notfqdn", Hostname: hostname})
+		return
+	}
+
+	// Tenants may only update hostnames in their own zones
+	if !s.allowsHostname(hostname) {
+		log.Printf("Rejected update of %s outside the zones %s", hostname, strings.Join(s.zones, ", "))
+		s.respond(w, profile, responseData{Code: "nohost", Hostname: hostname})
+		return
+	}
+
+	// Daily quotas stop clients stuck in an update loop from exhausting the API budget of everyone
+	username := requestUsername(r)
+	if !s.usage.Allow(username) {
+		log.Printf("Rejected update of %s: daily quota of %s exceeded", hostname, username)
+		s.respond(w, profile, responseData{Code: "abuse", Hostname: hostname})
+		return
+	}
+
+	// Deep names like a.b.c.example.com are only accepted from credentials allowed to create them
+	if err := s.checkSubdomainDepth(username, hostname); err != nil {
+		log.Printf("Rejected update: %v", err)
+		s.respond(w, profile, responseData{Code: "nohost", Hostname: hostname})
+		return
+	}
+
+	// Round-robin hostnames drop the values of the source going offline
+	if offline == "yes" && s.isRoundRobin(hostname) {
+		if err := s.removeMembers(hostname, username); err != nil {
+			log.Printf("Failed to remove the addresses of %s from %s: %v", username, hostname, err)
+			s.respond(w, profile, responseData{Code: "911", Hostname: hostname})
+			return
+		}
+		log.Printf("Removed the addresses of %s from %s", username, hostname)
+		s.respond(w, profile, responseData{Code: "good", Hostname: hostname})
+		return
+	}
+
 	// Handle offline request
 	if offline == "yes" {
 		log.Printf("Offline request for %s - not implemented", hostname)
-		fmt.Fprintf(w, "good")
+		s.respond(w, profile, responseData{Code: "good", Hostname: hostname})
 		return
 	}
 
+	// Routers relying on the provider to see their address send only one family or none
+	if s.usesConnectionIP(username) {
+		connIPv4, connIPv6 := connectionIPs(r)
+		if myip == "" && connIPv4 != "" {
+			myip = connIPv4
+			log.Printf("No IPv4 address sent for %s, using the connection address %s", hostname, myip)
+		}
+		if myipv6 == "" && connIPv6 != "" {
+			myipv6 = connIPv6
+			log.Printf("No IPv6 address sent for %s, using the connection address %s", hostname, myipv6)
+		}
+	}
+
+	// Requests without addresses are an error, updated to the connection address or refused
+	if myip == "" && myipv6 == "" {
+		switch s.emptyIPMode(profile) {
+		case emptyIPSource:
+			myip, myipv6 = connectionIPs(r)
+			log.Printf("No address sent for %s, using the connection address %s%s", hostname, myip, myipv6)
+		case emptyIPStrict:
+			log.Printf("Rejected update of %s without addresses", hostname)
+			s.respond(w, profile, responseData{Code: "badagent", Hostname: hostname})
+			return
+		}
+	}
+
 	var ipv4, ipv6 string
-	var updateResults []string
 
 	// Handle IPv4 address
 	if myip != "" {
-		if isValidIPv4(myip) {
+		if parse.IsIPv4(myip) {
 			ipv4 = myip
 		} else {
 			http.Error(w, "Invalid IPv4 address", http.StatusBadRequest)
@@ -73,7 +362,7 @@ func (s *DynDNSServer) handleUpdate(w http.ResponseWriter, r *http.Request) {
 
 	// Handle IPv6 address
 	if myipv6 != "" {
-		if isValidIPv6(myipv6) {
+		if parse.IsIPv6(myipv6) {
 			ipv6 = myipv6
 		} else {
 			http.Error(w, "Invalid IPv6 address", http.StatusBadRequest)
@@ -81,42 +370,230 @@ func (s *DynDNSServer) handleUpdate(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// DS-Lite routers send a CGNAT address shared with other customers, only the AAAA record is maintained
+	ignoredIPv4 := ipv4 != "" && s.isDSLite(hostname)
+	if ignoredIPv4 {
+		log.Printf("DS-Lite: ignoring IPv4 address %s of %s, only the AAAA record is maintained", ipv4, hostname)
+		ipv4 = ""
+	}
+
 	// If no IP addresses provided and we couldn't detect any, error
-	if ipv4 == "" && ipv6 == "" {
+	if ipv4 == "" && ipv6 == "" && !ignoredIPv4 {
 		http.Error(w, "No valid IP address provided or detected", http.StatusBadRequest)
 		return
 	}
 
+	// Private and reserved addresses would make the hostname unreachable from outside
+	for _, ip := range []string{ipv4, ipv6} {
+		if err := s.checkPublicIP(ip); err != nil {
+			log.Printf("Rejected update of %s: %v", hostname, err)
+			http.Error(w, "Rejected address: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Records to update, wildcard=ON additionally maintains *.hostname
+	targets := []string{hostname}
+	if strings.EqualFold(wildcard, "ON") {
+		targets = append(targets, "*."+hostname)
+	}
+	for _, target := range targets {
+		if s.annotations != nil {
+			s.annotations.SetClient(target, requestAnnotation(r))
+		}
+		s.history.SetClient(target, requestAnnotation(r))
+	}
+
+	// The deadline bounds the whole update so the client gets an answer before it gives up
+	unchanged, err := s.acknowledge(hostname, func() (bool, error) {
+		// Round-robin hostnames replace only the values of this source, without *.hostname
+		if s.isRoundRobin(hostname) {
+			unchanged, writes, err := s.updateMembers(hostname, username, ipv4, ipv6)
+			s.usage.AddWrites(username, writes)
+			return unchanged, err
+		}
+		unchanged, writes, err := s.updateTargets(targets, ipv4, ipv6)
+		s.usage.AddWrites(username, writes)
+		return unchanged, err
+	})
+	if errors.Is(err, errNoZone) {
+		s.respond(w, profile, responseData{Code: "nohost", Hostname: hostname, IPv4: ipv4, IPv6: ipv6})
+		return
+	}
+	if err != nil {
+		s.respond(w, profile, responseData{Code: "911", Hostname: hostname, IPv4: ipv4, IPv6: ipv6})
+		return
+	}
+
+	status := "good"
+	if unchanged {
+		status = "nochg"
+	}
+
+	// Return success response with the updated IPs
+	s.respond(w, profile, responseData{
+		Code:     status,
+		Hostname: hostname,
+		IPv4:     ipv4,
+		IPv6:     ipv6,
+		Details:  updateDetails(ipv4, ipv6),
+	})
+}
+
+// updateTargets writes the addresses to all targets and reports whether every
+// write was a throttled repeat of the current value and how many records were
+// sent to the API
+func (s *DynDNSServer) updateTargets(targets []string, ipv4, ipv6 string) (bool, int, error) {
+	unchanged, writes := true, 0
+	for _, target := range targets {
+		s.removeStaleA(target)
+	}
+
 	// Update IPv4 record if provided
 	if ipv4 != "" {
-		err := s.updateDNSRecord(hostname, ipv4, "A")
-		if err != nil {
-			log.Printf("Failed to update IPv4 DNS record: %v", err)
-			fmt.Fprintf(w, "911")
-			return
+		for _, target := range targets {
+			if s.isDSLite(target) {
+				continue
+			}
+			decision, err := s.submitUpdate(target, ipv4, "A")
+			if err != nil {
+				logUpdateError("IPv4", err)
+				return false, writes, err
+			}
+			if decision == rateAllow {
+				writes++
+			}
+			unchanged = unchanged && decision == rateNoChange
 		}
-		updateResults = append(updateResults, fmt.Sprintf("IPv4: %s", ipv4))
-		log.Printf("Successfully updated %s A record to %s", hostname, ipv4)
 	}
 
 	// Update IPv6 record if provided
 	if ipv6 != "" {
-		err := s.updateDNSRecord(hostname, ipv6, "AAAA")
+		for _, target := range targets {
+			decision, err := s.submitUpdate(target, ipv6, "AAAA")
+			if err != nil {
+				logUpdateError("IPv6", err)
+				return false, writes, err
+			}
+			if decision == rateAllow {
+				writes++
+			}
+			unchanged = unchanged && decision == rateNoChange
+		}
+	}
+
+	return unchanged, writes, nil
+}
+
+// logUpdateError logs a failed update, repeated lookups of unknown hostnames
+// answered from the negative cache are not logged again
+func logUpdateError(family string, err error) {
+	if errors.Is(err, errNoZoneCached) {
+		return
+	}
+	log.Printf("Failed to update %s DNS record: %v", family, err)
+}
+
+// submitUpdate updates a record, honouring the per-hostname rate limit if one is configured
+func (s *DynDNSServer) submitUpdate(hostname, ip, recordType string) (rateDecision, error) {
+	return s.submit(parkedWrite{Hostname: hostname, Type: recordType, Value: ip})
+}
+
+// submit performs write unless maintenance mode, a failover, the rate limit
+// or an unavailable API holds it back
+func (s *DynDNSServer) submit(write parkedWrite) (rateDecision, error) {
+	hostname, ip, recordType := write.Hostname, write.Value, write.Type
+	if decision, held := s.maintenance.Hold(s, write); held {
+		if decision == rateQueued {
+			s.status.Record(hostname, recordType, ip, recordStateQueued, nil)
+		}
+		return decision, nil
+	}
+	// While the record points at its backup, updates only change the primary it switches back to
+	if ip != "" && !write.Failover && s.backups.Hold(hostname, recordType, ip) {
+		return rateNoChange, nil
+	}
+	decision, err := s.reserveUpdate(write)
+	s.health.Observe(err)
+	if s.parkWrite(write, err) {
+		decision, err = rateQueued, nil
+	}
+	state := recordStateOK
+	switch {
+	case err != nil:
+		state = recordStateError
+	case decision == rateQueued:
+		state = recordStateQueued
+	}
+	if err == nil {
+		s.refreshed.Mark(hostname, recordType)
+	} else if !errors.Is(err, errNoZoneCached) {
+		s.events.Publish(Event{Kind: eventUpdateFailed, Severity: severityWarning, Hostname: hostname, Type: recordType, NewValue: ip, Error: err.Error()})
+	}
+	s.status.Record(hostname, recordType, ip, state, err)
+	s.metrics.Inc("dyndns_record_updates_total", Labels{"type": recordType, "result": state})
+	return decision, err
+}
+
+// reserveUpdate performs or defers the write according to the rate limiter
+func (s *DynDNSServer) reserveUpdate(write parkedWrite) (rateDecision, error) {
+	hostname, ip, recordType := write.Hostname, write.Value, write.Type
+	if s.limiter == nil {
+		if err := s.writeRecord(write); err != nil {
+			return rateAllow, err
+		}
+		log.Printf("Successfully updated %s %s record to %s", hostname, recordType, ip)
+		return rateAllow, nil
+	}
+
+	key := write.key()
+	decision := s.limiter.Reserve(key, ip, func(value string) {
+		queued := write
+		queued.Value = value
+		// Maintenance started while the update was waiting for its interval
+		if _, held := s.maintenance.Hold(s, queued); held {
+			s.limiter.Forget(key)
+			return
+		}
+		err := s.writeRecord(queued)
+		s.health.Observe(err)
+		if s.parkWrite(queued, err) {
+			s.limiter.Forget(key)
+			return
+		}
 		if err != nil {
-			log.Printf("Failed to update IPv6 DNS record: %v", err)
-			fmt.Fprintf(w, "911")
+			log.Printf("Failed to apply queued %s update for %s: %v", recordType, hostname, err)
+			s.status.Record(hostname, recordType, value, recordStateError, err)
+			s.limiter.Forget(key)
 			return
 		}
-		updateResults = append(updateResults, fmt.Sprintf("IPv6: %s", ipv6))
-		log.Printf("Successfully updated %s AAAA record to %s", hostname, ipv6)
+		s.status.Record(hostname, recordType, value, recordStateOK, nil)
+		log.Printf("Successfully applied queued update of %s %s record to %s", hostname, recordType, value)
+	})
+
+	switch decision {
+	case rateNoChange:
+		log.Printf("Rate limited %s %s update to %s: value unchanged", hostname, recordType, ip)
+		return decision, nil
+	case rateQueued:
+		log.Printf("Rate limited %s %s update to %s: queued", hostname, recordType, ip)
+		return decision, nil
 	}
 
-	// Return success response with the updated IPs
-	if len(updateResults) > 0 {
-		fmt.Fprintf(w, "good %s", strings.Join(updateResults, ", "))
-	} else {
-		fmt.Fprintf(w, "good")
+	if err := s.writeRecord(write); err != nil {
+		s.limiter.Forget(key)
+		return decision, err
 	}
+	log.Printf("Successfully updated %s %s record to %s", hostname, recordType, ip)
+	return decision, nil
+}
+
+// healthResponse is returned by /health
+type healthResponse struct {
+	Status    string `json:"status"`
+	Service   string `json:"service"`
+	Timestamp string `json:"timestamp"`
+	Version   string `json:"version"`
 }
 
 // handleHealth handles health check requests
@@ -125,11 +602,11 @@ func (s *DynDNSServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
-	response := map[string]interface{}{
-		"status":    "healthy",
-		"service":   "hetzner-dns-bridge",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"version":   "1.0.0",
+	response := healthResponse{
+		Status:    "healthy",
+		Service:   "hetzner-dns-bridge",
+		Timestamp: s.now().UTC().Format(time.RFC3339),
+		Version:   currentBuildInfo().Version,
 	}
 
 	json.NewEncoder(w).Encode(response)
@@ -137,41 +614,182 @@ func (s *DynDNSServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 
 // updateDNSRecord updates the DNS record using Hetzner API
 func (s *DynDNSServer) updateDNSRecord(hostname, ip, recordType string) error {
-	// Get all zones to find the correct one
-	zones, err := s.client.GetZones()
+	return s.writeRecord(parkedWrite{Hostname: hostname, Type: recordType, Value: ip})
+}
+
+// writeRecord writes the value of a record, or of one member of a round-robin record
+func (s *DynDNSServer) writeRecord(write parkedWrite) error {
+	if write.Source != "" {
+		return s.writeMember(write)
+	}
+	hostname, ip, recordType := write.Hostname, write.Value, write.Type
+	lookup, err := s.lookupRecord(hostname, recordType)
 	if err != nil {
-		return fmt.Errorf("failed to get zones: %w", err)
+		return err
 	}
+	targetZone := lookup.Zone
+	recordName := lookup.Name
+	existingRecord := lookup.Existing
 
-	var targetZone *Zone
-	var recordName string
+	if err := s.checkWritable(lookup, hostname, recordType); err != nil {
+		return err
+	}
 
-	// Find the zone that matches the hostname
-	for _, zone := range zones {
-		if hostname == zone.Name {
-			// Exact match - update root record
-			targetZone = &zone
-			recordName = "@"
-			break
-		} else if strings.HasSuffix(hostname, "."+zone.Name) {
-			// Subdomain - extract the subdomain part
-			targetZone = &zone
-			recordName = strings.TrimSuffix(hostname, "."+zone.Name)
-			break
+	if existingRecord != nil {
+		if write, err := s.checkConflict(lookup.Client, hostname, recordType, existingRecord); !write {
+			return err
+		}
+	}
+
+	s.checkDNSSEC(lookup, hostname, recordType, ip)
+
+	// Existing records keep their TTL unless the write sets one, new ones get the default of 60 minutes
+	record, changed, err := dyndns.UpsertRecord(lookup.Client, targetZone.ID, recordName, recordType, ip, write.TTL, existingRecord)
+	if err != nil {
+		return err
+	}
+	s.rememberRecord(hostname, recordType, targetZone, recordName, record.ID)
+	s.rememberModified(hostname, recordType, record)
+
+	if existingRecord != nil {
+		if !changed {
+			log.Printf("Record %s (%s) already points to %s", existingRecord.ID, recordType, ip)
+		} else if existingRecord.Value == ip {
+			log.Printf("Updated the TTL of record %s (%s) to %d", existingRecord.ID, recordType, write.TTL)
+		} else {
+			log.Printf("Updated existing record %s (%s) to %s", existingRecord.ID, recordType, ip)
+			s.rememberPrevious(hostname, recordType, existingRecord.Value)
+			s.recordChange(hostname, recordType, existingRecord.Value, ip, s.changedBy(write))
+			s.notifyIPChange(hostname, recordType, existingRecord.Value, ip)
 		}
+		s.annotateRecord(lookup, hostname, recordType)
+	} else {
+		log.Printf("Created new record %s %s -> %s", recordType, recordName, ip)
+		s.recordChange(hostname, recordType, "", ip, s.changedBy(write))
+		s.events.Publish(Event{Kind: eventRecordCreated, Severity: severityInfo, Hostname: hostname, Type: recordType, NewValue: ip})
+
+		if s.ownerID != "" {
+			if err := s.createOwnershipRecord(lookup.Client, targetZone.ID, hostname, recordName, recordType); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.verifyRecord(targetZone, hostname, recordType, ip, changed)
+	return nil
+}
+
+// checkWritable refuses writes to records of another owner or, in strict mode,
+// to records that appear to be managed by another tool
+func (s *DynDNSServer) checkWritable(lookup *recordLookup, hostname, recordType string) error {
+	if lookup.Existing != nil && s.ownerID != "" && !isOwnedRecord(lookup.Records, lookup.Name, recordType, s.ownerID) {
+		return fmt.Errorf("record %s (%s) is not owned by this bridge, refusing to update", lookup.Name, recordType)
+	}
+	return s.checkManaged(lookup, hostname, recordType)
+}
+
+// verifyRecord checks that the nameservers of zone serve value, waiting for
+// changed records if propagation is awaited
+func (s *DynDNSServer) verifyRecord(zone *Zone, hostname, recordType, value string, changed bool) {
+	// Wildcard records cannot be looked up by name, only verify regular hosts.
+	// Only addresses are resolved, other types written by the manifest are not verified.
+	if strings.HasPrefix(hostname, "*.") || recordType != "A" && recordType != "AAAA" {
+		return
+	}
+	if s.propagation != nil && changed {
+		s.propagation.WaitForPropagation(zone.NS, hostname, recordType, value)
+	} else if s.verifier != nil {
+		s.verifier.VerifyAsync(zone.NS, hostname, recordType, value)
+	}
+}
+
+// notifyIPChange publishes the change of a record value
+func (s *DynDNSServer) notifyIPChange(hostname, recordType, oldValue, newValue string) {
+	s.events.Publish(Event{
+		Kind:     eventIPChange,
+		Severity: severityInfo,
+		Hostname: hostname,
+		Type:     recordType,
+		OldValue: oldValue,
+		NewValue: newValue,
+	})
+}
+
+// clientFor returns the API client responsible for hostname
+func (s *DynDNSServer) clientFor(hostname string) (*Client, error) {
+	if s.router == nil {
+		return s.client, nil
+	}
+	return s.router.ClientFor(hostname)
+}
+
+// clients returns all configured API clients
+func (s *DynDNSServer) clients() []*Client {
+	if s.router == nil {
+		return []*Client{s.client}
+	}
+	return s.router.Clients()
+}
+
+// recordLookup is the result of resolving a hostname to its zone and records
+type recordLookup struct {
+	Client   *Client // client holding the API token for the zone
+	Zone     *Zone
+	Name     string      // record name relative to the zone, "@" for the apex
+	Records  []DNSRecord // all records of the zone
+	Existing *DNSRecord  // existing record of the requested type, nil if there is none
+}
+
+// lookupRecord finds the zone of hostname and its existing record of recordType
+func (s *DynDNSServer) lookupRecord(hostname, recordType string) (*recordLookup, error) {
+	client, err := s.clientFor(hostname)
+	if err != nil {
+		return nil, err
+	}
+
+	if lookup := s.cachedRecord(client, hostname, recordType); lookup != nil {
+		return lookup, nil
+	}
+	if s.nohost != nil && s.nohost.Cached(hostname) {
+		return nil, fmt.Errorf("%w: %s", errNoZoneCached, hostname)
+	}
+
+	lookup, err := findRecord(client, hostname, recordType)
+	if errors.Is(err, errNoZone) && s.nohost != nil {
+		ttl := s.nohost.Miss(hostname)
+		log.Printf("No zone found for %s, answering nohost without API lookups for %s", hostname, ttl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.nohost != nil {
+		s.nohost.Forget(hostname)
 	}
+	lookup.Client = client
+	if lookup.Existing != nil {
+		s.rememberRecord(hostname, recordType, lookup.Zone, lookup.Name, lookup.Existing.ID)
+	}
+	return lookup, nil
+}
 
+// findRecord resolves hostname to its zone and existing record of recordType using provider
+func findRecord(provider Provider, hostname, recordType string) (*recordLookup, error) {
+	// Find the zone that matches the hostname
+	targetZone, recordName, err := findZoneFor(provider, hostname)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zones: %w", err)
+	}
 	if targetZone == nil {
-		return fmt.Errorf("no zone found for hostname: %s", hostname)
+		return nil, fmt.Errorf("%w: %s", errNoZone, hostname)
 	}
 
 	log.Printf("Found zone: %s (ID: %s) for hostname: %s, record name: %s",
 		targetZone.Name, targetZone.ID, hostname, recordName)
 
 	// Get existing records for the zone
-	records, err := s.client.GetAllRecords(targetZone.ID)
+	records, err := zoneRecords(provider, targetZone.ID, recordName)
 	if err != nil {
-		return fmt.Errorf("failed to get records: %w", err)
+		return nil, fmt.Errorf("failed to get records: %w", err)
 	}
 
 	// Look for existing record
@@ -183,58 +801,21 @@ func (s *DynDNSServer) updateDNSRecord(hostname, ip, recordType string) error {
 		}
 	}
 
-	if existingRecord != nil {
-		// Update existing record
-		updateReq := UpdateRecordRequest{
-			ZoneID: targetZone.ID,
-			Type:  recordType,
-			Name:  recordName,
-			Value: ip,
-			TTL:   existingRecord.TTL,
-		}
-
-		log.Printf("UpdateRecord %v",
-			   updateReq)
-
-		_, err = s.client.UpdateRecord(existingRecord.ID, updateReq)
-		if err != nil {
-			return fmt.Errorf("failed to update record: %w", err)
-		}
-
-		log.Printf("Updated existing record %s (%s) to %s", existingRecord.ID, recordType, ip)
-	} else {
-		// Create new record
-		ttl := 3600 // 60 minutes TTL for dynamic records
-		createReq := CreateRecordRequest{
-			Type:   recordType,
-			Name:   recordName,
-			Value:  ip,
-			TTL:    ttl,
-			ZoneID: targetZone.ID,
-		}
-
-
-		log.Printf("createReq %v",
-			   createReq)
-		_, err = s.client.CreateRecord(createReq)
-		if err != nil {
-			return fmt.Errorf("failed to create record: %w", err)
-		}
-
-		log.Printf("Created new record %s %s -> %s", recordType, recordName, ip)
-	}
-
-	return nil
+	return &recordLookup{
+		Zone:     targetZone,
+		Name:     recordName,
+		Records:  records,
+		Existing: existingRecord,
+	}, nil
 }
 
-// isValidIPv4 checks if the given string is a valid IPv4 address
-func isValidIPv4(ip string) bool {
-	return net.ParseIP(ip) != nil && strings.Count(ip, ":") == 0
-}
-
-// isValidIPv6 checks if the given string is a valid IPv6 address
-func isValidIPv6(ip string) bool {
-	return net.ParseIP(ip) != nil && strings.Count(ip, ":") > 0
+// isSupportedSystem checks the dyndns2 system parameter, an empty value defaults to dyndns
+func isSupportedSystem(system string) bool {
+	switch strings.ToLower(system) {
+	case "", "dyndns", "statdns":
+		return true
+	}
+	return false
 }
 
 // getClientIP extracts the client IP from the request
@@ -254,6 +835,13 @@ func getClientIP(r *http.Request) string {
 	}
 
 	// Fall back to RemoteAddr
+	return remoteIP(r)
+}
+
+// remoteIP returns the address of the connection. Unlike getClientIP it
+// ignores forwarding headers, which clients can forge, so it is used to
+// decide about access.
+func remoteIP(r *http.Request) string {
 	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		return r.RemoteAddr
@@ -261,20 +849,32 @@ func getClientIP(r *http.Request) string {
 	return ip
 }
 
+// startupSummary describes the listening server without revealing credentials
+func (s *DynDNSServer) startupSummary() string {
+	scheme := "http"
+	if s.tlsCert != "" {
+		scheme = "https"
+	}
+	var endpoints []string
+	for _, route := range s.routes() {
+		// The root and the subtrees below /debug/ are not announced
+		if !strings.HasSuffix(route.Path, "/") {
+			endpoints = append(endpoints, route.Path)
+		}
+	}
+	summary := fmt.Sprintf("Starting DynDNS server: listen=%s scheme=%s endpoints=%s hostnames=%d",
+		strings.Join(s.listenAddrsOrDefault(), ","), scheme, strings.Join(endpoints, ","), len(s.hostnames))
+	if s.basePath != "" {
+		summary += " base_path=" + s.basePath
+	}
+	return summary
+}
+
 // Start starts the DynDNS server
 func (s *DynDNSServer) Start() error {
-	http.HandleFunc("/update", s.handleUpdate)
-	http.HandleFunc("/nic/update", s.handleUpdate) // Alternative endpoint some clients use
-	http.HandleFunc("/health", s.handleHealth)     // Health check endpoint
-	http.HandleFunc("/", s.handleHealth)           // Root endpoint for simple health checks
-
-	log.Printf("Starting DynDNS server on port %s", s.port)
-	log.Printf("Update URL: http://localhost:%s/update?hostname=yourdomain.com&myip=1.2.3.4", s.port)
-	log.Printf("Configure your FritzBox with:")
-	log.Printf("  Update URL: http://your-server:%s/update", s.port)
-	log.Printf("  Domain: <your-domain>")
-	log.Printf("  Username: %s", s.username)
-	log.Printf("  Password: %s", s.password)
-
-	return http.ListenAndServe(":"+s.port, nil)
+	log.Print(s.startupSummary())
+	if _, shadowed := s.emulationRoutes(s.bridgeRoutes()); len(shadowed) > 0 {
+		log.Printf("WARNING: emulated paths %s are served by the bridge itself", strings.Join(shadowed, ", "))
+	}
+	return s.serve(s.Routes())
 }
@@ -0,0 +1,81 @@
+package dyndns
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTTL is the TTL of created dynamic records, 60 minutes
+const DefaultTTL = 3600
+
+// Provider is the DNS backend records are written through, the Hetzner
+// client of fritzbox-hetzner-dyndns implements it for the Hetzner DNS API
+type Provider interface {
+	GetZones() ([]Zone, error)
+	GetAllRecords(zoneID string) ([]DNSRecord, error)
+	CreateRecord(req CreateRecordRequest) (*DNSRecord, error)
+	UpdateRecord(recordID string, req UpdateRecordRequest) (*DNSRecord, error)
+}
+
+// ZoneFor picks the most specific zone of zones holding fqdn and the record
+// name within it, "@" for the apex. It returns nil if no zone holds fqdn.
+func ZoneFor(zones []Zone, fqdn string) (*Zone, string) {
+	fqdn = strings.ToLower(strings.TrimSuffix(fqdn, "."))
+
+	var best *Zone
+	var recordName string
+	for i, zone := range zones {
+		zoneName := strings.ToLower(zone.Name)
+		if best != nil && len(zoneName) <= len(best.Name) {
+			continue
+		}
+		if fqdn == zoneName {
+			best, recordName = &zones[i], "@"
+		} else if strings.HasSuffix(fqdn, "."+zoneName) {
+			best, recordName = &zones[i], strings.TrimSuffix(fqdn, "."+zoneName)
+		}
+	}
+	return best, recordName
+}
+
+// UpsertRecord creates the record or updates existing unless it already
+// holds value and ttl. A ttl of 0 keeps the TTL of an existing record and
+// uses DefaultTTL for a new one. It reports whether a write was needed.
+func UpsertRecord(provider Provider, zoneID, name, recordType, value string, ttl int, existing *DNSRecord) (*DNSRecord, bool, error) {
+	if existing == nil {
+		if ttl == 0 {
+			ttl = DefaultTTL
+		}
+		record, err := provider.CreateRecord(CreateRecordRequest{
+			Type:   recordType,
+			Name:   name,
+			Value:  value,
+			TTL:    &ttl,
+			ZoneID: zoneID,
+		})
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to create record: %w", err)
+		}
+		return record, true, nil
+	}
+
+	recordTTL := existing.TTL
+	if ttl != 0 {
+		recordTTL = &ttl
+	}
+	if existing.Value == value && (ttl == 0 || existing.TTL != nil && *existing.TTL == ttl) {
+		return existing, false, nil
+	}
+
+	record, err := provider.UpdateRecord(existing.ID, UpdateRecordRequest{
+		ZoneID: zoneID,
+		Type:   recordType,
+		Name:   name,
+		Value:  value,
+		TTL:    recordTTL,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to update record: %w", err)
+	}
+	return record, true, nil
+}
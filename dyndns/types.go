@@ -0,0 +1,111 @@
+package dyndns
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// timeLayouts lists the timestamp formats returned by the Hetzner DNS API
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999 -0700 MST",
+	"2006-01-02 15:04:05 -0700 MST",
+}
+
+// DNSRecord represents a DNS record in the Hetzner DNS API
+type DNSRecord struct {
+	ID       string `json:"id,omitempty"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	TTL      *int   `json:"ttl,omitempty"`
+	ZoneID   string `json:"zone_id,omitempty"`
+	Created  string `json:"created,omitempty"`
+	Modified string `json:"modified,omitempty"`
+}
+
+// Zone represents a DNS zone
+type Zone struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	TTL             int       `json:"ttl"`
+	Registrar       string    `json:"registrar"`
+	LegacyDNSHost   string    `json:"legacy_dns_host"`
+	LegacyNS        []string  `json:"legacy_ns"`
+	NS              []string  `json:"ns"`
+	Created         time.Time `json:"created"`
+	Verified        time.Time `json:"verified"`
+	Modified        time.Time `json:"modified"`
+	Project         string    `json:"project"`
+	Owner           string    `json:"owner"`
+	Permission      string    `json:"permission"`
+	ZoneType        string    `json:"zone_type"`
+	Status          string    `json:"status"`
+	Paused          bool      `json:"paused"`
+	IsSecondaryDNS  bool      `json:"is_secondary_dns"`
+	TxtVerification struct {
+		Name  string `json:"name"`
+		Token string `json:"token"`
+	} `json:"txt_verification"`
+	RecordsCount int `json:"records_count"`
+}
+
+// UnmarshalJSON decodes a zone, accepting both RFC 3339 and the
+// "2006-01-02 15:04:05.000 +0000 UTC" timestamps used by the Hetzner API
+func (z *Zone) UnmarshalJSON(data []byte) error {
+	type zoneAlias Zone
+	aux := struct {
+		*zoneAlias
+		Created  string `json:"created"`
+		Verified string `json:"verified"`
+		Modified string `json:"modified"`
+	}{zoneAlias: (*zoneAlias)(z)}
+
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	var err error
+	if z.Created, err = ParseTime(aux.Created); err != nil {
+		return err
+	}
+	if z.Verified, err = ParseTime(aux.Verified); err != nil {
+		return err
+	}
+	if z.Modified, err = ParseTime(aux.Modified); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseTime parses a timestamp in any of the known API formats, an empty string yields the zero time
+func ParseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
+// CreateRecordRequest represents the request to create a new record
+type CreateRecordRequest struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    *int   `json:"ttl,omitempty"`
+	ZoneID string `json:"zone_id"`
+}
+
+// UpdateRecordRequest represents the request to update a record
+type UpdateRecordRequest struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	TTL    *int   `json:"ttl,omitempty"`
+	ZoneID string `json:"zone_id"`
+}
@@ -0,0 +1,160 @@
+// Package dyndns holds the update logic of fritzbox-hetzner-dyndns, so other
+// programs can point A and AAAA records at addresses without running the
+// HTTP server of the bridge
+package dyndns
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// ErrNoZone is returned for hostnames that belong to none of the zones of the provider
+var ErrNoZone = errors.New("no zone found for hostname")
+
+// cacheBucket holds the values last written by an Updater with a cache
+const cacheBucket = "updater"
+
+// Cache stores the values written by an Updater, the state stores of
+// fritzbox-hetzner-dyndns implement it
+type Cache interface {
+	Get(bucket, key string) ([]byte, bool, error)
+	Put(bucket, key string, value []byte) error
+}
+
+// Updater points A and AAAA records at addresses through a Provider
+type Updater struct {
+	provider Provider
+	ttl      int
+	cache    Cache
+	logger   *log.Logger
+	allowed  []string
+}
+
+// Option configures an Updater
+type Option func(*Updater)
+
+// WithTTL sets the TTL of created records, the default is DefaultTTL
+func WithTTL(ttl int) Option {
+	return func(u *Updater) { u.ttl = ttl }
+}
+
+// WithCache remembers written values in cache and skips API calls for unchanged addresses
+func WithCache(cache Cache) Option {
+	return func(u *Updater) { u.cache = cache }
+}
+
+// WithLogger sets the logger, the default is the standard logger
+func WithLogger(logger *log.Logger) Option {
+	return func(u *Updater) { u.logger = logger }
+}
+
+// WithAllowedHosts restricts updates to hostnames matching patterns, which
+// are zone names or globs like *.lab.example.com
+func WithAllowedHosts(patterns ...string) Option {
+	return func(u *Updater) {
+		for _, pattern := range patterns {
+			u.allowed = append(u.allowed, strings.ToLower(pattern))
+		}
+	}
+}
+
+// New creates an Updater writing records through provider
+func New(provider Provider, opts ...Option) *Updater {
+	u := &Updater{provider: provider, ttl: DefaultTTL, logger: log.Default()}
+	for _, opt := range opts {
+		opt(u)
+	}
+	return u
+}
+
+// Update points the A or AAAA record of hostname, depending on the address
+// family of ip, at ip and reports whether the record was changed
+func (u *Updater) Update(hostname, ip string) (bool, error) {
+	hostname = parse.NormalizeHostname(hostname)
+
+	var recordType string
+	switch {
+	case parse.IsIPv4(ip):
+		recordType = "A"
+	case parse.IsIPv6(ip):
+		recordType = "AAAA"
+	default:
+		return false, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	if !u.isAllowed(hostname) {
+		return false, fmt.Errorf("hostname not allowed: %s", hostname)
+	}
+
+	cacheKey := hostname + "/" + recordType
+	if u.cache != nil {
+		if cached, ok, err := u.cache.Get(cacheBucket, cacheKey); err == nil && ok && string(cached) == ip {
+			return false, nil
+		}
+	}
+
+	zones, err := u.provider.GetZones()
+	if err != nil {
+		return false, fmt.Errorf("failed to get zones: %w", err)
+	}
+	zone, name := ZoneFor(zones, hostname)
+	if zone == nil {
+		return false, fmt.Errorf("%w: %s", ErrNoZone, hostname)
+	}
+	records, err := u.provider.GetAllRecords(zone.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to get records: %w", err)
+	}
+	var existing *DNSRecord
+	for i, record := range records {
+		if record.Name == name && record.Type == recordType {
+			existing = &records[i]
+			break
+		}
+	}
+
+	// The configured TTL applies to new records, existing ones keep theirs
+	ttl := 0
+	if existing == nil {
+		ttl = u.ttl
+	}
+	_, changed, err := UpsertRecord(u.provider, zone.ID, name, recordType, ip, ttl, existing)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case existing == nil:
+		u.logger.Printf("Created new record %s %s -> %s", recordType, hostname, ip)
+	case changed:
+		u.logger.Printf("Updated record %s %s to %s", recordType, hostname, ip)
+	}
+
+	if u.cache != nil {
+		if err := u.cache.Put(cacheBucket, cacheKey, []byte(ip)); err != nil {
+			u.logger.Printf("Failed to cache %s %s: %v", recordType, hostname, err)
+		}
+	}
+	return changed, nil
+}
+
+// isAllowed reports whether hostname matches one of the allowed patterns
+func (u *Updater) isAllowed(hostname string) bool {
+	if len(u.allowed) == 0 {
+		return true
+	}
+	for _, pattern := range u.allowed {
+		if strings.Contains(pattern, "*") {
+			if matched, err := path.Match(pattern, hostname); err == nil && matched {
+				return true
+			}
+		} else if hostname == pattern || strings.HasSuffix(hostname, "."+pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package dyndns
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+// fakeProvider serves the zone example.com with fixed records and logs writes
+// as "POST <type> <name>" and "PUT <id>", created records are not served
+type fakeProvider struct {
+	records []DNSRecord
+	writes  []string
+}
+
+func (p *fakeProvider) GetZones() ([]Zone, error) {
+	return []Zone{{ID: "zone1", Name: "example.com"}}, nil
+}
+
+func (p *fakeProvider) GetAllRecords(zoneID string) ([]DNSRecord, error) {
+	return p.records, nil
+}
+
+func (p *fakeProvider) CreateRecord(req CreateRecordRequest) (*DNSRecord, error) {
+	p.writes = append(p.writes, "POST "+req.Type+" "+req.Name)
+	return &DNSRecord{ID: "new", Type: req.Type, Name: req.Name, Value: req.Value, TTL: req.TTL, ZoneID: req.ZoneID}, nil
+}
+
+func (p *fakeProvider) UpdateRecord(recordID string, req UpdateRecordRequest) (*DNSRecord, error) {
+	p.writes = append(p.writes, "PUT "+recordID)
+	return &DNSRecord{ID: recordID, Type: req.Type, Name: req.Name, Value: req.Value, TTL: req.TTL, ZoneID: req.ZoneID}, nil
+}
+
+// memoryCache is a Cache backed by a map
+type memoryCache map[string][]byte
+
+func (c memoryCache) Get(bucket, key string) ([]byte, bool, error) {
+	value, ok := c[bucket+"/"+key]
+	return value, ok, nil
+}
+
+func (c memoryCache) Put(bucket, key string, value []byte) error {
+	c[bucket+"/"+key] = value
+	return nil
+}
+
+func TestUpdaterUpdate(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"},
+		{ID: "rec2", Type: "AAAA", Name: "home", Value: "2001:db8::1"},
+	}
+
+	tests := []struct {
+		name           string
+		hostname       string
+		ip             string
+		opts           []Option
+		expectChanged  bool
+		expectError    bool
+		expectedWrites []string
+	}{
+		{
+			name:           "update changed A record",
+			hostname:       "home.example.com",
+			ip:             "1.2.3.4",
+			expectChanged:  true,
+			expectedWrites: []string{"PUT rec1"},
+		},
+		{
+			name:     "unchanged AAAA record",
+			hostname: "home.example.com.",
+			ip:       "2001:db8::1",
+		},
+		{
+			name:           "create missing record",
+			hostname:       "nas.example.com",
+			ip:             "1.2.3.4",
+			opts:           []Option{WithTTL(300)},
+			expectChanged:  true,
+			expectedWrites: []string{"POST A nas"},
+		},
+		{
+			name:        "invalid IP",
+			hostname:    "home.example.com",
+			ip:          "not-an-ip",
+			expectError: true,
+		},
+		{
+			name:        "unknown zone",
+			hostname:    "home.example.org",
+			ip:          "1.2.3.4",
+			expectError: true,
+		},
+		{
+			name:        "hostname not allowed",
+			hostname:    "home.example.com",
+			ip:          "1.2.3.4",
+			opts:        []Option{WithAllowedHosts("*.lab.example.com")},
+			expectError: true,
+		},
+		{
+			name:           "allowed hostname",
+			hostname:       "nas.lab.example.com",
+			ip:             "1.2.3.4",
+			opts:           []Option{WithAllowedHosts("*.lab.example.com")},
+			expectChanged:  true,
+			expectedWrites: []string{"POST A nas.lab"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := &fakeProvider{records: records}
+			updater := New(provider, tt.opts...)
+
+			changed, err := updater.Update(tt.hostname, tt.ip)
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if changed != tt.expectChanged {
+				t.Errorf("Expected changed=%v, got %v", tt.expectChanged, changed)
+			}
+			if strings.Join(provider.writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, provider.writes)
+			}
+		})
+	}
+}
+
+func TestUpdaterCache(t *testing.T) {
+	provider := &fakeProvider{}
+	var logs bytes.Buffer
+	updater := New(provider, WithCache(memoryCache{}), WithLogger(log.New(&logs, "", 0)))
+
+	if _, err := updater.Update("home.example.com", "1.2.3.4"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The provider keeps serving no records, only the cache prevents a second create
+	changed, err := updater.Update("home.example.com", "1.2.3.4")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if changed {
+		t.Error("Expected cached value to be reported as unchanged")
+	}
+	if len(provider.writes) != 1 {
+		t.Errorf("Expected a single write, got %v", provider.writes)
+	}
+	if !strings.Contains(logs.String(), "Created new record A home.example.com") {
+		t.Errorf("Expected create to be logged with the custom logger, got %q", logs.String())
+	}
+}
@@ -1,11 +1,17 @@
 package main
 
 import (
-	"encoding/json"
+	"io"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
+
+	"fritzbox-hetzner-dyndns/parse"
 )
 
 func TestNewDynDNSServer(t *testing.T) {
@@ -173,39 +179,7 @@ func TestHandleUpdateIPValidation(t *testing.T) {
 }
 
 func TestHandleUpdateWithMockClient(t *testing.T) {
-	// Create a mock Hetzner API server
-	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		switch {
-		case r.URL.Path == "/zones":
-			zones := ZonesResponse{
-				Zones: []Zone{
-					{ID: "zone123", Name: "example.com"},
-				},
-			}
-			json.NewEncoder(w).Encode(zones)
-
-		case r.URL.Path == "/records" && r.URL.Query().Get("zone_id") == "zone123":
-			records := RecordsResponse{
-				Records: []DNSRecord{
-					{ID: "record123", Type: "A", Name: "test", Value: "1.2.3.4"},
-				},
-			}
-			json.NewEncoder(w).Encode(records)
-
-		case r.URL.Path == "/records/record123" && r.Method == "PUT":
-			record := RecordResponse{
-				Record: DNSRecord{ID: "record123", Type: "A", Name: "test", Value: "1.2.3.5"},
-			}
-			json.NewEncoder(w).Encode(record)
-
-		default:
-			w.WriteHeader(http.StatusNotFound)
-		}
-	}))
-	defer mockAPI.Close()
-
-	client := NewClient("test-api-key")
-	client.BaseURL = mockAPI.URL
+	fake, client := newFakeAPIServer(t, DNSRecord{ID: "record123", Type: "A", Name: "test", Value: "1.2.3.4"})
 
 	server := NewDynDNSServer(client, "admin", "password", "8080")
 
@@ -222,6 +196,9 @@ func TestHandleUpdateWithMockClient(t *testing.T) {
 	if !strings.Contains(w.Body.String(), "good") {
 		t.Errorf("Expected success response, got '%s'", w.Body.String())
 	}
+	if records := fake.Records(); len(records) != 1 || records[0].ID != "record123" || records[0].Value != "1.2.3.5" {
+		t.Errorf("Expected record123 to be updated, got %+v", records)
+	}
 }
 
 func TestIsValidIPv4(t *testing.T) {
@@ -243,9 +220,9 @@ func TestIsValidIPv4(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.ip, func(t *testing.T) {
-			result := isValidIPv4(tt.ip)
+			result := parse.IsIPv4(tt.ip)
 			if result != tt.expected {
-				t.Errorf("isValidIPv4(%s) = %v, expected %v", tt.ip, result, tt.expected)
+				t.Errorf("parse.IsIPv4(%s) = %v, expected %v", tt.ip, result, tt.expected)
 			}
 		})
 	}
@@ -268,9 +245,9 @@ func TestIsValidIPv6(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.ip, func(t *testing.T) {
-			result := isValidIPv6(tt.ip)
+			result := parse.IsIPv6(tt.ip)
 			if result != tt.expected {
-				t.Errorf("isValidIPv6(%s) = %v, expected %v", tt.ip, result, tt.expected)
+				t.Errorf("parse.IsIPv6(%s) = %v, expected %v", tt.ip, result, tt.expected)
 			}
 		})
 	}
@@ -331,7 +308,6 @@ func TestUpdateDNSRecord(t *testing.T) {
 		hostname    string
 		ip          string
 		recordType  string
-		zones       []Zone
 		records     []DNSRecord
 		expectError bool
 	}{
@@ -340,23 +316,16 @@ func TestUpdateDNSRecord(t *testing.T) {
 			hostname:   "test.example.com",
 			ip:         "1.2.3.4",
 			recordType: "A",
-			zones: []Zone{
-				{ID: "zone1", Name: "example.com"},
-			},
 			records: []DNSRecord{
 				{ID: "rec1", Type: "A", Name: "test", Value: "1.2.3.3"},
 			},
 			expectError: false,
 		},
 		{
-			name:       "create new record",
-			hostname:   "new.example.com",
-			ip:         "1.2.3.4",
-			recordType: "A",
-			zones: []Zone{
-				{ID: "zone1", Name: "example.com"},
-			},
-			records:     []DNSRecord{},
+			name:        "create new record",
+			hostname:    "new.example.com",
+			ip:          "1.2.3.4",
+			recordType:  "A",
 			expectError: false,
 		},
 		{
@@ -364,42 +333,13 @@ func TestUpdateDNSRecord(t *testing.T) {
 			hostname:    "test.notfound.com",
 			ip:          "1.2.3.4",
 			recordType:  "A",
-			zones:       []Zone{},
-			records:     []DNSRecord{},
 			expectError: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				switch {
-				case r.URL.Path == "/zones":
-					response := ZonesResponse{Zones: tt.zones}
-					json.NewEncoder(w).Encode(response)
-
-				case strings.HasPrefix(r.URL.Path, "/records") && r.Method == "GET":
-					response := RecordsResponse{Records: tt.records}
-					json.NewEncoder(w).Encode(response)
-
-				case strings.HasPrefix(r.URL.Path, "/records") && r.Method == "PUT":
-					record := RecordResponse{
-						Record: DNSRecord{ID: "updated", Type: tt.recordType, Name: "test", Value: tt.ip},
-					}
-					json.NewEncoder(w).Encode(record)
-
-				case r.URL.Path == "/records" && r.Method == "POST":
-					record := RecordResponse{
-						Record: DNSRecord{ID: "created", Type: tt.recordType, Name: "test", Value: tt.ip},
-					}
-					json.NewEncoder(w).Encode(record)
-				}
-			}))
-			defer mockAPI.Close()
-
-			client := NewClient("test-api-key")
-			client.BaseURL = mockAPI.URL
-
+			fake, client := newFakeAPIServer(t, tt.records...)
 			server := NewDynDNSServer(client, "admin", "password", "8080")
 
 			err := server.updateDNSRecord(tt.hostname, tt.ip, tt.recordType)
@@ -410,6 +350,127 @@ func TestUpdateDNSRecord(t *testing.T) {
 			if !tt.expectError && err != nil {
 				t.Errorf("Unexpected error: %v", err)
 			}
+			if tt.expectError {
+				return
+			}
+			name := strings.TrimSuffix(tt.hostname, ".example.com")
+			if !slices.ContainsFunc(fake.Records(), func(r DNSRecord) bool { return r.Type == tt.recordType && r.Name == name && r.Value == tt.ip }) {
+				t.Errorf("Expected %s %s %s to be written, got %+v", tt.recordType, name, tt.ip, fake.Records())
+			}
 		})
 	}
 }
+
+func TestHandleUpdateRateLimited(t *testing.T) {
+	fake, client := newFakeAPIServer(t)
+
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.limiter = NewRateLimiter(time.Hour)
+
+	expected := []string{"good IPv4: 1.2.3.4", "nochg IPv4: 1.2.3.4"}
+	for i, want := range expected {
+		req := httptest.NewRequest("GET", "/update?hostname=test.example.com&myip=1.2.3.4", nil)
+		req.SetBasicAuth("admin", "password")
+
+		w := httptest.NewRecorder()
+		server.handleUpdate(w, req)
+
+		if w.Body.String() != want {
+			t.Errorf("Request %d: expected '%s', got '%s'", i, want, w.Body.String())
+		}
+	}
+
+	var writes int
+	for _, request := range fake.Requests() {
+		if request == "POST /records" || strings.HasPrefix(request, "PUT ") {
+			writes++
+		}
+	}
+	if writes != 1 {
+		t.Errorf("Expected 1 write to the API, got %d", writes)
+	}
+}
+
+func TestHandleUpdateSystemParameter(t *testing.T) {
+	client := NewClient("test-api-key")
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	req := httptest.NewRequest("GET", "/update?hostname=test.com&myip=1.2.3.4&system=custom", nil)
+	req.SetBasicAuth("admin", "password")
+
+	w := httptest.NewRecorder()
+	server.handleUpdate(w, req)
+
+	if w.Body.String() != "badagent" {
+		t.Errorf("Expected response 'badagent', got '%s'", w.Body.String())
+	}
+}
+
+func TestHandleUpdateWildcard(t *testing.T) {
+	fake, client := newFakeAPIServer(t)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	req := httptest.NewRequest("GET", "/update?hostname=home.example.com&myip=1.2.3.4&system=dyndns&wildcard=ON", nil)
+	req.SetBasicAuth("admin", "password")
+
+	w := httptest.NewRecorder()
+	server.handleUpdate(w, req)
+
+	if !strings.HasPrefix(w.Body.String(), "good") {
+		t.Errorf("Expected success response, got '%s'", w.Body.String())
+	}
+	if records := fake.Records(); len(records) != 2 || records[0].Name != "home" || records[1].Name != "*.home" {
+		t.Errorf("Expected records home and *.home to be created, got %+v", records)
+	}
+}
+
+func TestIsSupportedSystem(t *testing.T) {
+	tests := []struct {
+		system   string
+		expected bool
+	}{
+		{"", true},
+		{"dyndns", true},
+		{"statdns", true},
+		{"DynDNS", true},
+		{"custom", false},
+		{"invalid", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.system, func(t *testing.T) {
+			if result := isSupportedSystem(tt.system); result != tt.expected {
+				t.Errorf("isSupportedSystem(%s) = %v, expected %v", tt.system, result, tt.expected)
+			}
+		})
+	}
+}
+
+func BenchmarkHandleUpdate(b *testing.B) {
+	fake, err := newFakeHetzner([]string{"example.com"}, "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	server := NewDynDNSServer(newFakeClient(fake, "test-api-key"), "admin", "password", "8080")
+	server.limiter = NewRateLimiter(time.Hour)
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&myip=1.2.3.4&myipv6=2001:db8::1", nil)
+	req.SetBasicAuth("admin", "password")
+	// The first update writes the records, later ones are answered from the limiter
+	server.handleUpdate(httptest.NewRecorder(), req)
+	requests := len(fake.Requests())
+
+	b.ReportAllocs()
+	for b.Loop() {
+		w := httptest.NewRecorder()
+		server.handleUpdate(w, req)
+		if !strings.HasPrefix(w.Body.String(), "nochg") {
+			b.Fatalf("Unexpected response %q", w.Body.String())
+		}
+	}
+	if len(fake.Requests()) != requests {
+		b.Errorf("Expected no API requests after the first update, got %v", fake.Requests()[requests:])
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// providerEmulation serves the update endpoint of another DynDNS provider, so
+// devices with a hardcoded provider URL can be pointed at the bridge by
+// overriding the provider's hostname in the local DNS
+type providerEmulation struct {
+	Name  string
+	Paths []string
+	// SecretParams carry the password instead of Basic Auth, the request then
+	// authenticates as DYNDNS_USERNAME
+	SecretParams []string
+	Profile      *ClientProfile
+}
+
+// withDefaults prepends names to the default spellings of a parameter
+func withDefaults(names, defaults []string) []string {
+	return append(slices.Clone(names), defaults...)
+}
+
+// providerEmulations are the built-in emulations selectable with DYNDNS_EMULATE
+var providerEmulations = map[string]*providerEmulation{
+	"dynv6": {
+		Name:         "dynv6",
+		Paths:        []string{"/api/update"},
+		SecretParams: []string{"token"},
+		Profile: &ClientProfile{
+			Name:   "dynv6",
+			Params: parse.Names{Hostname: withDefaults([]string{"zone"}, parse.HostnameParams), IPv4: parse.IPv4Params, IPv6: parse.IPv6Params},
+			Responses: ResponseTemplates{
+				"good":  template.Must(template.New("good").Parse(`addresses updated`)),
+				"nochg": template.Must(template.New("nochg").Parse(`addresses unchanged`)),
+			},
+		},
+	},
+	"ipv64": {
+		Name:         "ipv64",
+		Paths:        []string{"/update.php"},
+		SecretParams: []string{"key"},
+		Profile:      &ClientProfile{Name: "ipv64", Params: parse.DefaultNames},
+	},
+	"ddnss": {
+		Name:         "ddnss",
+		Paths:        []string{"/upd.php"},
+		SecretParams: []string{"key"},
+		Profile:      &ClientProfile{Name: "ddnss", Params: parse.DefaultNames},
+	},
+}
+
+// emulationNames returns the names of the built-in emulations
+func emulationNames() []string {
+	names := make([]string, 0, len(providerEmulations))
+	for name := range providerEmulations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseEmulations parses the provider names and paths of DYNDNS_EMULATE,
+// a path such as /v3/update serves the dyndns2 protocol there
+func parseEmulations(value string) ([]*providerEmulation, error) {
+	var emulations []*providerEmulation
+	for _, item := range splitList(value) {
+		if !strings.HasPrefix(item, "/") {
+			emulation := providerEmulations[strings.ToLower(item)]
+			if emulation == nil {
+				return nil, fmt.Errorf("unknown provider %q (expected one of %s or a path)", item, strings.Join(emulationNames(), ", "))
+			}
+			emulations = append(emulations, emulation)
+			continue
+		}
+		if item == "/" || strings.HasSuffix(item, "/") || strings.ContainsAny(item, "?#% ") {
+			return nil, fmt.Errorf("invalid path %q, expected e.g. /v3/update", item)
+		}
+		if strings.HasPrefix(item, "/debug/") {
+			return nil, fmt.Errorf("path %q is reserved for the debug endpoints", item)
+		}
+		emulations = append(emulations, &providerEmulation{Name: item, Paths: []string{item}})
+	}
+	return emulations, nil
+}
+
+// translate returns r with the secret of a provider parameter as Basic Auth
+// credentials of username, r itself if it carries none
+func (e *providerEmulation) translate(r *http.Request, username string) *http.Request {
+	if r.Header.Get("Authorization") != "" {
+		return r
+	}
+	if _, _, ok := queryCredentials(r); ok {
+		return r
+	}
+	secret := parse.FirstParam(parse.Values(r), e.SecretParams)
+	if secret == "" {
+		return r
+	}
+	translated := r.Clone(r.Context())
+	translated.SetBasicAuth(username, secret)
+	return translated
+}
+
+// emulationRoutes returns the update routes of the configured emulations and
+// the emulated paths skipped because the bridge serves them already
+func (s *DynDNSServer) emulationRoutes(served []serverRoute) ([]serverRoute, []string) {
+	taken := map[string]bool{}
+	for _, route := range served {
+		taken[route.Path] = true
+	}
+	var routes []serverRoute
+	var shadowed []string
+	for _, emulation := range s.emulations {
+		handler := s.emulate(emulation)
+		for _, path := range emulation.Paths {
+			if taken[path] {
+				shadowed = append(shadowed, path)
+				continue
+			}
+			taken[path] = true
+			routes = append(routes, serverRoute{Path: path, Group: routeGroupUpdate, Methods: s.updateMethods(), Blocked: true, handler: handler})
+		}
+	}
+	return routes, shadowed
+}
+
+// emulate handles the updates of an emulated provider with its parameters and responses
+func (s *DynDNSServer) emulate(emulation *providerEmulation) http.HandlerFunc {
+	update := s.routeTenant(func(server *DynDNSServer, w http.ResponseWriter, r *http.Request) {
+		server.handleUpdateAs(w, r, emulation.Profile)
+	})
+	return func(w http.ResponseWriter, r *http.Request) {
+		// The secret may be sent in a POST body
+		if !parseUpdateForm(w, r) {
+			return
+		}
+		update(w, emulation.translate(r, s.username))
+	}
+}
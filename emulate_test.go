@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestParseEmulations(t *testing.T) {
+	tests := []struct {
+		value         string
+		expected      []string
+		errorContains string
+	}{
+		{value: "", expected: nil},
+		{value: "dynv6, IPv64", expected: []string{"dynv6", "ipv64"}},
+		{value: "ddnss,/v3/update", expected: []string{"ddnss", "/v3/update"}},
+		{value: "noip", errorContains: "unknown provider"},
+		{value: "/", errorContains: "invalid path"},
+		{value: "/v3/", errorContains: "invalid path"},
+		{value: "/update?x=1", errorContains: "invalid path"},
+		{value: "/debug/update", errorContains: "reserved"},
+	}
+
+	for _, tt := range tests {
+		emulations, err := parseEmulations(tt.value)
+		if tt.errorContains != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("parseEmulations(%q): expected error containing %q, got %v", tt.value, tt.errorContains, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseEmulations(%q): unexpected error: %v", tt.value, err)
+			continue
+		}
+		var names []string
+		for _, emulation := range emulations {
+			names = append(names, emulation.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(tt.expected, ",") {
+			t.Errorf("parseEmulations(%q) = %v, expected %v", tt.value, names, tt.expected)
+		}
+	}
+}
+
+func TestEmulatedUpdates(t *testing.T) {
+	_, client := newFakeAPIServer(t)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.allowPost = true
+	server.emulations, _ = parseEmulations("dynv6,ipv64,ddnss,/v3/update")
+	routes := server.Routes()
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		form           url.Values
+		basicAuth      bool
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "dynv6", target: "/api/update?zone=home.example.com&ipv4=203.0.113.7&token=password", expectedStatus: http.StatusOK, expectedBody: "addresses updated"},
+		{name: "ipv64", target: "/update.php?key=password&domain=nas.example.com&ip=203.0.113.8", expectedStatus: http.StatusOK, expectedBody: "good IPv4: 203.0.113.8"},
+		{name: "ddnss form body", method: "POST", target: "/upd.php", form: url.Values{"key": {"password"}, "host": {"ddnss.example.com"}, "ip": {"203.0.113.9"}}, expectedStatus: http.StatusOK, expectedBody: "good IPv4: 203.0.113.9"},
+		{name: "custom path", target: "/v3/update?hostname=v3.example.com&myip=203.0.113.10", basicAuth: true, expectedStatus: http.StatusOK, expectedBody: "good IPv4: 203.0.113.10"},
+		{name: "wrong secret", target: "/update.php?key=wrong&domain=nas.example.com&ip=203.0.113.8", expectedStatus: http.StatusUnauthorized},
+		{name: "missing secret", target: "/update.php?domain=nas.example.com&ip=203.0.113.8", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = "GET"
+			}
+			var req *http.Request
+			if tt.form != nil {
+				req = httptest.NewRequest(method, tt.target, strings.NewReader(tt.form.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				req = httptest.NewRequest(method, tt.target, nil)
+			}
+			if tt.basicAuth {
+				req.SetBasicAuth("admin", "password")
+			}
+			req.RemoteAddr = "192.0.2.1:1234"
+			w := httptest.NewRecorder()
+			routes.ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain %q, got %q", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestEmulationRoutesShadowed(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.emulations, _ = parseEmulations("/nic/update,ipv64")
+
+	routes, shadowed := server.emulationRoutes(server.bridgeRoutes())
+	if len(routes) != 1 || routes[0].Path != "/update.php" || routes[0].Group != routeGroupUpdate {
+		t.Errorf("Expected only the /update.php update route, got %+v", routes)
+	}
+	if len(shadowed) != 1 || shadowed[0] != "/nic/update" {
+		t.Errorf("Expected /nic/update to be shadowed, got %v", shadowed)
+	}
+}
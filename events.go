@@ -0,0 +1,141 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event kinds published on the event bus
+const (
+	eventIPChange      = "ip_change"
+	eventRecordCreated = "record_created"
+	eventUpdateFailed  = "update_failed"
+	eventAuthFailure   = "auth_failure"
+	eventAlert         = "alert"
+)
+
+// eventKinds lists the supported event kinds
+var eventKinds = []string{eventIPChange, eventRecordCreated, eventUpdateFailed, eventAuthFailure, eventAlert}
+
+// Event severities
+const (
+	severityInfo    = "info"
+	severityWarning = "warning"
+)
+
+// severityRanks orders the severities for minimum severity routing
+var severityRanks = map[string]int{severityInfo: 0, severityWarning: 1}
+
+// Event is something that happened in the bridge, published on the event bus
+type Event struct {
+	Kind     string `json:"kind"`
+	Severity string `json:"severity"`
+	Hostname string `json:"hostname,omitempty"`
+	Type     string `json:"type,omitempty"`
+	OldValue string `json:"old_value,omitempty"`
+	NewValue string `json:"new_value,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Username and Source are the claimed user and client address of auth failures
+	Username string `json:"username,omitempty"`
+	Source   string `json:"source,omitempty"`
+	// Message is the human readable text, rendered from the kind's template before notifications are delivered
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Title returns a short subject line for the event
+func (e Event) Title() string {
+	title := "DynDNS " + strings.ReplaceAll(e.Kind, "_", " ")
+	if e.Hostname != "" {
+		title += ": " + e.Hostname
+	}
+	return title
+}
+
+// eventSubscriber is a handler registered on the event bus
+type eventSubscriber struct {
+	id      int
+	kinds   []string
+	handler func(Event)
+}
+
+// EventBus passes published events to the subscribed handlers
+type EventBus struct {
+	mu          sync.RWMutex
+	nextID      int
+	subscribers []eventSubscriber
+	now         func() time.Time
+}
+
+// NewEventBus creates an event bus without subscribers
+func NewEventBus() *EventBus {
+	return &EventBus{now: time.Now}
+}
+
+// Subscribe registers handler for events of the given kinds, all kinds if none
+// are given, and returns a function removing the subscription. Handlers run on
+// the publishing goroutine in subscription order and must not block.
+func (b *EventBus) Subscribe(handler func(Event), kinds ...string) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	id := b.nextID
+	b.subscribers = append(b.subscribers, eventSubscriber{id: id, kinds: kinds, handler: handler})
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		for i, subscriber := range b.subscribers {
+			if subscriber.id == id {
+				b.subscribers = append(b.subscribers[:i:i], b.subscribers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish timestamps event and passes it to the subscribers of its kind
+func (b *EventBus) Publish(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = b.now().UTC()
+	}
+
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	for _, subscriber := range subscribers {
+		if len(subscriber.kinds) == 0 || slices.Contains(subscriber.kinds, event.Kind) {
+			subscriber.handler(event)
+		}
+	}
+}
+
+// subscribeBuiltins connects the server's own integrations to its event bus
+func (s *DynDNSServer) subscribeBuiltins() {
+	s.metrics.Describe("dyndns_events_total", "counter", "Number of published events by kind.")
+	s.events.Subscribe(func(event Event) {
+		s.metrics.Inc("dyndns_events_total", Labels{"kind": event.Kind})
+	})
+
+	s.events.Subscribe(func(event Event) {
+		s.churn.Observe(event.Hostname, event.Type)
+	}, eventIPChange)
+
+	// The MQTT bridge publishes record changes and alerts on its own topics
+	s.events.Subscribe(func(event Event) {
+		if s.mqtt != nil {
+			s.mqtt.Notify(event)
+		}
+	}, eventIPChange, eventRecordCreated, eventAlert)
+
+	s.events.Subscribe(func(event Event) {
+		if s.notifications != nil {
+			s.notifications.Send(event)
+		}
+	})
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEventBusSubscribe(t *testing.T) {
+	bus := NewEventBus()
+	var all, failures []string
+	unsubscribe := bus.Subscribe(func(event Event) { all = append(all, event.Kind) })
+	bus.Subscribe(func(event Event) { failures = append(failures, event.Hostname) }, eventUpdateFailed)
+
+	bus.Publish(Event{Kind: eventIPChange, Hostname: "home.example.com"})
+	bus.Publish(Event{Kind: eventUpdateFailed, Hostname: "nas.example.com"})
+	unsubscribe()
+	bus.Publish(Event{Kind: eventUpdateFailed, Hostname: "office.example.com"})
+
+	if strings.Join(all, ",") != "ip_change,update_failed" {
+		t.Errorf("Unexpected events for catch-all subscriber: %v", all)
+	}
+	if strings.Join(failures, ",") != "nas.example.com,office.example.com" {
+		t.Errorf("Unexpected events for failure subscriber: %v", failures)
+	}
+}
+
+func TestServerPublishesEvents(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	var events []Event
+	server.events.Subscribe(func(event Event) { events = append(events, event) })
+
+	if err := server.updateDNSRecord("new.example.com", "1.2.3.4", "A"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	req := httptest.NewRequest("GET", "/update?hostname=new.example.com&myip=1.2.3.4", nil)
+	req.SetBasicAuth("admin", "wrong")
+	req.RemoteAddr = "198.51.100.7:4711"
+	w := httptest.NewRecorder()
+	server.handleUpdate(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("Expected 401, got %d", w.Code)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("Expected two events, got %+v", events)
+	}
+	if created := events[0]; created.Kind != eventRecordCreated || created.Hostname != "new.example.com" || created.NewValue != "1.2.3.4" {
+		t.Errorf("Unexpected creation event: %+v", created)
+	}
+	if failure := events[1]; failure.Kind != eventAuthFailure || failure.Username != "admin" || failure.Source != "198.51.100.7" || failure.Timestamp.IsZero() {
+		t.Errorf("Unexpected auth failure event: %+v", failure)
+	}
+	if value := server.metrics.Value("dyndns_events_total", Labels{"kind": eventAuthFailure}); value != 1 {
+		t.Errorf("Expected auth failure to be counted, got %v", value)
+	}
+}
+
+func TestAuthFailuresNeedExplicitRoute(t *testing.T) {
+	route := notifierRoute{}
+	if route.matches(Event{Kind: eventAuthFailure}) {
+		t.Error("Expected auth failures to be skipped without a route")
+	}
+	route.rule.Kinds = []string{eventAuthFailure}
+	if !route.matches(Event{Kind: eventAuthFailure}) {
+		t.Error("Expected routed auth failures to be delivered")
+	}
+}
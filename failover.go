@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// tokenFailover switches a client to a secondary API token after the primary
+// has been rejected several times in a row, so tokens can be rotated without downtime
+type tokenFailover struct {
+	secondary  string
+	threshold  int
+	onFailover func()
+
+	mu       sync.Mutex
+	failures int
+	active   bool
+}
+
+// EnableFailover configures secondary as fallback token, used after threshold
+// consecutive authentication errors with the primary token. onFailover is
+// called once when the switch happens and may be nil.
+func (c *Client) EnableFailover(secondary string, threshold int, onFailover func()) {
+	if threshold < 1 {
+		threshold = 1
+	}
+	c.failover = &tokenFailover{secondary: secondary, threshold: threshold, onFailover: onFailover}
+}
+
+// UsingSecondaryToken reports whether the client has failed over to its secondary token
+func (c *Client) UsingSecondaryToken() bool {
+	if c.failover == nil {
+		return false
+	}
+	c.failover.mu.Lock()
+	defer c.failover.mu.Unlock()
+	return c.failover.active
+}
+
+// token returns the API token to send with the next request
+func (c *Client) token() string {
+	if c.UsingSecondaryToken() {
+		return c.failover.secondary
+	}
+	return c.APIKey
+}
+
+// observeStatus tracks authentication errors of the primary token and reports
+// whether the client just failed over, in which case the request should be retried
+func (c *Client) observeStatus(status int) bool {
+	if c.failover == nil {
+		return false
+	}
+
+	f := c.failover
+	f.mu.Lock()
+	if f.active {
+		f.mu.Unlock()
+		return false
+	}
+	if status != http.StatusUnauthorized && status != http.StatusForbidden {
+		f.failures = 0
+		f.mu.Unlock()
+		return false
+	}
+
+	f.failures++
+	if f.failures < f.threshold {
+		f.mu.Unlock()
+		return false
+	}
+	f.active = true
+	onFailover := f.onFailover
+	f.mu.Unlock()
+
+	if onFailover != nil {
+		onFailover()
+	}
+	return true
+}
+
+// enableTokenFailover configures secondary as fallback token for client and
+// raises a notification when name's primary token is given up
+func (s *DynDNSServer) enableTokenFailover(client *Client, name, secondary string, threshold int) {
+	s.metrics.Describe("dyndns_token_failovers_total", "counter", "Number of failovers to a secondary API token.")
+
+	client.EnableFailover(secondary, threshold, func() {
+		s.metrics.Inc("dyndns_token_failovers_total", Labels{"token": name})
+		message := fmt.Sprintf("API token %s was rejected %d times in a row, switched to the secondary token", name, threshold)
+		log.Print(message)
+		s.events.Publish(Event{Kind: eventAlert, Severity: severityWarning, Message: message})
+	})
+}
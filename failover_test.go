@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTokenMockAPI returns the fake API accepting only the valid token and recording the tokens used
+func newTokenMockAPI(t *testing.T, valid string, tokens *[]string) *httptest.Server {
+	fake := newTestFake(t)
+	fake.tokens = []string{valid}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*tokens = append(*tokens, r.Header.Get("Auth-API-Token"))
+		fake.ServeHTTP(w, r)
+	}))
+}
+
+func TestClientTokenFailover(t *testing.T) {
+	var tokens []string
+	mockAPI := newTokenMockAPI(t, "secondary", &tokens)
+	defer mockAPI.Close()
+
+	failovers := 0
+	client := NewClient("primary")
+	client.BaseURL = mockAPI.URL
+	client.EnableFailover("secondary", 2, func() { failovers++ })
+
+	if _, err := client.GetZones(); err == nil {
+		t.Fatal("Expected first request to fail with the primary token")
+	}
+	if client.UsingSecondaryToken() {
+		t.Fatal("Expected no failover after a single auth error")
+	}
+
+	if _, err := client.GetZones(); err != nil {
+		t.Fatalf("Expected retry with secondary token to succeed, got %v", err)
+	}
+	if _, err := client.GetZones(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !client.UsingSecondaryToken() {
+		t.Error("Expected client to use the secondary token")
+	}
+	if failovers != 1 {
+		t.Errorf("Expected 1 failover notification, got %d", failovers)
+	}
+	expected := "primary;primary;secondary;secondary"
+	if strings.Join(tokens, ";") != expected {
+		t.Errorf("Expected tokens %s, got %s", expected, strings.Join(tokens, ";"))
+	}
+}
+
+func TestClientTokenFailoverResetsOnSuccess(t *testing.T) {
+	status := http.StatusUnauthorized
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"zones":[]}`))
+	}))
+	defer mockAPI.Close()
+
+	client := NewClient("primary")
+	client.BaseURL = mockAPI.URL
+	client.EnableFailover("secondary", 2, nil)
+
+	client.GetZones()
+	status = http.StatusOK
+	client.GetZones()
+	status = http.StatusUnauthorized
+	client.GetZones()
+
+	if client.UsingSecondaryToken() {
+		t.Error("Expected successful request to reset the failure count")
+	}
+}
+
+func TestClientWithoutFailover(t *testing.T) {
+	var tokens []string
+	mockAPI := newTokenMockAPI(t, "secondary", &tokens)
+	defer mockAPI.Close()
+
+	client := NewClient("primary")
+	client.BaseURL = mockAPI.URL
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetZones(); err == nil {
+			t.Fatal("Expected request to fail without a secondary token")
+		}
+	}
+	if client.UsingSecondaryToken() {
+		t.Error("Expected no failover without a secondary token")
+	}
+}
+
+func TestServerTokenFailoverMetric(t *testing.T) {
+	var tokens []string
+	mockAPI := newTokenMockAPI(t, "secondary", &tokens)
+	defer mockAPI.Close()
+
+	client := NewClient("primary")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.enableTokenFailover(client, "default", "secondary", 1)
+
+	if _, err := client.GetZones(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value := server.metrics.Value("dyndns_token_failovers_total", Labels{"token": "default"}); value != 1 {
+		t.Errorf("Expected failover metric 1, got %v", value)
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// fakeFailure answers matching requests with an API error instead of serving them
+type fakeFailure struct {
+	Status int `json:"status"`
+	// Method and Path restrict the failure to matching requests, empty matches all
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	// Count is the number of requests still failing
+	Count int `json:"count"`
+}
+
+// matches reports whether the failure applies to a request
+func (f fakeFailure) matches(method, path string) bool {
+	return (f.Method == "" || strings.EqualFold(f.Method, method)) && strings.HasPrefix(path, f.Path)
+}
+
+// FailNext makes the next count requests matching method and path prefix fail with status
+func (f *fakeHetzner) FailNext(status, count int, method, path string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failures = append(f.failures, fakeFailure{Status: status, Method: method, Path: path, Count: count})
+}
+
+// Requests returns the method and path of the API requests served so far
+func (f *fakeHetzner) Requests() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.requests)
+}
+
+// Seed adds records to zone, which is created if needed. Records without an ID get one.
+func (f *fakeHetzner) Seed(zone string, records ...DNSRecord) {
+	f.AddZone(zone)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	zoneID := f.state.Zones[slices.IndexFunc(f.state.Zones, func(z Zone) bool { return z.Name == parse.NormalizeHostname(zone) })].ID
+	for _, record := range records {
+		record.ZoneID = zoneID
+		if record.ID == "" {
+			record.ID = "mock" + strconv.Itoa(f.state.NextID)
+			f.state.NextID++
+		}
+		f.state.Records = append(f.state.Records, record)
+	}
+	f.save()
+}
+
+// admit checks the token, the rate limit and the injected failures before a
+// request is served, answering the error itself. The caller holds mu.
+func (f *fakeHetzner) admit(w http.ResponseWriter, r *http.Request, path string) bool {
+	f.requests = append(f.requests, r.Method+" "+path)
+
+	token := r.Header.Get("Auth-API-Token")
+	if token == "" || (len(f.tokens) > 0 && !slices.Contains(f.tokens, token)) {
+		fakeAPIError(w, "invalid authentication credentials", http.StatusUnauthorized)
+		return false
+	}
+
+	if f.rateLimit > 0 {
+		now := time.Now()
+		if now.Sub(f.windowStart) >= f.rateWindow {
+			f.windowStart, f.windowCount = now, 0
+		}
+		f.windowCount++
+		reset := f.windowStart.Add(f.rateWindow)
+		w.Header().Set("Ratelimit-Limit", strconv.Itoa(f.rateLimit))
+		w.Header().Set("Ratelimit-Remaining", strconv.Itoa(max(f.rateLimit-f.windowCount, 0)))
+		w.Header().Set("Ratelimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		if f.windowCount > f.rateLimit {
+			w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+			fakeAPIError(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return false
+		}
+	}
+
+	for i, failure := range f.failures {
+		if !failure.matches(r.Method, path) {
+			continue
+		}
+		if f.failures[i].Count--; f.failures[i].Count <= 0 {
+			f.failures = slices.Delete(f.failures, i, i+1)
+		}
+		fakeAPIError(w, fmt.Sprintf("injected failure %d", failure.Status), failure.Status)
+		return false
+	}
+	return true
+}
+
+// serveControl serves the endpoints below /_fake/ steering the fake in
+// integration tests: state, requests, failures, zones and reset
+func (f *fakeHetzner) serveControl(w http.ResponseWriter, r *http.Request, endpoint string) {
+	query := r.URL.Query()
+	switch {
+	case endpoint == "state" && r.Method == http.MethodGet:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		json.NewEncoder(w).Encode(f.state)
+	case endpoint == "requests" && r.Method == http.MethodGet:
+		json.NewEncoder(w).Encode(f.Requests())
+	case endpoint == "failures" && r.Method == http.MethodPost:
+		status, err := strconv.Atoi(query.Get("status"))
+		if err != nil || status < 400 || status > 599 {
+			fakeAPIError(w, "status must be an HTTP error status", http.StatusBadRequest)
+			return
+		}
+		count := 1
+		if value := query.Get("count"); value != "" {
+			if count, err = strconv.Atoi(value); err != nil || count < 1 {
+				fakeAPIError(w, "count must be a positive number", http.StatusBadRequest)
+				return
+			}
+		}
+		f.FailNext(status, count, query.Get("method"), query.Get("path"))
+		w.WriteHeader(http.StatusNoContent)
+	case endpoint == "zones" && r.Method == http.MethodPost:
+		if query.Get("name") == "" {
+			fakeAPIError(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		f.AddZone(query.Get("name"))
+		w.WriteHeader(http.StatusNoContent)
+	case endpoint == "reset" && r.Method == http.MethodPost:
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.state.Records, f.failures, f.requests = nil, nil, nil
+		f.save()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		fakeAPIError(w, "not found", http.StatusNotFound)
+	}
+}
+
+// fakeAPIOptions are the arguments of the fakeapi subcommand
+type fakeAPIOptions struct {
+	Listen    string
+	Zones     []string
+	StatePath string
+	Tokens    []string
+	// RateLimit is the number of requests allowed per minute, zero is unlimited
+	RateLimit int
+}
+
+// parseFakeAPIArgs parses "[--listen <addr>] [--zones <a,b>] [--state <file>] [--token <token>] [--rate-limit <n>]"
+func parseFakeAPIArgs(args []string) (fakeAPIOptions, error) {
+	opts := fakeAPIOptions{Listen: ":8081"}
+	for i := 0; i < len(args); i++ {
+		if i+1 == len(args) {
+			return opts, fmt.Errorf("%s requires a value\n%s", args[i], cliUsage)
+		}
+		value := args[i+1]
+		switch args[i] {
+		case "--listen":
+			opts.Listen = value
+		case "--zones":
+			opts.Zones = splitList(value)
+		case "--state":
+			opts.StatePath = value
+		case "--token":
+			opts.Tokens = append(opts.Tokens, value)
+		case "--rate-limit":
+			limit, err := strconv.Atoi(value)
+			if err != nil || limit < 0 {
+				return opts, fmt.Errorf("--rate-limit must be a non-negative number")
+			}
+			opts.RateLimit = limit
+		default:
+			return opts, fmt.Errorf("unknown fakeapi option %s\n%s", args[i], cliUsage)
+		}
+		i++
+	}
+	if len(opts.Zones) == 0 {
+		opts.Zones = []string{"example.com"}
+	}
+	return opts, nil
+}
+
+// newFakeAPI creates the fake backend configured by opts
+func newFakeAPI(opts fakeAPIOptions) (*fakeHetzner, error) {
+	fake, err := newFakeHetzner(opts.Zones, opts.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	fake.tokens = opts.Tokens
+	fake.rateLimit, fake.rateWindow = opts.RateLimit, time.Minute
+	return fake, nil
+}
+
+// runFakeAPI handles "fakeapi", serving the fake Hetzner DNS API over HTTP
+// until it fails. It needs no configuration.
+func runFakeAPI(args []string, out io.Writer) error {
+	opts, err := parseFakeAPIArgs(args)
+	if err != nil {
+		return err
+	}
+	fake, err := newFakeAPI(opts)
+	if err != nil {
+		return err
+	}
+	address := opts.Listen
+	if strings.HasPrefix(address, ":") {
+		address = "localhost" + address
+	}
+	fmt.Fprintf(out, "Fake Hetzner DNS API serving %s on %s, point the bridge at it with HETZNER_DNS_API_URL=http://%s\n",
+		strings.Join(opts.Zones, ", "), opts.Listen, address)
+	return http.ListenAndServe(opts.Listen, fake)
+}
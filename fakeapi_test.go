@@ -0,0 +1,172 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestFake creates a fake Hetzner DNS API with the zone example.com holding records
+func newTestFake(t *testing.T, records ...DNSRecord) *fakeHetzner {
+	t.Helper()
+	fake, err := newFakeHetzner([]string{"example.com"}, "")
+	if err != nil {
+		t.Fatalf("Failed to create fake API: %v", err)
+	}
+	fake.Seed("example.com", records...)
+	return fake
+}
+
+// newFakeAPIServer serves the zone example.com holding records from a fake
+// Hetzner DNS API over HTTP and returns a client for it
+func newFakeAPIServer(t *testing.T, records ...DNSRecord) (*fakeHetzner, *Client) {
+	t.Helper()
+	fake := newTestFake(t, records...)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := NewClient("test-api-key")
+	client.BaseURL = server.URL
+	return fake, client
+}
+
+func TestParseFakeAPIArgs(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		expected      fakeAPIOptions
+		errorContains string
+	}{
+		{
+			name:     "defaults",
+			expected: fakeAPIOptions{Listen: ":8081", Zones: []string{"example.com"}},
+		},
+		{
+			name:     "all options",
+			args:     []string{"--listen", "127.0.0.1:9000", "--zones", "example.org,example.net", "--state", "fake.json", "--token", "a", "--token", "b", "--rate-limit", "60"},
+			expected: fakeAPIOptions{Listen: "127.0.0.1:9000", Zones: []string{"example.org", "example.net"}, StatePath: "fake.json", Tokens: []string{"a", "b"}, RateLimit: 60},
+		},
+		{name: "missing value", args: []string{"--listen"}, errorContains: "requires a value"},
+		{name: "invalid rate limit", args: []string{"--rate-limit", "-1"}, errorContains: "--rate-limit"},
+		{name: "unknown option", args: []string{"--verbose", "true"}, errorContains: "unknown fakeapi option"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseFakeAPIArgs(tt.args)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(opts, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, opts)
+			}
+		})
+	}
+}
+
+func TestFakeAPIErrors(t *testing.T) {
+	fake, client := newFakeAPIServer(t, DNSRecord{Type: "A", Name: "home", Value: "203.0.113.1"})
+
+	// Injected server errors surface as an unavailable API, then the fake recovers
+	fake.FailNext(http.StatusServiceUnavailable, 2, "GET", "/zones")
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetZones(); !isAPIUnavailable(err) {
+			t.Errorf("Request %d: expected an unavailable API, got %v", i, err)
+		}
+	}
+	if _, err := client.GetZones(); err != nil {
+		t.Errorf("Expected the failure to be used up, got %v", err)
+	}
+
+	// Failures only apply to matching requests
+	fake.FailNext(http.StatusUnprocessableEntity, 1, "POST", "/records")
+	if _, err := client.GetAllRecords("zone-example-com"); err != nil {
+		t.Errorf("Expected listing records to succeed, got %v", err)
+	}
+	if _, err := client.CreateRecord(CreateRecordRequest{ZoneID: "zone-example-com", Type: "A", Name: "nas", Value: "203.0.113.2"}); err == nil || isAPIUnavailable(err) {
+		t.Errorf("Expected the injected client error, got %v", err)
+	}
+
+	fake.tokens = []string{"other-key"}
+	if _, err := client.GetZones(); err == nil || !strings.Contains(err.Error(), "authentication") {
+		t.Errorf("Expected an unknown token to be rejected, got %v", err)
+	}
+}
+
+func TestFakeAPIRateLimit(t *testing.T) {
+	fake, err := newFakeAPI(fakeAPIOptions{Zones: []string{"example.com"}, RateLimit: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/api/v1/zones", nil)
+		req.Header.Set("Auth-API-Token", "token")
+		w := httptest.NewRecorder()
+		fake.ServeHTTP(w, req)
+		statuses = append(statuses, w.Code)
+		if i == 2 && (w.Header().Get("Ratelimit-Remaining") != "0" || w.Header().Get("Retry-After") == "") {
+			t.Errorf("Expected rate limit headers, got %v", w.Header())
+		}
+	}
+	if !reflect.DeepEqual(statuses, []int{200, 200, 429}) {
+		t.Errorf("Expected the third request to be limited, got %v", statuses)
+	}
+
+	// A new window admits requests again
+	fake.windowStart = time.Now().Add(-time.Minute)
+	req := httptest.NewRequest("GET", "/zones", nil)
+	req.Header.Set("Auth-API-Token", "token")
+	w := httptest.NewRecorder()
+	fake.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the next window to admit requests, got %d", w.Code)
+	}
+}
+
+func TestFakeAPIControl(t *testing.T) {
+	fake, client := newFakeAPIServer(t, DNSRecord{Type: "A", Name: "home", Value: "203.0.113.1"})
+
+	tests := []struct {
+		method         string
+		target         string
+		expectedStatus int
+	}{
+		{"POST", "/_fake/failures?status=500&count=1&path=/zones", http.StatusNoContent},
+		{"POST", "/_fake/failures?status=200", http.StatusBadRequest},
+		{"POST", "/_fake/zones?name=example.org", http.StatusNoContent},
+		{"GET", "/_fake/state", http.StatusOK},
+		{"GET", "/_fake/unknown", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		fake.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != tt.expectedStatus {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.target, tt.expectedStatus, w.Code)
+		}
+	}
+
+	if _, err := client.GetZones(); err == nil {
+		t.Errorf("Expected the injected failure")
+	}
+	zones, err := client.GetZones()
+	if err != nil || len(zones) != 2 {
+		t.Errorf("Expected the added zone, got %+v %v", zones, err)
+	}
+
+	w := httptest.NewRecorder()
+	fake.ServeHTTP(w, httptest.NewRequest("POST", "/_fake/reset", nil))
+	if len(fake.Records()) != 0 || len(fake.Requests()) != 0 {
+		t.Errorf("Expected reset to clear records and requests, got %+v %v", fake.Records(), fake.Requests())
+	}
+}
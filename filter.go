@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// RecordFilter selects records, empty fields match every record
+type RecordFilter struct {
+	Type       string
+	NamePrefix string
+	Value      string
+}
+
+// Match reports whether record passes the filter. Types compare case-insensitively, names and values exactly.
+func (f RecordFilter) Match(record DNSRecord) bool {
+	if f.Type != "" && !strings.EqualFold(record.Type, f.Type) {
+		return false
+	}
+	if f.NamePrefix != "" && !strings.HasPrefix(record.Name, f.NamePrefix) {
+		return false
+	}
+	if f.Value != "" && record.Value != f.Value {
+		return false
+	}
+	return true
+}
+
+// FilterRecords returns the records passing filter
+func FilterRecords(records []DNSRecord, filter RecordFilter) []DNSRecord {
+	var matching []DNSRecord
+	for _, record := range records {
+		if filter.Match(record) {
+			matching = append(matching, record)
+		}
+	}
+	return matching
+}
+
+// FindRecords returns the records of the zone passing filter
+func (c *Client) FindRecords(zoneID string, filter RecordFilter) ([]DNSRecord, error) {
+	records, err := c.GetAllRecords(zoneID)
+	if err != nil {
+		return nil, err
+	}
+	return FilterRecords(records, filter), nil
+}
+
+// GetRecordsByName returns the records of the zone named name. With
+// FilterByName the API is asked for them with a name parameter, falling back
+// to listing the zone for good once the API ignores or rejects it.
+func (c *Client) GetRecordsByName(zoneID, name string) ([]DNSRecord, error) {
+	match := func(record DNSRecord) bool { return record.Name == name }
+	if !c.FilterByName || c.filterUnsupported.Load() {
+		records, err := c.GetAllRecords(zoneID)
+		if err != nil {
+			return nil, err
+		}
+		return slices.DeleteFunc(records, func(record DNSRecord) bool { return !match(record) }), nil
+	}
+
+	var all []DNSRecord
+	for page := 1; ; page++ {
+		endpoint := fmt.Sprintf("/records?zone_id=%s&name=%s", zoneID, url.QueryEscape(name))
+		if c.PageSize > 0 {
+			endpoint += fmt.Sprintf("&page=%d&per_page=%d", page, c.PageSize)
+		}
+		resp, err := c.makeRequest("GET", endpoint, nil)
+		if err != nil {
+			return nil, err
+		}
+		var recordsResp RecordsResponse
+		err = c.handleResponse(resp, &recordsResp)
+		if err != nil && resp.StatusCode != http.StatusBadRequest && resp.StatusCode != http.StatusUnprocessableEntity {
+			return nil, err
+		}
+		if err != nil {
+			// The parameter is rejected, list the zone instead
+			c.disableNameFilter(err.Error())
+			return c.GetRecordsByName(zoneID, name)
+		}
+		all = append(all, recordsResp.Records...)
+		if c.PageSize <= 0 || page >= recordsResp.Meta.Pagination.LastPage {
+			break
+		}
+	}
+
+	// An API ignoring the parameter returns the whole zone, which was fetched completely anyway
+	if slices.ContainsFunc(all, func(record DNSRecord) bool { return !match(record) }) {
+		c.disableNameFilter("records of other names were returned")
+		return slices.DeleteFunc(all, func(record DNSRecord) bool { return !match(record) }), nil
+	}
+	return all, nil
+}
+
+// disableNameFilter makes later lookups list the zone as the API does not filter by name
+func (c *Client) disableNameFilter(reason string) {
+	if !c.filterUnsupported.Swap(true) {
+		log.Printf("The API does not filter records by name (%s), listing zones instead", reason)
+	}
+}
+
+// zoneRecords returns the records of the zone needed to update recordName:
+// all of them, or those of the name and their ownership markers if the
+// provider filters by name
+func zoneRecords(provider Provider, zoneID, recordName string) ([]DNSRecord, error) {
+	client, ok := provider.(*Client)
+	if !ok || !client.FilterByName || client.filterUnsupported.Load() {
+		return provider.GetAllRecords(zoneID)
+	}
+
+	records, err := client.GetRecordsByName(zoneID, recordName)
+	if err != nil {
+		return nil, err
+	}
+	var types []string
+	for _, record := range records {
+		if !slices.Contains(types, record.Type) {
+			types = append(types, record.Type)
+		}
+	}
+	for _, recordType := range types {
+		markers, err := client.GetRecordsByName(zoneID, ownershipRecordName(recordName, recordType))
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, markers...)
+	}
+	return records, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFilterRecords(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "1", Type: "A", Name: "home", Value: "1.2.3.4"},
+		{ID: "2", Type: "AAAA", Name: "home", Value: "2001:db8::1"},
+		{ID: "3", Type: "A", Name: "nas.home", Value: "1.2.3.4"},
+		{ID: "4", Type: "TXT", Name: "_dyndns-a.home", Value: "marker"},
+	}
+
+	tests := []struct {
+		name     string
+		filter   RecordFilter
+		expected string
+	}{
+		{"no filter", RecordFilter{}, "1234"},
+		{"by type", RecordFilter{Type: "a"}, "13"},
+		{"by name prefix", RecordFilter{NamePrefix: "nas."}, "3"},
+		{"by value", RecordFilter{Value: "1.2.3.4"}, "13"},
+		{"combined", RecordFilter{Type: "A", NamePrefix: "home", Value: "1.2.3.4"}, "1"},
+		{"no match", RecordFilter{Type: "MX"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids := ""
+			for _, record := range FilterRecords(records, tt.filter) {
+				ids += record.ID
+			}
+			if ids != tt.expected {
+				t.Errorf("Expected records %q, got %q", tt.expected, ids)
+			}
+		})
+	}
+}
+
+func TestGetRecordsByName(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "1", Type: "A", Name: "home", Value: "203.0.113.1"},
+		{ID: "2", Type: "AAAA", Name: "home", Value: "2001:db8::1"},
+		{ID: "3", Type: "A", Name: "nas", Value: "203.0.113.2"},
+	}
+
+	tests := []struct {
+		name              string
+		api               string
+		expectUnsupported bool
+	}{
+		{name: "filtering API", api: "filter"},
+		{name: "API ignoring the filter", api: "ignore", expectUnsupported: true},
+		{name: "API rejecting the filter", api: "reject", expectUnsupported: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var queries []string
+			mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				queries = append(queries, r.URL.RawQuery)
+				name := r.URL.Query().Get("name")
+				switch {
+				case name != "" && tt.api == "reject":
+					w.WriteHeader(http.StatusBadRequest)
+					json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{"message": "unknown parameter name", "code": 400}})
+				case name != "" && tt.api == "filter":
+					json.NewEncoder(w).Encode(RecordsResponse{Records: FilterRecords(records, RecordFilter{NamePrefix: name})})
+				default:
+					json.NewEncoder(w).Encode(RecordsResponse{Records: records})
+				}
+			}))
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			client.FilterByName = true
+
+			for i := 0; i < 2; i++ {
+				found, err := client.GetRecordsByName("zone1", "home")
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if len(found) != 2 || found[0].ID != "1" || found[1].ID != "2" {
+					t.Errorf("Expected the records of home, got %+v", found)
+				}
+			}
+			if client.filterUnsupported.Load() != tt.expectUnsupported {
+				t.Errorf("Expected unsupported %v, got %v", tt.expectUnsupported, client.filterUnsupported.Load())
+			}
+			// Once the filter is known to be unsupported the zone is listed right away
+			if last := queries[len(queries)-1]; strings.Contains(last, "name=") == tt.expectUnsupported {
+				t.Errorf("Unexpected last query %q", last)
+			}
+		})
+	}
+}
+
+func TestZoneRecordsFilterByName(t *testing.T) {
+	fake, client := newFakeAPIServer(t,
+		DNSRecord{Type: "A", Name: "home", Value: "203.0.113.1"},
+		DNSRecord{Type: "TXT", Name: "_dyndns-a.home", Value: ownershipValue("bridge1")},
+		DNSRecord{Type: "A", Name: "nas", Value: "203.0.113.2"},
+		DNSRecord{Type: "TXT", Name: "_dyndns-a.nas", Value: ownershipValue("bridge1")},
+	)
+	client.FilterByName = true
+
+	records, err := zoneRecords(client, "zone-example-com", "home")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, record := range records {
+		names = append(names, record.Type+" "+record.Name)
+	}
+	if strings.Join(names, ";") != "A home;TXT _dyndns-a.home" {
+		t.Errorf("Expected home and its marker, got %v", names)
+	}
+	if !isOwnedRecord(records, "home", "A", "bridge1") {
+		t.Errorf("Expected the marker to be found")
+	}
+	if requests := fake.Requests(); len(requests) != 2 {
+		t.Errorf("Expected one request for the name and one for the marker, got %v", requests)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// FirewallTarget is a Hetzner Cloud firewall rule whose source follows the address of a hostname
+type FirewallTarget struct {
+	Hostname   string
+	FirewallID string
+	// Description identifies the rule within the firewall, e.g. "home"
+	Description string
+}
+
+// FirewallConfig configures the firewall rule updates
+type FirewallConfig struct {
+	Targets    []FirewallTarget
+	CloudToken string
+}
+
+// firewallRule is a rule as returned and accepted by the Hetzner Cloud API
+type firewallRule struct {
+	Direction      string   `json:"direction"`
+	Protocol       string   `json:"protocol"`
+	Port           string   `json:"port,omitempty"`
+	SourceIPs      []string `json:"source_ips"`
+	DestinationIPs []string `json:"destination_ips"`
+	Description    string   `json:"description,omitempty"`
+}
+
+// FirewallSync restricts Hetzner Cloud firewall rules to the current address of a hostname
+type FirewallSync struct {
+	targets []FirewallTarget
+	cloud   *hcloudAPI
+
+	// mu serializes the read-modify-write cycles, the API replaces all rules at once
+	mu sync.Mutex
+	wg sync.WaitGroup
+}
+
+// NewFirewallSync creates a sync for the configured targets
+func NewFirewallSync(cfg FirewallConfig) *FirewallSync {
+	return &FirewallSync{targets: cfg.Targets, cloud: newHCloudAPI(cfg.CloudToken)}
+}
+
+// Handle updates the rules tracking the changed hostname in the background
+func (f *FirewallSync) Handle(event Event) {
+	for _, target := range f.targets {
+		if target.Hostname != event.Hostname {
+			continue
+		}
+		f.wg.Add(1)
+		go func(target FirewallTarget) {
+			defer f.wg.Done()
+			changed, err := f.Update(target, event.NewValue)
+			if err != nil {
+				log.Printf("Failed to update firewall %s rule %q for %s: %v", target.FirewallID, target.Description, target.Hostname, err)
+				return
+			}
+			if changed {
+				log.Printf("Updated firewall %s rule %q to %s", target.FirewallID, target.Description, event.NewValue)
+			}
+		}(target)
+	}
+}
+
+// Wait blocks until all started updates have finished
+func (f *FirewallSync) Wait() {
+	f.wg.Wait()
+}
+
+// Update replaces the source addresses of ip's family in the target's rules
+// with ip, keeping those of the other family, and reports whether anything changed
+func (f *FirewallSync) Update(target FirewallTarget, ip string) (bool, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false, fmt.Errorf("invalid IP address %q", ip)
+	}
+	source := ip + "/128"
+	if parsed.To4() != nil {
+		source = parsed.To4().String() + "/32"
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var current struct {
+		Firewall struct {
+			Rules []firewallRule `json:"rules"`
+		} `json:"firewall"`
+	}
+	if err := f.cloud.do("GET", "/firewalls/"+url.PathEscape(target.FirewallID), nil, &current); err != nil {
+		return false, err
+	}
+
+	rules := current.Firewall.Rules
+	found, changed := false, false
+	for i, rule := range rules {
+		if rule.Description != target.Description {
+			continue
+		}
+		found = true
+
+		sources := []string{source}
+		for _, existing := range rule.SourceIPs {
+			if existing == source {
+				continue
+			}
+			if _, network, err := net.ParseCIDR(existing); err == nil && (network.IP.To4() != nil) == (parsed.To4() != nil) {
+				continue
+			}
+			sources = append(sources, existing)
+		}
+		if !sameStrings(sources, rule.SourceIPs) {
+			rules[i].SourceIPs = sources
+			changed = true
+		}
+	}
+	if !found {
+		return false, fmt.Errorf("no rule with description %q", target.Description)
+	}
+	if !changed {
+		return false, nil
+	}
+
+	body := map[string]interface{}{"rules": rules}
+	return true, f.cloud.do("POST", "/firewalls/"+url.PathEscape(target.FirewallID)+"/actions/set_rules", body, nil)
+}
+
+// sameStrings reports whether a and b contain the same strings regardless of order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	counts := make(map[string]int, len(a))
+	for _, s := range a {
+		counts[s]++
+	}
+	for _, s := range b {
+		if counts[s] == 0 {
+			return false
+		}
+		counts[s]--
+	}
+	return true
+}
+
+// parseFirewallTargets parses "hostname=firewallid:description" entries separated by commas
+func parseFirewallTargets(value string) ([]FirewallTarget, error) {
+	var targets []FirewallTarget
+	for _, entry := range splitList(value) {
+		hostname, spec, ok := strings.Cut(entry, "=")
+		id, description, ok2 := strings.Cut(spec, ":")
+		if !ok || !ok2 || hostname == "" || id == "" || description == "" {
+			return nil, fmt.Errorf("expected hostname=firewallid:description, got %q", entry)
+		}
+		targets = append(targets, FirewallTarget{Hostname: strings.ToLower(hostname), FirewallID: id, Description: description})
+	}
+	return targets, nil
+}
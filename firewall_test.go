@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func newFirewallMockAPI(t *testing.T, rules []firewallRule, sets *int) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer cloud-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/firewalls/4711":
+			json.NewEncoder(w).Encode(map[string]interface{}{"firewall": map[string]interface{}{"id": 4711, "rules": rules}})
+		case r.Method == "POST" && r.URL.Path == "/firewalls/4711/actions/set_rules":
+			var body struct {
+				Rules []firewallRule `json:"rules"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			rules = body.Rules
+			*sets++
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"actions":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestFirewallSyncUpdate(t *testing.T) {
+	initial := func() []firewallRule {
+		return []firewallRule{
+			{Direction: "in", Protocol: "tcp", Port: "22", SourceIPs: []string{"198.51.100.7/32", "2001:db8:1::/64"}, Description: "home"},
+			{Direction: "in", Protocol: "tcp", Port: "443", SourceIPs: []string{"0.0.0.0/0", "::/0"}, Description: "https"},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		description string
+		ip          string
+		expected    []string
+		changed     bool
+		expectError bool
+	}{
+		{name: "replaces IPv4 source", description: "home", ip: "203.0.113.1", expected: []string{"203.0.113.1/32", "2001:db8:1::/64"}, changed: true},
+		{name: "replaces IPv6 source", description: "home", ip: "2001:db8:2::1", expected: []string{"2001:db8:2::1/128", "198.51.100.7/32"}, changed: true},
+		{name: "unchanged address", description: "home", ip: "198.51.100.7", expected: []string{"198.51.100.7/32", "2001:db8:1::/64"}},
+		{name: "unknown rule", description: "office", ip: "203.0.113.1", expectError: true},
+		{name: "invalid address", description: "home", ip: "not-an-ip", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sets := 0
+			api := newFirewallMockAPI(t, initial(), &sets)
+			fw := NewFirewallSync(FirewallConfig{CloudToken: "cloud-token"})
+			fw.cloud.baseURL = api.URL
+
+			changed, err := fw.Update(FirewallTarget{Hostname: "home.example.com", FirewallID: "4711", Description: tt.description}, tt.ip)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if changed != tt.changed || (sets == 1) != tt.changed {
+				t.Errorf("Expected changed=%v, got %v with %d set_rules calls", tt.changed, changed, sets)
+			}
+
+			var current struct {
+				Firewall struct {
+					Rules []firewallRule `json:"rules"`
+				} `json:"firewall"`
+			}
+			if err := fw.cloud.do("GET", "/firewalls/4711", nil, &current); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			rules := current.Firewall.Rules
+			if !slices.Equal(rules[0].SourceIPs, tt.expected) {
+				t.Errorf("Expected sources %v, got %v", tt.expected, rules[0].SourceIPs)
+			}
+			if !slices.Equal(rules[1].SourceIPs, []string{"0.0.0.0/0", "::/0"}) {
+				t.Errorf("Other rules must not change, got %v", rules[1].SourceIPs)
+			}
+		})
+	}
+}
+
+func TestFirewallSyncHandle(t *testing.T) {
+	sets := 0
+	api := newFirewallMockAPI(t, []firewallRule{{Direction: "in", Protocol: "tcp", Port: "22", SourceIPs: []string{"198.51.100.7/32"}, Description: "home"}}, &sets)
+
+	targets, err := parseFirewallTargets("home.example.com=4711:home")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	fw := NewFirewallSync(FirewallConfig{Targets: targets, CloudToken: "cloud-token"})
+	fw.cloud.baseURL = api.URL
+
+	fw.Handle(Event{Kind: eventIPChange, Hostname: "other.example.com", Type: "A", NewValue: "192.0.2.1"})
+	fw.Handle(Event{Kind: eventIPChange, Hostname: "home.example.com", Type: "A", NewValue: "203.0.113.1"})
+	fw.Wait()
+
+	if sets != 1 {
+		t.Errorf("Expected one set_rules call, got %d", sets)
+	}
+}
+
+func TestParseFirewallTargets(t *testing.T) {
+	for _, value := range []string{"home.example.com", "home.example.com=4711", "home.example.com=:home", "=4711:home"} {
+		if _, err := parseFirewallTargets(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
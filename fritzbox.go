@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// FritzBox update URL placeholders and the query parameters they are usually mapped to:
+//
+//	<ipaddr>       myip          public IPv4 address
+//	<ip6addr>      myipv6        public IPv6 address of the router
+//	<ip6lanprefix> ip6lanprefix  delegated IPv6 LAN prefix, e.g. 2001:db8:1:2::/64
+//	<dualstack>    dualstack     1 for dual-stack connections, 0 otherwise
+//	<username>     username      DynDNS username
+//	<passwd>       password      DynDNS password
+
+// queryCredentials returns the credentials passed through the <username> and <passwd> placeholders
+func queryCredentials(r *http.Request) (string, string, bool) {
+	query := parse.Values(r)
+	user := query.Get("username")
+	pass := query.Get("password")
+	if pass == "" {
+		pass = query.Get("passwd")
+	}
+	if user == "" || pass == "" || parse.IsPlaceholder(user) || parse.IsPlaceholder(pass) {
+		return "", "", false
+	}
+	return user, pass, true
+}
+
+// isDualStackFlag interprets the <dualstack> placeholder value
+func isDualStackFlag(value string) bool {
+	switch strings.ToLower(value) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// applyDualStack resolves the addresses to update from the FritzBox specific
+// parameters. With an interface ID configured, the AAAA record points at the
+// LAN host built from <ip6lanprefix> instead of the router's own address. A
+// dual-stack connection updates both records, a single-stack connection
+// (dualstack=0) only the family actually in use.
+func applyDualStack(req parse.Request, interfaceID string) (parse.Request, error) {
+	if interfaceID != "" && req.IP6LanPrefix != "" {
+		ip, err := combineIPv6Prefix(req.IP6LanPrefix, interfaceID)
+		if err != nil {
+			return req, err
+		}
+		req.MyIPv6 = ip
+	}
+
+	if req.DualStack == "" {
+		return req, nil
+	}
+
+	if isDualStackFlag(req.DualStack) {
+		if req.MyIP == "" || req.MyIPv6 == "" {
+			log.Printf("Dual-stack update for %s is missing an address: myip=%s, myipv6=%s", req.Hostname, req.MyIP, req.MyIPv6)
+		}
+		return req, nil
+	}
+
+	// Single stack, prefer IPv4 if the router sent both
+	if req.MyIP != "" {
+		req.MyIPv6 = ""
+	}
+	return req, nil
+}
+
+// combineIPv6Prefix builds an address from the network part of prefix and the host part of interfaceID
+func combineIPv6Prefix(prefix, interfaceID string) (string, error) {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil || network.IP.To4() != nil {
+		return "", fmt.Errorf("invalid IPv6 LAN prefix: %s", prefix)
+	}
+
+	suffix := net.ParseIP(interfaceID)
+	if suffix == nil || suffix.To4() != nil {
+		return "", fmt.Errorf("invalid IPv6 interface ID: %s", interfaceID)
+	}
+
+	ip := make(net.IP, net.IPv6len)
+	for i := range ip {
+		ip[i] = network.IP[i]&network.Mask[i] | suffix[i]&^network.Mask[i]
+	}
+	return ip.String(), nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+func TestIsPlaceholder(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+	}{
+		{"<ipaddr>", true},
+		{"<ip6addr>", true},
+		{"1.2.3.4", false},
+		{"<>", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if result := parse.IsPlaceholder(tt.value); result != tt.expected {
+				t.Errorf("parse.IsPlaceholder(%s) = %v, expected %v", tt.value, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApplyDualStack(t *testing.T) {
+	tests := []struct {
+		name         string
+		request      parse.Request
+		interfaceID  string
+		expectedIPv4 string
+		expectedIPv6 string
+		expectError  bool
+	}{
+		{
+			name:         "no FritzBox parameters",
+			request:      parse.Request{MyIP: "1.2.3.4", MyIPv6: "2001:db8::1"},
+			expectedIPv4: "1.2.3.4",
+			expectedIPv6: "2001:db8::1",
+		},
+		{
+			name:         "dual-stack keeps both addresses",
+			request:      parse.Request{MyIP: "1.2.3.4", MyIPv6: "2001:db8::1", DualStack: "1"},
+			expectedIPv4: "1.2.3.4",
+			expectedIPv6: "2001:db8::1",
+		},
+		{
+			name:         "single stack prefers IPv4",
+			request:      parse.Request{MyIP: "1.2.3.4", MyIPv6: "2001:db8::1", DualStack: "0"},
+			expectedIPv4: "1.2.3.4",
+		},
+		{
+			name:         "single stack IPv6 only",
+			request:      parse.Request{MyIPv6: "2001:db8::1", DualStack: "0"},
+			expectedIPv6: "2001:db8::1",
+		},
+		{
+			name:         "LAN prefix with interface ID",
+			request:      parse.Request{MyIP: "1.2.3.4", MyIPv6: "2001:db8::1", IP6LanPrefix: "2001:db8:1:2::/64", DualStack: "1"},
+			interfaceID:  "::1234:56ff:fe78:9abc",
+			expectedIPv4: "1.2.3.4",
+			expectedIPv6: "2001:db8:1:2:1234:56ff:fe78:9abc",
+		},
+		{
+			name:         "LAN prefix without interface ID is ignored",
+			request:      parse.Request{MyIPv6: "2001:db8::1", IP6LanPrefix: "2001:db8:1:2::/64"},
+			expectedIPv6: "2001:db8::1",
+		},
+		{
+			name:        "invalid LAN prefix",
+			request:     parse.Request{IP6LanPrefix: "invalid"},
+			interfaceID: "::1",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := applyDualStack(tt.request, tt.interfaceID)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.MyIP != tt.expectedIPv4 || result.MyIPv6 != tt.expectedIPv6 {
+				t.Errorf("Expected %s/%s, got %s/%s", tt.expectedIPv4, tt.expectedIPv6, result.MyIP, result.MyIPv6)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateQueryCredentials(t *testing.T) {
+	client := NewClient("test-api-key")
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{"valid query credentials", "hostname=test.com&offline=yes&username=admin&password=password", http.StatusOK},
+		{"passwd parameter", "hostname=test.com&offline=yes&username=admin&passwd=password", http.StatusOK},
+		{"wrong password", "hostname=test.com&offline=yes&username=admin&password=wrong", http.StatusUnauthorized},
+		{"unsubstituted placeholders", "hostname=test.com&offline=yes&username=<username>&password=<passwd>", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/update?"+tt.query, nil)
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
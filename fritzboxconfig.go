@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// fritzBoxQRScale is the size of a QR code module in pixels
+const fritzBoxQRScale = 8
+
+// fritzBoxSettings are the values entered under Internet > Permit Access > DynDNS of a FritzBox
+type fritzBoxSettings struct {
+	UpdateURL string `json:"update_url"`
+	Domain    string `json:"domain"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+}
+
+// String formats the settings for copying them into the FritzBox one by one
+func (f fritzBoxSettings) String() string {
+	return fmt.Sprintf("DynDNS Provider: User-defined\nUpdate URL:      %s\nDomain name:     %s\nUsername:        %s\nPassword:        %s\n",
+		f.UpdateURL, f.Domain, f.Username, f.Password)
+}
+
+// fritzBoxSettings returns the settings pointing a FritzBox at the bridge served at baseURL.
+// Without a domain the first configured hostname is used.
+func (s *DynDNSServer) fritzBoxSettings(baseURL, domain string) (fritzBoxSettings, error) {
+	if domain == "" {
+		for _, hostname := range s.hostnames {
+			if !strings.Contains(hostname, "*") {
+				domain = hostname
+				break
+			}
+		}
+	}
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if domain == "" {
+		return fritzBoxSettings{}, fmt.Errorf("a hostname is required, none is configured in DYNDNS_HOSTNAMES")
+	}
+	if !s.allowsHostname(domain) {
+		return fritzBoxSettings{}, fmt.Errorf("%s is outside the zones %s", domain, strings.Join(s.zones, ", "))
+	}
+
+	updateURL := strings.TrimSuffix(baseURL, "/") + "/update?hostname=<domain>&myip=<ipaddr>&myipv6=<ip6addr>"
+	// The LAN prefix is only used to address a LAN host with a configured interface ID
+	if s.ipv6InterfaceID != "" {
+		updateURL += "&ip6lanprefix=<ip6lanprefix>"
+	}
+	updateURL += "&dualstack=<dualstack>"
+	return fritzBoxSettings{UpdateURL: updateURL, Domain: domain, Username: s.username, Password: s.password}, nil
+}
+
+// requestBaseURL returns the URL the client reached the bridge at, honoring TLS
+// terminating proxies and path prefixes
+func requestBaseURL(r *http.Request, basePath string) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	} else if proto := r.Header.Get("X-Forwarded-Proto"); proto == "https" {
+		scheme = proto
+	}
+	return scheme + "://" + r.Host + requestBasePath(r, basePath)
+}
+
+// handleFritzBoxConfig serves the FritzBox settings for the requested hostname as text,
+// JSON or, with format=png, as a QR code to scan with a phone next to the router
+func (s *DynDNSServer) handleFritzBoxConfig(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, authScopeAdmin) {
+		return
+	}
+	settings, err := s.fritzBoxSettings(requestBaseURL(r, s.basePath), r.URL.Query().Get("hostname"))
+	if err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// The response contains the password
+	w.Header().Set("Cache-Control", "no-store")
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		io.WriteString(w, settings.String())
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(settings)
+	case "png":
+		code, err := encodeQR([]byte(settings.String()))
+		if err != nil {
+			httpError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		code.WritePNG(w, fritzBoxQRScale)
+	default:
+		httpError(w, r, fmt.Sprintf("Unsupported format %q (expected text, json or png)", format), http.StatusBadRequest)
+	}
+}
+
+// runFritzBoxConfig handles "fritzbox-config <server-url> [hostname] [--qr file.png]"
+func runFritzBoxConfig(server *DynDNSServer, args []string, out io.Writer) error {
+	var positional []string
+	qrFile := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--qr" {
+			if i+1 == len(args) {
+				return fmt.Errorf("--qr requires a file name\n%s", cliUsage)
+			}
+			i++
+			qrFile = args[i]
+			continue
+		}
+		positional = append(positional, args[i])
+	}
+	if len(positional) < 1 || len(positional) > 2 {
+		return fmt.Errorf("fritzbox-config requires the URL the FritzBox reaches the bridge at\n%s", cliUsage)
+	}
+	if !strings.HasPrefix(positional[0], "http://") && !strings.HasPrefix(positional[0], "https://") {
+		return fmt.Errorf("server URL must start with http:// or https://, got %s", positional[0])
+	}
+	domain := ""
+	if len(positional) == 2 {
+		domain = positional[1]
+	}
+
+	settings, err := server.fritzBoxSettings(withBasePath(positional[0], server.basePath), domain)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, settings.String())
+	if qrFile == "" {
+		return nil
+	}
+
+	code, err := encodeQR([]byte(settings.String()))
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(qrFile)
+	if err != nil {
+		return err
+	}
+	if err := code.WritePNG(file, fritzBoxQRScale); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Wrote QR code to %s\n", qrFile)
+	return nil
+}
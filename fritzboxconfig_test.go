@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFritzBoxSettings(t *testing.T) {
+	tests := []struct {
+		name           string
+		hostnames      []string
+		zones          []string
+		interfaceID    string
+		domain         string
+		expectedURL    string
+		expectedDomain string
+		errorContains  string
+	}{
+		{
+			name:           "first configured hostname",
+			hostnames:      []string{"*.lab.example.com", "home.example.com"},
+			expectedURL:    "http://dyndns.lan:8080/update?hostname=<domain>&myip=<ipaddr>&myipv6=<ip6addr>&dualstack=<dualstack>",
+			expectedDomain: "home.example.com",
+		},
+		{
+			name:           "explicit domain with LAN prefix",
+			interfaceID:    "::1234:56ff:fe78:9abc",
+			domain:         "NAS.Example.com.",
+			expectedURL:    "http://dyndns.lan:8080/update?hostname=<domain>&myip=<ipaddr>&myipv6=<ip6addr>&ip6lanprefix=<ip6lanprefix>&dualstack=<dualstack>",
+			expectedDomain: "nas.example.com",
+		},
+		{
+			name:          "no hostname",
+			errorContains: "hostname is required",
+		},
+		{
+			name:          "outside the zones of a tenant",
+			zones:         []string{"example.org"},
+			domain:        "home.example.com",
+			errorContains: "outside the zones",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewDynDNSServer(nil, "admin", "secret", "8080")
+			server.hostnames = tt.hostnames
+			server.zones = tt.zones
+			server.ipv6InterfaceID = tt.interfaceID
+
+			settings, err := server.fritzBoxSettings("http://dyndns.lan:8080/", tt.domain)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			expected := fritzBoxSettings{UpdateURL: tt.expectedURL, Domain: tt.expectedDomain, Username: "admin", Password: "secret"}
+			if settings != expected {
+				t.Errorf("Expected %+v, got %+v", expected, settings)
+			}
+		})
+	}
+}
+
+func TestHandleFritzBoxConfig(t *testing.T) {
+	server := NewDynDNSServer(nil, "admin", "secret", "8080")
+	server.hostnames = []string{"home.example.com"}
+
+	tests := []struct {
+		name             string
+		query            string
+		https            bool
+		password         string
+		expectedStatus   int
+		expectedType     string
+		expectedContains string
+	}{
+		{name: "text", expectedStatus: 200, expectedType: "text/plain", expectedContains: "Update URL:      http://dyndns.lan/update?hostname=<domain>"},
+		{name: "behind a TLS proxy", https: true, expectedStatus: 200, expectedType: "text/plain", expectedContains: "https://dyndns.lan/update"},
+		{name: "json", query: "?format=json&hostname=nas.example.com", expectedStatus: 200, expectedType: "application/json", expectedContains: `"domain":"nas.example.com"`},
+		{name: "qr code", query: "?format=png", expectedStatus: 200, expectedType: "image/png"},
+		{name: "unknown format", query: "?format=svg", expectedStatus: 400, expectedContains: "Unsupported format"},
+		{name: "wrong password", password: "wrong", expectedStatus: 401},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://dyndns.lan/api/fritzbox"+tt.query, nil)
+			password := "secret"
+			if tt.password != "" {
+				password = tt.password
+			}
+			req.SetBasicAuth("admin", password)
+			if tt.https {
+				req.Header.Set("X-Forwarded-Proto", "https")
+			}
+			w := httptest.NewRecorder()
+			server.handleFritzBoxConfig(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedType != "" && !strings.HasPrefix(w.Header().Get("Content-Type"), tt.expectedType) {
+				t.Errorf("Expected content type %s, got %s", tt.expectedType, w.Header().Get("Content-Type"))
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedContains) {
+				t.Errorf("Expected response containing %q, got %q", tt.expectedContains, w.Body.String())
+			}
+			if tt.expectedStatus != 200 {
+				return
+			}
+			if w.Header().Get("Cache-Control") != "no-store" {
+				t.Error("Expected the settings with the password not to be cached")
+			}
+			switch tt.expectedType {
+			case "image/png":
+				if _, err := png.Decode(w.Body); err != nil {
+					t.Errorf("Invalid PNG: %v", err)
+				}
+			case "application/json":
+				var settings fritzBoxSettings
+				if err := json.Unmarshal(w.Body.Bytes(), &settings); err != nil || settings.Password != "secret" {
+					t.Errorf("Expected settings with the password, got %+v (%v)", settings, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunFritzBoxConfig(t *testing.T) {
+	server := NewDynDNSServer(nil, "admin", "secret", "8080")
+	qrFile := filepath.Join(t.TempDir(), "fritzbox.png")
+
+	tests := []struct {
+		name             string
+		args             []string
+		expectedContains []string
+		errorContains    string
+	}{
+		{
+			name:             "text",
+			args:             []string{"fritzbox-config", "https://dyndns.example.com", "home.example.com"},
+			expectedContains: []string{"Update URL:      https://dyndns.example.com/update?hostname=<domain>", "Domain name:     home.example.com", "Password:        secret"},
+		},
+		{
+			name:             "qr code",
+			args:             []string{"fritzbox-config", "--qr", qrFile, "http://192.168.178.2:8080", "home.example.com"},
+			expectedContains: []string{"Wrote QR code to " + qrFile},
+		},
+		{name: "missing server url", args: []string{"fritzbox-config"}, errorContains: "requires the URL"},
+		{name: "invalid server url", args: []string{"fritzbox-config", "dyndns.example.com", "home.example.com"}, errorContains: "must start with http"},
+		{name: "missing qr file", args: []string{"fritzbox-config", "https://dyndns.example.com", "--qr"}, errorContains: "--qr requires a file name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := runCommand(server, tt.args, &out)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, expected := range tt.expectedContains {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("Expected output containing %q, got %q", expected, out.String())
+				}
+			}
+		})
+	}
+
+	file, err := os.Open(qrFile)
+	if err != nil {
+		t.Fatalf("Expected QR code file: %v", err)
+	}
+	defer file.Close()
+	if _, err := png.Decode(file); err != nil {
+		t.Errorf("Invalid PNG: %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// The fuzz targets cover the handlers and decoders reachable from the
+// network, those of the request, address and hostname parsers are in the
+// parse package. Their seeds run with go test, fuzzing with e.g.
+//
+//	go test -run=^$ -fuzz=FuzzHandleUpdate -fuzztime=30s
+
+func FuzzHandleUpdate(f *testing.F) {
+	for _, seed := range []string{
+		"hostname=home.example.com&myip=1.2.3.4",
+		"hostname=home.example.com,nas.example.com&myip=1.2.3.4&myipv6=2001:db8::1&wildcard=ON",
+		"hostname=*.example.com&offline=YES&system=dyndns",
+		"hostname=example.org&myip=999.1.1.1",
+		"hostname=" + strings.Repeat("a.", 200) + "example.com",
+	} {
+		f.Add(seed)
+	}
+
+	fake, err := newFakeHetzner([]string{"example.com"}, "")
+	if err != nil {
+		f.Fatal(err)
+	}
+	server := NewDynDNSServer(newFakeClient(fake, "test-api-key"), "admin", "password", "8080")
+	log.SetOutput(io.Discard)
+	f.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	f.Fuzz(func(t *testing.T, query string) {
+		r := httptest.NewRequest("GET", "/update", nil)
+		r.URL.RawQuery = query
+		r.SetBasicAuth("admin", "password")
+		w := httptest.NewRecorder()
+		server.handleUpdate(w, r)
+		if w.Body.Len() == 0 {
+			t.Errorf("Update %q answered without a return code", query)
+		}
+	})
+}
+
+func FuzzParseDSResponse(f *testing.F) {
+	query, id, _ := buildDNSQuery("example.com", dnsTypeDS)
+	f.Add(query, id)
+	f.Add([]byte{0, 1, 0x81, 0x80, 0, 1, 0, 1, 0xc0, 0x0c, 0, 43}, uint16(1))
+
+	f.Fuzz(func(t *testing.T, msg []byte, id uint16) {
+		parseDSResponse(msg, id)
+	})
+}
+
+func FuzzAnswerDNS(f *testing.F) {
+	for _, name := range []string{"home.example.com", "example.com", "nas.example.org"} {
+		query, _, _ := buildDNSQuery(name, dnsTypeA)
+		f.Add(query)
+	}
+	f.Add([]byte{0, 1, 1, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0xc0, 0x0c, 0, 1, 0, 1})
+
+	fake, err := newFakeHetzner([]string{"example.com"}, "")
+	if err != nil {
+		f.Fatal(err)
+	}
+	fake.Seed("example.com", DNSRecord{Type: "A", Name: "home", Value: "203.0.113.1"}, DNSRecord{Type: "TXT", Name: "home", Value: strings.Repeat("x", 300)})
+
+	f.Fuzz(func(t *testing.T, query []byte) {
+		if response := fake.answerDNS(query); response != nil && len(response) < 12 {
+			t.Errorf("Short response %x", response)
+		}
+	})
+}
+
+func FuzzParseProto(f *testing.F) {
+	f.Add([]byte{0x0a, 0x03, 'a', 'b', 'c', 0x10, 0x01})
+	f.Add([]byte{0x0d, 0, 0, 0, 0, 0x09, 0, 0, 0, 0, 0, 0, 0, 0})
+	f.Add([]byte{0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f})
+
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		parseProto(msg)
+	})
+}
+
+func FuzzParseMQTTPublish(f *testing.F) {
+	f.Add(byte(0x30), []byte{0, 5, 't', 'o', 'p', 'i', 'c', 'x'})
+	f.Add(byte(0x32), []byte{0, 1, 't', 0, 1, 'x'})
+
+	f.Fuzz(func(t *testing.T, header byte, body []byte) {
+		parseMQTTPublish(header, body)
+	})
+}
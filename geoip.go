@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// geoIPUnknown is the country label of addresses the database holds no country for,
+// e.g. private LAN addresses
+const geoIPUnknown = "unknown"
+
+// GeoIPDatabase maps addresses to countries using a MaxMind DB file such as
+// GeoLite2-Country or GeoLite2-City. The file is reloaded when it changes, so
+// geoipupdate can replace it while the bridge is running.
+type GeoIPDatabase struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	reader  *mmdbReader
+}
+
+// OpenGeoIPDatabase opens the database at path
+func OpenGeoIPDatabase(path string) (*GeoIPDatabase, error) {
+	db := &GeoIPDatabase{path: path}
+	if err := db.reload(); err != nil {
+		return nil, err
+	}
+	return db, nil
+}
+
+// reload reads the file again if it was modified since the last load
+func (db *GeoIPDatabase) reload() error {
+	info, err := os.Stat(db.path)
+	if err != nil {
+		return fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+
+	db.mu.Lock()
+	unchanged := info.ModTime().Equal(db.modTime)
+	db.mu.Unlock()
+	if unchanged {
+		return nil
+	}
+
+	data, err := os.ReadFile(db.path)
+	if err != nil {
+		return fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+	reader, err := openMMDB(data)
+	if err != nil {
+		return fmt.Errorf("failed to read GeoIP database %s: %w", db.path, err)
+	}
+
+	db.mu.Lock()
+	db.reader = reader
+	db.modTime = info.ModTime()
+	db.mu.Unlock()
+	log.Printf("Loaded GeoIP database %s (%s)", db.path, reader.DatabaseType)
+	return nil
+}
+
+// Country returns the ISO code of the country of ip, empty if it is unknown
+func (db *GeoIPDatabase) Country(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", nil
+	}
+	if err := db.reload(); err != nil {
+		// Keep using the last successfully loaded database
+		log.Printf("Failed to reload GeoIP database %s: %v", db.path, err)
+	}
+
+	db.mu.Lock()
+	reader := db.reader
+	db.mu.Unlock()
+	value, ok, err := reader.Lookup(addr)
+	if err != nil || !ok {
+		return "", err
+	}
+	// The country of the connection, registered_country for anonymous networks without one
+	fields, _ := value.(map[string]any)
+	for _, key := range []string{"country", "registered_country"} {
+		country, _ := fields[key].(map[string]any)
+		if code, _ := country["iso_code"].(string); code != "" {
+			return code, nil
+		}
+	}
+	return "", nil
+}
+
+// Label returns the country of ip for logs and metrics, geoIPUnknown if the
+// database has none
+func (db *GeoIPDatabase) Label(ip string) string {
+	country, err := db.Country(ip)
+	if err != nil {
+		log.Printf("GeoIP lookup for %s failed: %v", ip, err)
+	}
+	if country == "" {
+		return geoIPUnknown
+	}
+	return country
+}
+
+// parseCountries parses the ISO codes of DYNDNS_GEOIP_COUNTRIES
+func parseCountries(value string) ([]string, error) {
+	var countries []string
+	for _, code := range splitList(value) {
+		code = strings.ToUpper(code)
+		if len(code) != 2 || strings.Trim(code, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+			return nil, fmt.Errorf("expected two letter ISO country codes, got %q", code)
+		}
+		countries = append(countries, code)
+	}
+	return countries, nil
+}
+
+// setGeoIP tags update requests and access log lines with their country from
+// db and rejects countries outside of countries if it is not empty
+func (s *DynDNSServer) setGeoIP(db *GeoIPDatabase, countries []string, rejectUnknown bool) {
+	s.metrics.Describe("dyndns_geoip_requests_total", "counter", "Number of update requests by GeoIP country and whether they were allowed.")
+	s.geoip, s.countries, s.rejectUnknownCountry = db, countries, rejectUnknown
+	if s.accessLog != nil {
+		s.accessLog.country = db.Label
+	}
+}
+
+// requestCountry returns the country of the connection of r, geoIPUnknown if
+// the database has none and empty without a database. Forwarding headers are
+// ignored, clients could forge them to pass the country restriction.
+func (s *DynDNSServer) requestCountry(r *http.Request) string {
+	if s.geoip == nil {
+		return ""
+	}
+	return s.geoip.Label(remoteIP(r))
+}
+
+// checkCountry counts the update requests by country and rejects those from
+// countries outside DYNDNS_GEOIP_COUNTRIES. Addresses without a country, e.g. of
+// the LAN, are allowed unless DYNDNS_GEOIP_REJECT_UNKNOWN is set.
+func (s *DynDNSServer) checkCountry(next http.HandlerFunc) http.HandlerFunc {
+	if s.geoip == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		country := s.requestCountry(r)
+		allowed := len(s.countries) == 0 || slices.Contains(s.countries, country)
+		if country == geoIPUnknown {
+			allowed = !s.rejectUnknownCountry
+		}
+		if !allowed {
+			log.Printf("Rejected update request from %s in %s", remoteIP(r), country)
+			s.metrics.Inc("dyndns_geoip_requests_total", Labels{"country": country, "result": "rejected"})
+			httpError(w, r, "Forbidden", http.StatusForbidden)
+			return
+		}
+		s.metrics.Inc("dyndns_geoip_requests_total", Labels{"country": country, "result": "allowed"})
+		next(w, r)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// writeTestGeoIP writes a database with registered_country only for 198.51.100.0/24
+func writeTestGeoIP(t *testing.T, path, country string) {
+	t.Helper()
+	file := buildTestMMDB(t, map[string]map[string]any{
+		"203.0.113.0/24":  {"country": map[string]any{"iso_code": country}, "registered_country": map[string]any{"iso_code": "US"}},
+		"198.51.100.0/24": {"registered_country": map[string]any{"iso_code": "FR"}},
+		"2001:db8::/32":   {"country": map[string]any{"iso_code": country}},
+	})
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGeoIPDatabaseCountry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+	writeTestGeoIP(t, path, "DE")
+	db, err := OpenGeoIPDatabase(path)
+	if err != nil {
+		t.Fatalf("OpenGeoIPDatabase failed: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		country string
+	}{
+		{"203.0.113.7", "DE"},
+		{"2001:db8::7", "DE"},
+		{"198.51.100.7", "FR"},
+		{"192.168.178.20", ""},
+		{"not-an-ip", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			country, err := db.Country(tt.ip)
+			if err != nil {
+				t.Fatalf("Country failed: %v", err)
+			}
+			if country != tt.country {
+				t.Errorf("Expected %q, got %q", tt.country, country)
+			}
+		})
+	}
+	if label := db.Label("192.168.178.20"); label != geoIPUnknown {
+		t.Errorf("Expected label %q, got %q", geoIPUnknown, label)
+	}
+
+	// geoipupdate replaces the file while the bridge is running
+	writeTestGeoIP(t, path, "AT")
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if country, _ := db.Country("203.0.113.7"); country != "AT" {
+		t.Errorf("Expected AT after reload, got %q", country)
+	}
+
+	// A broken replacement keeps the last database
+	if err := os.WriteFile(path, []byte("broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if country, _ := db.Country("203.0.113.7"); country != "AT" {
+		t.Errorf("Expected AT after failed reload, got %q", country)
+	}
+}
+
+func TestOpenGeoIPDatabaseInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.mmdb")
+	if _, err := OpenGeoIPDatabase(path); err == nil {
+		t.Error("Expected error for missing file")
+	}
+	if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenGeoIPDatabase(path); err == nil {
+		t.Error("Expected error for invalid file")
+	}
+}
+
+func TestParseCountries(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+		wantErr  bool
+	}{
+		{"", nil, false},
+		{"de, at,CH", []string{"DE", "AT", "CH"}, false},
+		{"DEU", nil, true},
+		{"D1", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			countries, err := parseCountries(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got %v", tt.wantErr, err)
+			}
+			if !reflect.DeepEqual(countries, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, countries)
+			}
+		})
+	}
+}
+
+func TestCheckCountry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+	writeTestGeoIP(t, path, "DE")
+	db, err := OpenGeoIPDatabase(path)
+	if err != nil {
+		t.Fatalf("OpenGeoIPDatabase failed: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		countries     []string
+		rejectUnknown bool
+		remoteAddr    string
+		forwardedFor  string
+		rejected      bool
+	}{
+		{"no allowlist", nil, false, "198.51.100.7:1234", "", false},
+		{"allowed country", []string{"DE", "AT"}, false, "203.0.113.7:1234", "", false},
+		{"other country", []string{"DE", "AT"}, false, "198.51.100.7:1234", "", true},
+		{"forged forwarding header", []string{"DE", "AT"}, false, "198.51.100.7:1234", "203.0.113.7", true},
+		{"unknown allowed", []string{"DE"}, false, "192.168.178.20:1234", "", false},
+		{"unknown rejected", []string{"DE"}, true, "192.168.178.20:1234", "", true},
+		{"unknown rejected without allowlist", nil, true, "192.168.178.20:1234", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+			server.setGeoIP(db, tt.countries, tt.rejectUnknown)
+			req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&myip=203.0.113.1", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			w := httptest.NewRecorder()
+			server.Routes().ServeHTTP(w, req)
+
+			if rejected := w.Code == http.StatusForbidden; rejected != tt.rejected {
+				t.Errorf("Expected rejected=%v, got status %d", tt.rejected, w.Code)
+			}
+			result := "allowed"
+			if tt.rejected {
+				result = "rejected"
+			}
+			country := db.Label(remoteIP(req))
+			if value := server.metrics.Value("dyndns_geoip_requests_total", Labels{"country": country, "result": result}); value != 1 {
+				t.Errorf("Expected 1 %s request from %s, got %v", result, country, value)
+			}
+		})
+	}
+
+	// Other endpoints are not restricted
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.setGeoIP(db, []string{"DE"}, true)
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.RemoteAddr = "198.51.100.7:1234"
+	w := httptest.NewRecorder()
+	server.Routes().ServeHTTP(w, req)
+	if w.Code == http.StatusForbidden {
+		t.Errorf("Expected /health to be served, got %d", w.Code)
+	}
+}
+
+func TestAccessLogCountry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+	writeTestGeoIP(t, path, "DE")
+	db, err := OpenGeoIPDatabase(path)
+	if err != nil {
+		t.Fatalf("OpenGeoIPDatabase failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.accessLog = NewAccessLogger(&out, accessLogJSON, logPrivacyOff)
+	server.setGeoIP(db, nil, false)
+	req := httptest.NewRequest("GET", "/nic/update", nil)
+	req.RemoteAddr = "203.0.113.7:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	server.Routes().ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry accessEntry
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", out.String(), err)
+	}
+	if entry.Country != "DE" {
+		t.Errorf("Expected country DE, got %+v", entry)
+	}
+}
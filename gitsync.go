@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitSyncConfig configures pulling the manifest from a Git repository
+type GitSyncConfig struct {
+	// Repo is the clone URL, e.g. https://github.com/user/dns.git or a local path
+	Repo   string
+	Branch string
+	// Path is the manifest file relative to the repository root
+	Path string
+	// Dir is the local checkout, replaced by a fresh clone if it is a checkout of another repository
+	Dir      string
+	Interval time.Duration
+	Timeout  time.Duration
+	// WebhookSecret authenticates push webhooks triggering a sync, empty disables the webhook
+	WebhookSecret string
+}
+
+// ManifestPath returns the location of the manifest in the local checkout
+func (c GitSyncConfig) ManifestPath() string {
+	return filepath.Join(c.Dir, c.Path)
+}
+
+// GitSync pulls the manifest from a Git repository and applies validated
+// changes, so the DNS setup lives in version control. It shells out to git,
+// which handles credentials through its usual helpers and SSH keys.
+type GitSync struct {
+	cfg      GitSyncConfig
+	manifest *ManifestReconciler
+	events   *EventBus
+	metrics  *Metrics
+
+	// mu serializes syncs started by the interval and webhooks
+	mu       sync.Mutex
+	revision string
+	trigger  chan struct{}
+}
+
+// NewGitSync creates a sync applying pulled changes with manifest
+func NewGitSync(server *DynDNSServer, manifest *ManifestReconciler, cfg GitSyncConfig) *GitSync {
+	server.metrics.Describe("dyndns_git_syncs_total", "counter", "Number of manifest syncs from Git by result.")
+	return &GitSync{
+		cfg:      cfg,
+		manifest: manifest,
+		events:   server.events,
+		metrics:  server.metrics,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Run syncs every interval and whenever a webhook arrives until stop is closed
+func (g *GitSync) Run(stop <-chan struct{}) {
+	var tick <-chan time.Time
+	if g.cfg.Interval > 0 {
+		ticker := time.NewTicker(g.cfg.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-g.trigger:
+		case <-stop:
+			return
+		}
+		if _, err := g.Sync(); err != nil {
+			log.Printf("Git sync: %v", err)
+		}
+	}
+}
+
+// Sync pulls the repository and, if the revision changed and the manifest is
+// valid, reconciles it. It reports whether a new revision was applied.
+func (g *GitSync) Sync() (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if err := g.pull(); err != nil {
+		g.metrics.Inc("dyndns_git_syncs_total", Labels{"result": "error"})
+		return false, err
+	}
+	revision, err := g.git(g.cfg.Dir, "rev-parse", "HEAD")
+	if err != nil {
+		g.metrics.Inc("dyndns_git_syncs_total", Labels{"result": "error"})
+		return false, err
+	}
+	if revision == g.revision {
+		g.metrics.Inc("dyndns_git_syncs_total", Labels{"result": "unchanged"})
+		return false, nil
+	}
+
+	// A broken commit must not replace the records of the last valid one
+	if _, err := loadManifest(g.cfg.ManifestPath()); err != nil {
+		g.metrics.Inc("dyndns_git_syncs_total", Labels{"result": "invalid"})
+		message := fmt.Sprintf("manifest %s at revision %.12s is invalid, keeping the last valid records: %v", g.cfg.Path, revision, err)
+		g.events.Publish(Event{Kind: eventAlert, Severity: severityWarning, Message: message, Error: err.Error()})
+		g.revision = revision
+		return false, fmt.Errorf("%s", message)
+	}
+
+	// The initial checkout is applied by the manifest's own reconcile loop
+	initial := g.revision == ""
+	log.Printf("Git sync: applying %s at revision %.12s", g.cfg.Path, revision)
+	g.revision = revision
+	g.metrics.Inc("dyndns_git_syncs_total", Labels{"result": "applied"})
+	if !initial {
+		g.manifest.Reconcile()
+	}
+	return true, nil
+}
+
+// pull updates the checkout to the head of the branch, cloning it first if needed
+func (g *GitSync) pull() error {
+	origin, err := g.origin()
+	if err != nil {
+		// Only an empty or missing directory is cloned into, other files are never deleted
+		if entries, _ := os.ReadDir(g.cfg.Dir); len(entries) > 0 {
+			return fmt.Errorf("%s is not a git checkout and not empty", g.cfg.Dir)
+		}
+		return g.clone()
+	}
+	if origin != g.cfg.Repo {
+		// It may hold local work, so it is left to the operator to remove it
+		return fmt.Errorf("%s is a checkout of %s, not %s", g.cfg.Dir, origin, g.cfg.Repo)
+	}
+	if _, err := g.git(g.cfg.Dir, "fetch", "--quiet", "--depth", "1", "origin", g.cfg.Branch); err != nil {
+		return err
+	}
+	_, err = g.git(g.cfg.Dir, "reset", "--quiet", "--hard", "FETCH_HEAD")
+	return err
+}
+
+// origin returns the remote URL of the checkout, directories inside other checkouts have none
+func (g *GitSync) origin() (string, error) {
+	if _, err := os.Stat(filepath.Join(g.cfg.Dir, ".git")); err != nil {
+		return "", err
+	}
+	return g.git(g.cfg.Dir, "remote", "get-url", "origin")
+}
+
+// clone creates a shallow checkout of the branch
+func (g *GitSync) clone() error {
+	_, err := g.git("", "clone", "--quiet", "--depth", "1", "--branch", g.cfg.Branch, g.cfg.Repo, g.cfg.Dir)
+	return err
+}
+
+// git runs a git command in dir and returns its trimmed output
+func (g *GitSync) git(dir string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.cfg.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	// Never wait for a password prompt, credentials have to come from helpers or keys
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// Trigger requests a sync without waiting for it
+func (g *GitSync) Trigger() {
+	select {
+	case g.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// ServeHTTP accepts push webhooks signed like GitHub's (X-Hub-Signature-256) or
+// carrying the secret like GitLab's (X-Gitlab-Token) and triggers a sync.
+// A webhook secret must be configured
+func (g *GitSync) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		httpError(w, r, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	if !g.verifyWebhook(r, body) {
+		httpError(w, r, "Invalid webhook signature", http.StatusUnauthorized)
+		return
+	}
+
+	log.Printf("Git sync: webhook received, syncing %s", g.cfg.Repo)
+	g.Trigger()
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// verifyWebhook checks the signature or token of a webhook request
+func (g *GitSync) verifyWebhook(r *http.Request, body []byte) bool {
+	if token := r.Header.Get("X-Gitlab-Token"); token != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(g.cfg.WebhookSecret)) == 1
+	}
+	signature, ok := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	if !ok {
+		return false
+	}
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(g.cfg.WebhookSecret))
+	mac.Write(body)
+	return hmac.Equal(expected, mac.Sum(nil))
+}
+
+// handleGitWebhook passes push webhooks to the Git sync if a webhook secret is configured
+func (s *DynDNSServer) handleGitWebhook(w http.ResponseWriter, r *http.Request) {
+	if s.gitSync == nil || s.gitSync.cfg.WebhookSecret == "" {
+		httpError(w, r, "Git sync webhook is not configured", http.StatusNotFound)
+		return
+	}
+	s.gitSync.ServeHTTP(w, r)
+}
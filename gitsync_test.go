@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// commitManifest writes the manifest to the repository at dir and commits it
+func commitManifest(t *testing.T, dir, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, "records.yaml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"add", "records.yaml"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "Update records"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %v: %s", args[0], err, output)
+		}
+	}
+}
+
+func TestGitSync(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	repo := t.TempDir()
+	if output, err := exec.Command("git", "init", "--quiet", "-b", "main", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, output)
+	}
+	commitManifest(t, repo, "records:\n  - {name: vpn.example.com, type: A, target: 203.0.113.7}\n")
+
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "vpn", Value: "203.0.113.7"}}, &writes)
+	defer mockAPI.Close()
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	var alerts []Event
+	server.events.Subscribe(func(event Event) { alerts = append(alerts, event) }, eventAlert)
+
+	cfg := GitSyncConfig{Repo: repo, Branch: "main", Path: "records.yaml", Dir: filepath.Join(t.TempDir(), "checkout"), Timeout: 10 * time.Second}
+	manifest := NewManifestReconciler(server, NewIPDetector("", ""), cfg.ManifestPath(), nil, false)
+	sync := NewGitSync(server, manifest, cfg)
+
+	steps := []struct {
+		name            string
+		commit          string
+		expectApplied   bool
+		expectError     bool
+		expectedWrites  []string
+		expectedAlerts  int
+		expectedRecords int
+	}{
+		// The initial checkout is left to the manifest's reconcile loop
+		{name: "initial clone", expectApplied: true},
+		{name: "unchanged"},
+		{
+			name:            "new revision is applied",
+			commit:          "records:\n  - {name: vpn.example.com, type: A, target: 203.0.113.7}\n  - {name: www.example.com, type: CNAME, target: vpn.example.com.}\n",
+			expectApplied:   true,
+			expectedWrites:  []string{"POST CNAME www"},
+			expectedRecords: 2,
+		},
+		{
+			name:            "invalid revision is rejected",
+			commit:          "records:\n  - {name: www.example.com, type: A, target: www}\n",
+			expectError:     true,
+			expectedWrites:  []string{"POST CNAME www"},
+			expectedAlerts:  1,
+			expectedRecords: 2,
+		},
+	}
+
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			if step.commit != "" {
+				commitManifest(t, repo, step.commit)
+			}
+			applied, err := sync.Sync()
+			if (err != nil) != step.expectError {
+				t.Fatalf("Expected error %v, got %v", step.expectError, err)
+			}
+			if applied != step.expectApplied {
+				t.Errorf("Expected applied %v, got %v", step.expectApplied, applied)
+			}
+			if !reflect.DeepEqual(writes, step.expectedWrites) {
+				t.Errorf("Expected writes %v, got %v", step.expectedWrites, writes)
+			}
+			if len(alerts) != step.expectedAlerts {
+				t.Errorf("Expected %d alerts, got %v", step.expectedAlerts, alerts)
+			}
+			if step.expectedRecords > 0 && len(manifest.Report().Records) != step.expectedRecords {
+				t.Errorf("Expected %d reconciled records, got %+v", step.expectedRecords, manifest.Report().Records)
+			}
+		})
+	}
+}
+
+func TestGitSyncKeepsForeignDirectories(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep me"), 0o644)
+
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	cfg := GitSyncConfig{Repo: t.TempDir(), Branch: "main", Path: "records.yaml", Dir: dir, Timeout: 10 * time.Second}
+	sync := NewGitSync(server, NewManifestReconciler(server, NewIPDetector("", ""), cfg.ManifestPath(), nil, false), cfg)
+
+	if _, err := sync.Sync(); err == nil || !strings.Contains(err.Error(), "not a git checkout") {
+		t.Errorf("Expected the directory to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("Expected foreign files to be kept: %v", err)
+	}
+}
+
+func TestGitSyncKeepsOtherCheckouts(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	other := t.TempDir()
+	if output, err := exec.Command("git", "init", "--quiet", "-b", "main", other).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v: %s", err, output)
+	}
+	commitManifest(t, other, "records: []\n")
+	dir := filepath.Join(t.TempDir(), "checkout")
+	if output, err := exec.Command("git", "clone", "--quiet", other, dir).CombinedOutput(); err != nil {
+		t.Fatalf("git clone failed: %v: %s", err, output)
+	}
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep me"), 0o644)
+
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	cfg := GitSyncConfig{Repo: t.TempDir(), Branch: "main", Path: "records.yaml", Dir: dir, Timeout: 10 * time.Second}
+	sync := NewGitSync(server, NewManifestReconciler(server, NewIPDetector("", ""), cfg.ManifestPath(), nil, false), cfg)
+
+	if _, err := sync.Sync(); err == nil || !strings.Contains(err.Error(), "is a checkout of") {
+		t.Errorf("Expected the other checkout to be refused, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Errorf("Expected the other checkout to be kept: %v", err)
+	}
+}
+
+func TestGitWebhook(t *testing.T) {
+	body := `{"ref":"refs/heads/main"}`
+	mac := hmac.New(sha256.New, []byte("hook-secret"))
+	mac.Write([]byte(body))
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name          string
+		secret        string
+		headers       map[string]string
+		expectedCode  int
+		expectTrigger bool
+	}{
+		{name: "GitHub signature", secret: "hook-secret", headers: map[string]string{"X-Hub-Signature-256": signature}, expectedCode: 202, expectTrigger: true},
+		{name: "GitLab token", secret: "hook-secret", headers: map[string]string{"X-Gitlab-Token": "hook-secret"}, expectedCode: 202, expectTrigger: true},
+		{name: "wrong signature", secret: "other-secret", headers: map[string]string{"X-Hub-Signature-256": signature}, expectedCode: 401},
+		{name: "unsigned", secret: "hook-secret", expectedCode: 401},
+		{name: "webhook disabled", headers: map[string]string{"X-Gitlab-Token": ""}, expectedCode: 404},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+			server.gitSync = NewGitSync(server, nil, GitSyncConfig{WebhookSecret: tt.secret})
+
+			req := httptest.NewRequest("POST", "/api/git-sync", strings.NewReader(body))
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			w := httptest.NewRecorder()
+			server.handleGitWebhook(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Errorf("Expected status %d, got %d", tt.expectedCode, w.Code)
+			}
+			if triggered := len(server.gitSync.trigger) == 1; triggered != tt.expectTrigger {
+				t.Errorf("Expected trigger %v, got %v", tt.expectTrigger, triggered)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// gRPC status codes returned by the API, see proto/dyndns.proto
+const (
+	grpcOK                 = 0
+	grpcInvalidArgument    = 3
+	grpcDeadlineExceeded   = 4
+	grpcNotFound           = 5
+	grpcPermissionDenied   = 7
+	grpcFailedPrecondition = 9
+	grpcUnimplemented      = 12
+	grpcInternal           = 13
+	grpcUnavailable        = 14
+	grpcUnauthenticated    = 16
+)
+
+// grpcServicePath prefixes the method paths of the DynDNS service
+const grpcServicePath = "/dyndns.v1.DynDNS/"
+
+// maxGRPCMessage limits the size of request messages
+const maxGRPCMessage = 64 << 10
+
+// grpcError is a failed call answered with a gRPC status code
+type grpcError struct {
+	code    int
+	message string
+}
+
+func (e *grpcError) Error() string {
+	return e.message
+}
+
+// grpcMethod handles the request message of a call and returns the response message
+type grpcMethod func(request []protoField) (protoMessage, error)
+
+// GRPCService serves the DynDNS gRPC API for programmatic integrations.
+// It speaks the gRPC wire protocol over HTTP/2 directly, the messages are
+// defined in proto/dyndns.proto.
+type GRPCService struct {
+	server *DynDNSServer
+	// reconciler runs ForceReconcile, nil if no hostnames are configured
+	reconciler *Reconciler
+	auth       tokenAuth
+	methods    map[string]grpcMethod
+}
+
+// NewGRPCService creates the gRPC API of server accepting one of tokens
+func NewGRPCService(server *DynDNSServer, reconciler *Reconciler, tokens []string) *GRPCService {
+	g := &GRPCService{server: server, reconciler: reconciler, auth: tokenAuth{tokens: tokens}}
+	g.methods = map[string]grpcMethod{
+		"Update":           g.update,
+		"GetStatus":        g.getStatus,
+		"ListManagedHosts": g.listManagedHosts,
+		"ForceReconcile":   g.forceReconcile,
+	}
+	return g
+}
+
+// ServeHTTP handles a unary gRPC call
+func (g *GRPCService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "gRPC requests only", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+
+	if _, err := g.auth.Authenticate(r); err != nil {
+		writeGRPCError(w, &grpcError{grpcUnauthenticated, err.Error()})
+		return
+	}
+	name, _ := strings.CutPrefix(r.URL.Path, grpcServicePath)
+	method, ok := g.methods[name]
+	if !ok {
+		writeGRPCError(w, &grpcError{grpcUnimplemented, "unknown method " + r.URL.Path})
+		return
+	}
+
+	request, err := readGRPCMessage(r.Body)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+	fields, err := parseProto(request)
+	if err != nil {
+		writeGRPCError(w, &grpcError{grpcInvalidArgument, err.Error()})
+		return
+	}
+	response, err := method(fields)
+	if err != nil {
+		writeGRPCError(w, err)
+		return
+	}
+
+	frame := make([]byte, 5, 5+len(response))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(response)))
+	w.Write(append(frame, response...))
+	w.Header().Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(grpcOK))
+}
+
+// readGRPCMessage reads the single length-prefixed message of a unary call
+func readGRPCMessage(body io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(body, 5+maxGRPCMessage+1))
+	if err != nil {
+		return nil, &grpcError{grpcInternal, err.Error()}
+	}
+	if len(data) < 5 || int(binary.BigEndian.Uint32(data[1:])) != len(data)-5 {
+		return nil, &grpcError{grpcInvalidArgument, "expected a single length-prefixed message"}
+	}
+	if data[0] != 0 {
+		return nil, &grpcError{grpcUnimplemented, "compressed messages are not supported"}
+	}
+	return data[5:], nil
+}
+
+// writeGRPCError answers a call with the status of err in a trailers-only response
+func writeGRPCError(w http.ResponseWriter, err error) {
+	status := &grpcError{grpcInternal, err.Error()}
+	errors.As(err, &status)
+	w.Header().Set("Grpc-Status", strconv.Itoa(status.code))
+	w.Header().Set("Grpc-Message", url.PathEscape(status.message))
+	w.WriteHeader(http.StatusOK)
+}
+
+// update sets the records of a hostname like a dyndns2 update
+func (g *GRPCService) update(request []protoField) (protoMessage, error) {
+	var hostname, ipv4, ipv6 string
+	for _, field := range request {
+		switch field.Number {
+		case 1:
+			hostname = parse.NormalizeHostname(string(field.Data))
+		case 2:
+			ipv4 = string(field.Data)
+		case 3:
+			ipv6 = string(field.Data)
+		}
+	}
+	switch {
+	case hostname == "":
+		return nil, &grpcError{grpcInvalidArgument, "hostname is required"}
+	case ipv4 == "" && ipv6 == "":
+		return nil, &grpcError{grpcInvalidArgument, "ipv4 or ipv6 is required"}
+	case ipv4 != "" && !parse.IsIPv4(ipv4):
+		return nil, &grpcError{grpcInvalidArgument, "invalid IPv4 address"}
+	case ipv6 != "" && !parse.IsIPv6(ipv6):
+		return nil, &grpcError{grpcInvalidArgument, "invalid IPv6 address"}
+	}
+	if err := parse.CheckFQDN(hostname); err != nil {
+		return nil, &grpcError{grpcInvalidArgument, err.Error()}
+	}
+	if !g.server.allowsHostname(hostname) {
+		return nil, &grpcError{grpcPermissionDenied, hostname + " is outside the zones of the bridge"}
+	}
+	// Token clients are no round-robin source, DS-Lite hostnames are skipped by updateTargets
+	if g.server.isRoundRobin(hostname) {
+		return nil, &grpcError{grpcFailedPrecondition, hostname + ": " + errRoundRobin.Error()}
+	}
+	for _, ip := range []string{ipv4, ipv6} {
+		if err := g.server.checkPublicIP(ip); err != nil {
+			return nil, &grpcError{grpcInvalidArgument, "rejected address: " + err.Error()}
+		}
+	}
+
+	log.Printf("gRPC update request: hostname=%s, ipv4=%s, ipv6=%s", hostname, ipv4, ipv6)
+	unchanged, err := g.server.withDeadline(hostname, func() (bool, error) {
+		unchanged, _, err := g.server.updateTargets([]string{hostname}, ipv4, ipv6)
+		return unchanged, err
+	})
+	if errors.Is(err, errNoZone) {
+		return nil, &grpcError{grpcNotFound, err.Error()}
+	}
+	if errors.Is(err, errUpdateTimeout) {
+		return nil, &grpcError{grpcDeadlineExceeded, err.Error()}
+	}
+	if err != nil {
+		return nil, &grpcError{grpcUnavailable, err.Error()}
+	}
+
+	code := "good"
+	if unchanged {
+		code = "nochg"
+	}
+	return protoMessage(nil).String(1, code).String(2, hostname).String(3, ipv4).String(4, ipv6), nil
+}
+
+// getStatus returns the state reported by /api/status
+func (g *GRPCService) getStatus([]protoField) (protoMessage, error) {
+	status := g.server.currentStatus()
+	response := protoMessage(nil).
+		String(1, status.Status).
+		Int(2, int64(status.QueueDepth)).
+		Int(3, int64(status.Errors))
+	for _, record := range status.Records {
+		encoded := protoMessage(nil).
+			String(1, record.Hostname).
+			String(2, record.Type).
+			String(3, record.Value).
+			String(4, record.State).
+			String(5, record.LastError).
+			Int(6, int64(record.Updates)).
+			Int(7, int64(record.Failures))
+		response = response.Bytes(4, encoded)
+	}
+	return response, nil
+}
+
+// listManagedHosts returns the configured hostnames and those updated since startup
+func (g *GRPCService) listManagedHosts([]protoField) (protoMessage, error) {
+	hostnames := slices.Clone(g.server.hostnames)
+	for _, record := range g.server.status.Snapshot() {
+		hostnames = append(hostnames, record.Hostname)
+	}
+	slices.Sort(hostnames)
+
+	var response protoMessage
+	for _, hostname := range slices.Compact(hostnames) {
+		response = response.String(1, hostname)
+	}
+	return response, nil
+}
+
+// forceReconcile corrects drifted records of the configured hostnames immediately
+func (g *GRPCService) forceReconcile([]protoField) (protoMessage, error) {
+	if g.reconciler == nil {
+		return nil, &grpcError{grpcFailedPrecondition, "reconciliation requires DYNDNS_HOSTNAMES"}
+	}
+	corrected := g.reconciler.Reconcile()
+	return protoMessage(nil).Int(1, int64(corrected)), nil
+}
+
+// ListenAndServe serves the API on port, over TLS if cert is set and as
+// unencrypted HTTP/2 (h2c) otherwise
+func (g *GRPCService) ListenAndServe(port, cert, key string) error {
+	server := &http.Server{Addr: ":" + port, Handler: g, Protocols: new(http.Protocols)}
+	server.Protocols.SetHTTP2(true)
+	if cert != "" {
+		return server.ListenAndServeTLS(cert, key)
+	}
+	server.Protocols.SetUnencryptedHTTP2(true)
+	return server.ListenAndServe()
+}
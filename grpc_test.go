@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// callGRPC sends a unary call to the gRPC test server and returns the status code and response message
+func callGRPC(t *testing.T, server *httptest.Server, method, token string, request protoMessage) (int, []protoField) {
+	t.Helper()
+	frame := make([]byte, 5, 5+len(request))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(request)))
+
+	req, _ := http.NewRequest("POST", server.URL+grpcServicePath+method, bytes.NewReader(append(frame, request...)))
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("gRPC call failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("Expected HTTP/2, got %s", resp.Proto)
+	}
+	body, _ := io.ReadAll(resp.Body)
+
+	status := resp.Header.Get("Grpc-Status")
+	if status == "" {
+		status = resp.Trailer.Get("Grpc-Status")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		t.Fatalf("Missing grpc-status, got %q", status)
+	}
+	if code != grpcOK {
+		return code, nil
+	}
+	if len(body) < 5 || int(binary.BigEndian.Uint32(body[1:])) != len(body)-5 {
+		t.Fatalf("Invalid response frame %x", body)
+	}
+	fields, err := parseProto(body[5:])
+	if err != nil {
+		t.Fatalf("Invalid response message: %v", err)
+	}
+	return code, fields
+}
+
+// newGRPCTestServer serves the gRPC API of a bridge using mockAPI over HTTP/2
+func newGRPCTestServer(t *testing.T, mockAPI *httptest.Server) (*DynDNSServer, *httptest.Server) {
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	return server, serveGRPC(t, server)
+}
+
+// serveGRPC serves the gRPC API of server over HTTP/2 with the token grpc-token
+func serveGRPC(t *testing.T, server *DynDNSServer) *httptest.Server {
+	grpcServer := httptest.NewUnstartedServer(NewGRPCService(server, nil, []string{"grpc-token"}))
+	grpcServer.EnableHTTP2 = true
+	grpcServer.StartTLS()
+	t.Cleanup(grpcServer.Close)
+	return grpcServer
+}
+
+func TestGRPCUpdate(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		request        protoMessage
+		expectedCode   int
+		expectedResult string
+		expectedWrites []string
+	}{
+		{
+			name:           "update creates record",
+			token:          "grpc-token",
+			request:        protoMessage(nil).String(1, "home.example.com").String(2, "203.0.113.7"),
+			expectedCode:   grpcOK,
+			expectedResult: "good",
+			expectedWrites: []string{"POST A home"},
+		},
+		{
+			name:         "missing token",
+			request:      protoMessage(nil).String(1, "home.example.com").String(2, "203.0.113.7"),
+			expectedCode: grpcUnauthenticated,
+		},
+		{
+			name:         "wrong token",
+			token:        "other",
+			request:      protoMessage(nil).String(1, "home.example.com").String(2, "203.0.113.7"),
+			expectedCode: grpcUnauthenticated,
+		},
+		{
+			name:         "invalid address",
+			token:        "grpc-token",
+			request:      protoMessage(nil).String(1, "home.example.com").String(2, "not-an-ip"),
+			expectedCode: grpcInvalidArgument,
+		},
+		{
+			name:         "missing hostname",
+			token:        "grpc-token",
+			request:      protoMessage(nil).String(2, "203.0.113.7"),
+			expectedCode: grpcInvalidArgument,
+		},
+		{
+			name:         "invalid hostname",
+			token:        "grpc-token",
+			request:      protoMessage(nil).String(1, "home..example.com").String(2, "203.0.113.7"),
+			expectedCode: grpcInvalidArgument,
+		},
+		{
+			name:         "outside the zones",
+			token:        "grpc-token",
+			request:      protoMessage(nil).String(1, "office.example.com").String(2, "203.0.113.7"),
+			expectedCode: grpcPermissionDenied,
+		},
+		{
+			name:         "unknown zone",
+			token:        "grpc-token",
+			request:      protoMessage(nil).String(1, "home.example.org").String(2, "203.0.113.7"),
+			expectedCode: grpcNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, nil, &writes)
+			defer mockAPI.Close()
+			server, grpcServer := newGRPCTestServer(t, mockAPI)
+			server.zones = []string{"home.example.com", "*.example.org"}
+
+			code, fields := callGRPC(t, grpcServer, "Update", tt.token, tt.request)
+			if code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d", tt.expectedCode, code)
+			}
+			if tt.expectedResult != "" && (len(fields) == 0 || string(fields[0].Data) != tt.expectedResult) {
+				t.Errorf("Expected result %s, got %+v", tt.expectedResult, fields)
+			}
+			if len(writes) != len(tt.expectedWrites) {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+		})
+	}
+}
+
+func TestGRPCStatusAndHosts(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+	server, grpcServer := newGRPCTestServer(t, mockAPI)
+	server.hostnames = []string{"nas.example.com", "home.example.com"}
+	server.status.Record("home.example.com", "A", "203.0.113.7", recordStateOK, nil)
+
+	code, fields := callGRPC(t, grpcServer, "GetStatus", "grpc-token", nil)
+	if code != grpcOK {
+		t.Fatalf("Expected GetStatus to succeed, got %d", code)
+	}
+	var records int
+	for _, field := range fields {
+		switch field.Number {
+		case 1:
+			if string(field.Data) != "ok" {
+				t.Errorf("Expected status ok, got %s", field.Data)
+			}
+		case 4:
+			records++
+			record, _ := parseProto(field.Data)
+			if len(record) < 4 || string(record[0].Data) != "home.example.com" || string(record[2].Data) != "203.0.113.7" {
+				t.Errorf("Unexpected record %+v", record)
+			}
+		}
+	}
+	if records != 1 {
+		t.Errorf("Expected 1 record, got %d", records)
+	}
+
+	_, fields = callGRPC(t, grpcServer, "ListManagedHosts", "grpc-token", nil)
+	var hostnames []string
+	for _, field := range fields {
+		hostnames = append(hostnames, string(field.Data))
+	}
+	if len(hostnames) != 2 || hostnames[0] != "home.example.com" || hostnames[1] != "nas.example.com" {
+		t.Errorf("Expected sorted unique hostnames, got %v", hostnames)
+	}
+
+	if code, _ := callGRPC(t, grpcServer, "ForceReconcile", "grpc-token", nil); code != grpcFailedPrecondition {
+		t.Errorf("Expected ForceReconcile without reconciler to fail with %d, got %d", grpcFailedPrecondition, code)
+	}
+	if code, _ := callGRPC(t, grpcServer, "Delete", "grpc-token", nil); code != grpcUnimplemented {
+		t.Errorf("Expected unknown method to be unimplemented, got %d", code)
+	}
+}
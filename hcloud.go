@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HCloudBaseURL is the base URL of the Hetzner Cloud API
+const HCloudBaseURL = "https://api.hetzner.cloud/v1"
+
+// hcloudAPI is a minimal Hetzner Cloud API client for the server side integrations
+type hcloudAPI struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// newHCloudAPI creates a client authenticating with a project API token
+func newHCloudAPI(token string) *hcloudAPI {
+	return &hcloudAPI{baseURL: HCloudBaseURL, token: token, client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// do sends body as JSON to path and decodes the response into out unless it is nil
+func (a *hcloudAPI) do(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, a.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkDeliveryStatus(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyBucket stores the value changes of each record
+const historyBucket = "history"
+
+// defaultHistoryLimit is the number of changes kept per record
+const defaultHistoryLimit = 100
+
+// Changes applied later than historyClientTTL after the request, e.g. after a
+// long maintenance, are not attributed to its client, and at most
+// historyClientLimit hostnames are remembered
+const (
+	historyClientTTL   = 24 * time.Hour
+	historyClientLimit = 10000
+)
+
+// Background components changing records without a client request
+const (
+	historySystemFailover   = "failover"
+	historySystemJanitor    = "janitor"
+	historySystemReconciler = "reconciler"
+	historySystemDocker     = "docker"
+	historySystemKubernetes = "kubernetes"
+	historySystemTelegram   = "telegram"
+	historySystemTR064      = "tr064"
+	historySystemMQTT       = "mqtt"
+	historySystemManifest   = "manifest"
+)
+
+// historyClient is the client whose request triggered a change, or the
+// background component that made it
+type historyClient struct {
+	User    string `json:"user,omitempty"`
+	Device  string `json:"device,omitempty"`
+	Address string `json:"address,omitempty"`
+	System  string `json:"system,omitempty"`
+}
+
+// historyEntry is a change of a record value
+type historyEntry struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+	// OldValue is empty for created records
+	OldValue string `json:"old_value,omitempty"`
+	NewValue string `json:"new_value"`
+	// Diff marks the changed part, e.g. 203.0.113.{7 -> 9}
+	Diff string `json:"diff"`
+	// CommonPrefix is the number of leading bits both addresses share, e.g. 56
+	// when the provider assigned a new /56 prefix
+	CommonPrefix int            `json:"common_prefix_bits,omitempty"`
+	Client       *historyClient `json:"client,omitempty"`
+}
+
+// historyResponse is returned by GET /api/history
+type historyResponse struct {
+	Hostname string         `json:"hostname"`
+	Entries  []historyEntry `json:"entries"`
+}
+
+// historyTracker keeps the client of the latest request per hostname, so
+// changes applied later, e.g. from the rate limiter queue, are attributed to it
+type historyTracker struct {
+	// limit is the number of changes kept per record, zero disables the history
+	limit int
+
+	now     func() time.Time
+	mu      sync.Mutex
+	clients map[string]requestClient
+}
+
+// requestClient is a remembered client and the time of its request
+type requestClient struct {
+	client historyClient
+	at     time.Time
+}
+
+// newHistoryTracker creates a tracker keeping limit changes per record
+func newHistoryTracker(limit int) *historyTracker {
+	return &historyTracker{limit: limit, now: time.Now, clients: make(map[string]requestClient)}
+}
+
+// SetClient remembers the client of the latest request changing hostname,
+// forgetting expired clients and the oldest beyond historyClientLimit
+func (h *historyTracker) SetClient(hostname string, annotation recordAnnotation) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	h.clients[hostname] = requestClient{
+		client: historyClient{User: annotation.CreatedBy, Device: annotation.Device, Address: annotation.ClientIP},
+		at:     now,
+	}
+	for name, remembered := range h.clients {
+		if now.Sub(remembered.at) >= historyClientTTL {
+			delete(h.clients, name)
+		}
+	}
+	for len(h.clients) > historyClientLimit {
+		oldest := ""
+		for name, remembered := range h.clients {
+			if oldest == "" || remembered.at.Before(h.clients[oldest].at) {
+				oldest = name
+			}
+		}
+		delete(h.clients, oldest)
+	}
+}
+
+// client returns the client of the latest request changing hostname, nil if
+// it is unknown or expired
+func (h *historyTracker) client(hostname string) *historyClient {
+	remembered, ok := h.clients[hostname]
+	if !ok || h.now().Sub(remembered.at) >= historyClientTTL {
+		return nil
+	}
+	return &remembered.client
+}
+
+// changedBy returns whom a change by write is attributed to: the background
+// component that submitted it, or else the client of the latest request
+func (s *DynDNSServer) changedBy(write parkedWrite) *historyClient {
+	if write.System != "" {
+		return &historyClient{System: write.System}
+	}
+	s.history.mu.Lock()
+	defer s.history.mu.Unlock()
+	return s.history.client(write.Hostname)
+}
+
+// recordChange appends the change of hostname's recordType from oldValue to
+// newValue made by client to the history in the store, dropping the oldest
+// changes beyond the limit
+func (s *DynDNSServer) recordChange(hostname, recordType, oldValue, newValue string, client *historyClient) {
+	h := s.history
+	if h.limit <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	key := hostname + "/" + recordType
+	var entries []historyEntry
+	if data, ok, err := s.store.Get(historyBucket, key); err == nil && ok {
+		json.Unmarshal(data, &entries)
+	}
+	entries = append(entries, historyEntry{
+		Time:         s.clock.Now().UTC(),
+		Type:         recordType,
+		OldValue:     oldValue,
+		NewValue:     newValue,
+		Diff:         diffValues(oldValue, newValue),
+		CommonPrefix: commonPrefixBits(oldValue, newValue),
+		Client:       client,
+	})
+	if len(entries) > h.limit {
+		entries = entries[len(entries)-h.limit:]
+	}
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	if err := s.store.Put(historyBucket, key, data); err != nil {
+		log.Printf("Failed to store history of %s %s: %v", hostname, recordType, err)
+	}
+}
+
+// recordHistory returns the changes of all records of hostname, or only those
+// of recordType if it is set, oldest first
+func (s *DynDNSServer) recordHistory(hostname, recordType string) ([]historyEntry, error) {
+	stored, err := s.store.List(historyBucket)
+	if err != nil {
+		return nil, err
+	}
+	entries := []historyEntry{}
+	for key, data := range stored {
+		name, keyType, _ := strings.Cut(key, "/")
+		if name != hostname || (recordType != "" && keyType != recordType) {
+			continue
+		}
+		var recordEntries []historyEntry
+		if err := json.Unmarshal(data, &recordEntries); err != nil {
+			return nil, err
+		}
+		entries = append(entries, recordEntries...)
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, nil
+}
+
+// diffValues marks the part of newValue that differs from oldValue, keeping
+// the common leading labels or groups of an address
+func diffValues(oldValue, newValue string) string {
+	if oldValue == "" {
+		return "+" + newValue
+	}
+	if newValue == "" {
+		return "-" + oldValue
+	}
+	common := 0
+	for i := 0; i < len(oldValue) && i < len(newValue) && oldValue[i] == newValue[i]; i++ {
+		if oldValue[i] == '.' || oldValue[i] == ':' {
+			common = i + 1
+		}
+	}
+	return newValue[:common] + "{" + oldValue[common:] + " -> " + newValue[common:] + "}"
+}
+
+// commonPrefixBits returns the number of leading bits two addresses of the same
+// family share, zero if they are not addresses of the same family
+func commonPrefixBits(oldValue, newValue string) int {
+	oldIP, newIP := net.ParseIP(oldValue), net.ParseIP(newValue)
+	if oldIP == nil || newIP == nil || (oldIP.To4() == nil) != (newIP.To4() == nil) {
+		return 0
+	}
+	if oldIP.To4() != nil {
+		oldIP, newIP = oldIP.To4(), newIP.To4()
+	}
+	bits := 0
+	for i := range oldIP {
+		diff := oldIP[i] ^ newIP[i]
+		for mask := byte(0x80); mask > 0; mask >>= 1 {
+			if diff&mask != 0 {
+				return bits
+			}
+			bits++
+		}
+	}
+	return bits
+}
+
+// handleHistory serves GET /api/history?hostname=, the timeline of value
+// changes of a hostname's records
+func (s *DynDNSServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, authScopeAdmin) {
+		return
+	}
+
+	hostname := strings.ToLower(r.URL.Query().Get("hostname"))
+	if hostname == "" {
+		httpErrorDetails(w, r, "Missing hostname parameter", http.StatusBadRequest, map[string]string{"parameter": "hostname"})
+		return
+	}
+
+	entries, err := s.recordHistory(hostname, strings.ToUpper(r.URL.Query().Get("type")))
+	if err != nil {
+		log.Printf("Failed to load history of %s: %v", hostname, err)
+		httpError(w, r, "Failed to load history", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(historyResponse{Hostname: hostname, Entries: entries})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDiffValues(t *testing.T) {
+	tests := []struct {
+		oldValue string
+		newValue string
+		expected string
+	}{
+		{"", "203.0.113.7", "+203.0.113.7"},
+		{"203.0.113.7", "203.0.113.9", "203.0.113.{7 -> 9}"},
+		{"203.0.113.7", "203.0.113.71", "203.0.113.{7 -> 71}"},
+		{"198.51.100.1", "203.0.113.1", "{198.51.100.1 -> 203.0.113.1}"},
+		{"2001:db8:1:a::1", "2001:db8:1:b::1", "2001:db8:1:{a::1 -> b::1}"},
+	}
+
+	for _, tt := range tests {
+		if diff := diffValues(tt.oldValue, tt.newValue); diff != tt.expected {
+			t.Errorf("diffValues(%q, %q) = %q, expected %q", tt.oldValue, tt.newValue, diff, tt.expected)
+		}
+	}
+}
+
+func TestCommonPrefixBits(t *testing.T) {
+	tests := []struct {
+		oldValue string
+		newValue string
+		expected int
+	}{
+		{"203.0.113.7", "203.0.113.7", 32},
+		{"203.0.113.7", "203.0.113.6", 31},
+		{"203.0.113.7", "75.0.113.7", 0},
+		{"2001:db8:1:a::1", "2001:db8:1:b::1", 63},
+		{"2001:db8:1:a::1", "2001:db8:1:a::2", 126},
+		{"203.0.113.7", "2001:db8::1", 0},
+		{"", "203.0.113.7", 0},
+	}
+
+	for _, tt := range tests {
+		if bits := commonPrefixBits(tt.oldValue, tt.newValue); bits != tt.expected {
+			t.Errorf("commonPrefixBits(%q, %q) = %d, expected %d", tt.oldValue, tt.newValue, bits, tt.expected)
+		}
+	}
+}
+
+func TestHandleHistory(t *testing.T) {
+	_, client := newFakeAPIServer(t)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	for _, query := range []string{
+		"myip=203.0.113.7",
+		"myip=203.0.113.7&myipv6=2001:db8:1:a::1",
+		"myip=203.0.113.9&myipv6=2001:db8:1:a::1",
+	} {
+		req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&"+query, nil)
+		req.SetBasicAuth("admin", "password")
+		req.Header.Set("User-Agent", "Fritz!Box")
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		server.handleUpdate(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Update %s failed: %d %s", query, w.Code, w.Body.String())
+		}
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedDiffs  []string
+	}{
+		{
+			name:           "all record types",
+			query:          "hostname=HOME.example.com",
+			expectedStatus: http.StatusOK,
+			expectedDiffs:  []string{"+203.0.113.7", "+2001:db8:1:a::1", "203.0.113.{7 -> 9}"},
+		},
+		{
+			name:           "one record type",
+			query:          "hostname=home.example.com&type=a",
+			expectedStatus: http.StatusOK,
+			expectedDiffs:  []string{"+203.0.113.7", "203.0.113.{7 -> 9}"},
+		},
+		{
+			name:           "unknown hostname",
+			query:          "hostname=nas.example.com",
+			expectedStatus: http.StatusOK,
+			expectedDiffs:  []string{},
+		},
+		{
+			name:           "missing hostname",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/history?"+tt.query, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleHistory(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+			var response historyResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			diffs := []string{}
+			for _, entry := range response.Entries {
+				diffs = append(diffs, entry.Diff)
+			}
+			if len(diffs) != len(tt.expectedDiffs) {
+				t.Fatalf("Expected diffs %v, got %v", tt.expectedDiffs, diffs)
+			}
+			for i := range diffs {
+				if diffs[i] != tt.expectedDiffs[i] {
+					t.Errorf("Expected diffs %v, got %v", tt.expectedDiffs, diffs)
+					break
+				}
+			}
+			if len(response.Entries) == 0 {
+				return
+			}
+			last := response.Entries[len(response.Entries)-1]
+			if last.OldValue != "203.0.113.7" || last.CommonPrefix != 28 {
+				t.Errorf("Unexpected last change: %+v", last)
+			}
+			if last.Client == nil || *last.Client != (historyClient{User: "admin", Device: agentName("Fritz!Box"), Address: "192.0.2.1"}) {
+				t.Errorf("Expected the updating client, got %+v", last.Client)
+			}
+		})
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.history.limit = 2
+	for _, value := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+		server.recordChange("home.example.com", "A", "", value, nil)
+	}
+	entries, err := server.recordHistory("home.example.com", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].NewValue != "203.0.113.2" {
+		t.Errorf("Expected the two latest changes, got %+v", entries)
+	}
+
+	server.history.limit = 0
+	server.recordChange("nas.example.com", "A", "", "203.0.113.1", nil)
+	if entries, _ := server.recordHistory("nas.example.com", ""); len(entries) != 0 {
+		t.Errorf("Expected no history with a limit of zero, got %+v", entries)
+	}
+}
+
+func TestHistoryClients(t *testing.T) {
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := newFakeClock(start)
+	history := newHistoryTracker(defaultHistoryLimit)
+	history.now = clock.Now
+
+	history.SetClient("home.example.com", recordAnnotation{CreatedBy: "admin"})
+	clock.Advance(historyClientTTL)
+	if client := history.client("home.example.com"); client != nil {
+		t.Errorf("Expected the client to expire, got %+v", client)
+	}
+
+	// The oldest clients are forgotten beyond the limit
+	for i := 0; i <= historyClientLimit; i++ {
+		history.SetClient(fmt.Sprintf("host%d.example.com", i), recordAnnotation{CreatedBy: "admin"})
+		clock.Advance(time.Millisecond)
+	}
+	if len(history.clients) != historyClientLimit {
+		t.Errorf("Expected %d remembered clients, got %d", historyClientLimit, len(history.clients))
+	}
+	if history.client("host0.example.com") != nil || history.client(fmt.Sprintf("host%d.example.com", historyClientLimit)) == nil {
+		t.Error("Expected the oldest client to be forgotten")
+	}
+}
+
+func TestHistoryBackgroundChanges(t *testing.T) {
+	_, client := newFakeAPIServer(t)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.history.SetClient("home.example.com", recordAnnotation{CreatedBy: "admin", ClientIP: "192.0.2.1"})
+
+	if err := server.updateDNSRecord("home.example.com", "203.0.113.7", "A"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := server.submit(parkedWrite{Hostname: "home.example.com", Type: "A", Value: "203.0.113.9", Failover: true, System: historySystemFailover}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	entries, err := server.recordHistory("home.example.com", "A")
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("Expected two changes, got %+v %v", entries, err)
+	}
+	if entries[0].Client == nil || entries[0].Client.User != "admin" {
+		t.Errorf("Expected the request to be attributed to its client, got %+v", entries[0].Client)
+	}
+	if entries[1].Client == nil || *entries[1].Client != (historyClient{System: historySystemFailover}) {
+		t.Errorf("Expected the failover to be attributed to the system, got %+v", entries[1].Client)
+	}
+}
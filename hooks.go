@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultHookEvents are the event kinds hooks run for unless DYNDNS_HOOK_EVENTS is set
+var defaultHookEvents = []string{eventIPChange, eventRecordCreated}
+
+// ExecHook runs an external command for events, passing the event as JSON on
+// stdin and its main fields as DYNDNS_* environment variables
+type ExecHook struct {
+	command []string
+	timeout time.Duration
+
+	// mu serializes runs so a slow hook sees events in order
+	mu sync.Mutex
+	wg sync.WaitGroup
+}
+
+// NewExecHook creates a hook for command, a path followed by space separated arguments
+func NewExecHook(command string, timeout time.Duration) *ExecHook {
+	return &ExecHook{command: strings.Fields(command), timeout: timeout}
+}
+
+// Run executes the command for event and waits for it to finish or time out
+func (h *ExecHook) Run(event Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.command[0], h.command[1:]...)
+	cmd.Stdin = bytes.NewReader(payload)
+	// Children of a killed hook may keep its output open, stop waiting for them
+	cmd.WaitDelay = time.Second
+	cmd.Env = append(os.Environ(),
+		"DYNDNS_EVENT="+event.Kind,
+		"DYNDNS_HOSTNAME="+event.Hostname,
+		"DYNDNS_RECORD_TYPE="+event.Type,
+		"DYNDNS_OLD_VALUE="+event.OldValue,
+		"DYNDNS_NEW_VALUE="+event.NewValue,
+	)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("hook %s failed: %w: %s", h.command[0], err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// Handle runs the hook for event in the background, event bus handlers must not block
+func (h *ExecHook) Handle(event Event) {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		if err := h.Run(event); err != nil {
+			log.Print(err)
+		}
+	}()
+}
+
+// Wait blocks until all started runs have finished
+func (h *ExecHook) Wait() {
+	h.wg.Wait()
+}
+
+// parseHookEvents validates the event kinds hooks run for, the defaults if value is empty
+func parseHookEvents(value string) ([]string, error) {
+	kinds := splitList(value)
+	if len(kinds) == 0 {
+		return defaultHookEvents, nil
+	}
+	for _, kind := range kinds {
+		if !slices.Contains(eventKinds, kind) {
+			return nil, fmt.Errorf("unknown event kind %q (expected %s)", kind, strings.Join(eventKinds, ", "))
+		}
+	}
+	return kinds, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeHookScript creates an executable shell script in a temporary directory
+func writeHookScript(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "hook.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExecHookRun(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	script := writeHookScript(t, `cat > "$1"; echo "$DYNDNS_EVENT $DYNDNS_HOSTNAME $DYNDNS_NEW_VALUE" >> "$1.env"`)
+
+	server := NewDynDNSServer(nil, "admin", "password", "8080")
+	hook := NewExecHook(script+" "+out, 5*time.Second)
+	server.events.Subscribe(hook.Handle, defaultHookEvents...)
+
+	server.notifyIPChange("home.example.com", "A", "1.1.1.1", "1.2.3.4")
+	server.events.Publish(Event{Kind: eventUpdateFailed, Hostname: "home.example.com"})
+	hook.Wait()
+
+	var event Event
+	data, _ := os.ReadFile(out)
+	if err := json.Unmarshal(data, &event); err != nil {
+		t.Fatalf("Hook did not receive the event as JSON: %v (%q)", err, data)
+	}
+	if event.Kind != eventIPChange || event.OldValue != "1.1.1.1" {
+		t.Errorf("Unexpected event on stdin: %+v", event)
+	}
+	env, _ := os.ReadFile(out + ".env")
+	if strings.TrimSpace(string(env)) != "ip_change home.example.com 1.2.3.4" {
+		t.Errorf("Expected a single run with event environment, got %q", env)
+	}
+}
+
+func TestExecHookErrors(t *testing.T) {
+	failing := NewExecHook(writeHookScript(t, `echo "wg not running"; exit 3`), 5*time.Second)
+	err := failing.Run(Event{Kind: eventIPChange})
+	if err == nil || !strings.Contains(err.Error(), "wg not running") {
+		t.Errorf("Expected error with hook output, got %v", err)
+	}
+
+	slow := NewExecHook(writeHookScript(t, `sleep 5`), 50*time.Millisecond)
+	start := time.Now()
+	if err := slow.Run(Event{Kind: eventIPChange}); err == nil {
+		t.Error("Expected timeout error")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Error("Expected hook to be killed after the timeout")
+	}
+}
+
+func TestParseHookEvents(t *testing.T) {
+	kinds, err := parseHookEvents("")
+	if err != nil || strings.Join(kinds, ",") != "ip_change,record_created" {
+		t.Errorf("Expected default events, got %v (%v)", kinds, err)
+	}
+	if _, err := parseHookEvents("ip_change,reboot"); err == nil {
+		t.Error("Expected error for unknown event kind")
+	}
+}
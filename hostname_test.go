@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleUpdateNotFQDN(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	tests := []struct {
+		name     string
+		hostname string
+		expected string
+	}{
+		{name: "garbage", hostname: "not%20a%20host", expected: "notfqdn"},
+		{name: "single label", hostname: "home", expected: "notfqdn"},
+		{name: "absolute name in upper case", hostname: "Home.Example.COM.", expected: "good IPv4: 203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/update?hostname="+tt.hostname+"&myip=203.0.113.7", nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if w.Body.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, w.Body.String())
+			}
+		})
+	}
+	if strings.Join(writes, ";") != "POST A home" {
+		t.Errorf("Expected only the valid hostname to be written, got %v", writes)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// importedSetup is what an existing DynDNS client configuration tells about the hostnames to serve
+type importedSetup struct {
+	Source    string
+	Hostnames []string
+	// Usernames are the logins of the previous provider, in the order they appeared
+	Usernames []string
+	// Providers are the services the hostnames were updated at
+	Providers []string
+}
+
+// addHostnames records hostnames updated at provider with username
+func (s *importedSetup) addHostnames(provider, username string, hostnames ...string) {
+	for _, hostname := range hostnames {
+		hostname = parse.NormalizeHostname(strings.Trim(hostname, `"'`))
+		if hostname != "" && !slices.Contains(s.Hostnames, hostname) {
+			s.Hostnames = append(s.Hostnames, hostname)
+		}
+	}
+	if len(hostnames) == 0 {
+		return
+	}
+	if username != "" && !slices.Contains(s.Usernames, username) {
+		s.Usernames = append(s.Usernames, username)
+	}
+	if provider != "" && !slices.Contains(s.Providers, provider) {
+		s.Providers = append(s.Providers, provider)
+	}
+}
+
+// ddclientSetting matches key=value pairs of ddclient.conf, values may be quoted
+var ddclientSetting = regexp.MustCompile(`([A-Za-z][\w-]*)\s*=\s*('[^']*'|"[^"]*"|[^\s,]*)`)
+
+// parseDDClientConfig reads a ddclient.conf. Settings apply to the hosts listed
+// after them on the same or following lines until they are set again.
+func parseDDClientConfig(r io.Reader) (*importedSetup, error) {
+	setup := &importedSetup{Source: "ddclient"}
+	settings := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	line := ""
+	for scanner.Scan() {
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		// A trailing backslash continues the line
+		if strings.HasSuffix(text, `\`) {
+			line += strings.TrimSuffix(text, `\`) + " "
+			continue
+		}
+		line += text
+		if line == "" {
+			continue
+		}
+
+		for _, match := range ddclientSetting.FindAllStringSubmatch(line, -1) {
+			settings[strings.ToLower(match[1])] = strings.Trim(match[2], `"'`)
+		}
+		rest := ddclientSetting.ReplaceAllString(line, "")
+		hosts := strings.FieldsFunc(rest, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		provider := settings["protocol"]
+		if server := settings["server"]; server != "" {
+			provider += " (" + server + ")"
+		}
+		setup.addHostnames(provider, settings["login"], hosts...)
+		line = ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return setup, nil
+}
+
+// stripComment removes a # comment outside of quotes
+func stripComment(line string) string {
+	quote := rune(0)
+	for i, c := range line {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// inadynToken matches the tokens of inadyn.conf: quoted strings, braces, = and commas, and bare words
+var inadynToken = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'[^']*'|[{}=,]|[^\s{}=,"']+`)
+
+// parseInadynConfig reads an inadyn.conf of inadyn 2, where every provider or
+// custom section lists its hostname as a string or a { "a", "b" } list
+func parseInadynConfig(r io.Reader) (*importedSetup, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []string
+	for _, line := range strings.Split(string(data), "\n") {
+		tokens = append(tokens, inadynToken.FindAllString(stripComment(line), -1)...)
+	}
+
+	setup := &importedSetup{Source: "inadyn"}
+	sections := 0
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i] != "provider" && tokens[i] != "custom" {
+			continue
+		}
+		if i+2 >= len(tokens) || tokens[i+2] != "{" {
+			return nil, fmt.Errorf("expected a name and { after %s", tokens[i])
+		}
+		provider := strings.Trim(tokens[i+1], `"'`)
+		// Hostname lists are nested braces, find the brace closing the section
+		depth, end := 1, i+3
+		for ; end < len(tokens) && depth > 0; end++ {
+			switch tokens[end] {
+			case "{":
+				depth++
+			case "}":
+				depth--
+			}
+		}
+		if depth > 0 {
+			return nil, fmt.Errorf("section %s %s is not closed", tokens[i], provider)
+		}
+
+		values := inadynValues(tokens[i+3 : end-1])
+		if server := strings.Join(values["ddns-server"], ""); server != "" {
+			provider += " (" + server + ")"
+		}
+		setup.addHostnames(provider, strings.Join(values["username"], ""), values["hostname"]...)
+		sections++
+		i = end - 1
+	}
+	if sections == 0 {
+		return nil, fmt.Errorf("no provider or custom sections found, only inadyn 2 configurations are supported")
+	}
+	return setup, nil
+}
+
+// inadynValues maps the keys of a section body to their unquoted values
+func inadynValues(tokens []string) map[string][]string {
+	values := map[string][]string{}
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i+1] != "=" {
+			continue
+		}
+		key := strings.ToLower(tokens[i])
+		if tokens[i+2] != "{" {
+			values[key] = append(values[key], strings.Trim(tokens[i+2], `"'`))
+			i += 2
+			continue
+		}
+		j := i + 3
+		for ; j < len(tokens) && tokens[j] != "}"; j++ {
+			if tokens[j] != "," {
+				values[key] = append(values[key], strings.Trim(tokens[j], `"'`))
+			}
+		}
+		i = j
+	}
+	return values
+}
+
+// writeImportedSetup prints the bridge configuration equivalent to setup as
+// shell exports. Secrets are placeholders: the bridge uses its own credentials,
+// not those of the previous provider.
+func writeImportedSetup(setup *importedSetup, path string, out io.Writer) {
+	username := "admin"
+	if len(setup.Usernames) > 0 {
+		username = setup.Usernames[0]
+	}
+
+	fmt.Fprintf(out, "# Generated from the %s configuration %s\n", setup.Source, path)
+	if len(setup.Providers) > 0 {
+		fmt.Fprintf(out, "# Previously updated at: %s\n", strings.Join(setup.Providers, ", "))
+	}
+	if len(setup.Usernames) > 1 {
+		fmt.Fprintf(out, "# Further logins, see DYNDNS_PROFILE_USERS to keep them: %s\n", strings.Join(setup.Usernames[1:], ", "))
+	}
+	fmt.Fprintf(out, "export HETZNER_DNS_API_KEY=\"<your Hetzner DNS API token>\"\n")
+	fmt.Fprintf(out, "export DYNDNS_USERNAME=%q\n", username)
+	fmt.Fprintf(out, "export DYNDNS_PASSWORD=\"<choose a password>\"\n")
+	fmt.Fprintf(out, "export DYNDNS_HOSTNAMES=%q\n", strings.Join(setup.Hostnames, ","))
+	fmt.Fprintf(out, "#\n# Then point %s at the bridge using the dyndns2 protocol, e.g.\n", setup.Source)
+	if setup.Source == "ddclient" {
+		fmt.Fprintf(out, "#   protocol=dyndns2, server=<bridge-host>:8080, ssl=no, login=%s, password=<password> %s\n", username, strings.Join(setup.Hostnames, ","))
+		return
+	}
+	quoted := make([]string, len(setup.Hostnames))
+	for i, hostname := range setup.Hostnames {
+		quoted[i] = fmt.Sprintf("%q", hostname)
+	}
+	fmt.Fprintf(out, "#   custom bridge { ddns-server = \"<bridge-host>:8080\" ddns-path = \"/nic/update?hostname=%%h&myip=%%i\" username = %q password = \"<password>\" hostname = { %s } }\n",
+		username, strings.Join(quoted, ", "))
+}
+
+// runImportCommand handles "import ddclient|inadyn <file>", it needs no configuration
+func runImportCommand(args []string, out io.Writer) error {
+	if len(args) != 2 {
+		return fmt.Errorf("import requires the client and its configuration file\n%s", cliUsage)
+	}
+	parse := map[string]func(io.Reader) (*importedSetup, error){
+		"ddclient": parseDDClientConfig,
+		"inadyn":   parseInadynConfig,
+	}[args[0]]
+	if parse == nil {
+		return fmt.Errorf("unsupported client %s, expected ddclient or inadyn", args[0])
+	}
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	setup, err := parse(file)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", args[1], err)
+	}
+	if len(setup.Hostnames) == 0 {
+		return fmt.Errorf("no hostnames found in %s", args[1])
+	}
+	writeImportedSetup(setup, args[1], out)
+	return nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDDClientConfig(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            string
+		expectedHostnames []string
+		expectedUsernames []string
+		expectedProviders []string
+	}{
+		{
+			name: "settings on separate lines",
+			config: `# ddclient.conf
+daemon=300
+use=web, web=checkip.dyndns.org
+protocol=dyndns2
+server=members.dyndns.org
+login=alice
+password='se#cret'
+home.example.com,nas.example.com
+`,
+			expectedHostnames: []string{"home.example.com", "nas.example.com"},
+			expectedUsernames: []string{"alice"},
+			expectedProviders: []string{"dyndns2 (members.dyndns.org)"},
+		},
+		{
+			name: "settings and hosts on one line with continuation",
+			config: `protocol=dyndns2, server=dyn.example.net, \
+  login=bob, password="x y" vpn.example.org
+protocol=cloudflare, zone=example.com, login=carol, password=token Home.Example.COM.
+`,
+			expectedHostnames: []string{"vpn.example.org", "home.example.com"},
+			expectedUsernames: []string{"bob", "carol"},
+			expectedProviders: []string{"dyndns2 (dyn.example.net)", "cloudflare (dyn.example.net)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup, err := parseDDClientConfig(strings.NewReader(tt.config))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(setup.Hostnames, tt.expectedHostnames) {
+				t.Errorf("Expected hostnames %v, got %v", tt.expectedHostnames, setup.Hostnames)
+			}
+			if !reflect.DeepEqual(setup.Usernames, tt.expectedUsernames) {
+				t.Errorf("Expected usernames %v, got %v", tt.expectedUsernames, setup.Usernames)
+			}
+			if !reflect.DeepEqual(setup.Providers, tt.expectedProviders) {
+				t.Errorf("Expected providers %v, got %v", tt.expectedProviders, setup.Providers)
+			}
+		})
+	}
+}
+
+func TestParseInadynConfig(t *testing.T) {
+	tests := []struct {
+		name              string
+		config            string
+		expectedHostnames []string
+		expectedUsernames []string
+		expectError       bool
+	}{
+		{
+			name: "provider and custom sections",
+			config: `# inadyn.conf
+period = 300
+provider default@dyndns.org {
+    username = alice
+    password = "se{cret}"
+    hostname = { "home.example.com", "nas.example.com" }
+}
+custom old-bridge {
+    username    = "bob"
+    ddns-server = "dyn.example.net"
+    ddns-path   = "/update?hostname=%h"
+    hostname    = "vpn.example.org"
+}
+`,
+			expectedHostnames: []string{"home.example.com", "nas.example.com", "vpn.example.org"},
+			expectedUsernames: []string{"alice", "bob"},
+		},
+		{
+			name:        "inadyn 1 options",
+			config:      "--username alice --password secret --alias home.example.com\n",
+			expectError: true,
+		},
+		{
+			name:        "unclosed section",
+			config:      "provider default@dyndns.org {\n hostname = home.example.com\n",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup, err := parseInadynConfig(strings.NewReader(tt.config))
+			if tt.expectError {
+				if err == nil {
+					t.Fatalf("Expected an error, got %+v", setup)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(setup.Hostnames, tt.expectedHostnames) {
+				t.Errorf("Expected hostnames %v, got %v", tt.expectedHostnames, setup.Hostnames)
+			}
+			if !reflect.DeepEqual(setup.Usernames, tt.expectedUsernames) {
+				t.Errorf("Expected usernames %v, got %v", tt.expectedUsernames, setup.Usernames)
+			}
+		})
+	}
+}
+
+func TestRunImportCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ddclient.conf")
+	os.WriteFile(path, []byte("login=alice\npassword=oldsecret\nhome.example.com\n"), 0o600)
+
+	var out bytes.Buffer
+	if err := runImportCommand([]string{"ddclient", path}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{`export DYNDNS_USERNAME="alice"`, `export DYNDNS_HOSTNAMES="home.example.com"`, "<choose a password>"} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "oldsecret") {
+		t.Errorf("Expected the old password not to be copied, got:\n%s", out.String())
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty.conf")
+	os.WriteFile(empty, []byte("daemon=300\n"), 0o600)
+	for _, args := range [][]string{{"ddclient"}, {"ez-ipupdate", path}, {"ddclient", empty}} {
+		if err := runImportCommand(args, &out); err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// Default services returning the caller's public address as plain text
+const (
+	DefaultIPv4DetectURL = "https://api.ipify.org"
+	DefaultIPv6DetectURL = "https://api6.ipify.org"
+)
+
+// IPDetector determines the current public IPv4 and IPv6 address using echo services
+type IPDetector struct {
+	IPv4URL    string
+	IPv6URL    string
+	HTTPClient *http.Client
+}
+
+// NewIPDetector creates a detector using the given echo service URLs, an empty URL disables that family
+func NewIPDetector(ipv4URL, ipv6URL string) *IPDetector {
+	return &IPDetector{
+		IPv4URL: ipv4URL,
+		IPv6URL: ipv6URL,
+		HTTPClient: &http.Client{
+			Timeout: 10 * time.Second,
+		},
+	}
+}
+
+// DetectIPv4 returns the public IPv4 address, or an empty string if detection is disabled
+func (d *IPDetector) DetectIPv4() (string, error) {
+	if d.IPv4URL == "" {
+		return "", nil
+	}
+	ip, err := d.detect(d.IPv4URL)
+	if err != nil {
+		return "", err
+	}
+	if !parse.IsIPv4(ip) {
+		return "", fmt.Errorf("invalid IPv4 address from %s: %q", d.IPv4URL, ip)
+	}
+	return ip, nil
+}
+
+// DetectIPv6 returns the public IPv6 address, or an empty string if detection is disabled
+func (d *IPDetector) DetectIPv6() (string, error) {
+	if d.IPv6URL == "" {
+		return "", nil
+	}
+	ip, err := d.detect(d.IPv6URL)
+	if err != nil {
+		return "", err
+	}
+	if !parse.IsIPv6(ip) {
+		return "", fmt.Errorf("invalid IPv6 address from %s: %q", d.IPv6URL, ip)
+	}
+	return ip, nil
+}
+
+// detect fetches url and returns the trimmed response body
+func (d *IPDetector) detect(url string) (string, error) {
+	resp, err := d.HTTPClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to query %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to query %s: status %d", url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response from %s: %w", url, err)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
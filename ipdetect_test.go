@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPDetector(t *testing.T) {
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v4":
+			w.Write([]byte("203.0.113.1\n"))
+		case "/v6":
+			w.Write([]byte("2001:db8::1"))
+		case "/garbage":
+			w.Write([]byte("<html>"))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer echo.Close()
+
+	detector := NewIPDetector(echo.URL+"/v4", echo.URL+"/v6")
+	if ip, err := detector.DetectIPv4(); err != nil || ip != "203.0.113.1" {
+		t.Errorf("Expected 203.0.113.1, got %q (%v)", ip, err)
+	}
+	if ip, err := detector.DetectIPv6(); err != nil || ip != "2001:db8::1" {
+		t.Errorf("Expected 2001:db8::1, got %q (%v)", ip, err)
+	}
+
+	detector = NewIPDetector(echo.URL+"/garbage", echo.URL+"/error")
+	if _, err := detector.DetectIPv4(); err == nil {
+		t.Error("Expected error for invalid response")
+	}
+	if _, err := detector.DetectIPv6(); err == nil {
+		t.Error("Expected error for failed request")
+	}
+
+	detector = NewIPDetector("", "")
+	if ip, err := detector.DetectIPv4(); err != nil || ip != "" {
+		t.Errorf("Expected disabled detection to return nothing, got %q (%v)", ip, err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+)
+
+// refreshBucket is the store bucket holding the last refresh per hostname and record type
+const refreshBucket = "refresh"
+
+// refreshTracker remembers when a hostname and record type was last confirmed by a client
+type refreshTracker struct {
+	store Store
+	now   func() time.Time
+}
+
+// newRefreshTracker creates a refresh tracker persisting into store
+func newRefreshTracker(store Store) *refreshTracker {
+	return &refreshTracker{store: store, now: time.Now}
+}
+
+// Mark records a refresh of hostname and recordType now
+func (t *refreshTracker) Mark(hostname, recordType string) {
+	value := []byte(t.now().UTC().Format(time.RFC3339Nano))
+	if err := t.store.Put(refreshBucket, hostname+"/"+recordType, value); err != nil {
+		log.Printf("Failed to store refresh of %s %s: %v", hostname, recordType, err)
+	}
+}
+
+// LastSeen returns the last refresh of hostname and recordType, or the zero time
+func (t *refreshTracker) LastSeen(hostname, recordType string) time.Time {
+	value, ok, err := t.store.Get(refreshBucket, hostname+"/"+recordType)
+	if err != nil {
+		log.Printf("Failed to load refresh of %s %s: %v", hostname, recordType, err)
+		return time.Time{}
+	}
+	if !ok {
+		return time.Time{}
+	}
+
+	seen, err := time.Parse(time.RFC3339Nano, string(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return seen
+}
+
+// Janitor periodically deletes owned A/AAAA records that have not been refreshed for a while
+type Janitor struct {
+	server *DynDNSServer
+	maxAge time.Duration
+	dryRun bool
+}
+
+// NewJanitor creates a janitor deleting records not refreshed within maxAge, dryRun only reports them
+func NewJanitor(server *DynDNSServer, maxAge time.Duration, dryRun bool) *Janitor {
+	server.metrics.Describe("dyndns_janitor_runs_total", "counter", "Number of stale record sweeps by result.")
+	server.metrics.Describe("dyndns_janitor_stale_records", "gauge", "Number of stale records found in the last sweep.")
+	server.metrics.Describe("dyndns_janitor_deleted_records_total", "counter", "Number of stale records deleted.")
+
+	return &Janitor{
+		server: server,
+		maxAge: maxAge,
+		dryRun: dryRun,
+	}
+}
+
+// Run sweeps for stale records every interval until stop is closed
+func (j *Janitor) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := j.Sweep(); err != nil {
+				log.Printf("Stale record sweep failed: %v", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Sweep finds owned A/AAAA records not refreshed within maxAge and deletes them
+// unless running in dry-run mode. It returns the stale records found.
+func (j *Janitor) Sweep() ([]DNSRecord, error) {
+	if j.server.maintenance.Active() {
+		log.Printf("Janitor: skipping sweep during maintenance")
+		return nil, nil
+	}
+	stale, err := j.sweep()
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	j.server.metrics.Inc("dyndns_janitor_runs_total", Labels{"result": result})
+	return stale, err
+}
+
+// sweep performs a single sweep over all zones of all configured API tokens
+func (j *Janitor) sweep() ([]DNSRecord, error) {
+	var stale []DNSRecord
+	for _, client := range j.server.clients() {
+		found, err := j.sweepClient(client)
+		stale = append(stale, found...)
+		if err != nil {
+			return stale, err
+		}
+	}
+
+	j.server.metrics.Set("dyndns_janitor_stale_records", nil, float64(len(stale)))
+	return stale, nil
+}
+
+// sweepClient sweeps the zones accessible with client
+func (j *Janitor) sweepClient(client *Client) ([]DNSRecord, error) {
+	zones, err := client.GetZones()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zones: %w", err)
+	}
+
+	var stale []DNSRecord
+	now := j.server.clock.Now()
+
+	for _, zone := range zones {
+		records, err := client.GetAllRecords(zone.ID)
+		if err != nil {
+			return stale, fmt.Errorf("failed to get records for zone %s: %w", zone.Name, err)
+		}
+
+		for _, record := range records {
+			if record.Type != "A" && record.Type != "AAAA" {
+				continue
+			}
+			if !isOwnedRecord(records, record.Name, record.Type, j.server.ownerID) {
+				continue
+			}
+
+			hostname := recordFQDN(record.Name, zone.Name)
+			lastSeen := j.lastRefresh(hostname, record)
+			if now.Sub(lastSeen) < j.maxAge {
+				continue
+			}
+
+			stale = append(stale, record)
+			if j.dryRun {
+				log.Printf("Dry run: would delete stale record %s %s (last refreshed %s)",
+					record.Type, hostname, lastSeen.Format(time.RFC3339))
+				continue
+			}
+
+			if err := j.server.deleteOwnedRecord(client, records, record); err != nil {
+				return stale, err
+			}
+			j.server.recordChange(hostname, record.Type, record.Value, "", &historyClient{System: historySystemJanitor})
+			j.server.metrics.Inc("dyndns_janitor_deleted_records_total", Labels{"type": record.Type})
+			log.Printf("Deleted stale record %s %s (last refreshed %s)",
+				record.Type, hostname, lastSeen.Format(time.RFC3339))
+		}
+	}
+
+	return stale, nil
+}
+
+// lastRefresh returns the later of the last client refresh and the record's modification time
+func (j *Janitor) lastRefresh(hostname string, record DNSRecord) time.Time {
+	lastSeen := j.server.refreshed.LastSeen(hostname, record.Type)
+	if modified, err := dyndns.ParseTime(record.Modified); err == nil && modified.After(lastSeen) {
+		lastSeen = modified
+	}
+	return lastSeen
+}
+
+// recordFQDN returns the fully qualified name of a record in zone
+func recordFQDN(recordName, zoneName string) string {
+	if recordName == "@" || recordName == "" {
+		return zoneName
+	}
+	return strings.TrimSuffix(recordName, ".") + "." + zoneName
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJanitorSweep(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour).UTC().Format("2006-01-02 15:04:05.000 -0700 MST")
+	recent := time.Now().Add(-time.Minute).UTC().Format("2006-01-02 15:04:05.000 -0700 MST")
+
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "stale", Value: "1.1.1.1", Modified: old},
+		{ID: "rec2", Type: "TXT", Name: "_dyndns-a.stale", Value: ownershipValue("bridge1")},
+		{ID: "rec3", Type: "A", Name: "fresh", Value: "1.1.1.1", Modified: recent},
+		{ID: "rec4", Type: "TXT", Name: "_dyndns-a.fresh", Value: ownershipValue("bridge1")},
+		{ID: "rec5", Type: "A", Name: "refreshed", Value: "1.1.1.1", Modified: old},
+		{ID: "rec6", Type: "TXT", Name: "_dyndns-a.refreshed", Value: ownershipValue("bridge1")},
+		{ID: "rec7", Type: "A", Name: "manual", Value: "1.1.1.1", Modified: old},
+	}
+
+	tests := []struct {
+		name           string
+		dryRun         bool
+		expectedWrites string
+	}{
+		{name: "dry run", dryRun: true, expectedWrites: ""},
+		{name: "delete", dryRun: false, expectedWrites: "DELETE rec1;DELETE rec2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.ownerID = "bridge1"
+			server.refreshed.Mark("refreshed.example.com", "A")
+
+			janitor := NewJanitor(server, 24*time.Hour, tt.dryRun)
+			stale, err := janitor.Sweep()
+			if err != nil {
+				t.Fatalf("Sweep failed: %v", err)
+			}
+
+			if len(stale) != 1 || stale[0].ID != "rec1" {
+				t.Errorf("Expected only rec1 to be stale, got %+v", stale)
+			}
+			if got := strings.Join(writes, ";"); got != tt.expectedWrites {
+				t.Errorf("Expected writes %q, got %q", tt.expectedWrites, got)
+			}
+			if value := server.metrics.Value("dyndns_janitor_stale_records", nil); value != 1 {
+				t.Errorf("Expected stale records gauge 1, got %g", value)
+			}
+			entries, _ := server.recordHistory("stale.example.com", "A")
+			if deleted := len(entries) == 1 && entries[0].NewValue == "" && *entries[0].Client == (historyClient{System: historySystemJanitor}); deleted == tt.dryRun {
+				t.Errorf("Expected the deletion by the janitor in the history: %v, got %+v", !tt.dryRun, entries)
+			}
+		})
+	}
+}
+
+func TestRecordFQDN(t *testing.T) {
+	tests := []struct {
+		recordName string
+		expected   string
+	}{
+		{"@", "example.com"},
+		{"home", "home.example.com"},
+		{"*.home", "*.home.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := recordFQDN(tt.recordName, "example.com"); result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
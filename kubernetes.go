@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// KubeHostnameAnnotation lists the hostnames (comma separated) to maintain for a Service or Ingress
+const KubeHostnameAnnotation = "dyndns.hetzner.com/hostname"
+
+// serviceAccountDir holds the credentials mounted into every pod
+const serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// KubeClient is a minimal read-only client for the Kubernetes API
+type KubeClient struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// kubeObjectList is the subset of a Service or Ingress list used by the watcher
+type kubeObjectList struct {
+	Items []kubeObject `json:"items"`
+}
+
+// kubeObject is the subset of a Service or Ingress used by the watcher
+type kubeObject struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Namespace   string            `json:"namespace"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Status struct {
+		LoadBalancer struct {
+			Ingress []struct {
+				IP       string `json:"ip"`
+				Hostname string `json:"hostname"`
+			} `json:"ingress"`
+		} `json:"loadBalancer"`
+	} `json:"status"`
+}
+
+// NewInClusterKubeClient creates a client using the pod's service account
+func NewInClusterKubeClient() (*KubeClient, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("not running inside a Kubernetes cluster")
+	}
+
+	token, err := os.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	ca, err := os.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cluster CA: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("invalid cluster CA certificate")
+	}
+
+	return &KubeClient{
+		BaseURL: "https://" + net.JoinHostPort(host, port),
+		Token:   string(token),
+		HTTPClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}},
+		},
+	}, nil
+}
+
+// list fetches a resource list from path
+func (k *KubeClient) list(path string) ([]kubeObject, error) {
+	req, err := http.NewRequest("GET", k.BaseURL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+k.Token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := k.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("failed to list %s: status %d: %s", path, resp.StatusCode, body)
+	}
+
+	var list kubeObjectList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return list.Items, nil
+}
+
+// KubeWatcher keeps records for annotated Services and Ingresses in sync with their load balancer addresses
+type KubeWatcher struct {
+	server *DynDNSServer
+	kube   *KubeClient
+}
+
+// NewKubeWatcher creates a watcher using kube to discover annotated objects
+func NewKubeWatcher(server *DynDNSServer, kube *KubeClient) *KubeWatcher {
+	server.metrics.Describe("dyndns_kubernetes_records", "gauge", "Number of records managed from Kubernetes objects.")
+	server.metrics.Describe("dyndns_kubernetes_sync_errors_total", "counter", "Number of failed Kubernetes syncs.")
+
+	return &KubeWatcher{server: server, kube: kube}
+}
+
+// Run syncs immediately and then every interval until stop is closed
+func (w *KubeWatcher) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.Sync(); err != nil {
+			log.Printf("Kubernetes sync failed: %v", err)
+			w.server.metrics.Inc("dyndns_kubernetes_sync_errors_total", nil)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Sync lists annotated Services and Ingresses and ensures their records point at the load balancer IPs
+func (w *KubeWatcher) Sync() error {
+	desired := map[string]map[string]string{} // hostname -> record type -> IP
+
+	for _, path := range []string{"/api/v1/services", "/apis/networking.k8s.io/v1/ingresses"} {
+		objects, err := w.kube.list(path)
+		if err != nil {
+			return err
+		}
+		for _, object := range objects {
+			collectDesiredRecords(desired, object)
+		}
+	}
+
+	hostnames := make([]string, 0, len(desired))
+	for hostname := range desired {
+		hostnames = append(hostnames, hostname)
+	}
+	sort.Strings(hostnames)
+
+	managed := 0
+	var lastErr error
+	for _, hostname := range hostnames {
+		for recordType, ip := range desired[hostname] {
+			managed++
+			if _, err := w.server.ensureRecord(hostname, ip, recordType, historySystemKubernetes); err != nil {
+				log.Printf("Kubernetes sync of %s %s failed: %v", hostname, recordType, err)
+				lastErr = err
+			}
+		}
+	}
+
+	w.server.metrics.Set("dyndns_kubernetes_records", nil, float64(managed))
+	return lastErr
+}
+
+// collectDesiredRecords adds the records requested by object's annotation to desired
+func collectDesiredRecords(desired map[string]map[string]string, object kubeObject) {
+	hostnames := splitList(object.Metadata.Annotations[KubeHostnameAnnotation])
+	if len(hostnames) == 0 {
+		return
+	}
+
+	records := map[string]string{}
+	for _, ingress := range object.Status.LoadBalancer.Ingress {
+		switch {
+		case parse.IsIPv4(ingress.IP) && records["A"] == "":
+			records["A"] = ingress.IP
+		case parse.IsIPv6(ingress.IP) && records["AAAA"] == "":
+			records["AAAA"] = ingress.IP
+		}
+	}
+	if len(records) == 0 {
+		log.Printf("Kubernetes object %s/%s has no load balancer IP yet", object.Metadata.Namespace, object.Metadata.Name)
+		return
+	}
+
+	for _, hostname := range hostnames {
+		if desired[hostname] == nil {
+			desired[hostname] = map[string]string{}
+		}
+		for recordType, ip := range records {
+			desired[hostname][recordType] = ip
+		}
+	}
+}
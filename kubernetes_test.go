@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestKubeWatcherSync(t *testing.T) {
+	kubeAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer kube-token" {
+			t.Errorf("Unexpected Authorization header: %s", r.Header.Get("Authorization"))
+		}
+
+		switch r.URL.Path {
+		case "/api/v1/services":
+			w.Write([]byte(`{"items": [
+				{"metadata": {"name": "app", "namespace": "default", "annotations": {"dyndns.hetzner.com/hostname": "app.example.com"}},
+				 "status": {"loadBalancer": {"ingress": [{"ip": "203.0.113.10"}, {"ip": "2001:db8::10"}]}}},
+				{"metadata": {"name": "pending", "namespace": "default", "annotations": {"dyndns.hetzner.com/hostname": "pending.example.com"}},
+				 "status": {"loadBalancer": {}}},
+				{"metadata": {"name": "plain", "namespace": "default"},
+				 "status": {"loadBalancer": {"ingress": [{"ip": "203.0.113.11"}]}}}
+			]}`))
+		case "/apis/networking.k8s.io/v1/ingresses":
+			w.Write([]byte(`{"items": [
+				{"metadata": {"name": "web", "namespace": "web", "annotations": {"dyndns.hetzner.com/hostname": "web.example.com"}},
+				 "status": {"loadBalancer": {"ingress": [{"ip": "203.0.113.1"}]}}}
+			]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer kubeAPI.Close()
+
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "web", Value: "203.0.113.1"},
+	}
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, records, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	kube := &KubeClient{BaseURL: kubeAPI.URL, Token: "kube-token", HTTPClient: http.DefaultClient}
+	watcher := NewKubeWatcher(server, kube)
+
+	if err := watcher.Sync(); err != nil {
+		t.Fatalf("Sync failed: %v", err)
+	}
+
+	joined := strings.Join(writes, ";")
+	if !strings.Contains(joined, "POST A app") || !strings.Contains(joined, "POST AAAA app") || len(writes) != 2 {
+		t.Errorf("Expected A and AAAA records for app to be created, got %v", writes)
+	}
+	if value := server.metrics.Value("dyndns_kubernetes_records", nil); value != 3 {
+		t.Errorf("Expected 3 managed records, got %g", value)
+	}
+}
+
+func TestKubeClientListError(t *testing.T) {
+	kubeAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("services is forbidden"))
+	}))
+	defer kubeAPI.Close()
+
+	kube := &KubeClient{BaseURL: kubeAPI.URL, HTTPClient: http.DefaultClient}
+	if _, err := kube.list("/api/v1/services"); err == nil || !strings.Contains(err.Error(), "forbidden") {
+		t.Errorf("Expected forbidden error, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// shutdownTimeout bounds how long running requests may take after SIGTERM
+const shutdownTimeout = 10 * time.Second
+
+// Address families selectable with DYNDNS_LISTEN_FAMILY
+const (
+	listenDual = "dual"
+	listenIPv4 = "ipv4"
+	listenIPv6 = "ipv6"
+)
+
+// listenNetworks maps the address families to the networks passed to net.Listen.
+// tcp6 sockets are IPv6-only, so "[::]" does not accept IPv4 connections then.
+var listenNetworks = map[string]string{
+	listenDual: "tcp",
+	listenIPv4: "tcp4",
+	listenIPv6: "tcp6",
+}
+
+// parseListenAddrs parses "address[:port]" entries separated by commas, entries
+// without a port listen on port. An empty value listens on all addresses.
+func parseListenAddrs(value, port string) ([]string, error) {
+	entries := splitList(value)
+	if len(entries) == 0 {
+		entries = []string{""}
+	}
+
+	var addrs []string
+	for _, entry := range entries {
+		host, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			// A bare address, IPv6 addresses may be written with or without brackets
+			host, entryPort = strings.TrimSuffix(strings.TrimPrefix(entry, "["), "]"), port
+		}
+		if n, err := strconv.Atoi(entryPort); err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid port in %q", entry)
+		}
+		if host != "" && net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("expected an IP address in %q", entry)
+		}
+		addrs = append(addrs, net.JoinHostPort(host, entryPort))
+	}
+	return addrs, nil
+}
+
+// checkListenFamily rejects addresses that cannot be listened on with family
+func checkListenFamily(addrs []string, family string) error {
+	if _, ok := listenNetworks[family]; !ok {
+		return fmt.Errorf("invalid DYNDNS_LISTEN_FAMILY: %s (expected dual, ipv4 or ipv6)", family)
+	}
+	for _, addr := range addrs {
+		host, _, _ := net.SplitHostPort(addr)
+		ip := net.ParseIP(host)
+		if ip == nil {
+			continue
+		}
+		if (family == listenIPv4 && ip.To4() == nil) || (family == listenIPv6 && ip.To4() != nil) {
+			return fmt.Errorf("DYNDNS_LISTEN address %s does not match DYNDNS_LISTEN_FAMILY=%s", addr, family)
+		}
+	}
+	return nil
+}
+
+// listenAddrsOrDefault returns the configured listen addresses, all addresses on the port by default
+func (s *DynDNSServer) listenAddrsOrDefault() []string {
+	if len(s.listenAddrs) > 0 {
+		return s.listenAddrs
+	}
+	return []string{":" + s.port}
+}
+
+// listen opens a listener for every address, closing the opened ones if one fails
+func (s *DynDNSServer) listen() ([]net.Listener, error) {
+	network := listenNetworks[s.listenFamily]
+	if network == "" {
+		network = "tcp"
+	}
+
+	var listeners []net.Listener
+	for _, addr := range s.listenAddrsOrDefault() {
+		listener, err := net.Listen(network, addr)
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// serve serves handler on all listen addresses until one of them fails
+func (s *DynDNSServer) serve(handler http.Handler) error {
+	listeners, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: handler}
+	if s.tlsCert != "" {
+		server.TLSConfig = &tls.Config{
+			ClientCAs:  s.clientCAs,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+		}
+	}
+
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		go func() {
+			if s.tlsCert != "" {
+				errs <- server.ServeTLS(listener, s.tlsCert, s.tlsKey)
+				return
+			}
+			errs <- server.Serve(listener)
+		}()
+	}
+	// procd, systemd and Docker stop services with SIGTERM, running updates are finished first
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(stop)
+	defer close(s.done)
+	select {
+	case err = <-errs:
+		server.Close()
+		return err
+	case sig := <-stop:
+		log.Printf("Received %s, shutting down", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(ctx)
+	}
+}
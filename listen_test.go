@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseListenAddrs(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    []string
+		expectError bool
+	}{
+		{name: "default listens on all addresses", value: "", expected: []string{":8080"}},
+		{name: "IPv4 and IPv6 wildcards", value: "0.0.0.0, ::", expected: []string{"0.0.0.0:8080", "[::]:8080"}},
+		{name: "bracketed IPv6 without port", value: "[2001:db8::1]", expected: []string{"[2001:db8::1]:8080"}},
+		{name: "explicit ports", value: "192.168.178.2:8081,[::1]:8082,:8083", expected: []string{"192.168.178.2:8081", "[::1]:8082", ":8083"}},
+		{name: "hostname", value: "localhost", expectError: true},
+		{name: "invalid port", value: "0.0.0.0:http", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addrs, err := parseListenAddrs(tt.value, "8080")
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", addrs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, addrs)
+			}
+		})
+	}
+}
+
+func TestCheckListenFamily(t *testing.T) {
+	tests := []struct {
+		name        string
+		addrs       []string
+		family      string
+		expectError bool
+	}{
+		{name: "dual accepts both", addrs: []string{"0.0.0.0:8080", "[::]:8080"}, family: listenDual},
+		{name: "IPv6-only", addrs: []string{":8080", "[::]:8081"}, family: listenIPv6},
+		{name: "IPv4 address with IPv6-only", addrs: []string{"0.0.0.0:8080"}, family: listenIPv6, expectError: true},
+		{name: "IPv6 address with IPv4-only", addrs: []string{"[::1]:8080"}, family: listenIPv4, expectError: true},
+		{name: "unknown family", addrs: []string{":8080"}, family: "both", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkListenFamily(tt.addrs, tt.family)
+			if (err != nil) != tt.expectError {
+				t.Errorf("Expected error %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+func TestListenMultipleAddresses(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.listenAddrs = []string{"127.0.0.1:0", "127.0.0.1:0"}
+	server.listenFamily = listenIPv4
+
+	listeners, err := server.listen()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+	if len(listeners) != 2 {
+		t.Fatalf("Expected 2 listeners, got %d", len(listeners))
+	}
+	for _, listener := range listeners {
+		if ip := listener.Addr().(*net.TCPAddr).IP; ip.To4() == nil {
+			t.Errorf("Expected an IPv4 listener, got %s", ip)
+		}
+	}
+	if !strings.Contains(server.startupSummary(), "listen=127.0.0.1:0,127.0.0.1:0") {
+		t.Errorf("Expected all addresses in the startup summary, got %s", server.startupSummary())
+	}
+
+	// IPv6-only listeners cannot bind IPv4 addresses, the opened listeners are closed again
+	server.listenFamily = listenIPv6
+	if _, err := server.listen(); err == nil {
+		t.Error("Expected listening on an IPv4 address with the IPv6 family to fail")
+	}
+}
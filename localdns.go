@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+)
+
+// LocalDNSBackend writes records to a DNS server answering LAN clients
+type LocalDNSBackend interface {
+	SetRecord(hostname, recordType, value string) error
+}
+
+// LocalHost is a hostname mirrored to the local DNS server. LAN clients get
+// IPv4 instead of the public address if it is set, the AAAA record is mirrored
+// as is since global IPv6 addresses are reachable from the LAN as well.
+type LocalHost struct {
+	Hostname string
+	IPv4     string
+}
+
+// Local DNS backends selectable in DYNDNS_LOCAL_ROUTES
+const (
+	localDNSRFC2136 = "rfc2136"
+	localDNSPihole  = "pihole"
+	localDNSAdGuard = "adguard"
+)
+
+// localDNSBackends lists the local DNS backend names
+var localDNSBackends = []string{localDNSRFC2136, localDNSPihole, localDNSAdGuard}
+
+// LocalMirror mirrors record changes of hostnames to a local DNS server for split-horizon setups
+type LocalMirror struct {
+	hosts  map[string]LocalHost
+	router *LocalDNSRouter
+	wg     sync.WaitGroup
+}
+
+// NewLocalMirror creates a mirror writing the records of hosts to the backend router selects
+func NewLocalMirror(hosts []LocalHost, router *LocalDNSRouter) *LocalMirror {
+	byName := make(map[string]LocalHost, len(hosts))
+	for _, host := range hosts {
+		byName[host.Hostname] = host
+	}
+	return &LocalMirror{hosts: byName, router: router}
+}
+
+// Handle mirrors the changed record in the background if its hostname is mirrored
+func (m *LocalMirror) Handle(event Event) {
+	host, ok := m.hosts[event.Hostname]
+	if !ok {
+		return
+	}
+	backend, err := m.router.BackendFor(event.Hostname)
+	if err != nil {
+		log.Printf("Failed to mirror %s %s to local DNS: %v", event.Hostname, event.Type, err)
+		return
+	}
+	value := event.NewValue
+	if event.Type == "A" && host.IPv4 != "" {
+		value = host.IPv4
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		if err := backend.SetRecord(event.Hostname, event.Type, value); err != nil {
+			log.Printf("Failed to mirror %s %s to local DNS: %v", event.Hostname, event.Type, err)
+			return
+		}
+		log.Printf("Mirrored %s %s -> %s to local DNS", event.Hostname, event.Type, value)
+	}()
+}
+
+// Wait blocks until all started writes have finished
+func (m *LocalMirror) Wait() {
+	m.wg.Wait()
+}
+
+// parseLocalHosts parses "hostname[=internal IPv4]" entries separated by commas
+func parseLocalHosts(value string) ([]LocalHost, error) {
+	var hosts []LocalHost
+	for _, entry := range splitList(value) {
+		hostname, ip, hasIP := strings.Cut(entry, "=")
+		if hostname == "" {
+			return nil, fmt.Errorf("expected hostname or hostname=ipv4, got %q", entry)
+		}
+		if hasIP && (net.ParseIP(ip) == nil || net.ParseIP(ip).To4() == nil) {
+			return nil, fmt.Errorf("invalid internal IPv4 address in %q", entry)
+		}
+		hosts = append(hosts, LocalHost{Hostname: strings.ToLower(hostname), IPv4: ip})
+	}
+	return hosts, nil
+}
+
+// isAddressOf reports whether value is an address of recordType, A or AAAA
+func isAddressOf(recordType, value string) bool {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+	return (ip.To4() != nil) == (recordType == "A")
+}
+
+// LocalDNSConfig configures the local DNS servers mirrored for split-horizon setups
+type LocalDNSConfig struct {
+	Hosts []LocalHost
+	// Routes maps hostname patterns to backend names, without routes the only configured backend is used
+	Routes map[string]string
+
+	// RFC2136Server receives dynamic updates for RFC2136Zone, optionally signed with the TSIG key "name:base64secret"
+	RFC2136Server string
+	RFC2136Zone   string
+	RFC2136TSIG   string
+	RFC2136TTL    int
+
+	PiholeURL      string
+	PiholePassword string
+
+	AdGuardURL      string
+	AdGuardUser     string
+	AdGuardPassword string
+}
+
+// NewBackends creates the configured local DNS backends by name
+func (c LocalDNSConfig) NewBackends() (map[string]LocalDNSBackend, error) {
+	backends := map[string]LocalDNSBackend{}
+	if c.RFC2136Server != "" || c.RFC2136Zone != "" {
+		if c.RFC2136Server == "" || c.RFC2136Zone == "" {
+			return nil, fmt.Errorf("DYNDNS_RFC2136_SERVER and DYNDNS_RFC2136_ZONE have to be set together")
+		}
+		backend, err := NewRFC2136Backend(c.RFC2136Server, c.RFC2136Zone, c.RFC2136TSIG, c.RFC2136TTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid DYNDNS_RFC2136_TSIG: %w", err)
+		}
+		backends[localDNSRFC2136] = backend
+	}
+	if c.PiholeURL != "" {
+		backends[localDNSPihole] = NewPiholeBackend(c.PiholeURL, c.PiholePassword)
+	}
+	if c.AdGuardURL != "" {
+		backends[localDNSAdGuard] = NewAdGuardBackend(c.AdGuardURL, c.AdGuardUser, c.AdGuardPassword)
+	}
+	return backends, nil
+}
+
+// NewRouter creates the router selecting the backend of each mirrored hostname
+func (c LocalDNSConfig) NewRouter() (*LocalDNSRouter, error) {
+	backends, err := c.NewBackends()
+	if err != nil {
+		return nil, err
+	}
+	if len(backends) == 0 {
+		return nil, fmt.Errorf("DYNDNS_LOCAL_HOSTS requires DYNDNS_RFC2136_SERVER, DYNDNS_PIHOLE_URL or DYNDNS_ADGUARD_URL")
+	}
+
+	var fallback LocalDNSBackend
+	if len(c.Routes) == 0 {
+		if len(backends) > 1 {
+			return nil, fmt.Errorf("several local DNS servers are configured, DYNDNS_LOCAL_ROUTES has to select one per hostname")
+		}
+		for _, backend := range backends {
+			fallback = backend
+		}
+	}
+	router := NewLocalDNSRouter(fallback)
+	for pattern, name := range c.Routes {
+		backend, ok := backends[name]
+		if !ok {
+			return nil, fmt.Errorf("DYNDNS_LOCAL_ROUTES uses %s for %s, but it is not configured", name, pattern)
+		}
+		router.Add(pattern, backend)
+	}
+	return router, nil
+}
+
+// Validate checks that every mirrored hostname has a backend to write to
+func (c LocalDNSConfig) Validate() error {
+	if len(c.Hosts) == 0 {
+		return nil
+	}
+	router, err := c.NewRouter()
+	if err != nil {
+		return err
+	}
+	for _, host := range c.Hosts {
+		if _, err := router.BackendFor(host.Hostname); err != nil {
+			return fmt.Errorf("invalid DYNDNS_LOCAL_ROUTES: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingBackend is a LocalDNSBackend remembering all written records
+type recordingBackend struct {
+	mu      sync.Mutex
+	records []string
+}
+
+func (b *recordingBackend) SetRecord(hostname, recordType, value string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(b.records, recordType+" "+hostname+" "+value)
+	return nil
+}
+
+func TestLocalMirror(t *testing.T) {
+	hosts, err := parseLocalHosts("nas.example.com=192.168.178.10,router.example.com")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	backend := &recordingBackend{}
+	mirror := NewLocalMirror(hosts, NewLocalDNSRouter(backend))
+
+	mirror.Handle(Event{Kind: eventIPChange, Hostname: "nas.example.com", Type: "A", NewValue: "203.0.113.1"})
+	mirror.Handle(Event{Kind: eventIPChange, Hostname: "nas.example.com", Type: "AAAA", NewValue: "2001:db8::10"})
+	mirror.Handle(Event{Kind: eventRecordCreated, Hostname: "router.example.com", Type: "A", NewValue: "203.0.113.1"})
+	mirror.Handle(Event{Kind: eventIPChange, Hostname: "home.example.com", Type: "A", NewValue: "203.0.113.1"})
+	mirror.Wait()
+
+	got := strings.Join(backend.records, ";")
+	for _, expected := range []string{
+		"A nas.example.com 192.168.178.10",
+		"AAAA nas.example.com 2001:db8::10",
+		"A router.example.com 203.0.113.1",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("Expected record %q, got %v", expected, backend.records)
+		}
+	}
+	if len(backend.records) != 3 {
+		t.Errorf("Expected three records, got %v", backend.records)
+	}
+}
+
+func TestParseLocalHosts(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expectError bool
+	}{
+		{name: "hostname only", value: "nas.example.com"},
+		{name: "internal address", value: "NAS.example.com=192.168.178.10"},
+		{name: "IPv6 internal address", value: "nas.example.com=fd00::10", expectError: true},
+		{name: "invalid address", value: "nas.example.com=nas", expectError: true},
+		{name: "missing hostname", value: "=192.168.178.10", expectError: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hosts, err := parseLocalHosts(tt.value)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Expected error %v, got %v", tt.expectError, err)
+			}
+			if err == nil && hosts[0].Hostname != "nas.example.com" {
+				t.Errorf("Expected lowercase hostname, got %q", hosts[0].Hostname)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// backupTimeFormat names rotated log files like app-2024-03-01T12-30-45.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// LogFileConfig is the destination of a log stream and its rotation
+type LogFileConfig struct {
+	// Path is the log file, "-" for the standard stream of the log
+	Path   string `yaml:"path"`
+	Format string `yaml:"format"`
+	// MaxSizeMB rotates the file before it grows beyond this size, zero disables it
+	MaxSizeMB int `yaml:"max_size_mb"`
+	// RotateEvery rotates the file at multiples of this interval since midnight UTC, zero disables it
+	RotateEvery time.Duration `yaml:"rotate_every"`
+	// MaxBackups is the number of rotated files kept, zero keeps all of them
+	MaxBackups int  `yaml:"max_backups"`
+	Compress   bool `yaml:"compress"`
+}
+
+// LoggingConfig is the file referenced by DYNDNS_LOGGING_FILE
+type LoggingConfig struct {
+	Application LogFileConfig `yaml:"application"`
+	Access      LogFileConfig `yaml:"access"`
+	// Audit receives the authentication failures
+	Audit LogFileConfig `yaml:"audit"`
+}
+
+// loadLoggingFile reads the log destinations from a YAML file
+func loadLoggingFile(path string) (LoggingConfig, error) {
+	var logging LoggingConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return logging, err
+	}
+	if err := yaml.Unmarshal(data, &logging); err != nil {
+		return logging, err
+	}
+	for name, file := range map[string]LogFileConfig{"application": logging.Application, "access": logging.Access, "audit": logging.Audit} {
+		if file.MaxSizeMB < 0 || file.RotateEvery < 0 || file.MaxBackups < 0 {
+			return logging, fmt.Errorf("%s: max_size_mb, rotate_every and max_backups must not be negative", name)
+		}
+		if file.Path == "-" && (file.MaxSizeMB > 0 || file.RotateEvery > 0) {
+			return logging, fmt.Errorf("%s: rotation requires a file path", name)
+		}
+	}
+	return logging, nil
+}
+
+// rotatingFile is a log file rotated by size and time. Rotated files get the
+// time of the rotation in their name and are optionally compressed with gzip.
+type rotatingFile struct {
+	config LogFileConfig
+	now    func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+
+	// cleanup serializes compressing and pruning the backups, which run in
+	// the background so writes are not blocked; pending tracks them
+	cleanup sync.Mutex
+	pending sync.WaitGroup
+}
+
+// openLogFile opens the log file of config for appending
+func openLogFile(config LogFileConfig) (*rotatingFile, error) {
+	f := &rotatingFile{config: config, now: time.Now}
+	if err := f.Reopen(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reopen opens the file again, e.g. after logrotate moved it away. A file
+// continued from an earlier run keeps the rotation period of its last change.
+func (f *rotatingFile) Reopen() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file != nil {
+		f.file.Close()
+	}
+	return f.open()
+}
+
+// open opens the file, the caller holds mu
+func (f *rotatingFile) open() error {
+	if dir := filepath.Dir(f.config.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return fmt.Errorf("failed to create log directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(f.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	f.file, f.size, f.opened = file, info.Size(), info.ModTime()
+	if info.Size() == 0 {
+		f.opened = f.now()
+	}
+	return nil
+}
+
+// Write appends p, rotating the file first if p would exceed the size limit
+// or the rotation period has passed
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.size > 0 && f.due(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// due reports whether the file has to be rotated before writing size bytes
+func (f *rotatingFile) due(size int64) bool {
+	if f.config.MaxSizeMB > 0 && f.size+size > int64(f.config.MaxSizeMB)<<20 {
+		return true
+	}
+	interval := f.config.RotateEvery
+	return interval > 0 && !f.now().UTC().Truncate(interval).Equal(f.opened.UTC().Truncate(interval))
+}
+
+// rotate moves the file to a backup, opens a new one and removes old backups
+func (f *rotatingFile) rotate() error {
+	f.file.Close()
+	backup := f.backupName(f.now())
+	if err := os.Rename(f.config.Path, backup); err != nil {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.pending.Add(1)
+	go func() {
+		defer f.pending.Done()
+		f.cleanup.Lock()
+		defer f.cleanup.Unlock()
+		// The log package may be writing to this file, so errors go to stderr
+		if f.config.Compress {
+			if err := compressFile(backup); err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to compress %s: %v\n", backup, err)
+			}
+		}
+		f.prune()
+	}()
+	return nil
+}
+
+// backupName returns the name of the file rotated at t
+func (f *rotatingFile) backupName(t time.Time) string {
+	ext := filepath.Ext(f.config.Path)
+	return strings.TrimSuffix(f.config.Path, ext) + "-" + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// prune removes the oldest backups beyond MaxBackups, the caller holds cleanup
+func (f *rotatingFile) prune() {
+	if f.config.MaxBackups <= 0 {
+		return
+	}
+	backups := f.backups()
+	// The timestamps sort chronologically
+	sort.Strings(backups)
+	for len(backups) > f.config.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+// backups returns the files rotated by backupName, optionally compressed.
+// Other files next to the log, e.g. app-access.log, are not matched.
+func (f *rotatingFile) backups() []string {
+	dir := filepath.Dir(f.config.Path)
+	ext := filepath.Ext(f.config.Path)
+	prefix := strings.TrimSuffix(filepath.Base(f.config.Path), ext) + "-"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(name, prefix)
+		if !ok {
+			continue
+		}
+		stamp = strings.TrimSuffix(stamp, ".gz")
+		stamp, ok = strings.CutSuffix(stamp, ext)
+		if !ok || len(stamp) != len(backupTimeFormat) {
+			continue
+		}
+		if _, err := time.Parse(backupTimeFormat, stamp); err != nil {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	return backups
+}
+
+// compressFile replaces path with path.gz
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// openLogDestination opens the file of config, "-" returns std. Opened files
+// are added to files so they can be reopened on SIGHUP.
+func openLogDestination(config LogFileConfig, std io.Writer, files *[]*rotatingFile) (io.Writer, error) {
+	if config.Path == "-" {
+		return std, nil
+	}
+	file, err := openLogFile(config)
+	if err != nil {
+		return nil, err
+	}
+	*files = append(*files, file)
+	return file, nil
+}
+
+// reopenOnHangup reopens files whenever the process receives SIGHUP
+func reopenOnHangup(files []*rotatingFile) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	for range hangups {
+		for _, file := range files {
+			if err := file.Reopen(); err != nil {
+				log.Printf("Failed to reopen %s: %v", file.config.Path, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadLoggingFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expected      LoggingConfig
+		errorContains string
+	}{
+		{
+			name: "all destinations",
+			content: `application:
+  path: /var/log/dyndns/dyndns.log
+  max_size_mb: 10
+  max_backups: 5
+  compress: true
+access:
+  path: /var/log/dyndns/access.log
+  format: combined
+  rotate_every: 24h
+audit:
+  path: "-"
+`,
+			expected: LoggingConfig{
+				Application: LogFileConfig{Path: "/var/log/dyndns/dyndns.log", MaxSizeMB: 10, MaxBackups: 5, Compress: true},
+				Access:      LogFileConfig{Path: "/var/log/dyndns/access.log", Format: "combined", RotateEvery: 24 * time.Hour},
+				Audit:       LogFileConfig{Path: "-"},
+			},
+		},
+		{name: "negative size", content: "access:\n  path: a.log\n  max_size_mb: -1\n", errorContains: "access"},
+		{name: "rotating a stream", content: "audit:\n  path: \"-\"\n  rotate_every: 1h\n", errorContains: "requires a file path"},
+		{name: "invalid YAML", content: "application: [", errorContains: "yaml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "logging.yaml")
+			os.WriteFile(path, []byte(tt.content), 0o600)
+			logging, err := loadLoggingFile(path)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(logging, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, logging)
+			}
+		})
+	}
+}
+
+// logFiles returns the names of the files in dir
+func logFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestRotatingFileSize(t *testing.T) {
+	dir := t.TempDir()
+	// Other logs next to the file are not backups
+	for _, name := range []string{"app-access.log", "app-2024-01-01.log"} {
+		os.WriteFile(filepath.Join(dir, name), nil, 0o600)
+	}
+	file, err := openLogFile(LogFileConfig{Path: filepath.Join(dir, "app.log"), MaxSizeMB: 1, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	file.now = func() time.Time { return now }
+
+	line := []byte(strings.Repeat("x", 1<<19-1) + "\n")
+	for range 7 {
+		if _, err := file.Write(line); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	file.pending.Wait()
+
+	// Two lines fit into a file, the oldest backup was removed
+	expected := []string{"app-2024-01-01.log", "app-2024-03-01T12-00-04.000.log", "app-2024-03-01T12-00-06.000.log", "app-access.log", "app.log"}
+	if names := logFiles(t, dir); !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected files %v, got %v", expected, names)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "app.log")); info.Size() != int64(len(line)) {
+		t.Errorf("Expected the current file to hold one line, got %d bytes", info.Size())
+	}
+}
+
+func TestRotatingFileTime(t *testing.T) {
+	dir := t.TempDir()
+	file, err := openLogFile(LogFileConfig{Path: filepath.Join(dir, "access.log"), RotateEvery: 24 * time.Hour, Compress: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	now := time.Date(2024, 3, 1, 23, 59, 0, 0, time.UTC)
+	file.now = func() time.Time { return now }
+	file.opened = now
+
+	file.Write([]byte("first day\n"))
+	now = now.Add(2 * time.Minute)
+	file.Write([]byte("second day\n"))
+	file.pending.Wait()
+
+	expected := []string{"access-2024-03-02T00-01-00.000.log.gz", "access.log"}
+	if names := logFiles(t, dir); !reflect.DeepEqual(names, expected) {
+		t.Fatalf("Expected files %v, got %v", expected, names)
+	}
+	compressed, err := os.Open(filepath.Join(dir, expected[0]))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer compressed.Close()
+	zr, err := gzip.NewReader(compressed)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data, _ := io.ReadAll(zr); string(data) != "first day\n" {
+		t.Errorf("Expected the first day in the backup, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "access.log")); string(data) != "second day\n" {
+		t.Errorf("Expected the second day in the current file, got %q", data)
+	}
+}
+
+func TestRotatingFileReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	file, err := openLogFile(LogFileConfig{Path: path})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	file.Write([]byte("before\n"))
+
+	// logrotate moves the file away and signals the bridge
+	rotated := path + ".1"
+	if err := os.Rename(path, rotated); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := file.Reopen(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	file.Write([]byte("after\n"))
+
+	for name, expected := range map[string]string{rotated: "before\n", path: "after\n"} {
+		if data, err := os.ReadFile(name); err != nil || string(data) != expected {
+			t.Errorf("Expected %q in %s, got %q %v", expected, name, data, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"log"
+	"sync"
+)
+
+// lookupCache remembers where the record of a hostname lives, so updates can
+// fetch just that record by ID instead of listing the whole zone each time
+type lookupCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedLookup
+}
+
+// cachedLookup is the location of a single record
+type cachedLookup struct {
+	Zone     Zone
+	Name     string
+	RecordID string
+}
+
+// newLookupCache creates an empty lookup cache
+func newLookupCache() *lookupCache {
+	return &lookupCache{entries: make(map[string]cachedLookup)}
+}
+
+// Get returns the cached location of the record of hostname and recordType
+func (c *lookupCache) Get(hostname, recordType string) (cachedLookup, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[hostname+"/"+recordType]
+	return entry, ok
+}
+
+// Put remembers the location of a record
+func (c *lookupCache) Put(hostname, recordType string, entry cachedLookup) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[hostname+"/"+recordType] = entry
+}
+
+// Forget drops the cached location of a record, e.g. after it was deleted
+func (c *lookupCache) Forget(hostname, recordType string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, hostname+"/"+recordType)
+}
+
+// Len returns the number of cached record locations
+func (c *lookupCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// cachedRecord fetches a record from its cached location. It returns nil if
+// the location is unknown or stale, in which case the zone has to be listed.
+// Ownership checks need the marker records of the zone, so the cache is only
+// used without an owner ID. Round-robin hostnames hold several records of a
+// type, which are always listed.
+func (s *DynDNSServer) cachedRecord(client *Client, hostname, recordType string) *recordLookup {
+	if s.lookups == nil || s.ownerID != "" || s.isRoundRobin(hostname) {
+		return nil
+	}
+	entry, ok := s.lookups.Get(hostname, recordType)
+	if !ok {
+		return nil
+	}
+
+	record, err := client.GetRecord(entry.RecordID)
+	if err != nil || record.Name != entry.Name || record.Type != recordType {
+		log.Printf("Cached record %s for %s (%s) is stale, listing zone", entry.RecordID, hostname, recordType)
+		s.lookups.Forget(hostname, recordType)
+		return nil
+	}
+
+	zone := entry.Zone
+	return &recordLookup{
+		Client:   client,
+		Zone:     &zone,
+		Name:     entry.Name,
+		Records:  []DNSRecord{*record},
+		Existing: record,
+	}
+}
+
+// rememberRecord caches the location of a record for later updates of hostname
+func (s *DynDNSServer) rememberRecord(hostname, recordType string, zone *Zone, name, recordID string) {
+	if s.lookups == nil || recordID == "" || s.isRoundRobin(hostname) {
+		return
+	}
+	s.lookups.Put(hostname, recordType, cachedLookup{Zone: *zone, Name: name, RecordID: recordID})
+}
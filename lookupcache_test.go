@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDirectLookup(t *testing.T) {
+	fake, client := newFakeAPIServer(t, DNSRecord{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"})
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.lookups = newLookupCache()
+
+	if err := server.updateDNSRecord("home.example.com", "1.2.3.4", "A"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	before := len(fake.Requests())
+	if err := server.updateDNSRecord("home.example.com", "1.2.3.5", "A"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if requests := fake.Requests()[before:]; strings.Join(requests, ";") != "GET /records/rec1;PUT /records/rec1" {
+		t.Errorf("Expected the cached record to be fetched by ID, got %v", requests)
+	}
+}
+
+func TestDirectLookupStale(t *testing.T) {
+	_, client := newFakeAPIServer(t)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.lookups = newLookupCache()
+	server.lookups.Put("home.example.com", "A", cachedLookup{Zone: Zone{ID: "zone1"}, Name: "home", RecordID: "deleted"})
+
+	if lookup := server.cachedRecord(client, "home.example.com", "A"); lookup != nil {
+		t.Error("Expected stale cache entry to be ignored")
+	}
+	if _, ok := server.lookups.Get("home.example.com", "A"); ok {
+		t.Error("Expected stale cache entry to be forgotten")
+	}
+}
@@ -1,38 +1,465 @@
 package main
 
 import (
+	"crypto/x509"
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
+	"slices"
+	"strings"
+	"time"
 )
 
 func main() {
-	// Get API key from environment variable
-	apiKey := os.Getenv("HETZNER_DNS_API_KEY")
-	if apiKey == "" {
-		log.Fatal("HETZNER_DNS_API_KEY environment variable is required")
+	if len(os.Args) == 2 && os.Args[1] == "--version" {
+		info := currentBuildInfo()
+		fmt.Printf("hetzner-dyndns %s (commit %s, built %s, %s)\n", info.Version, info.Commit, info.BuildDate, info.GoVersion)
+		return
 	}
 
-	// Get DynDNS credentials from environment
-	username := os.Getenv("DYNDNS_USERNAME")
-	if username == "" {
-		username = "admin" // Default username
+	// The dashboard is generated from code and needs no configuration
+	if len(os.Args) > 1 && os.Args[1] == "dashboard" {
+		if err := runDashboardCommand(os.Args[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	password := os.Getenv("DYNDNS_PASSWORD")
-	if password == "" {
-		log.Fatal("DYNDNS_PASSWORD environment variable is required")
+	// Importing the configuration of another client needs no configuration either
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImportCommand(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	port := os.Getenv("DYNDNS_PORT")
-	if port == "" {
-		port = "8080" // Default port
+	// The fake API for integration tests runs without the bridge
+	if len(os.Args) > 1 && os.Args[1] == "fakeapi" {
+		if err := runFakeAPI(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	// Create Hetzner DNS client
-	client := NewClient(apiKey)
+	// --uci reads the configuration of the OpenWrt package, the environment takes precedence
+	if i := slices.Index(os.Args, "--uci"); i > 0 {
+		if i+1 == len(os.Args) {
+			log.Fatalf("--uci requires a file, - for stdin\n%s", cliUsage)
+		}
+		if err := applyUCI(os.Args[i+1]); err != nil {
+			log.Fatal(err)
+		}
+		os.Args = slices.Delete(os.Args, i, i+2)
+	}
+
+	// --mock-provider is a shorthand for DYNDNS_MOCK_PROVIDER=true and combines with any subcommand
+	if i := slices.Index(os.Args, "--mock-provider"); i > 0 {
+		os.Args = slices.Delete(os.Args, i, i+1)
+		os.Setenv("DYNDNS_MOCK_PROVIDER", "true")
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// --print-config shows the effective configuration with masked secrets and exits
+	if len(os.Args) == 2 && os.Args[1] == "--print-config" {
+		printConfig(cfg, os.Stdout)
+		return
+	}
+
+	// Log files are reopened on SIGHUP, e.g. by logrotate, unless they rotate themselves
+	var logFiles []*rotatingFile
+	var logOutput io.Writer = os.Stderr
+	if cfg.LogFile != "" {
+		application := cfg.Logging.Application
+		application.Path = cfg.LogFile
+		if logOutput, err = openLogDestination(application, os.Stderr, &logFiles); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Mask addresses and hostnames before logs leave the process
+	if cfg.LogPrivacy != logPrivacyOff {
+		logOutput = newRedactingWriter(logOutput, cfg.LogPrivacy)
+	}
+	log.SetOutput(logOutput)
+
+	store, err := NewStore(cfg.Store)
+	if err != nil {
+		log.Fatalf("Failed to open %s store: %v", cfg.Store.Type, err)
+	}
+	defer store.Close()
+
+	// Create DynDNS server, its metrics also cover the API requests
+	server := NewDynDNSServer(nil, cfg.Username, cfg.Password, cfg.Port)
+	server.SetStore(store)
+
+	transport, err := NewTransport(cfg.Transport)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var apiRoundTripper http.RoundTripper = transport
+	if cfg.MockProvider {
+		// Every client, whatever its token, talks to the same fake backend
+		fake, err := newFakeHetzner(cfg.MockZones, cfg.MockState)
+		if err != nil {
+			log.Fatalf("Failed to start the mock provider: %v", err)
+		}
+		apiRoundTripper = fake
+		log.Printf("WARNING: mock provider enabled, zones %s are served from memory and no record reaches Hetzner DNS", strings.Join(cfg.MockZones, ", "))
+		if cfg.MockDNSListen != "" {
+			go func() {
+				log.Printf("Mock provider: answering DNS queries on %s/udp", cfg.MockDNSListen)
+				if err := fake.ServeDNS(cfg.MockDNSListen); err != nil {
+					log.Printf("Mock provider: DNS responder stopped: %v", err)
+				}
+			}()
+		}
+	}
+	apiTransport := newMeteredTransport(apiRoundTripper, server.metrics)
+	newClient := func(apiKey string) *Client {
+		client := NewClient(apiKey)
+		client.BaseURL = cfg.APIURL
+		client.PageSize = cfg.PageSize
+		client.FilterByName = cfg.RecordFilter
+		if cfg.ZoneIndexInterval > 0 {
+			client.EnableZoneIndex(cfg.ZoneIndexInterval)
+		}
+		client.HTTPClient.Transport = apiTransport
+		client.EnableRequestLog(cfg.APILogLevel, cfg.APILogSampleRate)
+		return client
+	}
+
+	// Create Hetzner DNS client for the default token
+	var client *Client
+	if cfg.APIKey != "" {
+		client = newClient(cfg.APIKey)
+		server.client = client
+	}
+
+	// Zones listed in DYNDNS_ZONE_TOKENS use their own token, secondary tokens
+	// take over when a primary token keeps being rejected
+	if client != nil && cfg.SecondaryAPIKey != "" {
+		server.enableTokenFailover(client, "default", cfg.SecondaryAPIKey, cfg.TokenFailoverAfter)
+	}
+	server.router = NewClientRouter(client)
+	server.zoneTokensFile = cfg.ZoneTokensFile
+	for pattern, token := range cfg.ZoneTokens {
+		zoneClient := newClient(token.Primary)
+		if token.Secondary != "" {
+			server.enableTokenFailover(zoneClient, pattern, token.Secondary, cfg.TokenFailoverAfter)
+		}
+		server.router.Add(pattern, zoneClient)
+		if cfg.CircuitBreakerAfter > 0 {
+			server.enableCircuitBreaker(zoneClient, pattern, cfg.CircuitBreakerAfter, cfg.CircuitBreakerCooldown, cfg.CircuitBreakerMaxCooldown)
+		}
+	}
+	if client != nil && cfg.CircuitBreakerAfter > 0 {
+		server.enableCircuitBreaker(client, "default", cfg.CircuitBreakerAfter, cfg.CircuitBreakerCooldown, cfg.CircuitBreakerMaxCooldown)
+	}
+	if cfg.MinUpdateInterval > 0 {
+		server.limiter = NewRateLimiter(cfg.MinUpdateInterval)
+	}
+	server.usage.defaults = usageQuota{Updates: cfg.QuotaUpdates, Writes: cfg.QuotaWrites}
+	if cfg.DirectLookup {
+		server.lookups = newLookupCache()
+	}
+	if cfg.NohostTTL > 0 {
+		server.nohost = newNohostCache(cfg.NohostTTL, cfg.NohostMaxTTL)
+	}
+	server.listenAddrs = cfg.ListenAddrs
+	server.listenFamily = cfg.ListenFamily
+	server.ipv6InterfaceID = cfg.IPv6InterfaceID
+	server.ownerID = cfg.OwnerID
+	if cfg.Annotations {
+		server.annotations = newAnnotationTracker()
+	}
+	server.hostnames = cfg.Hostnames
+	server.basePath = cfg.BasePath
+	server.emulations = cfg.Emulations
+	if len(cfg.DSLiteHosts) > 0 {
+		server.dsLite = newDSLiteHosts(cfg.DSLiteHosts, cfg.DSLiteDeleteA)
+	}
+	if len(cfg.RoundRobinHosts) > 0 {
+		server.roundRobin = newRoundRobinHosts(cfg.RoundRobinHosts, cfg.RoundRobinSources)
+	}
+	server.updateTimeout = cfg.UpdateTimeout
+	server.fastAck = cfg.FastAck
+	server.churn.threshold = cfg.FlapThreshold
+	server.history.limit = cfg.HistoryLimit
+	server.maxSubdomainDepth = cfg.MaxSubdomainDepth
+	server.subdomainDepths = cfg.SubdomainDepths
+	server.strict = cfg.Strict
+	server.conflictPolicy = cfg.ConflictPolicy
+	server.deletable = cfg.DeletableHosts
+	server.allowPost = cfg.AllowPost
+	server.rejectBogons = !cfg.AllowPrivateIPs
+	server.profileUsers = cfg.ProfileUsers
+	server.emptyIP = cfg.EmptyIPModes
+	server.connectionIPUsers = cfg.ConnectionIPUsers
+	server.requireAgent = cfg.RequireUserAgent
+	server.blockedAgents = cfg.BlockedUserAgents
+	if server.responses, err = ParseResponseTemplates(cfg.Responses); err != nil {
+		log.Fatal(err)
+	}
+	if err := server.configureAuth(cfg.Auth); err != nil {
+		log.Fatal(err)
+	}
+	if cfg.TLSCert != "" {
+		server.tlsCert, server.tlsKey = cfg.TLSCert, cfg.TLSKey
+	}
+	if cfg.TLSClientCA != "" {
+		ca, err := os.ReadFile(cfg.TLSClientCA)
+		if err != nil {
+			log.Fatalf("Failed to read client CA: %v", err)
+		}
+		server.clientCAs = x509.NewCertPool()
+		if !server.clientCAs.AppendCertsFromPEM(ca) {
+			log.Fatalf("Invalid client CA certificate %s", cfg.TLSClientCA)
+		}
+	}
+	if cfg.AuthLog != "" {
+		audit := cfg.Logging.Audit
+		audit.Path = cfg.AuthLog
+		out, err := openLogDestination(audit, os.Stderr, &logFiles)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.authLog = NewAuthLogger(out, cfg.AuthLogFormat)
+	}
+	if cfg.AccessLog != "" {
+		access := cfg.Logging.Access
+		access.Path = cfg.AccessLog
+		out, err := openLogDestination(access, os.Stdout, &logFiles)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.accessLog = NewAccessLogger(out, cfg.AccessLogFormat, cfg.LogPrivacy)
+	}
+	if len(logFiles) > 0 {
+		go reopenOnHangup(logFiles)
+	}
+	if cfg.BlocklistFile != "" {
+		blocklist, err := NewFileBlocklist(cfg.BlocklistFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.addBlocklist(blocklist)
+	}
+	if cfg.CrowdSecURL != "" {
+		server.addBlocklist(NewCrowdSecBlocklist(cfg.CrowdSecURL, cfg.CrowdSecAPIKey))
+	}
+	if cfg.GeoIPDatabase != "" {
+		geoip, err := OpenGeoIPDatabase(cfg.GeoIPDatabase)
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.setGeoIP(geoip, cfg.GeoIPCountries, cfg.GeoIPRejectUnknown)
+	}
+
+	// check-config validates the configuration against the API and endpoints, e.g. in CI
+	if len(os.Args) == 2 && os.Args[1] == "check-config" {
+		if err := runCheckConfig(server, cfg, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Subcommands run once against the API instead of starting the server
+	if len(os.Args) > 1 {
+		if err := runCommand(server, os.Args[1:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	// Optional notifications of IP changes, failed updates and alerts
+	if server.notifications, err = cfg.Notify.NewNotifications(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Optional MQTT integration for events and update commands
+	if cfg.MQTT.Broker != "" {
+		server.mqtt = NewMQTTBridge(server, cfg.MQTT)
+		go server.mqtt.Run()
+		defer server.mqtt.Close()
+	}
+
+	// External commands run for update events, e.g. to restart a VPN when the address changes
+	for _, command := range cfg.Hooks {
+		server.events.Subscribe(NewExecHook(command, cfg.HookTimeout).Handle, cfg.HookEvents...)
+	}
+
+	// Built-in hook keeping WireGuard peer endpoints on the tracked hostnames
+	if len(cfg.WireGuardPeers) > 0 {
+		server.events.Subscribe(NewWireGuardHook(cfg.WireGuardPeers).Handle, eventIPChange, eventRecordCreated)
+	}
+
+	// Alert when forwarded ports are not reachable on the new address
+	var checker *PortChecker
+	if len(cfg.PortChecks) > 0 {
+		checker = NewPortChecker(server, cfg.PortChecks, cfg.PortCheckURL, cfg.PortCheckDelay, cfg.PortCheckTimeout)
+		server.events.Subscribe(checker.Handle, eventIPChange, eventRecordCreated)
+	}
+
+	// Records with a backup address switch over while the checked ports of the primary are down
+	if len(cfg.BackupIPs) > 0 {
+		server.backups = NewBackupMonitor(server, checker, cfg.BackupIPs, cfg.BackupInterval, cfg.BackupFailAfter, cfg.BackupRecoverAfter)
+		go server.backups.Run(server.done)
+	}
+
+	// Availability samples for the monthly report, probing the checked ports as well
+	if cfg.SLAInterval > 0 {
+		go NewSLAProber(server, checker, cfg.SLAInterval).Run(nil)
+	}
+
+	// PTR records of Hetzner servers follow their forward records
+	if len(cfg.ReverseDNS.Targets) > 0 {
+		server.events.Subscribe(NewReverseDNSUpdater(cfg.ReverseDNS).Handle, eventIPChange, eventRecordCreated)
+	}
+
+	// Cloud firewall rules follow the current address, e.g. to restrict SSH to home
+	if len(cfg.Firewall.Targets) > 0 {
+		server.events.Subscribe(NewFirewallSync(cfg.Firewall).Handle, eventIPChange, eventRecordCreated)
+	}
+
+	// Split-horizon DNS, LAN clients resolve the internal address through the local server
+	if len(cfg.LocalDNS.Hosts) > 0 {
+		router, err := cfg.LocalDNS.NewRouter()
+		if err != nil {
+			log.Fatal(err)
+		}
+		server.events.Subscribe(NewLocalMirror(cfg.LocalDNS.Hosts, router).Handle, eventIPChange, eventRecordCreated)
+	}
+
+	// Optional Telegram bot answering /status and /forceupdate
+	if cfg.Notify.TelegramCommands {
+		chatIDs, err := parseChatIDs(cfg.Notify.TelegramAllowedChats)
+		if err != nil {
+			log.Fatal(err)
+		}
+		detector := NewIPDetector(cfg.IPv4DetectURL, cfg.IPv6DetectURL)
+		bot := NewTelegramBot(server, detector, cfg.Notify.TelegramToken, chatIDs)
+		go bot.Run(nil)
+	}
+
+	// Writes failing while the API is down are parked and retried instead of answered with 911
+	if cfg.RetryInterval > 0 {
+		server.retries = NewRetryQueue(server)
+		go server.retries.Run(cfg.RetryInterval, nil)
+	}
 
-	// Create and start DynDNS server
-	server := NewDynDNSServer(client, username, password, port)
+	// Optional daily summary of all managed hostnames
+	if len(cfg.Report.Sinks) > 0 {
+		sinks, err := cfg.Report.NewSinks()
+		if err != nil {
+			log.Fatal(err)
+		}
+		reporter := NewReporter(server, sinks...)
+		go reporter.Run(cfg.Report.At, nil)
+	}
+
+	// Optional DNSSEC detection warning about rapid changes in signed zones
+	if cfg.DNSSEC {
+		server.dnssec = NewDNSSECMonitor(cfg.DNSSECResolver, cfg.DNSSECChurnWindow, cfg.DNSSECDelay)
+	}
+
+	// Optional verification that updates are served by the authoritative nameservers
+	if cfg.Verify {
+		server.verifier = NewVerifier(cfg.VerifyTimeout, 5*time.Second, server.metrics)
+	}
+	if cfg.PropagationWait > 0 {
+		server.propagation = NewVerifier(cfg.PropagationWait, time.Second, server.metrics)
+	}
+
+	// Tenants share the bridge with their own credentials, zones, state and notifications
+	for _, tenant := range cfg.Tenants {
+		var tenantClient *Client
+		if tenant.APIToken != "" {
+			tenantClient = newClient(tenant.APIToken)
+		}
+		if err := server.addTenant(tenant, tenantClient, cfg.MinUpdateInterval); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Optional janitor deleting owned records that are no longer refreshed
+	if cfg.StaleAfter > 0 {
+		janitor := NewJanitor(server, cfg.StaleAfter, cfg.JanitorDryRun)
+		go janitor.Run(cfg.JanitorInterval, nil)
+	}
+
+	// Correct drift of configured hostnames missed while the bridge was down
+	var reconciler *Reconciler
+	if len(cfg.Hostnames) > 0 {
+		detector := NewIPDetector(cfg.IPv4DetectURL, cfg.IPv6DetectURL)
+		reconciler = NewReconciler(server, detector, cfg.Hostnames)
+		go reconciler.Run(cfg.ReconcileInterval, nil)
+	}
+
+	// Optional polling of the FritzBox addresses, the router then does not need to push updates
+	if cfg.FritzBoxURL != "" {
+		poller := NewTR064Poller(server, NewTR064Client(cfg.FritzBoxURL), cfg.Hostnames)
+		go poller.Run(cfg.FritzBoxInterval, nil)
+	}
+
+	// Records declared in the manifest are kept in their desired state, optionally pulled from Git
+	if cfg.Manifest != "" {
+		detector := NewIPDetector(cfg.IPv4DetectURL, cfg.IPv6DetectURL)
+		server.manifest = NewManifestReconciler(server, detector, cfg.Manifest, cfg.ManifestRecords, cfg.ManifestDryRun)
+		if cfg.Git.Repo != "" {
+			server.gitSync = NewGitSync(server, server.manifest, cfg.Git)
+			if _, err := server.gitSync.Sync(); err != nil {
+				log.Printf("Git sync: %v", err)
+			}
+			go server.gitSync.Run(nil)
+		}
+		go server.manifest.Run(cfg.ManifestInterval, nil)
+	}
+
+	// Optional gRPC API for programmatic integrations
+	if cfg.GRPCPort != "" {
+		grpcService := NewGRPCService(server, reconciler, cfg.Auth.Tokens)
+		go func() {
+			log.Printf("Starting gRPC API on :%s", cfg.GRPCPort)
+			log.Fatal(grpcService.ListenAndServe(cfg.GRPCPort, cfg.TLSCert, cfg.TLSKey))
+		}()
+	}
+
+	// Optional controller mode for annotated Kubernetes Services and Ingresses
+	if cfg.Kubernetes {
+		kube, err := NewInClusterKubeClient()
+		if err != nil {
+			log.Fatalf("Failed to create Kubernetes client: %v", err)
+		}
+		watcher := NewKubeWatcher(server, kube)
+		go watcher.Run(cfg.KubernetesInterval, nil)
+	}
+
+	// Optional watcher for labeled Docker containers
+	if cfg.DockerSocket != "" {
+		detector := NewIPDetector(cfg.IPv4DetectURL, cfg.IPv6DetectURL)
+		watcher := NewDockerWatcher(server, NewDockerClient(cfg.DockerSocket), detector)
+		go watcher.Run(cfg.DockerInterval, nil)
+	}
+
+	// Profiling and internal state for diagnosing long-running instances
+	server.debug = cfg.Debug
+	if cfg.DebugListen != "" {
+		go func() {
+			log.Fatal(server.serveDebug(cfg.DebugListen))
+		}()
+	}
+
+	// Fill the zone index and lookup cache before the first updates arrive
+	if cfg.WarmUp {
+		go server.warmUp(warmUpHostnames(cfg.Hostnames, cfg.DeletableHosts))
+	}
 
 	log.Printf("Starting DynDNS bridge for FritzBox -> Hetzner DNS")
 	if err := server.Start(); err != nil {
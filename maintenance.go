@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Maintenance modes selecting what happens to updates received during maintenance
+const (
+	// maintenanceQueue keeps the latest value per record and writes it when maintenance ends
+	maintenanceQueue = "queue"
+	// maintenanceNoChange answers nochg and drops the updates
+	maintenanceNoChange = "nochg"
+)
+
+// errMaintenance is returned for writes refused while the bridge is in maintenance mode
+var errMaintenance = errors.New("maintenance mode is active, no changes are written to the DNS API")
+
+// maintenanceResponse is the JSON schema of /api/maintenance
+type maintenanceResponse struct {
+	Active bool       `json:"active"`
+	Since  *time.Time `json:"since,omitempty"`
+	Reason string     `json:"reason,omitempty"`
+	Mode   string     `json:"mode,omitempty"`
+	// Queued counts the updates held back until maintenance ends
+	Queued int `json:"queued"`
+	// Applied and Failed count the queued updates written when maintenance ended
+	Applied int `json:"applied,omitempty"`
+	Failed  int `json:"failed,omitempty"`
+}
+
+// maintenanceMode holds back all writes to the DNS API, e.g. while zones are
+// migrated. It is shared by all tenants, queued updates remember the server
+// they were submitted to.
+type maintenanceMode struct {
+	mu      sync.Mutex
+	since   time.Time
+	reason  string
+	mode    string
+	pending map[*DynDNSServer]map[string]parkedWrite
+	now     func() time.Time
+}
+
+// newMaintenanceMode creates an inactive maintenance mode
+func newMaintenanceMode(metrics *Metrics) *maintenanceMode {
+	metrics.Describe("dyndns_maintenance_active", "gauge", "1 while maintenance mode holds back writes to the DNS API.")
+	metrics.Describe("dyndns_maintenance_queued_updates", "gauge", "Number of updates queued until maintenance ends.")
+	return &maintenanceMode{pending: make(map[*DynDNSServer]map[string]parkedWrite), now: time.Now}
+}
+
+// Active reports whether writes are currently held back
+func (m *maintenanceMode) Active() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return !m.since.IsZero()
+}
+
+// Begin starts maintenance, or changes reason and mode of the running one
+func (m *maintenanceMode) Begin(reason, mode string) maintenanceResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.since.IsZero() {
+		m.since = m.now().UTC()
+	}
+	m.reason, m.mode = reason, mode
+	return m.snapshot()
+}
+
+// Hold queues write submitted to server and returns the decision reported to
+// the client. It reports false if maintenance is not active.
+func (m *maintenanceMode) Hold(server *DynDNSServer, write parkedWrite) (rateDecision, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.since.IsZero() {
+		return rateAllow, false
+	}
+	if m.mode == maintenanceNoChange {
+		log.Printf("Maintenance: dropping %s update of %s to %s", write.Type, write.Hostname, write.Value)
+		return rateNoChange, true
+	}
+	if m.pending[server] == nil {
+		m.pending[server] = make(map[string]parkedWrite)
+	}
+	m.pending[server][write.key()] = write
+	log.Printf("Maintenance: queued %s update of %s to %s", write.Type, write.Hostname, write.Value)
+	server.metrics.Set("dyndns_maintenance_queued_updates", nil, float64(m.queued()))
+	return rateQueued, true
+}
+
+// End stops maintenance and writes the queued updates through the servers they were submitted to
+func (m *maintenanceMode) End() maintenanceResponse {
+	m.mu.Lock()
+	response := m.snapshot()
+	pending := m.pending
+	m.since, m.reason, m.mode = time.Time{}, "", ""
+	m.pending = make(map[*DynDNSServer]map[string]parkedWrite)
+	m.mu.Unlock()
+
+	for server, writes := range pending {
+		server.metrics.Set("dyndns_maintenance_queued_updates", nil, 0)
+		for _, write := range writes {
+			if _, err := server.submit(write); err != nil {
+				log.Printf("Maintenance: failed to apply queued %s update of %s: %v", write.Type, write.Hostname, err)
+				response.Failed++
+				continue
+			}
+			response.Applied++
+		}
+	}
+	response.Active = false
+	return response
+}
+
+// Snapshot returns the current state
+func (m *maintenanceMode) Snapshot() maintenanceResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.snapshot()
+}
+
+// snapshot returns the current state, the caller holds mu
+func (m *maintenanceMode) snapshot() maintenanceResponse {
+	response := maintenanceResponse{Active: !m.since.IsZero(), Reason: m.reason, Mode: m.mode, Queued: m.queued()}
+	if response.Active {
+		since := m.since
+		response.Since = &since
+	}
+	return response
+}
+
+// queued counts the pending updates of all servers, the caller holds mu
+func (m *maintenanceMode) queued() int {
+	queued := 0
+	for _, writes := range m.pending {
+		queued += len(writes)
+	}
+	return queued
+}
+
+// rejectMaintenance answers 503 to API requests that would write records during maintenance
+func (s *DynDNSServer) rejectMaintenance(w http.ResponseWriter, r *http.Request) bool {
+	if !s.maintenance.Active() {
+		return false
+	}
+	w.Header().Set("Retry-After", "60")
+	httpError(w, r, errMaintenance.Error(), http.StatusServiceUnavailable)
+	return true
+}
+
+// handleMaintenance shows (GET), starts (POST ?reason=&mode=) or ends (DELETE) maintenance mode
+func (s *DynDNSServer) handleMaintenance(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, authScopeAdmin) {
+		return
+	}
+
+	var response maintenanceResponse
+	switch r.Method {
+	case http.MethodPost:
+		mode := r.URL.Query().Get("mode")
+		if mode == "" {
+			mode = maintenanceQueue
+		}
+		if mode != maintenanceQueue && mode != maintenanceNoChange {
+			httpErrorDetails(w, r, fmt.Sprintf("Invalid mode %q, expected %s or %s", mode, maintenanceQueue, maintenanceNoChange),
+				http.StatusBadRequest, map[string]string{"parameter": "mode"})
+			return
+		}
+		response = s.maintenance.Begin(r.URL.Query().Get("reason"), mode)
+		s.metrics.Set("dyndns_maintenance_active", nil, 1)
+		log.Printf("Maintenance mode started (%s): %s", mode, response.Reason)
+	case http.MethodDelete:
+		response = s.maintenance.End()
+		s.metrics.Set("dyndns_maintenance_active", nil, 0)
+		log.Printf("Maintenance mode ended, applied %d queued updates, %d failed", response.Applied, response.Failed)
+	default:
+		response = s.maintenance.Snapshot()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaintenanceHoldsUpdates(t *testing.T) {
+	tests := []struct {
+		name             string
+		mode             string
+		expectedResponse string
+		expectedState    string
+		expectedWrites   []string
+	}{
+		{
+			name:             "queue",
+			expectedResponse: "good IPv4: 203.0.113.5",
+			expectedState:    recordStateQueued,
+			expectedWrites:   []string{"PUT rec1"},
+		},
+		{
+			name:             "nochg",
+			mode:             "nochg",
+			expectedResponse: "nochg IPv4: 203.0.113.5",
+			expectedState:    recordStateOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"}}, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.status.Record("home.example.com", "A", "1.1.1.1", recordStateOK, nil)
+
+			req := httptest.NewRequest("POST", "/api/maintenance?reason=migration&mode="+tt.mode, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleMaintenance(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+			}
+
+			req = httptest.NewRequest("GET", "/update?hostname=home.example.com&myip=203.0.113.5", nil)
+			req.SetBasicAuth("admin", "password")
+			w = httptest.NewRecorder()
+			server.handleUpdate(w, req)
+			if w.Body.String() != tt.expectedResponse {
+				t.Errorf("Expected %q, got %q", tt.expectedResponse, w.Body.String())
+			}
+			if len(writes) != 0 {
+				t.Fatalf("Expected no writes during maintenance, got %v", writes)
+			}
+			status := server.currentStatus()
+			if status.MaintenanceSince == nil || status.Records[0].State != tt.expectedState {
+				t.Errorf("Expected maintenance and state %s in the status, got %+v", tt.expectedState, status)
+			}
+
+			req = httptest.NewRequest("DELETE", "/api/maintenance", nil)
+			req.SetBasicAuth("admin", "password")
+			w = httptest.NewRecorder()
+			server.handleMaintenance(w, req)
+			var response maintenanceResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Active || response.Reason != "migration" || response.Applied != len(tt.expectedWrites) {
+				t.Errorf("Unexpected response: %+v", response)
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+		})
+	}
+}
+
+func TestMaintenanceRejectsAPIWrites(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.maintenance.Begin("", maintenanceQueue)
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		handler http.HandlerFunc
+	}{
+		{name: "delete records", method: "DELETE", target: "/api/records?hostname=home.example.com", handler: server.handleRecords},
+		{name: "rollback", method: "POST", target: "/api/rollback?hostname=home.example.com", handler: server.handleRollback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			tt.handler(w, req)
+
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("Expected status 503, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+	if len(writes) != 0 {
+		t.Errorf("Expected no writes, got %v", writes)
+	}
+}
+
+func TestHandleMaintenanceInvalidMode(t *testing.T) {
+	server := NewDynDNSServer(nil, "admin", "password", "8080")
+	req := httptest.NewRequest("POST", "/api/maintenance?mode=pause", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	server.handleMaintenance(w, req)
+
+	if w.Code != http.StatusBadRequest || server.maintenance.Active() {
+		t.Errorf("Expected 400 and no maintenance, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"fritzbox-hetzner-dyndns/parse"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Manifest targets resolved to the detected public addresses
+const (
+	targetPublicIPv4 = "public-ipv4"
+	targetPublicIPv6 = "public-ipv6"
+)
+
+// Manifest record states reported by /api/manifest
+const (
+	manifestInSync    = "in-sync"
+	manifestCorrected = "corrected"
+	manifestDrift     = "drift"
+	manifestQueued    = "queued"
+	manifestError     = "error"
+)
+
+// manifestRecordTypes are the record types a manifest may declare
+var manifestRecordTypes = []string{"A", "AAAA", "CNAME", "TXT", "MX"}
+
+// ManifestRecord is a record the bridge keeps in the declared state
+type ManifestRecord struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// TTL is enforced if set, zero keeps the TTL of existing records
+	TTL int `yaml:"ttl"`
+	// Target is public-ipv4, public-ipv6 or the literal record value
+	Target string `yaml:"target"`
+}
+
+// manifestFile is the YAML document read from DYNDNS_MANIFEST
+type manifestFile struct {
+	Records []ManifestRecord `yaml:"records"`
+}
+
+// loadManifest reads and validates the records of a manifest
+func loadManifest(path string) ([]ManifestRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file manifestFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for i, record := range file.Records {
+		record.Name = strings.ToLower(strings.TrimSuffix(record.Name, "."))
+		record.Type = strings.ToUpper(record.Type)
+		key := record.Name + "/" + record.Type
+		switch {
+		case record.Name == "":
+			return nil, fmt.Errorf("record %d: name is required", i+1)
+		case !slices.Contains(manifestRecordTypes, record.Type):
+			return nil, fmt.Errorf("%s: unsupported type %q (expected %s)", record.Name, record.Type, strings.Join(manifestRecordTypes, ", "))
+		case record.Target == "":
+			return nil, fmt.Errorf("%s %s: target is required", record.Name, record.Type)
+		case record.TTL < 0:
+			return nil, fmt.Errorf("%s %s: ttl must not be negative", record.Name, record.Type)
+		case seen[key]:
+			return nil, fmt.Errorf("%s %s is declared twice", record.Name, record.Type)
+		}
+		if err := checkManifestTarget(record); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", record.Name, record.Type, err)
+		}
+		seen[key] = true
+		file.Records[i] = record
+	}
+	return file.Records, nil
+}
+
+// checkManifestTarget verifies that the target of record suits its type
+func checkManifestTarget(record ManifestRecord) error {
+	switch {
+	case record.Target == targetPublicIPv4 && record.Type != "A",
+		record.Target == targetPublicIPv6 && record.Type != "AAAA":
+		return fmt.Errorf("target %s does not fit the record type", record.Target)
+	case record.Target == targetPublicIPv4, record.Target == targetPublicIPv6:
+		return nil
+	case record.Type == "A" && !parse.IsIPv4(record.Target):
+		return fmt.Errorf("target must be %s or an IPv4 address, got %q", targetPublicIPv4, record.Target)
+	case record.Type == "AAAA" && !parse.IsIPv6(record.Target):
+		return fmt.Errorf("target must be %s or an IPv6 address, got %q", targetPublicIPv6, record.Target)
+	}
+	return nil
+}
+
+// manifestRecordState is the outcome of the last reconciliation of a manifest record
+type manifestRecordState struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Desired string `json:"desired"`
+	// Actual is the value before reconciliation, empty if the record did not exist
+	Actual string `json:"actual,omitempty"`
+	State  string `json:"state"`
+	Error  string `json:"error,omitempty"`
+}
+
+// manifestResponse is the JSON schema of /api/manifest
+type manifestResponse struct {
+	Path      string                `json:"path"`
+	DryRun    bool                  `json:"dry_run"`
+	CheckedAt *time.Time            `json:"checked_at,omitempty"`
+	Records   []manifestRecordState `json:"records"`
+}
+
+// ManifestReconciler keeps the records declared in a manifest file in their desired
+// state. The file is read on every pass, so edits, e.g. pulled from a git
+// repository, take effect without a restart.
+type ManifestReconciler struct {
+	server   *DynDNSServer
+	detector *IPDetector
+	path     string
+	// dryRun only reports drift instead of correcting it
+	dryRun bool
+
+	mu        sync.Mutex
+	records   []ManifestRecord
+	checkedAt *time.Time
+	states    []manifestRecordState
+}
+
+// NewManifestReconciler creates a reconciler for the manifest at path, records are its validated content
+func NewManifestReconciler(server *DynDNSServer, detector *IPDetector, path string, records []ManifestRecord, dryRun bool) *ManifestReconciler {
+	server.metrics.Describe("dyndns_manifest_drift_total", "counter", "Number of manifest records found drifted by type and whether they were corrected.")
+	server.metrics.Describe("dyndns_manifest_errors_total", "counter", "Number of failed manifest reconciliations by stage.")
+	return &ManifestReconciler{
+		server:   server,
+		detector: detector,
+		path:     path,
+		dryRun:   dryRun,
+		records:  records,
+	}
+}
+
+// Run reconciles once immediately and then every interval until stop is closed, a zero interval only reconciles once
+func (m *ManifestReconciler) Run(interval time.Duration, stop <-chan struct{}) {
+	m.Reconcile()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			m.Reconcile()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Reconcile rereads the manifest and brings every declared record into its desired state.
+// An invalid manifest keeps the records of the last valid one. It returns the number of drifted records.
+func (m *ManifestReconciler) Reconcile() int {
+	if m.server.maintenance.Active() {
+		log.Printf("Manifest: skipping reconciliation during maintenance")
+		return 0
+	}
+	records, err := loadManifest(m.path)
+	m.mu.Lock()
+	if err != nil {
+		log.Printf("Manifest: keeping the last valid records, %s is invalid: %v", m.path, err)
+		m.server.metrics.Inc("dyndns_manifest_errors_total", Labels{"stage": "load"})
+	} else {
+		m.records = records
+	}
+	records = m.records
+	m.mu.Unlock()
+
+	// Addresses are only detected if a record needs them
+	var ipv4, ipv6 string
+	if slices.ContainsFunc(records, func(r ManifestRecord) bool { return r.Target == targetPublicIPv4 }) {
+		ipv4 = m.detect("IPv4", m.detector.DetectIPv4)
+	}
+	if slices.ContainsFunc(records, func(r ManifestRecord) bool { return r.Target == targetPublicIPv6 }) {
+		ipv6 = m.detect("IPv6", m.detector.DetectIPv6)
+	}
+
+	drifted := 0
+	states := make([]manifestRecordState, 0, len(records))
+	for _, record := range records {
+		value := record.Target
+		switch value {
+		case targetPublicIPv4:
+			value = ipv4
+		case targetPublicIPv6:
+			value = ipv6
+		}
+		state := manifestRecordState{Name: record.Name, Type: record.Type, Desired: value}
+		if value == "" {
+			state.State, state.Error = manifestError, "public address could not be detected"
+		} else {
+			m.reconcileRecord(record, &state)
+		}
+		if state.State == manifestCorrected || state.State == manifestDrift || state.State == manifestQueued {
+			drifted++
+		}
+		states = append(states, state)
+	}
+
+	now := m.server.now().UTC()
+	m.mu.Lock()
+	m.checkedAt, m.states = &now, states
+	m.mu.Unlock()
+	return drifted
+}
+
+// detect returns the public address found by detect, empty if detection failed
+func (m *ManifestReconciler) detect(family string, detect func() (string, error)) string {
+	ip, err := detect()
+	if err != nil {
+		log.Printf("Manifest: %s detection failed: %v", family, err)
+		m.server.metrics.Inc("dyndns_manifest_errors_total", Labels{"stage": "detect"})
+		return ""
+	}
+	return ip
+}
+
+// reconcileRecord compares a record with its desired value and TTL and corrects it unless in dry-run mode
+func (m *ManifestReconciler) reconcileRecord(record ManifestRecord, state *manifestRecordState) {
+	s := m.server
+	lookup, err := s.lookupRecord(record.Name, record.Type)
+	if err != nil {
+		m.fail(record, state, err)
+		return
+	}
+
+	existing := lookup.Existing
+	if existing != nil {
+		state.Actual = existing.Value
+		if existing.Value == state.Desired && (record.TTL == 0 || existing.TTL != nil && *existing.TTL == record.TTL) {
+			state.State = manifestInSync
+			return
+		}
+	}
+
+	current := "<none>"
+	if existing != nil {
+		current = existing.Value
+	}
+	if m.dryRun {
+		log.Printf("Manifest: %s %s drifted (%s, expected %s), dry-run leaves it unchanged", record.Name, record.Type, current, state.Desired)
+		s.metrics.Inc("dyndns_manifest_drift_total", Labels{"type": record.Type, "corrected": "false"})
+		state.State = manifestDrift
+		s.events.Publish(Event{
+			Kind:     eventAlert,
+			Severity: severityWarning,
+			Hostname: record.Name,
+			Type:     record.Type,
+			OldValue: state.Actual,
+			NewValue: state.Desired,
+			Message:  fmt.Sprintf("%s %s drifted from the manifest: %s, expected %s", record.Name, record.Type, current, state.Desired),
+		})
+		return
+	}
+
+	// Members of round-robin hostnames are written by their sources, a single value would drop the others
+	if s.isRoundRobin(record.Name) {
+		m.fail(record, state, fmt.Errorf("%s is a round-robin hostname, its members cannot be declared in the manifest", record.Name))
+		return
+	}
+	log.Printf("Manifest: %s %s drifted (%s, expected %s), correcting", record.Name, record.Type, current, state.Desired)
+	// Written like every other update, so ownership, strict mode, conflicts, maintenance and the history apply
+	decision, err := s.submit(parkedWrite{Hostname: record.Name, Type: record.Type, Value: state.Desired, TTL: record.TTL, System: historySystemManifest})
+	if err != nil {
+		m.fail(record, state, err)
+		return
+	}
+	switch decision {
+	case rateQueued:
+		state.State = manifestQueued
+	case rateNoChange:
+		state.State = manifestDrift
+	default:
+		s.metrics.Inc("dyndns_manifest_drift_total", Labels{"type": record.Type, "corrected": "true"})
+		state.State = manifestCorrected
+	}
+}
+
+// fail records a failed reconciliation of record
+func (m *ManifestReconciler) fail(record ManifestRecord, state *manifestRecordState, err error) {
+	log.Printf("Manifest: reconciliation of %s %s failed: %v", record.Name, record.Type, err)
+	m.server.metrics.Inc("dyndns_manifest_errors_total", Labels{"stage": "update"})
+	state.State, state.Error = manifestError, err.Error()
+}
+
+// Report returns the outcome of the last reconciliation
+func (m *ManifestReconciler) Report() manifestResponse {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	response := manifestResponse{Path: m.path, DryRun: m.dryRun, CheckedAt: m.checkedAt, Records: m.states}
+	if response.Records == nil {
+		response.Records = []manifestRecordState{}
+	}
+	return response
+}
+
+// handleManifest serves the outcome of the last manifest reconciliation as JSON
+func (s *DynDNSServer) handleManifest(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, authScopeAdmin) {
+		return
+	}
+	if s.manifest == nil {
+		httpError(w, r, "No manifest configured", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.manifest.Report())
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeManifest writes content to a manifest file in a temporary directory
+func writeManifest(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "records.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadManifest(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expected      []ManifestRecord
+		errorContains string
+	}{
+		{
+			name:    "valid manifest",
+			content: "records:\n  - {name: Home.example.com., type: a, ttl: 300, target: public-ipv4}\n  - {name: nas.example.com, type: CNAME, target: home.example.com.}\n",
+			expected: []ManifestRecord{
+				{Name: "home.example.com", Type: "A", TTL: 300, Target: "public-ipv4"},
+				{Name: "nas.example.com", Type: "CNAME", Target: "home.example.com."},
+			},
+		},
+		{name: "unsupported type", content: "records:\n  - {name: home.example.com, type: NS, target: ns1.example.com.}\n", errorContains: "unsupported type"},
+		{name: "target of the wrong family", content: "records:\n  - {name: home.example.com, type: AAAA, target: public-ipv4}\n", errorContains: "does not fit"},
+		{name: "invalid address", content: "records:\n  - {name: home.example.com, type: A, target: home}\n", errorContains: "IPv4 address"},
+		{name: "missing target", content: "records:\n  - {name: home.example.com, type: A}\n", errorContains: "target is required"},
+		{name: "duplicate record", content: "records:\n  - {name: home.example.com, type: A, target: 203.0.113.7}\n  - {name: home.example.com, type: A, target: 203.0.113.8}\n", errorContains: "declared twice"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := loadManifest(writeManifest(t, tt.content))
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(records, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, records)
+			}
+		})
+	}
+}
+
+func TestManifestReconcile(t *testing.T) {
+	ttl := 300
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "home", Value: "198.51.100.1", TTL: &ttl},
+		{ID: "rec2", Type: "CNAME", Name: "nas", Value: "home.example.com.", TTL: &ttl},
+		{ID: "rec3", Type: "A", Name: "vpn", Value: "203.0.113.7", TTL: &ttl},
+	}
+	manifest := `records:
+  - {name: home.example.com, type: A, target: public-ipv4}
+  - {name: nas.example.com, type: CNAME, target: home.example.com.}
+  - {name: vpn.example.com, type: A, ttl: 60, target: 203.0.113.7}
+  - {name: www.example.com, type: CNAME, target: home.example.com.}
+`
+
+	tests := []struct {
+		name            string
+		dryRun          bool
+		expectedDrifted int
+		expectedWrites  []string
+		expectedStates  []string
+		expectAlerts    bool
+	}{
+		{
+			name:            "drift is corrected",
+			expectedDrifted: 3,
+			expectedWrites:  []string{"PUT rec1", "PUT rec3", "POST CNAME www"},
+			expectedStates:  []string{manifestCorrected, manifestInSync, manifestCorrected, manifestCorrected},
+		},
+		{
+			name:            "dry-run only reports drift",
+			dryRun:          true,
+			expectedDrifted: 3,
+			expectedStates:  []string{manifestDrift, manifestInSync, manifestDrift, manifestDrift},
+			expectAlerts:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, records, &writes)
+			defer mockAPI.Close()
+			echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("203.0.113.1"))
+			}))
+			defer echo.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			var alerts []Event
+			server.events.Subscribe(func(event Event) { alerts = append(alerts, event) }, eventAlert)
+
+			path := writeManifest(t, manifest)
+			reconciler := NewManifestReconciler(server, NewIPDetector(echo.URL, ""), path, nil, tt.dryRun)
+			if drifted := reconciler.Reconcile(); drifted != tt.expectedDrifted {
+				t.Errorf("Expected %d drifted records, got %d", tt.expectedDrifted, drifted)
+			}
+			if !reflect.DeepEqual(writes, tt.expectedWrites) {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+			var states []string
+			for _, state := range reconciler.Report().Records {
+				states = append(states, state.State)
+			}
+			if !reflect.DeepEqual(states, tt.expectedStates) {
+				t.Errorf("Expected states %v, got %v", tt.expectedStates, states)
+			}
+			if (len(alerts) > 0) != tt.expectAlerts {
+				t.Errorf("Expected alerts %v, got %v", tt.expectAlerts, alerts)
+			}
+		})
+	}
+}
+
+func TestManifestWritesLikeUpdates(t *testing.T) {
+	ttl, changedTTL := defaultRecordTTL, 300
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "home", Value: "198.51.100.1", TTL: &ttl},
+		{ID: "marker1", Type: "TXT", Name: "_dyndns-a.home", Value: ownershipValue("bridge1")},
+		{ID: "rec2", Type: "A", Name: "vpn", Value: "198.51.100.2", TTL: &changedTTL},
+		{ID: "marker2", Type: "TXT", Name: "_dyndns-a.vpn", Value: ownershipValue("bridge1")},
+		{ID: "rec3", Type: "A", Name: "nas", Value: "198.51.100.3", TTL: &ttl},
+	}
+	manifest := `records:
+  - {name: home.example.com, type: A, target: 203.0.113.1}
+  - {name: vpn.example.com, type: A, target: 203.0.113.2}
+  - {name: nas.example.com, type: A, target: 203.0.113.3}
+`
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, records, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.ownerID = "bridge1"
+	server.strict = true
+
+	reconciler := NewManifestReconciler(server, NewIPDetector("", ""), writeManifest(t, manifest), nil, false)
+	reconciler.Reconcile()
+
+	// Strict mode protects the record with a changed TTL, ownership the unowned one
+	if expected := []string{"PUT rec1"}; !reflect.DeepEqual(writes, expected) {
+		t.Errorf("Expected writes %v, got %v", expected, writes)
+	}
+	var states []string
+	for _, state := range reconciler.Report().Records {
+		states = append(states, state.State)
+	}
+	if expected := []string{manifestCorrected, manifestError, manifestError}; !reflect.DeepEqual(states, expected) {
+		t.Errorf("Expected states %v, got %v", expected, states)
+	}
+
+	entries, err := server.recordHistory("home.example.com", "A")
+	if err != nil || len(entries) != 1 || entries[0].Client == nil || entries[0].Client.System != historySystemManifest {
+		t.Errorf("Expected the correction in the history, attributed to the manifest, got %+v, %v", entries, err)
+	}
+	if previous, _ := server.previous("home.example.com", "A"); previous == nil || previous.Value != "198.51.100.1" {
+		t.Errorf("Expected the replaced value to be remembered for rollback, got %+v", previous)
+	}
+}
+
+func TestManifestKeepsLastValidRecords(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "vpn", Value: "203.0.113.7"}}, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	path := writeManifest(t, "records:\n  - {name: vpn.example.com, type: A, target: 203.0.113.7}\n")
+	records, _ := loadManifest(path)
+	reconciler := NewManifestReconciler(server, NewIPDetector("", ""), path, records, false)
+	os.WriteFile(path, []byte("records: [broken"), 0o644)
+
+	reconciler.Reconcile()
+	report := reconciler.Report()
+	if len(report.Records) != 1 || report.Records[0].State != manifestInSync {
+		t.Errorf("Expected the last valid record to be reconciled, got %+v", report.Records)
+	}
+	if value := server.metrics.Value("dyndns_manifest_errors_total", Labels{"stage": "load"}); value != 1 {
+		t.Errorf("Expected load error counter 1, got %g", value)
+	}
+}
+
+func TestHandleManifest(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	req := httptest.NewRequest("GET", "/api/manifest", nil)
+	req.SetBasicAuth("admin", "password")
+
+	w := httptest.NewRecorder()
+	server.handleManifest(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 without manifest, got %d", w.Code)
+	}
+
+	server.manifest = NewManifestReconciler(server, NewIPDetector("", ""), "records.yaml", nil, true)
+	w = httptest.NewRecorder()
+	server.handleManifest(w, req)
+	var response manifestResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Invalid response %q: %v", w.Body.String(), err)
+	}
+	if !response.DryRun || response.Records == nil || response.CheckedAt != nil {
+		t.Errorf("Expected an empty dry-run report, got %+v", response)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"runtime/debug"
+	"strings"
+)
+
+// maxFormBodySize limits the form body of POST update requests
+const maxFormBodySize = 4 << 10
+
+// allowMethods wraps next so only methods are passed on. OPTIONS is answered
+// with the allowed methods, other methods with 405 and an Allow header.
+func allowMethods(next http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allow := strings.Join(methods, ", ") + ", " + http.MethodOptions
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		for _, method := range methods {
+			if r.Method == method {
+				next(w, r)
+				return
+			}
+		}
+		w.Header().Set("Allow", allow)
+		httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// recoverPanics answers an update whose handler panicked with 911 instead of
+// dropping the connection, so the client retries later, and logs the cause
+func recoverPanics(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err)
+			}
+			log.Printf("Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+			fmt.Fprint(w, "911")
+		}()
+		next(w, r)
+	}
+}
+
+// updateMethods returns the methods accepted by the update endpoints
+func (s *DynDNSServer) updateMethods() []string {
+	if s.allowPost {
+		return []string{http.MethodGet, http.MethodHead, http.MethodPost}
+	}
+	return []string{http.MethodGet, http.MethodHead}
+}
+
+// parseUpdateForm reads the form body of a POST update request, answering
+// unsupported or oversized bodies with an error status
+func parseUpdateForm(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method != http.MethodPost {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-www-form-urlencoded" {
+		http.Error(w, "Unsupported content type, expected application/x-www-form-urlencoded", http.StatusUnsupportedMediaType)
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBodySize)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form body", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
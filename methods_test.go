@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAllowMethods(t *testing.T) {
+	handler := allowMethods(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}, "GET", "HEAD")
+
+	tests := []struct {
+		method         string
+		expectedStatus int
+		expectAllow    bool
+	}{
+		{"GET", http.StatusTeapot, false},
+		{"HEAD", http.StatusTeapot, false},
+		{"OPTIONS", http.StatusNoContent, true},
+		{"POST", http.StatusMethodNotAllowed, true},
+		{"DELETE", http.StatusMethodNotAllowed, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest(tt.method, "/health", nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+			allow := w.Header().Get("Allow")
+			if tt.expectAllow && allow != "GET, HEAD, OPTIONS" {
+				t.Errorf("Expected Allow header, got %q", allow)
+			}
+			if !tt.expectAllow && allow != "" {
+				t.Errorf("Expected no Allow header, got %q", allow)
+			}
+		})
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	handler := recoverPanics(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("hostname") == "" {
+			panic("malformed input")
+		}
+		w.Write([]byte("good"))
+	})
+
+	for query, expected := range map[string]string{"hostname=home.example.com": "good", "": "911"} {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/update?"+query, nil))
+		if w.Body.String() != expected {
+			t.Errorf("Query %q: expected %q, got %q", query, expected, w.Body.String())
+		}
+	}
+}
+
+func TestHandleUpdateHead(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	req := httptest.NewRequest("HEAD", "/update?hostname=home.example.com&myip=1.2.3.4", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	server.handleUpdate(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", w.Code)
+	}
+	if len(writes) != 0 {
+		t.Errorf("Expected HEAD not to update records, got %v", writes)
+	}
+
+	req = httptest.NewRequest("HEAD", "/update", nil)
+	w = httptest.NewRecorder()
+	server.handleUpdate(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected HEAD without credentials to be rejected, got %d", w.Code)
+	}
+}
+
+func TestHandleUpdatePostForm(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedWrites []string
+	}{
+		{
+			name:           "form body",
+			contentType:    "application/x-www-form-urlencoded",
+			body:           "hostname=home.example.com&myip=1.2.3.4",
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"POST A home"},
+		},
+		{
+			name:           "json body",
+			contentType:    "application/json",
+			body:           `{"hostname":"home.example.com"}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "oversized body",
+			contentType:    "application/x-www-form-urlencoded",
+			body:           "hostname=" + strings.Repeat("a", maxFormBodySize),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, nil, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.allowPost = true
+
+			req := httptest.NewRequest("POST", "/update", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			allowMethods(server.handleUpdate, server.updateMethods()...)(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+		})
+	}
+}
+
+func TestUpdateMethods(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+
+	req := httptest.NewRequest("POST", "/update", strings.NewReader("hostname=home.example.com"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	allowMethods(server.handleUpdate, server.updateMethods()...)(w, req)
+
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected POST to be rejected by default, got %d", w.Code)
+	}
+	if w.Header().Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("Unexpected Allow header %q", w.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Labels are the label names and values identifying a metric series
+type Labels map[string]string
+
+// Metrics is a minimal registry of counters and gauges exposed in the Prometheus text format
+type Metrics struct {
+	mu       sync.Mutex
+	families map[string]*metricFamily
+	// parent is the registry a labeled view created by WithLabels records into
+	parent *Metrics
+	labels Labels
+}
+
+// metricFamily holds all series of a single metric
+type metricFamily struct {
+	kind   string
+	help   string
+	series map[string]float64
+}
+
+// NewMetrics creates an empty metrics registry
+func NewMetrics() *Metrics {
+	return &Metrics{families: make(map[string]*metricFamily)}
+}
+
+// WithLabels returns a view of the registry adding labels to every series it records
+func (m *Metrics) WithLabels(labels Labels) *Metrics {
+	return &Metrics{parent: m, labels: labels}
+}
+
+// withParentLabels merges labels with the labels of the view
+func (m *Metrics) withParentLabels(labels Labels) Labels {
+	merged := make(Labels, len(labels)+len(m.labels))
+	for name, value := range labels {
+		merged[name] = value
+	}
+	for name, value := range m.labels {
+		merged[name] = value
+	}
+	return merged
+}
+
+// Add increments the counter name for labels by value
+func (m *Metrics) Add(name string, labels Labels, value float64) {
+	if m.parent != nil {
+		m.parent.Add(name, m.withParentLabels(labels), value)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.family(name, "counter").series[labels.String()] += value
+}
+
+// Inc increments the counter name for labels by one
+func (m *Metrics) Inc(name string, labels Labels) {
+	m.Add(name, labels, 1)
+}
+
+// Set sets the gauge name for labels to value
+func (m *Metrics) Set(name string, labels Labels, value float64) {
+	if m.parent != nil {
+		m.parent.Set(name, m.withParentLabels(labels), value)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.family(name, "gauge").series[labels.String()] = value
+}
+
+// Value returns the current value of a series, mainly useful in tests
+func (m *Metrics) Value(name string, labels Labels) float64 {
+	if m.parent != nil {
+		return m.parent.Value(name, m.withParentLabels(labels))
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if family, ok := m.families[name]; ok {
+		return family.series[labels.String()]
+	}
+	return 0
+}
+
+// Describe sets the help text of a metric
+func (m *Metrics) Describe(name, kind, help string) {
+	if m.parent != nil {
+		m.parent.Describe(name, kind, help)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.family(name, kind).help = help
+}
+
+// family returns the family for name, creating it if needed. Callers must hold mu.
+func (m *Metrics) family(name, kind string) *metricFamily {
+	family, ok := m.families[name]
+	if !ok {
+		family = &metricFamily{kind: kind, series: make(map[string]float64)}
+		m.families[name] = family
+	}
+	return family
+}
+
+// ServeHTTP writes all metrics in the Prometheus text exposition format
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if m.parent != nil {
+		m.parent.ServeHTTP(w, r)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	names := make([]string, 0, len(m.families))
+	for name := range m.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		family := m.families[name]
+		if family.help != "" {
+			fmt.Fprintf(w, "# HELP %s %s\n", name, family.help)
+		}
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, family.kind)
+
+		keys := make([]string, 0, len(family.series))
+		for key := range family.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fmt.Fprintf(w, "%s%s %g\n", name, key, family.series[key])
+		}
+	}
+}
+
+// labelValueEscaper escapes label values for the text exposition format, it
+// is built once as building a Replacer dominated the cost of Inc
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// String formats the labels as a sorted Prometheus label set, e.g. {type="A"}
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(key)
+		b.WriteString(`="`)
+		labelValueEscaper.WriteString(&b, l[key])
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsCountersAndGauges(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Inc("dyndns_test_total", Labels{"type": "A"})
+	metrics.Add("dyndns_test_total", Labels{"type": "A"}, 2)
+	metrics.Set("dyndns_test_gauge", nil, 5)
+	metrics.Set("dyndns_test_gauge", nil, 7)
+
+	if value := metrics.Value("dyndns_test_total", Labels{"type": "A"}); value != 3 {
+		t.Errorf("Expected counter 3, got %g", value)
+	}
+	if value := metrics.Value("dyndns_test_gauge", nil); value != 7 {
+		t.Errorf("Expected gauge 7, got %g", value)
+	}
+	if value := metrics.Value("dyndns_unknown", nil); value != 0 {
+		t.Errorf("Expected unknown metric 0, got %g", value)
+	}
+}
+
+func TestMetricsServeHTTP(t *testing.T) {
+	metrics := NewMetrics()
+	metrics.Describe("dyndns_test_total", "counter", "Test counter.")
+	metrics.Inc("dyndns_test_total", Labels{"type": "A", "result": "ok"})
+	metrics.Set("dyndns_test_gauge", nil, 1.5)
+
+	w := httptest.NewRecorder()
+	metrics.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	expected := []string{
+		"# TYPE dyndns_test_gauge gauge\ndyndns_test_gauge 1.5\n",
+		"# HELP dyndns_test_total Test counter.\n",
+		"# TYPE dyndns_test_total counter\n",
+		`dyndns_test_total{result="ok",type="A"} 1`,
+	}
+	for _, want := range expected {
+		if !strings.Contains(w.Body.String(), want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, w.Body.String())
+		}
+	}
+}
+
+func TestLabelsString(t *testing.T) {
+	tests := []struct {
+		labels   Labels
+		expected string
+	}{
+		{nil, ""},
+		{Labels{"type": "A"}, `{type="A"}`},
+		{Labels{"b": "2", "a": "1"}, `{a="1",b="2"}`},
+		{Labels{"v": `say "hi"`}, `{v="say \"hi\""}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := tt.labels.String(); result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMetricsWithLabels(t *testing.T) {
+	metrics := NewMetrics()
+	tenant := metrics.WithLabels(Labels{"tenant": "alice"})
+	tenant.Inc("dyndns_test_total", Labels{"type": "A"})
+	metrics.Inc("dyndns_test_total", Labels{"type": "A"})
+
+	if value := metrics.Value("dyndns_test_total", Labels{"type": "A", "tenant": "alice"}); value != 1 {
+		t.Errorf("Expected labeled counter 1, got %g", value)
+	}
+	if value := tenant.Value("dyndns_test_total", Labels{"type": "A"}); value != 1 {
+		t.Errorf("Expected the view to read its own series, got %g", value)
+	}
+	if value := metrics.Value("dyndns_test_total", Labels{"type": "A"}); value != 1 {
+		t.Errorf("Expected unlabeled counter 1, got %g", value)
+	}
+}
+
+func BenchmarkMetricsInc(b *testing.B) {
+	metrics := NewMetrics()
+	metrics.Describe("dyndns_bench_total", "counter", "Benchmark counter")
+	labels := Labels{"type": "A", "result": "nochg"}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		metrics.Inc("dyndns_bench_total", labels)
+	}
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// migrateOptions are the arguments of the migrate subcommand
+type migrateOptions struct {
+	Zone string
+	// FromToken reads the zone with another token than the one routed to it
+	FromToken string
+	ToToken   string
+	// ToURL is the API of the target, the API of the source if empty
+	ToURL  string
+	DryRun bool
+}
+
+// parseMigrateArgs parses "<zone> --to <token> [--from <token>] [--to-url <url>] [--dry-run]"
+func parseMigrateArgs(args []string) (migrateOptions, error) {
+	var opts migrateOptions
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		var target *string
+		switch args[i] {
+		case "--dry-run":
+			opts.DryRun = true
+			continue
+		case "--to":
+			target = &opts.ToToken
+		case "--from":
+			target = &opts.FromToken
+		case "--to-url":
+			target = &opts.ToURL
+		default:
+			positional = append(positional, args[i])
+			continue
+		}
+		if i+1 == len(args) {
+			return opts, fmt.Errorf("%s requires a value\n%s", args[i], cliUsage)
+		}
+		i++
+		*target = args[i]
+	}
+	if len(positional) != 1 || opts.ToToken == "" {
+		return opts, fmt.Errorf("migrate requires a zone and the target token (--to)\n%s", cliUsage)
+	}
+	opts.Zone = parse.NormalizeHostname(positional[0])
+	return opts, nil
+}
+
+// managedRecords returns the records of a zone maintained by the bridge: the A
+// and AAAA records it owns including their markers, or all A and AAAA records
+// if ownership markers are not used
+func managedRecords(records []DNSRecord, ownerID string) []DNSRecord {
+	var managed []DNSRecord
+	for _, record := range records {
+		if record.Type != "A" && record.Type != "AAAA" {
+			continue
+		}
+		if ownerID == "" {
+			managed = append(managed, record)
+			continue
+		}
+		if marker := findOwnershipRecord(records, record.Name, record.Type, ownerID); marker != nil {
+			managed = append(managed, record, *marker)
+		}
+	}
+	return managed
+}
+
+// findZone returns the zone named name among those accessible with provider
+func findZone(provider Provider, name string) (*Zone, error) {
+	zones, err := provider.GetZones()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zones: %w", err)
+	}
+	for i := range zones {
+		if strings.EqualFold(zones[i].Name, name) {
+			return &zones[i], nil
+		}
+	}
+	return nil, fmt.Errorf("zone %s: %w", name, errZoneNotFound)
+}
+
+// findSameRecord returns the record of records with the name and type of record
+func findSameRecord(records []DNSRecord, record DNSRecord) *DNSRecord {
+	for i := range records {
+		if records[i].Name == record.Name && records[i].Type == record.Type {
+			return &records[i]
+		}
+	}
+	return nil
+}
+
+// migrateZone copies the managed records of a zone from source to target and
+// verifies the copy, returning the copied records. The zone must already exist
+// at the target, other records there are left alone.
+func migrateZone(source, target Provider, zone, ownerID string, dryRun bool, out io.Writer) ([]DNSRecord, error) {
+	sourceZone, err := findZone(source, zone)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	targetZone, err := findZone(target, zone)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w, create it there first", err)
+	}
+	records, err := source.GetAllRecords(sourceZone.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records of the source zone: %w", err)
+	}
+	existing, err := target.GetAllRecords(targetZone.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records of the target zone: %w", err)
+	}
+
+	managed := managedRecords(records, ownerID)
+	for _, record := range managed {
+		if dryRun {
+			fmt.Fprintf(out, "Would copy %s %s %s\n", record.Type, recordFQDN(record.Name, zone), record.Value)
+			continue
+		}
+		ttl := 0
+		if record.TTL != nil {
+			ttl = *record.TTL
+		}
+		if _, _, err := dyndns.UpsertRecord(target, targetZone.ID, record.Name, record.Type, record.Value, ttl, findSameRecord(existing, record)); err != nil {
+			return nil, fmt.Errorf("failed to copy %s %s: %w", record.Type, record.Name, err)
+		}
+		fmt.Fprintf(out, "Copied %s %s %s\n", record.Type, recordFQDN(record.Name, zone), record.Value)
+	}
+	if dryRun {
+		return managed, nil
+	}
+
+	// Only a complete copy may take over the zone
+	copied, err := target.GetAllRecords(targetZone.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify the copy: %w", err)
+	}
+	for _, record := range managed {
+		if found := findSameRecord(copied, record); found == nil || found.Value != record.Value {
+			return nil, fmt.Errorf("verification failed: %s %s is missing at the target", record.Type, record.Name)
+		}
+	}
+	return managed, nil
+}
+
+// readZoneTokensFile reads the pattern=token lines of DYNDNS_ZONE_TOKENS_FILE,
+// a missing file holds no routes yet
+func readZoneTokensFile(path string) (map[string]APIToken, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]APIToken{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			entries = append(entries, line)
+		}
+	}
+	return parseZoneTokens(strings.Join(entries, ","))
+}
+
+// routeZone points the route of zone in the zone tokens file at token. The file
+// is replaced by renaming a complete copy, so the bridge never reads half of it.
+func routeZone(path, zone, token string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var lines []string
+	replaced := false
+	for _, line := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		pattern, _, ok := strings.Cut(line, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(pattern), zone) {
+			if replaced {
+				continue
+			}
+			line, replaced = zone+"="+token, true
+		}
+		if line != "" || len(lines) > 0 {
+			lines = append(lines, line)
+		}
+	}
+	if !replaced {
+		lines = append(lines, zone+"="+token)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// runMigrate handles "migrate <zone> --to <token>", copying the managed records
+// to the zone accessible with the target token and routing the zone to it
+func runMigrate(server *DynDNSServer, args []string, out io.Writer) error {
+	opts, err := parseMigrateArgs(args)
+	if err != nil {
+		return err
+	}
+
+	baseURL := BaseURL
+	source, err := server.clientFor(opts.Zone)
+	if err == nil && source != nil {
+		baseURL = source.BaseURL
+	}
+	switch {
+	case opts.FromToken != "":
+		source = NewClient(opts.FromToken)
+		source.BaseURL = baseURL
+	case err != nil:
+		return err
+	case source == nil:
+		return fmt.Errorf("no API token configured for %s, pass the source token with --from", opts.Zone)
+	}
+	target := NewClient(opts.ToToken)
+	target.BaseURL = baseURL
+	if opts.ToURL != "" {
+		target.BaseURL = opts.ToURL
+	}
+
+	copied, err := migrateZone(source, target, opts.Zone, server.ownerID, opts.DryRun, out)
+	if err != nil {
+		return err
+	}
+	if opts.DryRun {
+		fmt.Fprintf(out, "Dry run: %d records of %s would be copied\n", len(copied), opts.Zone)
+		return nil
+	}
+	fmt.Fprintf(out, "Copied and verified %d records of %s\n", len(copied), opts.Zone)
+
+	if server.zoneTokensFile == "" {
+		fmt.Fprintf(out, "Add %s=<token> to DYNDNS_ZONE_TOKENS and restart the bridge to serve the zone from the target\n", opts.Zone)
+		return nil
+	}
+	if err := routeZone(server.zoneTokensFile, opts.Zone, opts.ToToken); err != nil {
+		return fmt.Errorf("records were copied, but the route could not be updated: %w", err)
+	}
+	fmt.Fprintf(out, "Routed %s to the target in %s, restart the bridge to apply it\n", opts.Zone, server.zoneTokensFile)
+	return nil
+}
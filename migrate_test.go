@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dropWrites answers writes to the records of api without performing them,
+// which simulates an API losing writes
+func dropWrites(api http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" || !strings.HasPrefix(r.URL.Path, "/records") {
+			api.ServeHTTP(w, r)
+			return
+		}
+		var req CreateRecordRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(RecordResponse{Record: DNSRecord{ID: "dropped", Type: req.Type, Name: req.Name, Value: req.Value, TTL: req.TTL}})
+	})
+}
+
+func TestManagedRecords(t *testing.T) {
+	records := []DNSRecord{
+		{Type: "A", Name: "home", Value: "203.0.113.1"},
+		{Type: "TXT", Name: "_dyndns-a.home", Value: ownershipValue("bridge1")},
+		{Type: "A", Name: "manual", Value: "203.0.113.2"},
+		{Type: "MX", Name: "@", Value: "10 mail.example.com."},
+	}
+
+	tests := []struct {
+		name     string
+		ownerID  string
+		expected []string
+	}{
+		{name: "owned records and markers", ownerID: "bridge1", expected: []string{"A home", "TXT _dyndns-a.home"}},
+		{name: "all address records without ownership", expected: []string{"A home", "A manual"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, record := range managedRecords(records, tt.ownerID) {
+				got = append(got, record.Type+" "+record.Name)
+			}
+			if strings.Join(got, ";") != strings.Join(tt.expected, ";") {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunMigrate(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		dropWrites    bool
+		existing      []DNSRecord
+		expectRoute   bool
+		expectedCopy  []string
+		errorContains string
+	}{
+		{
+			name:         "copies records and routes the zone",
+			args:         []string{"example.com", "--to", "new-token"},
+			expectRoute:  true,
+			expectedCopy: []string{"A home 203.0.113.1", "AAAA home 2001:db8::1"},
+		},
+		{
+			name:         "updates records existing at the target",
+			args:         []string{"example.com", "--to", "new-token"},
+			existing:     []DNSRecord{{ID: "old", Type: "A", Name: "home", Value: "198.51.100.1"}},
+			expectRoute:  true,
+			expectedCopy: []string{"A home 203.0.113.1", "AAAA home 2001:db8::1"},
+		},
+		{
+			name: "dry run",
+			args: []string{"example.com", "--to", "new-token", "--dry-run"},
+		},
+		{
+			name:          "failed verification keeps the route",
+			args:          []string{"example.com", "--to", "new-token"},
+			dropWrites:    true,
+			errorContains: "verification failed",
+		},
+		{
+			name:          "missing target token",
+			args:          []string{"example.com"},
+			errorContains: "requires a zone and the target token",
+		},
+		{
+			name:          "unknown zone",
+			args:          []string{"example.org", "--to", "new-token"},
+			errorContains: "zone not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, client := newFakeAPIServer(t,
+				DNSRecord{ID: "rec1", Type: "A", Name: "home", Value: "203.0.113.1"},
+				DNSRecord{ID: "rec2", Type: "AAAA", Name: "home", Value: "2001:db8::1"},
+				DNSRecord{ID: "rec3", Type: "MX", Name: "@", Value: "10 mail.example.com."},
+			)
+			targetAPI := newTestFake(t, tt.existing...)
+			var handler http.Handler = targetAPI
+			if tt.dropWrites {
+				handler = dropWrites(targetAPI)
+			}
+			target := httptest.NewServer(handler)
+			defer target.Close()
+
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.zoneTokensFile = filepath.Join(t.TempDir(), "zone-tokens")
+			os.WriteFile(server.zoneTokensFile, []byte("# routes\nexample.org=other-token\n"), 0o600)
+
+			var out bytes.Buffer
+			err := runCommand(server, append([]string{"migrate"}, append(tt.args, "--to-url", target.URL)...), &out)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var copied []string
+			for _, record := range targetAPI.Records() {
+				copied = append(copied, record.Type+" "+record.Name+" "+record.Value)
+			}
+			if strings.Join(copied, ";") != strings.Join(tt.expectedCopy, ";") {
+				t.Errorf("Expected target records %v, got %v", tt.expectedCopy, copied)
+			}
+
+			routes, err := readZoneTokensFile(server.zoneTokensFile)
+			if err != nil {
+				t.Fatalf("Failed to read routes: %v", err)
+			}
+			if routed := routes["example.com"].Primary == "new-token"; routed != tt.expectRoute {
+				t.Errorf("Expected route %v, got %v", tt.expectRoute, routes)
+			}
+			if routes["example.org"].Primary != "other-token" {
+				t.Errorf("Expected other routes to be kept, got %v", routes)
+			}
+		})
+	}
+}
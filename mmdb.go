@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+)
+
+// mmdbMetadataMarker starts the metadata section at the end of a MaxMind DB file
+var mmdbMetadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// errMMDBInvalid is returned for files that are not valid MaxMind DB databases
+var errMMDBInvalid = errors.New("invalid MaxMind DB file")
+
+// mmdbReader looks up addresses in a MaxMind DB file such as GeoLite2-Country.
+// Only the parts of the format needed for lookups are implemented, see
+// https://maxmind.github.io/MaxMind-DB/
+type mmdbReader struct {
+	tree       []byte
+	data       []byte
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	// DatabaseType is e.g. "GeoLite2-Country"
+	DatabaseType string
+	// ipv4Start is the node of ::0.0.0.0/96 in IPv6 trees
+	ipv4Start uint
+}
+
+// openMMDB parses the metadata and search tree of a MaxMind DB file
+func openMMDB(file []byte) (*mmdbReader, error) {
+	start := bytes.LastIndex(file, mmdbMetadataMarker)
+	if start < 0 {
+		return nil, fmt.Errorf("%w: no metadata", errMMDBInvalid)
+	}
+	metadata := file[start+len(mmdbMetadataMarker):]
+	value, _, err := (&mmdbDecoder{data: metadata}).decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", errMMDBInvalid, err)
+	}
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", errMMDBInvalid)
+	}
+	number := func(key string) uint {
+		n, _ := fields[key].(uint64)
+		return uint(n)
+	}
+
+	r := &mmdbReader{nodeCount: number("node_count"), recordSize: number("record_size"), ipVersion: number("ip_version")}
+	r.DatabaseType, _ = fields["database_type"].(string)
+	if r.recordSize != 24 && r.recordSize != 28 && r.recordSize != 32 {
+		return nil, fmt.Errorf("%w: unsupported record size %d", errMMDBInvalid, r.recordSize)
+	}
+	if r.ipVersion != 4 && r.ipVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", errMMDBInvalid, r.ipVersion)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+16 > uint(start) {
+		return nil, fmt.Errorf("%w: search tree exceeds the file", errMMDBInvalid)
+	}
+	r.tree = file[:treeSize]
+	r.data = file[treeSize+16 : start]
+
+	if r.ipVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < r.nodeCount; i++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// record returns the left (bit 0) or right (bit 1) record of node
+func (r *mmdbReader) record(node uint, bit uint) uint {
+	b := r.tree[node*r.recordSize/4:]
+	switch r.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// Lookup returns the data stored for addr, false if the database holds none
+func (r *mmdbReader) Lookup(addr netip.Addr) (any, bool, error) {
+	addr = addr.Unmap()
+	node := uint(0)
+	var bits []byte
+	switch {
+	case addr.Is4() && r.ipVersion == 6:
+		node = r.ipv4Start
+		a := addr.As4()
+		bits = a[:]
+	case addr.Is4():
+		a := addr.As4()
+		bits = a[:]
+	case r.ipVersion == 4:
+		return nil, false, nil
+	default:
+		a := addr.As16()
+		bits = a[:]
+	}
+
+	for i := 0; i < len(bits)*8 && node < r.nodeCount; i++ {
+		node = r.record(node, uint(bits[i/8]>>(7-i%8)&1))
+	}
+	if node == r.nodeCount {
+		return nil, false, nil
+	}
+	if node < r.nodeCount {
+		return nil, false, fmt.Errorf("%w: search tree too deep", errMMDBInvalid)
+	}
+	if node-r.nodeCount < 16 {
+		return nil, false, fmt.Errorf("%w: record points into the separator", errMMDBInvalid)
+	}
+	offset := node - r.nodeCount - 16
+	value, _, err := (&mmdbDecoder{data: r.data}).decode(offset, 0)
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// mmdbDecoder decodes values of the data section, pointers are relative to data
+type mmdbDecoder struct {
+	data []byte
+}
+
+// Types of the MaxMind DB data section
+const (
+	mmdbExtended = 0
+	mmdbPointer  = 1
+	mmdbString   = 2
+	mmdbDouble   = 3
+	mmdbBytes    = 4
+	mmdbUint16   = 5
+	mmdbUint32   = 6
+	mmdbMap      = 7
+	mmdbInt32    = 8
+	mmdbUint64   = 9
+	mmdbUint128  = 10
+	mmdbArray    = 11
+	mmdbBoolean  = 14
+	mmdbFloat    = 15
+)
+
+// maxMMDBDepth bounds the nesting of maps and arrays in corrupt files
+const maxMMDBDepth = 32
+
+// decode returns the value at offset and the offset following it
+func (d *mmdbDecoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxMMDBDepth {
+		return nil, 0, fmt.Errorf("%w: data nested too deeply", errMMDBInvalid)
+	}
+	kind, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if kind == mmdbPointer {
+		target, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	switch kind {
+	case mmdbMap:
+		values := make(map[string]any, min(size, 64))
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is not a string", errMMDBInvalid)
+			}
+			if values[name], offset, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return values, offset, nil
+	case mmdbArray:
+		values := make([]any, 0, min(size, 64))
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			values = append(values, value)
+			offset = next
+		}
+		return values, offset, nil
+	case mmdbBoolean:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > uint(len(d.data)) {
+		return nil, 0, fmt.Errorf("%w: value exceeds the data section", errMMDBInvalid)
+	}
+	payload := d.data[offset : offset+size]
+	next := offset + size
+	switch kind {
+	case mmdbString:
+		return string(payload), next, nil
+	case mmdbBytes, mmdbUint128:
+		return bytes.Clone(payload), next, nil
+	case mmdbDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: double of %d bytes", errMMDBInvalid, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(payload)), next, nil
+	case mmdbFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: float of %d bytes", errMMDBInvalid, size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(payload))), next, nil
+	case mmdbUint16, mmdbUint32, mmdbUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("%w: integer of %d bytes", errMMDBInvalid, size)
+		}
+		var n uint64
+		for _, b := range payload {
+			n = n<<8 | uint64(b)
+		}
+		return n, next, nil
+	case mmdbInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("%w: integer of %d bytes", errMMDBInvalid, size)
+		}
+		var n uint32
+		for _, b := range payload {
+			n = n<<8 | uint32(b)
+		}
+		return int64(int32(n)), next, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unknown data type %d", errMMDBInvalid, kind)
+}
+
+// control reads the control byte at offset, returning the type, the size and
+// the offset of the payload
+func (d *mmdbDecoder) control(offset uint) (int, uint, uint, error) {
+	read := func(n uint) ([]byte, error) {
+		if offset+n > uint(len(d.data)) {
+			return nil, fmt.Errorf("%w: unexpected end of data", errMMDBInvalid)
+		}
+		b := d.data[offset : offset+n]
+		offset += n
+		return b, nil
+	}
+	b, err := read(1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	ctrl := b[0]
+	kind := int(ctrl >> 5)
+	if kind == mmdbPointer {
+		// The pointer size is decoded by pointer
+		return kind, uint(ctrl & 0x1f), offset, nil
+	}
+	if kind == mmdbExtended {
+		if b, err = read(1); err != nil {
+			return 0, 0, 0, err
+		}
+		kind = 7 + int(b[0])
+	}
+
+	size := uint(ctrl & 0x1f)
+	if size >= 29 {
+		extra := size - 28
+		if b, err = read(extra); err != nil {
+			return 0, 0, 0, err
+		}
+		var n uint
+		for _, c := range b {
+			n = n<<8 | uint(c)
+		}
+		size = []uint{29, 285, 65821}[extra-1] + n
+	}
+	return kind, size, offset, nil
+}
+
+// pointer decodes the target of a pointer with the size bits of its control byte
+func (d *mmdbDecoder) pointer(bits, offset uint) (uint, uint, error) {
+	length := bits>>3&0x3 + 1
+	if offset+length > uint(len(d.data)) {
+		return 0, 0, fmt.Errorf("%w: unexpected end of data", errMMDBInvalid)
+	}
+	var n uint
+	if length < 4 {
+		n = bits & 0x7
+	}
+	for _, b := range d.data[offset : offset+length] {
+		n = n<<8 | uint(b)
+	}
+	n += []uint{0, 2048, 526336, 0}[length-1]
+	return n, offset + length, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/netip"
+	"sort"
+	"testing"
+)
+
+// encodeMMDB encodes maps, strings and unsigned integers for the data section
+func encodeMMDB(value any) []byte {
+	control := func(kind int, size int) []byte {
+		if size < 29 {
+			return []byte{byte(kind<<5 | size)}
+		}
+		return []byte{byte(kind<<5 | 29), byte(size - 29)}
+	}
+	switch v := value.(type) {
+	case string:
+		return append(control(mmdbString, len(v)), v...)
+	case int:
+		b := binary.BigEndian.AppendUint32(nil, uint32(v))
+		return append(control(mmdbUint32, 4), b...)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		out := control(mmdbMap, len(v))
+		for _, key := range keys {
+			out = append(out, encodeMMDB(key)...)
+			out = append(out, encodeMMDB(v[key])...)
+		}
+		return out
+	}
+	panic("unsupported value")
+}
+
+// mmdbTestNode is a node of the search tree built by buildTestMMDB
+type mmdbTestNode struct {
+	children [2]*mmdbTestNode
+	data     []byte
+	index    int
+}
+
+// buildTestMMDB writes an IPv6 MaxMind DB with 24 bit records holding the
+// values of networks, IPv4 networks are stored below ::/96
+func buildTestMMDB(t *testing.T, networks map[string]map[string]any) []byte {
+	t.Helper()
+	root := &mmdbTestNode{}
+	for network, value := range networks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bits := prefix.Bits()
+		addr := prefix.Addr().As16()
+		if prefix.Addr().Is4() {
+			v4 := prefix.Addr().As4()
+			addr = [16]byte{12: v4[0], 13: v4[1], 14: v4[2], 15: v4[3]}
+			bits += 96
+		}
+		node := root
+		for i := 0; i < bits; i++ {
+			bit := addr[i/8] >> (7 - i%8) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &mmdbTestNode{}
+			}
+			node = node.children[bit]
+		}
+		node.data = encodeMMDB(value)
+	}
+
+	// Number the inner nodes and lay out the data of the leaves
+	var nodes []*mmdbTestNode
+	var data []byte
+	var walk func(node *mmdbTestNode)
+	walk = func(node *mmdbTestNode) {
+		if node.data != nil {
+			node.index = len(data)
+			data = append(data, node.data...)
+			return
+		}
+		node.index = len(nodes)
+		nodes = append(nodes, node)
+		for _, child := range node.children {
+			if child != nil {
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+
+	var file []byte
+	for _, node := range nodes {
+		for _, child := range node.children {
+			record := len(nodes)
+			switch {
+			case child == nil:
+			case child.data != nil:
+				record = len(nodes) + 16 + child.index
+			default:
+				record = child.index
+			}
+			file = append(file, byte(record>>16), byte(record>>8), byte(record))
+		}
+	}
+	file = append(file, make([]byte, 16)...)
+	file = append(file, data...)
+	file = append(file, mmdbMetadataMarker...)
+	return append(file, encodeMMDB(map[string]any{
+		"node_count":    len(nodes),
+		"record_size":   24,
+		"ip_version":    6,
+		"database_type": "Test-Country",
+	})...)
+}
+
+func TestMMDBLookup(t *testing.T) {
+	reader, err := openMMDB(buildTestMMDB(t, map[string]map[string]any{
+		"81.2.69.0/24":   {"country": map[string]any{"iso_code": "GB"}},
+		"2001:db8::/32":  {"country": map[string]any{"iso_code": "DE"}},
+		"2a02:8100::/24": {"country": map[string]any{"iso_code": "NL", "names": map[string]any{"en": "Netherlands"}}},
+	}))
+	if err != nil {
+		t.Fatalf("openMMDB failed: %v", err)
+	}
+	if reader.DatabaseType != "Test-Country" {
+		t.Errorf("Expected database type Test-Country, got %q", reader.DatabaseType)
+	}
+
+	tests := []struct {
+		ip      string
+		country string
+	}{
+		{"81.2.69.142", "GB"},
+		{"::ffff:81.2.69.142", "GB"},
+		{"81.2.70.1", ""},
+		{"2001:db8::1", "DE"},
+		{"2a02:8100:1::1", "NL"},
+		{"2a03::1", ""},
+		{"192.168.1.1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			value, ok, err := reader.Lookup(netip.MustParseAddr(tt.ip))
+			if err != nil {
+				t.Fatalf("Lookup failed: %v", err)
+			}
+			if ok != (tt.country != "") {
+				t.Fatalf("Expected found=%v, got %v", tt.country != "", ok)
+			}
+			if !ok {
+				return
+			}
+			country, _ := value.(map[string]any)["country"].(map[string]any)
+			if country["iso_code"] != tt.country {
+				t.Errorf("Expected %s, got %v", tt.country, value)
+			}
+		})
+	}
+}
+
+func TestOpenMMDBInvalid(t *testing.T) {
+	valid := buildTestMMDB(t, map[string]map[string]any{"81.2.69.0/24": {"country": map[string]any{"iso_code": "GB"}}})
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"empty", nil},
+		{"no metadata", valid[:len(valid)/2]},
+		{"metadata not a map", append(append([]byte{}, mmdbMetadataMarker...), encodeMMDB("x")...)},
+		{"unsupported record size", append(append([]byte{}, mmdbMetadataMarker...), encodeMMDB(map[string]any{"node_count": 1, "record_size": 20, "ip_version": 6})...)},
+		{"tree exceeds file", append(append([]byte{}, mmdbMetadataMarker...), encodeMMDB(map[string]any{"node_count": 100, "record_size": 24, "ip_version": 4})...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := openMMDB(tt.file); !errors.Is(err, errMMDBInvalid) {
+				t.Errorf("Expected errMMDBInvalid, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,441 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// mockProviderURL is the API base URL of clients talking to the built-in fake backend
+const mockProviderURL = "http://mock-provider"
+
+// fakeHetznerState is the content of the fake backend, persisted as JSON
+type fakeHetznerState struct {
+	Zones   []Zone      `json:"zones"`
+	Records []DNSRecord `json:"records"`
+	NextID  int         `json:"next_id"`
+}
+
+// fakeHetzner implements the endpoints of the Hetzner DNS API used by the
+// client in memory, so configurations can be tested end-to-end without
+// touching real DNS. It serves HTTP and, as a RoundTripper, clients directly.
+type fakeHetzner struct {
+	mu    sync.Mutex
+	state fakeHetznerState
+	// statePath persists the state after every change, empty keeps it in memory only
+	statePath string
+	// tokens are the accepted API tokens, any non-empty token if there are none
+	tokens []string
+	// rateLimit answers 429 once more than rateLimit requests arrive within rateWindow, zero disables it
+	rateLimit   int
+	rateWindow  time.Duration
+	windowStart time.Time
+	windowCount int
+	// failures are the injected errors answering the next matching requests
+	failures []fakeFailure
+	// requests logs the method and path of every API request
+	requests []string
+}
+
+// newFakeHetzner creates a backend serving zones, loading the records of a previous run from statePath if it exists
+func newFakeHetzner(zones []string, statePath string) (*fakeHetzner, error) {
+	f := &fakeHetzner{statePath: statePath, state: fakeHetznerState{NextID: 1}}
+	if statePath != "" {
+		data, err := os.ReadFile(statePath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			if err := json.Unmarshal(data, &f.state); err != nil {
+				return nil, fmt.Errorf("invalid mock state %s: %w", statePath, err)
+			}
+		}
+	}
+	for _, name := range zones {
+		f.AddZone(name)
+	}
+	return f, nil
+}
+
+// AddZone adds an empty zone unless it exists
+func (f *fakeHetzner) AddZone(name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = parse.NormalizeHostname(name)
+	if slices.ContainsFunc(f.state.Zones, func(z Zone) bool { return z.Name == name }) {
+		return
+	}
+	now := time.Now().UTC()
+	f.state.Zones = append(f.state.Zones, Zone{
+		ID: "zone-" + strings.ReplaceAll(name, ".", "-"), Name: name, TTL: defaultRecordTTL,
+		Created: now, Modified: now, Status: "verified", Permission: "owner", ZoneType: "primary",
+	})
+}
+
+// Records returns a copy of the records of all zones
+func (f *fakeHetzner) Records() []DNSRecord {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.state.Records)
+}
+
+// RoundTrip answers requests of a Client in process
+func (f *fakeHetzner) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorder := httptest.NewRecorder()
+	f.ServeHTTP(recorder, req)
+	response := recorder.Result()
+	response.Request = req
+	return response, nil
+}
+
+// ServeHTTP serves the zones and records endpoints, with or without the /api/v1 prefix
+func (f *fakeHetzner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+	if strings.HasPrefix(path, "/_fake/") {
+		f.serveControl(w, r, strings.TrimPrefix(path, "/_fake/"))
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.admit(w, r, path) {
+		return
+	}
+	switch {
+	case path == "/zones" && r.Method == http.MethodGet:
+		page, perPage := fakePage(r, zonesPerPage)
+		zones, pagination := paginate(f.state.Zones, page, perPage)
+		response := ZonesResponse{Zones: zones}
+		response.Meta.Pagination = pagination
+		json.NewEncoder(w).Encode(response)
+	case path == "/records" && r.Method == http.MethodGet:
+		zoneID := r.URL.Query().Get("zone_id")
+		if f.zone(zoneID) == nil {
+			fakeAPIError(w, "zone not found", http.StatusNotFound)
+			return
+		}
+		// The name filter of FilterByName, the Hetzner API itself ignores it
+		name := r.URL.Query().Get("name")
+		var records []DNSRecord
+		for _, record := range f.state.Records {
+			if record.ZoneID == zoneID && (name == "" || record.Name == name) {
+				records = append(records, record)
+			}
+		}
+		page, perPage := fakePage(r, len(records))
+		records, pagination := paginate(records, page, perPage)
+		response := RecordsResponse{Records: records}
+		response.Meta.Pagination = pagination
+		json.NewEncoder(w).Encode(response)
+	case path == "/records" && r.Method == http.MethodPost:
+		var req CreateRecordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			fakeAPIError(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+		record := DNSRecord{ID: "mock" + strconv.Itoa(f.state.NextID), Type: req.Type, Name: req.Name, Value: req.Value, TTL: req.TTL, ZoneID: req.ZoneID}
+		record, ok := f.writeRecord(w, record)
+		if !ok {
+			return
+		}
+		f.state.NextID++
+		f.state.Records = append(f.state.Records, record)
+		f.save()
+		json.NewEncoder(w).Encode(RecordResponse{Record: record})
+	case strings.HasPrefix(path, "/records/"):
+		i := slices.IndexFunc(f.state.Records, func(record DNSRecord) bool { return record.ID == strings.TrimPrefix(path, "/records/") })
+		if i < 0 {
+			fakeAPIError(w, "record not found", http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			json.NewEncoder(w).Encode(RecordResponse{Record: f.state.Records[i]})
+		case http.MethodPut:
+			var req UpdateRecordRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				fakeAPIError(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			record := f.state.Records[i]
+			record.Type, record.Name, record.Value, record.TTL, record.ZoneID = req.Type, req.Name, req.Value, req.TTL, req.ZoneID
+			record, ok := f.writeRecord(w, record)
+			if !ok {
+				return
+			}
+			f.state.Records[i] = record
+			f.save()
+			json.NewEncoder(w).Encode(RecordResponse{Record: record})
+		case http.MethodDelete:
+			f.state.Records = slices.Delete(f.state.Records, i, i+1)
+			f.save()
+		default:
+			fakeAPIError(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	default:
+		fakeAPIError(w, "not found", http.StatusNotFound)
+	}
+}
+
+// writeRecord validates a record about to be stored and timestamps it, answering 422 if it is invalid
+func (f *fakeHetzner) writeRecord(w http.ResponseWriter, record DNSRecord) (DNSRecord, bool) {
+	switch {
+	case f.zone(record.ZoneID) == nil:
+		fakeAPIError(w, "zone not found", http.StatusUnprocessableEntity)
+		return record, false
+	case record.Name == "" || record.Type == "" || record.Value == "":
+		fakeAPIError(w, "name, type and value are required", http.StatusUnprocessableEntity)
+		return record, false
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	if record.Created == "" {
+		record.Created = now
+	}
+	record.Modified = now
+	return record, true
+}
+
+// zone returns the zone with id, the caller holds mu
+func (f *fakeHetzner) zone(id string) *Zone {
+	for i := range f.state.Zones {
+		if f.state.Zones[i].ID == id {
+			return &f.state.Zones[i]
+		}
+	}
+	return nil
+}
+
+// save persists the state, the caller holds mu. Failures are logged, the
+// state in memory stays authoritative.
+func (f *fakeHetzner) save() {
+	if f.statePath == "" {
+		return
+	}
+	data, err := json.MarshalIndent(f.state, "", "  ")
+	if err == nil {
+		tmp := f.statePath + ".tmp"
+		if err = os.WriteFile(tmp, data, 0o600); err == nil {
+			err = os.Rename(tmp, filepath.Clean(f.statePath))
+		}
+	}
+	if err != nil {
+		log.Printf("Mock provider: failed to save %s: %v", f.statePath, err)
+	}
+}
+
+// fakeAPIError answers with the error body of the Hetzner DNS API
+func fakeAPIError(w http.ResponseWriter, message string, status int) {
+	var body APIError
+	body.Error.Message, body.Error.Code = message, status
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// fakePage returns the requested page and page size, all entries on one page if none is requested
+func fakePage(r *http.Request, all int) (int, int) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = max(all, 1)
+	}
+	return page, perPage
+}
+
+// paginate returns a page of items with the pagination metadata of the API
+func paginate[T any](items []T, page, perPage int) ([]T, Pagination) {
+	lastPage := max((len(items)+perPage-1)/perPage, 1)
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	pagination := Pagination{Page: page, PerPage: perPage, LastPage: lastPage, TotalEntries: len(items)}
+	if page > 1 {
+		pagination.PreviousPage = page - 1
+	}
+	if page < lastPage {
+		pagination.NextPage = page + 1
+	}
+	return slices.Clone(items[start:end]), pagination
+}
+
+// mockZones returns the zones served by the mock provider: the configured
+// ones, or the domains of the hostnames, or example.com
+func mockZones(zones, hostnames []string) []string {
+	if len(zones) > 0 {
+		return zones
+	}
+	var derived []string
+	for _, hostname := range hostnames {
+		labels := strings.Split(parse.NormalizeHostname(strings.TrimPrefix(hostname, "*.")), ".")
+		if len(labels) < 2 {
+			continue
+		}
+		if zone := strings.Join(labels[len(labels)-2:], "."); !slices.Contains(derived, zone) {
+			derived = append(derived, zone)
+		}
+	}
+	if len(derived) == 0 {
+		return []string{"example.com"}
+	}
+	return derived
+}
+
+// DNS wire format constants of the mock responder
+const (
+	dnsTypeTXT      = 16
+	dnsFlagQR       = 0x8000
+	dnsFlagAA       = 0x0400
+	dnsRcodeNX      = 3
+	dnsRcodeNotImpl = 4
+)
+
+// ServeDNS answers A, AAAA and TXT queries for the records of the fake backend
+// over UDP on addr until the listener fails
+func (f *fakeHetzner) ServeDNS(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	buf := make([]byte, 512)
+	for {
+		n, client, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if response := f.answerDNS(buf[:n]); response != nil {
+			conn.WriteTo(response, client)
+		}
+	}
+}
+
+// answerDNS builds the authoritative response to query, nil for malformed queries
+func (f *fakeHetzner) answerDNS(query []byte) []byte {
+	if len(query) < 12 || binary.BigEndian.Uint16(query[4:]) != 1 {
+		return nil
+	}
+	var labels []string
+	offset := 12
+	for {
+		if offset >= len(query) {
+			return nil
+		}
+		length := int(query[offset])
+		offset++
+		if length == 0 {
+			break
+		}
+		if length&0xc0 != 0 || offset+length > len(query) {
+			return nil
+		}
+		labels = append(labels, string(query[offset:offset+length]))
+		offset += length
+	}
+	if offset+4 > len(query) {
+		return nil
+	}
+	qtype := binary.BigEndian.Uint16(query[offset:])
+	question := query[12 : offset+4]
+	name := parse.NormalizeHostname(strings.Join(labels, "."))
+
+	flags := dnsFlagQR | dnsFlagAA | binary.BigEndian.Uint16(query[2:])&dnsFlagRD
+	answers, known := f.lookupDNS(name, qtype)
+	switch {
+	case qtype != dnsTypeA && qtype != dnsTypeAAAA && qtype != dnsTypeTXT:
+		flags |= dnsRcodeNotImpl
+	case !known:
+		flags |= dnsRcodeNX
+	}
+
+	response := make([]byte, 12, 512)
+	copy(response, query[:2])
+	binary.BigEndian.PutUint16(response[2:], uint16(flags))
+	binary.BigEndian.PutUint16(response[4:], 1)
+	binary.BigEndian.PutUint16(response[6:], uint16(len(answers)))
+	response = append(response, question...)
+	for _, answer := range answers {
+		// The name points at the question
+		response = binary.BigEndian.AppendUint16(response, 0xc00c)
+		response = binary.BigEndian.AppendUint16(response, qtype)
+		response = binary.BigEndian.AppendUint16(response, dnsClassIN)
+		response = binary.BigEndian.AppendUint32(response, uint32(answer.ttl))
+		response = binary.BigEndian.AppendUint16(response, uint16(len(answer.rdata)))
+		response = append(response, answer.rdata...)
+	}
+	return response
+}
+
+// dnsAnswer is the TTL and wire format data of an answer
+type dnsAnswer struct {
+	ttl   int
+	rdata []byte
+}
+
+// lookupDNS returns the answers for name and qtype and whether name exists at all
+func (f *fakeHetzner) lookupDNS(name string, qtype uint16) ([]dnsAnswer, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var answers []dnsAnswer
+	known := false
+	for _, zone := range f.state.Zones {
+		if name == zone.Name {
+			known = true
+		}
+		for _, record := range f.state.Records {
+			if record.ZoneID != zone.ID || recordFQDN(record.Name, zone.Name) != name {
+				continue
+			}
+			known = true
+			ttl := zone.TTL
+			if record.TTL != nil {
+				ttl = *record.TTL
+			}
+			rdata := dnsRecordData(record, qtype)
+			if rdata != nil {
+				answers = append(answers, dnsAnswer{ttl: ttl, rdata: rdata})
+			}
+		}
+	}
+	return answers, known
+}
+
+// dnsRecordData encodes the value of record if it has the type qtype, nil otherwise
+func dnsRecordData(record DNSRecord, qtype uint16) []byte {
+	switch {
+	case record.Type == "A" && qtype == dnsTypeA:
+		if ip := net.ParseIP(record.Value).To4(); ip != nil {
+			return ip
+		}
+	case record.Type == "AAAA" && qtype == dnsTypeAAAA:
+		if ip := net.ParseIP(record.Value); ip != nil && ip.To4() == nil {
+			return ip.To16()
+		}
+	case record.Type == "TXT" && qtype == dnsTypeTXT:
+		// Character strings hold at most 255 bytes each
+		var rdata []byte
+		text := strings.Trim(record.Value, `"`)
+		for len(rdata) == 0 || len(text) > 0 {
+			chunk := text[:min(len(text), 255)]
+			rdata = append(append(rdata, byte(len(chunk))), chunk...)
+			text = text[len(chunk):]
+		}
+		return rdata
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newFakeClient returns a client talking to fake in process
+func newFakeClient(fake *fakeHetzner, token string) *Client {
+	client := NewClient(token)
+	client.BaseURL = mockProviderURL
+	client.HTTPClient.Transport = fake
+	return client
+}
+
+func TestFakeHetzner(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "mock-state.json")
+	fake, err := newFakeHetzner([]string{"example.com"}, statePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	client := newFakeClient(fake, "any-token")
+
+	zone, subdomain, err := client.FindZoneForFQDN("home.example.com")
+	if err != nil || zone.Name != "example.com" || subdomain != "home" {
+		t.Fatalf("Expected zone example.com and subdomain home, got %+v %q %v", zone, subdomain, err)
+	}
+	if _, changed, err := client.EnsureRecord(zone.ID, "home", "A", "203.0.113.1", 60); err != nil || !changed {
+		t.Fatalf("Expected the record to be created, got %v %v", changed, err)
+	}
+	record, changed, err := client.EnsureRecord(zone.ID, "home", "A", "203.0.113.2", 60)
+	if err != nil || !changed || record.Value != "203.0.113.2" {
+		t.Fatalf("Expected the record to be updated, got %+v %v %v", record, changed, err)
+	}
+	if _, changed, _ := client.EnsureRecord(zone.ID, "home", "A", "203.0.113.2", 60); changed {
+		t.Errorf("Expected an unchanged record not to be written")
+	}
+
+	// A restart keeps the records
+	reloaded, err := newFakeHetzner([]string{"example.com"}, statePath)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	records, err := newFakeClient(reloaded, "any-token").GetAllRecords(zone.ID)
+	if err != nil || len(records) != 1 || records[0].Value != "203.0.113.2" {
+		t.Fatalf("Expected the persisted record, got %+v %v", records, err)
+	}
+
+	if err := client.DeleteRecord(record.ID); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := client.DeleteRecord(record.ID); err == nil {
+		t.Errorf("Expected deleting a missing record to fail")
+	}
+	if _, err := client.GetAllRecords("zone-unknown"); err == nil {
+		t.Errorf("Expected listing an unknown zone to fail")
+	}
+	if _, err := newFakeClient(fake, "").GetZones(); err == nil || !strings.Contains(err.Error(), "authentication") {
+		t.Errorf("Expected a missing token to be rejected, got %v", err)
+	}
+}
+
+func TestMockProviderUpdate(t *testing.T) {
+	fake, err := newFakeHetzner([]string{"example.com"}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	server := NewDynDNSServer(newFakeClient(fake, "mock-token"), "admin", "password", "8080")
+
+	req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&myip=203.0.113.7", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	server.handleUpdate(w, req)
+
+	if !strings.HasPrefix(w.Body.String(), "good") {
+		t.Fatalf("Unexpected response %q", w.Body.String())
+	}
+	records := fake.Records()
+	if len(records) != 1 || records[0].Name != "home" || records[0].Value != "203.0.113.7" {
+		t.Errorf("Expected the record in the mock provider, got %+v", records)
+	}
+}
+
+func TestFakeHetznerAnswerDNS(t *testing.T) {
+	fake, err := newFakeHetzner([]string{"example.com"}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	ttl := 60
+	fake.state.Records = []DNSRecord{
+		{ID: "a", ZoneID: "zone-example-com", Type: "A", Name: "home", Value: "203.0.113.1", TTL: &ttl},
+		{ID: "txt", ZoneID: "zone-example-com", Type: "TXT", Name: "home", Value: `"hello"`},
+	}
+
+	tests := []struct {
+		name          string
+		query         string
+		qtype         uint16
+		expectedRcode uint16
+		expectedData  [][]byte
+	}{
+		{name: "address record", query: "Home.example.com", qtype: dnsTypeA, expectedData: [][]byte{net.ParseIP("203.0.113.1").To4()}},
+		{name: "text record", query: "home.example.com", qtype: dnsTypeTXT, expectedData: [][]byte{[]byte("\x05hello")}},
+		{name: "no record of the type", query: "home.example.com", qtype: dnsTypeAAAA},
+		{name: "zone apex", query: "example.com", qtype: dnsTypeA},
+		{name: "unknown name", query: "nas.example.com", qtype: dnsTypeA, expectedRcode: dnsRcodeNX},
+		{name: "unsupported type", query: "home.example.com", qtype: dnsTypeSOA, expectedRcode: dnsRcodeNotImpl},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, id, err := buildDNSQuery(tt.query, tt.qtype)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			response := fake.answerDNS(query)
+			if len(response) < 12 || binary.BigEndian.Uint16(response) != id {
+				t.Fatalf("Expected a response to query %d, got %x", id, response)
+			}
+			if rcode := binary.BigEndian.Uint16(response[2:]) & dnsRcodeMask; rcode != tt.expectedRcode {
+				t.Errorf("Expected rcode %d, got %d", tt.expectedRcode, rcode)
+			}
+
+			// Answers follow the echoed question, their names are pointers
+			offset := len(query)
+			var data [][]byte
+			for range binary.BigEndian.Uint16(response[6:]) {
+				length := int(binary.BigEndian.Uint16(response[offset+10:]))
+				data = append(data, response[offset+12:offset+12+length])
+				offset += 12 + length
+			}
+			if !reflect.DeepEqual(data, tt.expectedData) {
+				t.Errorf("Expected answers %x, got %x", tt.expectedData, data)
+			}
+		})
+	}
+
+	if fake.answerDNS([]byte{1, 2, 3}) != nil {
+		t.Errorf("Expected no response to a malformed query")
+	}
+}
+
+func TestMockZones(t *testing.T) {
+	tests := []struct {
+		name      string
+		zones     []string
+		hostnames []string
+		expected  []string
+	}{
+		{name: "configured zones", zones: []string{"example.org"}, hostnames: []string{"home.example.com"}, expected: []string{"example.org"}},
+		{name: "domains of the hostnames", hostnames: []string{"home.example.com", "*.lab.example.com", "vpn.example.net"}, expected: []string{"example.com", "example.net"}},
+		{name: "default", expected: []string{"example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mockZones(tt.zones, tt.hostnames); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// MQTT 3.1.1 control packet types
+const (
+	mqttConnect    = 0x10
+	mqttConnAck    = 0x20
+	mqttPublish    = 0x30
+	mqttSubscribe  = 0x82
+	mqttSubAck     = 0x90
+	mqttPingReq    = 0xC0
+	mqttPingResp   = 0xD0
+	mqttDisconnect = 0xE0
+)
+
+// MQTTConfig configures the MQTT integration
+type MQTTConfig struct {
+	Broker      string // host:port of the broker
+	ClientID    string
+	Username    string
+	Password    string
+	TopicPrefix string
+	// Commands enables update commands on <prefix>/update
+	Commands bool
+}
+
+// ipChangeEvent is published to <prefix>/events whenever a record value changes
+type ipChangeEvent struct {
+	Hostname  string `json:"hostname"`
+	Type      string `json:"type"`
+	OldValue  string `json:"old_value,omitempty"`
+	NewValue  string `json:"new_value"`
+	Timestamp string `json:"timestamp"`
+}
+
+// alertEvent is published to <prefix>/alerts for conditions needing attention
+type alertEvent struct {
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+}
+
+// mqttUpdateCommand is accepted on <prefix>/update
+type mqttUpdateCommand struct {
+	Hostname string `json:"hostname"`
+	MyIP     string `json:"myip"`
+	MyIPv6   string `json:"myipv6"`
+}
+
+// MQTTBridge publishes IP change events and status to an MQTT broker and optionally accepts update commands
+type MQTTBridge struct {
+	cfg       MQTTConfig
+	server    *DynDNSServer
+	keepAlive time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// NewMQTTBridge creates an MQTT bridge for server, call Run to connect
+func NewMQTTBridge(server *DynDNSServer, cfg MQTTConfig) *MQTTBridge {
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = "dyndns"
+	}
+	if cfg.ClientID == "" {
+		cfg.ClientID = "hetzner-dyndns"
+	}
+	return &MQTTBridge{cfg: cfg, server: server, keepAlive: 30 * time.Second}
+}
+
+// Run keeps a connection to the broker open, reconnecting with backoff, until Close is called
+func (b *MQTTBridge) Run() {
+	backoff := time.Second
+	for {
+		err := b.session()
+		if b.isClosed() {
+			return
+		}
+		log.Printf("MQTT connection to %s lost: %v, reconnecting in %s", b.cfg.Broker, err, backoff)
+		sleep(b.server.clock, backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// Close publishes the offline status and disconnects
+func (b *MQTTBridge) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	if b.conn == nil {
+		return nil
+	}
+	b.conn.Write(mqttPublishPacket(b.cfg.TopicPrefix+"/status", []byte("offline"), true))
+	b.conn.Write([]byte{mqttDisconnect, 0})
+	return b.conn.Close()
+}
+
+// PublishIPChange publishes an IP change event, it is dropped if the broker is not connected
+func (b *MQTTBridge) PublishIPChange(hostname, recordType, oldValue, newValue string) {
+	payload, err := json.Marshal(ipChangeEvent{
+		Hostname:  hostname,
+		Type:      recordType,
+		OldValue:  oldValue,
+		NewValue:  newValue,
+		Timestamp: b.server.clock.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return
+	}
+	if err := b.publish(b.cfg.TopicPrefix+"/events", payload, false); err != nil {
+		log.Printf("Failed to publish MQTT event: %v", err)
+	}
+}
+
+// PublishAlert publishes an operational alert to <prefix>/alerts
+func (b *MQTTBridge) PublishAlert(message string) {
+	payload, err := json.Marshal(alertEvent{
+		Message:   message,
+		Timestamp: b.server.clock.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return
+	}
+	if err := b.publish(b.cfg.TopicPrefix+"/alerts", payload, false); err != nil {
+		log.Printf("Failed to publish MQTT alert: %v", err)
+	}
+}
+
+// Notify implements Notifier, record changes are published as events and everything else as alerts
+func (b *MQTTBridge) Notify(event Event) error {
+	if event.Kind == eventIPChange || event.Kind == eventRecordCreated {
+		b.PublishIPChange(event.Hostname, event.Type, event.OldValue, event.NewValue)
+	} else {
+		b.PublishAlert(event.Message)
+	}
+	return nil
+}
+
+// publish sends a QoS 0 message
+func (b *MQTTBridge) publish(topic string, payload []byte, retain bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.conn == nil {
+		return errors.New("not connected")
+	}
+	_, err := b.conn.Write(mqttPublishPacket(topic, payload, retain))
+	return err
+}
+
+// isClosed reports whether Close was called
+func (b *MQTTBridge) isClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// session connects, subscribes and processes incoming packets until the connection fails
+func (b *MQTTBridge) session() error {
+	conn, err := net.DialTimeout("tcp", b.cfg.Broker, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if _, err := conn.Write(b.connectPacket()); err != nil {
+		return err
+	}
+	packetType, body, err := readMQTTPacket(reader)
+	if err != nil {
+		return err
+	}
+	if packetType != mqttConnAck || len(body) < 2 || body[1] != 0 {
+		return fmt.Errorf("connection refused by broker (code %v)", body)
+	}
+
+	if b.cfg.Commands {
+		if _, err := conn.Write(mqttSubscribePacket(1, b.cfg.TopicPrefix+"/update")); err != nil {
+			return err
+		}
+	}
+	if _, err := conn.Write(mqttPublishPacket(b.cfg.TopicPrefix+"/status", []byte("online"), true)); err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.conn = conn
+	b.mu.Unlock()
+	log.Printf("Connected to MQTT broker %s", b.cfg.Broker)
+
+	defer func() {
+		b.mu.Lock()
+		b.conn = nil
+		b.mu.Unlock()
+	}()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go b.ping(conn, stop)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(2 * b.keepAlive))
+		packetType, body, err := readMQTTPacket(reader)
+		if err != nil {
+			return err
+		}
+		if packetType&0xF0 == mqttPublish {
+			b.handlePublish(packetType, body)
+		}
+	}
+}
+
+// ping sends keepalive requests until stop is closed
+func (b *MQTTBridge) ping(conn net.Conn, stop <-chan struct{}) {
+	ticker := time.NewTicker(b.keepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			b.mu.Lock()
+			conn.Write([]byte{mqttPingReq, 0})
+			b.mu.Unlock()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// handlePublish processes an incoming update command
+func (b *MQTTBridge) handlePublish(header byte, body []byte) {
+	topic, payload, err := parseMQTTPublish(header, body)
+	if err != nil || topic != b.cfg.TopicPrefix+"/update" {
+		return
+	}
+
+	var cmd mqttUpdateCommand
+	if err := json.Unmarshal(payload, &cmd); err != nil || cmd.Hostname == "" {
+		log.Printf("Ignoring invalid MQTT update command: %s", payload)
+		return
+	}
+
+	hostname := parse.NormalizeHostname(cmd.Hostname)
+	log.Printf("MQTT update command: hostname=%s, myip=%s, myipv6=%s", hostname, cmd.MyIP, cmd.MyIPv6)
+	// Hostnames are validated like those of HTTP and gRPC updates
+	if err := parse.CheckFQDN(hostname); err != nil {
+		log.Printf("Rejected MQTT update: %v", err)
+		return
+	}
+	if !b.server.allowsHostname(hostname) {
+		log.Printf("Rejected MQTT update of %s outside the zones %s", hostname, strings.Join(b.server.zones, ", "))
+		return
+	}
+	if b.server.isRoundRobin(hostname) {
+		log.Printf("Rejected MQTT update of %s: %v", hostname, errRoundRobin)
+		return
+	}
+	for _, ip := range []string{cmd.MyIP, cmd.MyIPv6} {
+		if err := b.server.checkPublicIP(ip); err != nil {
+			log.Printf("Rejected MQTT update of %s: %v", hostname, err)
+			return
+		}
+	}
+	// DS-Lite hostnames have no public IPv4 address of their own
+	if cmd.MyIP != "" && parse.IsIPv4(cmd.MyIP) && !b.server.isDSLite(hostname) {
+		if _, err := b.server.submit(parkedWrite{Hostname: hostname, Type: "A", Value: cmd.MyIP, System: historySystemMQTT}); err != nil {
+			log.Printf("MQTT update of %s A failed: %v", hostname, err)
+		}
+	}
+	if cmd.MyIPv6 != "" && parse.IsIPv6(cmd.MyIPv6) {
+		if _, err := b.server.submit(parkedWrite{Hostname: hostname, Type: "AAAA", Value: cmd.MyIPv6, System: historySystemMQTT}); err != nil {
+			log.Printf("MQTT update of %s AAAA failed: %v", hostname, err)
+		}
+	}
+}
+
+// connectPacket builds the CONNECT packet including the offline status as last will
+func (b *MQTTBridge) connectPacket() []byte {
+	flags := byte(0x02 | 0x04 | 0x20) // clean session, will flag, will retain
+	if b.cfg.Username != "" {
+		flags |= 0x80
+	}
+	if b.cfg.Password != "" {
+		flags |= 0x40
+	}
+
+	body := mqttString("MQTT")
+	body = append(body, 4, flags)
+	body = binary.BigEndian.AppendUint16(body, uint16(b.keepAlive/time.Second))
+	body = append(body, mqttString(b.cfg.ClientID)...)
+	body = append(body, mqttString(b.cfg.TopicPrefix+"/status")...)
+	body = append(body, mqttString("offline")...)
+	if b.cfg.Username != "" {
+		body = append(body, mqttString(b.cfg.Username)...)
+	}
+	if b.cfg.Password != "" {
+		body = append(body, mqttString(b.cfg.Password)...)
+	}
+	return mqttPacket(mqttConnect, body)
+}
+
+// mqttPublishPacket builds a QoS 0 PUBLISH packet
+func mqttPublishPacket(topic string, payload []byte, retain bool) []byte {
+	header := byte(mqttPublish)
+	if retain {
+		header |= 0x01
+	}
+	return mqttPacket(header, append(mqttString(topic), payload...))
+}
+
+// mqttSubscribePacket builds a SUBSCRIBE packet for a single QoS 0 topic filter
+func mqttSubscribePacket(packetID uint16, topic string) []byte {
+	body := binary.BigEndian.AppendUint16(nil, packetID)
+	body = append(body, mqttString(topic)...)
+	body = append(body, 0)
+	return mqttPacket(mqttSubscribe, body)
+}
+
+// parseMQTTPublish extracts topic and payload from a PUBLISH packet body
+func parseMQTTPublish(header byte, body []byte) (string, []byte, error) {
+	if len(body) < 2 {
+		return "", nil, errors.New("short publish packet")
+	}
+	size := int(binary.BigEndian.Uint16(body))
+	if len(body) < 2+size {
+		return "", nil, errors.New("short publish packet")
+	}
+	topic := string(body[2 : 2+size])
+	rest := body[2+size:]
+	if (header>>1)&0x03 > 0 {
+		// QoS 1 and 2 messages carry a packet identifier
+		if len(rest) < 2 {
+			return "", nil, errors.New("short publish packet")
+		}
+		rest = rest[2:]
+	}
+	return topic, rest, nil
+}
+
+// mqttPacket prefixes body with the fixed header
+func mqttPacket(header byte, body []byte) []byte {
+	packet := []byte{header}
+	length := len(body)
+	for {
+		digit := byte(length % 128)
+		length /= 128
+		if length > 0 {
+			digit |= 0x80
+		}
+		packet = append(packet, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return append(packet, body...)
+}
+
+// mqttString encodes a length-prefixed UTF-8 string
+func mqttString(value string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(len(value))), value...)
+}
+
+// readMQTTPacket reads a single control packet and returns its first header byte and body
+func readMQTTPacket(reader *bufio.Reader) (byte, []byte, error) {
+	header, err := reader.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		digit, err := reader.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7F) * multiplier
+		multiplier *= 128
+		if digit&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(reader, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
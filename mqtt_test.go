@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMQTTPacketEncoding(t *testing.T) {
+	packet := mqttPublishPacket("dyndns/status", []byte("online"), true)
+	reader := bufio.NewReader(strings.NewReader(string(packet)))
+
+	header, body, err := readMQTTPacket(reader)
+	if err != nil {
+		t.Fatalf("readMQTTPacket failed: %v", err)
+	}
+	if header != mqttPublish|0x01 {
+		t.Errorf("Expected retained publish header, got %#x", header)
+	}
+
+	topic, payload, err := parseMQTTPublish(header, body)
+	if err != nil || topic != "dyndns/status" || string(payload) != "online" {
+		t.Errorf("Unexpected publish: topic=%s payload=%s err=%v", topic, payload, err)
+	}
+
+	// Remaining length above 127 needs two bytes
+	large := mqttPacket(mqttPublish, make([]byte, 200))
+	if large[1] != 0xC8 || large[2] != 0x01 {
+		t.Errorf("Unexpected remaining length encoding: %#x %#x", large[1], large[2])
+	}
+}
+
+func TestMQTTBridge(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	var mu sync.Mutex
+	published := map[string]string{}
+	brokerConn := make(chan net.Conn, 1)
+
+	// Minimal broker accepting a single client
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		reader := bufio.NewReader(conn)
+		for {
+			header, body, err := readMQTTPacket(reader)
+			if err != nil {
+				return
+			}
+			switch {
+			case header == mqttConnect:
+				if !strings.Contains(string(body), "broker-user") {
+					t.Errorf("Expected credentials in CONNECT packet")
+				}
+				conn.Write([]byte{mqttConnAck, 2, 0, 0})
+			case header == mqttSubscribe:
+				conn.Write([]byte{mqttSubAck, 3, body[0], body[1], 0})
+				brokerConn <- conn
+			case header&0xF0 == mqttPublish:
+				topic, payload, _ := parseMQTTPublish(header, body)
+				mu.Lock()
+				published[topic] = string(payload)
+				mu.Unlock()
+			}
+		}
+	}()
+
+	records := []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"}}
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, records, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.mqtt = NewMQTTBridge(server, MQTTConfig{
+		Broker:   listener.Addr().String(),
+		Username: "broker-user",
+		Password: "broker-pass",
+		Commands: true,
+	})
+	go server.mqtt.Run()
+	defer server.mqtt.Close()
+
+	var conn net.Conn
+	select {
+	case conn = <-brokerConn:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Bridge did not subscribe")
+	}
+
+	// Deliver an update command, the resulting change is published as event
+	command, _ := json.Marshal(mqttUpdateCommand{Hostname: "home.example.com", MyIP: "203.0.113.1"})
+	conn.Write(mqttPublishPacket("dyndns/update", command, false))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		event := published["dyndns/events"]
+		mu.Unlock()
+		if event != "" {
+			var decoded ipChangeEvent
+			json.Unmarshal([]byte(event), &decoded)
+			if decoded.Hostname != "home.example.com" || decoded.OldValue != "1.1.1.1" || decoded.NewValue != "203.0.113.1" {
+				t.Errorf("Unexpected event: %s", event)
+			}
+			mu.Lock()
+			if published["dyndns/status"] != "online" {
+				t.Errorf("Expected online status, got %q", published["dyndns/status"])
+			}
+			mu.Unlock()
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("No IP change event published, writes: %v", writes)
+}
+
+func TestMQTTUpdateCommandValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		zones    []string
+		command  mqttUpdateCommand
+		expected []string
+	}{
+		{name: "valid", command: mqttUpdateCommand{Hostname: "Home.Example.com.", MyIP: "81.2.69.1"}, expected: []string{"81.2.69.1"}},
+		{name: "not a FQDN", command: mqttUpdateCommand{Hostname: "home", MyIP: "81.2.69.1"}},
+		{name: "invalid label", command: mqttUpdateCommand{Hostname: "ho me.example.com", MyIP: "81.2.69.1"}},
+		{name: "private address", command: mqttUpdateCommand{Hostname: "home.example.com", MyIP: "192.168.178.1"}},
+		{name: "outside the zones", zones: []string{"*.lab.example.com"}, command: mqttUpdateCommand{Hostname: "home.example.com", MyIP: "81.2.69.1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeAPIServer(t)
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.zones = tt.zones
+			server.rejectBogons = true
+			bridge := NewMQTTBridge(server, MQTTConfig{Commands: true})
+
+			payload, _ := json.Marshal(tt.command)
+			header, body, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(mqttPublishPacket("dyndns/update", payload, false))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bridge.handlePublish(header, body)
+
+			var values []string
+			for _, record := range fake.Records() {
+				if record.Type == "A" {
+					values = append(values, record.Value)
+				}
+			}
+			if !slices.Equal(values, tt.expected) {
+				t.Errorf("Expected the A records %v, got %v", tt.expected, values)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// errNoZone is returned for hostnames that belong to none of the accessible zones
+	errNoZone = fmt.Errorf("no zone found for hostname")
+	// errNoZoneCached is errNoZone answered from the negative cache without asking the API
+	errNoZoneCached = fmt.Errorf("%w (cached)", errNoZone)
+)
+
+// nohostEntry is the negative cache state of a hostname
+type nohostEntry struct {
+	until    time.Time
+	failures int
+}
+
+// nohostCache remembers hostnames without a zone, so clients repeating updates
+// for them do not cause a zone listing per request. The TTL doubles with every
+// consecutive miss up to maxTTL.
+type nohostCache struct {
+	ttl    time.Duration
+	maxTTL time.Duration
+	now    func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*nohostEntry
+}
+
+// newNohostCache creates a negative cache starting at ttl and backing off to maxTTL
+func newNohostCache(ttl, maxTTL time.Duration) *nohostCache {
+	if maxTTL < ttl {
+		maxTTL = ttl
+	}
+	return &nohostCache{ttl: ttl, maxTTL: maxTTL, now: time.Now, entries: make(map[string]*nohostEntry)}
+}
+
+// Cached reports whether hostname is known to have no zone
+func (c *nohostCache) Cached(hostname string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[hostname]
+	return ok && c.now().Before(entry.until)
+}
+
+// Miss records that no zone was found for hostname and returns how long this is cached
+func (c *nohostCache) Miss(hostname string) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[hostname]
+	if !ok {
+		entry = &nohostEntry{}
+		c.entries[hostname] = entry
+	}
+	ttl := c.ttl << entry.failures
+	if ttl > c.maxTTL || ttl <= 0 {
+		ttl = c.maxTTL
+	} else {
+		entry.failures++
+	}
+	entry.until = c.now().Add(ttl)
+	return ttl
+}
+
+// Forget drops hostname from the cache, e.g. once its zone was found
+func (c *nohostCache) Forget(hostname string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, hostname)
+}
+
+// Len returns the number of hostnames currently cached as having no zone
+func (c *nohostCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	cached := 0
+	for _, entry := range c.entries {
+		if now.Before(entry.until) {
+			cached++
+		}
+	}
+	return cached
+}
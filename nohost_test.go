@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNohostCacheBackoff(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cache := newNohostCache(time.Minute, 5*time.Minute)
+	cache.now = func() time.Time { return now }
+
+	if cache.Cached("nas.example.org") {
+		t.Fatal("Expected empty cache")
+	}
+	for _, expected := range []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute} {
+		if ttl := cache.Miss("nas.example.org"); ttl != expected {
+			t.Errorf("Expected TTL %s, got %s", expected, ttl)
+		}
+	}
+	if !cache.Cached("nas.example.org") {
+		t.Error("Expected hostname to be cached")
+	}
+
+	now = now.Add(5 * time.Minute)
+	if cache.Cached("nas.example.org") {
+		t.Error("Expected entry to expire after its TTL")
+	}
+	cache.Forget("nas.example.org")
+	if ttl := cache.Miss("nas.example.org"); ttl != time.Minute {
+		t.Errorf("Expected backoff to restart after Forget, got %s", ttl)
+	}
+}
+
+func TestHandleUpdateNohost(t *testing.T) {
+	fake, client := newFakeAPIServer(t)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.nohost = newNohostCache(time.Minute, time.Hour)
+
+	var failures []Event
+	server.events.Subscribe(func(event Event) { failures = append(failures, event) }, eventUpdateFailed)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/update?hostname=nas.example.org&myip=203.0.113.1", nil)
+		req.SetBasicAuth("admin", "password")
+		w := httptest.NewRecorder()
+		server.handleUpdate(w, req)
+
+		if body := strings.TrimSpace(w.Body.String()); !strings.HasPrefix(body, "nohost") {
+			t.Errorf("Request %d: expected nohost, got %q", i+1, body)
+		}
+	}
+
+	if requests := fake.Requests(); !slices.Equal(requests, []string{"GET /zones"}) {
+		t.Errorf("Expected a single zone lookup, got %v", requests)
+	}
+	if len(failures) != 1 {
+		t.Errorf("Expected the failure to be published once, got %d", len(failures))
+	}
+}
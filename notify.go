@@ -0,0 +1,428 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/smtp"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Notifier delivers events to a notification service
+type Notifier interface {
+	Notify(event Event) error
+}
+
+// defaultEventTemplates render the message of each event kind
+var defaultEventTemplates = map[string]string{
+	eventIPChange:      `{{.Hostname}} ({{.Type}}) changed{{if .OldValue}} from {{.OldValue}}{{end}} to {{.NewValue}}`,
+	eventRecordCreated: `Created {{.Hostname}} ({{.Type}}) pointing to {{.NewValue}}`,
+	eventUpdateFailed:  `Update of {{.Hostname}} ({{.Type}}) to {{.NewValue}} failed: {{.Error}}`,
+	eventAuthFailure:   `Authentication of {{if .Username}}{{.Username}}{{else}}an unknown user{{end}} from {{.Source}} failed: {{.Error}}`,
+	eventAlert:         `{{.Message}}`,
+}
+
+// defaultNotifyKinds are delivered to notifiers without a route, auth failures
+// can be frequent during brute force attempts and must be routed explicitly
+var defaultNotifyKinds = []string{eventIPChange, eventRecordCreated, eventUpdateFailed, eventAlert}
+
+// notifierRule selects the events a notifier receives, empty fields match all events
+type notifierRule struct {
+	// Kinds defaults to defaultNotifyKinds
+	Kinds []string
+	// Hostnames are zone names or glob patterns, events without a hostname such as alerts always match
+	Hostnames   []string
+	MinSeverity string
+}
+
+// notifierRoute delivers the events matching rule to a notifier
+type notifierRoute struct {
+	name     string
+	notifier Notifier
+	rule     notifierRule
+}
+
+// matches reports whether event is routed to the notifier
+func (r notifierRoute) matches(event Event) bool {
+	kinds := r.rule.Kinds
+	if len(kinds) == 0 {
+		kinds = defaultNotifyKinds
+	}
+	if !slices.Contains(kinds, event.Kind) {
+		return false
+	}
+	if r.rule.MinSeverity != "" && severityRanks[event.Severity] < severityRanks[r.rule.MinSeverity] {
+		return false
+	}
+	if len(r.rule.Hostnames) == 0 || event.Hostname == "" {
+		return true
+	}
+	for _, pattern := range r.rule.Hostnames {
+		if matchesRoute(pattern, event.Hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notifications renders events and delivers them to the routed notifiers, retrying failed deliveries
+type Notifications struct {
+	routes    []notifierRoute
+	templates map[string]*template.Template
+	retries   int
+	backoff   time.Duration
+	clock     Clock
+	wg        sync.WaitGroup
+}
+
+// NewNotifications creates a dispatcher retrying each delivery up to retries times
+func NewNotifications(templates map[string]*template.Template, retries int) *Notifications {
+	return &Notifications{templates: templates, retries: retries, backoff: time.Second, clock: systemClock{}}
+}
+
+// Add routes the events of the given kinds to notifier, the default kinds if none are given
+func (n *Notifications) Add(name string, notifier Notifier, kinds ...string) {
+	n.AddRoute(name, notifier, notifierRule{Kinds: kinds})
+}
+
+// AddRoute routes the events matching rule to notifier
+func (n *Notifications) AddRoute(name string, notifier Notifier, rule notifierRule) {
+	n.routes = append(n.routes, notifierRoute{name: name, notifier: notifier, rule: rule})
+}
+
+// Send renders event and delivers it in the background to all notifiers routed its kind
+func (n *Notifications) Send(event Event) {
+	if event.Timestamp.IsZero() {
+		event.Timestamp = n.clock.Now().UTC()
+	}
+	if tmpl := n.templates[event.Kind]; tmpl != nil {
+		var b strings.Builder
+		if err := tmpl.Execute(&b, event); err != nil {
+			log.Printf("Failed to render %s notification: %v", event.Kind, err)
+		} else {
+			event.Message = b.String()
+		}
+	}
+
+	for _, route := range n.routes {
+		if !route.matches(event) {
+			continue
+		}
+		n.wg.Add(1)
+		go func(route notifierRoute) {
+			defer n.wg.Done()
+			n.deliver(route, event)
+		}(route)
+	}
+}
+
+// Wait blocks until all pending deliveries have finished
+func (n *Notifications) Wait() {
+	n.wg.Wait()
+}
+
+// deliver sends event through route, retrying with exponential backoff
+func (n *Notifications) deliver(route notifierRoute, event Event) {
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		err := route.notifier.Notify(event)
+		if err == nil {
+			return
+		}
+		if attempt >= n.retries {
+			log.Printf("Failed to deliver %s notification via %s: %v", event.Kind, route.name, err)
+			return
+		}
+		sleep(n.clock, backoff)
+		backoff *= 2
+	}
+}
+
+// postJSON posts v as JSON to url and checks for a successful status
+func postJSON(client *http.Client, url string, v interface{}) error {
+	return postJSONWithHeaders(client, url, nil, v)
+}
+
+// postJSONWithHeaders posts v as JSON to url with additional request headers
+func postJSONWithHeaders(client *http.Client, url string, headers map[string]string, v interface{}) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkDeliveryStatus(resp)
+}
+
+// checkDeliveryStatus turns non-2xx responses of notification services into errors
+func checkDeliveryStatus(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// webhookNotifier posts events as JSON
+type webhookNotifier struct {
+	url    string
+	client *http.Client
+}
+
+// Notify implements Notifier
+func (n webhookNotifier) Notify(event Event) error {
+	return postJSON(n.client, n.url, event)
+}
+
+// SMTPConfig configures mail delivery
+type SMTPConfig struct {
+	Addr     string // host:port of the mail server
+	Username string
+	Password string
+	From     string
+	To       []string
+}
+
+// sendMail sends a plain text mail, authenticating if a username is set
+func (c SMTPConfig) sendMail(subject, body string) error {
+	var auth smtp.Auth
+	if c.Username != "" {
+		host, _, _ := strings.Cut(c.Addr, ":")
+		auth = smtp.PlainAuth("", c.Username, c.Password, host)
+	}
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
+		c.From, strings.Join(c.To, ", "), subject, strings.ReplaceAll(body, "\n", "\r\n"))
+	return smtp.SendMail(c.Addr, auth, c.From, c.To, []byte(message))
+}
+
+// emailNotifier mails events
+type emailNotifier struct {
+	smtp SMTPConfig
+}
+
+// Notify implements Notifier
+func (n emailNotifier) Notify(event Event) error {
+	return n.smtp.sendMail(event.Title(), event.Message)
+}
+
+// ntfyNotifier publishes events to an ntfy topic URL, e.g. https://ntfy.sh/my-dyndns
+type ntfyNotifier struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// Notify implements Notifier
+func (n ntfyNotifier) Notify(event Event) error {
+	req, err := http.NewRequest("POST", n.url, strings.NewReader(event.Message))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", event.Title())
+	req.Header.Set("Tags", event.Kind)
+	if event.Severity == severityWarning {
+		req.Header.Set("Priority", "high")
+	}
+	if n.token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.token)
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkDeliveryStatus(resp)
+}
+
+// telegramAPIURL is the base URL of the Telegram Bot API
+const telegramAPIURL = "https://api.telegram.org"
+
+// telegramNotifier sends events as messages of a Telegram bot
+type telegramNotifier struct {
+	baseURL string
+	token   string
+	chatID  string
+	client  *http.Client
+}
+
+// Notify implements Notifier
+func (n telegramNotifier) Notify(event Event) error {
+	return n.redact(postJSON(n.client, n.baseURL+"/bot"+n.token+"/sendMessage", map[string]string{
+		"chat_id": n.chatID,
+		"text":    event.Message,
+	}))
+}
+
+// redact masks the bot token, which is part of the request URLs quoted in errors
+func (n telegramNotifier) redact(err error) error {
+	if err == nil || n.token == "" || !strings.Contains(err.Error(), n.token) {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), n.token, secretMask))
+}
+
+// NotifyConfig configures the notifiers and which events they receive
+type NotifyConfig struct {
+	// Notifiers are "webhook", "email", "ntfy", "telegram", "gotify" and "pushover", notifications are disabled without any
+	Notifiers []string
+	// Routes limits notifiers to event kinds, notifiers without a route receive all but auth failures
+	Routes map[string][]string
+	// Hosts limits notifiers to hostname patterns, Severities sets their minimum event severity
+	Hosts      map[string][]string
+	Severities map[string]string
+	// Templates override the default message templates by event kind
+	Templates map[string]string
+	Retries   int
+
+	WebhookURL     string
+	SMTP           SMTPConfig
+	NtfyURL        string
+	NtfyToken      string
+	TelegramToken  string
+	TelegramChatID string
+	GotifyURL      string
+	GotifyToken    string
+	PushoverToken  string
+	PushoverUser   string
+
+	// TelegramCommands enables the bot commands for the chats in TelegramAllowedChats
+	TelegramCommands     bool
+	TelegramAllowedChats []string
+}
+
+// NewNotifications creates the configured notifiers, nil if none are configured
+func (c NotifyConfig) NewNotifications() (*Notifications, error) {
+	if len(c.Notifiers) == 0 {
+		return nil, nil
+	}
+
+	templates := map[string]*template.Template{}
+	for _, kind := range eventKinds {
+		spec := defaultEventTemplates[kind]
+		if custom := c.Templates[kind]; custom != "" {
+			spec = custom
+		}
+		tmpl, err := template.New(kind).Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", kind, err)
+		}
+		if err := tmpl.Execute(io.Discard, Event{Kind: kind}); err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", kind, err)
+		}
+		templates[kind] = tmpl
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	notifications := NewNotifications(templates, c.Retries)
+	for _, name := range c.Notifiers {
+		var notifier Notifier
+		switch name {
+		case "webhook":
+			if c.WebhookURL == "" {
+				return nil, fmt.Errorf("webhook notifier requires DYNDNS_NOTIFY_WEBHOOK_URL")
+			}
+			notifier = webhookNotifier{url: c.WebhookURL, client: client}
+		case "email":
+			if c.SMTP.Addr == "" || c.SMTP.From == "" || len(c.SMTP.To) == 0 {
+				return nil, fmt.Errorf("email notifier requires DYNDNS_NOTIFY_SMTP_ADDR, DYNDNS_NOTIFY_EMAIL_FROM and DYNDNS_NOTIFY_EMAIL_TO")
+			}
+			notifier = emailNotifier{smtp: c.SMTP}
+		case "ntfy":
+			if c.NtfyURL == "" {
+				return nil, fmt.Errorf("ntfy notifier requires DYNDNS_NOTIFY_NTFY_URL")
+			}
+			notifier = ntfyNotifier{url: c.NtfyURL, token: c.NtfyToken, client: client}
+		case "telegram":
+			if c.TelegramToken == "" || c.TelegramChatID == "" {
+				return nil, fmt.Errorf("telegram notifier requires DYNDNS_NOTIFY_TELEGRAM_TOKEN and DYNDNS_NOTIFY_TELEGRAM_CHAT_ID")
+			}
+			notifier = telegramNotifier{baseURL: telegramAPIURL, token: c.TelegramToken, chatID: c.TelegramChatID, client: client}
+		case "gotify":
+			if c.GotifyURL == "" || c.GotifyToken == "" {
+				return nil, fmt.Errorf("gotify notifier requires DYNDNS_NOTIFY_GOTIFY_URL and DYNDNS_NOTIFY_GOTIFY_TOKEN")
+			}
+			notifier = gotifyNotifier{url: c.GotifyURL, token: c.GotifyToken, client: client}
+		case "pushover":
+			if c.PushoverToken == "" || c.PushoverUser == "" {
+				return nil, fmt.Errorf("pushover notifier requires DYNDNS_NOTIFY_PUSHOVER_TOKEN and DYNDNS_NOTIFY_PUSHOVER_USER")
+			}
+			notifier = pushoverNotifier{url: pushoverAPIURL, token: c.PushoverToken, user: c.PushoverUser, client: client}
+		default:
+			return nil, fmt.Errorf("unknown notifier %q (expected webhook, email, ntfy, telegram, gotify or pushover)", name)
+		}
+		notifications.AddRoute(name, notifier, notifierRule{
+			Kinds:       c.Routes[name],
+			Hostnames:   c.Hosts[name],
+			MinSeverity: c.Severities[name],
+		})
+	}
+
+	for name, severity := range c.Severities {
+		if _, ok := severityRanks[severity]; !ok {
+			return nil, fmt.Errorf("unknown severity %q for notifier %q (expected info or warning)", severity, name)
+		}
+	}
+	for _, names := range []map[string][]string{c.Routes, c.Hosts} {
+		for name := range names {
+			if !slices.Contains(c.Notifiers, name) {
+				return nil, fmt.Errorf("route for unconfigured notifier %q", name)
+			}
+		}
+	}
+	for name := range c.Severities {
+		if !slices.Contains(c.Notifiers, name) {
+			return nil, fmt.Errorf("route for unconfigured notifier %q", name)
+		}
+	}
+	return notifications, nil
+}
+
+// parseNotifyRoutes parses "notifier=kind+kind,..." into the event kinds per notifier
+func parseNotifyRoutes(value string) (map[string][]string, error) {
+	routes, err := parseNotifierLists(value)
+	if err != nil {
+		return nil, err
+	}
+	for _, kinds := range routes {
+		for _, kind := range kinds {
+			if !slices.Contains(eventKinds, kind) {
+				return nil, fmt.Errorf("unknown event kind %q (expected %s)", kind, strings.Join(eventKinds, ", "))
+			}
+		}
+	}
+	return routes, nil
+}
+
+// parseNotifierLists parses "notifier=value+value,..." into the values per notifier
+func parseNotifierLists(value string) (map[string][]string, error) {
+	lists := map[string][]string{}
+	for _, entry := range splitList(value) {
+		name, values, ok := strings.Cut(entry, "=")
+		if !ok || name == "" || values == "" {
+			return nil, fmt.Errorf("expected notifier=value+value, got %q", entry)
+		}
+		lists[name] = append(lists[name], strings.Split(values, "+")...)
+	}
+	return lists, nil
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingNotifier records delivered events, failing the first failures attempts
+type recordingNotifier struct {
+	mu       sync.Mutex
+	failures int
+	attempts int
+	events   []Event
+}
+
+func (n *recordingNotifier) Notify(event Event) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.attempts++
+	if n.attempts <= n.failures {
+		return errors.New("service unavailable")
+	}
+	n.events = append(n.events, event)
+	return nil
+}
+
+func newTestNotifications(t *testing.T, cfg NotifyConfig) *Notifications {
+	cfg.Notifiers = []string{"webhook"}
+	cfg.WebhookURL = "http://127.0.0.1:1"
+	notifications, err := cfg.NewNotifications()
+	if err != nil {
+		t.Fatalf("NewNotifications failed: %v", err)
+	}
+	notifications.routes = nil
+	notifications.backoff = 0
+	return notifications
+}
+
+func TestNotificationsRoutingAndTemplates(t *testing.T) {
+	notifications := newTestNotifications(t, NotifyConfig{
+		Retries:   2,
+		Templates: map[string]string{eventAlert: "ALERT: {{.Message}}"},
+	})
+	all, alerts, flaky := &recordingNotifier{}, &recordingNotifier{}, &recordingNotifier{failures: 2}
+	notifications.Add("all", all)
+	notifications.Add("alerts", alerts, eventAlert)
+	notifications.Add("flaky", flaky, eventIPChange)
+
+	notifications.Send(Event{Kind: eventIPChange, Hostname: "home.example.com", Type: "A", OldValue: "1.1.1.1", NewValue: "1.2.3.4"})
+	notifications.Send(Event{Kind: eventAlert, Message: "token rejected"})
+	notifications.Wait()
+
+	if len(all.events) != 2 || len(alerts.events) != 1 {
+		t.Fatalf("Unexpected routing: all=%d alerts=%d", len(all.events), len(alerts.events))
+	}
+	if message := alerts.events[0].Message; message != "ALERT: token rejected" {
+		t.Errorf("Expected custom template, got %q", message)
+	}
+	if len(flaky.events) != 1 || flaky.attempts != 3 {
+		t.Fatalf("Expected delivery after two retries, got %d events in %d attempts", len(flaky.events), flaky.attempts)
+	}
+	if message := flaky.events[0].Message; message != "home.example.com (A) changed from 1.1.1.1 to 1.2.3.4" {
+		t.Errorf("Unexpected default message %q", message)
+	}
+}
+
+func TestNotificationsGiveUp(t *testing.T) {
+	notifications := newTestNotifications(t, NotifyConfig{Retries: 1})
+	failing := &recordingNotifier{failures: 5}
+	notifications.Add("failing", failing)
+
+	notifications.Send(Event{Kind: eventAlert, Message: "test"})
+	notifications.Wait()
+
+	if failing.attempts != 2 || len(failing.events) != 0 {
+		t.Errorf("Expected two attempts without delivery, got %d attempts", failing.attempts)
+	}
+}
+
+func TestUpdateNotifiesIPChange(t *testing.T) {
+	records := []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"}}
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, records, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.notifications = newTestNotifications(t, NotifyConfig{})
+	notifier := &recordingNotifier{}
+	server.notifications.Add("test", notifier)
+
+	if _, err := server.submitUpdate("home.example.com", "1.2.3.4", "A"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := server.submitUpdate("missing.example.org", "1.2.3.4", "A"); err == nil {
+		t.Fatal("Expected error for unknown zone")
+	}
+	server.notifications.Wait()
+
+	if len(notifier.events) != 2 {
+		t.Fatalf("Expected two events, got %+v", notifier.events)
+	}
+	changed, failed := notifier.events[0], notifier.events[1]
+	if changed.Kind != eventIPChange || changed.OldValue != "1.1.1.1" || changed.NewValue != "1.2.3.4" {
+		t.Errorf("Unexpected IP change event: %+v", changed)
+	}
+	if failed.Kind != eventUpdateFailed || failed.Severity != severityWarning || failed.Hostname != "missing.example.org" || failed.Error == "" {
+		t.Errorf("Unexpected failure event: %+v", failed)
+	}
+}
+
+func TestHTTPNotifiers(t *testing.T) {
+	var path, body string
+	var header http.Header
+	service := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, body, header = r.URL.Path, string(data), r.Header
+	}))
+	defer service.Close()
+
+	event := Event{Kind: eventUpdateFailed, Severity: severityWarning, Hostname: "home.example.com", Message: "update failed"}
+
+	if err := (ntfyNotifier{url: service.URL + "/dyndns", token: "tk", client: http.DefaultClient}).Notify(event); err != nil {
+		t.Fatalf("ntfy: %v", err)
+	}
+	if path != "/dyndns" || body != "update failed" || header.Get("Title") != "DynDNS update failed: home.example.com" ||
+		header.Get("Priority") != "high" || header.Get("Authorization") != "Bearer tk" {
+		t.Errorf("Unexpected ntfy request: %s %q %v", path, body, header)
+	}
+
+	if err := (telegramNotifier{baseURL: service.URL, token: "123:abc", chatID: "42", client: http.DefaultClient}).Notify(event); err != nil {
+		t.Fatalf("telegram: %v", err)
+	}
+	var message map[string]string
+	json.Unmarshal([]byte(body), &message)
+	if path != "/bot123:abc/sendMessage" || message["chat_id"] != "42" || message["text"] != "update failed" {
+		t.Errorf("Unexpected Telegram request: %s %q", path, body)
+	}
+
+	if err := (webhookNotifier{url: service.URL + "/hook", client: http.DefaultClient}).Notify(event); err != nil {
+		t.Fatalf("webhook: %v", err)
+	}
+	var decoded Event
+	json.Unmarshal([]byte(body), &decoded)
+	if path != "/hook" || decoded.Kind != eventUpdateFailed || decoded.Hostname != "home.example.com" {
+		t.Errorf("Unexpected webhook request: %s %q", path, body)
+	}
+}
+
+func TestNotifyConfigErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		cfg           NotifyConfig
+		errorContains string
+	}{
+		{"unknown notifier", NotifyConfig{Notifiers: []string{"pager"}}, "unknown notifier"},
+		{"webhook without URL", NotifyConfig{Notifiers: []string{"webhook"}}, "DYNDNS_NOTIFY_WEBHOOK_URL"},
+		{"telegram without chat", NotifyConfig{Notifiers: []string{"telegram"}, TelegramToken: "123:abc"}, "DYNDNS_NOTIFY_TELEGRAM_CHAT_ID"},
+		{"route without notifier", NotifyConfig{Notifiers: []string{"ntfy"}, NtfyURL: "https://ntfy.sh/x", Routes: map[string][]string{"email": {eventAlert}}}, "unconfigured notifier"},
+		{"pushover without user", NotifyConfig{Notifiers: []string{"pushover"}, PushoverToken: "app"}, "DYNDNS_NOTIFY_PUSHOVER_USER"},
+		{"unknown severity", NotifyConfig{Notifiers: []string{"ntfy"}, NtfyURL: "https://ntfy.sh/x", Severities: map[string]string{"ntfy": "fatal"}}, "unknown severity"},
+		{"hosts without notifier", NotifyConfig{Notifiers: []string{"ntfy"}, NtfyURL: "https://ntfy.sh/x", Hosts: map[string][]string{"gotify": {"example.com"}}}, "unconfigured notifier"},
+		{"broken template", NotifyConfig{Notifiers: []string{"ntfy"}, NtfyURL: "https://ntfy.sh/x", Templates: map[string]string{eventAlert: "{{.Missing}}"}}, "invalid alert template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.cfg.NewNotifications()
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorContains, err)
+			}
+		})
+	}
+}
+
+func TestParseNotifyRoutes(t *testing.T) {
+	routes, err := parseNotifyRoutes("telegram=alert+update_failed, ntfy=ip_change")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(routes["telegram"], ",") != "alert,update_failed" || strings.Join(routes["ntfy"], ",") != "ip_change" {
+		t.Errorf("Unexpected routes: %v", routes)
+	}
+
+	for _, value := range []string{"telegram", "telegram=reboot"} {
+		if _, err := parseNotifyRoutes(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
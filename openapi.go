@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// apiParameter is a query parameter of an endpoint
+type apiParameter struct {
+	Name        string
+	Description string
+	Required    bool
+}
+
+// apiOperation describes an endpoint of the bridge for the OpenAPI document.
+// Request and Response are zero values of the JSON body types, their schemas
+// are derived from the struct fields and json tags.
+type apiOperation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Auth        []string
+	Parameters  []apiParameter
+	Request     any
+	Response    any
+	ContentType string
+	// Status is the success status code, 200 if zero
+	Status int
+	Errors []int
+}
+
+// Security schemes referenced by apiOperation.Auth
+const (
+	apiAuthBasic  = "basicAuth"
+	apiAuthBearer = "bearerAuth"
+)
+
+// apiOperations lists the HTTP endpoints registered in Start
+var apiOperations = []apiOperation{
+	{
+		Method: "GET", Path: "/update", Summary: "Update the records of a hostname (dyndns2), also served at /nic/update",
+		Auth: []string{apiAuthBasic},
+		Parameters: []apiParameter{
+			{Name: "hostname", Description: "Hostname to update", Required: true},
+			{Name: "myip", Description: "IPv4 address"},
+			{Name: "myipv6", Description: "IPv6 address"},
+			{Name: "offline", Description: "yes to take the hostname offline"},
+			{Name: "system", Description: "dyndns or statdns"},
+			{Name: "wildcard", Description: "ON to update *.hostname as well"},
+		},
+		Response: "", ContentType: "text/plain", Errors: []int{400, 401},
+	},
+	{
+		Method: "GET", Path: "/health", Summary: "Liveness check",
+		Response: healthResponse{},
+	},
+	{
+		Method: "GET", Path: "/readyz", Summary: "Readiness, 503 while a circuit breaker suspends API requests",
+		Response: readyResponse{}, Errors: []int{503},
+	},
+	{
+		Method: "GET", Path: "/version", Summary: "Build information",
+		Response: BuildInfo{},
+	},
+	{
+		Method: "GET", Path: "/metrics", Summary: "Prometheus metrics",
+		Response: "", ContentType: "text/plain",
+	},
+	{
+		Method: "GET", Path: "/api/status", Summary: "State of all hostnames updated since startup",
+		Auth: []string{apiAuthBasic, apiAuthBearer}, Response: statusResponse{}, Errors: []int{401},
+	},
+	{
+		Method: "GET", Path: "/api/records", Summary: "Records of a zone with their management state",
+		Auth:       []string{apiAuthBasic, apiAuthBearer},
+		Parameters: []apiParameter{{Name: "zone", Description: "Zone name", Required: true}},
+		Response:   recordsResponse{}, Errors: []int{400, 401, 404, 502},
+	},
+	{
+		Method: "POST", Path: "/api/records", Summary: "Add an SRV or CAA record",
+		Auth:    []string{apiAuthBasic, apiAuthBearer},
+		Request: serviceRecordRequest{}, Response: serviceRecordResponse{}, Status: http.StatusCreated,
+		Errors: []int{400, 401, 502},
+	},
+	{
+		Method: "DELETE", Path: "/api/records", Summary: "Delete the records of a whitelisted hostname",
+		Auth: []string{apiAuthBasic, apiAuthBearer},
+		Parameters: []apiParameter{
+			{Name: "hostname", Description: "Hostname whose records are deleted", Required: true},
+			{Name: "type", Description: "A, AAAA, SRV or CAA, all address records if empty"},
+			{Name: "value", Description: "Only delete the SRV or CAA record with this value"},
+		},
+		Response: deleteResponse{}, Errors: []int{400, 401, 403, 502},
+	},
+	{
+		Method: "GET", Path: "/api/usage", Summary: "Updates and API writes per credential today, tenants only see their own",
+		Auth: []string{apiAuthBasic, apiAuthBearer}, Response: usageResponse{}, Errors: []int{401},
+	},
+	{
+		Method: "GET", Path: "/api/manifest", Summary: "Outcome of the last reconciliation of the desired-state manifest",
+		Auth: []string{apiAuthBasic, apiAuthBearer}, Response: manifestResponse{}, Errors: []int{401, 404},
+	},
+	{
+		Method: "POST", Path: "/api/git-sync", Summary: "Push webhook triggering a sync of the manifest repository, signed with X-Hub-Signature-256 or X-Gitlab-Token",
+		Response: "", ContentType: "text/plain", Status: http.StatusAccepted, Errors: []int{401, 404},
+	},
+	{
+		Method: "GET", Path: "/api/fritzbox", Summary: "DynDNS settings to enter in a FritzBox, as text, JSON or a QR code",
+		Auth: []string{apiAuthBasic, apiAuthBearer},
+		Parameters: []apiParameter{
+			{Name: "hostname", Description: "Domain name, defaults to the first configured hostname"},
+			{Name: "format", Description: "text (default), json or png"},
+		},
+		Response: "", ContentType: "text/plain", Errors: []int{400, 401},
+	},
+	{
+		Method: "POST", Path: "/api/rollback", Summary: "Restore the previous A and AAAA values of a hostname",
+		Auth:       []string{apiAuthBasic, apiAuthBearer},
+		Parameters: []apiParameter{{Name: "hostname", Description: "Hostname to roll back", Required: true}},
+		Response:   rollbackResponse{}, Errors: []int{400, 401, 404},
+	},
+	{
+		Method: "GET", Path: "/api/history", Summary: "Timeline of the value changes of a hostname's records with the triggering client",
+		Auth: []string{apiAuthBasic, apiAuthBearer},
+		Parameters: []apiParameter{
+			{Name: "hostname", Description: "Hostname whose changes are listed", Required: true},
+			{Name: "type", Description: "Only list the changes of this record type, e.g. AAAA"},
+		},
+		Response: historyResponse{}, Errors: []int{400, 401},
+	},
+	{
+		Method: "GET", Path: "/api/sla", Summary: "Monthly availability report of the managed hostnames, as JSON or an HTML page",
+		Auth: []string{apiAuthBasic, apiAuthBearer},
+		Parameters: []apiParameter{
+			{Name: "month", Description: "Month of the report as YYYY-MM, defaults to the current month"},
+			{Name: "format", Description: "json (default) or html"},
+		},
+		Response: slaReport{}, Errors: []int{400, 401},
+	},
+	{
+		Method: "GET", Path: "/api/maintenance", Summary: "State of maintenance mode",
+		Auth: []string{apiAuthBasic, apiAuthBearer}, Response: maintenanceResponse{}, Errors: []int{401},
+	},
+	{
+		Method: "POST", Path: "/api/maintenance", Summary: "Start maintenance mode, no changes are written to the DNS API until it ends",
+		Auth: []string{apiAuthBasic, apiAuthBearer},
+		Parameters: []apiParameter{
+			{Name: "reason", Description: "Shown in the status while maintenance is active"},
+			{Name: "mode", Description: "queue (default) writes the latest update per record when maintenance ends, nochg drops them"},
+		},
+		Response: maintenanceResponse{}, Errors: []int{400, 401},
+	},
+	{
+		Method: "DELETE", Path: "/api/maintenance", Summary: "End maintenance mode and write the queued updates",
+		Auth: []string{apiAuthBasic, apiAuthBearer}, Response: maintenanceResponse{}, Errors: []int{401},
+	},
+}
+
+// openAPIDocument builds the OpenAPI 3.0 document of operations
+func openAPIDocument(operations []apiOperation) map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]map[string]any{}
+	for _, op := range operations {
+		operation := map[string]any{
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+		}
+		if len(op.Auth) > 0 {
+			var security []map[string][]string
+			for _, scheme := range op.Auth {
+				security = append(security, map[string][]string{scheme: {}})
+			}
+			operation["security"] = security
+		}
+		if len(op.Parameters) > 0 {
+			var parameters []map[string]any
+			for _, param := range op.Parameters {
+				parameters = append(parameters, map[string]any{
+					"name":        param.Name,
+					"in":          "query",
+					"description": param.Description,
+					"required":    param.Required,
+					"schema":      map[string]string{"type": "string"},
+				})
+			}
+			operation["parameters"] = parameters
+		}
+		if op.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(op.Request), schemas)}},
+			}
+		}
+
+		contentType := op.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		responses := map[string]any{
+			strconv.Itoa(status): map[string]any{
+				"description": http.StatusText(status),
+				"content":     map[string]any{contentType: map[string]any{"schema": jsonSchema(reflect.TypeOf(op.Response), schemas)}},
+			},
+		}
+		for _, code := range op.Errors {
+			response := map[string]any{"description": http.StatusText(code)}
+			// Endpoints below /api/ answer errors with the JSON envelope
+			if strings.HasPrefix(op.Path, "/api/") {
+				response["content"] = map[string]any{"application/json": map[string]any{"schema": jsonSchema(reflect.TypeOf(apiError{}), schemas)}}
+			}
+			responses[strconv.Itoa(code)] = response
+		}
+		operation["responses"] = responses
+
+		if paths[op.Path] == nil {
+			paths[op.Path] = map[string]any{}
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Hetzner DynDNS Bridge",
+			"version": currentBuildInfo().Version,
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				apiAuthBasic:  map[string]string{"type": "http", "scheme": "basic"},
+				apiAuthBearer: map[string]string{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+// timeType is encoded as an RFC 3339 string
+var timeType = reflect.TypeOf(time.Time{})
+
+// jsonSchema returns the schema of values of t as encoding/json writes them,
+// adding named structs to schemas and referencing them
+func jsonSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": jsonSchema(t.Elem(), schemas)}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchema(t.Elem(), schemas)}
+	case t.Kind() != reflect.Struct:
+		return map[string]any{}
+	}
+
+	name := schemaName(t)
+	ref := map[string]any{"$ref": "#/components/schemas/" + name}
+	if _, ok := schemas[name]; ok {
+		return ref
+	}
+	// Reserve the name first so recursive types terminate
+	schemas[name] = nil
+
+	properties := map[string]any{}
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if !field.IsExported() || tag == "-" {
+			continue
+		}
+		fieldName, options, _ := strings.Cut(tag, ",")
+		if fieldName == "" {
+			fieldName = field.Name
+		}
+		properties[fieldName] = jsonSchema(field.Type, schemas)
+		if !strings.Contains(options, "omitempty") {
+			required = append(required, fieldName)
+		}
+	}
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	schemas[name] = schema
+	return ref
+}
+
+// schemaName exports the Go type name, e.g. statusResponse becomes StatusResponse
+func schemaName(t reflect.Type) string {
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+// operationID derives a stable operation ID such as getApiStatus from method and path
+func operationID(op apiOperation) string {
+	id := strings.ToLower(op.Method)
+	for _, part := range strings.FieldsFunc(op.Path, func(r rune) bool { return r == '/' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// handleOpenAPI serves the OpenAPI document of the HTTP API
+func (s *DynDNSServer) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	document := openAPIDocument(apiOperations)
+	if base := requestBasePath(r, s.basePath); base != "" {
+		document["servers"] = []map[string]string{{"url": base}}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(document)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// openAPISpec mirrors the parts of the document checked by the tests
+type openAPISpec struct {
+	OpenAPI    string                                `json:"openapi"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]map[string]any `json:"properties"`
+			Required   []string                  `json:"required"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func TestHandleOpenAPI(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	w := httptest.NewRecorder()
+	server.handleOpenAPI(w, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON content type, got %s", w.Header().Get("Content-Type"))
+	}
+	var spec openAPISpec
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("Invalid document: %v", err)
+	}
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("Expected OpenAPI 3.0.3, got %s", spec.OpenAPI)
+	}
+
+	// Every endpoint announced at startup is documented
+	endpoints := regexp.MustCompile(`endpoints=(\S+)`).FindStringSubmatch(server.startupSummary())[1]
+	for _, path := range strings.Split(endpoints, ",") {
+		if _, ok := spec.Paths[path]; !ok && path != "/nic/update" && path != "/openapi.json" {
+			t.Errorf("Expected %s to be documented", path)
+		}
+	}
+	for _, method := range []string{"get", "post", "delete"} {
+		if _, ok := spec.Paths["/api/records"][method]; !ok {
+			t.Errorf("Expected %s /api/records to be documented", method)
+		}
+	}
+
+	status := spec.Components.Schemas["StatusResponse"]
+	if !slices.Contains(status.Required, "records") || slices.Contains(status.Required, "dnssec") {
+		t.Errorf("Expected omitempty fields to be optional, got required %v", status.Required)
+	}
+	if status.Properties["records"]["type"] != "array" {
+		t.Errorf("Expected records to be an array, got %v", status.Properties["records"])
+	}
+	if status.Properties["api_unavailable_since"]["format"] != "date-time" {
+		t.Errorf("Expected timestamps to be date-time strings, got %v", status.Properties["api_unavailable_since"])
+	}
+	if _, ok := spec.Components.Schemas["RecordAnnotation"]; !ok {
+		t.Error("Expected nested structs to be added to the schemas")
+	}
+	if envelope := spec.Components.Schemas["ApiError"]; !slices.Contains(envelope.Required, "request_id") {
+		t.Errorf("Expected the error envelope to be documented, got %v", envelope)
+	}
+	if !strings.Contains(string(spec.Paths["/api/status"]["get"]), "ApiError") || strings.Contains(string(spec.Paths["/update"]["get"]), "ApiError") {
+		t.Error("Expected only /api/ errors to reference the error envelope")
+	}
+}
+
+func TestOperationID(t *testing.T) {
+	tests := []struct {
+		op       apiOperation
+		expected string
+	}{
+		{op: apiOperation{Method: "GET", Path: "/api/status"}, expected: "getApiStatus"},
+		{op: apiOperation{Method: "DELETE", Path: "/api/records"}, expected: "deleteApiRecords"},
+		{op: apiOperation{Method: "GET", Path: "/readyz"}, expected: "getReadyz"},
+	}
+
+	for _, tt := range tests {
+		if id := operationID(tt.op); id != tt.expected {
+			t.Errorf("Expected %s, got %s", tt.expected, id)
+		}
+	}
+}
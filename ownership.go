@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ownershipHeritage identifies ownership markers written by this bridge
+const ownershipHeritage = "hetzner-dyndns"
+
+// ownershipRecordName returns the name of the TXT marker for a record, e.g. "_dyndns-a.home" for the A record "home"
+func ownershipRecordName(recordName, recordType string) string {
+	prefix := "_dyndns-" + strings.ToLower(recordType)
+	if recordName == "@" || recordName == "" {
+		return prefix
+	}
+	return prefix + "." + recordName
+}
+
+// ownershipValue returns the TXT marker value for owner
+func ownershipValue(owner string) string {
+	return fmt.Sprintf(`"heritage=%s,owner=%s"`, ownershipHeritage, owner)
+}
+
+// findOwnershipRecord returns the marker TXT record of owner for a record, or nil if there is none
+func findOwnershipRecord(records []DNSRecord, recordName, recordType, owner string) *DNSRecord {
+	name := ownershipRecordName(recordName, recordType)
+	for i := range records {
+		record := records[i]
+		if record.Type != "TXT" || record.Name != name {
+			continue
+		}
+		// Annotated markers carry further fields after heritage and owner
+		fields := parseOwnershipValue(record.Value)
+		if fields["heritage"] == ownershipHeritage && fields["owner"] == owner {
+			return &records[i]
+		}
+	}
+	return nil
+}
+
+// isOwnedRecord reports whether the record is marked as owned by owner
+func isOwnedRecord(records []DNSRecord, recordName, recordType, owner string) bool {
+	return findOwnershipRecord(records, recordName, recordType, owner) != nil
+}
+
+// createOwnershipRecord creates the marker TXT record for a record, annotated
+// with the client of the latest update of hostname if annotations are enabled
+func (s *DynDNSServer) createOwnershipRecord(client *Client, zoneID, hostname, recordName, recordType string) error {
+	value := ownershipValue(s.ownerID)
+	if s.annotations != nil {
+		if annotation, ok := s.annotations.Client(hostname); ok {
+			value = annotatedOwnershipValue(s.ownerID, annotation)
+			s.annotations.SetWritten(hostname, recordType, annotation)
+		}
+	}
+
+	ttl := defaultRecordTTL
+	_, err := client.CreateRecord(CreateRecordRequest{
+		Type:   "TXT",
+		Name:   ownershipRecordName(recordName, recordType),
+		Value:  value,
+		TTL:    &ttl,
+		ZoneID: zoneID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create ownership record: %w", err)
+	}
+
+	log.Printf("Created ownership record for %s %s", recordType, recordName)
+	return nil
+}
+
+// deleteOwnedRecord deletes a record and its marker, refusing records not owned by this bridge
+func (s *DynDNSServer) deleteOwnedRecord(client *Client, records []DNSRecord, record DNSRecord) error {
+	if s.maintenance.Active() {
+		return errMaintenance
+	}
+	marker := findOwnershipRecord(records, record.Name, record.Type, s.ownerID)
+	if marker == nil {
+		return fmt.Errorf("record %s (%s) is not owned by this bridge", record.Name, record.Type)
+	}
+
+	if err := client.DeleteRecord(record.ID); err != nil {
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+	if err := client.DeleteRecord(marker.ID); err != nil {
+		return fmt.Errorf("failed to delete ownership record: %w", err)
+	}
+
+	log.Printf("Deleted owned record %s %s", record.Type, record.Name)
+	return nil
+}
+
+// removeRecord deletes the record of hostname and recordType if it exists and
+// is owned by this bridge, reporting whether a record was deleted
+func (s *DynDNSServer) removeRecord(hostname, recordType string) (bool, error) {
+	if s.ownerID == "" {
+		return false, fmt.Errorf("deleting records requires ownership markers (DYNDNS_OWNER_ID)")
+	}
+
+	lookup, err := s.lookupRecord(hostname, recordType)
+	if err != nil {
+		return false, err
+	}
+	if lookup.Existing == nil {
+		return false, nil
+	}
+
+	if err := s.deleteOwnedRecord(lookup.Client, lookup.Records, *lookup.Existing); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestOwnershipRecordName(t *testing.T) {
+	tests := []struct {
+		recordName string
+		recordType string
+		expected   string
+	}{
+		{"home", "A", "_dyndns-a.home"},
+		{"home", "AAAA", "_dyndns-aaaa.home"},
+		{"@", "A", "_dyndns-a"},
+		{"a.b", "A", "_dyndns-a.a.b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if result := ownershipRecordName(tt.recordName, tt.recordType); result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestIsOwnedRecord(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "1", Type: "A", Name: "home", Value: "1.2.3.4"},
+		{ID: "2", Type: "TXT", Name: "_dyndns-a.home", Value: ownershipValue("bridge1")},
+		{ID: "3", Type: "A", Name: "manual", Value: "1.2.3.4"},
+		{ID: "4", Type: "TXT", Name: "_dyndns-a.other", Value: "heritage=hetzner-dyndns,owner=bridge1"},
+	}
+
+	if !isOwnedRecord(records, "home", "A", "bridge1") {
+		t.Error("Expected home A record to be owned")
+	}
+	if isOwnedRecord(records, "home", "A", "bridge2") {
+		t.Error("Expected home A record not to be owned by another owner")
+	}
+	if isOwnedRecord(records, "home", "AAAA", "bridge1") {
+		t.Error("Expected home AAAA record not to be owned")
+	}
+	if isOwnedRecord(records, "manual", "A", "bridge1") {
+		t.Error("Expected manual record not to be owned")
+	}
+	if !isOwnedRecord(records, "other", "A", "bridge1") {
+		t.Error("Expected unquoted marker value to be recognized")
+	}
+}
+
+// newOwnershipMockAPI serves records from the fake API and records the writes
+// as "POST <type> <name>", "PUT <id>" or "DELETE <id>"
+func newOwnershipMockAPI(t *testing.T, records []DNSRecord, writes *[]string) *httptest.Server {
+	fake := newTestFake(t, records...)
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/records" && r.Method == "POST":
+			body, _ := io.ReadAll(r.Body)
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			var req CreateRecordRequest
+			json.Unmarshal(body, &req)
+			mu.Lock()
+			*writes = append(*writes, "POST "+req.Type+" "+req.Name)
+			mu.Unlock()
+		case strings.HasPrefix(r.URL.Path, "/records/") && r.Method != "GET":
+			mu.Lock()
+			*writes = append(*writes, r.Method+" "+strings.TrimPrefix(r.URL.Path, "/records/"))
+			mu.Unlock()
+		}
+		fake.ServeHTTP(w, r)
+	}))
+}
+
+func TestUpdateDNSRecordOwnership(t *testing.T) {
+	tests := []struct {
+		name           string
+		hostname       string
+		records        []DNSRecord
+		expectError    bool
+		expectedWrites []string
+	}{
+		{
+			name:           "create marks record as owned",
+			hostname:       "new.example.com",
+			expectedWrites: []string{"POST A new", "POST TXT _dyndns-a.new"},
+		},
+		{
+			name:     "update owned record",
+			hostname: "home.example.com",
+			records: []DNSRecord{
+				{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"},
+				{ID: "rec2", Type: "TXT", Name: "_dyndns-a.home", Value: ownershipValue("bridge1")},
+			},
+			expectedWrites: []string{"PUT rec1"},
+		},
+		{
+			name:     "refuse unowned record",
+			hostname: "manual.example.com",
+			records: []DNSRecord{
+				{ID: "rec1", Type: "A", Name: "manual", Value: "1.1.1.1"},
+			},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, tt.records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.ownerID = "bridge1"
+
+			err := server.updateDNSRecord(tt.hostname, "1.2.3.4", "A")
+			if tt.expectError && err == nil {
+				t.Error("Expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+		})
+	}
+}
+
+func TestDeleteOwnedRecord(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"},
+		{ID: "rec2", Type: "TXT", Name: "_dyndns-a.home", Value: ownershipValue("bridge1")},
+		{ID: "rec3", Type: "A", Name: "manual", Value: "1.1.1.1"},
+	}
+
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, records, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.ownerID = "bridge1"
+
+	if err := server.deleteOwnedRecord(client, records, records[2]); err == nil {
+		t.Error("Expected error deleting unowned record")
+	}
+	if err := server.deleteOwnedRecord(client, records, records[0]); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if strings.Join(writes, ";") != "DELETE rec1;DELETE rec2" {
+		t.Errorf("Expected record and marker to be deleted, got %v", writes)
+	}
+}
@@ -0,0 +1,76 @@
+package parse
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+// The fuzz targets cover the parsers of the input taken from the network.
+// Their seeds run with go test, fuzzing with e.g.
+//
+//	go test -run=^$ -fuzz=FuzzUpdateRequest -fuzztime=30s ./parse
+
+func FuzzUpdateRequest(f *testing.F) {
+	for _, seed := range []string{
+		"hostname=home.example.com&myip=1.2.3.4",
+		"hostname=home.example.com&myip=1.2.3.4,2001:db8::1",
+		"host=home.example.com&ip=2001:db8::1&ipv6=",
+		"domains=a.example.com,b.example.com&myip=<ipaddr>&ip6lanprefix=2001:db8::/64",
+		"hostname=%zz&myip=,,:,",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		r := httptest.NewRequest("GET", "/update", nil)
+		r.URL.RawQuery = query
+		req := UpdateRequest(r)
+		if strings.Contains(req.MyIP, ":") {
+			t.Errorf("IPv6 address left in MyIP: %+v", req)
+		}
+		if req.Hostname != strings.TrimSpace(req.Hostname) {
+			t.Errorf("Hostname not trimmed: %q", req.Hostname)
+		}
+	})
+}
+
+func FuzzIsIP(f *testing.F) {
+	for _, seed := range []string{"1.2.3.4", "2001:db8::1", "::ffff:192.0.2.1", "256.1.1.1", "01.2.3.4", "fe80::1%eth0", ""} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, ip string) {
+		ipv4, ipv6 := IsIPv4(ip), IsIPv6(ip)
+		if ipv4 && ipv6 {
+			t.Fatalf("%q is valid as IPv4 and IPv6", ip)
+		}
+		addr, err := netip.ParseAddr(ip)
+		if ipv4 && (err != nil || !addr.Is4()) {
+			t.Errorf("%q accepted as IPv4, netip: %v %v", ip, addr, err)
+		}
+		if ipv6 && (err != nil || !addr.Is6()) {
+			t.Errorf("%q accepted as IPv6, netip: %v %v", ip, addr, err)
+		}
+	})
+}
+
+func FuzzCheckFQDN(f *testing.F) {
+	for _, seed := range []string{"home.example.com", "Home.Example.COM.", "*.example.com", "_acme-challenge.example.com", "-a.example.com", "1.2.3.4", "a..b", ""} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, hostname string) {
+		normalized := NormalizeHostname(hostname)
+		if NormalizeHostname(normalized) != normalized && !strings.HasSuffix(normalized, ".") {
+			t.Errorf("normalizeHostname is not idempotent for %q", hostname)
+		}
+		if CheckFQDN(normalized) != nil {
+			return
+		}
+		if len(normalized) > MaxHostnameLength || !strings.Contains(normalized, ".") || normalized != strings.ToLower(normalized) {
+			t.Errorf("Accepted invalid hostname %q", normalized)
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package parse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Length limits of RFC 1035 for names in presentation format
+const (
+	MaxHostnameLength = 253
+	MaxLabelLength    = 63
+)
+
+// NormalizeHostname lowercases hostname and strips the trailing dot of an absolute name
+func NormalizeHostname(hostname string) string {
+	return strings.ToLower(strings.TrimSuffix(hostname, "."))
+}
+
+// CheckFQDN reports why a normalized hostname is not a fully qualified domain
+// name. A leading * label is accepted for wildcard records, underscores for
+// service names such as _acme-challenge.
+func CheckFQDN(hostname string) error {
+	if len(hostname) > MaxHostnameLength {
+		return fmt.Errorf("hostname is %d characters long, at most %d are allowed", len(hostname), MaxHostnameLength)
+	}
+	labels := strings.Split(hostname, ".")
+	if len(labels) < 2 {
+		return fmt.Errorf("hostname %q is not fully qualified, expected e.g. %s.example.com", hostname, hostname)
+	}
+	for i, label := range labels {
+		switch {
+		case label == "":
+			return fmt.Errorf("hostname %q contains an empty label", hostname)
+		case len(label) > MaxLabelLength:
+			return fmt.Errorf("label %.16s... is %d characters long, at most %d are allowed", label, len(label), MaxLabelLength)
+		case label == "*" && i == 0:
+			continue
+		case label[0] == '-' || label[len(label)-1] == '-':
+			return fmt.Errorf("label %q must not start or end with a hyphen", label)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("label %q contains %q, only letters, digits, hyphens and underscores are allowed", label, c)
+			}
+		}
+	}
+	// An IPv4 address is no hostname, top-level domains are never numeric
+	if strings.Trim(labels[len(labels)-1], "0123456789") == "" {
+		return fmt.Errorf("hostname %q has a numeric top-level domain, an address was probably passed instead of a hostname", hostname)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckFQDN(t *testing.T) {
+	tests := []struct {
+		name          string
+		hostname      string
+		errorContains string
+	}{
+		{name: "hostname", hostname: "home.example.com"},
+		{name: "deep subdomain", hostname: "a.b-c.d.example.com"},
+		{name: "wildcard", hostname: "*.example.com"},
+		{name: "service name", hostname: "_acme-challenge.example.com"},
+		{name: "numeric label", hostname: "1.example.com"},
+		{name: "single label", hostname: "home", errorContains: "not fully qualified"},
+		{name: "empty label", hostname: "home..example.com", errorContains: "empty label"},
+		{name: "leading dot", hostname: ".example.com", errorContains: "empty label"},
+		{name: "hyphen", hostname: "-home.example.com", errorContains: "hyphen"},
+		{name: "invalid character", hostname: "ho me.example.com", errorContains: `contains ' '`},
+		{name: "several hostnames", hostname: "a.example.com,b.example.com", errorContains: `contains ','`},
+		{name: "wildcard inside", hostname: "home.*.example.com", errorContains: `contains '*'`},
+		{name: "long label", hostname: strings.Repeat("a", 64) + ".example.com", errorContains: "64 characters long"},
+		{name: "long hostname", hostname: strings.Repeat("abcdefgh.", 28) + "com", errorContains: "255 characters long"},
+		{name: "ip address", hostname: "203.0.113.7", errorContains: "numeric top-level domain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckFQDN(tt.hostname)
+			if tt.errorContains == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorContains, err)
+			}
+		})
+	}
+}
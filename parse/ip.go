@@ -0,0 +1,16 @@
+package parse
+
+import (
+	"net"
+	"strings"
+)
+
+// IsIPv4 checks if the given string is a valid IPv4 address
+func IsIPv4(ip string) bool {
+	return net.ParseIP(ip) != nil && strings.Count(ip, ":") == 0
+}
+
+// IsIPv6 checks if the given string is a valid IPv6 address
+func IsIPv6(ip string) bool {
+	return net.ParseIP(ip) != nil && strings.Count(ip, ":") > 0
+}
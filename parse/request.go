@@ -0,0 +1,112 @@
+// Package parse holds the parsers of the input the bridge takes from the
+// network: update requests, addresses and hostnames. They depend on no
+// server state, so they can be tested and fuzzed on their own.
+package parse
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Parameter spellings used by common DynDNS clients, checked in order
+var (
+	HostnameParams = []string{"hostname", "host", "domain", "domains"}
+	IPv4Params     = []string{"myip", "ip", "ipv4", "ipaddr", "address"}
+	IPv6Params     = []string{"myipv6", "ipv6", "ip6", "ip6addr"}
+)
+
+// Names lists the accepted spellings of the update parameters, checked in order
+type Names struct {
+	Hostname []string
+	IPv4     []string
+	IPv6     []string
+}
+
+// DefaultNames accepts the dyndns2 names as well as the No-IP, DuckDNS, Dynu and FreeDNS variants
+var DefaultNames = Names{Hostname: HostnameParams, IPv4: IPv4Params, IPv6: IPv6Params}
+
+// Request holds the normalized parameters of a DynDNS update request
+type Request struct {
+	Hostname string
+	MyIP     string
+	MyIPv6   string
+	Offline  string
+	System   string
+	Wildcard string
+
+	// FritzBox specific parameters
+	IP6LanPrefix string
+	DualStack    string
+}
+
+// Values returns the query parameters, merged with the form body once ParseForm was called
+func Values(r *http.Request) url.Values {
+	if r.Form != nil {
+		return r.Form
+	}
+	return r.URL.Query()
+}
+
+// UpdateRequest extracts the update parameters from the request, accepting
+// the dyndns2 names as well as the No-IP, DuckDNS, Dynu and FreeDNS variants
+func UpdateRequest(r *http.Request) Request {
+	return UpdateRequestWith(r, DefaultNames)
+}
+
+// UpdateRequestWith extracts the update parameters using the spellings in names
+func UpdateRequestWith(r *http.Request, names Names) Request {
+	query := Values(r)
+
+	req := Request{
+		Hostname: FirstParam(query, names.Hostname),
+		MyIP:     FirstParam(query, names.IPv4),
+		MyIPv6:   FirstParam(query, names.IPv6),
+		Offline:  query.Get("offline"),
+		System:   query.Get("system"),
+		Wildcard: query.Get("wildcard"),
+
+		IP6LanPrefix: FirstParam(query, []string{"ip6lanprefix"}),
+		DualStack:    FirstParam(query, []string{"dualstack"}),
+	}
+
+	// No-IP allows both addresses in myip separated by a comma
+	if strings.Contains(req.MyIP, ",") {
+		var ipv4 []string
+		for _, ip := range strings.Split(req.MyIP, ",") {
+			ip = strings.TrimSpace(ip)
+			if strings.Contains(ip, ":") {
+				if req.MyIPv6 == "" {
+					req.MyIPv6 = ip
+				}
+				continue
+			}
+			ipv4 = append(ipv4, ip)
+		}
+		req.MyIP = strings.Join(ipv4, ",")
+	}
+
+	// Some clients put an IPv6 address into the generic ip parameter
+	if req.MyIPv6 == "" && strings.Contains(req.MyIP, ":") {
+		req.MyIPv6 = req.MyIP
+		req.MyIP = ""
+	}
+
+	return req
+}
+
+// FirstParam returns the value of the first non-empty parameter in names,
+// FritzBox placeholders left unsubstituted by the router count as empty
+func FirstParam(query url.Values, names []string) string {
+	for _, name := range names {
+		if value := strings.TrimSpace(query.Get(name)); value != "" && !IsPlaceholder(value) {
+			return value
+		}
+	}
+	return ""
+}
+
+// IsPlaceholder reports whether value is an unsubstituted FritzBox placeholder such as <ip6addr>
+func IsPlaceholder(value string) bool {
+	return len(value) > 2 && strings.HasPrefix(value, "<") && strings.HasSuffix(value, ">")
+}
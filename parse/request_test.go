@@ -0,0 +1,74 @@
+package parse
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseUpdateRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected Request
+	}{
+		{
+			name:     "dyndns2 parameters",
+			query:    "hostname=home.example.com&myip=1.2.3.4&myipv6=2001:db8::1&wildcard=ON&system=dyndns",
+			expected: Request{Hostname: "home.example.com", MyIP: "1.2.3.4", MyIPv6: "2001:db8::1", Wildcard: "ON", System: "dyndns"},
+		},
+		{
+			name:     "DuckDNS parameters",
+			query:    "domains=home.example.com&ip=1.2.3.4&ipv6=2001:db8::1",
+			expected: Request{Hostname: "home.example.com", MyIP: "1.2.3.4", MyIPv6: "2001:db8::1"},
+		},
+		{
+			name:     "FreeDNS parameters",
+			query:    "host=home.example.com&address=1.2.3.4",
+			expected: Request{Hostname: "home.example.com", MyIP: "1.2.3.4"},
+		},
+		{
+			name:     "No-IP combined addresses",
+			query:    "hostname=home.example.com&myip=1.2.3.4,2001:db8::1",
+			expected: Request{Hostname: "home.example.com", MyIP: "1.2.3.4", MyIPv6: "2001:db8::1"},
+		},
+		{
+			name:     "IPv6 in generic ip parameter",
+			query:    "domain=home.example.com&ip=2001:db8::1",
+			expected: Request{Hostname: "home.example.com", MyIPv6: "2001:db8::1"},
+		},
+		{
+			name:     "unsubstituted FritzBox placeholders",
+			query:    "hostname=home.example.com&myip=1.2.3.4&myipv6=<ip6addr>&dualstack=<dualstack>",
+			expected: Request{Hostname: "home.example.com", MyIP: "1.2.3.4"},
+		},
+		{
+			name:     "FritzBox LAN prefix",
+			query:    "hostname=home.example.com&myipv6=2001:db8::1&ip6lanprefix=2001:db8:1:2::/64&dualstack=1",
+			expected: Request{Hostname: "home.example.com", MyIPv6: "2001:db8::1", IP6LanPrefix: "2001:db8:1:2::/64", DualStack: "1"},
+		},
+		{
+			name:     "dyndns2 names take precedence",
+			query:    "hostname=a.example.com&host=b.example.com&myip=1.2.3.4&ip=5.6.7.8",
+			expected: Request{Hostname: "a.example.com", MyIP: "1.2.3.4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/update?"+tt.query, nil)
+			result := UpdateRequest(req)
+			if result != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func BenchmarkUpdateRequest(b *testing.B) {
+	r := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&myip=1.2.3.4,2001:db8::1", nil)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		UpdateRequest(r)
+	}
+}
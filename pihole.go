@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// PiholeBackend writes local DNS records through the Pi-hole v6 API
+type PiholeBackend struct {
+	baseURL  string
+	password string
+	client   *http.Client
+}
+
+// piholeAuthResponse is returned by POST /api/auth
+type piholeAuthResponse struct {
+	Session struct {
+		Valid bool   `json:"valid"`
+		SID   string `json:"sid"`
+	} `json:"session"`
+}
+
+// piholeHostsResponse is returned by GET /api/config/dns/hosts, each host is "address hostname..."
+type piholeHostsResponse struct {
+	Config struct {
+		DNS struct {
+			Hosts []string `json:"hosts"`
+		} `json:"dns"`
+	} `json:"config"`
+}
+
+// NewPiholeBackend creates a backend for the Pi-hole at baseURL, e.g. http://pi.hole,
+// authenticating with the web interface or an app password
+func NewPiholeBackend(baseURL, password string) *PiholeBackend {
+	return &PiholeBackend{
+		baseURL:  strings.TrimSuffix(baseURL, "/"),
+		password: password,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SetRecord replaces the local DNS entries of hostname with the same address family as value
+func (b *PiholeBackend) SetRecord(hostname, recordType, value string) error {
+	sid, err := b.login()
+	if err != nil {
+		return fmt.Errorf("failed to log in: %w", err)
+	}
+	// Pi-hole only keeps a few sessions, so release this one right away
+	defer b.do("DELETE", "/api/auth", sid, nil, nil)
+
+	var hosts piholeHostsResponse
+	if err := b.do("GET", "/api/config/dns/hosts", sid, nil, &hosts); err != nil {
+		return err
+	}
+
+	entry := value + " " + hostname
+	exists := false
+	for _, host := range hosts.Config.DNS.Hosts {
+		// Entries listing several hostnames were not written by the bridge and are left alone
+		fields := strings.Fields(host)
+		if len(fields) != 2 || !strings.EqualFold(fields[1], hostname) || !isAddressOf(recordType, fields[0]) {
+			continue
+		}
+		if fields[0] == value {
+			exists = true
+			continue
+		}
+		if err := b.do("DELETE", "/api/config/dns/hosts/"+url.PathEscape(host), sid, nil, nil); err != nil {
+			return err
+		}
+	}
+	if exists {
+		return nil
+	}
+	return b.do("PUT", "/api/config/dns/hosts/"+url.PathEscape(entry), sid, nil, nil)
+}
+
+// login creates an API session and returns its ID
+func (b *PiholeBackend) login() (string, error) {
+	var auth piholeAuthResponse
+	if err := b.do("POST", "/api/auth", "", map[string]string{"password": b.password}, &auth); err != nil {
+		return "", err
+	}
+	if !auth.Session.Valid || auth.Session.SID == "" {
+		return "", fmt.Errorf("password rejected")
+	}
+	return auth.Session.SID, nil
+}
+
+// do sends body as JSON to path within session sid and decodes the response into out unless it is nil
+func (b *PiholeBackend) do(method, path, sid string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, b.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	if sid != "" {
+		req.Header.Set("X-FTL-SID", sid)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkDeliveryStatus(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPiholeSetRecord(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.URL.Path == "/api/auth" && r.Method == "POST" {
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			valid := body["password"] == "app-password"
+			json.NewEncoder(w).Encode(map[string]interface{}{"session": map[string]interface{}{"valid": valid, "sid": "session1"}})
+			return
+		}
+		if r.Header.Get("X-FTL-SID") != "session1" {
+			t.Errorf("Missing session ID for %s %s", r.Method, r.URL.Path)
+		}
+		if r.Method == "GET" {
+			w.Write([]byte(`{"config":{"dns":{"hosts":["192.168.178.9 nas.example.com","2001:db8::9 nas.example.com","192.168.178.9 nas.example.com nas","192.168.178.20 tv.example.com"]}}}`))
+			return
+		}
+		path, _ := url.PathUnescape(r.URL.EscapedPath())
+		requests = append(requests, r.Method+" "+path)
+	}))
+	defer api.Close()
+
+	backend := NewPiholeBackend(api.URL+"/", "app-password")
+	if err := backend.SetRecord("nas.example.com", "A", "192.168.178.10"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "DELETE /api/config/dns/hosts/192.168.178.9 nas.example.com;PUT /api/config/dns/hosts/192.168.178.10 nas.example.com;DELETE /api/auth"
+	if got := strings.Join(requests, ";"); got != expected {
+		t.Errorf("Expected requests %q, got %q", expected, got)
+	}
+
+	// The existing entry is kept
+	requests = nil
+	if err := backend.SetRecord("tv.example.com", "A", "192.168.178.20"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(requests) != 1 {
+		t.Errorf("Expected only the logout, got %v", requests)
+	}
+
+	backend.password = "wrong"
+	if err := backend.SetRecord("nas.example.com", "A", "192.168.178.10"); err == nil {
+		t.Error("Expected rejected password to fail")
+	}
+}
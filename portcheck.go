@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PortCheck is a TCP port that must be reachable on the address of hostnames matching Pattern
+type PortCheck struct {
+	Pattern string
+	Port    int
+}
+
+// PortChecker verifies after address changes that forwarded ports are reachable
+// on the new address, alerting when DNS points somewhere the services are not.
+// Checks either dial the address directly or ask an external checker, since a
+// direct dial from inside the LAN only tests the router's NAT loopback.
+type PortChecker struct {
+	checks []PortCheck
+	// checkURL is the external checker, {ip} and {port} are replaced, a 2xx answer means reachable
+	checkURL string
+	// delay gives the router time to apply new forwardings before checking
+	delay   time.Duration
+	timeout time.Duration
+	events  *EventBus
+	metrics *Metrics
+	client  *http.Client
+	dial    func(network, address string, timeout time.Duration) (net.Conn, error)
+	wg      sync.WaitGroup
+}
+
+// NewPortChecker creates a checker publishing alerts for unreachable ports on server's event bus
+func NewPortChecker(server *DynDNSServer, checks []PortCheck, checkURL string, delay, timeout time.Duration) *PortChecker {
+	server.metrics.Describe("dyndns_port_checks_total", "counter", "Number of port reachability checks by result.")
+	return &PortChecker{
+		checks:   checks,
+		checkURL: checkURL,
+		delay:    delay,
+		timeout:  timeout,
+		events:   server.events,
+		metrics:  server.metrics,
+		client:   &http.Client{Timeout: timeout},
+		dial:     net.DialTimeout,
+	}
+}
+
+// Handle checks the ports of the changed hostname in the background
+func (c *PortChecker) Handle(event Event) {
+	if event.Type != "A" && event.Type != "AAAA" {
+		return
+	}
+	for _, check := range c.checks {
+		if !matchesRoute(check.Pattern, event.Hostname) {
+			continue
+		}
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			time.Sleep(c.delay)
+			c.run(event, check.Port)
+		}()
+	}
+}
+
+// Wait blocks until all started checks have finished
+func (c *PortChecker) Wait() {
+	c.wg.Wait()
+}
+
+// run checks a single port and publishes an alert if it is unreachable
+func (c *PortChecker) run(event Event, port int) {
+	address := net.JoinHostPort(event.NewValue, strconv.Itoa(port))
+	err := c.check(event.NewValue, port)
+	if err == nil {
+		c.metrics.Inc("dyndns_port_checks_total", Labels{"result": "reachable"})
+		log.Printf("Port check: %s (%s) is reachable", address, event.Hostname)
+		return
+	}
+
+	c.metrics.Inc("dyndns_port_checks_total", Labels{"result": "unreachable"})
+	message := fmt.Sprintf("%s points to %s, but port %d is not reachable there: %v", event.Hostname, event.NewValue, port, err)
+	log.Printf("Port check: %s", message)
+	c.events.Publish(Event{
+		Kind:     eventAlert,
+		Severity: severityWarning,
+		Hostname: event.Hostname,
+		Type:     event.Type,
+		NewValue: event.NewValue,
+		Error:    err.Error(),
+		Message:  message,
+	})
+}
+
+// check reports whether port is reachable on ip through the external checker or by dialing it
+func (c *PortChecker) check(ip string, port int) error {
+	if c.checkURL == "" {
+		conn, err := c.dial("tcp", net.JoinHostPort(ip, strconv.Itoa(port)), c.timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	target := strings.NewReplacer("{ip}", url.QueryEscape(ip), "{port}", strconv.Itoa(port)).Replace(c.checkURL)
+	resp, err := c.client.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkDeliveryStatus(resp)
+}
+
+// parsePortChecks parses "hostname:port" entries separated by commas, hostname may be a glob
+func parsePortChecks(value string) ([]PortCheck, error) {
+	var checks []PortCheck
+	for _, entry := range splitList(value) {
+		host, port, err := net.SplitHostPort(entry)
+		if err != nil || host == "" {
+			return nil, fmt.Errorf("expected hostname:port, got %q", entry)
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return nil, fmt.Errorf("invalid port in %q", entry)
+		}
+		checks = append(checks, PortCheck{Pattern: strings.ToLower(host), Port: n})
+	}
+	return checks, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParsePortChecks(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		expected    []PortCheck
+		expectError bool
+	}{
+		{name: "empty", value: ""},
+		{
+			name:     "hostnames and globs",
+			value:    "Home.example.com:443, *.lab.example.com:22",
+			expected: []PortCheck{{Pattern: "home.example.com", Port: 443}, {Pattern: "*.lab.example.com", Port: 22}},
+		},
+		{name: "missing port", value: "home.example.com", expectError: true},
+		{name: "invalid port", value: "home.example.com:70000", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checks, err := parsePortChecks(tt.value)
+			if (err != nil) != tt.expectError {
+				t.Fatalf("Expected error %v, got %v", tt.expectError, err)
+			}
+			if !reflect.DeepEqual(checks, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, checks)
+			}
+		})
+	}
+}
+
+func TestPortChecker(t *testing.T) {
+	open, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer open.Close()
+	openPort := open.Addr().(*net.TCPAddr).Port
+
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	closed.Close()
+
+	var checked []string
+	checker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		checked = append(checked, r.URL.Query().Get("ip")+" "+r.URL.Query().Get("port"))
+		if r.URL.Query().Get("port") != "443" {
+			http.Error(w, "closed", http.StatusServiceUnavailable)
+		}
+	}))
+	defer checker.Close()
+
+	tests := []struct {
+		name           string
+		checkURL       string
+		port           int
+		event          Event
+		expectAlert    bool
+		expectedChecks []string
+	}{
+		{
+			name:  "reachable port",
+			port:  openPort,
+			event: Event{Kind: eventIPChange, Hostname: "home.example.com", Type: "A", NewValue: "127.0.0.1"},
+		},
+		{
+			name:        "unreachable port",
+			port:        closedPort,
+			event:       Event{Kind: eventIPChange, Hostname: "home.example.com", Type: "A", NewValue: "127.0.0.1"},
+			expectAlert: true,
+		},
+		{
+			name:  "other hostname is not checked",
+			port:  closedPort,
+			event: Event{Kind: eventIPChange, Hostname: "office.example.com", Type: "A", NewValue: "127.0.0.1"},
+		},
+		{
+			name:           "external checker reachable",
+			checkURL:       checker.URL + "/?ip={ip}&port={port}",
+			port:           443,
+			event:          Event{Kind: eventRecordCreated, Hostname: "home.example.com", Type: "AAAA", NewValue: "2001:db8::1"},
+			expectedChecks: []string{"2001:db8::1 443"},
+		},
+		{
+			name:           "external checker unreachable",
+			checkURL:       checker.URL + "/?ip={ip}&port={port}",
+			port:           22,
+			event:          Event{Kind: eventIPChange, Hostname: "home.example.com", Type: "A", NewValue: "203.0.113.7"},
+			expectAlert:    true,
+			expectedChecks: []string{"203.0.113.7 22"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checked = nil
+			server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+			var mu sync.Mutex
+			var alerts []Event
+			server.events.Subscribe(func(event Event) {
+				mu.Lock()
+				alerts = append(alerts, event)
+				mu.Unlock()
+			}, eventAlert)
+
+			portChecker := NewPortChecker(server, []PortCheck{{Pattern: "home.example.com", Port: tt.port}}, tt.checkURL, 0, time.Second)
+			portChecker.Handle(tt.event)
+			portChecker.Wait()
+
+			if (len(alerts) > 0) != tt.expectAlert {
+				t.Errorf("Expected alert %v, got %v", tt.expectAlert, alerts)
+			}
+			if tt.expectAlert && alerts[0].Severity != severityWarning {
+				t.Errorf("Expected a warning, got %+v", alerts[0])
+			}
+			if !reflect.DeepEqual(checked, tt.expectedChecks) {
+				t.Errorf("Expected checks %v, got %v", tt.expectedChecks, checked)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// secretMask replaces configured secrets in printed configurations
+const secretMask = "********"
+
+// maskSecret masks a non-empty secret, empty values stay empty to show they are unset
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return secretMask
+}
+
+// Redacted returns a copy of the configuration with all secrets masked
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redacted.APIKey = maskSecret(c.APIKey)
+	redacted.SecondaryAPIKey = maskSecret(c.SecondaryAPIKey)
+	redacted.Password = maskSecret(c.Password)
+	redacted.CrowdSecAPIKey = maskSecret(c.CrowdSecAPIKey)
+	redacted.Store.RedisPassword = maskSecret(c.Store.RedisPassword)
+	redacted.MQTT.Password = maskSecret(c.MQTT.Password)
+	redacted.Report.SMTPPassword = maskSecret(c.Report.SMTPPassword)
+	redacted.Notify.SMTP.Password = maskSecret(c.Notify.SMTP.Password)
+	redacted.Notify.NtfyToken = maskSecret(c.Notify.NtfyToken)
+	redacted.Notify.TelegramToken = maskSecret(c.Notify.TelegramToken)
+	redacted.Notify.GotifyToken = maskSecret(c.Notify.GotifyToken)
+	redacted.Notify.PushoverToken = maskSecret(c.Notify.PushoverToken)
+	redacted.ReverseDNS.CloudToken = maskSecret(c.ReverseDNS.CloudToken)
+	redacted.ReverseDNS.RobotPassword = maskSecret(c.ReverseDNS.RobotPassword)
+	redacted.Firewall.CloudToken = maskSecret(c.Firewall.CloudToken)
+	redacted.LocalDNS.RFC2136TSIG = maskSecret(c.LocalDNS.RFC2136TSIG)
+	redacted.LocalDNS.PiholePassword = maskSecret(c.LocalDNS.PiholePassword)
+	redacted.LocalDNS.AdGuardPassword = maskSecret(c.LocalDNS.AdGuardPassword)
+	redacted.Git.WebhookSecret = maskSecret(c.Git.WebhookSecret)
+	// Clone URLs may carry an access token as password
+	if repo, err := url.Parse(c.Git.Repo); err == nil {
+		redacted.Git.Repo = repo.Redacted()
+	}
+
+	redacted.Auth.Tokens = make([]string, len(c.Auth.Tokens))
+	for i, token := range c.Auth.Tokens {
+		redacted.Auth.Tokens[i] = maskSecret(token)
+	}
+	redacted.ZoneTokens = make(map[string]APIToken, len(c.ZoneTokens))
+	for pattern, token := range c.ZoneTokens {
+		redacted.ZoneTokens[pattern] = APIToken{Primary: maskSecret(token.Primary), Secondary: maskSecret(token.Secondary)}
+	}
+	redacted.Tenants = make([]TenantConfig, len(c.Tenants))
+	for i, tenant := range c.Tenants {
+		tenant.Password = maskSecret(tenant.Password)
+		tenant.APIToken = maskSecret(tenant.APIToken)
+		tenant.NotifyNtfyToken = maskSecret(tenant.NotifyNtfyToken)
+		redacted.Tenants[i] = tenant
+	}
+	return &redacted
+}
+
+// printConfig writes the configuration with masked secrets to out, one "Field: value" line per setting
+func printConfig(cfg *Config, out io.Writer) {
+	printFields(out, "", reflect.ValueOf(*cfg.Redacted()))
+}
+
+// printFields writes the fields of the struct v, prefixing nested structs with their field name
+func printFields(out io.Writer, prefix string, v reflect.Value) {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, value := prefix+field.Name, v.Field(i)
+
+		switch value.Kind() {
+		case reflect.Struct:
+			printFields(out, name+".", value)
+		case reflect.Map:
+			keys := make([]string, 0, value.Len())
+			for _, key := range value.MapKeys() {
+				keys = append(keys, fmt.Sprintf("%v=%v", key, value.MapIndex(key)))
+			}
+			sort.Strings(keys)
+			fmt.Fprintf(out, "%s: %s\n", name, strings.Join(keys, ", "))
+		case reflect.Slice:
+			items := make([]string, value.Len())
+			for j := range items {
+				items[j] = fmt.Sprint(value.Index(j))
+			}
+			fmt.Fprintf(out, "%s: %s\n", name, strings.Join(items, ", "))
+		default:
+			fmt.Fprintf(out, "%s: %v\n", name, value)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrintConfigMasksSecrets(t *testing.T) {
+	cfg, err := loadConfig(mapLookup(map[string]string{
+		"HETZNER_DNS_API_KEY":   "api-secret",
+		"DYNDNS_PASSWORD":       "password-secret",
+		"DYNDNS_ZONE_TOKENS":    "example.org=zone-secret|zone-secondary",
+		"DYNDNS_AUTH_TOKENS":    "bearer-secret",
+		"DYNDNS_MQTT_PASSWORD":  "mqtt-secret",
+		"DYNDNS_REDIS_PASSWORD": "redis-secret",
+		"DYNDNS_HOSTNAMES":      "home.example.com",
+		"DYNDNS_NOTIFY":         "telegram",
+
+		"DYNDNS_NOTIFY_TELEGRAM_TOKEN":   "telegram-secret",
+		"DYNDNS_NOTIFY_TELEGRAM_CHAT_ID": "42",
+	}))
+	if err != nil {
+		t.Fatalf("loadConfig failed: %v", err)
+	}
+
+	var out bytes.Buffer
+	printConfig(cfg, &out)
+	printed := out.String()
+
+	for _, secret := range []string{"api-secret", "password-secret", "zone-secret", "zone-secondary", "bearer-secret", "mqtt-secret", "redis-secret", "telegram-secret"} {
+		if strings.Contains(printed, secret) {
+			t.Errorf("Printed configuration contains secret %q", secret)
+		}
+	}
+	for _, line := range []string{"APIKey: " + secretMask, "Port: 8080", "Hostnames: home.example.com", "Store.Type: memory", "SecondaryAPIKey: \n", "ReconcileInterval: 15m0s"} {
+		if !strings.Contains(printed, line) {
+			t.Errorf("Expected printed configuration to contain %q:\n%s", line, printed)
+		}
+	}
+	if cfg.APIKey != "api-secret" {
+		t.Error("Expected the original configuration to be left unmasked")
+	}
+}
+
+func TestStartupSummaryHidesCredentials(t *testing.T) {
+	server := NewDynDNSServer(nil, "admin", "password-secret", "8080")
+	server.hostnames = []string{"home.example.com", "nas.example.com"}
+
+	summary := server.startupSummary()
+	if strings.Contains(summary, "password-secret") || strings.Contains(summary, "admin") {
+		t.Errorf("Startup summary reveals credentials: %s", summary)
+	}
+	if !strings.Contains(summary, "listen=:8080") || !strings.Contains(summary, "hostnames=2") {
+		t.Errorf("Unexpected startup summary: %s", summary)
+	}
+}
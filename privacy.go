@@ -0,0 +1,62 @@
+package main
+
+import (
+	"io"
+	"net/netip"
+	"regexp"
+)
+
+// Log privacy modes
+const (
+	logPrivacyOff  = "off"
+	logPrivacyIP   = "ip"   // mask IP addresses
+	logPrivacyFull = "full" // mask IP addresses and redact hostnames
+)
+
+var (
+	ipv4Pattern     = regexp.MustCompile(`\b(\d{1,3}\.\d{1,3}\.\d{1,3})\.\d{1,3}\b`)
+	ipv6Pattern     = regexp.MustCompile(`[0-9A-Fa-f]*:[0-9A-Fa-f:.]*:[0-9A-Fa-f.]*`)
+	hostnamePattern = regexp.MustCompile(`(?i)(?:\*\.)?\b(?:[a-z0-9_-]+\.)+([a-z0-9-]+\.[a-z]{2,})\b`)
+)
+
+// redactingWriter masks addresses and optionally hostnames in log output
+// before passing it on, for logs shipped to third-party aggregators
+type redactingWriter struct {
+	out       io.Writer
+	hostnames bool
+}
+
+// newRedactingWriter creates a writer applying the log privacy mode to out
+func newRedactingWriter(out io.Writer, mode string) io.Writer {
+	return &redactingWriter{out: out, hostnames: mode == logPrivacyFull}
+}
+
+// Write redacts p and writes it to the underlying writer
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := w.out.Write(w.redact(p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// redact applies the masking rules to a log line
+func (w *redactingWriter) redact(line []byte) []byte {
+	line = ipv6Pattern.ReplaceAllFunc(line, func(match []byte) []byte {
+		addr, err := netip.ParseAddr(string(match))
+		if err != nil || !addr.Is6() || addr.Is4In6() {
+			return match
+		}
+		return []byte(maskIPv6(addr))
+	})
+	line = ipv4Pattern.ReplaceAll(line, []byte("${1}.0"))
+	if w.hostnames {
+		line = hostnamePattern.ReplaceAll(line, []byte("***.${1}"))
+	}
+	return line
+}
+
+// maskIPv6 keeps the /48 routing prefix of addr, masking the lower 80 bits
+func maskIPv6(addr netip.Addr) string {
+	prefix, _ := addr.Prefix(48)
+	return prefix.Addr().String()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestRedactingWriter(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		line     string
+		expected string
+	}{
+		{
+			name:     "IPv4 last octet",
+			mode:     logPrivacyIP,
+			line:     "Updated record rec1 (A) to 203.0.113.45",
+			expected: "Updated record rec1 (A) to 203.0.113.0",
+		},
+		{
+			name:     "IPv6 lower 80 bits",
+			mode:     logPrivacyIP,
+			line:     "update request: myipv6=2001:db8:1234:5678::1, offline=",
+			expected: "update request: myipv6=2001:db8:1234::, offline=",
+		},
+		{
+			name:     "time stamps and ports untouched",
+			mode:     logPrivacyIP,
+			line:     "12:00:05 listening on :8080",
+			expected: "12:00:05 listening on :8080",
+		},
+		{
+			name:     "hostnames kept in ip mode",
+			mode:     logPrivacyIP,
+			line:     "hostname=home.example.com, myip=1.2.3.4",
+			expected: "hostname=home.example.com, myip=1.2.3.0",
+		},
+		{
+			name:     "hostnames redacted in full mode",
+			mode:     logPrivacyFull,
+			line:     "Found zone: example.com for hostname: nas.home.example.com, wildcard *.home.example.com",
+			expected: "Found zone: example.com for hostname: ***.example.com, wildcard ***.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			logger := log.New(newRedactingWriter(&out, tt.mode), "", 0)
+			logger.Print(tt.line)
+
+			if got := out.String(); got != tt.expected+"\n" {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"text/template"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// ClientProfile bundles the quirks of a family of update clients
+type ClientProfile struct {
+	Name string
+	// UserAgents are case-insensitive User-Agent substrings selecting the profile
+	UserAgents []string
+	Params     parse.Names
+	// Responses are the profile's response formats, DYNDNS_RESPONSE_* templates take precedence
+	Responses ResponseTemplates
+	// FritzBoxDualStack honors the ip6lanprefix and dualstack parameters
+	FritzBoxDualStack bool
+}
+
+// profileCustom is the generic profile used when no other profile matches
+const profileCustom = "custom"
+
+// codeAndIP answers like most dyndns2 providers, e.g. "good 203.0.113.1"
+var codeAndIP = template.Must(template.New("code-ip").Parse(`{{.Code}}{{if .IP}} {{.IP}}{{end}}`))
+
+// clientProfiles are the built-in profiles
+var clientProfiles = map[string]*ClientProfile{
+	"fritzbox": {
+		Name:              "fritzbox",
+		UserAgents:        []string{"fritz!box", "avm"},
+		Params:            parse.DefaultNames,
+		FritzBoxDualStack: true,
+	},
+	"synology": {
+		Name:       "synology",
+		UserAgents: []string{"synology"},
+		Params:     parse.Names{Hostname: []string{"hostname"}, IPv4: []string{"myip"}, IPv6: []string{"myipv6"}},
+		// DSM only checks the return code and shows anything else as an error message
+		Responses: ResponseTemplates{
+			"good":  template.Must(template.New("good").Parse(`{{.Code}}`)),
+			"nochg": template.Must(template.New("nochg").Parse(`{{.Code}}`)),
+		},
+	},
+	"ddclient": {
+		Name:       "ddclient",
+		UserAgents: []string{"ddclient"},
+		Params:     parse.Names{Hostname: []string{"hostname"}, IPv4: []string{"myip"}, IPv6: []string{"myipv6"}},
+		Responses:  ResponseTemplates{"good": codeAndIP, "nochg": codeAndIP},
+	},
+	"inadyn": {
+		Name:       "inadyn",
+		UserAgents: []string{"inadyn"},
+		Params:     parse.Names{Hostname: []string{"hostname"}, IPv4: []string{"myip"}, IPv6: []string{"myipv6"}},
+		Responses:  ResponseTemplates{"good": codeAndIP, "nochg": codeAndIP},
+	},
+	profileCustom: {
+		Name:              profileCustom,
+		Params:            parse.DefaultNames,
+		FritzBoxDualStack: true,
+	},
+}
+
+// profileNames returns the names of the built-in profiles
+func profileNames() []string {
+	names := make([]string, 0, len(clientProfiles))
+	for name := range clientProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseProfileUsers parses "username=profile" pairs, separated by commas
+func parseProfileUsers(value string) (map[string]string, error) {
+	users := map[string]string{}
+	for _, item := range splitList(value) {
+		user, profile, ok := strings.Cut(item, "=")
+		user, profile = strings.TrimSpace(user), strings.TrimSpace(profile)
+		if !ok || user == "" {
+			return nil, fmt.Errorf("invalid profile user %q, expected username=profile", item)
+		}
+		if clientProfiles[profile] == nil {
+			return nil, fmt.Errorf("unknown client profile %q (expected one of %s)", profile, strings.Join(profileNames(), ", "))
+		}
+		users[user] = profile
+	}
+	return users, nil
+}
+
+// Modes for updates sending neither myip nor myipv6 without offline=yes
+const (
+	emptyIPReject = "reject" // answer with a 400 error, the default
+	emptyIPSource = "source" // update the address of the connection
+	emptyIPStrict = "strict" // answer badagent, which clients report instead of retrying
+)
+
+// parseEmptyIPModes parses "profile=mode" pairs separated by commas, a mode
+// without a profile applies to the profiles not listed
+func parseEmptyIPModes(value string) (map[string]string, error) {
+	modes := map[string]string{}
+	for _, item := range splitList(value) {
+		profile, mode, ok := strings.Cut(item, "=")
+		if !ok {
+			profile, mode = "", profile
+		}
+		profile, mode = strings.TrimSpace(profile), strings.TrimSpace(mode)
+		if ok && clientProfiles[profile] == nil {
+			return nil, fmt.Errorf("unknown client profile %q (expected one of %s)", profile, strings.Join(profileNames(), ", "))
+		}
+		switch mode {
+		case emptyIPReject, emptyIPSource, emptyIPStrict:
+		default:
+			return nil, fmt.Errorf("invalid mode %q, expected %s, %s or %s", mode, emptyIPReject, emptyIPSource, emptyIPStrict)
+		}
+		modes[profile] = mode
+	}
+	return modes, nil
+}
+
+// emptyIPMode returns how updates of profile without addresses are handled
+func (s *DynDNSServer) emptyIPMode(profile *ClientProfile) string {
+	if mode, ok := s.emptyIP[profile.Name]; ok {
+		return mode
+	}
+	if mode, ok := s.emptyIP[""]; ok {
+		return mode
+	}
+	return emptyIPReject
+}
+
+// connectionIPs returns the address of the client connection as IPv4 or IPv6 address
+func connectionIPs(r *http.Request) (ipv4, ipv6 string) {
+	ip := net.ParseIP(getClientIP(r))
+	if ip == nil {
+		return "", ""
+	}
+	if v4 := ip.To4(); v4 != nil {
+		return v4.String(), ""
+	}
+	return "", ip.String()
+}
+
+// usesConnectionIP reports whether updates of username fill in a missing address
+// of the connection's family from the connection, "*" enables it for everyone
+func (s *DynDNSServer) usesConnectionIP(username string) bool {
+	return slices.Contains(s.connectionIPUsers, username) || slices.Contains(s.connectionIPUsers, "*")
+}
+
+// selectProfile picks the profile of r by its username, then its User-Agent,
+// falling back to the custom profile
+func (s *DynDNSServer) selectProfile(r *http.Request) *ClientProfile {
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		user, _, _ = queryCredentials(r)
+	}
+	if name, ok := s.profileUsers[user]; ok {
+		return clientProfiles[name]
+	}
+
+	agent := strings.ToLower(r.UserAgent())
+	if agent != "" {
+		for _, name := range profileNames() {
+			for _, pattern := range clientProfiles[name].UserAgents {
+				if strings.Contains(agent, pattern) {
+					return clientProfiles[name]
+				}
+			}
+		}
+	}
+	return clientProfiles[profileCustom]
+}
+
+// respond renders the response for data using the configured templates, then the profile's formats
+func (s *DynDNSServer) respond(w http.ResponseWriter, profile *ClientProfile, data responseData) {
+	if s.responses[data.Code] != nil {
+		s.responses.render(w, data)
+		return
+	}
+	profile.Responses.render(w, data)
+}
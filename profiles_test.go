@@ -0,0 +1,272 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectProfile(t *testing.T) {
+	server := NewDynDNSServer(nil, "admin", "password", "8080")
+	server.profileUsers = map[string]string{"nas": "synology"}
+
+	tests := []struct {
+		name      string
+		userAgent string
+		username  string
+		expected  string
+	}{
+		{"fritzbox agent", "Fritz!Box DDNS/1.0.1", "admin", "fritzbox"},
+		{"ddclient agent", "ddclient/3.11.2", "admin", "ddclient"},
+		{"inadyn agent", "inadyn/2.12.0", "admin", "inadyn"},
+		{"username wins over agent", "ddclient/3.11.2", "nas", "synology"},
+		{"unknown agent", "curl/8.5.0", "admin", "custom"},
+		{"no agent", "", "admin", "custom"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/update", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.SetBasicAuth(tt.username, "password")
+			if profile := server.selectProfile(req); profile.Name != tt.expected {
+				t.Errorf("Expected profile %s, got %s", tt.expected, profile.Name)
+			}
+		})
+	}
+}
+
+func TestParseProfileUsers(t *testing.T) {
+	users, err := parseProfileUsers("nas=synology, router=fritzbox")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if users["nas"] != "synology" || users["router"] != "fritzbox" {
+		t.Errorf("Unexpected profile users %v", users)
+	}
+
+	for _, value := range []string{"nas", "=synology", "nas=unknown"} {
+		if _, err := parseProfileUsers(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestParseEmptyIPModes(t *testing.T) {
+	modes, err := parseEmptyIPModes("strict, fritzbox=source")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if modes[""] != emptyIPStrict || modes["fritzbox"] != emptyIPSource {
+		t.Errorf("Unexpected modes %v", modes)
+	}
+
+	for _, value := range []string{"detect", "unknown=source", "fritzbox="} {
+		if _, err := parseEmptyIPModes(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestHandleUpdateEmptyIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		modes          map[string]string
+		userAgent      string
+		remoteAddr     string
+		expected       string
+		expectedWrites []string
+	}{
+		{
+			name:     "rejected by default",
+			expected: "No valid IP address provided or detected\n",
+		},
+		{
+			name:           "IPv4 connection address",
+			modes:          map[string]string{"fritzbox": emptyIPSource},
+			userAgent:      "Fritz!Box DDNS/1.0.1",
+			remoteAddr:     "1.2.3.4:40000",
+			expected:       "good IPv4: 1.2.3.4",
+			expectedWrites: []string{"A home 1.2.3.4"},
+		},
+		{
+			name:           "IPv6 connection address",
+			modes:          map[string]string{"": emptyIPSource},
+			remoteAddr:     "[2a01:4f8::1]:40000",
+			expected:       "good IPv6: 2a01:4f8::1",
+			expectedWrites: []string{"AAAA home 2a01:4f8::1"},
+		},
+		{
+			name:           "IPv4-mapped connection address",
+			modes:          map[string]string{"": emptyIPSource},
+			remoteAddr:     "[::ffff:1.2.3.4]:40000",
+			expected:       "good IPv4: 1.2.3.4",
+			expectedWrites: []string{"A home 1.2.3.4"},
+		},
+		{
+			name:      "strict profile",
+			modes:     map[string]string{"": emptyIPSource, "ddclient": emptyIPStrict},
+			userAgent: "ddclient/3.11.2",
+			expected:  "badagent",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeAPIServer(t)
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.emptyIP = tt.modes
+
+			req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&myip=&myipv6=", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			if tt.remoteAddr != "" {
+				req.RemoteAddr = tt.remoteAddr
+			}
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if w.Body.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, w.Body.String())
+			}
+			var written []string
+			for _, record := range fake.Records() {
+				written = append(written, record.Type+" "+record.Name+" "+record.Value)
+			}
+			if !reflect.DeepEqual(written, tt.expectedWrites) {
+				t.Errorf("Expected records %v, got %v", tt.expectedWrites, written)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateConnectionIP(t *testing.T) {
+	tests := []struct {
+		name           string
+		users          []string
+		username       string
+		query          string
+		remoteAddr     string
+		expectedWrites []string
+	}{
+		{
+			name:           "IPv6 connection adds the AAAA record",
+			users:          []string{"router"},
+			username:       "router",
+			query:          "myip=1.2.3.4",
+			remoteAddr:     "[2a01:4f8::1]:40000",
+			expectedWrites: []string{"A home 1.2.3.4", "AAAA home 2a01:4f8::1"},
+		},
+		{
+			name:           "IPv4 connection adds the A record",
+			users:          []string{"*"},
+			username:       "admin",
+			query:          "myipv6=2a01:4f8::1",
+			remoteAddr:     "1.2.3.4:40000",
+			expectedWrites: []string{"A home 1.2.3.4", "AAAA home 2a01:4f8::1"},
+		},
+		{
+			name:           "sent addresses win",
+			users:          []string{"router"},
+			username:       "router",
+			query:          "myipv6=2a01:4f8::2",
+			remoteAddr:     "[2a01:4f8::1]:40000",
+			expectedWrites: []string{"AAAA home 2a01:4f8::2"},
+		},
+		{
+			name:           "other credentials",
+			users:          []string{"router"},
+			username:       "admin",
+			query:          "myip=1.2.3.4",
+			remoteAddr:     "[2a01:4f8::1]:40000",
+			expectedWrites: []string{"A home 1.2.3.4"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeAPIServer(t)
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.profileUsers = map[string]string{"router": "custom"}
+			server.connectionIPUsers = tt.users
+
+			req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&"+tt.query, nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.SetBasicAuth(tt.username, "password")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if !strings.HasPrefix(w.Body.String(), "good") {
+				t.Fatalf("Unexpected response %q", w.Body.String())
+			}
+			var written []string
+			for _, record := range fake.Records() {
+				written = append(written, record.Type+" "+record.Name+" "+record.Value)
+			}
+			if !reflect.DeepEqual(written, tt.expectedWrites) {
+				t.Errorf("Expected records %v, got %v", tt.expectedWrites, written)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateProfiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		username  string
+		query     string
+		expected  string
+	}{
+		{
+			name:      "ddclient reports the address",
+			userAgent: "ddclient/3.11.2",
+			username:  "admin",
+			query:     "hostname=home.example.com&myip=1.2.3.4",
+			expected:  "good 1.2.3.4",
+		},
+		{
+			name:     "synology answers with the code only",
+			username: "nas",
+			query:    "hostname=home.example.com&myip=1.2.3.4",
+			expected: "good",
+		},
+		{
+			name:     "synology ignores vendor parameter names",
+			username: "nas",
+			query:    "hostname=home.example.com&ip=1.2.3.4",
+			expected: "No valid IP address provided or detected\n",
+		},
+		{
+			name:      "fritzbox keeps the detailed response",
+			userAgent: "Fritz!Box DDNS/1.0.1",
+			username:  "admin",
+			query:     "hostname=home.example.com&myip=1.2.3.4",
+			expected:  "good IPv4: 1.2.3.4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, nil, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.profileUsers = map[string]string{"nas": "synology"}
+
+			req := httptest.NewRequest("GET", "/update?"+tt.query, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.SetBasicAuth(tt.username, "password")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if w.Body.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Protocol Buffers wire types used by the gRPC messages
+const (
+	protoVarint = 0
+	protoBytes  = 2
+)
+
+// errInvalidProto is returned for messages that are not valid Protocol Buffers
+var errInvalidProto = fmt.Errorf("invalid protobuf message")
+
+// protoMessage encodes a Protocol Buffers message field by field
+type protoMessage []byte
+
+// String appends a string field, empty strings are omitted like in proto3
+func (m protoMessage) String(field int, value string) protoMessage {
+	if value == "" {
+		return m
+	}
+	return m.Bytes(field, []byte(value))
+}
+
+// Bytes appends a length-delimited field, e.g. an embedded message
+func (m protoMessage) Bytes(field int, value []byte) protoMessage {
+	m = binary.AppendUvarint(m, uint64(field<<3|protoBytes))
+	m = binary.AppendUvarint(m, uint64(len(value)))
+	return append(m, value...)
+}
+
+// Int appends an int64 field, zero is omitted like in proto3
+func (m protoMessage) Int(field int, value int64) protoMessage {
+	if value == 0 {
+		return m
+	}
+	m = binary.AppendUvarint(m, uint64(field<<3|protoVarint))
+	return binary.AppendUvarint(m, uint64(value))
+}
+
+// protoField is a decoded field, Data is set for length-delimited and Value for varint fields
+type protoField struct {
+	Number int
+	Value  uint64
+	Data   []byte
+}
+
+// parseProto decodes the fields of a message, skipping fixed-size fields
+func parseProto(msg []byte) ([]protoField, error) {
+	var fields []protoField
+	for len(msg) > 0 {
+		key, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return nil, errInvalidProto
+		}
+		msg = msg[n:]
+		field := protoField{Number: int(key >> 3)}
+
+		switch key & 7 {
+		case protoVarint:
+			if field.Value, n = binary.Uvarint(msg); n <= 0 {
+				return nil, errInvalidProto
+			}
+			msg = msg[n:]
+		case protoBytes:
+			length, n := binary.Uvarint(msg)
+			if n <= 0 || uint64(len(msg)-n) < length {
+				return nil, errInvalidProto
+			}
+			field.Data = msg[n : n+int(length)]
+			msg = msg[n+int(length):]
+		case 1, 5:
+			// fixed64 and fixed32 are not used by the API
+			size := 8
+			if key&7 == 5 {
+				size = 4
+			}
+			if len(msg) < size {
+				return nil, errInvalidProto
+			}
+			msg = msg[size:]
+			continue
+		default:
+			return nil, errInvalidProto
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
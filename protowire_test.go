@@ -0,0 +1,47 @@
+package main
+
+import "testing"
+
+func TestProtoRoundTrip(t *testing.T) {
+	embedded := protoMessage(nil).String(1, "inner")
+	msg := protoMessage(nil).String(1, "home.example.com").String(2, "").Int(3, 300).Bytes(4, embedded)
+
+	fields, err := parseProto(msg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Empty strings are omitted like in proto3
+	if len(fields) != 3 {
+		t.Fatalf("Expected 3 fields, got %+v", fields)
+	}
+	if fields[0].Number != 1 || string(fields[0].Data) != "home.example.com" {
+		t.Errorf("Unexpected string field %+v", fields[0])
+	}
+	if fields[1].Number != 3 || fields[1].Value != 300 {
+		t.Errorf("Unexpected varint field %+v", fields[1])
+	}
+	inner, err := parseProto(fields[2].Data)
+	if err != nil || len(inner) != 1 || string(inner[0].Data) != "inner" {
+		t.Errorf("Unexpected embedded message %+v (%v)", inner, err)
+	}
+}
+
+func TestParseProtoInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  []byte
+	}{
+		{name: "truncated key", msg: []byte{0x80}},
+		{name: "length beyond message", msg: []byte{0x0a, 0x05, 'a'}},
+		{name: "truncated fixed32", msg: []byte{0x0d, 0x01}},
+		{name: "group wire type", msg: []byte{0x0b}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseProto(tt.msg); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
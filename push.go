@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// gotifyPriorities map event severities to Gotify message priorities
+var gotifyPriorities = map[string]int{severityInfo: 4, severityWarning: 8}
+
+// gotifyNotifier pushes events to a self-hosted Gotify server
+type gotifyNotifier struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// Notify implements Notifier
+func (n gotifyNotifier) Notify(event Event) error {
+	headers := map[string]string{"X-Gotify-Key": n.token}
+	return postJSONWithHeaders(n.client, strings.TrimSuffix(n.url, "/")+"/message", headers, map[string]interface{}{
+		"title":    event.Title(),
+		"message":  event.Message,
+		"priority": gotifyPriorities[event.Severity],
+	})
+}
+
+// pushoverAPIURL is the Pushover message endpoint
+const pushoverAPIURL = "https://api.pushover.net/1/messages.json"
+
+// pushoverNotifier sends events through Pushover, warnings with high priority
+type pushoverNotifier struct {
+	url    string
+	token  string
+	user   string
+	client *http.Client
+}
+
+// Notify implements Notifier
+func (n pushoverNotifier) Notify(event Event) error {
+	priority := "0"
+	if event.Severity == severityWarning {
+		priority = "1"
+	}
+	resp, err := n.client.PostForm(n.url, url.Values{
+		"token":    {n.token},
+		"user":     {n.user},
+		"title":    {event.Title()},
+		"message":  {event.Message},
+		"priority": {priority},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkDeliveryStatus(resp)
+}
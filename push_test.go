@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGotifyNotifier(t *testing.T) {
+	var key string
+	var message map[string]interface{}
+	gotify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/message" {
+			t.Errorf("Unexpected path %s", r.URL.Path)
+		}
+		key = r.Header.Get("X-Gotify-Key")
+		json.NewDecoder(r.Body).Decode(&message)
+	}))
+	defer gotify.Close()
+
+	notifier := gotifyNotifier{url: gotify.URL + "/", token: "app-token", client: http.DefaultClient}
+	if err := notifier.Notify(Event{Kind: eventUpdateFailed, Severity: severityWarning, Hostname: "home.example.com", Message: "failed"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if key != "app-token" || message["message"] != "failed" || message["priority"] != float64(8) || message["title"] != "DynDNS update failed: home.example.com" {
+		t.Errorf("Unexpected Gotify message %v (key %q)", message, key)
+	}
+}
+
+func TestPushoverNotifier(t *testing.T) {
+	var form map[string]string
+	pushover := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form = map[string]string{}
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+	}))
+	defer pushover.Close()
+
+	notifier := pushoverNotifier{url: pushover.URL, token: "app", user: "me", client: http.DefaultClient}
+	if err := notifier.Notify(Event{Kind: eventIPChange, Severity: severityInfo, Hostname: "home.example.com", Message: "changed"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if form["token"] != "app" || form["user"] != "me" || form["message"] != "changed" || form["priority"] != "0" {
+		t.Errorf("Unexpected Pushover form %v", form)
+	}
+}
+
+func TestNotifierRuleMatches(t *testing.T) {
+	route := notifierRoute{rule: notifierRule{Hostnames: []string{"home.example.com", "*.lab.example.com"}, MinSeverity: severityWarning}}
+
+	tests := []struct {
+		name     string
+		event    Event
+		expected bool
+	}{
+		{"matching host and severity", Event{Kind: eventUpdateFailed, Severity: severityWarning, Hostname: "home.example.com"}, true},
+		{"glob host", Event{Kind: eventUpdateFailed, Severity: severityWarning, Hostname: "nas.lab.example.com"}, true},
+		{"other host", Event{Kind: eventUpdateFailed, Severity: severityWarning, Hostname: "office.example.com"}, false},
+		{"below severity", Event{Kind: eventIPChange, Severity: severityInfo, Hostname: "home.example.com"}, false},
+		{"alert without host", Event{Kind: eventAlert, Severity: severityWarning}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := route.matches(tt.event); result != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// qrMaxVersion is the largest supported QR version, enough for about 270 bytes
+const qrMaxVersion = 10
+
+// Error correction level L of QR versions 1 to 10: EC codewords per block,
+// number of blocks and total codewords of the symbol
+var (
+	qrECCodewords  = [qrMaxVersion + 1]int{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18}
+	qrBlocks       = [qrMaxVersion + 1]int{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4}
+	qrRawCodewords = [qrMaxVersion + 1]int{0, 26, 44, 70, 100, 134, 172, 196, 242, 292, 346}
+	// qrAlignment are the center coordinates of the alignment patterns
+	qrAlignment = [qrMaxVersion + 1][]int{
+		nil, nil, {6, 18}, {6, 22}, {6, 26}, {6, 30}, {6, 34}, {6, 22, 38}, {6, 24, 42}, {6, 26, 46}, {6, 28, 50},
+	}
+)
+
+// errQRTooLong is returned for data exceeding the capacity of the largest supported version
+var errQRTooLong = errors.New("data too long for a QR code")
+
+// qrCode is a QR code symbol encoding bytes with error correction level L
+type qrCode struct {
+	version  int
+	size     int
+	modules  [][]bool // true is dark, indexed [y][x]
+	function [][]bool // finder, timing, alignment and format modules excluded from masking
+}
+
+// encodeQR encodes data in byte mode using the smallest version it fits in
+func encodeQR(data []byte) (*qrCode, error) {
+	version := 1
+	for ; version <= qrMaxVersion; version++ {
+		if len(data)*8 <= qrDataCodewords(version)*8-4-qrCountBits(version) {
+			break
+		}
+	}
+	if version > qrMaxVersion {
+		return nil, errQRTooLong
+	}
+
+	size := 4*version + 17
+	q := &qrCode{version: version, size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for y := range size {
+		q.modules[y] = make([]bool, size)
+		q.function[y] = make([]bool, size)
+	}
+	q.drawFunctionPatterns()
+	q.drawCodewords(q.codewords(data))
+
+	// Keep the mask with the lowest penalty, as readers expect
+	best, bestPenalty := 0, -1
+	for mask := range 8 {
+		q.applyMask(mask)
+		q.drawFormatBits(mask)
+		if penalty := q.penalty(); bestPenalty < 0 || penalty < bestPenalty {
+			best, bestPenalty = mask, penalty
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormatBits(best)
+	return q, nil
+}
+
+// qrDataCodewords returns the number of data codewords of version
+func qrDataCodewords(version int) int {
+	return qrRawCodewords[version] - qrECCodewords[version]*qrBlocks[version]
+}
+
+// qrCountBits returns the length of the byte mode character count of version
+func qrCountBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+	return 16
+}
+
+// codewords returns the data and error correction codewords in their interleaved order
+func (q *qrCode) codewords(data []byte) []byte {
+	var bits []bool
+	appendBits := func(value, length int) {
+		for i := length - 1; i >= 0; i-- {
+			bits = append(bits, value>>i&1 == 1)
+		}
+	}
+	appendBits(0b0100, 4)
+	appendBits(len(data), qrCountBits(q.version))
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+
+	capacity := qrDataCodewords(q.version) * 8
+	appendBits(0, min(4, capacity-len(bits)))
+	appendBits(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		appendBits(pad, 8)
+	}
+	payload := make([]byte, len(bits)/8)
+	for i, bit := range bits {
+		if bit {
+			payload[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	// Split into blocks, the last blocks are one codeword longer if the data does not divide evenly
+	numBlocks, ec := qrBlocks[q.version], qrECCodewords[q.version]
+	shortLen := len(payload) / numBlocks
+	numShort := numBlocks - len(payload)%numBlocks
+	var blocks, ecBlocks [][]byte
+	for i, offset := 0, 0; i < numBlocks; i++ {
+		length := shortLen
+		if i >= numShort {
+			length++
+		}
+		block := payload[offset : offset+length]
+		offset += length
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, reedSolomon(block, ec))
+	}
+
+	var result []byte
+	for i := 0; i <= shortLen; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := range ec {
+		for _, block := range ecBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// reedSolomon returns the n error correction codewords of data over GF(256)
+func reedSolomon(data []byte, n int) []byte {
+	// Generator polynomial (x - a^0)(x - a^1)...(x - a^(n-1)), leading coefficient omitted
+	generator := make([]byte, n)
+	generator[n-1] = 1
+	root := byte(1)
+	for range n {
+		for j := range n {
+			generator[j] = gfMultiply(generator[j], root)
+			if j+1 < n {
+				generator[j] ^= generator[j+1]
+			}
+		}
+		root = gfMultiply(root, 2)
+	}
+
+	remainder := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ remainder[0]
+		copy(remainder, remainder[1:])
+		remainder[n-1] = 0
+		for i := range remainder {
+			remainder[i] ^= gfMultiply(generator[i], factor)
+		}
+	}
+	return remainder
+}
+
+// gfMultiply multiplies in GF(256) with the QR code polynomial x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// set draws a function module
+func (q *qrCode) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns and reserves the format areas
+func (q *qrCode) drawFunctionPatterns() {
+	for i := range q.size {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(q.size-4, 3)
+	q.drawFinder(3, q.size-4)
+
+	positions := qrAlignment[q.version]
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			// Alignment patterns never overlap the finder patterns
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	q.drawFormatBits(0)
+	q.drawVersion()
+}
+
+// drawFinder draws a finder pattern with its separator centered on x, y
+func (q *qrCode) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			if px, py := x+dx, y+dy; px >= 0 && px < q.size && py >= 0 && py < q.size {
+				dist := max(abs(dx), abs(dy))
+				q.set(px, py, dist != 2 && dist != 4)
+			}
+		}
+	}
+}
+
+// drawFormatBits draws both copies of the error correction level and mask
+func (q *qrCode) drawFormatBits(mask int) {
+	data := 0b01<<3 | mask // level L
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := range 8 {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true) // dark module
+}
+
+// drawVersion draws both copies of the version information of versions 7 and up
+func (q *qrCode) drawVersion() {
+	if q.version < 7 {
+		return
+	}
+	rem := q.version
+	for range 12 {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := q.version<<12 | rem
+	for i := range 18 {
+		dark := bits>>i&1 == 1
+		a, b := q.size-11+i%3, i/3
+		q.set(a, b, dark)
+		q.set(b, a, dark)
+	}
+}
+
+// drawCodewords places the codewords in the zigzag order over the non-function modules
+func (q *qrCode) drawCodewords(codewords []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := range q.size {
+			for j := range 2 {
+				x, y := right-j, vert
+				if upward {
+					y = q.size - 1 - vert
+				}
+				if !q.function[y][x] && i < len(codewords)*8 {
+					q.modules[y][x] = codewords[i/8]>>(7-i%8)&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the non-function modules selected by mask, applying it twice undoes it
+func (q *qrCode) applyMask(mask int) {
+	for y := range q.size {
+		for x := range q.size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the symbol is to read: long runs, 2x2 blocks,
+// finder-like patterns and an unbalanced share of dark modules
+func (q *qrCode) penalty() int {
+	penalty := 0
+	line := make([]bool, q.size)
+	for _, vertical := range []bool{false, true} {
+		for a := range q.size {
+			for b := range q.size {
+				if vertical {
+					line[b] = q.modules[b][a]
+				} else {
+					line[b] = q.modules[a][b]
+				}
+			}
+			run := 1
+			for b := 1; b <= q.size; b++ {
+				if b < q.size && line[b] == line[b-1] {
+					run++
+					continue
+				}
+				if run >= 5 {
+					penalty += run - 2
+				}
+				run = 1
+			}
+			for b := 0; b+7 <= q.size; b++ {
+				if line[b] && !line[b+1] && line[b+2] && line[b+3] && line[b+4] && !line[b+5] && line[b+6] &&
+					(q.lightRun(line, b-4, b) || q.lightRun(line, b+7, b+11)) {
+					penalty += 40
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := range q.size {
+		for x := range q.size {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x+1 < q.size && y+1 < q.size {
+				c := q.modules[y][x]
+				if c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+					penalty += 3
+				}
+			}
+		}
+	}
+	total := q.size * q.size
+	penalty += (abs(dark*20-total*10)+total-1)/total*10 - 10
+	return penalty
+}
+
+// lightRun reports whether line is light from start to end, modules outside the symbol count as light
+func (q *qrCode) lightRun(line []bool, start, end int) bool {
+	for i := start; i < end; i++ {
+		if i >= 0 && i < len(line) && line[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// WritePNG renders the symbol with a quiet zone of four modules, scale pixels per module
+func (q *qrCode) WritePNG(w io.Writer, scale int) error {
+	const quiet = 4
+	pixels := (q.size + 2*quiet) * scale
+	img := image.NewPaletted(image.Rect(0, 0, pixels, pixels), color.Palette{color.White, color.Black})
+	for y := range q.size {
+		for x := range q.size {
+			if !q.modules[y][x] {
+				continue
+			}
+			for py := range scale {
+				for px := range scale {
+					img.SetColorIndex((x+quiet)*scale+px, (y+quiet)*scale+py, 1)
+				}
+			}
+		}
+	}
+	return png.Encode(w, img)
+}
+
+// abs returns the absolute value of x
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
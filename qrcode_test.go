@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// HELLO WORLD as version 1-M, the worked example of the QR code specification
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	expected := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomon(data, 10); !bytes.Equal(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestEncodeQR(t *testing.T) {
+	tests := []struct {
+		name            string
+		length          int
+		expectedVersion int
+		expectError     bool
+	}{
+		{name: "empty", length: 0, expectedVersion: 1},
+		{name: "version 1 capacity", length: 17, expectedVersion: 1},
+		{name: "version 2", length: 18, expectedVersion: 2},
+		{name: "version 7 with version information", length: 150, expectedVersion: 7},
+		{name: "version 10 with 16 bit count", length: 271, expectedVersion: 10},
+		{name: "too long", length: 272, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := []byte(strings.Repeat("fritz!", 50)[:tt.length])
+			code, err := encodeQR(data)
+			if tt.expectError {
+				if err == nil {
+					t.Fatal("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if code.version != tt.expectedVersion || code.size != 4*tt.expectedVersion+17 {
+				t.Fatalf("Expected version %d, got %d with size %d", tt.expectedVersion, code.version, code.size)
+			}
+
+			for _, corner := range [][2]int{{0, 0}, {code.size - 7, 0}, {0, code.size - 7}} {
+				for _, offset := range [][2]int{{0, 0}, {6, 6}, {3, 3}} {
+					if !code.modules[corner[1]+offset[1]][corner[0]+offset[0]] {
+						t.Errorf("Expected dark finder module at %d,%d", corner[0]+offset[0], corner[1]+offset[1])
+					}
+				}
+				if code.modules[corner[1]+1][corner[0]+1] {
+					t.Errorf("Expected light finder ring at %d,%d", corner[0]+1, corner[1]+1)
+				}
+			}
+
+			// Read the symbol back: format bits, mask and codewords must match the encoded data
+			mask := decodeFormatMask(t, code)
+			code.applyMask(mask)
+			got := readCodewords(code)
+			if len(got) != qrRawCodewords[code.version] {
+				t.Fatalf("Expected room for %d codewords, got %d", qrRawCodewords[code.version], len(got))
+			}
+			if !bytes.Equal(got, code.codewords(data)) {
+				t.Error("Codewords read back from the symbol differ from the encoded ones")
+			}
+		})
+	}
+}
+
+func TestQRFormatAndVersionBits(t *testing.T) {
+	code, err := encodeQR(make([]byte, 150))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Level L with mask 0 is 111011111000100 after masking
+	code.drawFormatBits(0)
+	if bits := readFormatBits(code); bits != 0b111011111000100 {
+		t.Errorf("Expected format bits 111011111000100, got %015b", bits)
+	}
+
+	// Version 7 is 000111110010010100
+	var version int
+	for i := 17; i >= 0; i-- {
+		version = version<<1 | b2i(code.modules[i/3][code.size-11+i%3])
+	}
+	if version != 0x07C94 {
+		t.Errorf("Expected version bits %018b, got %018b", 0x07C94, version)
+	}
+}
+
+func TestQRWritePNG(t *testing.T) {
+	code, err := encodeQR([]byte("https://dyndns.example.com"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := code.WritePNG(&buf, 4); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("Invalid PNG: %v", err)
+	}
+	if size := (code.size + 8) * 4; img.Bounds().Dx() != size || img.Bounds().Dy() != size {
+		t.Errorf("Expected %dx%d pixels, got %v", size, size, img.Bounds())
+	}
+	// The quiet zone is light, the top left finder corner dark
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Error("Expected light quiet zone")
+	}
+	if r, _, _, _ := img.At(16, 16).RGBA(); r != 0 {
+		t.Error("Expected dark finder module")
+	}
+}
+
+// decodeFormatMask reads the mask from the first copy of the format bits
+func decodeFormatMask(t *testing.T, code *qrCode) int {
+	t.Helper()
+	bits := readFormatBits(code) ^ 0x5412
+	if level := bits >> 13; level != 0b01 {
+		t.Fatalf("Expected error correction level L, got %02b", level)
+	}
+	return bits >> 10 & 7
+}
+
+// readFormatBits reads the first copy of the format bits
+func readFormatBits(code *qrCode) int {
+	var bits int
+	for i := 14; i >= 9; i-- {
+		bits = bits<<1 | b2i(code.modules[8][14-i])
+	}
+	bits = bits<<1 | b2i(code.modules[8][7])
+	bits = bits<<1 | b2i(code.modules[8][8])
+	bits = bits<<1 | b2i(code.modules[7][8])
+	for i := 5; i >= 0; i-- {
+		bits = bits<<1 | b2i(code.modules[i][8])
+	}
+	return bits
+}
+
+// readCodewords reads the codewords of an unmasked symbol in placement order
+func readCodewords(code *qrCode) []byte {
+	var result []byte
+	var current byte
+	n := 0
+	for right := code.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := range code.size {
+			for j := range 2 {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = code.size - 1 - vert
+				}
+				if code.function[y][x] {
+					continue
+				}
+				current = current<<1 | byte(b2i(code.modules[y][x]))
+				if n++; n%8 == 0 {
+					result = append(result, current)
+					current = 0
+				}
+			}
+		}
+	}
+	return result
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// rateDecision describes how a rate limited write should be handled
+type rateDecision int
+
+const (
+	// rateAllow means the write may be performed immediately
+	rateAllow rateDecision = iota
+	// rateNoChange means the write repeats the current value and can be skipped
+	rateNoChange
+	// rateQueued means the write was deferred until the interval has passed
+	rateQueued
+)
+
+// RateLimiter enforces a minimum interval between writes per key (hostname and record type)
+type RateLimiter struct {
+	interval time.Duration
+	clock    Clock
+	mu       sync.Mutex
+	entries  map[string]*rateEntry
+}
+
+// rateEntry tracks the last write and any pending write for a single key
+type rateEntry struct {
+	lastWrite    time.Time
+	lastValue    string
+	pendingValue string
+	pending      Timer
+	write        func(value string)
+}
+
+// NewRateLimiter creates a rate limiter allowing one write per interval and key
+func NewRateLimiter(interval time.Duration) *RateLimiter {
+	return &RateLimiter{
+		interval: interval,
+		clock:    systemClock{},
+		entries:  make(map[string]*rateEntry),
+	}
+}
+
+// Reserve decides whether a write of value for key may happen now. Writes
+// repeating the last value within the interval are reported as no change,
+// differing writes are queued and performed through write once the interval
+// has passed. Only the most recent queued value is written.
+func (l *RateLimiter) Reserve(key, value string, write func(value string)) rateDecision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	entry, ok := l.entries[key]
+	if !ok {
+		entry = &rateEntry{}
+		l.entries[key] = entry
+	}
+
+	if entry.lastWrite.IsZero() || now.Sub(entry.lastWrite) >= l.interval {
+		entry.stopPending()
+		entry.lastWrite = now
+		entry.lastValue = value
+		return rateAllow
+	}
+
+	if value == entry.lastValue {
+		// A newer request reverted to the current value, drop anything queued
+		entry.stopPending()
+		return rateNoChange
+	}
+
+	entry.pendingValue = value
+	entry.write = write
+	if entry.pending == nil {
+		delay := entry.lastWrite.Add(l.interval).Sub(now)
+		entry.pending = l.clock.AfterFunc(delay, func() { l.flush(key) })
+	}
+	return rateQueued
+}
+
+// Forget clears the state for key, e.g. after a failed write so the next request is not throttled
+func (l *RateLimiter) Forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry, ok := l.entries[key]; ok {
+		entry.stopPending()
+		delete(l.entries, key)
+	}
+}
+
+// Pending returns the number of queued writes
+func (l *RateLimiter) Pending() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	pending := 0
+	for _, entry := range l.entries {
+		if entry.pending != nil {
+			pending++
+		}
+	}
+	return pending
+}
+
+// flush performs the queued write for key
+func (l *RateLimiter) flush(key string) {
+	l.mu.Lock()
+	entry, ok := l.entries[key]
+	if !ok || entry.pending == nil {
+		l.mu.Unlock()
+		return
+	}
+	value := entry.pendingValue
+	write := entry.write
+	entry.pending = nil
+	entry.pendingValue = ""
+	entry.write = nil
+	entry.lastWrite = l.clock.Now()
+	entry.lastValue = value
+	l.mu.Unlock()
+
+	write(value)
+}
+
+// stopPending cancels a queued write
+func (e *rateEntry) stopPending() {
+	if e.pending != nil {
+		e.pending.Stop()
+		e.pending = nil
+	}
+	e.pendingValue = ""
+	e.write = nil
+}
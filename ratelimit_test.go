@@ -0,0 +1,62 @@
+package main
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterReserve(t *testing.T) {
+	limiter := NewRateLimiter(time.Hour)
+	noWrite := func(value string) { t.Errorf("Unexpected queued write of %s", value) }
+
+	if d := limiter.Reserve("test.example.com/A", "1.2.3.4", noWrite); d != rateAllow {
+		t.Errorf("Expected first write to be allowed, got %v", d)
+	}
+	if d := limiter.Reserve("test.example.com/A", "1.2.3.4", noWrite); d != rateNoChange {
+		t.Errorf("Expected identical write to be no change, got %v", d)
+	}
+	if d := limiter.Reserve("test.example.com/AAAA", "2001:db8::1", noWrite); d != rateAllow {
+		t.Errorf("Expected write for different key to be allowed, got %v", d)
+	}
+	if d := limiter.Reserve("test.example.com/A", "1.2.3.5", noWrite); d != rateQueued {
+		t.Errorf("Expected differing write to be queued, got %v", d)
+	}
+	// Reverting to the current value cancels the queued write
+	if d := limiter.Reserve("test.example.com/A", "1.2.3.4", noWrite); d != rateNoChange {
+		t.Errorf("Expected revert to current value to be no change, got %v", d)
+	}
+
+	limiter.Forget("test.example.com/A")
+	if d := limiter.Reserve("test.example.com/A", "1.2.3.4", noWrite); d != rateAllow {
+		t.Errorf("Expected write after Forget to be allowed, got %v", d)
+	}
+}
+
+func TestRateLimiterQueuedWrite(t *testing.T) {
+	limiter := NewRateLimiter(50 * time.Millisecond)
+
+	var mu sync.Mutex
+	var written []string
+	write := func(value string) {
+		mu.Lock()
+		defer mu.Unlock()
+		written = append(written, value)
+	}
+
+	limiter.Reserve("host/A", "1.1.1.1", write)
+	if d := limiter.Reserve("host/A", "2.2.2.2", write); d != rateQueued {
+		t.Fatalf("Expected write to be queued, got %v", d)
+	}
+	if d := limiter.Reserve("host/A", "3.3.3.3", write); d != rateQueued {
+		t.Fatalf("Expected write to be queued, got %v", d)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(written) != 1 || written[0] != "3.3.3.3" {
+		t.Errorf("Expected only the latest queued value to be written, got %v", written)
+	}
+}
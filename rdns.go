@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RobotBaseURL is the base URL of the Hetzner Robot webservice
+const RobotBaseURL = "https://robot-ws.your-server.de"
+
+// Reverse DNS providers
+const (
+	rdnsCloud = "cloud"
+	rdnsRobot = "robot"
+)
+
+// ReverseDNSTarget keeps the PTR record of hostname's address in sync with its forward record
+type ReverseDNSTarget struct {
+	Hostname string
+	// Provider is "cloud" for a Hetzner Cloud server identified by ServerID or "robot" for a dedicated server
+	Provider string
+	ServerID string
+}
+
+// ReverseDNSConfig configures the reverse DNS updates
+type ReverseDNSConfig struct {
+	Targets       []ReverseDNSTarget
+	CloudToken    string
+	RobotUser     string
+	RobotPassword string
+}
+
+// ReverseDNSUpdater sets the PTR record of a new address to the hostname pointing to it
+type ReverseDNSUpdater struct {
+	cfg          ReverseDNSConfig
+	cloud        *hcloudAPI
+	robotBaseURL string
+	client       *http.Client
+	wg           sync.WaitGroup
+}
+
+// NewReverseDNSUpdater creates an updater for the configured targets
+func NewReverseDNSUpdater(cfg ReverseDNSConfig) *ReverseDNSUpdater {
+	return &ReverseDNSUpdater{
+		cfg:          cfg,
+		cloud:        newHCloudAPI(cfg.CloudToken),
+		robotBaseURL: RobotBaseURL,
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Handle updates the PTR record in the background if the changed hostname is a target
+func (u *ReverseDNSUpdater) Handle(event Event) {
+	for _, target := range u.cfg.Targets {
+		if target.Hostname != event.Hostname {
+			continue
+		}
+		u.wg.Add(1)
+		go func(target ReverseDNSTarget) {
+			defer u.wg.Done()
+			if err := u.Update(target, event.NewValue); err != nil {
+				log.Printf("Failed to update reverse DNS of %s for %s: %v", event.NewValue, target.Hostname, err)
+				return
+			}
+			log.Printf("Updated reverse DNS of %s to %s", event.NewValue, target.Hostname)
+		}(target)
+	}
+}
+
+// Wait blocks until all started updates have finished
+func (u *ReverseDNSUpdater) Wait() {
+	u.wg.Wait()
+}
+
+// Update points the PTR record of ip to the target's hostname
+func (u *ReverseDNSUpdater) Update(target ReverseDNSTarget, ip string) error {
+	switch target.Provider {
+	case rdnsCloud:
+		body := map[string]string{"ip": ip, "dns_ptr": target.Hostname}
+		return u.cloud.do("POST", "/servers/"+url.PathEscape(target.ServerID)+"/actions/change_dns_ptr", body, nil)
+	case rdnsRobot:
+		return u.updateRobot(target, ip)
+	default:
+		return fmt.Errorf("unknown reverse DNS provider %q", target.Provider)
+	}
+}
+
+// updateRobot sets the PTR record of ip through the Robot webservice, which only accepts form posts
+func (u *ReverseDNSUpdater) updateRobot(target ReverseDNSTarget, ip string) error {
+	form := url.Values{"ptr": {target.Hostname}}
+	req, err := http.NewRequest("POST", u.robotBaseURL+"/rdns/"+url.PathEscape(ip), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(u.cfg.RobotUser, u.cfg.RobotPassword)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkDeliveryStatus(resp)
+}
+
+// parseReverseDNSTargets parses "hostname=cloud:serverid" and "hostname=robot" entries separated by commas
+func parseReverseDNSTargets(value string) ([]ReverseDNSTarget, error) {
+	var targets []ReverseDNSTarget
+	for _, entry := range splitList(value) {
+		hostname, spec, ok := strings.Cut(entry, "=")
+		if !ok || hostname == "" {
+			return nil, fmt.Errorf("expected hostname=cloud:serverid or hostname=robot, got %q", entry)
+		}
+		target := ReverseDNSTarget{Hostname: strings.ToLower(hostname)}
+		target.Provider, target.ServerID, _ = strings.Cut(spec, ":")
+
+		switch {
+		case target.Provider == rdnsCloud && target.ServerID != "":
+		case target.Provider == rdnsRobot && target.ServerID == "":
+		default:
+			return nil, fmt.Errorf("expected hostname=cloud:serverid or hostname=robot, got %q", entry)
+		}
+		targets = append(targets, target)
+	}
+	return targets, nil
+}
+
+// Validate checks that the credentials of the used providers are set
+func (c ReverseDNSConfig) Validate() error {
+	for _, target := range c.Targets {
+		if target.Provider == rdnsCloud && c.CloudToken == "" {
+			return fmt.Errorf("reverse DNS of %s requires DYNDNS_HCLOUD_TOKEN", target.Hostname)
+		}
+		if target.Provider == rdnsRobot && (c.RobotUser == "" || c.RobotPassword == "") {
+			return fmt.Errorf("reverse DNS of %s requires DYNDNS_ROBOT_USER and DYNDNS_ROBOT_PASSWORD", target.Hostname)
+		}
+	}
+	return nil
+}
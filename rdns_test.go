@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReverseDNSUpdater(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/cloud/"):
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			if r.Header.Get("Authorization") != "Bearer cloud-token" {
+				t.Errorf("Missing cloud token")
+			}
+			requests = append(requests, r.URL.Path+" "+body["ip"]+" "+body["dns_ptr"])
+		case strings.HasPrefix(r.URL.Path, "/robot/"):
+			r.ParseForm()
+			if user, password, _ := r.BasicAuth(); user != "robot-user" || password != "robot-pass" {
+				t.Errorf("Missing robot credentials")
+			}
+			requests = append(requests, r.URL.Path+" "+r.PostForm.Get("ptr"))
+		}
+	}))
+	defer api.Close()
+
+	targets, err := parseReverseDNSTargets("vpn.example.com=cloud:4711,mail.example.com=robot")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	updater := NewReverseDNSUpdater(ReverseDNSConfig{Targets: targets, CloudToken: "cloud-token", RobotUser: "robot-user", RobotPassword: "robot-pass"})
+	updater.cloud.baseURL = api.URL + "/cloud"
+	updater.robotBaseURL = api.URL + "/robot"
+
+	updater.Handle(Event{Kind: eventIPChange, Hostname: "vpn.example.com", Type: "A", NewValue: "203.0.113.1"})
+	updater.Handle(Event{Kind: eventRecordCreated, Hostname: "mail.example.com", Type: "A", NewValue: "198.51.100.2"})
+	updater.Handle(Event{Kind: eventIPChange, Hostname: "home.example.com", Type: "A", NewValue: "192.0.2.1"})
+	updater.Wait()
+
+	got := strings.Join(requests, ";")
+	for _, expected := range []string{
+		"/cloud/servers/4711/actions/change_dns_ptr 203.0.113.1 vpn.example.com",
+		"/robot/rdns/198.51.100.2 mail.example.com",
+	} {
+		if !strings.Contains(got, expected) {
+			t.Errorf("Expected request %q, got %v", expected, requests)
+		}
+	}
+	if len(requests) != 2 {
+		t.Errorf("Expected two requests, got %v", requests)
+	}
+}
+
+func TestParseReverseDNSTargets(t *testing.T) {
+	for _, value := range []string{"vpn.example.com", "vpn.example.com=cloud", "vpn.example.com=robot:1", "vpn.example.com=aws:1"} {
+		if _, err := parseReverseDNSTargets(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+
+	cfg := ReverseDNSConfig{Targets: []ReverseDNSTarget{{Hostname: "mail.example.com", Provider: rdnsRobot}}, RobotUser: "user"}
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "DYNDNS_ROBOT_PASSWORD") {
+		t.Errorf("Expected missing robot password error, got %v", err)
+	}
+}
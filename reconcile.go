@@ -0,0 +1,123 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// Reconciler compares the public IP of configured hostnames with their records and fixes drift
+type Reconciler struct {
+	server    *DynDNSServer
+	detector  *IPDetector
+	hostnames []string
+}
+
+// NewReconciler creates a reconciler for hostnames using detector to find the current public IP
+func NewReconciler(server *DynDNSServer, detector *IPDetector, hostnames []string) *Reconciler {
+	server.metrics.Describe("dyndns_reconcile_drift_total", "counter", "Number of drifted records corrected by reconciliation.")
+	server.metrics.Describe("dyndns_reconcile_errors_total", "counter", "Number of failed reconciliations.")
+
+	return &Reconciler{
+		server:    server,
+		detector:  detector,
+		hostnames: hostnames,
+	}
+}
+
+// Run reconciles once immediately and then every interval until stop is closed, a zero interval only reconciles once
+func (r *Reconciler) Run(interval time.Duration, stop <-chan struct{}) {
+	r.Reconcile()
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.Reconcile()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Reconcile detects the public addresses and updates every configured hostname whose records differ.
+// It returns the number of records that were corrected.
+func (r *Reconciler) Reconcile() int {
+	ipv4, err := r.detector.DetectIPv4()
+	if err != nil {
+		log.Printf("Reconciliation: IPv4 detection failed: %v", err)
+		r.server.metrics.Inc("dyndns_reconcile_errors_total", Labels{"stage": "detect"})
+	}
+	ipv6, err := r.detector.DetectIPv6()
+	if err != nil {
+		log.Printf("Reconciliation: IPv6 detection failed: %v", err)
+		r.server.metrics.Inc("dyndns_reconcile_errors_total", Labels{"stage": "detect"})
+	}
+
+	corrected := 0
+	for _, hostname := range r.hostnames {
+		if ipv4 != "" && !r.server.isDSLite(hostname) && r.reconcileRecord(hostname, ipv4, "A") {
+			corrected++
+		}
+		if ipv6 != "" && r.reconcileRecord(hostname, ipv6, "AAAA") {
+			corrected++
+		}
+	}
+	return corrected
+}
+
+// reconcileRecord updates a single record if its value differs from ip and reports whether it did
+func (r *Reconciler) reconcileRecord(hostname, ip, recordType string) bool {
+	changed, err := r.server.ensureRecord(hostname, ip, recordType, historySystemReconciler)
+	if err != nil {
+		log.Printf("Reconciliation of %s %s failed: %v", hostname, recordType, err)
+		r.server.metrics.Inc("dyndns_reconcile_errors_total", Labels{"stage": "update"})
+		return false
+	}
+	if changed {
+		r.server.metrics.Inc("dyndns_reconcile_drift_total", Labels{"type": recordType})
+	}
+	return changed
+}
+
+// ensureRecord updates the record of hostname to ip if it differs and reports
+// whether it was changed, system is the component attributed in the history
+func (s *DynDNSServer) ensureRecord(hostname, ip, recordType, system string) (bool, error) {
+	// The addresses detected by the bridge itself are one source of round-robin hostnames
+	if s.isRoundRobin(hostname) {
+		members, err := s.members(hostname, recordType)
+		if err != nil || members[roundRobinBridgeSource] == ip {
+			return false, err
+		}
+		if _, err := s.submit(parkedWrite{Hostname: hostname, Type: recordType, Value: ip, Source: roundRobinBridgeSource, System: system}); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	if s.backups.Hold(hostname, recordType, ip) {
+		return false, nil
+	}
+	lookup, err := s.lookupRecord(hostname, recordType)
+	if err != nil {
+		return false, err
+	}
+
+	if lookup.Existing != nil && lookup.Existing.Value == ip {
+		return false, nil
+	}
+
+	current := "<none>"
+	if lookup.Existing != nil {
+		current = lookup.Existing.Value
+	}
+	log.Printf("%s %s drifted (%s, expected %s), updating", hostname, recordType, current, ip)
+
+	if _, err := s.submit(parkedWrite{Hostname: hostname, Type: recordType, Value: ip, System: system}); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReconcilerReconcile(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"},
+		{ID: "rec2", Type: "A", Name: "nas", Value: "203.0.113.1"},
+	}
+
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, records, &writes)
+	defer mockAPI.Close()
+
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.1"))
+	}))
+	defer echo.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	detector := NewIPDetector(echo.URL, "")
+	reconciler := NewReconciler(server, detector, []string{"home.example.com", "nas.example.com", "new.example.com"})
+
+	corrected := reconciler.Reconcile()
+	if corrected != 2 {
+		t.Errorf("Expected 2 corrected records, got %d", corrected)
+	}
+	if strings.Join(writes, ";") != "PUT rec1;POST A new" {
+		t.Errorf("Expected drifted and missing records to be written, got %v", writes)
+	}
+	if value := server.metrics.Value("dyndns_reconcile_drift_total", Labels{"type": "A"}); value != 2 {
+		t.Errorf("Expected drift counter 2, got %g", value)
+	}
+}
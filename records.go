@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// errNotDeletable is returned for hostnames outside DYNDNS_DELETABLE_HOSTS
+	errNotDeletable = errors.New("hostname is not whitelisted for deletion")
+	// errZoneNotFound is returned when a zone is not accessible with the configured tokens
+	errZoneNotFound = errors.New("zone not found")
+)
+
+// deleteResponse is returned by DELETE /api/records
+type deleteResponse struct {
+	Hostname string   `json:"hostname"`
+	Deleted  []string `json:"deleted"`
+}
+
+// recordEntry is a single record returned by GET /api/records
+type recordEntry struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Hostname string `json:"hostname"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	TTL      *int   `json:"ttl,omitempty"`
+	// Managed is true for records owned or refreshed by this bridge
+	Managed     bool       `json:"managed"`
+	LastManaged *time.Time `json:"last_managed,omitempty"`
+	// Annotation is the metadata stored in the ownership marker, see DYNDNS_ANNOTATIONS
+	Annotation *recordAnnotation `json:"annotation,omitempty"`
+}
+
+// recordsResponse is returned by GET /api/records
+type recordsResponse struct {
+	Zone    string        `json:"zone"`
+	Records []recordEntry `json:"records"`
+	// Stale is set when the API is unavailable and the listing of CachedAt is served instead
+	Stale    bool       `json:"stale,omitempty"`
+	CachedAt *time.Time `json:"cached_at,omitempty"`
+}
+
+// handleRecords serves the /api/records endpoint
+func (s *DynDNSServer) handleRecords(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, authScopeAdmin) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.handleListRecords(w, r)
+	case http.MethodPost:
+		if !s.rejectMaintenance(w, r) {
+			s.handleAddServiceRecord(w, r)
+		}
+	case http.MethodDelete:
+		if !s.rejectMaintenance(w, r) {
+			s.handleDeleteRecord(w, r)
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST, DELETE")
+		httpError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleListRecords returns the records of ?zone= with their management state
+func (s *DynDNSServer) handleListRecords(w http.ResponseWriter, r *http.Request) {
+	zoneName := strings.ToLower(strings.TrimSuffix(r.URL.Query().Get("zone"), "."))
+	if zoneName == "" {
+		httpErrorDetails(w, r, "Missing zone parameter", http.StatusBadRequest, map[string]string{"parameter": "zone"})
+		return
+	}
+
+	response, err := s.listRecords(zoneName)
+	s.health.Observe(err)
+	if isAPIUnavailable(err) {
+		if cached, ok := s.listings.Get(zoneName); ok {
+			log.Printf("Hetzner DNS API unavailable, serving cached records of %s: %v", zoneName, err)
+			response, err = cached, nil
+		}
+	} else if err == nil {
+		s.listings.Put(zoneName, response)
+	}
+	if errors.Is(err, errZoneNotFound) {
+		httpError(w, r, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to list records of %s: %v", zoneName, err)
+		httpError(w, r, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// listRecords loads the records of zoneName through the client configured for it
+func (s *DynDNSServer) listRecords(zoneName string) (*recordsResponse, error) {
+	client, err := s.clientFor(zoneName)
+	if err != nil {
+		return nil, err
+	}
+
+	zones, err := client.GetZones()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get zones: %w", err)
+	}
+	var zone *Zone
+	for i := range zones {
+		if zones[i].Name == zoneName {
+			zone = &zones[i]
+			break
+		}
+	}
+	if zone == nil {
+		return nil, errZoneNotFound
+	}
+
+	records, err := client.GetAllRecords(zone.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get records: %w", err)
+	}
+
+	response := &recordsResponse{Zone: zone.Name, Records: make([]recordEntry, 0, len(records))}
+	for _, record := range records {
+		entry := recordEntry{
+			ID:       record.ID,
+			Name:     record.Name,
+			Hostname: recordFQDN(record.Name, zone.Name),
+			Type:     record.Type,
+			Value:    record.Value,
+			TTL:      record.TTL,
+		}
+		if record.Type == "A" || record.Type == "AAAA" {
+			if seen := s.refreshed.LastSeen(entry.Hostname, record.Type); !seen.IsZero() {
+				entry.Managed = true
+				entry.LastManaged = &seen
+			}
+			if s.ownerID != "" {
+				if marker := findOwnershipRecord(records, record.Name, record.Type, s.ownerID); marker != nil {
+					entry.Managed = true
+					entry.Annotation = markerAnnotation(marker.Value)
+				}
+			}
+		}
+		response.Records = append(response.Records, entry)
+	}
+	return response, nil
+}
+
+// handleDeleteRecord deletes the records of ?hostname=, optionally limited to
+// ?type=A or AAAA. ?type=SRV or CAA deletes those records, only the one of
+// ?value= if given.
+func (s *DynDNSServer) handleDeleteRecord(w http.ResponseWriter, r *http.Request) {
+	hostname := strings.ToLower(r.URL.Query().Get("hostname"))
+	if hostname == "" {
+		httpErrorDetails(w, r, "Missing hostname parameter", http.StatusBadRequest, map[string]string{"parameter": "hostname"})
+		return
+	}
+
+	var deleted []string
+	var err error
+	if recordType := strings.ToUpper(r.URL.Query().Get("type")); isServiceRecordType(recordType) {
+		value := r.URL.Query().Get("value")
+		if value != "" {
+			if _, err := normalizeServiceRecord(hostname, recordType, value); err != nil {
+				httpError(w, r, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		var count int
+		count, err = s.removeServiceRecords(hostname, recordType, value)
+		deleted = []string{}
+		if count > 0 {
+			deleted = append(deleted, recordType)
+		}
+	} else {
+		types, typeErr := deleteTypes(recordType)
+		if typeErr != nil {
+			httpError(w, r, typeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		deleted, err = s.deleteHostRecords(hostname, types)
+	}
+	if errors.Is(err, errNotDeletable) {
+		httpError(w, r, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to delete records of %s: %v", hostname, err)
+		httpError(w, r, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deleteResponse{Hostname: hostname, Deleted: deleted})
+}
+
+// deleteTypes returns the record types to delete for a type parameter, empty means A and AAAA
+func deleteTypes(recordType string) ([]string, error) {
+	switch strings.ToUpper(recordType) {
+	case "":
+		return []string{"A", "AAAA"}, nil
+	case "A", "AAAA":
+		return []string{strings.ToUpper(recordType)}, nil
+	}
+	return nil, fmt.Errorf("unsupported record type: %s", recordType)
+}
+
+// isDeletable reports whether hostname matches one of the DYNDNS_DELETABLE_HOSTS patterns
+func (s *DynDNSServer) isDeletable(hostname string) bool {
+	for _, pattern := range s.deletable {
+		if matchesRoute(strings.ToLower(pattern), hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteHostRecords deletes the records of types for a whitelisted hostname and
+// returns the types that were deleted. With ownership markers enabled only
+// owned records are deleted.
+func (s *DynDNSServer) deleteHostRecords(hostname string, types []string) ([]string, error) {
+	if !s.isDeletable(hostname) {
+		return nil, errNotDeletable
+	}
+
+	deleted := []string{}
+	for _, recordType := range types {
+		var removed bool
+		var err error
+		if s.ownerID != "" {
+			removed, err = s.removeRecord(hostname, recordType)
+		} else {
+			removed, err = s.removeUnmanagedRecord(hostname, recordType)
+		}
+		if err != nil {
+			return deleted, err
+		}
+		if removed {
+			deleted = append(deleted, recordType)
+		}
+	}
+	return deleted, nil
+}
+
+// removeUnmanagedRecord deletes the record of hostname and recordType without checking for ownership
+func (s *DynDNSServer) removeUnmanagedRecord(hostname, recordType string) (bool, error) {
+	if s.maintenance.Active() {
+		return false, errMaintenance
+	}
+	lookup, err := s.lookupRecord(hostname, recordType)
+	if err != nil {
+		return false, err
+	}
+	if lookup.Existing == nil {
+		return false, nil
+	}
+
+	if err := lookup.Client.DeleteRecord(lookup.Existing.ID); err != nil {
+		return false, fmt.Errorf("failed to delete record: %w", err)
+	}
+	log.Printf("Deleted record %s %s", recordType, hostname)
+	return true, nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleDeleteRecord(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "old", Value: "1.1.1.1"},
+		{ID: "rec2", Type: "AAAA", Name: "old", Value: "2001:db8::1"},
+		{ID: "rec3", Type: "A", Name: "owned", Value: "1.1.1.1"},
+		{ID: "rec4", Type: "TXT", Name: "_dyndns-a.owned", Value: ownershipValue("bridge1")},
+		{ID: "rec5", Type: "A", Name: "manual", Value: "1.1.1.1"},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		ownerID        string
+		expectedStatus int
+		expectedWrites []string
+	}{
+		{
+			name:           "delete both record types",
+			query:          "hostname=old.example.com",
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"DELETE rec1", "DELETE rec2"},
+		},
+		{
+			name:           "delete single record type",
+			query:          "hostname=old.example.com&type=aaaa",
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"DELETE rec2"},
+		},
+		{
+			name:           "delete owned record and marker",
+			query:          "hostname=owned.example.com&type=A",
+			ownerID:        "bridge1",
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"DELETE rec3", "DELETE rec4"},
+		},
+		{
+			name:           "refuse unowned record",
+			query:          "hostname=manual.example.com&type=A",
+			ownerID:        "bridge1",
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "hostname not whitelisted",
+			query:          "hostname=home.example.com",
+			expectedStatus: http.StatusForbidden,
+		},
+		{
+			name:           "missing hostname",
+			query:          "type=A",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unsupported type",
+			query:          "hostname=old.example.com&type=MX",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.ownerID = tt.ownerID
+			server.deletable = []string{"old.example.com", "owned.example.com", "manual.example.com"}
+
+			req := httptest.NewRequest("DELETE", "/api/records?"+tt.query, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleRecords(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+		})
+	}
+}
+
+func TestHandleRecordsAuth(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+
+	req := httptest.NewRequest("DELETE", "/api/records?hostname=old.example.com", nil)
+	w := httptest.NewRecorder()
+	server.handleRecords(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("GET", "/api/records?zone=example.com", nil)
+	w = httptest.NewRecorder()
+	server.handleRecords(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("PATCH", "/api/records", nil)
+	req.SetBasicAuth("admin", "password")
+	w = httptest.NewRecorder()
+	server.handleRecords(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", w.Code)
+	}
+}
+
+func TestHandleDeleteRecordResponse(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "old", Value: "1.1.1.1"}}, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.deletable = []string{"*.example.com"}
+
+	req := httptest.NewRequest("DELETE", "/api/records?hostname=old.example.com", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	server.handleRecords(w, req)
+
+	var response deleteResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Hostname != "old.example.com" || strings.Join(response.Deleted, ",") != "A" {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+}
+
+func TestHandleListRecords(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"},
+		{ID: "rec2", Type: "A", Name: "owned", Value: "1.1.1.2"},
+		{ID: "rec3", Type: "TXT", Name: "_dyndns-a.owned", Value: ownershipValue("bridge1")},
+		{ID: "rec4", Type: "MX", Name: "@", Value: "10 mail.example.com"},
+	}
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, records, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.ownerID = "bridge1"
+	server.refreshed.Mark("home.example.com", "A")
+
+	req := httptest.NewRequest("GET", "/api/records?zone=example.com", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	server.handleRecords(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var response recordsResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Zone != "example.com" || len(response.Records) != 4 {
+		t.Fatalf("Unexpected response: %+v", response)
+	}
+
+	byID := map[string]recordEntry{}
+	for _, record := range response.Records {
+		byID[record.ID] = record
+	}
+	if !byID["rec1"].Managed || byID["rec1"].LastManaged == nil || byID["rec1"].Hostname != "home.example.com" {
+		t.Errorf("Expected refreshed record to be managed: %+v", byID["rec1"])
+	}
+	if !byID["rec2"].Managed || byID["rec2"].LastManaged != nil {
+		t.Errorf("Expected owned record to be managed without refresh time: %+v", byID["rec2"])
+	}
+	if byID["rec4"].Managed || byID["rec4"].Hostname != "example.com" {
+		t.Errorf("Expected MX record not to be managed: %+v", byID["rec4"])
+	}
+	if len(writes) != 0 {
+		t.Errorf("Expected no writes, got %v", writes)
+	}
+}
+
+func TestHandleListRecordsErrors(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	tests := []struct {
+		query          string
+		expectedStatus int
+	}{
+		{"", http.StatusBadRequest},
+		{"zone=example.org", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/records?"+tt.query, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleRecords(w, req)
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, w.Code)
+			}
+		})
+	}
+}
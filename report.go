@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Report summarizes the managed hostnames for the period since the previous report
+type Report struct {
+	GeneratedAt time.Time     `json:"generated_at"`
+	Since       time.Time     `json:"since"`
+	Updates     int           `json:"updates"`
+	Failures    int           `json:"failures"`
+	Records     []reportEntry `json:"records"`
+}
+
+// reportEntry is the state of a hostname and record type in a report
+type reportEntry struct {
+	Hostname   string     `json:"hostname"`
+	Type       string     `json:"type"`
+	Value      string     `json:"value,omitempty"`
+	State      string     `json:"state"`
+	Updates    int        `json:"updates"`
+	Failures   int        `json:"failures"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+	LastError  string     `json:"last_error,omitempty"`
+}
+
+// recordStateUnknown marks configured hostnames no update was received for
+const recordStateUnknown = "unknown"
+
+// Text renders the report as a plain text summary
+func (r Report) Text() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "DynDNS report %s: %d updates, %d failures since %s\n",
+		r.GeneratedAt.Format(time.RFC3339), r.Updates, r.Failures, r.Since.Format(time.RFC3339))
+	for _, entry := range r.Records {
+		fmt.Fprintf(&b, "  %s %s: %s value=%s updates=%d failures=%d", entry.Hostname, entry.Type, entry.State, entry.Value, entry.Updates, entry.Failures)
+		if entry.LastError != "" {
+			fmt.Fprintf(&b, " last_error=%q", entry.LastError)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// ReportSink delivers reports
+type ReportSink interface {
+	Send(report Report) error
+}
+
+// logSink writes reports to the log
+type logSink struct{}
+
+// Send implements ReportSink
+func (logSink) Send(report Report) error {
+	for _, line := range strings.Split(strings.TrimSpace(report.Text()), "\n") {
+		log.Print(line)
+	}
+	return nil
+}
+
+// webhookSink posts reports as JSON
+type webhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// Send implements ReportSink
+func (s webhookSink) Send(report Report) error {
+	if err := postJSON(s.client, s.url, report); err != nil {
+		return fmt.Errorf("failed to post report: %w", err)
+	}
+	return nil
+}
+
+// emailSink mails reports through an SMTP server
+type emailSink struct {
+	smtp SMTPConfig
+}
+
+// Send implements ReportSink
+func (s emailSink) Send(report Report) error {
+	return s.smtp.sendMail(fmt.Sprintf("DynDNS report: %d updates, %d failures", report.Updates, report.Failures), report.Text())
+}
+
+// Reporter periodically sends a summary of all managed hostnames
+type Reporter struct {
+	server *DynDNSServer
+	sinks  []ReportSink
+
+	mu       sync.Mutex
+	since    time.Time
+	previous map[string]recordStatus
+}
+
+// NewReporter creates a reporter delivering to sinks
+func NewReporter(server *DynDNSServer, sinks ...ReportSink) *Reporter {
+	return &Reporter{server: server, sinks: sinks, since: server.now().UTC(), previous: map[string]recordStatus{}}
+}
+
+// Generate builds the report for the period since the previous one. Update
+// and failure counts cover that period, values and states are current.
+func (r *Reporter) Generate() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := Report{GeneratedAt: r.server.now().UTC(), Since: r.since}
+	seen := map[string]bool{}
+	current := map[string]recordStatus{}
+	for _, status := range r.server.status.Snapshot() {
+		key := status.Hostname + "/" + status.Type
+		previous := r.previous[key]
+		entry := reportEntry{
+			Hostname:   status.Hostname,
+			Type:       status.Type,
+			Value:      status.Value,
+			State:      status.State,
+			Updates:    status.Updates - previous.Updates,
+			Failures:   status.Failures - previous.Failures,
+			LastUpdate: status.LastUpdate,
+			LastError:  status.LastError,
+		}
+		report.Updates += entry.Updates
+		report.Failures += entry.Failures
+		report.Records = append(report.Records, entry)
+		seen[status.Hostname] = true
+		current[key] = status
+	}
+
+	// Configured hostnames without any update are the silent breakage this report is for
+	for _, hostname := range r.server.hostnames {
+		if !seen[hostname] {
+			report.Records = append(report.Records, reportEntry{Hostname: hostname, State: recordStateUnknown})
+		}
+	}
+	sort.SliceStable(report.Records, func(i, j int) bool {
+		return report.Records[i].Hostname < report.Records[j].Hostname
+	})
+
+	r.since = report.GeneratedAt
+	r.previous = current
+	return report
+}
+
+// Send generates a report and delivers it to all sinks
+func (r *Reporter) Send() error {
+	report := r.Generate()
+	var failed []string
+	for _, sink := range r.sinks {
+		if err := sink.Send(report); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to deliver report: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// Run sends a report every day at the given time of day until stop is closed
+func (r *Reporter) Run(at time.Duration, stop <-chan struct{}) {
+	for {
+		now := r.server.now()
+		due := make(chan struct{})
+		timer := r.server.clock.AfterFunc(nextReportTime(now, at).Sub(now), func() { close(due) })
+		select {
+		case <-due:
+			if err := r.Send(); err != nil {
+				log.Printf("Daily report: %v", err)
+			}
+		case <-stop:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// nextReportTime returns the next time after now at the time of day at
+func nextReportTime(now time.Time, at time.Duration) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	next := midnight.Add(at)
+	if !next.After(now) {
+		next = midnight.AddDate(0, 0, 1).Add(at)
+	}
+	return next
+}
+
+// parseTimeOfDay parses "HH:MM" into the offset from midnight
+func parseTimeOfDay(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got %q", value)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// ReportConfig configures the daily report
+type ReportConfig struct {
+	// Sinks are "log", "webhook" and "email", the report is disabled without any
+	Sinks []string
+	// At is the time of day the report is sent, as offset from midnight
+	At         time.Duration
+	WebhookURL string
+
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	From         string
+	To           []string
+}
+
+// NewSinks creates the configured report sinks
+func (c ReportConfig) NewSinks() ([]ReportSink, error) {
+	var sinks []ReportSink
+	for _, name := range c.Sinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, logSink{})
+		case "webhook":
+			if c.WebhookURL == "" {
+				return nil, fmt.Errorf("webhook report requires DYNDNS_REPORT_WEBHOOK_URL")
+			}
+			sinks = append(sinks, webhookSink{url: c.WebhookURL, client: &http.Client{Timeout: 30 * time.Second}})
+		case "email":
+			if c.SMTPAddr == "" || c.From == "" || len(c.To) == 0 {
+				return nil, fmt.Errorf("email report requires DYNDNS_REPORT_SMTP_ADDR, DYNDNS_REPORT_EMAIL_FROM and DYNDNS_REPORT_EMAIL_TO")
+			}
+			sinks = append(sinks, emailSink{smtp: SMTPConfig{Addr: c.SMTPAddr, Username: c.SMTPUsername, Password: c.SMTPPassword, From: c.From, To: c.To}})
+		default:
+			return nil, fmt.Errorf("unknown report sink %q (expected log, webhook or email)", name)
+		}
+	}
+	return sinks, nil
+}
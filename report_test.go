@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReporterGenerate(t *testing.T) {
+	server := NewDynDNSServer(nil, "admin", "password", "8080")
+	server.hostnames = []string{"home.example.com", "silent.example.com"}
+	reporter := NewReporter(server)
+
+	server.status.Record("home.example.com", "A", "1.2.3.4", recordStateOK, nil)
+	server.status.Record("home.example.com", "A", "1.2.3.5", recordStateError, errors.New("API error"))
+	report := reporter.Generate()
+
+	if report.Updates != 1 || report.Failures != 1 || len(report.Records) != 2 {
+		t.Fatalf("Unexpected first report: %+v", report)
+	}
+	silent := report.Records[1]
+	if silent.Hostname != "silent.example.com" || silent.State != recordStateUnknown {
+		t.Errorf("Expected configured hostname without updates to be reported, got %+v", silent)
+	}
+
+	// Counts of the next report only cover the period since the previous one
+	server.status.Record("home.example.com", "A", "1.2.3.5", recordStateOK, nil)
+	report = reporter.Generate()
+	if report.Updates != 1 || report.Failures != 0 {
+		t.Errorf("Expected one update since the previous report, got %+v", report)
+	}
+	if report.Records[0].Value != "1.2.3.5" {
+		t.Errorf("Expected current value, got %+v", report.Records[0])
+	}
+	if !strings.Contains(report.Text(), "home.example.com A: ok value=1.2.3.5 updates=1 failures=0") {
+		t.Errorf("Unexpected text report:\n%s", report.Text())
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var received Report
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer hook.Close()
+
+	sink := webhookSink{url: hook.URL, client: http.DefaultClient}
+	if err := sink.Send(Report{Updates: 3}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if received.Updates != 3 {
+		t.Errorf("Expected report to be posted, got %+v", received)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	if err := (webhookSink{url: failing.URL, client: http.DefaultClient}).Send(Report{}); err == nil {
+		t.Error("Expected error for failing webhook")
+	}
+}
+
+func TestNextReportTime(t *testing.T) {
+	at := 8 * time.Hour
+	tests := []struct {
+		now      string
+		expected string
+	}{
+		{"2026-03-01T07:59:00Z", "2026-03-01T08:00:00Z"},
+		{"2026-03-01T08:00:00Z", "2026-03-02T08:00:00Z"},
+		{"2026-03-01T23:00:00Z", "2026-03-02T08:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.now, func(t *testing.T) {
+			now, _ := time.Parse(time.RFC3339, tt.now)
+			if next := nextReportTime(now, at).Format(time.RFC3339); next != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, next)
+			}
+		})
+	}
+}
+
+func TestReportConfigNewSinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         ReportConfig
+		expectError bool
+	}{
+		{"log", ReportConfig{Sinks: []string{"log"}}, false},
+		{"webhook without URL", ReportConfig{Sinks: []string{"webhook"}}, true},
+		{"email", ReportConfig{Sinks: []string{"email"}, SMTPAddr: "mail:25", From: "a@example.com", To: []string{"b@example.com"}}, false},
+		{"email without recipient", ReportConfig{Sinks: []string{"email"}, SMTPAddr: "mail:25", From: "a@example.com"}, true},
+		{"unknown sink", ReportConfig{Sinks: []string{"pager"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.cfg.NewSinks()
+			if tt.expectError != (err != nil) {
+				t.Errorf("Expected error %v, got %v", tt.expectError, err)
+			}
+		})
+	}
+}
+
+// channelSink passes delivered reports to a channel
+type channelSink chan Report
+
+func (c channelSink) Send(report Report) error {
+	c <- report
+	return nil
+}
+
+func TestReporterRunClock(t *testing.T) {
+	clock := newFakeClock(time.Date(2026, 3, 1, 6, 0, 0, 0, time.UTC))
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.SetClock(clock)
+	sink := make(channelSink, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go NewReporter(server, sink).Run(8*time.Hour, stop)
+
+	// The report is due at 08:00 on the clock
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		clock.mu.Lock()
+		scheduled := len(clock.timers)
+		clock.mu.Unlock()
+		if scheduled > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	clock.Advance(time.Hour)
+	select {
+	case report := <-sink:
+		t.Fatalf("Expected no report before 08:00, got one at %v", report.GeneratedAt)
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(time.Hour)
+	select {
+	case report := <-sink:
+		if !report.GeneratedAt.Equal(time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)) {
+			t.Errorf("Expected the report at 08:00, got %v", report.GeneratedAt)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the report once the clock reached 08:00")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"text/template"
+)
+
+// responseCodes are the dyndns2 return codes whose body can be customized
+var responseCodes = []string{"good", "nochg", "nohost", "notfqdn", "911", "badagent"}
+
+// responseData is passed to response templates
+type responseData struct {
+	Code     string // dyndns2 return code, e.g. good or nochg
+	Hostname string
+	IPv4     string
+	IPv6     string
+	IP       string // IPv4 if set, otherwise IPv6
+	Details  string // summary of the updated addresses, e.g. "IPv4: 203.0.113.1"
+}
+
+// ResponseTemplates maps return codes to templates rendering the response body
+type ResponseTemplates map[string]*template.Template
+
+// ParseResponseTemplates parses templates keyed by return code
+func ParseResponseTemplates(specs map[string]string) (ResponseTemplates, error) {
+	templates := ResponseTemplates{}
+	for code, spec := range specs {
+		if spec == "" {
+			continue
+		}
+		tmpl, err := template.New(code).Option("missingkey=error").Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s response template: %w", code, err)
+		}
+		// Render once with sample data so broken field references fail at startup
+		if err := tmpl.Execute(io.Discard, responseData{Code: code}); err != nil {
+			return nil, fmt.Errorf("invalid %s response template: %w", code, err)
+		}
+		templates[code] = tmpl
+	}
+	return templates, nil
+}
+
+// render writes the body for data.Code, falling back to the standard response "code details"
+func (t ResponseTemplates) render(w io.Writer, data responseData) {
+	if data.IP == "" {
+		data.IP = data.IPv4
+		if data.IP == "" {
+			data.IP = data.IPv6
+		}
+	}
+
+	tmpl := t[data.Code]
+	if tmpl == nil {
+		// The standard dyndns2 response, written directly as it is on the path of every update
+		if data.Details == "" {
+			io.WriteString(w, data.Code)
+		} else {
+			io.WriteString(w, data.Code+" "+data.Details)
+		}
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Failed to render %s response: %v", data.Code, err)
+	}
+}
+
+// updateDetails summarizes the updated addresses as in "IPv4: x, IPv6: y"
+func updateDetails(ipv4, ipv6 string) string {
+	switch {
+	case ipv4 != "" && ipv6 != "":
+		return "IPv4: " + ipv4 + ", IPv6: " + ipv6
+	case ipv4 != "":
+		return "IPv4: " + ipv4
+	case ipv6 != "":
+		return "IPv6: " + ipv6
+	}
+	return ""
+}
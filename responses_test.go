@@ -0,0 +1,67 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseTemplatesRender(t *testing.T) {
+	templates, err := ParseResponseTemplates(map[string]string{
+		"good":  "OK {{.Hostname}} {{.IP}}",
+		"nochg": "",
+		"911":   "ERROR",
+	})
+	if err != nil {
+		t.Fatalf("ParseResponseTemplates failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		data     responseData
+		expected string
+	}{
+		{"custom good", responseData{Code: "good", Hostname: "home.example.com", IPv6: "2001:db8::1"}, "OK home.example.com 2001:db8::1"},
+		{"custom failure", responseData{Code: "911"}, "ERROR"},
+		{"empty template keeps default", responseData{Code: "nochg", Details: "IPv4: 1.2.3.4"}, "nochg IPv4: 1.2.3.4"},
+		{"default without details", responseData{Code: "badagent"}, "badagent"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			templates.render(w, tt.data)
+			if w.Body.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestParseResponseTemplatesErrors(t *testing.T) {
+	for _, spec := range []string{"{{.Code", "{{.Unknown}}"} {
+		if _, err := ParseResponseTemplates(map[string]string{"good": spec}); err == nil {
+			t.Errorf("Expected error for template %q", spec)
+		}
+	}
+}
+
+func TestHandleUpdateCustomResponse(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.responses, _ = ParseResponseTemplates(map[string]string{"good": "success={{.IPv4}}"})
+
+	req := httptest.NewRequest("GET", "/update?hostname=home.example.com&myip=1.2.3.4", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	server.handleUpdate(w, req)
+
+	if strings.TrimSpace(w.Body.String()) != "success=1.2.3.4" {
+		t.Errorf("Expected custom response, got %q", w.Body.String())
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// DNS UPDATE constants of RFC 2136 and TSIG constants of RFC 8945
+const (
+	dnsOpcodeUpdate = 5
+	dnsTypeA        = 1
+	dnsTypeSOA      = 6
+	dnsTypeAAAA     = 28
+	dnsTypeTSIG     = 250
+	dnsClassANY     = 255
+	tsigAlgorithm   = "hmac-sha256."
+	tsigFudge       = 300
+)
+
+// RFC2136Backend updates a local authoritative server such as BIND or Knot with dynamic DNS updates
+type RFC2136Backend struct {
+	server string
+	zone   string
+	ttl    int
+	// keyName and secret sign updates with TSIG HMAC-SHA256, unsigned if keyName is empty
+	keyName string
+	secret  []byte
+	timeout time.Duration
+	now     func() time.Time
+}
+
+// NewRFC2136Backend creates a backend sending updates for zone to server (host or host:port).
+// tsig is "keyname:base64secret" or empty for unsigned updates.
+func NewRFC2136Backend(server, zone, tsig string, ttl int) (*RFC2136Backend, error) {
+	if server == "" || zone == "" {
+		return nil, fmt.Errorf("server and zone are required")
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	backend := &RFC2136Backend{
+		server:  server,
+		zone:    strings.ToLower(strings.TrimSuffix(zone, ".")),
+		ttl:     ttl,
+		timeout: 5 * time.Second,
+		now:     time.Now,
+	}
+	if tsig != "" {
+		name, secret, ok := strings.Cut(tsig, ":")
+		key, err := base64.StdEncoding.DecodeString(secret)
+		if !ok || name == "" || err != nil || len(key) == 0 {
+			return nil, fmt.Errorf("TSIG key must be keyname:base64secret")
+		}
+		backend.keyName = strings.ToLower(strings.TrimSuffix(name, "."))
+		backend.secret = key
+	}
+	return backend, nil
+}
+
+// SetRecord replaces the records of hostname and recordType with value
+func (b *RFC2136Backend) SetRecord(hostname, recordType, value string) error {
+	if hostname != b.zone && !strings.HasSuffix(hostname, "."+b.zone) {
+		return fmt.Errorf("%s is not in local zone %s", hostname, b.zone)
+	}
+	msg, id, err := b.buildUpdate(hostname, recordType, value)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.DialTimeout("udp", b.server, b.timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(b.timeout))
+
+	if _, err := conn.Write(msg); err != nil {
+		return err
+	}
+	response := make([]byte, 4096)
+	n, err := conn.Read(response)
+	if err != nil {
+		return err
+	}
+	if n < 12 || binary.BigEndian.Uint16(response[0:]) != id {
+		return errShortDNSMessage
+	}
+	if rcode := binary.BigEndian.Uint16(response[2:]) & dnsRcodeMask; rcode != 0 {
+		return fmt.Errorf("update refused with response code %d", rcode)
+	}
+	return nil
+}
+
+// buildUpdate encodes an UPDATE deleting the RRset of hostname and recordType
+// and adding value, signed with TSIG if a key is configured
+func (b *RFC2136Backend) buildUpdate(hostname, recordType, value string) ([]byte, uint16, error) {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid address %q", value)
+	}
+	rrtype, rdata := uint16(dnsTypeA), addr.AsSlice()
+	switch {
+	case recordType == "A" && addr.Is4():
+	case recordType == "AAAA" && addr.Is6() && !addr.Is4In6():
+		rrtype = dnsTypeAAAA
+	default:
+		return nil, 0, fmt.Errorf("%s is not a valid %s value", value, recordType)
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	msg := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], dnsOpcodeUpdate<<11)
+	binary.BigEndian.PutUint16(msg[4:], 1) // zone
+	binary.BigEndian.PutUint16(msg[8:], 2) // updates
+
+	// Zone section
+	if msg, err = appendDNSName(msg, b.zone); err != nil {
+		return nil, 0, err
+	}
+	msg = binary.BigEndian.AppendUint16(msg, dnsTypeSOA)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+
+	// Delete the existing RRset, then add the new record
+	if msg, err = appendDNSName(msg, hostname); err != nil {
+		return nil, 0, err
+	}
+	msg = binary.BigEndian.AppendUint16(msg, rrtype)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassANY)
+	msg = binary.BigEndian.AppendUint32(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, 0)
+
+	msg, _ = appendDNSName(msg, hostname)
+	msg = binary.BigEndian.AppendUint16(msg, rrtype)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassIN)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(b.ttl))
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(rdata)))
+	msg = append(msg, rdata...)
+
+	if b.keyName == "" {
+		return msg, id, nil
+	}
+	msg, err = b.sign(msg, id)
+	return msg, id, err
+}
+
+// sign appends a TSIG record with the HMAC-SHA256 of msg, RFC 8945 section 4.3
+func (b *RFC2136Backend) sign(msg []byte, id uint16) ([]byte, error) {
+	keyName, err := appendDNSName(nil, b.keyName)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, _ := appendDNSName(nil, tsigAlgorithm)
+	signed := uint64(b.now().Unix())
+
+	// TSIG variables covered by the MAC
+	variables := append([]byte(nil), keyName...)
+	variables = binary.BigEndian.AppendUint16(variables, dnsClassANY)
+	variables = binary.BigEndian.AppendUint32(variables, 0)
+	variables = append(variables, algorithm...)
+	variables = appendUint48(variables, signed)
+	variables = binary.BigEndian.AppendUint16(variables, tsigFudge)
+	variables = binary.BigEndian.AppendUint16(variables, 0) // error
+	variables = binary.BigEndian.AppendUint16(variables, 0) // other length
+
+	mac := hmac.New(sha256.New, b.secret)
+	mac.Write(msg)
+	mac.Write(variables)
+	sum := mac.Sum(nil)
+
+	rdata := append([]byte(nil), algorithm...)
+	rdata = appendUint48(rdata, signed)
+	rdata = binary.BigEndian.AppendUint16(rdata, tsigFudge)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(sum)))
+	rdata = append(rdata, sum...)
+	rdata = binary.BigEndian.AppendUint16(rdata, id)
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // error
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // other length
+
+	msg = append(msg, keyName...)
+	msg = binary.BigEndian.AppendUint16(msg, dnsTypeTSIG)
+	msg = binary.BigEndian.AppendUint16(msg, dnsClassANY)
+	msg = binary.BigEndian.AppendUint32(msg, 0)
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(rdata)))
+	msg = append(msg, rdata...)
+	binary.BigEndian.PutUint16(msg[10:], 1) // additional
+	return msg, nil
+}
+
+// appendUint48 appends the 48 bit big endian value of TSIG timestamps
+func appendUint48(b []byte, v uint64) []byte {
+	return append(b, byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
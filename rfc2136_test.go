@@ -0,0 +1,119 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+// startUpdateServer answers DNS UPDATE messages on a local UDP port with rcode
+// and passes each received message to handle
+func startUpdateServer(t *testing.T, rcode uint16, handle func([]byte)) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			msg := append([]byte(nil), buf[:n]...)
+			handle(msg)
+			response := append([]byte(nil), msg[:12]...)
+			binary.BigEndian.PutUint16(response[2:], 0x8000|dnsOpcodeUpdate<<11|rcode)
+			conn.WriteTo(response, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestRFC2136Update(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	received := make(chan []byte, 1)
+	server := startUpdateServer(t, 0, func(msg []byte) { received <- msg })
+
+	backend, err := NewRFC2136Backend(server, "example.com.", "dyndns:"+base64.StdEncoding.EncodeToString(secret), 60)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	backend.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	if err := backend.SetRecord("nas.example.com", "A", "192.168.178.10"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	msg := <-received
+
+	if opcode := binary.BigEndian.Uint16(msg[2:]) >> 11 & 0xf; opcode != dnsOpcodeUpdate {
+		t.Errorf("Expected UPDATE opcode, got %d", opcode)
+	}
+	if zones, updates, additional := binary.BigEndian.Uint16(msg[4:]), binary.BigEndian.Uint16(msg[8:]), binary.BigEndian.Uint16(msg[10:]); zones != 1 || updates != 2 || additional != 1 {
+		t.Errorf("Expected 1 zone, 2 updates and a TSIG record, got %d, %d and %d", zones, updates, additional)
+	}
+
+	// The message ends with the 4 byte address of the added record followed by the TSIG record
+	keyName, _ := appendDNSName(nil, "dyndns")
+	algorithm, _ := appendDNSName(nil, tsigAlgorithm)
+	tsigLength := len(keyName) + 10 + len(algorithm) + 10 + sha256.Size + 6
+	unsigned, tsig := msg[:len(msg)-tsigLength], msg[len(msg)-tsigLength:]
+	if got := net.IP(unsigned[len(unsigned)-4:]).String(); got != "192.168.178.10" {
+		t.Errorf("Expected added address 192.168.178.10, got %s", got)
+	}
+
+	variables := append([]byte(nil), keyName...)
+	variables = binary.BigEndian.AppendUint16(variables, dnsClassANY)
+	variables = binary.BigEndian.AppendUint32(variables, 0)
+	variables = append(variables, algorithm...)
+	variables = appendUint48(variables, 1700000000)
+	variables = binary.BigEndian.AppendUint16(variables, tsigFudge)
+	variables = append(variables, 0, 0, 0, 0)
+	// The MAC covers the message as it was before the TSIG record was added
+	signed := append([]byte(nil), unsigned...)
+	binary.BigEndian.PutUint16(signed[10:], 0)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(signed)
+	mac.Write(variables)
+
+	macOffset := len(keyName) + 10 + len(algorithm) + 10
+	if !hmac.Equal(tsig[macOffset:macOffset+sha256.Size], mac.Sum(nil)) {
+		t.Errorf("TSIG MAC does not match")
+	}
+}
+
+func TestRFC2136UpdateRefused(t *testing.T) {
+	server := startUpdateServer(t, 5, func([]byte) {})
+	backend, err := NewRFC2136Backend(server, "example.com", "", 60)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := backend.SetRecord("nas.example.com", "AAAA", "2001:db8::10"); err == nil {
+		t.Errorf("Expected refused update to fail")
+	}
+}
+
+func TestRFC2136Validation(t *testing.T) {
+	backend, err := NewRFC2136Backend("192.168.178.1", "example.com", "", 60)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if backend.server != "192.168.178.1:53" {
+		t.Errorf("Expected default port 53, got %s", backend.server)
+	}
+	if err := backend.SetRecord("nas.example.org", "A", "192.168.178.10"); err == nil {
+		t.Errorf("Expected hostname outside the zone to fail")
+	}
+	if _, _, err := backend.buildUpdate("nas.example.com", "AAAA", "192.168.178.10"); err == nil {
+		t.Errorf("Expected IPv4 address in AAAA record to fail")
+	}
+	if _, err := NewRFC2136Backend("192.168.178.1", "example.com", "dyndns:not base64", 60); err == nil {
+		t.Errorf("Expected invalid TSIG secret to fail")
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// previousBucket stores the value each record held before its last change
+const previousBucket = "previous"
+
+// previousValue is a record value replaced by an update
+type previousValue struct {
+	Value     string    `json:"value"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// rollbackEntry describes a restored record
+type rollbackEntry struct {
+	Type string `json:"type"`
+	// Value is the restored value, Replaced the value it replaced
+	Value    string `json:"value"`
+	Replaced string `json:"replaced"`
+}
+
+// rollbackResponse is returned by POST /api/rollback
+type rollbackResponse struct {
+	Hostname string          `json:"hostname"`
+	Restored []rollbackEntry `json:"restored"`
+}
+
+// errNothingToRollback is returned for hostnames without a remembered previous value
+var errNothingToRollback = errors.New("no previous value to roll back to")
+
+// rememberPrevious stores value as the value hostname's recordType held before its change
+func (s *DynDNSServer) rememberPrevious(hostname, recordType, value string) {
+	data, err := json.Marshal(previousValue{Value: value, ChangedAt: s.clock.Now().UTC()})
+	if err != nil {
+		return
+	}
+	if err := s.store.Put(previousBucket, hostname+"/"+recordType, data); err != nil {
+		log.Printf("Failed to store previous value of %s %s: %v", hostname, recordType, err)
+	}
+}
+
+// previous returns the value hostname's recordType held before its last change
+func (s *DynDNSServer) previous(hostname, recordType string) (*previousValue, error) {
+	data, ok, err := s.store.Get(previousBucket, hostname+"/"+recordType)
+	if err != nil || !ok {
+		return nil, err
+	}
+	var previous previousValue
+	if err := json.Unmarshal(data, &previous); err != nil {
+		return nil, err
+	}
+	return &previous, nil
+}
+
+// rollback restores the previous A and AAAA values of hostname. The update goes
+// through the regular checks and remembers the replaced value, so a second
+// rollback undoes the first one.
+func (s *DynDNSServer) rollback(hostname string) ([]rollbackEntry, error) {
+	restored := []rollbackEntry{}
+	if s.isRoundRobin(hostname) {
+		return restored, fmt.Errorf("%s: %w", hostname, errRoundRobin)
+	}
+	for _, recordType := range []string{"A", "AAAA"} {
+		previous, err := s.previous(hostname, recordType)
+		if err != nil {
+			return restored, fmt.Errorf("failed to load previous %s value: %w", recordType, err)
+		}
+		if previous == nil {
+			continue
+		}
+
+		var replaced string
+		if lookup, err := s.lookupRecord(hostname, recordType); err == nil && lookup.Existing != nil {
+			replaced = lookup.Existing.Value
+		}
+		if err := s.updateDNSRecord(hostname, previous.Value, recordType); err != nil {
+			s.status.Record(hostname, recordType, previous.Value, recordStateError, err)
+			return restored, err
+		}
+		s.status.Record(hostname, recordType, previous.Value, recordStateOK, nil)
+		log.Printf("Rolled back %s %s from %s to %s", hostname, recordType, replaced, previous.Value)
+		restored = append(restored, rollbackEntry{Type: recordType, Value: previous.Value, Replaced: replaced})
+	}
+
+	if len(restored) == 0 {
+		return restored, errNothingToRollback
+	}
+	return restored, nil
+}
+
+// handleRollback serves POST /api/rollback?hostname=
+func (s *DynDNSServer) handleRollback(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, authScopeAdmin) || s.rejectMaintenance(w, r) {
+		return
+	}
+
+	hostname := strings.ToLower(r.URL.Query().Get("hostname"))
+	if hostname == "" {
+		httpErrorDetails(w, r, "Missing hostname parameter", http.StatusBadRequest, map[string]string{"parameter": "hostname"})
+		return
+	}
+
+	s.history.SetClient(hostname, requestAnnotation(r))
+	restored, err := s.rollback(hostname)
+	if errors.Is(err, errNothingToRollback) {
+		httpError(w, r, err.Error(), http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, errRoundRobin) {
+		httpError(w, r, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to roll back %s: %v", hostname, err)
+		httpError(w, r, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rollbackResponse{Hostname: hostname, Restored: restored})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUpdateRemembersPreviousValue(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"}}, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	if err := server.updateDNSRecord("home.example.com", "1.1.1.1", "A"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if previous, _ := server.previous("home.example.com", "A"); previous != nil {
+		t.Errorf("Expected unchanged update not to remember a value, got %+v", previous)
+	}
+
+	if err := server.updateDNSRecord("home.example.com", "10.0.0.1", "A"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	previous, err := server.previous("home.example.com", "A")
+	if err != nil || previous == nil || previous.Value != "1.1.1.1" {
+		t.Errorf("Expected previous value 1.1.1.1, got %+v (%v)", previous, err)
+	}
+}
+
+func TestHandleRollback(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "rec1", Type: "A", Name: "home", Value: "10.0.0.1"},
+		{ID: "rec2", Type: "AAAA", Name: "home", Value: "2001:db8::1"},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		previous       map[string]string
+		expectedStatus int
+		expectedWrites []string
+	}{
+		{
+			name:           "restore previous A value",
+			query:          "hostname=home.example.com",
+			previous:       map[string]string{"A": "203.0.113.1"},
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"PUT rec1"},
+		},
+		{
+			name:           "restore both record types",
+			query:          "hostname=HOME.example.com",
+			previous:       map[string]string{"A": "203.0.113.1", "AAAA": "2001:db8::2"},
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"PUT rec1", "PUT rec2"},
+		},
+		{
+			name:           "nothing to roll back",
+			query:          "hostname=home.example.com",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "missing hostname",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			for recordType, value := range tt.previous {
+				server.rememberPrevious("home.example.com", recordType, value)
+			}
+
+			req := httptest.NewRequest("POST", "/api/rollback?"+tt.query, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleRollback(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+			if w.Code != http.StatusOK {
+				return
+			}
+
+			var response rollbackResponse
+			if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if response.Restored[0].Value != "203.0.113.1" || response.Restored[0].Replaced != "10.0.0.1" {
+				t.Errorf("Unexpected response: %+v", response)
+			}
+			// The replaced value becomes the previous one, a second rollback undoes the first
+			if previous, _ := server.previous("home.example.com", "A"); previous == nil || previous.Value != "10.0.0.1" {
+				t.Errorf("Expected replaced value to be remembered, got %+v", previous)
+			}
+		})
+	}
+}
+
+func TestHandleRollbackAuth(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+
+	req := httptest.NewRequest("POST", "/api/rollback?hostname=home.example.com", nil)
+	w := httptest.NewRecorder()
+	server.handleRollback(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", w.Code)
+	}
+}
+
+func TestRunCommandRollback(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "10.0.0.1"}}, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.rememberPrevious("home.example.com", "A", "203.0.113.1")
+
+	var out bytes.Buffer
+	if err := runCommand(server, []string{"rollback", "home.example.com"}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "Rolled back home.example.com A from 10.0.0.1 to 203.0.113.1") {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+
+	if err := runCommand(server, []string{"rollback"}, &out); err == nil || !strings.Contains(err.Error(), "requires a hostname") {
+		t.Errorf("Expected missing hostname error, got %v", err)
+	}
+}
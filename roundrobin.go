@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+)
+
+// membersBucket stores the value each source contributes to a round-robin record set
+const membersBucket = "members"
+
+// roundRobinBridgeSource is the source of the addresses found by reconciliation and TR-064 polling
+const roundRobinBridgeSource = "bridge"
+
+// errRoundRobin is returned by writers without a source for round-robin
+// hostnames, a single value would replace the members of all sources
+var errRoundRobin = errors.New("round-robin hostnames are only updated by their sources")
+
+// roundRobinHosts are hostnames whose A and AAAA records hold one value per
+// source credential, e.g. one address per WAN link. An update replaces only
+// the value of its own credential and offline=yes removes it, so resolvers
+// rotate between the links that are up.
+type roundRobinHosts struct {
+	patterns []string
+	// sources are further usernames sharing the password, one per link
+	sources []string
+
+	// mu serializes the read-modify-write of the member values
+	mu sync.Mutex
+}
+
+// newRoundRobinHosts creates the round-robin mode for hostnames matching patterns
+func newRoundRobinHosts(patterns, sources []string) *roundRobinHosts {
+	return &roundRobinHosts{patterns: patterns, sources: sources}
+}
+
+// Matches reports whether hostname keeps a value per source
+func (h *roundRobinHosts) Matches(hostname string) bool {
+	for _, pattern := range h.patterns {
+		if matchesRoute(strings.ToLower(pattern), hostname) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRoundRobin reports whether hostname keeps a value per source
+func (s *DynDNSServer) isRoundRobin(hostname string) bool {
+	return s.roundRobin != nil && s.roundRobin.Matches(hostname)
+}
+
+// members returns the value of each source of hostname's recordType
+func (s *DynDNSServer) members(hostname, recordType string) (map[string]string, error) {
+	members := map[string]string{}
+	data, ok, err := s.store.Get(membersBucket, hostname+"/"+recordType)
+	if err != nil || !ok {
+		return members, err
+	}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// storeMembers saves the values of the sources of hostname's recordType
+func (s *DynDNSServer) storeMembers(hostname, recordType string, members map[string]string) error {
+	if len(members) == 0 {
+		return s.store.Delete(membersBucket, hostname+"/"+recordType)
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(membersBucket, hostname+"/"+recordType, data)
+}
+
+// updateMembers sets the A and AAAA values of source, an empty address leaves
+// the record type alone. Like updateTargets it reports whether every write was
+// a throttled repeat of the current value and how many were sent to the API.
+func (s *DynDNSServer) updateMembers(hostname, source, ipv4, ipv6 string) (bool, int, error) {
+	unchanged, writes := true, 0
+	for _, update := range []struct{ family, recordType, ip string }{{"IPv4", "A", ipv4}, {"IPv6", "AAAA", ipv6}} {
+		if update.ip == "" || update.recordType == "A" && s.isDSLite(hostname) {
+			continue
+		}
+		decision, err := s.submit(parkedWrite{Hostname: hostname, Type: update.recordType, Value: update.ip, Source: source})
+		if err != nil {
+			logUpdateError(update.family, err)
+			return false, writes, err
+		}
+		if decision == rateAllow {
+			writes++
+		}
+		unchanged = unchanged && decision == rateNoChange
+	}
+	return unchanged, writes, nil
+}
+
+// removeMembers removes the A and AAAA values of source from hostname
+func (s *DynDNSServer) removeMembers(hostname, source string) error {
+	for _, recordType := range []string{"A", "AAAA"} {
+		members, err := s.members(hostname, recordType)
+		if err != nil {
+			return fmt.Errorf("failed to load round-robin members: %w", err)
+		}
+		if _, ok := members[source]; !ok {
+			continue
+		}
+		if _, err := s.submit(parkedWrite{Hostname: hostname, Type: recordType, Source: source}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeMember points the value of write.Source at write.Value, an empty value
+// removes it. The record of the previous value is changed in place unless
+// another source still uses it. Records not set by any source are left alone,
+// except that the first source takes over the record of a hostname that was
+// updated before it became round-robin.
+func (s *DynDNSServer) writeMember(write parkedWrite) error {
+	changed, zone, err := s.setMember(write)
+	if err != nil || write.Value == "" {
+		return err
+	}
+	s.verifyRecord(zone, write.Hostname, write.Type, write.Value, changed)
+	return nil
+}
+
+// setMember performs the write of writeMember and reports whether a record
+// was written and the zone of the records
+func (s *DynDNSServer) setMember(write parkedWrite) (bool, *Zone, error) {
+	hostname, recordType, source, ip := write.Hostname, write.Type, write.Source, write.Value
+	s.roundRobin.mu.Lock()
+	defer s.roundRobin.mu.Unlock()
+
+	members, err := s.members(hostname, recordType)
+	if err != nil {
+		return false, nil, fmt.Errorf("failed to load round-robin members: %w", err)
+	}
+	previous, known := members[source]
+	if ip == "" && !known {
+		return false, nil, nil
+	}
+	shared := func(value string) bool {
+		for other, otherValue := range members {
+			if other != source && otherValue == value {
+				return true
+			}
+		}
+		return false
+	}
+
+	lookup, err := s.lookupRecord(hostname, recordType)
+	if err != nil {
+		return false, nil, err
+	}
+	if err := s.checkWritable(lookup, hostname, recordType); err != nil {
+		return false, nil, err
+	}
+	client := lookup.Client
+	var records []DNSRecord
+	for _, record := range lookup.Records {
+		if record.Name == lookup.Name && record.Type == recordType {
+			records = append(records, record)
+		}
+	}
+	find := func(value string) *DNSRecord {
+		for i := range records {
+			if records[i].Value == value {
+				return &records[i]
+			}
+		}
+		return nil
+	}
+
+	// The record of the previous value is free once no other source uses it
+	var free *DNSRecord
+	if known && previous != ip && !shared(previous) {
+		free = find(previous)
+	} else if !known && len(members) == 0 && len(records) > 0 && find(ip) == nil {
+		free = &records[0]
+	}
+	// Each member keeps the modification time of its own record for the conflict check
+	memberKey := recordType + "/" + source
+	if free != nil {
+		if write, err := s.checkConflict(client, hostname, memberKey, free); !write {
+			return false, lookup.Zone, err
+		}
+	}
+	if ip != "" {
+		memberLookup := *lookup
+		memberLookup.Existing = free
+		s.checkDNSSEC(&memberLookup, hostname, recordType, ip)
+	}
+
+	changed := false
+	switch {
+	case ip == "":
+		if free != nil {
+			if err := s.deleteMember(client, lookup, records, *free); err != nil {
+				return false, lookup.Zone, err
+			}
+			log.Printf("Removed %s from the %s records of %s for %s", free.Value, recordType, hostname, source)
+			s.recordChange(hostname, recordType, free.Value, "", s.changedBy(write))
+			changed = true
+		}
+		delete(members, source)
+	case find(ip) != nil:
+		if free != nil {
+			if err := s.deleteMember(client, lookup, records, *free); err != nil {
+				return false, lookup.Zone, err
+			}
+			log.Printf("Removed %s from the %s records of %s, %s is already present", free.Value, recordType, hostname, ip)
+			s.recordChange(hostname, recordType, free.Value, "", s.changedBy(write))
+			changed = true
+		}
+		members[source] = ip
+	default:
+		record, _, err := dyndns.UpsertRecord(client, lookup.Zone.ID, lookup.Name, recordType, ip, 0, free)
+		if err != nil {
+			return false, lookup.Zone, err
+		}
+		s.rememberModified(hostname, memberKey, record)
+		if free != nil {
+			log.Printf("Updated %s record %s of %s from %s to %s for %s", recordType, record.ID, hostname, free.Value, ip, source)
+			s.rememberPrevious(hostname, recordType, free.Value)
+			s.recordChange(hostname, recordType, free.Value, ip, s.changedBy(write))
+			s.notifyIPChange(hostname, recordType, free.Value, ip)
+		} else {
+			log.Printf("Added %s record %s -> %s for %s", recordType, lookup.Name, ip, source)
+			s.recordChange(hostname, recordType, "", ip, s.changedBy(write))
+			s.events.Publish(Event{Kind: eventRecordCreated, Severity: severityInfo, Hostname: hostname, Type: recordType, NewValue: ip})
+			if len(records) == 0 && s.ownerID != "" {
+				if err := s.createOwnershipRecord(client, lookup.Zone.ID, hostname, lookup.Name, recordType); err != nil {
+					return false, lookup.Zone, err
+				}
+			}
+		}
+		members[source] = ip
+		changed = true
+	}
+
+	if err := s.storeMembers(hostname, recordType, members); err != nil {
+		return changed, lookup.Zone, fmt.Errorf("failed to store round-robin members: %w", err)
+	}
+	return changed, lookup.Zone, nil
+}
+
+// deleteMember deletes record, the ownership marker goes with the last record of its type
+func (s *DynDNSServer) deleteMember(client *Client, lookup *recordLookup, records []DNSRecord, record DNSRecord) error {
+	if s.ownerID != "" && len(records) == 1 {
+		return s.deleteOwnedRecord(client, lookup.Records, record)
+	}
+	if err := client.DeleteRecord(record.ID); err != nil {
+		return fmt.Errorf("failed to delete record: %w", err)
+	}
+	return nil
+}
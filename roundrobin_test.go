@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundRobinValues returns the sorted values of the recordType records of name
+func roundRobinValues(fake *fakeHetzner, name, recordType string) []string {
+	var values []string
+	for _, record := range fake.Records() {
+		if record.Name == name && record.Type == recordType {
+			values = append(values, record.Value)
+		}
+	}
+	slices.Sort(values)
+	return values
+}
+
+func TestRoundRobinUpdates(t *testing.T) {
+	fake, client := newFakeAPIServer(t,
+		DNSRecord{Name: "home", Type: "A", Value: "198.51.100.1"},
+		DNSRecord{Name: "other", Type: "A", Value: "198.51.100.99"},
+	)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.roundRobin = newRoundRobinHosts([]string{"home.example.com"}, []string{"wan1", "wan2"})
+
+	tests := []struct {
+		name         string
+		user         string
+		query        string
+		expectedCode string
+		expected     []string
+	}{
+		// The first source takes over the record from before round-robin
+		{name: "first source", user: "wan1", query: "myip=203.0.113.1", expectedCode: "good", expected: []string{"203.0.113.1"}},
+		{name: "second source adds a value", user: "wan2", query: "myip=203.0.113.2", expectedCode: "good", expected: []string{"203.0.113.1", "203.0.113.2"}},
+		{name: "repeated value", user: "wan2", query: "myip=203.0.113.2", expectedCode: "good", expected: []string{"203.0.113.1", "203.0.113.2"}},
+		{name: "new address replaces only its own value", user: "wan1", query: "myip=203.0.113.3", expectedCode: "good", expected: []string{"203.0.113.2", "203.0.113.3"}},
+		{name: "shared value", user: "wan1", query: "myip=203.0.113.2", expectedCode: "good", expected: []string{"203.0.113.2"}},
+		{name: "shared value stays while used", user: "wan2", query: "myip=203.0.113.4", expectedCode: "good", expected: []string{"203.0.113.2", "203.0.113.4"}},
+		{name: "offline removes the source", user: "wan2", query: "offline=yes", expectedCode: "good", expected: []string{"203.0.113.2"}},
+		{name: "offline of the last source", user: "wan1", query: "offline=yes", expectedCode: "good", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&"+tt.query, nil)
+			req.SetBasicAuth(tt.user, "password")
+			req.RemoteAddr = "192.0.2.1:1234"
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), tt.expectedCode) {
+				t.Fatalf("Expected %s, got %d %s", tt.expectedCode, w.Code, w.Body.String())
+			}
+			if values := roundRobinValues(fake, "home", "A"); !slices.Equal(values, tt.expected) {
+				t.Errorf("Expected the A records %v, got %v", tt.expected, values)
+			}
+		})
+	}
+
+	if values := roundRobinValues(fake, "other", "A"); !slices.Equal(values, []string{"198.51.100.99"}) {
+		t.Errorf("Expected other hostnames to be untouched, got %v", values)
+	}
+}
+
+func TestRoundRobinStatus(t *testing.T) {
+	_, client := newFakeAPIServer(t)
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.roundRobin = newRoundRobinHosts([]string{"*.lb.example.com"}, []string{"wan1"})
+
+	expected := map[string]string{"admin": "2001:db8::1", "wan1": "2001:db8::2"}
+	for user, ip := range expected {
+		req := httptest.NewRequest("GET", "/nic/update?hostname=www.lb.example.com&myipv6="+ip, nil)
+		req.SetBasicAuth(user, "password")
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		server.handleUpdate(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Update of %s failed: %d %s", user, w.Code, w.Body.String())
+		}
+	}
+
+	status := server.currentStatus()
+	if len(status.Records) != 1 {
+		t.Fatalf("Expected one record, got %+v", status.Records)
+	}
+	if members := status.Records[0].Members; !maps.Equal(members, expected) {
+		t.Errorf("Expected the members %v, got %v", expected, status.Records[0].Members)
+	}
+}
+
+func TestRoundRobinWritesHonorGuards(t *testing.T) {
+	update := func(server *DynDNSServer, user, query string) string {
+		req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&"+query, nil)
+		req.SetBasicAuth(user, "password")
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		server.handleUpdate(w, req)
+		return w.Body.String()
+	}
+
+	t.Run("maintenance", func(t *testing.T) {
+		fake, client := newFakeAPIServer(t)
+		server := NewDynDNSServer(client, "admin", "password", "8080")
+		server.roundRobin = newRoundRobinHosts([]string{"home.example.com"}, []string{"wan1"})
+		server.maintenance.Begin("", maintenanceQueue)
+
+		update(server, "wan1", "myip=203.0.113.1")
+		update(server, "admin", "myip=203.0.113.2")
+		if values := roundRobinValues(fake, "home", "A"); values != nil {
+			t.Fatalf("Expected no writes during maintenance, got %v", values)
+		}
+		if response := server.maintenance.End(); response.Applied != 2 {
+			t.Errorf("Expected 2 queued member writes, got %+v", response)
+		}
+		if values := roundRobinValues(fake, "home", "A"); !slices.Equal(values, []string{"203.0.113.1", "203.0.113.2"}) {
+			t.Errorf("Expected both members after maintenance, got %v", values)
+		}
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		fake, client := newFakeAPIServer(t)
+		server := NewDynDNSServer(client, "admin", "password", "8080")
+		server.roundRobin = newRoundRobinHosts([]string{"home.example.com"}, []string{"wan1"})
+		server.limiter = NewRateLimiter(time.Hour)
+
+		update(server, "wan1", "myip=203.0.113.1")
+		if body := update(server, "wan1", "myip=203.0.113.1"); !strings.HasPrefix(body, "nochg") {
+			t.Errorf("Expected nochg for a repeated member value, got %s", body)
+		}
+		update(server, "wan1", "myip=203.0.113.3")
+		// Each source is limited on its own
+		update(server, "admin", "myip=203.0.113.2")
+		if values := roundRobinValues(fake, "home", "A"); !slices.Equal(values, []string{"203.0.113.1", "203.0.113.2"}) {
+			t.Errorf("Expected the change of wan1 to be queued, got %v", values)
+		}
+	})
+}
+
+func TestRoundRobinRefusesSourcelessWriters(t *testing.T) {
+	// newMembers returns a bridge on which wan1 and wan2 are members of home.example.com
+	newMembers := func(t *testing.T) (*fakeHetzner, *DynDNSServer) {
+		fake, client := newFakeAPIServer(t)
+		server := NewDynDNSServer(client, "admin", "password", "8080")
+		server.roundRobin = newRoundRobinHosts([]string{"home.example.com"}, []string{"wan1", "wan2"})
+		for user, ip := range map[string]string{"wan1": "203.0.113.1", "wan2": "203.0.113.2"} {
+			req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&myip="+ip, nil)
+			req.SetBasicAuth(user, "password")
+			req.RemoteAddr = "192.0.2.1:1234"
+			server.handleUpdate(httptest.NewRecorder(), req)
+		}
+		return fake, server
+	}
+	expectMembers := func(t *testing.T, fake *fakeHetzner, server *DynDNSServer) {
+		t.Helper()
+		if values := roundRobinValues(fake, "home", "A"); !slices.Equal(values, []string{"203.0.113.1", "203.0.113.2"}) {
+			t.Errorf("Expected the A records of both members, got %v", values)
+		}
+		members, _ := server.members("home.example.com", "A")
+		if expected := map[string]string{"wan1": "203.0.113.1", "wan2": "203.0.113.2"}; !maps.Equal(members, expected) {
+			t.Errorf("Expected the members %v, got %v", expected, members)
+		}
+	}
+
+	t.Run("grpc", func(t *testing.T) {
+		fake, server := newMembers(t)
+		code, _ := callGRPC(t, serveGRPC(t, server), "Update", "grpc-token", protoMessage(nil).String(1, "home.example.com").String(2, "198.51.100.1"))
+		if code != grpcFailedPrecondition {
+			t.Errorf("Expected status %d, got %d", grpcFailedPrecondition, code)
+		}
+		expectMembers(t, fake, server)
+	})
+
+	t.Run("mqtt", func(t *testing.T) {
+		fake, server := newMembers(t)
+		payload, _ := json.Marshal(mqttUpdateCommand{Hostname: "home.example.com", MyIP: "198.51.100.1"})
+		header, body, err := readMQTTPacket(bufio.NewReader(bytes.NewReader(mqttPublishPacket("dyndns/update", payload, false))))
+		if err != nil {
+			t.Fatal(err)
+		}
+		NewMQTTBridge(server, MQTTConfig{Commands: true}).handlePublish(header, body)
+		expectMembers(t, fake, server)
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		fake, server := newMembers(t)
+		server.rememberPrevious("home.example.com", "A", "198.51.100.1")
+		req := httptest.NewRequest("POST", "/api/rollback?hostname=home.example.com", nil)
+		req.SetBasicAuth("admin", "password")
+		w := httptest.NewRecorder()
+		server.handleRollback(w, req)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+		}
+		expectMembers(t, fake, server)
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Route groups, the audience of an endpoint
+const (
+	routeGroupUpdate  = "update"  // DynDNS clients
+	routeGroupHealth  = "health"  // load balancers and orchestrators
+	routeGroupMetrics = "metrics" // Prometheus
+	routeGroupAPI     = "api"     // scripts and the command line
+	routeGroupAdmin   = "admin"   // administrators debugging the bridge
+)
+
+// serverRoute is an endpoint of the server
+type serverRoute struct {
+	Path  string
+	Group string
+	// Methods are the allowed methods, answering others with 405, nil leaves them to the handler
+	Methods []string
+	// Blocked rejects requests of addresses on the blocklists before the handler runs
+	Blocked bool
+	handler http.HandlerFunc
+}
+
+// readMethods are the methods of endpoints that only return state
+var readMethods = []string{"GET", "HEAD"}
+
+// routes returns the route table of the server in the order the endpoints are
+// announced, followed by the emulated update endpoints of other providers
+func (s *DynDNSServer) routes() []serverRoute {
+	routes := s.bridgeRoutes()
+	emulated, _ := s.emulationRoutes(routes)
+	return append(routes, emulated...)
+}
+
+// bridgeRoutes returns the endpoints of the bridge itself
+func (s *DynDNSServer) bridgeRoutes() []serverRoute {
+	routes := []serverRoute{
+		{Path: "/update", Group: routeGroupUpdate, Methods: s.updateMethods(), Blocked: true, handler: s.routeTenant((*DynDNSServer).handleUpdate)},
+		// Alternative endpoint some clients use
+		{Path: "/nic/update", Group: routeGroupUpdate, Methods: s.updateMethods(), Blocked: true, handler: s.routeTenant((*DynDNSServer).handleUpdate)},
+		{Path: "/health", Group: routeGroupHealth, Methods: readMethods, handler: s.handleHealth},
+		// Readiness, 503 while the API is suspended
+		{Path: "/readyz", Group: routeGroupHealth, Methods: readMethods, handler: s.handleReady},
+		{Path: "/metrics", Group: routeGroupMetrics, Methods: readMethods, handler: withCaching(s.requireAuth(authScopeMetrics, s.metrics.ServeHTTP), cacheRevalidate)},
+		{Path: "/version", Group: routeGroupHealth, Methods: readMethods, handler: withCaching(s.handleVersion, cacheStatic)},
+		{Path: "/api/status", Group: routeGroupAPI, Methods: readMethods, Blocked: true, handler: withCaching(s.routeTenant((*DynDNSServer).handleStatus), cacheRevalidate)},
+		{Path: "/api/records", Group: routeGroupAPI, Methods: []string{"GET", "HEAD", "POST", "DELETE"}, Blocked: true, handler: withCaching(s.handleRecords, cacheRevalidate)},
+		{Path: "/api/usage", Group: routeGroupAPI, Methods: readMethods, Blocked: true, handler: withCaching(s.routeTenant((*DynDNSServer).handleUsage), cacheRevalidate)},
+		{Path: "/api/manifest", Group: routeGroupAPI, Methods: readMethods, Blocked: true, handler: withCaching(s.handleManifest, cacheRevalidate)},
+		// Signed by the Git host instead of authenticated
+		{Path: "/api/git-sync", Group: routeGroupAPI, Methods: []string{"POST"}, handler: s.handleGitWebhook},
+		{Path: "/api/fritzbox", Group: routeGroupAPI, Methods: readMethods, Blocked: true, handler: s.routeTenant((*DynDNSServer).handleFritzBoxConfig)},
+		{Path: "/api/rollback", Group: routeGroupAPI, Methods: []string{"POST"}, Blocked: true, handler: s.handleRollback},
+		{Path: "/api/history", Group: routeGroupAPI, Methods: readMethods, Blocked: true, handler: withCaching(s.handleHistory, cacheRevalidate)},
+		{Path: "/api/sla", Group: routeGroupAPI, Methods: readMethods, Blocked: true, handler: withCaching(s.handleSLA, cacheRevalidate)},
+		{Path: "/api/maintenance", Group: routeGroupAPI, Methods: []string{"GET", "HEAD", "POST", "DELETE"}, Blocked: true, handler: s.handleMaintenance},
+		{Path: "/openapi.json", Group: routeGroupAPI, Methods: readMethods, handler: withCaching(s.handleOpenAPI, cacheStatic)},
+		// Root endpoint for simple health checks
+		{Path: "/", Group: routeGroupHealth, Methods: readMethods, handler: s.handleHealth},
+	}
+	if s.debug {
+		routes = append(routes, serverRoute{Path: "/debug/", Group: routeGroupAdmin, Blocked: true, handler: s.requireAuth(authScopeAdmin, s.debugHandler().ServeHTTP)})
+	}
+	return routes
+}
+
+// Routes returns the handler Start serves all endpoints of the bridge with,
+// below DYNDNS_BASE_PATH if it is set. Requests are written to the access log
+// if one is configured. The handler depends on the whole server and is not
+// part of the dyndns package, other servers reach the endpoints through a
+// reverse proxy instead of mounting it.
+func (s *DynDNSServer) Routes() http.Handler {
+	// An own mux keeps the handlers net/http/pprof and expvar register on the default mux unreachable
+	mux := http.NewServeMux()
+	for _, route := range s.routes() {
+		handler := route.handler
+		if route.Methods != nil {
+			handler = allowMethods(handler, route.Methods...)
+		}
+		if route.Blocked {
+			handler = s.rejectBlocked(handler)
+		}
+		if route.Group == routeGroupUpdate {
+			// Panics are answered with 911 and the time clients wait for is measured
+			handler = recoverPanics(s.timeResponses(s.checkCountry(handler)))
+		}
+		mux.HandleFunc(route.Path, handler)
+	}
+	if s.accessLog != nil {
+		return s.accessLog.Wrap(s.mountBasePath(mux))
+	}
+	return s.mountBasePath(mux)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestRouteTable(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.debug = true
+
+	groups := map[string][]string{}
+	seen := map[string]bool{}
+	for _, route := range server.routes() {
+		if seen[route.Path] {
+			t.Errorf("Duplicate route %s", route.Path)
+		}
+		seen[route.Path] = true
+		if route.handler == nil {
+			t.Errorf("Route %s has no handler", route.Path)
+		}
+		groups[route.Group] = append(groups[route.Group], route.Path)
+	}
+
+	expected := map[string][]string{
+		routeGroupUpdate:  {"/update", "/nic/update"},
+		routeGroupHealth:  {"/health", "/readyz", "/version", "/"},
+		routeGroupMetrics: {"/metrics"},
+		routeGroupAPI: {"/api/status", "/api/records", "/api/usage", "/api/manifest", "/api/git-sync", "/api/fritzbox",
+			"/api/rollback", "/api/history", "/api/sla", "/api/maintenance", "/openapi.json"},
+		routeGroupAdmin: {"/debug/"},
+	}
+	for group, paths := range expected {
+		if !slices.Equal(groups[group], paths) {
+			t.Errorf("Expected %s routes %v, got %v", group, paths, groups[group])
+		}
+	}
+	if len(groups) != len(expected) {
+		t.Errorf("Unexpected route groups %v", groups)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		auth           bool
+		debug          bool
+		expectedStatus int
+		expectedAllow  string
+	}{
+		{name: "update requires credentials", method: "GET", path: "/update?hostname=home.example.com&myip=203.0.113.1", expectedStatus: http.StatusUnauthorized},
+		{name: "nic update requires credentials", method: "GET", path: "/nic/update?hostname=home.example.com&myip=203.0.113.1", expectedStatus: http.StatusUnauthorized},
+		{name: "update method not allowed", method: "DELETE", path: "/update", expectedStatus: http.StatusMethodNotAllowed, expectedAllow: "GET, HEAD, OPTIONS"},
+		{name: "health", method: "GET", path: "/health", expectedStatus: http.StatusOK},
+		{name: "readiness", method: "HEAD", path: "/readyz", expectedStatus: http.StatusOK},
+		{name: "root", method: "GET", path: "/", expectedStatus: http.StatusOK},
+		{name: "version", method: "GET", path: "/version", expectedStatus: http.StatusOK},
+		{name: "metrics", method: "GET", path: "/metrics", expectedStatus: http.StatusOK},
+		{name: "api requires credentials", method: "GET", path: "/api/status", expectedStatus: http.StatusUnauthorized},
+		{name: "api", method: "GET", path: "/api/status", auth: true, expectedStatus: http.StatusOK},
+		{name: "api method not allowed", method: "POST", path: "/api/status", auth: true, expectedStatus: http.StatusMethodNotAllowed, expectedAllow: "GET, HEAD, OPTIONS"},
+		{name: "api options", method: "OPTIONS", path: "/api/rollback", expectedStatus: http.StatusNoContent, expectedAllow: "POST, OPTIONS"},
+		{name: "openapi", method: "GET", path: "/openapi.json", expectedStatus: http.StatusOK},
+		{name: "admin requires credentials", method: "GET", path: "/debug/vars", debug: true, expectedStatus: http.StatusUnauthorized},
+		{name: "admin", method: "GET", path: "/debug/vars", auth: true, debug: true, expectedStatus: http.StatusOK},
+		{name: "admin disabled falls through to the root", method: "GET", path: "/debug/vars", auth: true, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+			server.debug = tt.debug
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.auth {
+				req.SetBasicAuth("admin", "password")
+			}
+			w := httptest.NewRecorder()
+			server.Routes().ServeHTTP(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedAllow != "" && w.Header().Get("Allow") != tt.expectedAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.expectedAllow, w.Header().Get("Allow"))
+			}
+		})
+	}
+}
+
+func TestRoutesBelowPrefix(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	mux := http.NewServeMux()
+	mux.Handle("/dyndns/", http.StripPrefix("/dyndns", server.Routes()))
+
+	req := httptest.NewRequest("GET", "/dyndns/api/status", nil)
+	req.SetBasicAuth("admin", "password")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the API below the prefix, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// clientRoute maps a zone name or hostname pattern to the client holding its API token
+type clientRoute struct {
+	pattern string
+	client  *Client
+}
+
+// ClientRouter selects the Hetzner API client for a hostname, so zones spread
+// across several projects or accounts can be served by one bridge
+type ClientRouter struct {
+	fallback *Client
+	routes   []clientRoute
+}
+
+// NewClientRouter creates a router using fallback for hostnames without a route, fallback may be nil
+func NewClientRouter(fallback *Client) *ClientRouter {
+	return &ClientRouter{fallback: fallback}
+}
+
+// Add routes hostnames matching pattern to client. A plain pattern like
+// "example.com" matches the zone and all its subdomains, patterns containing
+// "*" are matched as globs against the full hostname.
+func (r *ClientRouter) Add(pattern string, client *Client) {
+	r.routes = append(r.routes, clientRoute{pattern: strings.ToLower(pattern), client: client})
+}
+
+// ClientFor returns the client for hostname, preferring the most specific matching pattern
+func (r *ClientRouter) ClientFor(hostname string) (*Client, error) {
+	hostname = strings.ToLower(hostname)
+
+	var best *clientRoute
+	for i := range r.routes {
+		route := &r.routes[i]
+		if !matchesRoute(route.pattern, hostname) {
+			continue
+		}
+		if best == nil || len(route.pattern) > len(best.pattern) {
+			best = route
+		}
+	}
+
+	if best != nil {
+		return best.client, nil
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	return nil, fmt.Errorf("no API token configured for hostname: %s", hostname)
+}
+
+// Clients returns every distinct client known to the router
+func (r *ClientRouter) Clients() []*Client {
+	var clients []*Client
+	seen := map[*Client]bool{}
+	if r.fallback != nil {
+		clients = append(clients, r.fallback)
+		seen[r.fallback] = true
+	}
+	for _, route := range r.routes {
+		if !seen[route.client] {
+			clients = append(clients, route.client)
+			seen[route.client] = true
+		}
+	}
+	return clients
+}
+
+// matchesRoute reports whether hostname matches a zone name or glob pattern
+func matchesRoute(pattern, hostname string) bool {
+	if strings.Contains(pattern, "*") {
+		matched, err := path.Match(pattern, hostname)
+		return err == nil && matched
+	}
+	return hostname == pattern || strings.HasSuffix(hostname, "."+pattern)
+}
+
+// localDNSRoute maps a zone name or hostname pattern to a local DNS backend
+type localDNSRoute struct {
+	pattern string
+	backend LocalDNSBackend
+}
+
+// LocalDNSRouter selects the local DNS server mirroring a hostname, so e.g.
+// one subdomain can be served by Pi-hole and another by a BIND server
+type LocalDNSRouter struct {
+	fallback LocalDNSBackend
+	routes   []localDNSRoute
+}
+
+// NewLocalDNSRouter creates a router using fallback for hostnames without a route, fallback may be nil
+func NewLocalDNSRouter(fallback LocalDNSBackend) *LocalDNSRouter {
+	return &LocalDNSRouter{fallback: fallback}
+}
+
+// Add routes hostnames matching pattern to backend, patterns match like those of ClientRouter.Add
+func (r *LocalDNSRouter) Add(pattern string, backend LocalDNSBackend) {
+	r.routes = append(r.routes, localDNSRoute{pattern: strings.ToLower(pattern), backend: backend})
+}
+
+// BackendFor returns the backend for hostname, preferring the most specific matching pattern
+func (r *LocalDNSRouter) BackendFor(hostname string) (LocalDNSBackend, error) {
+	hostname = strings.ToLower(hostname)
+
+	var best *localDNSRoute
+	for i := range r.routes {
+		route := &r.routes[i]
+		if !matchesRoute(route.pattern, hostname) {
+			continue
+		}
+		if best == nil || len(route.pattern) > len(best.pattern) {
+			best = route
+		}
+	}
+
+	if best != nil {
+		return best.backend, nil
+	}
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+	return nil, fmt.Errorf("no local DNS server configured for hostname: %s", hostname)
+}
+
+// APIToken is a primary API token with an optional secondary token used for failover
+type APIToken struct {
+	Primary   string
+	Secondary string
+}
+
+// parseLocalDNSRoutes parses "pattern=backend" pairs separated by commas
+func parseLocalDNSRoutes(value string) (map[string]string, error) {
+	routes := map[string]string{}
+	for _, item := range splitList(value) {
+		pattern, backend, ok := strings.Cut(item, "=")
+		pattern, backend = strings.TrimSpace(pattern), strings.ToLower(strings.TrimSpace(backend))
+		if !ok || pattern == "" || !slices.Contains(localDNSBackends, backend) {
+			return nil, fmt.Errorf("invalid local DNS route %q, expected pattern=%s", item, strings.Join(localDNSBackends, "|"))
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid local DNS pattern %q: %w", pattern, err)
+		}
+		routes[pattern] = backend
+	}
+	return routes, nil
+}
+
+// parseZoneTokens parses "pattern=token" pairs separated by commas, a
+// secondary token can be appended as "pattern=primary|secondary"
+func parseZoneTokens(value string) (map[string]APIToken, error) {
+	tokens := map[string]APIToken{}
+	for _, item := range splitList(value) {
+		pattern, token, ok := strings.Cut(item, "=")
+		pattern = strings.TrimSpace(pattern)
+		primary, secondary, _ := strings.Cut(token, "|")
+		primary, secondary = strings.TrimSpace(primary), strings.TrimSpace(secondary)
+		if !ok || pattern == "" || primary == "" {
+			return nil, fmt.Errorf("invalid zone token mapping %q, expected pattern=token", item)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid zone pattern %q: %w", pattern, err)
+		}
+		tokens[pattern] = APIToken{Primary: primary, Secondary: secondary}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientRouterClientFor(t *testing.T) {
+	fallback := NewClient("fallback")
+	zoneA := NewClient("token-a")
+	zoneB := NewClient("token-b")
+	lab := NewClient("token-lab")
+
+	router := NewClientRouter(fallback)
+	router.Add("example.com", zoneA)
+	router.Add("Example.ORG", zoneB)
+	router.Add("*.lab.example.org", lab)
+
+	tests := []struct {
+		hostname string
+		expected *Client
+	}{
+		{"example.com", zoneA},
+		{"home.example.com", zoneA},
+		{"HOME.Example.com", zoneA},
+		{"home.example.org", zoneB},
+		{"nas.lab.example.org", lab},
+		{"notexample.com", fallback},
+		{"home.example.net", fallback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			client, err := router.ClientFor(tt.hostname)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if client != tt.expected {
+				t.Errorf("Expected client with token %s, got %s", tt.expected.APIKey, client.APIKey)
+			}
+		})
+	}
+}
+
+func TestClientRouterWithoutFallback(t *testing.T) {
+	router := NewClientRouter(nil)
+	router.Add("example.com", NewClient("token-a"))
+
+	if _, err := router.ClientFor("home.example.net"); err == nil {
+		t.Error("Expected error for hostname without token")
+	}
+	if len(router.Clients()) != 1 {
+		t.Errorf("Expected 1 client, got %d", len(router.Clients()))
+	}
+}
+
+func TestClientRouterClients(t *testing.T) {
+	fallback := NewClient("fallback")
+	shared := NewClient("shared")
+
+	router := NewClientRouter(fallback)
+	router.Add("example.com", shared)
+	router.Add("example.org", shared)
+
+	if clients := router.Clients(); len(clients) != 2 {
+		t.Errorf("Expected 2 distinct clients, got %d", len(clients))
+	}
+}
+
+func TestParseZoneTokens(t *testing.T) {
+	tests := []struct {
+		value       string
+		expected    map[string]APIToken
+		expectError bool
+	}{
+		{value: "", expected: map[string]APIToken{}},
+		{value: "example.com=a,*.lab.example.org=b", expected: map[string]APIToken{"example.com": {Primary: "a"}, "*.lab.example.org": {Primary: "b"}}},
+		{value: "example.com=a|a2", expected: map[string]APIToken{"example.com": {Primary: "a", Secondary: "a2"}}},
+		{value: "example.com", expectError: true},
+		{value: "example.com=", expectError: true},
+		{value: "example.com=|a2", expectError: true},
+		{value: "[example.com=a", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			tokens, err := parseZoneTokens(tt.value)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error but got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(tokens) != len(tt.expected) {
+				t.Fatalf("Expected %v, got %v", tt.expected, tokens)
+			}
+			for pattern, token := range tt.expected {
+				if tokens[pattern] != token {
+					t.Errorf("Expected %s=%+v, got %+v", pattern, token, tokens[pattern])
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateDNSRecordRoutesToZoneToken(t *testing.T) {
+	var fallbackWrites, zoneWrites []string
+	fallbackAPI := newOwnershipMockAPI(t, nil, &fallbackWrites)
+	defer fallbackAPI.Close()
+	zoneAPI := newOwnershipMockAPI(t, nil, &zoneWrites)
+	defer zoneAPI.Close()
+
+	fallback := NewClient("fallback")
+	fallback.BaseURL = fallbackAPI.URL
+	zoneClient := NewClient("zone-token")
+	zoneClient.BaseURL = zoneAPI.URL
+
+	server := NewDynDNSServer(fallback, "admin", "password", "8080")
+	server.router = NewClientRouter(fallback)
+	server.router.Add("example.com", zoneClient)
+
+	if err := server.updateDNSRecord("home.example.com", "1.2.3.4", "A"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(fallbackWrites) != 0 {
+		t.Errorf("Expected no writes with fallback token, got %v", fallbackWrites)
+	}
+	if strings.Join(zoneWrites, ";") != "POST A home" {
+		t.Errorf("Expected record to be created with zone token, got %v", zoneWrites)
+	}
+}
+
+func TestLocalDNSRouterBackendFor(t *testing.T) {
+	pihole := &recordingBackend{}
+	bind := &recordingBackend{}
+
+	router := NewLocalDNSRouter(nil)
+	router.Add("example.com", bind)
+	router.Add("*.lan.example.com", pihole)
+
+	for hostname, expected := range map[string]*recordingBackend{
+		"nas.example.com":    bind,
+		"tv.lan.example.com": pihole,
+		"TV.LAN.example.com": pihole,
+		"lan.example.com":    bind,
+		"www.example.com":    bind,
+	} {
+		backend, err := router.BackendFor(hostname)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", hostname, err)
+		}
+		if backend != LocalDNSBackend(expected) {
+			t.Errorf("Wrong backend for %s", hostname)
+		}
+	}
+	if _, err := router.BackendFor("home.example.net"); err == nil {
+		t.Error("Expected error for hostname without local DNS server")
+	}
+}
+
+func TestParseLocalDNSRoutes(t *testing.T) {
+	routes, err := parseLocalDNSRoutes("*.lan.example.com=PiHole, example.com=rfc2136")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if routes["*.lan.example.com"] != localDNSPihole || routes["example.com"] != localDNSRFC2136 {
+		t.Errorf("Unexpected routes: %v", routes)
+	}
+	for _, value := range []string{"example.com=unbound", "example.com", "[=pihole"} {
+		if _, err := parseLocalDNSRoutes(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
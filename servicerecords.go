@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// caaTags are the CAA property tags of RFC 8659
+var caaTags = []string{"issue", "issuewild", "iodef"}
+
+// serviceRecordRequest is the body of POST /api/records
+type serviceRecordRequest struct {
+	Hostname string `json:"hostname"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	TTL      int    `json:"ttl,omitempty"`
+}
+
+// serviceRecordResponse is returned by POST /api/records
+type serviceRecordResponse struct {
+	Hostname string `json:"hostname"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	// Created is false if the record already existed with this value
+	Created bool `json:"created"`
+}
+
+// isServiceRecordType reports whether recordType is managed through addServiceRecord
+func isServiceRecordType(recordType string) bool {
+	return recordType == "SRV" || recordType == "CAA"
+}
+
+// normalizeServiceRecord validates value for hostname and returns it in the
+// zone file form the API stores, e.g. `10 5 5060 sip.example.com.` for SRV
+// and `0 issue "letsencrypt.org"` for CAA
+func normalizeServiceRecord(hostname, recordType, value string) (string, error) {
+	switch recordType {
+	case "SRV":
+		return normalizeSRV(hostname, value)
+	case "CAA":
+		return normalizeCAA(value)
+	}
+	return "", fmt.Errorf("unsupported record type: %s", recordType)
+}
+
+// normalizeSRV validates "priority weight port target" and the _service._proto hostname
+func normalizeSRV(hostname, value string) (string, error) {
+	labels := strings.Split(hostname, ".")
+	if len(labels) < 3 || !strings.HasPrefix(labels[0], "_") || !strings.HasPrefix(labels[1], "_") {
+		return "", fmt.Errorf("SRV hostname must start with _service._proto, got %q", hostname)
+	}
+
+	fields := strings.Fields(value)
+	if len(fields) != 4 {
+		return "", fmt.Errorf("SRV value must be \"priority weight port target\", got %q", value)
+	}
+	for i, name := range []string{"priority", "weight", "port"} {
+		if _, err := strconv.ParseUint(fields[i], 10, 16); err != nil {
+			return "", fmt.Errorf("SRV %s must be between 0 and 65535, got %q", name, fields[i])
+		}
+	}
+
+	target := strings.ToLower(fields[3])
+	if target != "." {
+		if !isValidDomainName(strings.TrimSuffix(target, ".")) {
+			return "", fmt.Errorf("invalid SRV target %q", fields[3])
+		}
+		target = strings.TrimSuffix(target, ".") + "."
+	}
+	return strings.Join(append(fields[:3], target), " "), nil
+}
+
+// normalizeCAA validates "flags tag value", quoting the value if needed
+func normalizeCAA(value string) (string, error) {
+	flagsField, rest, _ := strings.Cut(strings.TrimSpace(value), " ")
+	tag, property, _ := strings.Cut(strings.TrimSpace(rest), " ")
+	property = strings.TrimSpace(property)
+
+	if _, err := strconv.ParseUint(flagsField, 10, 8); err != nil {
+		return "", fmt.Errorf("CAA flags must be between 0 and 255, got %q", flagsField)
+	}
+	tag = strings.ToLower(tag)
+	if !slices.Contains(caaTags, tag) {
+		return "", fmt.Errorf("CAA tag must be one of %s, got %q", strings.Join(caaTags, ", "), tag)
+	}
+
+	if len(property) >= 2 && strings.HasPrefix(property, `"`) && strings.HasSuffix(property, `"`) {
+		property = property[1 : len(property)-1]
+	}
+	if strings.Contains(property, `"`) {
+		return "", fmt.Errorf("CAA value must not contain quotes, got %q", property)
+	}
+
+	switch tag {
+	case "iodef":
+		if !strings.HasPrefix(property, "mailto:") && !strings.HasPrefix(property, "https://") && !strings.HasPrefix(property, "http://") {
+			return "", fmt.Errorf("CAA iodef value must be a mailto: or http(s) URL, got %q", property)
+		}
+	default:
+		// An empty issuer, or just ";", forbids issuance
+		issuer, _, _ := strings.Cut(property, ";")
+		if issuer = strings.TrimSpace(issuer); issuer != "" && !isValidDomainName(issuer) {
+			return "", fmt.Errorf("invalid CAA issuer %q", issuer)
+		}
+	}
+	return fmt.Sprintf("%s %s %q", flagsField, tag, property), nil
+}
+
+// isValidDomainName checks that name consists of valid hostname labels
+func isValidDomainName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// addServiceRecord creates an SRV or CAA record of hostname unless one with the
+// same value exists. Several records of these types may share a name, so other
+// values are kept. With ownership markers, the records of a name and type are
+// only added to if the bridge owns them, and a single marker covers them all.
+// It returns the normalized value and whether it was created.
+func (s *DynDNSServer) addServiceRecord(hostname, recordType, value string, ttl int) (string, bool, error) {
+	value, err := normalizeServiceRecord(hostname, recordType, value)
+	if err != nil {
+		return "", false, err
+	}
+
+	client, err := s.clientFor(hostname)
+	if err != nil {
+		return "", false, err
+	}
+	lookup, err := findRecord(client, hostname, recordType)
+	if err != nil {
+		return "", false, err
+	}
+	for _, record := range lookup.Records {
+		if record.Name == lookup.Name && record.Type == recordType && record.Value == value {
+			return value, false, nil
+		}
+	}
+	if err := s.checkWritable(lookup, hostname, recordType); err != nil {
+		return "", false, err
+	}
+
+	if ttl == 0 {
+		ttl = defaultRecordTTL
+	}
+	_, err = client.CreateRecord(CreateRecordRequest{
+		Type:   recordType,
+		Name:   lookup.Name,
+		Value:  value,
+		TTL:    &ttl,
+		ZoneID: lookup.Zone.ID,
+	})
+	if err != nil {
+		return "", false, fmt.Errorf("failed to create record: %w", err)
+	}
+	log.Printf("Created record %s %s %s", recordType, hostname, value)
+	if lookup.Existing == nil && s.ownerID != "" {
+		if err := s.createOwnershipRecord(client, lookup.Zone.ID, hostname, lookup.Name, recordType); err != nil {
+			return "", false, err
+		}
+	}
+	return value, true, nil
+}
+
+// removeServiceRecords deletes the SRV or CAA records of a whitelisted hostname,
+// only the one holding value if it is not empty, and returns how many were
+// deleted. With ownership markers, only records owned by the bridge are deleted,
+// and the marker goes with the last of them.
+func (s *DynDNSServer) removeServiceRecords(hostname, recordType, value string) (int, error) {
+	if !s.isDeletable(hostname) {
+		return 0, errNotDeletable
+	}
+	if value != "" {
+		normalized, err := normalizeServiceRecord(hostname, recordType, value)
+		if err != nil {
+			return 0, err
+		}
+		value = normalized
+	}
+
+	client, err := s.clientFor(hostname)
+	if err != nil {
+		return 0, err
+	}
+	lookup, err := findRecord(client, hostname, recordType)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.checkWritable(lookup, hostname, recordType); err != nil {
+		return 0, err
+	}
+
+	var matching []DNSRecord
+	remaining := 0
+	for _, record := range lookup.Records {
+		if record.Name != lookup.Name || record.Type != recordType {
+			continue
+		}
+		if value != "" && record.Value != value {
+			remaining++
+			continue
+		}
+		matching = append(matching, record)
+	}
+
+	deleted := 0
+	for i, record := range matching {
+		if s.ownerID != "" && remaining == 0 && i == len(matching)-1 {
+			err = s.deleteOwnedRecord(client, lookup.Records, record)
+		} else if err = client.DeleteRecord(record.ID); err != nil {
+			err = fmt.Errorf("failed to delete record: %w", err)
+		}
+		if err != nil {
+			return deleted, err
+		}
+		log.Printf("Deleted record %s %s %s", recordType, hostname, record.Value)
+		deleted++
+	}
+	return deleted, nil
+}
+
+// handleAddServiceRecord creates the SRV or CAA record described by the JSON body
+func (s *DynDNSServer) handleAddServiceRecord(w http.ResponseWriter, r *http.Request) {
+	var req serviceRecordRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		httpError(w, r, "Invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	hostname := strings.ToLower(strings.TrimSuffix(req.Hostname, "."))
+	recordType := strings.ToUpper(req.Type)
+	if hostname == "" {
+		httpErrorDetails(w, r, "Missing hostname", http.StatusBadRequest, map[string]string{"field": "hostname"})
+		return
+	}
+	if !isServiceRecordType(recordType) {
+		httpError(w, r, "unsupported record type: "+req.Type, http.StatusBadRequest)
+		return
+	}
+	if req.TTL < 0 {
+		httpError(w, r, "TTL must not be negative", http.StatusBadRequest)
+		return
+	}
+	if _, err := normalizeServiceRecord(hostname, recordType, req.Value); err != nil {
+		httpError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	value, created, err := s.addServiceRecord(hostname, recordType, req.Value, req.TTL)
+	if err != nil {
+		log.Printf("Failed to add %s record of %s: %v", recordType, hostname, err)
+		httpError(w, r, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(serviceRecordResponse{Hostname: hostname, Type: recordType, Value: value, Created: created})
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeServiceRecord(t *testing.T) {
+	tests := []struct {
+		name          string
+		hostname      string
+		recordType    string
+		value         string
+		expected      string
+		errorContains string
+	}{
+		{name: "SRV", hostname: "_sip._tcp.example.com", recordType: "SRV", value: "10 5 5060 SIP.example.com", expected: "10 5 5060 sip.example.com."},
+		{name: "SRV without service", hostname: "_sip._tcp.example.com", recordType: "SRV", value: "0 0 0 .", expected: "0 0 0 ."},
+		{name: "SRV hostname without labels", hostname: "sip.example.com", recordType: "SRV", value: "10 5 5060 sip.example.com", errorContains: "_service._proto"},
+		{name: "SRV missing target", hostname: "_sip._tcp.example.com", recordType: "SRV", value: "10 5 5060", errorContains: "priority weight port target"},
+		{name: "SRV port out of range", hostname: "_sip._tcp.example.com", recordType: "SRV", value: "10 5 70000 sip.example.com", errorContains: "port"},
+		{name: "SRV negative weight", hostname: "_sip._tcp.example.com", recordType: "SRV", value: "10 -1 5060 sip.example.com", errorContains: "weight"},
+		{name: "SRV invalid target", hostname: "_sip._tcp.example.com", recordType: "SRV", value: "10 5 5060 sip..example.com", errorContains: "target"},
+		{name: "CAA issue", hostname: "example.com", recordType: "CAA", value: "0 issue letsencrypt.org", expected: `0 issue "letsencrypt.org"`},
+		{name: "CAA quoted issue with parameters", hostname: "example.com", recordType: "CAA", value: `128 ISSUEWILD "letsencrypt.org; validationmethods=dns-01"`, expected: `128 issuewild "letsencrypt.org; validationmethods=dns-01"`},
+		{name: "CAA forbid issuance", hostname: "example.com", recordType: "CAA", value: `0 issue ";"`, expected: `0 issue ";"`},
+		{name: "CAA iodef", hostname: "example.com", recordType: "CAA", value: "0 iodef mailto:security@example.com", expected: `0 iodef "mailto:security@example.com"`},
+		{name: "CAA iodef without URL", hostname: "example.com", recordType: "CAA", value: "0 iodef security@example.com", errorContains: "iodef"},
+		{name: "CAA flags out of range", hostname: "example.com", recordType: "CAA", value: "256 issue letsencrypt.org", errorContains: "flags"},
+		{name: "CAA unknown tag", hostname: "example.com", recordType: "CAA", value: "0 issuer letsencrypt.org", errorContains: "tag"},
+		{name: "CAA invalid issuer", hostname: "example.com", recordType: "CAA", value: "0 issue lets encrypt", errorContains: "issuer"},
+		{name: "unsupported type", hostname: "example.com", recordType: "MX", value: "10 mail.example.com", errorContains: "unsupported"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value, err := normalizeServiceRecord(tt.hostname, tt.recordType, tt.value)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestHandleAddServiceRecord(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "rec1", Type: "CAA", Name: "@", Value: `0 issue "letsencrypt.org"`},
+	}
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedWrites []string
+	}{
+		{
+			name:           "create SRV record",
+			body:           `{"hostname":"_sip._tcp.example.com","type":"srv","value":"10 5 5060 sip.example.com"}`,
+			expectedStatus: http.StatusCreated,
+			expectedWrites: []string{"POST SRV _sip._tcp"},
+		},
+		{
+			name:           "add second CAA record",
+			body:           `{"hostname":"example.com","type":"CAA","value":"0 iodef mailto:security@example.com"}`,
+			expectedStatus: http.StatusCreated,
+			expectedWrites: []string{"POST CAA @"},
+		},
+		{
+			name:           "existing CAA record",
+			body:           `{"hostname":"example.com","type":"CAA","value":"0 issue letsencrypt.org"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid value",
+			body:           `{"hostname":"_sip._tcp.example.com","type":"SRV","value":"10 5 5060"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unsupported type",
+			body:           `{"hostname":"example.com","type":"A","value":"1.1.1.1"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "invalid JSON",
+			body:           `{"hostname":`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+
+			req := httptest.NewRequest("POST", "/api/records", strings.NewReader(tt.body))
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleRecords(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+			if w.Code == http.StatusCreated {
+				var response serviceRecordResponse
+				if err := json.NewDecoder(w.Body).Decode(&response); err != nil || !response.Created {
+					t.Errorf("Unexpected response %+v: %v", response, err)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteServiceRecords(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "rec1", Type: "CAA", Name: "@", Value: `0 issue "letsencrypt.org"`},
+		{ID: "rec2", Type: "CAA", Name: "@", Value: `0 iodef "mailto:security@example.com"`},
+		{ID: "rec3", Type: "A", Name: "@", Value: "1.1.1.1"},
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedWrites []string
+	}{
+		{
+			name:           "delete all CAA records",
+			query:          "hostname=example.com&type=CAA",
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"DELETE rec1", "DELETE rec2"},
+		},
+		{
+			name:           "delete single CAA value",
+			query:          "hostname=example.com&type=caa&value=0+issue+letsencrypt.org",
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"DELETE rec1"},
+		},
+		{
+			name:           "invalid value",
+			query:          "hostname=example.com&type=CAA&value=0+issuer+x",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "hostname not whitelisted",
+			query:          "hostname=other.com&type=CAA",
+			expectedStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.deletable = []string{"example.com"}
+
+			req := httptest.NewRequest("DELETE", "/api/records?"+tt.query, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleRecords(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+		})
+	}
+}
+
+func TestRunCommandRecordAdd(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+
+	var out bytes.Buffer
+	if err := runCommand(server, []string{"record", "add", "_sip._tcp.example.com", "SRV", "10", "5", "5060", "sip.example.com"}, &out); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Join(writes, ";") != "POST SRV _sip._tcp" {
+		t.Errorf("Expected record to be created, got %v", writes)
+	}
+	if !strings.Contains(out.String(), "Created SRV record 10 5 5060 sip.example.com. of _sip._tcp.example.com") {
+		t.Errorf("Unexpected output: %q", out.String())
+	}
+}
+
+func TestServiceRecordOwnership(t *testing.T) {
+	records := []DNSRecord{
+		{ID: "caa1", Type: "CAA", Name: "@", Value: `0 issue "letsencrypt.org"`},
+		{ID: "srv1", Type: "SRV", Name: "_sip._tcp", Value: "10 5 5060 sip.example.com."},
+		{ID: "srv2", Type: "SRV", Name: "_sip._tcp", Value: "20 5 5060 sip2.example.com."},
+		{ID: "marker1", Type: "TXT", Name: "_dyndns-srv._sip._tcp", Value: ownershipValue("bridge1")},
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+		expectedWrites []string
+	}{
+		{
+			name:           "add to foreign records",
+			method:         "POST",
+			body:           `{"hostname":"example.com","type":"CAA","value":"0 iodef mailto:security@example.com"}`,
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "add new records with marker",
+			method:         "POST",
+			body:           `{"hostname":"_xmpp._tcp.example.com","type":"SRV","value":"10 5 5222 xmpp.example.com"}`,
+			expectedStatus: http.StatusCreated,
+			expectedWrites: []string{"POST SRV _xmpp._tcp", "POST TXT _dyndns-srv._xmpp._tcp"},
+		},
+		{
+			name:           "add to owned records",
+			method:         "POST",
+			body:           `{"hostname":"_sip._tcp.example.com","type":"SRV","value":"30 5 5060 sip3.example.com"}`,
+			expectedStatus: http.StatusCreated,
+			expectedWrites: []string{"POST SRV _sip._tcp"},
+		},
+		{
+			name:           "delete foreign records",
+			method:         "DELETE",
+			target:         "?hostname=example.com&type=CAA",
+			expectedStatus: http.StatusBadGateway,
+		},
+		{
+			name:           "delete one owned record keeps the marker",
+			method:         "DELETE",
+			target:         "?hostname=_sip._tcp.example.com&type=SRV&value=10+5+5060+sip.example.com",
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"DELETE srv1"},
+		},
+		{
+			name:           "delete all owned records with the marker",
+			method:         "DELETE",
+			target:         "?hostname=_sip._tcp.example.com&type=SRV",
+			expectedStatus: http.StatusOK,
+			expectedWrites: []string{"DELETE srv1", "DELETE srv2", "DELETE marker1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.ownerID = "bridge1"
+			server.deletable = []string{"example.com", "_sip._tcp.example.com"}
+
+			req := httptest.NewRequest(tt.method, "/api/records"+tt.target, strings.NewReader(tt.body))
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleRecords(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+		})
+	}
+}
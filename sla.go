@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// slaBucket stores the availability samples per hostname and month
+const slaBucket = "sla"
+
+// slaMonthFormat names the months of the availability report, e.g. 2026-03
+const slaMonthFormat = "2006-01"
+
+// slaCounters are the samples of a hostname in one month
+type slaCounters struct {
+	Samples int `json:"samples"`
+	Up      int `json:"up"`
+	// UpdateFailures are samples while the last update of a record had failed,
+	// ProbeFailures samples with an unreachable port check
+	UpdateFailures  int     `json:"update_failures"`
+	ProbeFailures   int     `json:"probe_failures"`
+	DowntimeSeconds float64 `json:"downtime_seconds"`
+}
+
+// SLAProber samples the managed hostnames periodically. A hostname is up while
+// the last update of each of its records succeeded and, if port checks match
+// it, the ports are reachable on the current addresses.
+type SLAProber struct {
+	server *DynDNSServer
+	// checker probes the ports of DYNDNS_PORT_CHECKS, nil only samples the updates
+	checker  *PortChecker
+	interval time.Duration
+}
+
+// NewSLAProber creates a prober taking a sample every interval
+func NewSLAProber(server *DynDNSServer, checker *PortChecker, interval time.Duration) *SLAProber {
+	return &SLAProber{server: server, checker: checker, interval: interval}
+}
+
+// Run takes a sample of every hostname each interval until stop is closed
+func (p *SLAProber) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.Probe()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Probe takes a sample of every hostname an update was received for.
+// Hostnames without any update yet are not counted.
+func (p *SLAProber) Probe() {
+	records := map[string][]recordStatus{}
+	for _, status := range p.server.status.Snapshot() {
+		if !strings.HasPrefix(status.Hostname, "*.") {
+			records[status.Hostname] = append(records[status.Hostname], status)
+		}
+	}
+	month := p.server.clock.Now().UTC().Format(slaMonthFormat)
+	for hostname, statuses := range records {
+		updateOK := true
+		for _, status := range statuses {
+			if status.State == recordStateError {
+				updateOK = false
+			}
+		}
+		probeOK := p.probe(hostname, statuses)
+
+		counters, err := p.server.slaCounters(hostname, month)
+		if err != nil {
+			log.Printf("Failed to load availability of %s: %v", hostname, err)
+			continue
+		}
+		counters.Samples++
+		switch {
+		case updateOK && probeOK:
+			counters.Up++
+		case !updateOK:
+			counters.UpdateFailures++
+			counters.DowntimeSeconds += p.interval.Seconds()
+		default:
+			counters.ProbeFailures++
+			counters.DowntimeSeconds += p.interval.Seconds()
+		}
+		data, err := json.Marshal(counters)
+		if err != nil {
+			continue
+		}
+		if err := p.server.store.Put(slaBucket, hostname+"/"+month, data); err != nil {
+			log.Printf("Failed to store availability of %s: %v", hostname, err)
+		}
+	}
+}
+
+// probe reports whether the ports checked for hostname are reachable on all its addresses
+func (p *SLAProber) probe(hostname string, statuses []recordStatus) bool {
+	if p.checker == nil {
+		return true
+	}
+	for _, check := range p.checker.checks {
+		if !matchesRoute(check.Pattern, hostname) {
+			continue
+		}
+		for _, status := range statuses {
+			if (status.Type != "A" && status.Type != "AAAA") || status.Value == "" {
+				continue
+			}
+			if err := p.checker.check(status.Value, check.Port); err != nil {
+				log.Printf("Availability probe: port %d of %s (%s) is not reachable: %v", check.Port, hostname, status.Value, err)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// slaCounters returns the samples of hostname in month
+func (s *DynDNSServer) slaCounters(hostname, month string) (slaCounters, error) {
+	var counters slaCounters
+	data, ok, err := s.store.Get(slaBucket, hostname+"/"+month)
+	if err != nil || !ok {
+		return counters, err
+	}
+	err = json.Unmarshal(data, &counters)
+	return counters, err
+}
+
+// slaEntry is the availability of a hostname in the report
+type slaEntry struct {
+	Hostname string `json:"hostname"`
+	// Availability is the share of samples the hostname was up in percent,
+	// missing without samples
+	Availability *float64 `json:"availability_percent,omitempty"`
+	slaCounters
+	// Changes counts the value changes of the hostname's records in the month
+	Changes    int        `json:"changes"`
+	LastChange *time.Time `json:"last_change,omitempty"`
+}
+
+// slaReport is returned by GET /api/sla
+type slaReport struct {
+	Month       string     `json:"month"`
+	GeneratedAt time.Time  `json:"generated_at"`
+	Hostnames   []slaEntry `json:"hostnames"`
+}
+
+// slaReport builds the availability report of month for the sampled and the configured hostnames
+func (s *DynDNSServer) slaReport(month time.Time) (slaReport, error) {
+	name := month.Format(slaMonthFormat)
+	report := slaReport{Month: name, GeneratedAt: s.clock.Now().UTC(), Hostnames: []slaEntry{}}
+
+	stored, err := s.store.List(slaBucket)
+	if err != nil {
+		return report, err
+	}
+	entries := map[string]*slaEntry{}
+	for key, data := range stored {
+		hostname, keyMonth, _ := strings.Cut(key, "/")
+		if keyMonth != name {
+			continue
+		}
+		entry := &slaEntry{Hostname: hostname}
+		if err := json.Unmarshal(data, &entry.slaCounters); err != nil {
+			return report, err
+		}
+		if entry.Samples > 0 {
+			availability := float64(entry.Up) * 100 / float64(entry.Samples)
+			entry.Availability = &availability
+		}
+		entries[hostname] = entry
+	}
+	for _, hostname := range s.hostnames {
+		if entries[hostname] == nil {
+			entries[hostname] = &slaEntry{Hostname: hostname}
+		}
+	}
+
+	end := month.AddDate(0, 1, 0)
+	for hostname, entry := range entries {
+		history, err := s.recordHistory(hostname, "")
+		if err != nil {
+			return report, err
+		}
+		for _, change := range history {
+			if change.Time.Before(month) || !change.Time.Before(end) {
+				continue
+			}
+			entry.Changes++
+			entry.LastChange = &change.Time
+		}
+		report.Hostnames = append(report.Hostnames, *entry)
+	}
+	sort.Slice(report.Hostnames, func(i, j int) bool { return report.Hostnames[i].Hostname < report.Hostnames[j].Hostname })
+	return report, nil
+}
+
+// slaPage is the availability report with the links of its HTML page
+type slaPage struct {
+	slaReport
+	Base     string
+	Previous string
+	Next     string
+}
+
+// slaTemplate renders the availability report as a page for the browser
+var slaTemplate = template.Must(template.New("sla").Funcs(template.FuncMap{
+	"percent": func(value *float64) string {
+		if value == nil {
+			return "no data"
+		}
+		return fmt.Sprintf("%.3f %%", *value)
+	},
+	"duration": func(seconds float64) string {
+		return (time.Duration(seconds) * time.Second).String()
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Availability {{.Month}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.3em 1em; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+</style>
+</head>
+<body>
+<h1>Availability {{.Month}}</h1>
+<p><a href="{{.Base}}/api/sla?month={{.Previous}}&amp;format=html">&larr; {{.Previous}}</a> | <a href="{{.Base}}/api/sla?month={{.Next}}&amp;format=html">{{.Next}} &rarr;</a> | <a href="{{.Base}}/api/sla?month={{.Month}}">JSON</a></p>
+<table>
+<tr><th>Hostname</th><th>Availability</th><th>Downtime</th><th>Update failures</th><th>Probe failures</th><th>Address changes</th><th>Last change</th></tr>
+{{- range .Hostnames}}
+<tr><td>{{.Hostname}}</td><td>{{percent .Availability}}</td><td>{{duration .DowntimeSeconds}}</td><td>{{.UpdateFailures}}</td><td>{{.ProbeFailures}}</td><td>{{.Changes}}</td><td>{{with .LastChange}}{{.Format "2006-01-02 15:04"}}{{end}}</td></tr>
+{{- end}}
+</table>
+<p>Generated {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}</p>
+</body>
+</html>
+`))
+
+// handleSLA serves GET /api/sla?month=2026-03, the availability report of a
+// month as JSON or, with format=html, as a page
+func (s *DynDNSServer) handleSLA(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, authScopeAdmin) {
+		return
+	}
+
+	month := s.clock.Now().UTC()
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if value := r.URL.Query().Get("month"); value != "" {
+		parsed, err := time.Parse(slaMonthFormat, value)
+		if err != nil {
+			httpErrorDetails(w, r, fmt.Sprintf("Invalid month %q (expected YYYY-MM)", value), http.StatusBadRequest, map[string]string{"parameter": "month"})
+			return
+		}
+		month = parsed
+	}
+
+	report, err := s.slaReport(month)
+	if err != nil {
+		log.Printf("Failed to build availability report: %v", err)
+		httpError(w, r, "Failed to build availability report", http.StatusInternalServerError)
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	case "html":
+		// Links keep the path prefix the page was reached at
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		slaTemplate.Execute(w, slaPage{
+			slaReport: report,
+			Base:      requestBasePath(r, s.basePath),
+			Previous:  month.AddDate(0, -1, 0).Format(slaMonthFormat),
+			Next:      month.AddDate(0, 1, 0).Format(slaMonthFormat),
+		})
+	default:
+		httpError(w, r, fmt.Sprintf("Unsupported format %q (expected json or html)", format), http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSLAProber(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.hostnames = []string{"idle.example.com"}
+	checker := NewPortChecker(server, []PortCheck{{Pattern: "nas.example.com", Port: 443}}, "", 0, time.Second)
+	reachable := true
+	checker.dial = func(network, address string, timeout time.Duration) (net.Conn, error) {
+		if !reachable {
+			return nil, errors.New("connection refused")
+		}
+		client, peer := net.Pipe()
+		peer.Close()
+		return client, nil
+	}
+	prober := NewSLAProber(server, checker, 5*time.Minute)
+	server.SetClock(newFakeClock(time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)))
+
+	server.status.Record("home.example.com", "A", "203.0.113.7", recordStateOK, nil)
+	server.status.Record("nas.example.com", "A", "203.0.113.8", recordStateOK, nil)
+	server.status.Record("*.home.example.com", "A", "203.0.113.7", recordStateOK, nil)
+	prober.Probe()
+	reachable = false
+	server.status.Record("home.example.com", "AAAA", "", recordStateError, errors.New("API unavailable"))
+	prober.Probe()
+	prober.Probe()
+
+	report, err := server.slaReport(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Month != "2026-03" || len(report.Hostnames) != 3 {
+		t.Fatalf("Expected three hostnames in 2026-03, got %+v", report)
+	}
+
+	expected := []struct {
+		hostname     string
+		availability float64
+		counters     slaCounters
+	}{
+		{"home.example.com", 100.0 / 3, slaCounters{Samples: 3, Up: 1, UpdateFailures: 2, DowntimeSeconds: 600}},
+		{"idle.example.com", -1, slaCounters{}},
+		{"nas.example.com", 100.0 / 3, slaCounters{Samples: 3, Up: 1, ProbeFailures: 2, DowntimeSeconds: 600}},
+	}
+	for i, want := range expected {
+		entry := report.Hostnames[i]
+		if entry.Hostname != want.hostname || entry.slaCounters != want.counters {
+			t.Errorf("Expected %s with %+v, got %+v", want.hostname, want.counters, entry)
+		}
+		if want.availability < 0 {
+			if entry.Availability != nil {
+				t.Errorf("Expected no availability for %s, got %v", entry.Hostname, *entry.Availability)
+			}
+		} else if entry.Availability == nil || *entry.Availability != want.availability {
+			t.Errorf("Expected availability %v for %s, got %v", want.availability, entry.Hostname, entry.Availability)
+		}
+	}
+
+	if report, _ := server.slaReport(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)); len(report.Hostnames) != 1 {
+		t.Errorf("Expected only the configured hostname in 2026-04, got %+v", report.Hostnames)
+	}
+}
+
+func TestHandleSLA(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.hostnames = []string{"home.example.com"}
+	server.recordChange("home.example.com", "A", "203.0.113.7", "203.0.113.9", nil)
+
+	tests := []struct {
+		name                string
+		query               string
+		expectedStatus      int
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			name:                "json",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `"changes":1`,
+		},
+		{
+			name:                "html",
+			query:               "?format=html",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "text/html; charset=utf-8",
+			expectedBody:        "<td>home.example.com</td><td>no data</td>",
+		},
+		{
+			name:                "previous month",
+			query:               "?month=2020-01",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/json",
+			expectedBody:        `"changes":0`,
+		},
+		{name: "invalid month", query: "?month=March", expectedStatus: http.StatusBadRequest},
+		{name: "invalid format", query: "?format=pdf", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/sla"+tt.query, nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleSLA(w, req)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if tt.expectedContentType != "" && w.Header().Get("Content-Type") != tt.expectedContentType {
+				t.Errorf("Expected content type %s, got %s", tt.expectedContentType, w.Header().Get("Content-Type"))
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("Expected body to contain %q, got %s", tt.expectedBody, w.Body.String())
+			}
+			if tt.expectedContentType == "application/json" {
+				var report slaReport
+				if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+					t.Errorf("Invalid JSON: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record states reported by the status endpoint
+const (
+	recordStateOK     = "ok"
+	recordStateQueued = "queued"
+	recordStateError  = "error"
+)
+
+// recordStatus is the last known state of a hostname and record type
+type recordStatus struct {
+	Hostname    string     `json:"hostname"`
+	Type        string     `json:"type"`
+	Value       string     `json:"value,omitempty"`
+	State       string     `json:"state"`
+	LastUpdate  *time.Time `json:"last_update,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	LastErrorAt *time.Time `json:"last_error_at,omitempty"`
+	// Updates and Failures count the successful and failed updates since startup
+	Updates  int `json:"updates"`
+	Failures int `json:"failures"`
+	// Annotation is the metadata last stored in the ownership marker, see DYNDNS_ANNOTATIONS
+	Annotation *recordAnnotation `json:"annotation,omitempty"`
+	// Churn is set once the value of the record changed, see DYNDNS_FLAP_THRESHOLD
+	Churn *recordChurn `json:"churn,omitempty"`
+	// Members are the values by source credential of a round-robin record, see DYNDNS_ROUND_ROBIN
+	Members map[string]string `json:"members,omitempty"`
+	// Backup is the failover state of a record with a backup address, see DYNDNS_BACKUP_IPS
+	Backup *backupState `json:"backup,omitempty"`
+}
+
+// statusResponse is the stable JSON schema of /api/status
+type statusResponse struct {
+	Status     string         `json:"status"`
+	Timestamp  string         `json:"timestamp"`
+	QueueDepth int            `json:"queue_depth"`
+	Errors     int            `json:"errors"`
+	Records    []recordStatus `json:"records"`
+	// APIUnavailableSince is set while the Hetzner DNS API cannot be reached, records may then be stale
+	APIUnavailableSince *time.Time `json:"api_unavailable_since,omitempty"`
+	// MaintenanceSince is set while maintenance mode holds back writes, see /api/maintenance
+	MaintenanceSince *time.Time `json:"maintenance_since,omitempty"`
+	// DNSSEC lists the signing state of the zones seen so far if DYNDNS_DNSSEC is enabled
+	DNSSEC []zoneDNSSEC `json:"dnssec,omitempty"`
+}
+
+// statusTracker keeps the last known state per hostname and record type
+type statusTracker struct {
+	now     func() time.Time
+	mu      sync.Mutex
+	records map[string]*recordStatus
+}
+
+// newStatusTracker creates an empty status tracker
+func newStatusTracker() *statusTracker {
+	return &statusTracker{now: time.Now, records: make(map[string]*recordStatus)}
+}
+
+// Record stores the outcome of an update of hostname and recordType to value
+func (t *statusTracker) Record(hostname, recordType, value, state string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := hostname + "/" + recordType
+	status, ok := t.records[key]
+	if !ok {
+		status = &recordStatus{Hostname: hostname, Type: recordType}
+		t.records[key] = status
+	}
+
+	now := t.now().UTC()
+	status.State = state
+	if err != nil {
+		status.LastError = err.Error()
+		status.LastErrorAt = &now
+		status.Failures++
+		return
+	}
+	if state == recordStateOK {
+		status.Value = value
+		status.LastUpdate = &now
+		status.Updates++
+	}
+}
+
+// Snapshot returns a copy of all record states sorted by hostname and type
+func (t *statusTracker) Snapshot() []recordStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make([]recordStatus, 0, len(t.records))
+	for _, status := range t.records {
+		result = append(result, *status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Hostname != result[j].Hostname {
+			return result[i].Hostname < result[j].Hostname
+		}
+		return result[i].Type < result[j].Type
+	})
+	return result
+}
+
+// handleStatus serves the current state of all hostnames as JSON
+func (s *DynDNSServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, authScopeAdmin) {
+		return
+	}
+
+	response := s.currentStatus()
+	// The timestamp changes every second, the ETag only when the state does
+	untimed := response
+	untimed.Timestamp = ""
+	w.Header().Set("ETag", valueETag(untimed))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// currentStatus collects the state of all hostnames reported by the status API
+func (s *DynDNSServer) currentStatus() statusResponse {
+	response := statusResponse{
+		Status:    "ok",
+		Timestamp: s.now().UTC().Format(time.RFC3339),
+		Records:   s.status.Snapshot(),
+	}
+	if s.limiter != nil {
+		response.QueueDepth = s.limiter.Pending()
+	}
+	if s.retries != nil {
+		response.QueueDepth += s.retries.Len()
+	}
+	for i, record := range response.Records {
+		if record.State == recordStateError {
+			response.Errors++
+		}
+		if s.annotations != nil {
+			response.Records[i].Annotation = s.annotations.Written(record.Hostname, record.Type)
+		}
+		response.Records[i].Churn = s.churn.Stats(record.Hostname, record.Type)
+		response.Records[i].Backup = s.backups.Status(record.Hostname, record.Type)
+		if s.isRoundRobin(record.Hostname) {
+			response.Records[i].Members, _ = s.members(record.Hostname, record.Type)
+		}
+	}
+	if since := s.health.UnavailableSince(); !since.IsZero() {
+		response.APIUnavailableSince = &since
+	}
+	response.MaintenanceSince = s.maintenance.Snapshot().Since
+	if s.dnssec != nil {
+		response.DNSSEC = s.dnssec.Status()
+	}
+	if response.Errors > 0 || response.APIUnavailableSince != nil {
+		response.Status = "degraded"
+	}
+	return response
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatusTracker(t *testing.T) {
+	tracker := newStatusTracker()
+	tracker.Record("nas.example.com", "A", "1.2.3.4", recordStateOK, nil)
+	tracker.Record("home.example.com", "AAAA", "2001:db8::1", recordStateOK, nil)
+	tracker.Record("home.example.com", "A", "1.2.3.4", recordStateOK, nil)
+	tracker.Record("home.example.com", "A", "1.2.3.5", recordStateError, errors.New("API error"))
+
+	snapshot := tracker.Snapshot()
+	if len(snapshot) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(snapshot))
+	}
+
+	first := snapshot[0]
+	if first.Hostname != "home.example.com" || first.Type != "A" {
+		t.Errorf("Expected records to be sorted, got %+v", first)
+	}
+	// A failed update keeps the last value that was successfully written
+	if first.State != recordStateError || first.Value != "1.2.3.4" || first.LastError != "API error" {
+		t.Errorf("Unexpected error state: %+v", first)
+	}
+	if first.LastUpdate == nil || first.LastErrorAt == nil {
+		t.Errorf("Expected timestamps to be set: %+v", first)
+	}
+}
+
+func TestHandleStatus(t *testing.T) {
+	client := NewClient("test-api-key")
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.limiter = NewRateLimiter(time.Hour)
+	server.status.Record("home.example.com", "A", "1.2.3.4", recordStateOK, nil)
+	server.status.Record("nas.example.com", "A", "1.2.3.4", recordStateError, errors.New("no zone found"))
+
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	w := httptest.NewRecorder()
+	server.handleStatus(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status 401 without credentials, got %d", w.Code)
+	}
+
+	req.SetBasicAuth("admin", "password")
+	w = httptest.NewRecorder()
+	server.handleStatus(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected JSON content type, got %s", w.Header().Get("Content-Type"))
+	}
+
+	var response statusResponse
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if response.Status != "degraded" || response.Errors != 1 || len(response.Records) != 2 {
+		t.Errorf("Unexpected response: %+v", response)
+	}
+	if response.QueueDepth != 0 {
+		t.Errorf("Expected empty queue, got %d", response.QueueDepth)
+	}
+}
+
+func TestSubmitUpdateRecordsStatus(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.limiter = NewRateLimiter(time.Hour)
+
+	server.submitUpdate("home.example.com", "1.2.3.4", "A")
+	server.submitUpdate("home.example.com", "1.2.3.5", "A")
+
+	snapshot := server.status.Snapshot()
+	if len(snapshot) != 1 || snapshot[0].State != recordStateQueued || snapshot[0].Value != "1.2.3.4" {
+		t.Errorf("Expected queued state with last written value, got %+v", snapshot)
+	}
+	if server.limiter.Pending() != 1 {
+		t.Errorf("Expected 1 queued write, got %d", server.limiter.Pending())
+	}
+	server.limiter.Forget("home.example.com/A")
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Store persists bridge state such as refresh times, history and caches.
+// Values are grouped into buckets so independent features don't collide.
+type Store interface {
+	// Get returns the value of key in bucket and whether it exists
+	Get(bucket, key string) ([]byte, bool, error)
+	// Put stores value under key in bucket
+	Put(bucket, key string, value []byte) error
+	// Delete removes key from bucket, deleting a missing key is not an error
+	Delete(bucket, key string) error
+	// List returns all keys and values of bucket
+	List(bucket string) (map[string][]byte, error)
+	// Close releases the resources held by the store
+	Close() error
+}
+
+// StoreConfig selects and configures a Store implementation
+type StoreConfig struct {
+	Type          string // memory, bolt, sqlite or redis
+	Path          string // database file for bolt and sqlite
+	RedisAddr     string
+	RedisPassword string
+	RedisDB       int
+}
+
+// NewStore creates the store described by cfg
+func NewStore(cfg StoreConfig) (Store, error) {
+	switch cfg.Type {
+	case "", "memory":
+		return NewMemoryStore(), nil
+	case "bolt":
+		return NewBoltStore(cfg.Path)
+	case "sqlite":
+		return NewSQLiteStore(cfg.Path)
+	case "redis":
+		return NewRedisStore(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+	}
+	return nil, fmt.Errorf("unknown store type: %s", cfg.Type)
+}
+
+// MemoryStore is a Store keeping all state in memory, it is lost on restart
+type MemoryStore struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]map[string][]byte)}
+}
+
+// Get returns the value of key in bucket
+func (m *MemoryStore) Get(bucket, key string) ([]byte, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	value, ok := m.buckets[bucket][key]
+	return append([]byte(nil), value...), ok, nil
+}
+
+// Put stores value under key in bucket
+func (m *MemoryStore) Put(bucket, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.buckets[bucket] == nil {
+		m.buckets[bucket] = make(map[string][]byte)
+	}
+	m.buckets[bucket][key] = append([]byte(nil), value...)
+	return nil
+}
+
+// Delete removes key from bucket
+func (m *MemoryStore) Delete(bucket, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.buckets[bucket], key)
+	return nil
+}
+
+// List returns all keys and values of bucket
+func (m *MemoryStore) List(bucket string) (map[string][]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make(map[string][]byte, len(m.buckets[bucket]))
+	for key, value := range m.buckets[bucket] {
+		result[key] = append([]byte(nil), value...)
+	}
+	return result, nil
+}
+
+// Close is a no-op for the in-memory store
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BoltStore is a Store backed by a local bbolt database file
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens or creates the bbolt database at path
+func NewBoltStore(path string) (*BoltStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("bolt store requires a database path")
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Get returns the value of key in bucket
+func (b *BoltStore) Get(bucket, key string) ([]byte, bool, error) {
+	var value []byte
+	err := b.db.View(func(tx *bolt.Tx) error {
+		if bkt := tx.Bucket([]byte(bucket)); bkt != nil {
+			if v := bkt.Get([]byte(key)); v != nil {
+				value = append([]byte(nil), v...)
+			}
+		}
+		return nil
+	})
+	return value, value != nil, err
+}
+
+// Put stores value under key in bucket
+func (b *BoltStore) Put(bucket, key string, value []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bkt, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return bkt.Put([]byte(key), value)
+	})
+}
+
+// Delete removes key from bucket
+func (b *BoltStore) Delete(bucket, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if bkt := tx.Bucket([]byte(bucket)); bkt != nil {
+			return bkt.Delete([]byte(key))
+		}
+		return nil
+	})
+}
+
+// List returns all keys and values of bucket
+func (b *BoltStore) List(bucket string) (map[string][]byte, error) {
+	result := make(map[string][]byte)
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket([]byte(bucket))
+		if bkt == nil {
+			return nil
+		}
+		return bkt.ForEach(func(k, v []byte) error {
+			result[string(k)] = append([]byte(nil), v...)
+			return nil
+		})
+	})
+	return result, err
+}
+
+// Close closes the database file
+func (b *BoltStore) Close() error {
+	return b.db.Close()
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// redisKeyPrefix namespaces the hashes used for buckets
+const redisKeyPrefix = "dyndns:"
+
+// errRedisNil is returned for nil replies
+var errRedisNil = errors.New("redis: nil reply")
+
+// RedisStore is a Store backed by Redis, each bucket is stored as a hash.
+// It speaks the RESP protocol directly so multiple replicas can share state
+// without additional dependencies.
+type RedisStore struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisStore connects to the Redis server at addr
+func NewRedisStore(addr, password string, db int) (*RedisStore, error) {
+	if addr == "" {
+		return nil, fmt.Errorf("redis store requires an address")
+	}
+
+	store := &RedisStore{
+		addr:     addr,
+		password: password,
+		db:       db,
+		timeout:  5 * time.Second,
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	if err := store.connect(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Get returns the value of key in bucket
+func (r *RedisStore) Get(bucket, key string) ([]byte, bool, error) {
+	reply, err := r.do("HGET", redisKeyPrefix+bucket, key)
+	if errors.Is(err, errRedisNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Put stores value under key in bucket
+func (r *RedisStore) Put(bucket, key string, value []byte) error {
+	_, err := r.do("HSET", redisKeyPrefix+bucket, key, string(value))
+	return err
+}
+
+// Delete removes key from bucket
+func (r *RedisStore) Delete(bucket, key string) error {
+	_, err := r.do("HDEL", redisKeyPrefix+bucket, key)
+	return err
+}
+
+// List returns all keys and values of bucket
+func (r *RedisStore) List(bucket string) (map[string][]byte, error) {
+	reply, err := r.do("HGETALL", redisKeyPrefix+bucket)
+	if err != nil {
+		return nil, err
+	}
+	items, ok := reply.([]interface{})
+	if !ok || len(items)%2 != 0 {
+		return nil, fmt.Errorf("redis: unexpected reply %T", reply)
+	}
+
+	result := make(map[string][]byte, len(items)/2)
+	for i := 0; i < len(items); i += 2 {
+		key, _ := items[i].([]byte)
+		value, _ := items[i+1].([]byte)
+		result[string(key)] = value
+	}
+	return result, nil
+}
+
+// Close closes the connection
+func (r *RedisStore) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		return nil
+	}
+	err := r.conn.Close()
+	r.conn = nil
+	return err
+}
+
+// do sends a command, reconnecting once if the connection was lost
+func (r *RedisStore) do(args ...string) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		if err := r.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := r.roundTrip(args...)
+	var netErr net.Error
+	if err != nil && (errors.Is(err, io.EOF) || errors.As(err, &netErr)) {
+		r.conn.Close()
+		r.conn = nil
+		if err := r.connect(); err != nil {
+			return nil, err
+		}
+		reply, err = r.roundTrip(args...)
+	}
+	return reply, err
+}
+
+// connect dials the server and authenticates. Callers must hold mu.
+func (r *RedisStore) connect() error {
+	conn, err := net.DialTimeout("tcp", r.addr, r.timeout)
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	r.conn = conn
+	r.reader = bufio.NewReader(conn)
+
+	if r.password != "" {
+		if _, err := r.roundTrip("AUTH", r.password); err != nil {
+			r.conn.Close()
+			r.conn = nil
+			return fmt.Errorf("redis authentication failed: %w", err)
+		}
+	}
+	if r.db != 0 {
+		if _, err := r.roundTrip("SELECT", strconv.Itoa(r.db)); err != nil {
+			r.conn.Close()
+			r.conn = nil
+			return fmt.Errorf("failed to select redis database: %w", err)
+		}
+	}
+	return nil
+}
+
+// roundTrip writes a command and reads its reply. Callers must hold mu.
+func (r *RedisStore) roundTrip(args ...string) (interface{}, error) {
+	r.conn.SetDeadline(time.Now().Add(r.timeout))
+
+	buf := []byte(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)...)
+	}
+	if _, err := r.conn.Write(buf); err != nil {
+		return nil, err
+	}
+	return readRESP(r.reader)
+}
+
+// readRESP reads a single RESP reply
+func readRESP(reader *bufio.Reader) (interface{}, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, fmt.Errorf("redis: %s", line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, errRedisNil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, errRedisNil
+		}
+		items := make([]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			item, err := readRESP(reader)
+			if err != nil && !errors.Is(err, errRedisNil) {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: invalid reply %q", line)
+}
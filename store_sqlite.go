@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore is a Store backed by a local SQLite database file, for setups
+// that inspect or back up the state with the usual SQLite tools
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens or creates the SQLite database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite store requires a database path")
+	}
+
+	// WAL lets readers like the sqlite3 shell look at the file while the bridge writes
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite serializes writers anyway, a single connection avoids busy errors
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS state (
+		bucket TEXT NOT NULL,
+		key    TEXT NOT NULL,
+		value  BLOB NOT NULL,
+		PRIMARY KEY (bucket, key)
+	)`); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+// Get returns the value of key in bucket
+func (s *SQLiteStore) Get(bucket, key string) ([]byte, bool, error) {
+	var value []byte
+	err := s.db.QueryRow("SELECT value FROM state WHERE bucket = ? AND key = ?", bucket, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Put stores value under key in bucket
+func (s *SQLiteStore) Put(bucket, key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	_, err := s.db.Exec(`INSERT INTO state (bucket, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (bucket, key) DO UPDATE SET value = excluded.value`, bucket, key, value)
+	return err
+}
+
+// Delete removes key from bucket
+func (s *SQLiteStore) Delete(bucket, key string) error {
+	_, err := s.db.Exec("DELETE FROM state WHERE bucket = ? AND key = ?", bucket, key)
+	return err
+}
+
+// List returns all keys and values of bucket
+func (s *SQLiteStore) List(bucket string) (map[string][]byte, error) {
+	rows, err := s.db.Query("SELECT key, value FROM state WHERE bucket = ?", bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string][]byte)
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, rows.Err()
+}
+
+// Close closes the database file
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testStore runs the common Store contract against store
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	defer store.Close()
+
+	if _, ok, err := store.Get("refresh", "missing"); err != nil || ok {
+		t.Errorf("Expected missing key, got ok=%v err=%v", ok, err)
+	}
+
+	if err := store.Put("refresh", "home.example.com/A", []byte("one")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := store.Put("refresh", "nas.example.com/A", []byte("two")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := store.Put("other", "home.example.com/A", []byte("three")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	value, ok, err := store.Get("refresh", "home.example.com/A")
+	if err != nil || !ok || string(value) != "one" {
+		t.Errorf("Expected value one, got %q ok=%v err=%v", value, ok, err)
+	}
+
+	items, err := store.List("refresh")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(items) != 2 || string(items["nas.example.com/A"]) != "two" {
+		t.Errorf("Unexpected bucket contents: %v", items)
+	}
+
+	if err := store.Delete("refresh", "home.example.com/A"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Delete("refresh", "missing"); err != nil {
+		t.Errorf("Deleting a missing key failed: %v", err)
+	}
+	if _, ok, _ := store.Get("refresh", "home.example.com/A"); ok {
+		t.Error("Expected key to be deleted")
+	}
+	if items, _ := store.List("empty"); len(items) != 0 {
+		t.Errorf("Expected empty bucket, got %v", items)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestBoltStore(t *testing.T) {
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "dyndns.db"))
+	if err != nil {
+		t.Fatalf("NewBoltStore failed: %v", err)
+	}
+	testStore(t, store)
+}
+
+func TestSQLiteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dyndns.sqlite")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	testStore(t, store)
+
+	// The state survives reopening the file
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore failed: %v", err)
+	}
+	defer store.Close()
+	if value, ok, err := store.Get("refresh", "nas.example.com/A"); err != nil || !ok || string(value) != "two" {
+		t.Errorf("Expected the stored value after reopening, got %q ok=%v err=%v", value, ok, err)
+	}
+	if err := store.Put("refresh", "nas.example.com/A", []byte("updated")); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if value, _, _ := store.Get("refresh", "nas.example.com/A"); string(value) != "updated" {
+		t.Errorf("Expected the value to be replaced, got %q", value)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	addr := startFakeRedis(t, "secret")
+
+	store, err := NewRedisStore(addr, "secret", 0)
+	if err != nil {
+		t.Fatalf("NewRedisStore failed: %v", err)
+	}
+	testStore(t, store)
+
+	if _, err := NewRedisStore(addr, "wrong", 0); err == nil {
+		t.Error("Expected authentication error")
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	store, err := NewStore(StoreConfig{Type: "memory"})
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	if _, ok := store.(*MemoryStore); !ok {
+		t.Errorf("Expected memory store, got %T", store)
+	}
+
+	if _, err := NewStore(StoreConfig{Type: "sqlite"}); err == nil {
+		t.Error("Expected error for unknown store type")
+	}
+}
+
+func TestRefreshTrackerPersistence(t *testing.T) {
+	store := NewMemoryStore()
+	newRefreshTracker(store).Mark("home.example.com", "A")
+
+	// A second tracker sharing the store sees the refresh
+	seen := newRefreshTracker(store).LastSeen("home.example.com", "A")
+	if time.Since(seen) > time.Minute {
+		t.Errorf("Expected recent refresh, got %v", seen)
+	}
+	if !newRefreshTracker(store).LastSeen("home.example.com", "AAAA").IsZero() {
+		t.Error("Expected no refresh for AAAA record")
+	}
+}
+
+// startFakeRedis serves a minimal in-memory implementation of the hash commands used by RedisStore
+func startFakeRedis(t *testing.T, password string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	var mu sync.Mutex
+	hashes := map[string]map[string]string{}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					reply, err := readRESP(reader)
+					if err != nil {
+						return
+					}
+					items, _ := reply.([]interface{})
+					args := make([]string, len(items))
+					for i, item := range items {
+						b, _ := item.([]byte)
+						args[i] = string(b)
+					}
+
+					mu.Lock()
+					switch args[0] {
+					case "AUTH":
+						if args[1] == password {
+							fmt.Fprint(conn, "+OK\r\n")
+						} else {
+							fmt.Fprint(conn, "-WRONGPASS invalid password\r\n")
+						}
+					case "HSET":
+						if hashes[args[1]] == nil {
+							hashes[args[1]] = map[string]string{}
+						}
+						hashes[args[1]][args[2]] = args[3]
+						fmt.Fprint(conn, ":1\r\n")
+					case "HGET":
+						if value, ok := hashes[args[1]][args[2]]; ok {
+							fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+						} else {
+							fmt.Fprint(conn, "$-1\r\n")
+						}
+					case "HDEL":
+						delete(hashes[args[1]], args[2])
+						fmt.Fprint(conn, ":1\r\n")
+					case "HGETALL":
+						fmt.Fprintf(conn, "*%d\r\n", len(hashes[args[1]])*2)
+						for key, value := range hashes[args[1]] {
+							fmt.Fprintf(conn, "$%d\r\n%s\r\n$%d\r\n%s\r\n", len(key), key, len(value), value)
+						}
+					default:
+						fmt.Fprintf(conn, "-ERR unknown command %s\r\n", args[0])
+					}
+					mu.Unlock()
+				}
+			}(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// errExternallyManaged is returned in strict mode for records that appear to be managed by another tool
+var errExternallyManaged = errors.New("record appears to be managed externally")
+
+// checkManaged enforces the managed-names-only strict mode on top of the
+// ownership check. An existing record must still carry the TTL the bridge
+// writes, as tools like Terraform usually pin their own, and new records may
+// not be created next to records of the same name the bridge does not own.
+func (s *DynDNSServer) checkManaged(lookup *recordLookup, hostname, recordType string) error {
+	if !s.strict {
+		return nil
+	}
+
+	var reason string
+	if existing := lookup.Existing; existing != nil {
+		if existing.TTL != nil && *existing.TTL != defaultRecordTTL {
+			reason = fmt.Sprintf("its TTL was changed to %d", *existing.TTL)
+		}
+	} else {
+		for _, record := range lookup.Records {
+			if record.Name != lookup.Name || strings.HasPrefix(record.Name, "_dyndns-") {
+				continue
+			}
+			if !isOwnedRecord(lookup.Records, lookup.Name, record.Type, s.ownerID) {
+				reason = fmt.Sprintf("the name holds an unowned %s record", record.Type)
+				break
+			}
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	log.Printf("Conflict: refusing to touch %s (%s) because %s", hostname, recordType, reason)
+	return fmt.Errorf("%s (%s): %w: %s", hostname, recordType, errExternallyManaged, reason)
+}
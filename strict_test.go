@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUpdateDNSRecordStrict(t *testing.T) {
+	ttl, pinned := defaultRecordTTL, 300
+	marker := DNSRecord{ID: "m1", Type: "TXT", Name: "_dyndns-a.home", Value: ownershipValue("bridge1")}
+
+	tests := []struct {
+		name           string
+		records        []DNSRecord
+		expectError    bool
+		expectedWrites []string
+	}{
+		{
+			name:           "owned record is updated",
+			records:        []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1", TTL: &ttl}, marker},
+			expectedWrites: []string{"PUT rec1"},
+		},
+		{
+			name:        "owned record with pinned TTL is refused",
+			records:     []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1", TTL: &pinned}, marker},
+			expectError: true,
+		},
+		{
+			name:        "record beside unowned records is not created",
+			records:     []DNSRecord{{ID: "rec1", Type: "AAAA", Name: "home", Value: "2001:db8::1"}},
+			expectError: true,
+		},
+		{
+			name:           "record on a free name is created",
+			records:        []DNSRecord{{ID: "rec1", Type: "A", Name: "other", Value: "1.1.1.1"}},
+			expectedWrites: []string{"POST A home", "POST TXT _dyndns-a.home"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, tt.records, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.ownerID = "bridge1"
+			server.strict = true
+
+			err := server.updateDNSRecord("home.example.com", "1.2.3.4", "A")
+			if tt.expectError != errors.Is(err, errExternallyManaged) {
+				t.Errorf("Expected strict mode error %v, got %v", tt.expectError, err)
+			}
+			if strings.Join(writes, ";") != strings.Join(tt.expectedWrites, ";") {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+		})
+	}
+}
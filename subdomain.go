@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseSubdomainDepths parses the username=depth pairs of DYNDNS_SUBDOMAIN_DEPTHS
+func parseSubdomainDepths(value string) (map[string]int, error) {
+	depths := map[string]int{}
+	for _, item := range splitList(value) {
+		user, depth, ok := strings.Cut(item, "=")
+		user = strings.TrimSpace(user)
+		n, err := strconv.Atoi(strings.TrimSpace(depth))
+		if !ok || user == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid subdomain depth %q, expected username=depth with a non-negative depth", item)
+		}
+		depths[user] = n
+	}
+	return depths, nil
+}
+
+// subdomainDepth returns the number of labels of a record name relative to its
+// zone, 0 for the apex: "a.b.c" of a.b.c.example.com has a depth of 3
+func subdomainDepth(recordName string) int {
+	if recordName == "@" || recordName == "" {
+		return 0
+	}
+	return strings.Count(recordName, ".") + 1
+}
+
+// subdomainLimit returns the deepest record name username may update, zero is unlimited
+func (s *DynDNSServer) subdomainLimit(username string) int {
+	if limit, ok := s.subdomainDepths[username]; ok {
+		return limit
+	}
+	return s.maxSubdomainDepth
+}
+
+// checkSubdomainDepth rejects hostnames nested deeper below their zone than username
+// may update. Zones that cannot be resolved are left to the update to report.
+func (s *DynDNSServer) checkSubdomainDepth(username, hostname string) error {
+	limit := s.subdomainLimit(username)
+	if limit <= 0 {
+		return nil
+	}
+	client, err := s.clientFor(hostname)
+	if err != nil {
+		return nil
+	}
+	zone, name, err := client.zoneFor(hostname)
+	if err != nil || zone == nil {
+		return nil
+	}
+	if depth := subdomainDepth(name); depth > limit {
+		return fmt.Errorf("%s is %d labels below the zone %s, %s may update at most %d", hostname, depth, zone.Name, username, limit)
+	}
+	return nil
+}
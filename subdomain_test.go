@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+)
+
+func TestSubdomainDepth(t *testing.T) {
+	tests := []struct {
+		hostname     string
+		expectedName string
+		expected     int
+	}{
+		{hostname: "example.com", expectedName: "@", expected: 0},
+		{hostname: "home.example.com", expectedName: "home", expected: 1},
+		{hostname: "a.b.c.example.com", expectedName: "a.b.c", expected: 3},
+		{hostname: "*.lab.example.com", expectedName: "*.lab", expected: 2},
+		// The longest matching zone wins, sub.example.com is delegated separately
+		{hostname: "a.b.sub.example.com", expectedName: "a.b", expected: 2},
+	}
+
+	zones := []Zone{{ID: "zone1", Name: "example.com"}, {ID: "zone2", Name: "sub.example.com"}}
+	for _, tt := range tests {
+		t.Run(tt.hostname, func(t *testing.T) {
+			_, name := dyndns.ZoneFor(zones, tt.hostname)
+			if name != tt.expectedName {
+				t.Errorf("Expected record name %q, got %q", tt.expectedName, name)
+			}
+			if depth := subdomainDepth(name); depth != tt.expected {
+				t.Errorf("Expected depth %d, got %d", tt.expected, depth)
+			}
+		})
+	}
+}
+
+func TestParseSubdomainDepths(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expected      map[string]int
+		errorContains string
+	}{
+		{name: "empty", value: "", expected: map[string]int{}},
+		{name: "pairs", value: "nas=1, router = 3", expected: map[string]int{"nas": 1, "router": 3}},
+		{name: "missing depth", value: "nas", errorContains: "expected username=depth"},
+		{name: "negative depth", value: "nas=-1", errorContains: "non-negative"},
+		{name: "not a number", value: "nas=deep", errorContains: "expected username=depth"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			depths, err := parseSubdomainDepths(tt.value)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(depths, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, depths)
+			}
+		})
+	}
+}
+
+func TestHandleUpdateSubdomainDepth(t *testing.T) {
+	tests := []struct {
+		name           string
+		username       string
+		hostname       string
+		maxDepth       int
+		expected       string
+		expectedWrites string
+	}{
+		{name: "multi-label name unlimited", username: "admin", hostname: "a.b.c.example.com", expected: "good IPv4: 203.0.113.7", expectedWrites: "POST A a.b.c"},
+		{name: "within the limit", username: "admin", hostname: "home.example.com", maxDepth: 1, expected: "good IPv4: 203.0.113.7", expectedWrites: "POST A home"},
+		{name: "too deep", username: "admin", hostname: "a.b.example.com", maxDepth: 1, expected: "nohost"},
+		{name: "credential allowed deeper", username: "lab", hostname: "a.b.c.example.com", maxDepth: 1, expected: "good IPv4: 203.0.113.7", expectedWrites: "POST A a.b.c"},
+		{name: "credential limited further", username: "nas", hostname: "a.b.example.com", maxDepth: 3, expected: "nohost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, nil, &writes)
+			defer mockAPI.Close()
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.profileUsers = map[string]string{"lab": "custom", "nas": "custom"}
+			server.maxSubdomainDepth = tt.maxDepth
+			server.subdomainDepths = map[string]int{"lab": 3, "nas": 1}
+
+			req := httptest.NewRequest("GET", "/update?hostname="+tt.hostname+"&myip=203.0.113.7", nil)
+			req.SetBasicAuth(tt.username, "password")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if w.Body.String() != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, w.Body.String())
+			}
+			if got := strings.Join(writes, ";"); got != tt.expectedWrites {
+				t.Errorf("Expected writes %q, got %q", tt.expectedWrites, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegramUpdate is an entry of the getUpdates response
+type telegramUpdate struct {
+	UpdateID int64 `json:"update_id"`
+	Message  *struct {
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+		Text string `json:"text"`
+	} `json:"message"`
+}
+
+// TelegramBot answers /status and /forceupdate commands from allowlisted chats
+type TelegramBot struct {
+	server    *DynDNSServer
+	detector  *IPDetector
+	notifier  telegramNotifier
+	allowed   map[int64]bool
+	offset    int64
+	pollAfter time.Duration
+}
+
+// NewTelegramBot creates a bot for token accepting commands from chatIDs,
+// detector finds the public IP for /forceupdate
+func NewTelegramBot(server *DynDNSServer, detector *IPDetector, token string, chatIDs []int64) *TelegramBot {
+	allowed := map[int64]bool{}
+	for _, id := range chatIDs {
+		allowed[id] = true
+	}
+	return &TelegramBot{
+		server:   server,
+		detector: detector,
+		// Long polling keeps the request open for up to 30 seconds
+		notifier:  telegramNotifier{baseURL: telegramAPIURL, token: token, client: &http.Client{Timeout: 40 * time.Second}},
+		allowed:   allowed,
+		pollAfter: 5 * time.Second,
+	}
+}
+
+// Run polls for commands until stop is closed, waiting before retrying failed polls
+func (b *TelegramBot) Run(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		if err := b.Poll(30); err != nil {
+			log.Printf("Telegram bot: %v", err)
+			select {
+			case <-time.After(b.pollAfter):
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+// Poll fetches pending messages, waiting up to timeout seconds, and answers the commands among them
+func (b *TelegramBot) Poll(timeout int) error {
+	url := fmt.Sprintf("%s/bot%s/getUpdates?timeout=%d&offset=%d", b.notifier.baseURL, b.notifier.token, timeout, b.offset)
+	resp, err := b.notifier.client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch updates: %w", b.notifier.redact(err))
+	}
+	defer resp.Body.Close()
+	if err := checkDeliveryStatus(resp); err != nil {
+		return fmt.Errorf("failed to fetch updates: %w", err)
+	}
+
+	var result struct {
+		Result []telegramUpdate `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode updates: %w", err)
+	}
+
+	for _, update := range result.Result {
+		b.offset = update.UpdateID + 1
+		if update.Message == nil || !strings.HasPrefix(update.Message.Text, "/") {
+			continue
+		}
+		chatID := update.Message.Chat.ID
+		if !b.allowed[chatID] {
+			log.Printf("Telegram bot: ignoring command from chat %d, not in DYNDNS_TELEGRAM_ALLOWED_CHATS", chatID)
+			continue
+		}
+
+		reply := b.notifier
+		reply.chatID = strconv.FormatInt(chatID, 10)
+		if err := reply.Notify(Event{Message: b.handleCommand(update.Message.Text)}); err != nil {
+			log.Printf("Telegram bot: failed to reply: %v", err)
+		}
+	}
+	return nil
+}
+
+// handleCommand executes a command and returns the reply text
+func (b *TelegramBot) handleCommand(text string) string {
+	fields := strings.Fields(text)
+	// Commands in groups are addressed as /status@botname
+	command, _, _ := strings.Cut(fields[0], "@")
+
+	switch command {
+	case "/status":
+		return b.statusText()
+	case "/forceupdate":
+		if len(fields) != 2 {
+			return "usage: /forceupdate <hostname>"
+		}
+		return b.forceUpdate(strings.ToLower(fields[1]))
+	default:
+		return "Unknown command, available: /status, /forceupdate <hostname>"
+	}
+}
+
+// statusText summarizes the state of all records
+func (b *TelegramBot) statusText() string {
+	records := b.server.status.Snapshot()
+	if len(records) == 0 {
+		return "No updates received yet"
+	}
+
+	var lines []string
+	for _, record := range records {
+		line := fmt.Sprintf("%s %s: %s %s", record.Hostname, record.Type, record.State, record.Value)
+		if record.LastUpdate != nil {
+			line += " (updated " + record.LastUpdate.Format(time.RFC3339) + ")"
+		}
+		if record.LastError != "" {
+			line += " error: " + record.LastError
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// forceUpdate detects the public IP and corrects the records of a configured hostname
+func (b *TelegramBot) forceUpdate(hostname string) string {
+	if !slices.Contains(b.server.hostnames, hostname) {
+		return hostname + " is not listed in DYNDNS_HOSTNAMES"
+	}
+
+	lines := []string{hostname + ":"}
+	for _, family := range []struct {
+		recordType string
+		detect     func() (string, error)
+	}{
+		{"A", b.detector.DetectIPv4},
+		{"AAAA", b.detector.DetectIPv6},
+	} {
+		ip, err := family.detect()
+		switch {
+		case err != nil:
+			lines = append(lines, family.recordType+" detection failed: "+err.Error())
+			continue
+		case ip == "":
+			continue
+		case family.recordType == "A" && b.server.isDSLite(hostname):
+			lines = append(lines, "A skipped, the hostname uses DS-Lite")
+			continue
+		}
+
+		changed, err := b.server.ensureRecord(hostname, ip, family.recordType, historySystemTelegram)
+		switch {
+		case err != nil:
+			lines = append(lines, family.recordType+" update failed: "+err.Error())
+		case changed:
+			lines = append(lines, family.recordType+" updated to "+ip)
+		default:
+			lines = append(lines, family.recordType+" already points to "+ip)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseChatIDs parses Telegram chat IDs
+func parseChatIDs(values []string) ([]int64, error) {
+	ids := make([]int64, 0, len(values))
+	for _, value := range values {
+		id, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid chat ID %q", value)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
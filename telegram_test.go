@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTelegramBotCommands(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"}}, &writes)
+	defer mockAPI.Close()
+
+	echo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("203.0.113.1"))
+	}))
+	defer echo.Close()
+
+	messages := []string{
+		`{"update_id":1,"message":{"chat":{"id":42},"text":"/forceupdate home.example.com"}}`,
+		`{"update_id":2,"message":{"chat":{"id":7},"text":"/status"}}`,
+		`{"update_id":3,"message":{"chat":{"id":42},"text":"/status@dyndns_bot"}}`,
+		`{"update_id":4,"message":{"chat":{"id":42},"text":"/forceupdate other.example.com"}}`,
+		`{"update_id":5,"message":{"chat":{"id":42},"text":"hello"}}`,
+	}
+	var mu sync.Mutex
+	var offsets []string
+	replies := map[string][]string{}
+	telegramAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/bot123:abc/getUpdates":
+			offsets = append(offsets, r.URL.Query().Get("offset"))
+			fmt.Fprintf(w, `{"ok":true,"result":[%s]}`, strings.Join(messages, ","))
+			messages = nil
+		case "/bot123:abc/sendMessage":
+			var message map[string]string
+			json.NewDecoder(r.Body).Decode(&message)
+			replies[message["chat_id"]] = append(replies[message["chat_id"]], message["text"])
+		default:
+			t.Errorf("Unexpected request %s", r.URL.Path)
+		}
+	}))
+	defer telegramAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.hostnames = []string{"home.example.com"}
+
+	bot := NewTelegramBot(server, NewIPDetector(echo.URL, ""), "123:abc", []int64{42})
+	bot.notifier.baseURL = telegramAPI.URL
+	if err := bot.Poll(0); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if err := bot.Poll(0); err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+
+	if strings.Join(offsets, ",") != "0,6" {
+		t.Errorf("Expected offset to advance past handled updates, got %v", offsets)
+	}
+	if len(replies["7"]) != 0 {
+		t.Errorf("Expected commands from other chats to be ignored, got %v", replies["7"])
+	}
+	if strings.Join(writes, ";") != "PUT rec1" {
+		t.Errorf("Expected forced update to correct the record, got %v", writes)
+	}
+
+	got := replies["42"]
+	if len(got) < 3 {
+		t.Fatalf("Expected three replies, got %v", got)
+	}
+	if got[0] != "home.example.com:\nA updated to 203.0.113.1" {
+		t.Errorf("Unexpected /forceupdate reply %q", got[0])
+	}
+	if !strings.HasPrefix(got[1], "home.example.com A: ok 203.0.113.1") {
+		t.Errorf("Unexpected /status reply %q", got[1])
+	}
+	if got[2] != "other.example.com is not listed in DYNDNS_HOSTNAMES" {
+		t.Errorf("Unexpected reply for unconfigured hostname %q", got[2])
+	}
+}
+
+func TestTelegramRedactsToken(t *testing.T) {
+	notifier := telegramNotifier{baseURL: "http://127.0.0.1:1", token: "123:secret", chatID: "42", client: http.DefaultClient}
+	err := notifier.Notify(Event{Message: "test"})
+	if err == nil {
+		t.Fatal("Expected connection error")
+	}
+	if strings.Contains(err.Error(), "123:secret") {
+		t.Errorf("Error reveals the bot token: %v", err)
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TenantConfig is a household served by a shared bridge. Each tenant has its
+// own credentials and zones, and its state, rate limits and notifications are
+// isolated from the other tenants.
+type TenantConfig struct {
+	Name     string   `yaml:"name"`
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	Zones    []string `yaml:"zones"`
+	// APIToken is the tenant's Hetzner DNS token, the bridge's tokens are used if it is empty
+	APIToken string `yaml:"api_token"`
+	// MinUpdateInterval overrides DYNDNS_MIN_UPDATE_INTERVAL for the tenant
+	MinUpdateInterval *time.Duration `yaml:"min_update_interval"`
+	// QuotaUpdates and QuotaWrites override DYNDNS_QUOTA_UPDATES and DYNDNS_QUOTA_WRITES if set
+	QuotaUpdates int `yaml:"quota_updates"`
+	QuotaWrites  int `yaml:"quota_writes"`
+	// MaxSubdomainDepth overrides DYNDNS_MAX_SUBDOMAIN_DEPTH if set
+	MaxSubdomainDepth int `yaml:"max_subdomain_depth"`
+	// NotifyWebhookURL and NotifyNtfyURL deliver the tenant's events
+	NotifyWebhookURL string `yaml:"notify_webhook_url"`
+	NotifyNtfyURL    string `yaml:"notify_ntfy_url"`
+	NotifyNtfyToken  string `yaml:"notify_ntfy_token"`
+}
+
+// tenantsFile is the YAML document read from DYNDNS_TENANTS_FILE
+type tenantsFile struct {
+	Tenants []TenantConfig `yaml:"tenants"`
+}
+
+// tenantNamePattern restricts tenant names to what is safe in store keys and metric labels
+var tenantNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// loadTenants reads and validates the tenants of a YAML file
+func loadTenants(path string) ([]TenantConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file tenantsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	users := map[string]bool{}
+	zones := map[string]string{}
+	for i, tenant := range file.Tenants {
+		switch {
+		case !tenantNamePattern.MatchString(tenant.Name):
+			return nil, fmt.Errorf("tenant %d: name must consist of lowercase letters, digits and dashes, got %q", i+1, tenant.Name)
+		case names[tenant.Name]:
+			return nil, fmt.Errorf("tenant %s is defined twice", tenant.Name)
+		case tenant.Username == "" || tenant.Password == "":
+			return nil, fmt.Errorf("tenant %s: username and password are required", tenant.Name)
+		case users[tenant.Username]:
+			return nil, fmt.Errorf("tenant %s: username %s is used by another tenant", tenant.Name, tenant.Username)
+		case len(tenant.Zones) == 0:
+			return nil, fmt.Errorf("tenant %s: at least one zone is required", tenant.Name)
+		}
+		for j, zone := range tenant.Zones {
+			zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+			if owner, ok := zones[zone]; ok {
+				return nil, fmt.Errorf("tenant %s: zone %s already belongs to tenant %s", tenant.Name, zone, owner)
+			}
+			zones[zone] = tenant.Name
+			file.Tenants[i].Zones[j] = zone
+		}
+		names[tenant.Name] = true
+		users[tenant.Username] = true
+	}
+	return file.Tenants, nil
+}
+
+// notifyConfig returns the notification settings of the tenant
+func (t TenantConfig) notifyConfig() NotifyConfig {
+	var cfg NotifyConfig
+	if t.NotifyWebhookURL != "" {
+		cfg.Notifiers = append(cfg.Notifiers, "webhook")
+		cfg.WebhookURL = t.NotifyWebhookURL
+	}
+	if t.NotifyNtfyURL != "" {
+		cfg.Notifiers = append(cfg.Notifiers, "ntfy")
+		cfg.NtfyURL, cfg.NtfyToken = t.NotifyNtfyURL, t.NotifyNtfyToken
+	}
+	return cfg
+}
+
+// namespacedStore isolates the buckets of a tenant within a shared store
+type namespacedStore struct {
+	store  Store
+	prefix string
+}
+
+func (n namespacedStore) Get(bucket, key string) ([]byte, bool, error) {
+	return n.store.Get(n.prefix+bucket, key)
+}
+
+func (n namespacedStore) Put(bucket, key string, value []byte) error {
+	return n.store.Put(n.prefix+bucket, key, value)
+}
+
+func (n namespacedStore) Delete(bucket, key string) error {
+	return n.store.Delete(n.prefix+bucket, key)
+}
+
+func (n namespacedStore) List(bucket string) (map[string][]byte, error) {
+	return n.store.List(n.prefix + bucket)
+}
+
+// Close does nothing, the shared store is closed by its owner
+func (n namespacedStore) Close() error {
+	return nil
+}
+
+// addTenant creates the server of a tenant, sharing the update behaviour of s.
+// client holds the tenant's own token, nil uses the clients of s. interval is
+// the rate limit used unless the tenant sets its own.
+func (s *DynDNSServer) addTenant(cfg TenantConfig, client *Client, interval time.Duration) error {
+	// Requests are routed by username, a shared one would serve one of them with the other's rights
+	if s.usesUsername(cfg.Username) {
+		return fmt.Errorf("tenant %s: username %s is already used by the bridge or another tenant", cfg.Name, cfg.Username)
+	}
+	tenant := NewDynDNSServer(client, cfg.Username, cfg.Password, s.port)
+	if client == nil {
+		tenant.client, tenant.router = s.client, s.router
+	}
+	tenant.zones = cfg.Zones
+	tenant.SetStore(namespacedStore{store: s.store, prefix: "tenant/" + cfg.Name + "/"})
+	tenant.metrics = s.metrics.WithLabels(Labels{"tenant": cfg.Name})
+	tenant.agents = newAgentTracker(tenant.metrics)
+
+	if cfg.MinUpdateInterval != nil {
+		interval = *cfg.MinUpdateInterval
+	}
+	if interval > 0 {
+		tenant.limiter = NewRateLimiter(interval)
+	}
+	notifications, err := cfg.notifyConfig().NewNotifications()
+	if err != nil {
+		return fmt.Errorf("tenant %s: %w", cfg.Name, err)
+	}
+	tenant.notifications = notifications
+
+	// Usage is accounted in the bridge's tracker so /api/usage shows all tenants
+	quota := s.usage.defaults
+	if cfg.QuotaUpdates > 0 {
+		quota.Updates = cfg.QuotaUpdates
+	}
+	if cfg.QuotaWrites > 0 {
+		quota.Writes = cfg.QuotaWrites
+	}
+	tenant.tenant = cfg.Name
+	tenant.usage = s.usage
+	tenant.maintenance = s.maintenance
+	s.usage.SetQuota(cfg.Username, cfg.Name, quota)
+
+	// Update behaviour is the same for all tenants
+	tenant.ownerID = s.ownerID
+	tenant.ipv6InterfaceID = s.ipv6InterfaceID
+	tenant.responses = s.responses
+	tenant.requireAgent = s.requireAgent
+	tenant.blockedAgents = s.blockedAgents
+	tenant.emptyIP = s.emptyIP
+	tenant.connectionIPUsers = s.connectionIPUsers
+	tenant.updateTimeout = s.updateTimeout
+	tenant.fastAck = s.fastAck
+	tenant.churn.threshold = s.churn.threshold
+	tenant.history.limit = s.history.limit
+	tenant.maxSubdomainDepth = s.maxSubdomainDepth
+	if cfg.MaxSubdomainDepth > 0 {
+		tenant.maxSubdomainDepth = cfg.MaxSubdomainDepth
+	}
+	tenant.strict = s.strict
+	tenant.conflictPolicy = s.conflictPolicy
+	tenant.allowPost = s.allowPost
+	tenant.rejectBogons = s.rejectBogons
+	tenant.dsLite = s.dsLite
+	tenant.roundRobin = s.roundRobin
+	tenant.geoip = s.geoip
+	tenant.countries = s.countries
+	tenant.rejectUnknownCountry = s.rejectUnknownCountry
+	tenant.verifier = s.verifier
+	tenant.propagation = s.propagation
+	tenant.dnssec = s.dnssec
+	tenant.authLog = s.authLog
+	if s.annotations != nil {
+		tenant.annotations = newAnnotationTracker()
+	}
+	tenant.basePath = s.basePath
+	tenant.SetClock(s.clock)
+
+	if s.tenants == nil {
+		s.tenants = make(map[string]*DynDNSServer)
+	}
+	s.tenants[cfg.Username] = tenant
+	log.Printf("Serving tenant %s with zones %s", cfg.Name, strings.Join(cfg.Zones, ", "))
+	return nil
+}
+
+// tenantFor returns the server of the tenant whose username r claims, nil for
+// other users. The claim only selects the server, whose auth chain then checks
+// the tenant's password.
+func (s *DynDNSServer) tenantFor(r *http.Request) *DynDNSServer {
+	if len(s.tenants) == 0 {
+		return nil
+	}
+	user, _, ok := r.BasicAuth()
+	if !ok {
+		user, _, ok = queryCredentials(r)
+	}
+	if !ok {
+		return nil
+	}
+	return s.tenants[user]
+}
+
+// routeTenant serves requests of tenant users with handler on the tenant's server,
+// other requests on s
+func (s *DynDNSServer) routeTenant(handler func(*DynDNSServer, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if tenant := s.tenantFor(r); tenant != nil {
+			handler(tenant, w, r)
+			return
+		}
+		handler(s, w, r)
+	}
+}
+
+// usesUsername reports whether username authenticates with the bridge's own
+// credentials or those of a tenant
+func (s *DynDNSServer) usesUsername(username string) bool {
+	if _, ok := s.tenants[username]; ok || username == s.username {
+		return true
+	}
+	if _, ok := s.profileUsers[username]; ok {
+		return true
+	}
+	return s.roundRobin != nil && slices.Contains(s.roundRobin.sources, username)
+}
+
+// allowsHostname reports whether hostname is in one of the zones the server is
+// restricted to. Zones of tenants are only updated by the tenant.
+func (s *DynDNSServer) allowsHostname(hostname string) bool {
+	hostname = strings.ToLower(hostname)
+	for _, tenant := range s.tenants {
+		for _, zone := range tenant.zones {
+			if matchesRoute(zone, hostname) {
+				return false
+			}
+		}
+	}
+	if len(s.zones) == 0 {
+		return true
+	}
+	for _, zone := range s.zones {
+		if matchesRoute(zone, hostname) {
+			return true
+		}
+	}
+	return false
+}
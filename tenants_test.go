@@ -0,0 +1,226 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadTenants(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expectedZones [][]string
+		errorContains string
+	}{
+		{
+			name: "valid tenants",
+			content: `tenants:
+  - name: alice
+    username: alice
+    password: secret
+    zones: [Alice.example.]
+    min_update_interval: 5m
+  - name: bob
+    username: bob
+    password: secret
+    zones: [bob.example]
+`,
+			expectedZones: [][]string{{"alice.example"}, {"bob.example"}},
+		},
+		{
+			name:          "invalid name",
+			content:       "tenants:\n  - {name: Alice, username: alice, password: secret, zones: [alice.example]}\n",
+			errorContains: "lowercase",
+		},
+		{
+			name:          "duplicate username",
+			content:       "tenants:\n  - {name: alice, username: home, password: secret, zones: [alice.example]}\n  - {name: bob, username: home, password: secret, zones: [bob.example]}\n",
+			errorContains: "username home",
+		},
+		{
+			name:          "shared zone",
+			content:       "tenants:\n  - {name: alice, username: alice, password: secret, zones: [family.example]}\n  - {name: bob, username: bob, password: secret, zones: [family.example]}\n",
+			errorContains: "already belongs to tenant alice",
+		},
+		{
+			name:          "missing zones",
+			content:       "tenants:\n  - {name: alice, username: alice, password: secret}\n",
+			errorContains: "zone",
+		},
+		{
+			name:          "missing password",
+			content:       "tenants:\n  - {name: alice, username: alice, zones: [alice.example]}\n",
+			errorContains: "password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "tenants.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			tenants, err := loadTenants(path)
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var zones [][]string
+			for _, tenant := range tenants {
+				zones = append(zones, tenant.Zones)
+			}
+			if !reflect.DeepEqual(zones, tt.expectedZones) {
+				t.Errorf("Expected zones %v, got %v", tt.expectedZones, zones)
+			}
+		})
+	}
+}
+
+func TestNamespacedStore(t *testing.T) {
+	store := NewMemoryStore()
+	alice := namespacedStore{store: store, prefix: "tenant/alice/"}
+	bob := namespacedStore{store: store, prefix: "tenant/bob/"}
+
+	alice.Put("refreshed", "home.example.com", []byte("1"))
+	if _, ok, _ := bob.Get("refreshed", "home.example.com"); ok {
+		t.Error("Expected the key of alice to be invisible to bob")
+	}
+	if _, ok, _ := store.Get("refreshed", "home.example.com"); ok {
+		t.Error("Expected the key of alice to be invisible to the bridge")
+	}
+	if value, ok, _ := alice.Get("refreshed", "home.example.com"); !ok || string(value) != "1" {
+		t.Errorf("Expected alice to read her key, got %q", value)
+	}
+}
+
+func TestTenantUpdates(t *testing.T) {
+	tests := []struct {
+		name             string
+		username         string
+		password         string
+		hostname         string
+		expectedCode     int
+		expectedResponse string
+		expectedWrites   []string
+		expectedTenant   string
+	}{
+		{
+			name:             "tenant updates its zone",
+			username:         "alice",
+			password:         "alice-secret",
+			hostname:         "home.example.com",
+			expectedCode:     200,
+			expectedResponse: "good IPv4: 203.0.113.7",
+			expectedWrites:   []string{"POST A home"},
+			expectedTenant:   "alice",
+		},
+		{
+			name:             "tenant cannot update other zones",
+			username:         "alice",
+			password:         "alice-secret",
+			hostname:         "home.example.org",
+			expectedCode:     200,
+			expectedResponse: "nohost",
+		},
+		{
+			name:         "tenant with the bridge password",
+			username:     "alice",
+			password:     "password",
+			hostname:     "home.example.com",
+			expectedCode: 401,
+		},
+		{
+			name:             "bridge credentials cannot update tenant zones",
+			username:         "admin",
+			password:         "password",
+			hostname:         "home.example.com",
+			expectedCode:     200,
+			expectedResponse: "nohost",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, nil, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			for _, tenant := range []TenantConfig{
+				{Name: "alice", Username: "alice", Password: "alice-secret", Zones: []string{"example.com"}},
+				{Name: "bob", Username: "bob", Password: "bob-secret", Zones: []string{"example.net"}},
+			} {
+				if err := server.addTenant(tenant, nil, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			req := httptest.NewRequest("GET", "/update?hostname="+tt.hostname+"&myip=203.0.113.7", nil)
+			req.SetBasicAuth(tt.username, tt.password)
+			w := httptest.NewRecorder()
+			server.routeTenant((*DynDNSServer).handleUpdate)(w, req)
+
+			if w.Code != tt.expectedCode {
+				t.Fatalf("Expected status %d, got %d", tt.expectedCode, w.Code)
+			}
+			if tt.expectedResponse != "" && w.Body.String() != tt.expectedResponse {
+				t.Errorf("Expected response %q, got %q", tt.expectedResponse, w.Body.String())
+			}
+			if !reflect.DeepEqual(writes, tt.expectedWrites) {
+				t.Errorf("Expected writes %v, got %v", tt.expectedWrites, writes)
+			}
+
+			// Tenant state stays in the tenant's server, its metrics carry the tenant label
+			if tt.expectedTenant != "" {
+				if len(server.status.Snapshot()) != 0 {
+					t.Errorf("Expected no status on the bridge, got %v", server.status.Snapshot())
+				}
+				if len(server.tenants[tt.username].status.Snapshot()) == 0 {
+					t.Error("Expected the update in the tenant's status")
+				}
+				metrics := httptest.NewRecorder()
+				server.metrics.ServeHTTP(metrics, httptest.NewRequest("GET", "/metrics", nil))
+				if !strings.Contains(metrics.Body.String(), `tenant="`+tt.expectedTenant+`"`) {
+					t.Errorf("Expected metrics labeled with tenant %s, got %s", tt.expectedTenant, metrics.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestTenantIsolation(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	server.profileUsers = map[string]string{"fritzbox": "fritzbox"}
+	server.roundRobin = newRoundRobinHosts([]string{"www.example.org"}, []string{"site-a"})
+	if err := server.addTenant(TenantConfig{Name: "alice", Username: "alice", Password: "alice-secret", Zones: []string{"example.com"}}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Usernames select the server of a request, so they may not be shared
+	for _, username := range []string{"admin", "alice", "fritzbox", "site-a"} {
+		err := server.addTenant(TenantConfig{Name: "bob", Username: username, Password: "bob-secret", Zones: []string{"example.net"}}, nil, 0)
+		if err == nil || !strings.Contains(err.Error(), "already used") {
+			t.Errorf("Expected username %s to be refused, got %v", username, err)
+		}
+	}
+
+	for hostname, expected := range map[string]bool{"home.example.com": false, "Example.com": false, "home.example.org": true} {
+		if allowed := server.allowsHostname(hostname); allowed != expected {
+			t.Errorf("Expected the bridge to be allowed to update %s: %v, got %v", hostname, expected, allowed)
+		}
+	}
+	if !server.tenants["alice"].allowsHostname("home.example.com") {
+		t.Error("Expected the tenant to update its own zone")
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// FritzBox IGD control endpoint and service answering address queries without credentials
+const (
+	tr064ControlPath = "/igdupnp/control/WANIPConn1"
+	tr064Service     = "urn:schemas-upnp-org:service:WANIPConnection:1"
+)
+
+// TR064Client queries the WAN addresses of a FritzBox through its UPnP IGD
+// service, which must be enabled under Home Network > Network > Network
+// Settings ("Transmit status information over UPnP")
+type TR064Client struct {
+	// URL is the UPnP base URL, e.g. http://fritz.box:49000
+	URL        string
+	HTTPClient *http.Client
+}
+
+// NewTR064Client creates a client for the FritzBox at url
+func NewTR064Client(url string) *TR064Client {
+	return &TR064Client{
+		URL:        strings.TrimSuffix(url, "/"),
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// ExternalIPv4 returns the public IPv4 address, empty while the FritzBox has none
+func (c *TR064Client) ExternalIPv4() (string, error) {
+	values, err := c.call("GetExternalIPAddress")
+	if err != nil {
+		return "", err
+	}
+	ip := values["NewExternalIPAddress"]
+	// Disconnected or DS-Lite routers report no or an unspecified address
+	if ip == "" || ip == "0.0.0.0" {
+		return "", nil
+	}
+	if !parse.IsIPv4(ip) {
+		return "", fmt.Errorf("invalid IPv4 address from FritzBox: %q", ip)
+	}
+	return ip, nil
+}
+
+// ExternalIPv6 returns the public IPv6 address of the FritzBox, empty without IPv6 connectivity
+func (c *TR064Client) ExternalIPv6() (string, error) {
+	values, err := c.call("X_AVM_DE_GetExternalIPv6Address")
+	if err != nil {
+		return "", err
+	}
+	ip := values["NewExternalIPv6Address"]
+	if ip == "" || ip == "::" {
+		return "", nil
+	}
+	if !parse.IsIPv6(ip) {
+		return "", fmt.Errorf("invalid IPv6 address from FritzBox: %q", ip)
+	}
+	return ip, nil
+}
+
+// IPv6Prefix returns the delegated LAN prefix in CIDR notation, empty without IPv6 connectivity
+func (c *TR064Client) IPv6Prefix() (string, error) {
+	values, err := c.call("X_AVM_DE_GetIPv6Prefix")
+	if err != nil {
+		return "", err
+	}
+	prefix, length := values["NewIPv6Prefix"], values["NewPrefixLength"]
+	if prefix == "" || prefix == "::" || length == "" || length == "0" {
+		return "", nil
+	}
+	return prefix + "/" + length, nil
+}
+
+// call invokes a SOAP action of the WANIPConnection service and returns the values of the response
+func (c *TR064Client) call(action string) (map[string]string, error) {
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>`+
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">`+
+		`<s:Body><u:%s xmlns:u="%s"/></s:Body></s:Envelope>`, action, tr064Service)
+	req, err := http.NewRequest(http.MethodPost, c.URL+tr064ControlPath, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf("%q", tr064Service+"#"+action))
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("TR-064 %s failed: %w", action, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, fmt.Errorf("TR-064 %s failed: %w", action, err)
+	}
+	values, err := parseSOAPValues(data)
+	if err != nil {
+		return nil, fmt.Errorf("TR-064 %s returned invalid XML: %w", action, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		if description := values["errorDescription"]; description != "" {
+			return nil, fmt.Errorf("TR-064 %s failed: status %d: %s", action, resp.StatusCode, description)
+		}
+		return nil, fmt.Errorf("TR-064 %s failed: status %d", action, resp.StatusCode)
+	}
+	return values, nil
+}
+
+// parseSOAPValues returns the text of every leaf element of a SOAP envelope by its local name
+func parseSOAPValues(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var name string
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch token := token.(type) {
+		case xml.StartElement:
+			name = token.Name.Local
+			text.Reset()
+		case xml.CharData:
+			text.Write(token)
+		case xml.EndElement:
+			if name == token.Name.Local {
+				values[name] = strings.TrimSpace(text.String())
+			}
+			name = ""
+		}
+	}
+}
+
+// TR064Poller polls the FritzBox for its addresses and updates the configured
+// hostnames when they change, so the router does not have to push updates
+type TR064Poller struct {
+	server    *DynDNSServer
+	client    *TR064Client
+	hostnames []string
+
+	// mu guards the addresses applied last, a change or failed update applies them again
+	mu         sync.Mutex
+	ipv4, ipv6 string
+}
+
+// NewTR064Poller creates a poller updating hostnames with the addresses reported by client
+func NewTR064Poller(server *DynDNSServer, client *TR064Client, hostnames []string) *TR064Poller {
+	server.metrics.Describe("dyndns_tr064_polls_total", "counter", "Number of FritzBox address polls over TR-064 by result.")
+	return &TR064Poller{
+		server:    server,
+		client:    client,
+		hostnames: hostnames,
+	}
+}
+
+// Run polls once immediately and then every interval until stop is closed
+func (p *TR064Poller) Run(interval time.Duration, stop <-chan struct{}) {
+	p.Poll()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			p.Poll()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Poll queries the FritzBox and updates the hostnames if an address changed since the
+// last poll. It returns the number of records that were changed.
+func (p *TR064Poller) Poll() int {
+	ipv4, err := p.client.ExternalIPv4()
+	if err != nil {
+		log.Printf("TR-064: %v", err)
+		p.server.metrics.Inc("dyndns_tr064_polls_total", Labels{"result": "error"})
+		return 0
+	}
+	ipv6, err := p.detectIPv6()
+	if err != nil {
+		// Routers without IPv6 may not implement the AVM actions, IPv4 is still applied
+		log.Printf("TR-064: %v", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if ipv4 == p.ipv4 && ipv6 == p.ipv6 {
+		p.server.metrics.Inc("dyndns_tr064_polls_total", Labels{"result": "unchanged"})
+		return 0
+	}
+	log.Printf("TR-064: FritzBox reports ipv4=%s ipv6=%s, updating %s", ipv4, ipv6, strings.Join(p.hostnames, ", "))
+	p.server.metrics.Inc("dyndns_tr064_polls_total", Labels{"result": "changed"})
+
+	changed, failed := 0, false
+	for _, hostname := range p.hostnames {
+		for _, record := range []struct{ ip, recordType string }{{ipv4, "A"}, {ipv6, "AAAA"}} {
+			if record.ip == "" || record.recordType == "A" && p.server.isDSLite(hostname) {
+				continue
+			}
+			updated, err := p.server.ensureRecord(hostname, record.ip, record.recordType, historySystemTR064)
+			if err != nil {
+				log.Printf("TR-064: update of %s %s failed: %v", hostname, record.recordType, err)
+				failed = true
+				continue
+			}
+			if updated {
+				changed++
+			}
+		}
+	}
+	// Failed updates are retried with the next poll
+	if !failed {
+		p.ipv4, p.ipv6 = ipv4, ipv6
+	}
+	return changed
+}
+
+// detectIPv6 returns the address for AAAA records, the LAN host built from the delegated
+// prefix with DYNDNS_IPV6_INTERFACE_ID and the FritzBox's own address otherwise
+func (p *TR064Poller) detectIPv6() (string, error) {
+	if p.server.ipv6InterfaceID == "" {
+		return p.client.ExternalIPv6()
+	}
+	prefix, err := p.client.IPv6Prefix()
+	if err != nil || prefix == "" {
+		return "", err
+	}
+	return combineIPv6Prefix(prefix, p.server.ipv6InterfaceID)
+}
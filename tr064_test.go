@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newFritzBoxMock answers the TR-064 address actions with the values of responses by action
+func newFritzBoxMock(t *testing.T, responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		action := strings.Trim(r.Header.Get("SOAPAction"), `"`)
+		action = strings.TrimPrefix(action, tr064Service+"#")
+		if r.URL.Path != tr064ControlPath || !strings.Contains(string(body), "<u:"+action) {
+			t.Errorf("Unexpected request %s %s: %s", r.URL.Path, action, body)
+		}
+		values, ok := responses[action]
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault><detail>`+
+				`<UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>401</errorCode><errorDescription>Invalid Action</errorDescription></UPnPError>`+
+				`</detail></s:Fault></s:Body></s:Envelope>`)
+			return
+		}
+		fmt.Fprintf(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+			`<u:%sResponse xmlns:u="%s">%s</u:%sResponse></s:Body></s:Envelope>`, action, tr064Service, values, action)
+	}))
+}
+
+func TestTR064Client(t *testing.T) {
+	tests := []struct {
+		name           string
+		responses      map[string]string
+		expectedIPv4   string
+		expectedIPv6   string
+		expectedPrefix string
+		errorContains  string
+	}{
+		{
+			name: "dual stack",
+			responses: map[string]string{
+				"GetExternalIPAddress":            "<NewExternalIPAddress>203.0.113.7</NewExternalIPAddress>",
+				"X_AVM_DE_GetExternalIPv6Address": "<NewExternalIPv6Address>2001:db8::1</NewExternalIPv6Address><NewPrefixLength>64</NewPrefixLength>",
+				"X_AVM_DE_GetIPv6Prefix":          "<NewIPv6Prefix>2001:db8:1:2::</NewIPv6Prefix><NewPrefixLength>64</NewPrefixLength>",
+			},
+			expectedIPv4:   "203.0.113.7",
+			expectedIPv6:   "2001:db8::1",
+			expectedPrefix: "2001:db8:1:2::/64",
+		},
+		{
+			name: "disconnected",
+			responses: map[string]string{
+				"GetExternalIPAddress":            "<NewExternalIPAddress>0.0.0.0</NewExternalIPAddress>",
+				"X_AVM_DE_GetExternalIPv6Address": "<NewExternalIPv6Address></NewExternalIPv6Address>",
+				"X_AVM_DE_GetIPv6Prefix":          "<NewIPv6Prefix></NewIPv6Prefix><NewPrefixLength>0</NewPrefixLength>",
+			},
+		},
+		{
+			name:          "invalid address",
+			responses:     map[string]string{"GetExternalIPAddress": "<NewExternalIPAddress>fritz</NewExternalIPAddress>"},
+			errorContains: "invalid IPv4 address",
+		},
+		{
+			name:          "upnp disabled",
+			responses:     map[string]string{},
+			errorContains: "status 500: Invalid Action",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fritzBox := newFritzBoxMock(t, tt.responses)
+			defer fritzBox.Close()
+			client := NewTR064Client(fritzBox.URL + "/")
+
+			ipv4, err := client.ExternalIPv4()
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			ipv6, err := client.ExternalIPv6()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			prefix, err := client.IPv6Prefix()
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ipv4 != tt.expectedIPv4 || ipv6 != tt.expectedIPv6 || prefix != tt.expectedPrefix {
+				t.Errorf("Expected %q %q %q, got %q %q %q", tt.expectedIPv4, tt.expectedIPv6, tt.expectedPrefix, ipv4, ipv6, prefix)
+			}
+		})
+	}
+}
+
+func TestTR064PollerPoll(t *testing.T) {
+	tests := []struct {
+		name           string
+		interfaceID    string
+		responses      map[string]string
+		expectedWrites string
+	}{
+		{
+			name: "router addresses",
+			responses: map[string]string{
+				"GetExternalIPAddress":            "<NewExternalIPAddress>203.0.113.7</NewExternalIPAddress>",
+				"X_AVM_DE_GetExternalIPv6Address": "<NewExternalIPv6Address>2001:db8::1</NewExternalIPv6Address>",
+			},
+			expectedWrites: "PUT rec1;POST AAAA home",
+		},
+		{
+			name:        "LAN host from the delegated prefix",
+			interfaceID: "::1234:56ff:fe78:9abc",
+			responses: map[string]string{
+				"GetExternalIPAddress":   "<NewExternalIPAddress>203.0.113.7</NewExternalIPAddress>",
+				"X_AVM_DE_GetIPv6Prefix": "<NewIPv6Prefix>2001:db8:1:2::</NewIPv6Prefix><NewPrefixLength>64</NewPrefixLength>",
+			},
+			expectedWrites: "PUT rec1;POST AAAA home",
+		},
+		{
+			name: "IPv6 actions unsupported",
+			responses: map[string]string{
+				"GetExternalIPAddress": "<NewExternalIPAddress>203.0.113.7</NewExternalIPAddress>",
+			},
+			expectedWrites: "PUT rec1",
+		},
+		{
+			name: "address unchanged",
+			responses: map[string]string{
+				"GetExternalIPAddress": "<NewExternalIPAddress>1.1.1.1</NewExternalIPAddress>",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"}}, &writes)
+			defer mockAPI.Close()
+			fritzBox := newFritzBoxMock(t, tt.responses)
+			defer fritzBox.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.ipv6InterfaceID = tt.interfaceID
+			poller := NewTR064Poller(server, NewTR064Client(fritzBox.URL), []string{"home.example.com"})
+
+			poller.Poll()
+			if got := strings.Join(writes, ";"); got != tt.expectedWrites {
+				t.Errorf("Expected writes %q, got %q", tt.expectedWrites, got)
+			}
+
+			// Unchanged addresses are not applied again
+			writes = nil
+			if changed := poller.Poll(); changed != 0 || len(writes) != 0 {
+				t.Errorf("Expected no writes for unchanged addresses, got %d: %v", changed, writes)
+			}
+			if value := server.metrics.Value("dyndns_tr064_polls_total", Labels{"result": "unchanged"}); value != 1 {
+				t.Errorf("Expected 1 unchanged poll, got %g", value)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+)
+
+// TransportConfig tunes the connections of the API client
+type TransportConfig struct {
+	// Proxy is the proxy URL, empty uses HTTPS_PROXY, HTTP_PROXY and NO_PROXY from the environment
+	Proxy string
+	// DNSServer resolves the API host through this nameserver (host:port) instead of the system resolver
+	DNSServer string
+
+	MaxIdleConns      int
+	MaxConnsPerHost   int
+	IdleConnTimeout   time.Duration
+	DisableKeepAlives bool
+	// DisableHTTP2 restricts the client to HTTP/1.1, e.g. for proxies mishandling HTTP/2
+	DisableHTTP2 bool
+
+	// TLSMinVersion is "1.2" or "1.3", CABundle a PEM file of additional trusted CAs
+	TLSMinVersion string
+	CABundle      string
+}
+
+// tlsVersions maps the supported TLSMinVersion values
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// defaultIdleConnsPerHost is the number of idle connections kept to the API.
+// All requests go to a single host, so the net/http default of 2 would close
+// connections that the next burst of zone, record and update calls needs.
+const defaultIdleConnsPerHost = 10
+
+// NewTransport creates an HTTP transport for the API client from cfg
+func NewTransport(cfg TransportConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.Proxy != "" {
+		proxy, err := url.Parse(cfg.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if cfg.DNSServer != "" {
+		resolver := &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, cfg.DNSServer)
+			},
+		}
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Resolver: resolver}
+		transport.DialContext = dialer.DialContext
+	}
+
+	transport.MaxIdleConnsPerHost = defaultIdleConnsPerHost
+	if cfg.MaxIdleConns > 0 {
+		transport.MaxIdleConns = cfg.MaxIdleConns
+		transport.MaxIdleConnsPerHost = cfg.MaxIdleConns
+	}
+	if cfg.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = cfg.MaxConnsPerHost
+	}
+	if cfg.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = cfg.IdleConnTimeout
+	}
+	transport.DisableKeepAlives = cfg.DisableKeepAlives
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSMinVersion != "" {
+		version, ok := tlsVersions[cfg.TLSMinVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %q (expected 1.2 or 1.3)", cfg.TLSMinVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+	if cfg.CABundle != "" {
+		pem, err := os.ReadFile(cfg.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", cfg.CABundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	// A custom TLS config or dialer disables HTTP/2 unless it is forced, a
+	// non-nil empty TLSNextProto map turns it off completely
+	transport.ForceAttemptHTTP2 = !cfg.DisableHTTP2
+	if cfg.DisableHTTP2 {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return transport, nil
+}
+
+// meteredTransport records the number and latency of requests sent to the API
+type meteredTransport struct {
+	next    http.RoundTripper
+	metrics *Metrics
+}
+
+// newMeteredTransport wraps next, recording its requests in metrics
+func newMeteredTransport(next http.RoundTripper, metrics *Metrics) *meteredTransport {
+	metrics.Describe("dyndns_api_requests_total", "counter", "Number of Hetzner API requests by method and status code.")
+	metrics.Describe("dyndns_api_request_duration_seconds_sum", "counter", "Total time spent waiting for Hetzner API responses.")
+	metrics.Describe("dyndns_api_request_duration_seconds_count", "counter", "Number of timed Hetzner API requests.")
+
+	return &meteredTransport{next: next, metrics: metrics}
+}
+
+// RoundTrip performs the request, counting transport failures with code "error"
+func (t *meteredTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	t.metrics.Inc("dyndns_api_requests_total", Labels{"method": req.Method, "code": code})
+	t.metrics.Add("dyndns_api_request_duration_seconds_sum", nil, time.Since(start).Seconds())
+	t.metrics.Inc("dyndns_api_request_duration_seconds_count", nil)
+	return resp, err
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+)
+
+func TestNewTransport(t *testing.T) {
+	transport, err := NewTransport(TransportConfig{
+		Proxy:           "http://proxy.example.com:3128",
+		MaxIdleConns:    4,
+		MaxConnsPerHost: 2,
+		IdleConnTimeout: 10 * time.Second,
+		TLSMinVersion:   "1.3",
+	})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+
+	proxy, err := transport.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "dns.hetzner.com"}})
+	if err != nil || proxy == nil || proxy.Host != "proxy.example.com:3128" {
+		t.Errorf("Expected configured proxy, got %v (%v)", proxy, err)
+	}
+	if transport.MaxIdleConnsPerHost != 4 || transport.MaxConnsPerHost != 2 || transport.IdleConnTimeout != 10*time.Second {
+		t.Errorf("Unexpected pool settings: %d %d %v", transport.MaxIdleConnsPerHost, transport.MaxConnsPerHost, transport.IdleConnTimeout)
+	}
+	if transport.TLSClientConfig.MinVersion != tls.VersionTLS13 {
+		t.Errorf("Expected TLS 1.3 minimum, got %x", transport.TLSClientConfig.MinVersion)
+	}
+}
+
+func TestNewTransportErrors(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	os.WriteFile(empty, []byte("no certificates"), 0o600)
+
+	tests := []struct {
+		name string
+		cfg  TransportConfig
+	}{
+		{"invalid proxy", TransportConfig{Proxy: "://proxy"}},
+		{"unsupported TLS version", TransportConfig{TLSMinVersion: "1.1"}},
+		{"missing CA bundle", TransportConfig{CABundle: filepath.Join(t.TempDir(), "missing.pem")}},
+		{"empty CA bundle", TransportConfig{CABundle: empty}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTransport(tt.cfg); err == nil {
+				t.Error("Expected error but got none")
+			}
+		})
+	}
+}
+
+func TestNewTransportCABundle(t *testing.T) {
+	mockAPI := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"zones":[]}`))
+	}))
+	defer mockAPI.Close()
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: mockAPI.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	transport, err := NewTransport(TransportConfig{CABundle: bundle})
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	client.HTTPClient.Transport = transport
+
+	if _, err := client.GetZones(); err != nil {
+		t.Errorf("Expected the mock API certificate to be trusted, got %v", err)
+	}
+}
+
+func TestMeteredTransport(t *testing.T) {
+	mockAPI := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer mockAPI.Close()
+
+	metrics := NewMetrics()
+	client := &http.Client{Transport: newMeteredTransport(http.DefaultTransport, metrics)}
+	resp, err := client.Get(mockAPI.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if _, err := client.Get("http://127.0.0.1:1"); err == nil {
+		t.Fatal("Expected connection error")
+	}
+
+	if value := metrics.Value("dyndns_api_requests_total", Labels{"method": "GET", "code": "503"}); value != 1 {
+		t.Errorf("Expected one 503 response, got %v", value)
+	}
+	if value := metrics.Value("dyndns_api_requests_total", Labels{"method": "GET", "code": "error"}); value != 1 {
+		t.Errorf("Expected one transport error, got %v", value)
+	}
+	if value := metrics.Value("dyndns_api_request_duration_seconds_count", nil); value != 2 {
+		t.Errorf("Expected two timed requests, got %v", value)
+	}
+}
+
+// newHTTP2MockAPI serves the fake API holding home.example.com over TLS and HTTP/2
+func newHTTP2MockAPI(tb testing.TB) *httptest.Server {
+	fake, err := newFakeHetzner([]string{"example.com"}, "")
+	if err != nil {
+		tb.Fatalf("Failed to create fake API: %v", err)
+	}
+	fake.Seed("example.com", DNSRecord{ID: "rec1", Type: "A", Name: "home", Value: "203.0.113.1"})
+	mockAPI := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Proto", r.Proto)
+		fake.ServeHTTP(w, r)
+	}))
+	mockAPI.EnableHTTP2 = true
+	mockAPI.StartTLS()
+	return mockAPI
+}
+
+// newTrustingTransport creates a transport from cfg that trusts the certificate of mockAPI
+func newTrustingTransport(tb testing.TB, mockAPI *httptest.Server, cfg TransportConfig) *http.Transport {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		tb.Fatalf("NewTransport failed: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(mockAPI.Certificate())
+	transport.TLSClientConfig.RootCAs = pool
+	return transport
+}
+
+func TestNewTransportHTTP2(t *testing.T) {
+	mockAPI := newHTTP2MockAPI(t)
+	defer mockAPI.Close()
+
+	tests := []struct {
+		name     string
+		cfg      TransportConfig
+		expected string
+	}{
+		{"HTTP/2 by default", TransportConfig{TLSMinVersion: "1.2"}, "HTTP/2.0"},
+		{"HTTP/2 disabled", TransportConfig{DisableHTTP2: true}, "HTTP/1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newTrustingTransport(t, mockAPI, tt.cfg)
+			defer transport.CloseIdleConnections()
+			if transport.MaxIdleConnsPerHost != defaultIdleConnsPerHost {
+				t.Errorf("Expected %d idle connections per host, got %d", defaultIdleConnsPerHost, transport.MaxIdleConnsPerHost)
+			}
+
+			resp, err := (&http.Client{Transport: transport}).Get(mockAPI.URL + "/zones")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.Proto != tt.expected || resp.Header.Get("X-Proto") != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, resp.Proto)
+			}
+		})
+	}
+}
+
+// BenchmarkUpdateSequence measures the zone, record and update calls of an
+// update with reused connections against a new TLS handshake per request
+func BenchmarkUpdateSequence(b *testing.B) {
+	mockAPI := newHTTP2MockAPI(b)
+	defer mockAPI.Close()
+
+	benchmarks := []struct {
+		name string
+		cfg  TransportConfig
+	}{
+		{"http2", TransportConfig{}},
+		{"http1 keepalive", TransportConfig{DisableHTTP2: true}},
+		{"http1 new connections", TransportConfig{DisableHTTP2: true, DisableKeepAlives: true}},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			transport := newTrustingTransport(b, mockAPI, bm.cfg)
+			defer transport.CloseIdleConnections()
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			client.HTTPClient.Transport = transport
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				zone, name, err := client.FindZoneForFQDN("home.example.com")
+				if err != nil {
+					b.Fatal(err)
+				}
+				records, err := client.GetRecordsByNameAndType(zone.ID, name, "A")
+				if err != nil || len(records) != 1 {
+					b.Fatalf("Unexpected records %v: %v", records, err)
+				}
+				if _, _, err := dyndns.UpsertRecord(client, zone.ID, name, "A", "203.0.113.2", 0, &records[0]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -1,46 +1,33 @@
 package main
 
-
-// DNSRecord represents a DNS record in the Hetzner DNS API
-type DNSRecord struct {
-	ID       string `json:"id,omitempty"`
-	Type     string `json:"type"`
-	Name     string `json:"name"`
-	Value    string `json:"value"`
-	TTL      int   `json:"ttl"`
-	ZoneID   string `json:"zone_id,omitempty"`
-	Created  string `json:"created,omitempty"`
-	Modified string `json:"modified,omitempty"`
-}
-
-// Zone represents a DNS zone
-type Zone struct {
-	ID              string    `json:"id"`
-	Name            string    `json:"name"`
-	TTL             int       `json:"ttl"`
-	Registrar       string    `json:"registrar"`
-	LegacyDNSHost   string    `json:"legacy_dns_host"`
-	LegacyNS        []string  `json:"legacy_ns"`
-	NS              []string  `json:"ns"`
-	Created         string `json:"created"`
-	Verified        string `json:"verified"`
-	Modified        string `json:"modified"`
-	Project         string    `json:"project"`
-	Owner           string    `json:"owner"`
-	Permission      string    `json:"permission"`
-	Status          string    `json:"status"`
-	Paused          bool      `json:"paused"`
-	IsSecondaryDNS  bool      `json:"is_secondary_dns"`
-	TxtVerification struct {
-		Name  string `json:"name"`
-		Token string `json:"token"`
-	} `json:"txt_verification"`
-	RecordsCount int `json:"records_count"`
+import "fritzbox-hetzner-dyndns/dyndns"
+
+// The records and zones are those of the embeddable dyndns package, so
+// *Client implements its Provider
+type (
+	DNSRecord           = dyndns.DNSRecord
+	Zone                = dyndns.Zone
+	CreateRecordRequest = dyndns.CreateRecordRequest
+	UpdateRecordRequest = dyndns.UpdateRecordRequest
+	Provider            = dyndns.Provider
+)
+
+// Pagination describes the page of a paginated list response
+type Pagination struct {
+	Page         int `json:"page"`
+	PerPage      int `json:"per_page"`
+	PreviousPage int `json:"previous_page"`
+	NextPage     int `json:"next_page"`
+	LastPage     int `json:"last_page"`
+	TotalEntries int `json:"total_entries"`
 }
 
 // RecordsResponse represents the response when getting multiple records
 type RecordsResponse struct {
 	Records []DNSRecord `json:"records"`
+	Meta    struct {
+		Pagination Pagination `json:"pagination"`
+	} `json:"meta"`
 }
 
 // RecordResponse represents the response when getting/creating/updating a single record
@@ -52,14 +39,7 @@ type RecordResponse struct {
 type ZonesResponse struct {
 	Zones []Zone `json:"zones"`
 	Meta  struct {
-		Pagination struct {
-			Page         int `json:"page"`
-			PerPage      int `json:"per_page"`
-			PreviousPage int `json:"previous_page"`
-			NextPage     int `json:"next_page"`
-			LastPage     int `json:"last_page"`
-			TotalEntries int `json:"total_entries"`
-		} `json:"pagination"`
+		Pagination Pagination `json:"pagination"`
 	} `json:"meta"`
 }
 
@@ -70,21 +50,3 @@ type APIError struct {
 		Code    int    `json:"code"`
 	} `json:"error"`
 }
-
-// CreateRecordRequest represents the request to create a new record
-type CreateRecordRequest struct {
-	Type   string `json:"type"`
-	Name   string `json:"name"`
-	Value  string `json:"value"`
-	TTL    int   `json:"ttl"`
-	ZoneID string `json:"zone_id"`
-}
-
-// UpdateRecordRequest represents the request to update a record
-type UpdateRecordRequest struct {
-	Type  string `json:"type"`
-	Name  string `json:"name"`
-	Value string `json:"value"`
-	TTL   int   `json:"ttl"`
-	ZoneID string `json:"zone_id"`
-}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// uciEnvNames maps the UCI options that do not follow the DYNDNS_<OPTION> naming
+var uciEnvNames = map[string]string{
+	"api_key":           "HETZNER_DNS_API_KEY",
+	"api_key_secondary": "HETZNER_DNS_API_KEY_SECONDARY",
+	"api_url":           "HETZNER_DNS_API_URL",
+}
+
+// uciIgnored are options evaluated by the init script rather than the bridge
+var uciIgnored = map[string]bool{"enabled": true}
+
+// uciOptionName matches valid UCI option names
+var uciOptionName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// parseUCI reads OpenWrt configuration, either in the format of the files in
+// /etc/config or as printed by "uci show", and returns the environment
+// variables it sets. Options of all sections are merged, list values are
+// joined with commas.
+func parseUCI(r io.Reader) (map[string]string, error) {
+	values := map[string][]string{}
+	var order []string
+	set := func(option string, list bool, words ...string) error {
+		if !uciOptionName.MatchString(option) {
+			return fmt.Errorf("invalid option name %q", option)
+		}
+		if _, ok := values[option]; !ok {
+			order = append(order, option)
+		}
+		if list {
+			values[option] = append(values[option], words...)
+		} else {
+			values[option] = words
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		var err error
+		if keyword, rest, _ := strings.Cut(line, " "); keyword == "config" || keyword == "option" || keyword == "list" {
+			err = parseUCIStatement(keyword, rest, set)
+		} else {
+			err = parseUCIShow(line, set)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	env := map[string]string{}
+	for _, option := range order {
+		if uciIgnored[option] {
+			continue
+		}
+		name := uciEnvNames[option]
+		if name == "" {
+			name = "DYNDNS_" + strings.ToUpper(option)
+		}
+		env[name] = strings.Join(values[option], ",")
+	}
+	return env, nil
+}
+
+// parseUCIStatement parses a config, option or list line of a UCI file
+func parseUCIStatement(keyword, rest string, set func(string, bool, ...string) error) error {
+	words, err := splitUCIWords(rest)
+	if err != nil {
+		return err
+	}
+	switch {
+	case keyword == "config":
+		// config <type> [name] only starts a section
+		if len(words) == 0 || len(words) > 2 {
+			return fmt.Errorf("expected config <type> [name]")
+		}
+		return nil
+	case len(words) != 2:
+		return fmt.Errorf("expected %s <name> <value>", keyword)
+	}
+	return set(words[0], keyword == "list", words[1])
+}
+
+// parseUCIShow parses a line printed by "uci show", e.g.
+// dyndns.main.hostname='home.example.com' 'nas.example.com'
+func parseUCIShow(line string, set func(string, bool, ...string) error) error {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return fmt.Errorf("expected a config, option or list statement or package.section.option=value")
+	}
+	parts := strings.Split(key, ".")
+	switch len(parts) {
+	case 2:
+		// package.section=type declares the section
+		return nil
+	case 3:
+	default:
+		return fmt.Errorf("expected package.section.option=value, got %q", key)
+	}
+	words, err := splitUCIWords(value)
+	if err != nil {
+		return err
+	}
+	// uci show prints lists as several quoted words
+	return set(parts[2], len(words) > 1, words...)
+}
+
+// splitUCIWords splits s into words like the shell, honoring single and
+// double quotes and backslash escapes, e.g. 'it'\”s' is one word
+func splitUCIWords(s string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	quote := rune(0)
+	escaped := false
+	for _, c := range s {
+		switch {
+		case escaped:
+			word.WriteRune(c)
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\\':
+			escaped, inWord = true, true
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inWord = c, true
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		case c == '#' && !inWord:
+			return words, nil
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// applyUCI sets the environment from the UCI configuration at path, "-"
+// reads stdin. Variables already set in the environment take precedence.
+func applyUCI(path string) error {
+	in := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+	env, err := parseUCI(in)
+	if err != nil {
+		return fmt.Errorf("invalid UCI configuration %s: %w", path, err)
+	}
+	for name, value := range env {
+		if _, ok := os.LookupEnv(name); !ok {
+			os.Setenv(name, value)
+		}
+	}
+	return nil
+}
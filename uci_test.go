@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseUCI(t *testing.T) {
+	tests := []struct {
+		name          string
+		config        string
+		expected      map[string]string
+		errorContains string
+	}{
+		{
+			name: "config file",
+			config: `
+config dyndns 'main'
+	option enabled '1'
+	option api_key 'token'
+	option password 'it'\''s "secret"'
+	list hostnames 'home.example.com'
+	list hostnames "nas.example.com"  # the NAS
+	option min_update_interval 5m
+`,
+			expected: map[string]string{
+				"HETZNER_DNS_API_KEY":        "token",
+				"DYNDNS_PASSWORD":            `it's "secret"`,
+				"DYNDNS_HOSTNAMES":           "home.example.com,nas.example.com",
+				"DYNDNS_MIN_UPDATE_INTERVAL": "5m",
+			},
+		},
+		{
+			name: "uci show output",
+			config: `dyndns.main=dyndns
+dyndns.main.api_key='token'
+dyndns.main.hostnames='home.example.com' 'nas.example.com'
+dyndns.main.port='8080'
+`,
+			expected: map[string]string{
+				"HETZNER_DNS_API_KEY": "token",
+				"DYNDNS_HOSTNAMES":    "home.example.com,nas.example.com",
+				"DYNDNS_PORT":         "8080",
+			},
+		},
+		{name: "unterminated quote", config: "option password 'secret\n", errorContains: "line 1"},
+		{name: "missing value", config: "config dyndns\noption password\n", errorContains: "line 2"},
+		{name: "invalid option name", config: "dyndns.main.pass-word='x'\n", errorContains: "invalid option name"},
+		{name: "unknown statement", config: "package dyndns\n", errorContains: "expected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := parseUCI(strings.NewReader(tt.config))
+			if tt.errorContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+					t.Fatalf("Expected error containing %q, got %v", tt.errorContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(env, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, env)
+			}
+		})
+	}
+}
+
+func TestApplyUCI(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dyndns")
+	os.WriteFile(path, []byte("config dyndns\n\toption username 'router'\n\toption port '8081'\n"), 0o600)
+	t.Setenv("DYNDNS_PORT", "9000")
+	t.Setenv("DYNDNS_USERNAME", "")
+	os.Unsetenv("DYNDNS_USERNAME")
+
+	if err := applyUCI(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if os.Getenv("DYNDNS_USERNAME") != "router" {
+		t.Errorf("Expected the username from UCI, got %q", os.Getenv("DYNDNS_USERNAME"))
+	}
+	if os.Getenv("DYNDNS_PORT") != "9000" {
+		t.Errorf("Expected the environment to take precedence, got %q", os.Getenv("DYNDNS_PORT"))
+	}
+	if err := applyUCI(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// usageQuota limits the updates and API writes of a credential per day, zero is unlimited
+type usageQuota struct {
+	Updates int
+	Writes  int
+}
+
+// userUsage is the usage of a credential on the current day as reported by /api/usage
+type userUsage struct {
+	Username string `json:"username"`
+	Tenant   string `json:"tenant,omitempty"`
+	Updates  int    `json:"updates"`
+	Writes   int    `json:"writes"`
+	// UpdateQuota and WriteQuota are the daily limits, zero is unlimited
+	UpdateQuota int `json:"update_quota"`
+	WriteQuota  int `json:"write_quota"`
+	// Throttled counts the updates refused with abuse after a quota was exceeded
+	Throttled int `json:"throttled"`
+}
+
+// usageResponse is the JSON schema of /api/usage
+type usageResponse struct {
+	Date  string      `json:"date"`
+	Users []userUsage `json:"users"`
+}
+
+// usageTracker counts updates and API writes per credential and UTC day, so a
+// shared instance can spot and throttle a client stuck in an update loop
+type usageTracker struct {
+	mu       sync.Mutex
+	day      string
+	users    map[string]*userUsage
+	defaults usageQuota
+	quotas   map[string]usageQuota
+	tenants  map[string]string
+	events   *EventBus
+	metrics  *Metrics
+	now      func() time.Time
+}
+
+// newUsageTracker creates a tracker applying defaults to credentials without their own quota
+func newUsageTracker(defaults usageQuota, events *EventBus, metrics *Metrics) *usageTracker {
+	metrics.Describe("dyndns_quota_throttled_total", "counter", "Number of updates refused because a daily quota was exceeded.")
+	return &usageTracker{
+		users:    make(map[string]*userUsage),
+		defaults: defaults,
+		quotas:   make(map[string]usageQuota),
+		tenants:  make(map[string]string),
+		events:   events,
+		metrics:  metrics,
+		now:      time.Now,
+	}
+}
+
+// SetQuota overrides the quota of username, tenant names the tenant owning the credential
+func (t *usageTracker) SetQuota(username, tenant string, quota usageQuota) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.quotas[username] = quota
+	t.tenants[username] = tenant
+}
+
+// quota returns the quota of username
+func (t *usageTracker) quota(username string) usageQuota {
+	if quota, ok := t.quotas[username]; ok {
+		return quota
+	}
+	return t.defaults
+}
+
+// rollover resets all counters when the day changed
+func (t *usageTracker) rollover() {
+	if day := t.now().UTC().Format(time.DateOnly); day != t.day {
+		t.day = day
+		t.users = make(map[string]*userUsage)
+	}
+}
+
+// user returns the usage of username on the current day
+func (t *usageTracker) user(username string) *userUsage {
+	t.rollover()
+	usage, ok := t.users[username]
+	if !ok {
+		quota := t.quota(username)
+		usage = &userUsage{Username: username, Tenant: t.tenants[username], UpdateQuota: quota.Updates, WriteQuota: quota.Writes}
+		t.users[username] = usage
+	}
+	return usage
+}
+
+// Allow counts an update of username and reports whether it is within the quotas.
+// The first refused update of a day publishes an alert.
+func (t *usageTracker) Allow(username string) bool {
+	t.mu.Lock()
+	usage := t.user(username)
+	var exceeded string
+	switch {
+	case usage.UpdateQuota > 0 && usage.Updates >= usage.UpdateQuota:
+		exceeded = fmt.Sprintf("%d updates", usage.UpdateQuota)
+	case usage.WriteQuota > 0 && usage.Writes >= usage.WriteQuota:
+		exceeded = fmt.Sprintf("%d API writes", usage.WriteQuota)
+	default:
+		usage.Updates++
+		t.mu.Unlock()
+		return true
+	}
+	usage.Throttled++
+	first := usage.Throttled == 1
+	t.mu.Unlock()
+
+	t.metrics.Inc("dyndns_quota_throttled_total", Labels{"user": username})
+	if first {
+		message := fmt.Sprintf("%s exceeded its daily quota of %s, further updates are refused until midnight UTC", username, exceeded)
+		log.Printf("Quota: %s", message)
+		t.events.Publish(Event{Kind: eventAlert, Severity: severityWarning, Username: username, Message: message})
+	}
+	return false
+}
+
+// AddWrites counts n records written to the API on behalf of username
+func (t *usageTracker) AddWrites(username string, n int) {
+	if n == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.user(username).Writes += n
+}
+
+// Snapshot returns the usage of the current day sorted by username, limited to usernames if any are given
+func (t *usageTracker) Snapshot(usernames ...string) usageResponse {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rollover()
+	response := usageResponse{Date: t.day, Users: []userUsage{}}
+	for _, username := range usernames {
+		response.Users = append(response.Users, *t.user(username))
+	}
+	if len(usernames) == 0 {
+		for _, usage := range t.users {
+			response.Users = append(response.Users, *usage)
+		}
+	}
+	sort.Slice(response.Users, func(i, j int) bool { return response.Users[i].Username < response.Users[j].Username })
+	return response
+}
+
+// requestUsername returns the user authorize authenticated r as, "token" for
+// credentials without a username such as address allowlists. Credentials sent
+// along with others, e.g. a Basic Auth username next to a bearer token, are
+// not trusted.
+func requestUsername(r *http.Request) string {
+	if user, _ := r.Context().Value(authUserKey{}).(string); user != "" {
+		return user
+	}
+	return "token"
+}
+
+// handleUsage serves the usage of the current day as JSON, tenants only see their own
+func (s *DynDNSServer) handleUsage(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, authScopeAdmin) {
+		return
+	}
+	var response usageResponse
+	if s.tenant != "" {
+		response = s.usage.Snapshot(s.username)
+	} else {
+		response = s.usage.Snapshot()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUsageTrackerQuotas(t *testing.T) {
+	tests := []struct {
+		name            string
+		quota           usageQuota
+		writes          int
+		updates         int
+		expectedAllowed []bool
+	}{
+		{name: "unlimited", updates: 3, expectedAllowed: []bool{true, true, true}},
+		{name: "update quota", quota: usageQuota{Updates: 2}, updates: 3, expectedAllowed: []bool{true, true, false}},
+		{name: "write quota", quota: usageQuota{Writes: 2}, writes: 2, updates: 1, expectedAllowed: []bool{false}},
+		{name: "writes below the quota", quota: usageQuota{Writes: 3}, writes: 2, updates: 1, expectedAllowed: []bool{true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := NewEventBus()
+			var alerts []Event
+			events.Subscribe(func(event Event) { alerts = append(alerts, event) }, eventAlert)
+			tracker := newUsageTracker(tt.quota, events, NewMetrics())
+			tracker.AddWrites("admin", tt.writes)
+
+			var allowed []bool
+			for i := 0; i < tt.updates; i++ {
+				allowed = append(allowed, tracker.Allow("admin"))
+			}
+			if !reflect.DeepEqual(allowed, tt.expectedAllowed) {
+				t.Errorf("Expected %v, got %v", tt.expectedAllowed, allowed)
+			}
+			throttled := 0
+			for _, ok := range allowed {
+				if !ok {
+					throttled++
+				}
+			}
+			if expected := min(throttled, 1); len(alerts) != expected {
+				t.Errorf("Expected %d alerts, got %v", expected, alerts)
+			}
+		})
+	}
+}
+
+func TestUsageTrackerResetsDaily(t *testing.T) {
+	now := time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)
+	tracker := newUsageTracker(usageQuota{Updates: 1}, NewEventBus(), NewMetrics())
+	tracker.now = func() time.Time { return now }
+
+	if !tracker.Allow("admin") || tracker.Allow("admin") {
+		t.Fatal("Expected the second update of the day to be refused")
+	}
+	now = now.Add(2 * time.Minute)
+	if !tracker.Allow("admin") {
+		t.Error("Expected the quota to reset at midnight UTC")
+	}
+	if usage := tracker.Snapshot(); usage.Date != "2026-03-02" || usage.Users[0].Updates != 1 {
+		t.Errorf("Expected one update on 2026-03-02, got %+v", usage)
+	}
+}
+
+func TestHandleUpdateQuota(t *testing.T) {
+	var writes []string
+	mockAPI := newOwnershipMockAPI(t, nil, &writes)
+	defer mockAPI.Close()
+
+	client := NewClient("test-api-key")
+	client.BaseURL = mockAPI.URL
+	server := NewDynDNSServer(client, "admin", "password", "8080")
+	server.usage.defaults = usageQuota{Updates: 1}
+
+	var responses []string
+	for range 2 {
+		req := httptest.NewRequest("GET", "/update?hostname=home.example.com&myip=203.0.113.7", nil)
+		req.SetBasicAuth("admin", "password")
+		w := httptest.NewRecorder()
+		server.handleUpdate(w, req)
+		responses = append(responses, w.Body.String())
+	}
+
+	if expected := []string{"good IPv4: 203.0.113.7", "abuse"}; !reflect.DeepEqual(responses, expected) {
+		t.Errorf("Expected responses %v, got %v", expected, responses)
+	}
+	usage := server.usage.Snapshot("admin").Users[0]
+	if usage.Updates != 1 || usage.Writes != 1 || usage.Throttled != 1 {
+		t.Errorf("Expected 1 update, 1 write and 1 throttled update, got %+v", usage)
+	}
+	if value := server.metrics.Value("dyndns_quota_throttled_total", Labels{"user": "admin"}); value != 1 {
+		t.Errorf("Expected throttled counter 1, got %g", value)
+	}
+}
+
+func TestRequestUsernameIsAuthenticatedUser(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	if err := server.configureAuth(AuthConfig{Update: "token|basic", Tokens: []string{"secret-token"}}); err != nil {
+		t.Fatalf("configureAuth failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		setup    func(*http.Request)
+		expected string
+	}{
+		{
+			name:     "basic auth",
+			setup:    func(r *http.Request) { r.SetBasicAuth("admin", "password") },
+			expected: "admin",
+		},
+		{
+			// A token client must not claim the quota or depth limit of another user
+			name: "token with a claimed username",
+			setup: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer secret-token")
+				r.URL.RawQuery = "username=alice&password=guess"
+			},
+			expected: "token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/update", nil)
+			tt.setup(req)
+			if !server.authorize(httptest.NewRecorder(), req, authScopeUpdate) {
+				t.Fatal("Expected the request to be authenticated")
+			}
+			if user := requestUsername(req); user != tt.expected {
+				t.Errorf("Expected user %s, got %s", tt.expected, user)
+			}
+		})
+	}
+}
+
+func TestHandleUsage(t *testing.T) {
+	server := NewDynDNSServer(NewClient("test-api-key"), "admin", "password", "8080")
+	if err := server.addTenant(TenantConfig{Name: "alice", Username: "alice", Password: "alice-secret", Zones: []string{"example.com"}, QuotaUpdates: 10}, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	server.usage.Allow("admin")
+	server.usage.Allow("alice")
+
+	tests := []struct {
+		name          string
+		username      string
+		password      string
+		expectedUsers []string
+	}{
+		{name: "bridge sees all credentials", username: "admin", password: "password", expectedUsers: []string{"admin", "alice"}},
+		{name: "tenant sees its own usage", username: "alice", password: "alice-secret", expectedUsers: []string{"alice"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/usage", nil)
+			req.SetBasicAuth(tt.username, tt.password)
+			w := httptest.NewRecorder()
+			server.routeTenant((*DynDNSServer).handleUsage)(w, req)
+
+			var response usageResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Invalid response %q: %v", w.Body.String(), err)
+			}
+			var users []string
+			for _, usage := range response.Users {
+				users = append(users, usage.Username)
+				if usage.Username == "alice" && (usage.Tenant != "alice" || usage.UpdateQuota != 10) {
+					t.Errorf("Expected the tenant and its quota, got %+v", usage)
+				}
+			}
+			if !reflect.DeepEqual(users, tt.expectedUsers) {
+				t.Errorf("Expected users %v, got %v", tt.expectedUsers, users)
+			}
+		})
+	}
+}
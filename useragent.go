@@ -0,0 +1,72 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxTrackedAgents bounds the number of distinct agent labels in the metrics
+const maxTrackedAgents = 50
+
+// agentTracker counts update requests per client software
+type agentTracker struct {
+	metrics *Metrics
+	mu      sync.Mutex
+	seen    map[string]bool
+}
+
+// newAgentTracker creates a tracker reporting to metrics
+func newAgentTracker(metrics *Metrics) *agentTracker {
+	metrics.Describe("dyndns_update_requests_total", "counter", "Number of update requests by User-Agent and result.")
+	return &agentTracker{metrics: metrics, seen: make(map[string]bool)}
+}
+
+// Observe counts a request from agent with result, grouping agents beyond
+// maxTrackedAgents as "other" to bound the metric cardinality
+func (t *agentTracker) Observe(agent, result string) {
+	name := agentName(agent)
+
+	t.mu.Lock()
+	if !t.seen[name] {
+		if len(t.seen) >= maxTrackedAgents {
+			name = "other"
+		} else {
+			t.seen[name] = true
+		}
+	}
+	t.mu.Unlock()
+
+	t.metrics.Inc("dyndns_update_requests_total", Labels{"agent": name, "result": result})
+}
+
+// agentName reduces a User-Agent to its product name, e.g. "ddclient/3.11.2" to "ddclient"
+func agentName(agent string) string {
+	fields := strings.Fields(agent)
+	if len(fields) == 0 {
+		return "none"
+	}
+	name, _, _ := strings.Cut(fields[0], "/")
+	name = strings.ToLower(name)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	return name
+}
+
+// checkUserAgent reports whether the User-Agent of r may send updates. The
+// dyndns2 protocol expects clients to identify themselves, so empty agents are
+// rejected when requireAgent is set, as are agents matching blockedAgents.
+func (s *DynDNSServer) checkUserAgent(r *http.Request) bool {
+	agent := strings.TrimSpace(r.UserAgent())
+	if agent == "" {
+		return !s.requireAgent
+	}
+	lower := strings.ToLower(agent)
+	for _, pattern := range s.blockedAgents {
+		if strings.Contains(lower, strings.ToLower(pattern)) {
+			return false
+		}
+	}
+	return true
+}
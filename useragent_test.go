@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAgentName(t *testing.T) {
+	tests := []struct {
+		agent    string
+		expected string
+	}{
+		{"ddclient/3.11.2", "ddclient"},
+		{"Fritz!Box DDNS/1.0.1", "fritz!box"},
+		{"inadyn/2.12.0 https://github.com/troglobit/inadyn", "inadyn"},
+		{"  ", "none"},
+		{"", "none"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.agent, func(t *testing.T) {
+			if result := agentName(tt.agent); result != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestAgentTrackerCardinality(t *testing.T) {
+	metrics := NewMetrics()
+	tracker := newAgentTracker(metrics)
+	for i := 0; i < maxTrackedAgents+5; i++ {
+		tracker.Observe(fmt.Sprintf("client%d/1.0", i), "accepted")
+	}
+
+	if value := metrics.Value("dyndns_update_requests_total", Labels{"agent": "client0", "result": "accepted"}); value != 1 {
+		t.Errorf("Expected 1 request from client0, got %v", value)
+	}
+	if value := metrics.Value("dyndns_update_requests_total", Labels{"agent": "other", "result": "accepted"}); value != 5 {
+		t.Errorf("Expected 5 requests grouped as other, got %v", value)
+	}
+}
+
+func TestHandleUpdateBadAgent(t *testing.T) {
+	tests := []struct {
+		name          string
+		requireAgent  bool
+		blockedAgents []string
+		userAgent     string
+		expectBad     bool
+	}{
+		{"empty agent allowed by default", false, nil, "", false},
+		{"empty agent required", true, nil, "", true},
+		{"blocked agent", false, []string{"BadBot"}, "badbot/1.0", true},
+		{"other agent", true, []string{"badbot"}, "ddclient/3.11.2", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, nil, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.requireAgent = tt.requireAgent
+			server.blockedAgents = tt.blockedAgents
+
+			req := httptest.NewRequest("GET", "/update?hostname=home.example.com&myip=1.2.3.4", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+
+			if bad := w.Body.String() == "badagent"; bad != tt.expectBad {
+				t.Errorf("Expected badagent %v, got body %q", tt.expectBad, w.Body.String())
+			}
+			result := "accepted"
+			if tt.expectBad {
+				result = "badagent"
+			}
+			if value := server.metrics.Value("dyndns_update_requests_total", Labels{"agent": agentName(tt.userAgent), "result": result}); value != 1 {
+				t.Errorf("Expected one %s request, got %v", result, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// defaultNameservers are Hetzner's authoritative nameservers, used when a zone lists none
+var defaultNameservers = []string{"ns1.first-ns.de", "robotns2.second-ns.de", "robotns3.second-ns.com"}
+
+// resolveFunc looks up the values of a record type for hostname on a specific nameserver
+type resolveFunc func(ctx context.Context, nameserver, hostname, recordType string) ([]string, error)
+
+// Verifier checks that an updated record is served by the authoritative nameservers
+type Verifier struct {
+	timeout  time.Duration
+	interval time.Duration
+	resolve  resolveFunc
+	metrics  *Metrics
+}
+
+// NewVerifier creates a verifier retrying every interval until timeout has passed
+func NewVerifier(timeout, interval time.Duration, metrics *Metrics) *Verifier {
+	metrics.Describe("dyndns_verification_total", "counter", "Number of post-update DNS verifications by result.")
+	metrics.Describe("dyndns_propagation_seconds_sum", "counter", "Total time until updated records were served.")
+	metrics.Describe("dyndns_propagation_seconds_count", "counter", "Number of successfully propagated updates.")
+
+	return &Verifier{
+		timeout:  timeout,
+		interval: interval,
+		resolve:  lookupOnNameserver,
+		metrics:  metrics,
+	}
+}
+
+// Verify waits until every nameserver serves value for hostname and returns the propagation latency
+func (v *Verifier) Verify(nameservers []string, hostname, recordType, value string) (time.Duration, error) {
+	if len(nameservers) == 0 {
+		nameservers = defaultNameservers
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+
+	pending := append([]string(nil), nameservers...)
+	for {
+		var remaining []string
+		for _, ns := range pending {
+			values, err := v.resolve(ctx, ns, hostname, recordType)
+			if err != nil || !containsIP(values, value) {
+				remaining = append(remaining, ns)
+			}
+		}
+		pending = remaining
+
+		if len(pending) == 0 {
+			latency := time.Since(start)
+			v.metrics.Inc("dyndns_verification_total", Labels{"result": "success"})
+			v.metrics.Add("dyndns_propagation_seconds_sum", nil, latency.Seconds())
+			v.metrics.Inc("dyndns_propagation_seconds_count", nil)
+			return latency, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			v.metrics.Inc("dyndns_verification_total", Labels{"result": "timeout"})
+			return time.Since(start), fmt.Errorf("%s %s not served with %s by %s after %s",
+				hostname, recordType, value, strings.Join(pending, ", "), v.timeout)
+		case <-time.After(v.interval):
+		}
+	}
+}
+
+// WaitForPropagation blocks until the authoritative nameservers serve value for
+// hostname or the verifier's timeout has passed. A timeout is only logged, the
+// wait delays the answer to the client but never fails the update.
+func (v *Verifier) WaitForPropagation(nameservers []string, hostname, recordType, value string) {
+	latency, err := v.Verify(nameservers, hostname, recordType, value)
+	if err != nil {
+		log.Printf("Answering update without confirmed propagation: %v", err)
+		return
+	}
+	log.Printf("%s %s -> %s served by authoritative nameservers after %s, answering update",
+		hostname, recordType, value, latency.Round(time.Millisecond))
+}
+
+// VerifyAsync runs Verify in the background and logs the outcome
+func (v *Verifier) VerifyAsync(nameservers []string, hostname, recordType, value string) {
+	go func() {
+		latency, err := v.Verify(nameservers, hostname, recordType, value)
+		if err != nil {
+			log.Printf("DNS verification failed: %v", err)
+			return
+		}
+		log.Printf("Verified %s %s -> %s on authoritative nameservers after %s",
+			hostname, recordType, value, latency.Round(time.Millisecond))
+	}()
+}
+
+// lookupOnNameserver resolves hostname directly against nameserver, bypassing the system resolver
+func lookupOnNameserver(ctx context.Context, nameserver, hostname, recordType string) ([]string, error) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, net.JoinHostPort(nameserver, "53"))
+		},
+	}
+
+	network := "ip4"
+	if recordType == "AAAA" {
+		network = "ip6"
+	}
+
+	ips, err := resolver.LookupIP(ctx, network, strings.TrimSuffix(hostname, ".")+".")
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		values = append(values, ip.String())
+	}
+	return values, nil
+}
+
+// containsIP reports whether values contains ip, comparing addresses rather than strings
+func containsIP(values []string, ip string) bool {
+	want := net.ParseIP(ip)
+	for _, value := range values {
+		if got := net.ParseIP(value); got != nil && got.Equal(want) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVerifierVerify(t *testing.T) {
+	var mu sync.Mutex
+	attempts := map[string]int{}
+
+	verifier := NewVerifier(time.Second, 10*time.Millisecond, NewMetrics())
+	verifier.resolve = func(ctx context.Context, nameserver, hostname, recordType string) ([]string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		attempts[nameserver]++
+		// ns2 only serves the new value on the third attempt
+		if nameserver == "ns2" && attempts[nameserver] < 3 {
+			return []string{"1.1.1.1"}, nil
+		}
+		return []string{"1.2.3.4"}, nil
+	}
+
+	if _, err := verifier.Verify([]string{"ns1", "ns2"}, "home.example.com", "A", "1.2.3.4"); err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if attempts["ns1"] != 1 || attempts["ns2"] != 3 {
+		t.Errorf("Unexpected attempts: %v", attempts)
+	}
+	if value := verifier.metrics.Value("dyndns_verification_total", Labels{"result": "success"}); value != 1 {
+		t.Errorf("Expected 1 successful verification, got %g", value)
+	}
+}
+
+func TestVerifierTimeout(t *testing.T) {
+	verifier := NewVerifier(50*time.Millisecond, 10*time.Millisecond, NewMetrics())
+	verifier.resolve = func(ctx context.Context, nameserver, hostname, recordType string) ([]string, error) {
+		return nil, errors.New("no such host")
+	}
+
+	if _, err := verifier.Verify(nil, "home.example.com", "AAAA", "2001:db8::1"); err == nil {
+		t.Error("Expected timeout error")
+	}
+	if value := verifier.metrics.Value("dyndns_verification_total", Labels{"result": "timeout"}); value != 1 {
+		t.Errorf("Expected 1 timed out verification, got %g", value)
+	}
+}
+
+func TestContainsIP(t *testing.T) {
+	if !containsIP([]string{"2001:db8:0:0:0:0:0:1"}, "2001:db8::1") {
+		t.Error("Expected equivalent IPv6 notations to match")
+	}
+	if containsIP([]string{"1.1.1.1"}, "1.2.3.4") {
+		t.Error("Expected different addresses not to match")
+	}
+}
+
+func TestUpdateWaitsForPropagation(t *testing.T) {
+	tests := []struct {
+		name            string
+		ip              string
+		servedAfter     int
+		expectedLookups int
+	}{
+		// The first two of the three default nameservers serve the new value in the second round
+		{name: "changed record waits until served", ip: "2.2.2.2", servedAfter: 3, expectedLookups: 5},
+		{name: "unchanged record answers immediately", ip: "1.1.1.1", expectedLookups: 0},
+		{name: "timeout still succeeds", ip: "2.2.2.2", servedAfter: 1000, expectedLookups: -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var writes []string
+			mockAPI := newOwnershipMockAPI(t, []DNSRecord{{ID: "rec1", Type: "A", Name: "home", Value: "1.1.1.1"}}, &writes)
+			defer mockAPI.Close()
+
+			client := NewClient("test-api-key")
+			client.BaseURL = mockAPI.URL
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			server.propagation = NewVerifier(100*time.Millisecond, 5*time.Millisecond, server.metrics)
+			lookups := 0
+			server.propagation.resolve = func(ctx context.Context, nameserver, hostname, recordType string) ([]string, error) {
+				lookups++
+				if lookups < tt.servedAfter {
+					return []string{"1.1.1.1"}, nil
+				}
+				return []string{tt.ip}, nil
+			}
+
+			if err := server.updateDNSRecord("home.example.com", tt.ip, "A"); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.expectedLookups >= 0 && lookups != tt.expectedLookups {
+				t.Errorf("Expected %d lookups, got %d", tt.expectedLookups, lookups)
+			}
+			if tt.expectedLookups < 0 && server.metrics.Value("dyndns_verification_total", Labels{"result": "timeout"}) != 1 {
+				t.Error("Expected the wait to time out")
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestCurrentBuildInfo(t *testing.T) {
+	defer func(v, c, d string) { version, commit, buildDate = v, c, d }(version, commit, buildDate)
+	version, commit, buildDate = "1.2.0", "abc123", "2026-01-02T03:04:05Z"
+
+	info := currentBuildInfo()
+	expected := BuildInfo{Version: "1.2.0", Commit: "abc123", BuildDate: "2026-01-02T03:04:05Z", GoVersion: runtime.Version()}
+	if info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, info)
+	}
+}
+
+func TestHandleVersion(t *testing.T) {
+	defer func(v string) { version = v }(version)
+	version = "1.2.0"
+
+	server := NewDynDNSServer(nil, "admin", "password", "8080")
+	w := httptest.NewRecorder()
+	server.handleVersion(w, httptest.NewRequest("GET", "/version", nil))
+
+	var info BuildInfo
+	if err := json.NewDecoder(w.Body).Decode(&info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if info.Version != "1.2.0" || info.GoVersion == "" {
+		t.Errorf("Unexpected build info %+v", info)
+	}
+
+	w = httptest.NewRecorder()
+	server.handleHealth(w, httptest.NewRequest("GET", "/health", nil))
+	var health map[string]interface{}
+	json.NewDecoder(w.Body).Decode(&health)
+	if health["version"] != "1.2.0" {
+		t.Errorf("Expected health version 1.2.0, got %v", health["version"])
+	}
+}
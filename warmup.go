@@ -0,0 +1,101 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// warmUpTypes are the record types remembered for each warmed hostname
+var warmUpTypes = []string{"A", "AAAA"}
+
+// warmUpHostnames returns the hostnames to prefetch at startup: the configured
+// hostnames and the deletable hostnames that are not patterns
+func warmUpHostnames(hostnames, deletable []string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, hostname := range append(hostnames[:len(hostnames):len(hostnames)], deletable...) {
+		hostname = parse.NormalizeHostname(hostname)
+		if hostname == "" || strings.HasPrefix(hostname, "*") || seen[hostname] {
+			continue
+		}
+		seen[hostname] = true
+		result = append(result, hostname)
+	}
+	return result
+}
+
+// warmUp prefetches the zones and records of hostnames, so the first updates
+// after a restart are served from the zone index and the lookup cache. Every
+// client lists its zones once and every zone is listed once, however many of
+// its hostnames are configured. It returns the number of hostnames whose zone
+// was found.
+func (s *DynDNSServer) warmUp(hostnames []string) int {
+	type zoneKey struct {
+		client *Client
+		zoneID string
+	}
+	zones := make(map[*Client][]Zone)
+	records := make(map[zoneKey][]DNSRecord)
+	warmedZones := make(map[zoneKey]bool)
+
+	warmed := 0
+	for _, hostname := range hostnames {
+		client, err := s.clientFor(hostname)
+		if err != nil {
+			log.Printf("Warm-up: %s: %v", hostname, err)
+			continue
+		}
+
+		// The zone index is filled by its first lookup, without it the zones
+		// are listed once per client here
+		var zone *Zone
+		var name string
+		if client.zones != nil {
+			zone, name, err = client.zoneFor(hostname)
+		} else {
+			list, ok := zones[client]
+			if !ok {
+				if list, err = client.GetZones(); err == nil {
+					zones[client] = list
+				}
+			}
+			zone, name = dyndns.ZoneFor(list, hostname)
+		}
+		if err != nil {
+			log.Printf("Warm-up: failed to get zones for %s: %v", hostname, err)
+			continue
+		}
+		if zone == nil {
+			log.Printf("Warm-up: no zone found for %s", hostname)
+			continue
+		}
+		warmed++
+		key := zoneKey{client, zone.ID}
+		warmedZones[key] = true
+		if s.lookups == nil {
+			continue
+		}
+
+		zoneRecords, ok := records[key]
+		if !ok {
+			if zoneRecords, err = client.GetAllRecords(zone.ID); err != nil {
+				log.Printf("Warm-up: failed to get records of %s: %v", zone.Name, err)
+				continue
+			}
+			records[key] = zoneRecords
+		}
+		for _, recordType := range warmUpTypes {
+			for _, record := range zoneRecords {
+				if record.Name == name && record.Type == recordType {
+					s.rememberRecord(hostname, recordType, zone, name, record.ID)
+					break
+				}
+			}
+		}
+	}
+	log.Printf("Warm-up: prefetched %d of %d hostnames in %d zones", warmed, len(hostnames), len(warmedZones))
+	return warmed
+}
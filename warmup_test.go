@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWarmUpHostnames(t *testing.T) {
+	got := warmUpHostnames([]string{"Home.example.com.", "nas.example.com"}, []string{"*.lab.example.com", "home.example.com", "old.example.com"})
+	expected := []string{"home.example.com", "nas.example.com", "old.example.com"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %v, got %v", expected, got)
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	tests := []struct {
+		name         string
+		zoneIndex    bool
+		directLookup bool
+		// expectedWarmUp and expectedUpdate are the API requests of the warm-up and the following update of home
+		expectedWarmUp []string
+		expectedUpdate []string
+	}{
+		{
+			name:           "direct lookup",
+			directLookup:   true,
+			expectedWarmUp: []string{"GET /zones", "GET /records"},
+			expectedUpdate: []string{"GET /records/mock1", "PUT /records/mock1"},
+		},
+		{
+			name:           "zone index",
+			zoneIndex:      true,
+			expectedWarmUp: []string{"GET /zones"},
+			expectedUpdate: []string{"GET /records", "PUT /records/mock1"},
+		},
+		{
+			name:           "zone index and direct lookup",
+			zoneIndex:      true,
+			directLookup:   true,
+			expectedWarmUp: []string{"GET /zones", "GET /records"},
+			expectedUpdate: []string{"GET /records/mock1", "PUT /records/mock1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeAPIServer(t,
+				DNSRecord{Type: "A", Name: "home", Value: "203.0.113.1"},
+				DNSRecord{Type: "AAAA", Name: "home", Value: "2001:db8::1"},
+				DNSRecord{Type: "A", Name: "nas", Value: "203.0.113.2"},
+			)
+			if tt.zoneIndex {
+				client.EnableZoneIndex(time.Hour)
+			}
+			server := NewDynDNSServer(client, "admin", "password", "8080")
+			if tt.directLookup {
+				server.lookups = newLookupCache()
+			}
+
+			warmed := server.warmUp([]string{"home.example.com", "nas.example.com", "vpn.example.org"})
+			if warmed != 2 {
+				t.Errorf("Expected 2 warmed hostnames, got %d", warmed)
+			}
+			if requests := fake.Requests(); !reflect.DeepEqual(requests, tt.expectedWarmUp) {
+				t.Errorf("Expected warm-up requests %v, got %v", tt.expectedWarmUp, requests)
+			}
+			if tt.directLookup {
+				for _, key := range [][2]string{{"home.example.com", "A"}, {"home.example.com", "AAAA"}, {"nas.example.com", "A"}} {
+					if _, ok := server.lookups.Get(key[0], key[1]); !ok {
+						t.Errorf("Expected %s (%s) to be cached", key[0], key[1])
+					}
+				}
+				if _, ok := server.lookups.Get("nas.example.com", "AAAA"); ok {
+					t.Errorf("Expected no cache entry for a missing record")
+				}
+			}
+
+			req := httptest.NewRequest("GET", "/nic/update?hostname=home.example.com&myip=203.0.113.9", nil)
+			req.SetBasicAuth("admin", "password")
+			w := httptest.NewRecorder()
+			server.handleUpdate(w, req)
+			if !strings.HasPrefix(w.Body.String(), "good") {
+				t.Fatalf("Unexpected response %q", w.Body.String())
+			}
+			if requests := fake.Requests()[len(tt.expectedWarmUp):]; !reflect.DeepEqual(requests, tt.expectedUpdate) {
+				t.Errorf("Expected update requests %v, got %v", tt.expectedUpdate, requests)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// WireGuardPeer is a peer whose endpoint follows the record of a tracked hostname
+type WireGuardPeer struct {
+	Interface  string
+	PublicKey  string
+	Hostname   string
+	Port       int
+	RecordType string // A or AAAA
+}
+
+// WireGuardHook updates peer endpoints with the new address when a tracked hostname changes
+type WireGuardHook struct {
+	peers []WireGuardPeer
+	// run executes wg with the given arguments, replaced in tests
+	run func(args ...string) error
+}
+
+// NewWireGuardHook creates a hook updating peers through the wg command
+func NewWireGuardHook(peers []WireGuardPeer) *WireGuardHook {
+	return &WireGuardHook{peers: peers, run: runWG}
+}
+
+// Handle sets the endpoint of all peers tracking the changed record. The event
+// carries the new address, so the update does not wait for DNS propagation.
+// wg set only configures the local interface and returns immediately.
+func (h *WireGuardHook) Handle(event Event) {
+	for _, peer := range h.peers {
+		if peer.Hostname != event.Hostname || peer.RecordType != event.Type {
+			continue
+		}
+
+		endpoint := net.JoinHostPort(event.NewValue, strconv.Itoa(peer.Port))
+		if err := h.run("set", peer.Interface, "peer", peer.PublicKey, "endpoint", endpoint); err != nil {
+			log.Printf("Failed to update WireGuard endpoint of %s on %s: %v", peer.Hostname, peer.Interface, err)
+			continue
+		}
+		log.Printf("Updated WireGuard endpoint of %s on %s to %s", peer.Hostname, peer.Interface, endpoint)
+	}
+}
+
+// runWG executes the wg command
+func runWG(args ...string) error {
+	output, err := exec.Command("wg", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// parseWireGuardPeers parses "interface:publickey@hostname:port" entries
+// separated by commas, "/AAAA" appended to the port follows the IPv6 record
+func parseWireGuardPeers(value string) ([]WireGuardPeer, error) {
+	var peers []WireGuardPeer
+	for _, entry := range splitList(value) {
+		iface, rest, ok := strings.Cut(entry, ":")
+		key, endpoint, ok2 := strings.Cut(rest, "@")
+		if !ok || !ok2 || iface == "" || key == "" {
+			return nil, fmt.Errorf("expected interface:publickey@hostname:port, got %q", entry)
+		}
+
+		peer := WireGuardPeer{Interface: iface, PublicKey: key, RecordType: "A"}
+		if endpoint, ok = strings.CutSuffix(endpoint, "/AAAA"); ok {
+			peer.RecordType = "AAAA"
+		}
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil || host == "" {
+			return nil, fmt.Errorf("invalid endpoint in %q", entry)
+		}
+		if peer.Port, err = strconv.Atoi(port); err != nil || peer.Port < 1 || peer.Port > 65535 {
+			return nil, fmt.Errorf("invalid port in %q", entry)
+		}
+		peer.Hostname = strings.ToLower(host)
+		peers = append(peers, peer)
+	}
+	return peers, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWireGuardPeers(t *testing.T) {
+	peers, err := parseWireGuardPeers("wg0:xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=@Home.example.com:51820, wg1:Abc+/def=@home.example.com:4500/AAAA")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []WireGuardPeer{
+		{Interface: "wg0", PublicKey: "xTIBA5rboUvnH4htodjb6e697QjLERt1NAB4mZqp8Dg=", Hostname: "home.example.com", Port: 51820, RecordType: "A"},
+		{Interface: "wg1", PublicKey: "Abc+/def=", Hostname: "home.example.com", Port: 4500, RecordType: "AAAA"},
+	}
+	if len(peers) != len(expected) || peers[0] != expected[0] || peers[1] != expected[1] {
+		t.Errorf("Expected %+v, got %+v", expected, peers)
+	}
+
+	for _, value := range []string{"wg0", "wg0:key", "wg0:key@home.example.com", "wg0:key@home.example.com:99999"} {
+		if _, err := parseWireGuardPeers(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
+
+func TestWireGuardHook(t *testing.T) {
+	peers, _ := parseWireGuardPeers("wg0:key4=@home.example.com:51820,wg0:key6=@home.example.com:51820/AAAA")
+	hook := NewWireGuardHook(peers)
+	var commands []string
+	hook.run = func(args ...string) error {
+		commands = append(commands, strings.Join(args, " "))
+		return nil
+	}
+
+	server := NewDynDNSServer(nil, "admin", "password", "8080")
+	server.events.Subscribe(hook.Handle, eventIPChange, eventRecordCreated)
+	server.notifyIPChange("home.example.com", "A", "1.1.1.1", "203.0.113.1")
+	server.events.Publish(Event{Kind: eventRecordCreated, Hostname: "home.example.com", Type: "AAAA", NewValue: "2001:db8::1"})
+	server.notifyIPChange("nas.example.com", "A", "1.1.1.1", "203.0.113.2")
+
+	expected := []string{
+		"set wg0 peer key4= endpoint 203.0.113.1:51820",
+		"set wg0 peer key6= endpoint [2001:db8::1]:51820",
+	}
+	if strings.Join(commands, ";") != strings.Join(expected, ";") {
+		t.Errorf("Expected %v, got %v", expected, commands)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+	"fritzbox-hetzner-dyndns/parse"
+)
+
+// zoneIndexMissRefresh is the minimum age of the index before a hostname
+// without a zone refreshes it, so misses cannot hammer the zones endpoint
+const zoneIndexMissRefresh = 10 * time.Second
+
+// zoneIndex maps zone names to zones, so the zone of a hostname is found by
+// looking up its suffixes instead of listing the zones for every update
+type zoneIndex struct {
+	mu        sync.Mutex
+	byName    map[string]Zone
+	refreshed time.Time
+	// interval is the age after which the zones are listed again
+	interval time.Duration
+	now      func() time.Time
+}
+
+// newZoneIndex creates an empty index refreshed every interval
+func newZoneIndex(interval time.Duration) *zoneIndex {
+	return &zoneIndex{interval: interval, now: time.Now}
+}
+
+// EnableZoneIndex keeps the zones of the client in memory, listing them again
+// every interval or when a hostname matches none of them
+func (c *Client) EnableZoneIndex(interval time.Duration) {
+	c.zones = newZoneIndex(interval)
+}
+
+// Stats returns the number of indexed zones and when they were listed
+func (x *zoneIndex) Stats() (int, time.Time) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return len(x.byName), x.refreshed
+}
+
+// Resolve returns the zone holding fqdn and the record name relative to it,
+// nil if no zone holds it. fetch lists the zones when the index is empty or
+// stale, a stale index is kept if that fails.
+func (x *zoneIndex) Resolve(fqdn string, fetch func() ([]Zone, error)) (*Zone, string, error) {
+	fqdn = parse.NormalizeHostname(fqdn)
+
+	// Concurrent updates wait for one refresh instead of listing the zones each
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.byName == nil || x.now().Sub(x.refreshed) >= x.interval {
+		if err := x.refresh(fetch); err != nil {
+			if x.byName == nil {
+				return nil, "", err
+			}
+			log.Printf("Failed to refresh the zone index, using zones from %s: %v", x.refreshed.Format(time.RFC3339), err)
+		}
+	}
+
+	zone, name := x.lookup(fqdn)
+	if zone == nil && x.now().Sub(x.refreshed) >= zoneIndexMissRefresh {
+		// The zone may have been created since the last refresh
+		if err := x.refresh(fetch); err != nil {
+			return nil, "", err
+		}
+		zone, name = x.lookup(fqdn)
+	}
+	return zone, name, nil
+}
+
+// refresh replaces the index with the listed zones, the caller holds mu
+func (x *zoneIndex) refresh(fetch func() ([]Zone, error)) error {
+	zones, err := fetch()
+	if err != nil {
+		return err
+	}
+	x.byName = make(map[string]Zone, len(zones))
+	for _, zone := range zones {
+		x.byName[parse.NormalizeHostname(zone.Name)] = zone
+	}
+	x.refreshed = x.now()
+	return nil
+}
+
+// lookup returns the deepest zone holding fqdn by trying its suffixes from
+// the longest, so nested zones win over their parents. The caller holds mu.
+func (x *zoneIndex) lookup(fqdn string) (*Zone, string) {
+	for suffix := fqdn; suffix != ""; {
+		if zone, ok := x.byName[suffix]; ok {
+			if suffix == fqdn {
+				return &zone, "@"
+			}
+			return &zone, strings.TrimSuffix(fqdn, "."+suffix)
+		}
+		_, rest, found := strings.Cut(suffix, ".")
+		if !found {
+			break
+		}
+		suffix = rest
+	}
+	return nil, ""
+}
+
+// zoneFor returns the zone holding fqdn and the record name relative to it,
+// from the zone index if it is enabled. The zone is nil if none holds fqdn.
+func (c *Client) zoneFor(fqdn string) (*Zone, string, error) {
+	if c.zones != nil {
+		return c.zones.Resolve(fqdn, c.GetZones)
+	}
+	zones, err := c.GetZones()
+	if err != nil {
+		return nil, "", err
+	}
+	zone, name := dyndns.ZoneFor(zones, fqdn)
+	return zone, name, nil
+}
+
+// findZoneFor resolves hostname with provider, using its zone index if it has one
+func findZoneFor(provider Provider, hostname string) (*Zone, string, error) {
+	if client, ok := provider.(*Client); ok {
+		return client.zoneFor(hostname)
+	}
+	zones, err := provider.GetZones()
+	if err != nil {
+		return nil, "", err
+	}
+	zone, name := dyndns.ZoneFor(zones, hostname)
+	return zone, name, nil
+}
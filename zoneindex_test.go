@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"fritzbox-hetzner-dyndns/dyndns"
+)
+
+func TestZoneIndexLookup(t *testing.T) {
+	index := newZoneIndex(time.Hour)
+	zones := []Zone{{ID: "zone1", Name: "example.com"}, {ID: "zone2", Name: "Lab.Example.com"}}
+	fetch := func() ([]Zone, error) { return zones, nil }
+
+	tests := []struct {
+		fqdn         string
+		expectedZone string
+		expectedName string
+	}{
+		{"example.com", "zone1", "@"},
+		{"home.example.com", "zone1", "home"},
+		{"a.b.example.com", "zone1", "a.b"},
+		{"nas.lab.example.com", "zone2", "nas"},
+		{"Lab.Example.com.", "zone2", "@"},
+		{"*.lab.example.com", "zone2", "*"},
+		{"example.org", "", ""},
+		{"com", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fqdn, func(t *testing.T) {
+			zone, name, err := index.Resolve(tt.fqdn, fetch)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			// The index agrees with the linear scan it replaces
+			scanned, scannedName := dyndns.ZoneFor(zones, tt.fqdn)
+			if tt.expectedZone == "" {
+				if zone != nil || scanned != nil {
+					t.Errorf("Expected no zone, got %v and %v", zone, scanned)
+				}
+				return
+			}
+			if zone == nil || zone.ID != tt.expectedZone || name != tt.expectedName {
+				t.Errorf("Expected %s/%s, got %v/%s", tt.expectedZone, tt.expectedName, zone, name)
+			}
+			if scanned.ID != zone.ID || scannedName != name {
+				t.Errorf("Index returned %s/%s, the scan %s/%s", zone.ID, name, scanned.ID, scannedName)
+			}
+		})
+	}
+}
+
+func TestZoneIndexRefresh(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	index := newZoneIndex(10 * time.Minute)
+	index.now = func() time.Time { return now }
+
+	fetches := 0
+	zones := []Zone{{ID: "zone1", Name: "example.com"}}
+	var fetchErr error
+	fetch := func() ([]Zone, error) {
+		fetches++
+		return zones, fetchErr
+	}
+	resolve := func(fqdn string) *Zone {
+		t.Helper()
+		zone, _, err := index.Resolve(fqdn, fetch)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		return zone
+	}
+
+	resolve("home.example.com")
+	resolve("nas.example.com")
+	if fetches != 1 {
+		t.Errorf("Expected zones to be listed once, got %d", fetches)
+	}
+
+	// A zone created meanwhile is found by the refresh of a miss, but misses
+	// right after a refresh answer from the index
+	zones = append(zones, Zone{ID: "zone2", Name: "example.org"})
+	if resolve("home.example.org") != nil || fetches != 1 {
+		t.Errorf("Expected a miss without refresh right after listing, got %d fetches", fetches)
+	}
+	now = now.Add(zoneIndexMissRefresh)
+	if zone := resolve("home.example.org"); zone == nil || zone.ID != "zone2" || fetches != 2 {
+		t.Errorf("Expected the miss to refresh the index, got %v after %d fetches", zone, fetches)
+	}
+
+	// Stale zones are kept while the API fails
+	now = now.Add(10 * time.Minute)
+	fetchErr = errors.New("unavailable")
+	if zone := resolve("home.example.com"); zone == nil || fetches != 3 {
+		t.Errorf("Expected the stale index to answer, got %v after %d fetches", zone, fetches)
+	}
+
+	empty := newZoneIndex(time.Minute)
+	if _, _, err := empty.Resolve("home.example.com", fetch); err == nil {
+		t.Errorf("Expected an error without zones")
+	}
+}
+
+func TestFindRecordZoneIndex(t *testing.T) {
+	fake, client := newFakeAPIServer(t, DNSRecord{Type: "A", Name: "home", Value: "203.0.113.1"})
+	client.EnableZoneIndex(time.Hour)
+
+	for i := 0; i < 3; i++ {
+		lookup, err := findRecord(client, "home.example.com", "A")
+		if err != nil || lookup.Existing == nil {
+			t.Fatalf("Expected the record, got %+v %v", lookup, err)
+		}
+	}
+	zoneRequests := 0
+	for _, request := range fake.Requests() {
+		if request == "GET /zones" {
+			zoneRequests++
+		}
+	}
+	if zoneRequests != 1 {
+		t.Errorf("Expected the zones to be listed once, got %d times", zoneRequests)
+	}
+}